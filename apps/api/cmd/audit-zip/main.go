@@ -1,70 +1,424 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/yourorg/yourapp/apps/api/internal/auditchain"
 	"github.com/yourorg/yourapp/apps/api/internal/auditzip"
+	"github.com/yourorg/yourapp/apps/api/internal/auth"
+	"github.com/yourorg/yourapp/apps/api/internal/auth/authprom"
+	"github.com/yourorg/yourapp/apps/api/internal/health"
 	"github.com/yourorg/yourapp/apps/api/internal/pint"
+	"github.com/yourorg/yourapp/apps/api/internal/ratelimit"
+	"github.com/yourorg/yourapp/apps/api/internal/rules"
 	"github.com/joho/godotenv"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 func main() {
 	_ = godotenv.Load(".env")
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
 	cfg := auditzip.LoadConfig()
-	storage := auditzip.NewInMemoryStorage()
+	// pint and auth read the same AUDIT_HASH_ALGORITHM, so this covers every chain.
+	if err := auditchain.ValidateAlgorithm(cfg.AuditHashAlgorithm); err != nil {
+		slog.Error("invalid AUDIT_HASH_ALGORITHM", "error", err)
+		os.Exit(1)
+	}
+	var storage auditzip.Storage
+	var memStorage *auditzip.InMemoryStorage
+	if cfg.StorageBackend == "s3" {
+		s3Storage, err := auditzip.NewS3Storage(context.Background(), cfg)
+		if err != nil {
+			slog.Error("s3 storage init failed", "error", err)
+			os.Exit(1)
+		}
+		storage = s3Storage
+	} else {
+		var err error
+		memStorage, err = auditzip.NewInMemoryStorageWithBaseURL(cfg.PublicBaseURL)
+		if err != nil {
+			slog.Error("in-memory storage init failed", "error", err)
+			os.Exit(1)
+		}
+		memStorage.SetSigningSecret(cfg.StorageSigningSecret)
+		storage = memStorage
+	}
 	queue := auditzip.NewJobQueue(storage, cfg)
+	queue.StartReaper(ctx)
 	audit := auditzip.NewMemoryAuditRecorder()
-	svc := auditzip.NewService(cfg, queue, audit, slog.Default())
+	rateEvents := ratelimit.LogRecorder{Logger: slog.Default()}
+	svc := auditzip.NewService(cfg, queue, audit, slog.Default()).WithRateLimitRecorder(rateEvents)
 
 	// JP PINT invoice service (shares server for local dev).
 	pCfg := pint.LoadConfig()
-	pStorage := pint.NewInMemoryStorage()
+	pStorage, err := pint.NewInMemoryStorageWithBaseURL(pCfg.PublicBaseURL)
+	if err != nil {
+		slog.Error("invoice storage init failed", "error", err)
+		os.Exit(1)
+	}
+	pStorage.SetSigningSecret(pCfg.StorageSigningSecret)
 	pAudit := pint.NewMemoryAuditRecorder()
 	pSvc := pint.NewService(pCfg, pStorage, pAudit, slog.Default())
 
+	// Probes: /readyz checks both storages and, when PDFs are rendered, that
+	// Chromium can launch (cached so probes don't start a browser each time).
+	checker := health.NewChecker(5 * time.Second)
+	checker.Add("audit_storage", health.StorageCheck(storage, "_health/readyz"))
+	checker.Add("invoice_storage", health.StorageCheck(pStorage, "_health/readyz"))
+	if pCfg.PDFEnabled {
+		checker.Add("chromium", health.Cached(pSvc.PDFReady, pCfg.PDFReadinessTTL))
+	}
+
+	// Archive downloads, audit log exports and stored invoices take the tenant
+	// from the API key rather than a header, so one tenant cannot read or void
+	// another's data.
+	authCfg := auth.LoadConfig()
+	if err := authCfg.KeyConfig().Validate(); err != nil {
+		slog.Error("invalid API key layout", "error", err)
+		os.Exit(1)
+	}
+	authOpts, err := authMiddlewareOptions(authCfg, rateEvents, prometheus.DefaultRegisterer)
+	if err != nil {
+		slog.Error("auth middleware init failed", "error", err)
+		os.Exit(1)
+	}
+	authStore := auth.NewInMemoryAPIKeyStore(authCfg)
+	authAudit := newAuthAuditRecorder(authCfg)
+	auth.NewKeyExpirySweeper(authStore, authAudit, authCfg, slog.Default()).Start(ctx)
+	auth.NewAuditPruner(authAudit, authCfg, slog.Default()).Start(ctx)
+
+	// The integrity self-check re-verifies auth audit chains and the hashes.txt
+	// manifest of every retained archive.
+	var integrityAlert auth.IntegrityAlerter
+	if authCfg.IntegrityAlertWebhook != "" {
+		integrityAlert = auth.WebhookIntegrityAlerter(authCfg.IntegrityAlertWebhook, nil, slog.Default())
+	}
+	integrity := auth.NewIntegrityChecker(authAudit, authCfg, integrityAlert, slog.Default())
+	integrity.AddManifests(queue)
+	integrity.Start(ctx)
+
+	handler := newRouter(routes{
+		cfg:       cfg,
+		svc:       svc,
+		pCfg:      pCfg,
+		pSvc:      pSvc,
+		pStorage:  pStorage,
+		checker:   checker,
+		authCfg:   authCfg,
+		authStore: authStore,
+		authAudit: authAudit,
+		authOpts:  authOpts,
+		metrics:   promhttp.Handler(),
+		storage:   memStorage,
+	})
+
+	tlsCfg, err := serverTLSConfig(cfg, authCfg)
+	if err != nil {
+		slog.Error("tls init failed", "error", err)
+		os.Exit(1)
+	}
+	addr := ":8080"
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		slog.Error("listen failed", "addr", addr, "error", err)
+		os.Exit(1)
+	}
+	if tlsCfg != nil {
+		ln = tls.NewListener(ln, tlsCfg)
+	}
+	slog.Info("audit-zip api listening", "addr", addr)
+	err = serve(ctx, &http.Server{Handler: handler}, ln, queue, cfg.ShutdownTimeout)
+	_ = pSvc.Close()
+	if err != nil {
+		slog.Error("unclean shutdown", "error", err)
+		os.Exit(1)
+	}
+	slog.Info("server stopped")
+}
+
+// routes are the services newRouter mounts.
+type routes struct {
+	cfg       auditzip.Config
+	svc       auditzip.Service
+	pCfg      pint.Config
+	pSvc      pint.Service
+	pStorage  *pint.InMemoryStorage
+	checker   *health.Checker
+	authCfg   auth.Config
+	authStore *auth.InMemoryAPIKeyStore
+	authAudit *auth.InMemoryAuthAuditRecorder
+	// authOpts configure the auth middleware guarding every authenticated route.
+	authOpts []auth.MiddlewareOption
+	// metrics serves /metrics when set.
+	metrics http.Handler
+	// storage serves signed archive links under auditzip.StoragePath when
+	// archives are kept in memory; nil with S3, whose URLs point at S3.
+	storage *auditzip.InMemoryStorage
+}
+
+// newRouter mounts every endpoint. The /auth routes share rt.authStore with
+// the middleware guarding the rest, so tenants and keys created there are the
+// ones that authenticate downloads and audit log exports.
+func newRouter(rt routes) http.Handler {
+	svc, pSvc := rt.svc, rt.pSvc
+	authn := auth.Middleware(rt.authStore, rt.authAudit, rt.authCfg, slog.Default(), rt.authOpts...)
+	requireAuditRead := chi.Chain(authn, auth.RequireScope(auth.Scopes.AuditRead))
+	requireInvoiceRead := chi.Chain(authn, auth.RequireScope(auth.Scopes.InvoiceRead))
+	requireInvoiceWrite := chi.Chain(authn, auth.RequireScope(auth.Scopes.InvoiceWrite))
+
 	router := chi.NewRouter()
-	router.Use(corsMiddleware(cfg.AllowedOrigins))
+	router.Use(corsMiddleware(rt.cfg))
 	handler := auditzip.HandlerFromMuxWithBaseURL(svc, router, "")
+	router.Get("/healthz", rt.checker.Healthz)
+	router.Get("/readyz", rt.checker.Readyz)
+	if rt.metrics != nil {
+		router.Handle("/metrics", rt.metrics)
+	}
+	router.Get("/validation/rules", rules.Handler)
+	router.Post("/audit/zip/estimate", svc.EstimateAuditZip)
+	router.Get("/audit/jobs", svc.ListAuditZipJobs)
+	router.Get("/audit/jobs/{jobId}/events", func(w http.ResponseWriter, r *http.Request) {
+		svc.StreamAuditZipJobEvents(w, r, chi.URLParam(r, "jobId"))
+	})
+	// ?module=pint exports the invoice service's chain instead of auditzip's.
+	router.With(requireAuditRead...).Get("/audit/log", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("module") == "pint" {
+			pSvc.ExportAuditLog(w, r)
+			return
+		}
+		svc.ExportAuditLog(w, r)
+	})
+	router.With(requireAuditRead...).Get("/audit/jobs/{jobId}/download", func(w http.ResponseWriter, r *http.Request) {
+		svc.DownloadAuditZipArtifact(w, r, chi.URLParam(r, "jobId"))
+	})
+	if rt.storage != nil {
+		router.Get(auditzip.StoragePath+"*", auditzip.StorageDownloadHandler(rt.storage))
+	}
 
 	// Invoice endpoints
 	router.Post("/invoices/validate", pSvc.ValidateInvoice)
-	router.Post("/invoices", pSvc.IssueInvoice)
-	router.Get("/invoices/{id}", func(w http.ResponseWriter, r *http.Request) {
+	router.Post("/invoices/validate/stream", pSvc.ValidateInvoiceStream)
+	router.Post("/invoices/validate-batch", pSvc.ValidateInvoiceBatch)
+	router.With(requireInvoiceWrite...).Post("/invoices", pSvc.IssueInvoice)
+	router.With(requireInvoiceWrite...).Post("/invoices/preview", pSvc.PreviewInvoicePDF)
+	router.With(requireInvoiceRead...).Get("/invoices", pSvc.ListInvoices)
+	router.With(requireInvoiceRead...).Get("/invoices/{id}", func(w http.ResponseWriter, r *http.Request) {
 		pSvc.GetInvoice(w, r, chi.URLParam(r, "id"))
 	})
-	router.Get("/storage/*", func(w http.ResponseWriter, r *http.Request) {
-		key := strings.TrimPrefix(r.URL.Path, "/storage/")
-		body, ctype, err := pStorage.GetObject(r.Context(), key)
-		if err != nil {
-			http.NotFound(w, r)
-			return
-		}
-		w.Header().Set("Content-Type", ctype)
-		_, _ = w.Write(body)
+	router.With(requireInvoiceWrite...).Post("/invoices/{id}/void", func(w http.ResponseWriter, r *http.Request) {
+		pSvc.VoidInvoice(w, r, chi.URLParam(r, "id"))
+	})
+	router.With(requireInvoiceRead...).Get("/invoices/{id}/xml", func(w http.ResponseWriter, r *http.Request) {
+		pSvc.GetInvoiceXML(w, r, chi.URLParam(r, "id"))
+	})
+	router.With(requireInvoiceRead...).Get("/invoices/{id}/pdf", func(w http.ResponseWriter, r *http.Request) {
+		pSvc.GetInvoicePDF(w, r, chi.URLParam(r, "id"))
+	})
+	router.With(requireInvoiceRead...).Get("/invoices/{id}/verify-pdf", func(w http.ResponseWriter, r *http.Request) {
+		pSvc.VerifyInvoicePDF(w, r, chi.URLParam(r, "id"))
 	})
+	router.Get("/storage/*", pint.DownloadHandler(rt.pStorage, pint.NewDownloadThrottle(rt.pCfg.DownloadConcurrency, rt.pCfg.DownloadQueueWait)))
 
-	addr := ":8080"
-	slog.Info("audit-zip api listening", "addr", addr)
-	if err := http.ListenAndServe(addr, handler); err != nil {
-		slog.Error("server stopped", "error", err)
+	// Tenant and key administration. Each handler checks its own scope;
+	// onboarding a tenant needs no key, but one is honoured when sent.
+	authHandler := auth.NewHandler(rt.authStore, rt.authAudit, rt.authCfg, slog.Default())
+	router.With(optionalAuth(authn, rt.authCfg)).Post("/auth/tenants", authHandler.CreateTenant)
+	router.Group(func(r chi.Router) {
+		r.Use(authn)
+		r.Get("/auth/me", authHandler.WhoAmI)
+		r.Patch("/auth/tenants/{tenantId}", func(w http.ResponseWriter, r *http.Request) {
+			authHandler.UpdateTenantStatus(w, r, chi.URLParam(r, "tenantId"))
+		})
+		r.Delete("/auth/tenants/{tenantId}", func(w http.ResponseWriter, r *http.Request) {
+			authHandler.DeleteTenant(w, r, chi.URLParam(r, "tenantId"))
+		})
+		r.Post("/auth/keys", authHandler.CreateAPIKey)
+		r.Get("/auth/keys", authHandler.ListAPIKeys)
+		r.Post("/auth/keys/revoke-all", authHandler.RevokeAllAPIKeys)
+		r.Delete("/auth/keys/{keyId}", func(w http.ResponseWriter, r *http.Request) {
+			authHandler.RevokeAPIKey(w, r, chi.URLParam(r, "keyId"))
+		})
+		r.Post("/auth/keys/{keyId}/rotate", func(w http.ResponseWriter, r *http.Request) {
+			authHandler.RotateAPIKey(w, r, chi.URLParam(r, "keyId"))
+		})
+		r.Get("/auth/audit/verify", authHandler.VerifyAuditChain)
+		r.Post("/auth/audit/repair", authHandler.RepairAuditChain)
+	})
+	return handler
+}
+
+// authMiddlewareOptions builds the optional auth middleware behaviour enabled
+// by cfg, reporting rate-limit rejections to rateEvents and auth outcome
+// metrics to collectors registered with reg. Bearer tokens from an upstream
+// IdP are accepted alongside API keys when a JWT secret or public key is
+// configured.
+func authMiddlewareOptions(cfg auth.Config, rateEvents ratelimit.Recorder, reg prometheus.Registerer) ([]auth.MiddlewareOption, error) {
+	jwtVerifier, err := auth.NewJWTVerifier(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("jwt verifier: %w", err)
+	}
+	metrics, err := authprom.New(reg)
+	if err != nil {
+		return nil, fmt.Errorf("auth metrics: %w", err)
+	}
+	opts := []auth.MiddlewareOption{
+		auth.WithMetrics(metrics),
+		auth.WithJWTVerifier(jwtVerifier),
+		// Per-key limits, defaulted and capped by the tenant's plan
+		auth.WithRateLimiter(auth.NewRateLimiter(cfg.RateLimitPerMinute, time.Minute)),
+		auth.WithRateLimitRecorder(rateEvents),
 	}
+	if cfg.EnableMTLS {
+		opts = append(opts, auth.WithMTLSResolver(cfg.MTLSIdentities))
+	}
+	return opts, nil
+}
+
+// newAuthAuditRecorder returns the auth audit recorder configured from cfg
+// before anything writes to it, so every entry, including the expiry
+// sweeper's and the pruner's, is sealed with AUDIT_HASH_ALGORITHM.
+func newAuthAuditRecorder(cfg auth.Config) *auth.InMemoryAuthAuditRecorder {
+	audit := auth.NewInMemoryAuthAuditRecorder()
+	audit.SetHashAlgorithm(cfg.AuditHashAlgorithm)
+	if cfg.AuditRepairSigningKey != "" {
+		audit.EnableChainRepair([]byte(cfg.AuditRepairSigningKey))
+	}
+	return audit
 }
 
-// corsMiddleware allows configured origins for dev (e.g., Next.js on :3000).
-func corsMiddleware(allowed []string) func(http.Handler) http.Handler {
+// serverTLSConfig returns the listener's TLS configuration, or nil to serve
+// plain HTTP. With mTLS enabled the server must terminate TLS itself and
+// verifies client certificates, when offered, against authCfg.MTLSClientCAFile;
+// requests without one still authenticate by API key.
+func serverTLSConfig(cfg auditzip.Config, authCfg auth.Config) (*tls.Config, error) {
+	if cfg.TLSCertFile == "" && cfg.TLSKeyFile == "" {
+		if authCfg.EnableMTLS {
+			return nil, errors.New("AUTH_ENABLE_MTLS requires AUDIT_TLS_CERT_FILE and AUDIT_TLS_KEY_FILE")
+		}
+		return nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(cfg.TLSCertFile, cfg.TLSKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("load server certificate: %w", err)
+	}
+	tlsCfg := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	if !authCfg.EnableMTLS {
+		return tlsCfg, nil
+	}
+	if authCfg.MTLSClientCAFile == "" {
+		return nil, errors.New("AUTH_ENABLE_MTLS requires AUTH_MTLS_CLIENT_CA")
+	}
+	caPEM, err := os.ReadFile(authCfg.MTLSClientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("read client CA bundle: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return nil, fmt.Errorf("no certificates in client CA bundle %s", authCfg.MTLSClientCAFile)
+	}
+	tlsCfg.ClientCAs = pool
+	tlsCfg.ClientAuth = tls.VerifyClientCertIfGiven
+	return tlsCfg, nil
+}
+
+// optionalAuth runs authn for requests carrying credentials, as judged by
+// auth.CredentialsPresent, and passes the rest through without an actor.
+func optionalAuth(authn func(http.Handler) http.Handler, cfg auth.Config) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		authed := authn(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !auth.CredentialsPresent(r, cfg) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			authed.ServeHTTP(w, r)
+		})
+	}
+}
+
+// drainer is the part of the job queue serve waits on while shutting down.
+type drainer interface {
+	Shutdown(ctx context.Context) error
+}
+
+// serve runs srv on ln until ctx is done, then stops accepting connections and
+// waits for in-flight requests and the queue's running jobs, together bounded
+// by timeout (0 waits indefinitely). Connections still open at the deadline,
+// such as event streams, are closed. It returns nil only for a clean shutdown.
+func serve(ctx context.Context, srv *http.Server, ln net.Listener, queue drainer, timeout time.Duration) error {
+	served := make(chan error, 1)
+	go func() { served <- srv.Serve(ln) }()
+
+	var serveErr error
+	select {
+	case serveErr = <-served:
+	case <-ctx.Done():
+	}
+	slog.Info("shutting down", "timeout", timeout)
+
+	shutdownCtx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		shutdownCtx, cancel = context.WithTimeout(shutdownCtx, timeout)
+		defer cancel()
+	}
+	httpErr := srv.Shutdown(shutdownCtx)
+	if httpErr != nil {
+		_ = srv.Close()
+	}
+	queueErr := queue.Shutdown(shutdownCtx)
+	if serveErr == nil {
+		serveErr = <-served
+	}
+	if errors.Is(serveErr, http.ErrServerClosed) {
+		serveErr = nil
+	}
+	return errors.Join(serveErr, httpErr, queueErr)
+}
+
+// corsMiddleware allows configured origins for dev (e.g., Next.js on :3000),
+// advertising cfg.AllowedMethods and cfg.AllowedHeaders. Preflights are
+// cacheable for cfg.CORSMaxAge, and their requested headers are echoed back
+// when all of them are allowlisted.
+func corsMiddleware(cfg auditzip.Config) func(http.Handler) http.Handler {
+	methods := strings.Join(cfg.AllowedMethods, ",")
+	headers := strings.Join(cfg.AllowedHeaders, ", ")
+	maxAge := strconv.Itoa(int(cfg.CORSMaxAge.Seconds()))
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			origin := r.Header.Get("Origin")
-			if origin != "" && isAllowedOrigin(origin, allowed) {
+			if origin != "" && isAllowedOrigin(origin, cfg.AllowedOrigins) {
 				w.Header().Set("Access-Control-Allow-Origin", origin)
-				w.Header().Set("Vary", "Origin")
+				w.Header().Add("Vary", "Origin")
 				w.Header().Set("Access-Control-Allow-Credentials", "true")
-				w.Header().Set("Access-Control-Allow-Headers", "Content-Type, X-Correlation-Id, X-Tenant-Id, Idempotency-Key, Authorization")
-				w.Header().Set("Access-Control-Allow-Methods", "GET,POST,OPTIONS")
+				w.Header().Set("Access-Control-Allow-Headers", headers)
+				w.Header().Set("Access-Control-Allow-Methods", methods)
+				if r.Method == http.MethodOptions {
+					w.Header().Add("Vary", "Access-Control-Request-Headers")
+					if requested := r.Header.Get("Access-Control-Request-Headers"); requested != "" && allHeadersAllowed(requested, cfg.AllowedHeaders) {
+						w.Header().Set("Access-Control-Allow-Headers", requested)
+					}
+					if cfg.CORSMaxAge > 0 {
+						w.Header().Set("Access-Control-Max-Age", maxAge)
+					}
+				}
 			}
 			if r.Method == http.MethodOptions {
 				w.WriteHeader(http.StatusNoContent)
@@ -75,6 +429,28 @@ func corsMiddleware(allowed []string) func(http.Handler) http.Handler {
 	}
 }
 
+// allHeadersAllowed reports whether every header in the comma-separated
+// requested list is in allowed, ignoring case.
+func allHeadersAllowed(requested string, allowed []string) bool {
+	for _, h := range strings.Split(requested, ",") {
+		h = strings.TrimSpace(h)
+		if h == "" {
+			continue
+		}
+		found := false
+		for _, a := range allowed {
+			if strings.EqualFold(a, h) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
 func isAllowedOrigin(origin string, allowed []string) bool {
 	if len(allowed) == 0 {
 		return false
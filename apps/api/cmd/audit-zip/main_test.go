@@ -0,0 +1,508 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/yourorg/yourapp/apps/api/internal/auditchain"
+	"github.com/yourorg/yourapp/apps/api/internal/auditzip"
+	"github.com/yourorg/yourapp/apps/api/internal/auth"
+	"github.com/yourorg/yourapp/apps/api/internal/health"
+	"github.com/yourorg/yourapp/apps/api/internal/pint"
+	"github.com/yourorg/yourapp/apps/api/internal/ratelimit"
+)
+
+type fakeQueue struct {
+	shutdown chan struct{}
+}
+
+func (q *fakeQueue) Shutdown(context.Context) error {
+	close(q.shutdown)
+	return nil
+}
+
+func TestServe_ShutdownWaitsForInFlightRequest(t *testing.T) {
+	started := make(chan struct{})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, _ *http.Request) {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		_, _ = io.WriteString(w, "done")
+	})
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
+	queue := &fakeQueue{shutdown: make(chan struct{})}
+	served := make(chan error, 1)
+	go func() { served <- serve(ctx, &http.Server{Handler: mux}, ln, queue, 5*time.Second) }()
+
+	type result struct {
+		body string
+		err  error
+	}
+	responses := make(chan result, 1)
+	go func() {
+		resp, err := http.Get("http://" + ln.Addr().String() + "/slow")
+		if err != nil {
+			responses <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		responses <- result{body: string(body), err: err}
+	}()
+
+	<-started
+	stop()
+
+	res := <-responses
+	if res.err != nil || res.body != "done" {
+		t.Fatalf("expected the in-flight request to complete, got %q, %v", res.body, res.err)
+	}
+	if err := <-served; err != nil {
+		t.Fatalf("expected a clean shutdown, got %v", err)
+	}
+	select {
+	case <-queue.shutdown:
+	default:
+		t.Fatal("expected the job queue to be shut down")
+	}
+	if _, err := http.Get("http://" + ln.Addr().String() + "/slow"); err == nil {
+		t.Fatal("expected new connections to be refused after shutdown")
+	}
+}
+
+func TestServe_TimeoutIsUnclean(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	started := make(chan struct{})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/stuck", func(http.ResponseWriter, *http.Request) {
+		close(started)
+		<-release
+	})
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- serve(ctx, &http.Server{Handler: mux}, ln, &fakeQueue{shutdown: make(chan struct{})}, 50*time.Millisecond)
+	}()
+	go func() { _, _ = http.Get("http://" + ln.Addr().String() + "/stuck") }()
+
+	<-started
+	stop()
+	if err := <-served; err == nil {
+		t.Fatal("expected an error when in-flight requests outlive the timeout")
+	}
+}
+
+func TestCORSMiddleware_Preflight(t *testing.T) {
+	cfg := auditzip.LoadConfig()
+	cfg.AllowedOrigins = []string{"http://localhost:3000"}
+	cfg.AllowedMethods = []string{"GET", "POST", "DELETE"}
+	cfg.AllowedHeaders = []string{"Content-Type", "X-Tenant-Id"}
+	handler := corsMiddleware(cfg)(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		t.Fatal("preflight must not reach the handler")
+	}))
+
+	preflight := func(requestHeaders string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodOptions, "/audit/zip", nil)
+		r.Header.Set("Origin", "http://localhost:3000")
+		r.Header.Set("Access-Control-Request-Method", "POST")
+		if requestHeaders != "" {
+			r.Header.Set("Access-Control-Request-Headers", requestHeaders)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	w := preflight("x-tenant-id,content-type")
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", w.Code)
+	}
+	if got := w.Header().Get("Access-Control-Max-Age"); got != "600" {
+		t.Fatalf("expected default max-age 600, got %q", got)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Methods"); got != "GET,POST,DELETE" {
+		t.Fatalf("expected configured methods, got %q", got)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Headers"); got != "x-tenant-id,content-type" {
+		t.Fatalf("expected allowlisted request headers echoed, got %q", got)
+	}
+
+	w = preflight("X-Tenant-Id, X-Unknown")
+	if got := w.Header().Get("Access-Control-Allow-Headers"); got != "Content-Type, X-Tenant-Id" {
+		t.Fatalf("expected the allowlist when a requested header is not allowed, got %q", got)
+	}
+
+	r := httptest.NewRequest(http.MethodOptions, "/audit/zip", nil)
+	r.Header.Set("Origin", "http://evil.example")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Header().Get("Access-Control-Allow-Origin") != "" || w.Header().Get("Access-Control-Max-Age") != "" {
+		t.Fatalf("expected no CORS headers for a disallowed origin, got %v", w.Header())
+	}
+}
+
+// newTestRouter wires newRouter with in-memory services and cheap key hashing.
+func newTestRouter(t *testing.T) http.Handler {
+	t.Helper()
+	cfg := auditzip.LoadConfig()
+	storage, err := auditzip.NewInMemoryStorageWithBaseURL(cfg.PublicBaseURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc := auditzip.NewService(cfg, auditzip.NewJobQueue(storage, cfg), auditzip.NewMemoryAuditRecorder(), nil)
+
+	pCfg := pint.LoadConfig()
+	pCfg.PDFEnabled = false
+	pStorage, err := pint.NewInMemoryStorageWithBaseURL(pCfg.PublicBaseURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pSvc := pint.NewService(pCfg, pStorage, pint.NewMemoryAuditRecorder(), nil)
+	t.Cleanup(func() { _ = pSvc.Close() })
+
+	authCfg := auth.LoadConfig()
+	authCfg.BcryptCost = 4
+	reg := prometheus.NewRegistry()
+	authOpts, err := authMiddlewareOptions(authCfg, ratelimit.NewMemoryRecorder(), reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newRouter(routes{
+		cfg:       cfg,
+		svc:       svc,
+		pCfg:      pCfg,
+		pSvc:      pSvc,
+		pStorage:  pStorage,
+		checker:   health.NewChecker(time.Second),
+		authCfg:   authCfg,
+		authStore: auth.NewInMemoryAPIKeyStore(authCfg),
+		authAudit: newAuthAuditRecorder(authCfg),
+		authOpts:  authOpts,
+		metrics:   promhttp.HandlerFor(reg, promhttp.HandlerOpts{}),
+		storage:   storage,
+	})
+}
+
+// send serves a request with an optional API key and JSON body.
+func send(h http.Handler, method, target, key, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	if key != "" {
+		r.Header.Set("Authorization", "Bearer "+key)
+	}
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+// TestNewRouter_KeysFromAuthRoutesAuthenticate tests that a tenant onboarded
+// through /auth/tenants, and keys it mints through /auth/keys, are accepted by
+// the guarded audit routes.
+func TestNewRouter_KeysFromAuthRoutesAuthenticate(t *testing.T) {
+	router := newTestRouter(t)
+
+	if w := send(router, http.MethodGet, "/audit/log", "", ""); w.Code != http.StatusUnauthorized {
+		t.Fatalf("anonymous /audit/log: expected 401, got %d", w.Code)
+	}
+
+	w := send(router, http.MethodPost, "/auth/tenants", "", `{"id":"acme","name":"Acme"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create tenant: expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var tenant auth.CreateTenantResponse
+	if err := json.NewDecoder(w.Body).Decode(&tenant); err != nil || tenant.InitialKey.RawKey == "" {
+		t.Fatalf("expected an initial key, got %+v (%v)", tenant, err)
+	}
+	adminKey := tenant.InitialKey.RawKey
+
+	if w := send(router, http.MethodGet, "/auth/me", adminKey, ""); w.Code != http.StatusOK {
+		t.Fatalf("/auth/me: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	w = send(router, http.MethodPost, "/auth/keys", adminKey, `{"name":"Auditor","scopes":["audit:read"]}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create key: expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var key auth.CreateAPIKeyResponse
+	if err := json.NewDecoder(w.Body).Decode(&key); err != nil || key.RawKey == "" {
+		t.Fatalf("expected a raw key, got %+v (%v)", key, err)
+	}
+
+	if w := send(router, http.MethodGet, "/audit/log", key.RawKey, ""); w.Code != http.StatusOK {
+		t.Fatalf("/audit/log: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := send(router, http.MethodGet, "/audit/jobs/"+uuid.NewString()+"/download", key.RawKey, ""); w.Code != http.StatusNotFound {
+		t.Fatalf("download of an unknown job: expected 404, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := send(router, http.MethodGet, "/invoices/"+uuid.NewString()+"/xml", key.RawKey, ""); w.Code != http.StatusForbidden {
+		t.Fatalf("invoice xml without invoice:read: expected 403, got %d", w.Code)
+	}
+}
+
+// createTenant onboards a tenant through /auth/tenants and returns its initial admin key.
+func createTenant(t *testing.T, h http.Handler, id string) string {
+	t.Helper()
+	w := send(h, http.MethodPost, "/auth/tenants", "", `{"id":"`+id+`","name":"`+id+`"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create tenant %s: expected 201, got %d: %s", id, w.Code, w.Body.String())
+	}
+	var tenant auth.CreateTenantResponse
+	if err := json.NewDecoder(w.Body).Decode(&tenant); err != nil {
+		t.Fatal(err)
+	}
+	return tenant.InitialKey.RawKey
+}
+
+// TestNewRouter_KeysAreTenantScoped tests that one tenant can neither revoke
+// nor rotate another tenant's key.
+func TestNewRouter_KeysAreTenantScoped(t *testing.T) {
+	router := newTestRouter(t)
+	acmeKey := createTenant(t, router, "acme")
+	otherKey := createTenant(t, router, "other")
+
+	w := send(router, http.MethodGet, "/auth/keys", acmeKey, "")
+	var keys auth.ListAPIKeysResponse
+	if err := json.NewDecoder(w.Body).Decode(&keys); err != nil || len(keys.Keys) != 1 {
+		t.Fatalf("expected acme's initial key, got %+v (%v)", keys, err)
+	}
+	acmeKeyID := keys.Keys[0].ID
+
+	if w := send(router, http.MethodPost, "/auth/keys/"+acmeKeyID+"/rotate", otherKey, ""); w.Code != http.StatusNotFound {
+		t.Fatalf("cross-tenant rotate: expected 404, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := send(router, http.MethodDelete, "/auth/keys/"+acmeKeyID, otherKey, ""); w.Code != http.StatusNotFound {
+		t.Fatalf("cross-tenant revoke: expected 404, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := send(router, http.MethodGet, "/auth/me", acmeKey, ""); w.Code != http.StatusOK {
+		t.Fatalf("acme's key after cross-tenant attempts: expected 200, got %d", w.Code)
+	}
+}
+
+// TestNewRouter_PlatformAdminTenantCannotBeRegistered tests that onboarding
+// rejects the id of a platform admin tenant.
+func TestNewRouter_PlatformAdminTenantCannotBeRegistered(t *testing.T) {
+	t.Setenv("AUTH_PLATFORM_ADMIN_TENANTS", "ops")
+	router := newTestRouter(t)
+
+	if w := send(router, http.MethodPost, "/auth/tenants", "", `{"id":"ops","name":"Ops"}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("register platform admin tenant: expected 400, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+// TestNewRouter_AcceptsJWT tests that a configured HS256 secret lets bearer
+// tokens authenticate through the router.
+func TestNewRouter_AcceptsJWT(t *testing.T) {
+	const secret = "router-jwt-secret"
+	t.Setenv("AUTH_JWT_HS256_SECRET", secret)
+	router := newTestRouter(t)
+	createTenant(t, router, "acme")
+
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+	claims, _ := json.Marshal(auth.JWTClaims{Subject: "user-1", Tenant: "acme", Scope: "audit:read", ExpiresAt: time.Now().Add(time.Minute).Unix()})
+	input := header + "." + base64.RawURLEncoding.EncodeToString(claims)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(input))
+	token := input + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+
+	w := send(router, http.MethodGet, "/auth/me", token, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("/auth/me with a JWT: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var me auth.WhoAmIResponse
+	if err := json.NewDecoder(w.Body).Decode(&me); err != nil || me.ActorType != "user" || me.Subject != "user-1" {
+		t.Fatalf("expected the token's user, got %+v (%v)", me, err)
+	}
+}
+
+// TestNewRouter_AcceptsClientCertificate tests that, with mTLS enabled, a
+// verified client certificate whose CN is mapped authenticates as its tenant.
+func TestNewRouter_AcceptsClientCertificate(t *testing.T) {
+	t.Setenv("AUTH_ENABLE_MTLS", "true")
+	t.Setenv("AUTH_MTLS_IDENTITIES", `{"billing.acme.example":{"tenantId":"acme","scopes":["audit:read"]}}`)
+	router := newTestRouter(t)
+	createTenant(t, router, "acme")
+
+	r := httptest.NewRequest(http.MethodGet, "/auth/me", nil)
+	r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{Subject: pkix.Name{CommonName: "billing.acme.example"}}}}}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("/auth/me with a client certificate: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var me auth.WhoAmIResponse
+	if err := json.NewDecoder(w.Body).Decode(&me); err != nil || me.ActorType != "mtls" || me.Tenant.ID != "acme" {
+		t.Fatalf("expected an mtls actor for acme, got %+v (%v)", me, err)
+	}
+}
+
+func TestServerTLSConfig_MTLSNeedsTLS(t *testing.T) {
+	authCfg := auth.Config{EnableMTLS: true}
+	if _, err := serverTLSConfig(auditzip.Config{}, authCfg); err == nil {
+		t.Fatal("expected an error when mTLS is enabled without a server certificate")
+	}
+	if tlsCfg, err := serverTLSConfig(auditzip.Config{}, auth.Config{}); err != nil || tlsCfg != nil {
+		t.Fatalf("expected plain HTTP without TLS settings, got %v, %v", tlsCfg, err)
+	}
+}
+
+// TestNewRouter_RateLimitsByPlan tests that the per-key limiter applies the
+// tenant's plan default and answers 429 once it is spent.
+func TestNewRouter_RateLimitsByPlan(t *testing.T) {
+	t.Setenv("AUTH_PLAN_POLICIES", `{"free":{"rateLimitPerMinute":2}}`)
+	router := newTestRouter(t)
+	key := createTenant(t, router, "acme")
+
+	for i := 0; i < 2; i++ {
+		if w := send(router, http.MethodGet, "/auth/me", key, ""); w.Code != http.StatusOK {
+			t.Fatalf("request %d: expected 200, got %d: %s", i+1, w.Code, w.Body.String())
+		}
+	}
+	w := send(router, http.MethodGet, "/auth/me", key, "")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("request over the plan limit: expected 429, got %d: %s", w.Code, w.Body.String())
+	}
+	if w.Header().Get("Retry-After") == "" || w.Header().Get("X-RateLimit-Limit") != "2" {
+		t.Fatalf("expected Retry-After and the plan's limit, got %v", w.Header())
+	}
+}
+
+// TestNewRouter_ExportsAuthMetrics tests that auth outcomes reach /metrics.
+func TestNewRouter_ExportsAuthMetrics(t *testing.T) {
+	router := newTestRouter(t)
+	key := createTenant(t, router, "acme")
+	send(router, http.MethodGet, "/auth/me", key, "")
+	send(router, http.MethodGet, "/auth/me", "", "")
+
+	w := send(router, http.MethodGet, "/metrics", "", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("/metrics: expected 200, got %d", w.Code)
+	}
+	body := w.Body.String()
+	for _, want := range []string{`auth_outcomes_total{outcome="auth_success"} 1`, `auth_outcomes_total{outcome="auth_missing_key"} 1`, "auth_key_validation_duration_seconds_count 1"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected %q in /metrics, got:\n%s", want, body)
+		}
+	}
+}
+
+// TestNewRouter_OnboardingAuthenticatesAnyCredential tests that /auth/tenants
+// authenticates callers identifying themselves by query-parameter key or client
+// certificate instead of treating them as anonymous.
+func TestNewRouter_OnboardingAuthenticatesAnyCredential(t *testing.T) {
+	t.Setenv("AUTH_ALLOW_QUERY_PARAM_KEY", "true")
+	t.Setenv("AUTH_ENABLE_MTLS", "true")
+	router := newTestRouter(t)
+
+	body := `{"id":"acme","name":"Acme"}`
+	if w := send(router, http.MethodPost, "/auth/tenants?api_key=ppk_not-a-real-key", "", body); w.Code != http.StatusUnauthorized {
+		t.Fatalf("invalid query-parameter key: expected 401, got %d: %s", w.Code, w.Body.String())
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/auth/tenants", strings.NewReader(body))
+	r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{Subject: pkix.Name{CommonName: "unmapped.example"}}}}}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("unmapped client certificate: expected 401, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if w := send(router, http.MethodPost, "/auth/tenants", "", body); w.Code != http.StatusCreated {
+		t.Fatalf("anonymous onboarding: expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+// TestNewRouter_InvoiceRoutesRequireAuth tests that listing, reading,
+// verifying, issuing, previewing and voiding invoices need a key with the
+// matching scope.
+func TestNewRouter_InvoiceRoutesRequireAuth(t *testing.T) {
+	router := newTestRouter(t)
+	adminKey := createTenant(t, router, "acme")
+	w := send(router, http.MethodPost, "/auth/keys", adminKey, `{"name":"Reader","scopes":["invoice:read"]}`)
+	var reader auth.CreateAPIKeyResponse
+	if err := json.NewDecoder(w.Body).Decode(&reader); err != nil || reader.RawKey == "" {
+		t.Fatalf("expected a raw key, got %d: %s", w.Code, w.Body.String())
+	}
+
+	id := uuid.NewString()
+	for _, target := range []string{"/invoices", "/invoices/" + id, "/invoices/" + id + "/verify-pdf"} {
+		if w := send(router, http.MethodGet, target, "", ""); w.Code != http.StatusUnauthorized {
+			t.Fatalf("anonymous GET %s: expected 401, got %d", target, w.Code)
+		}
+	}
+	if w := send(router, http.MethodGet, "/invoices", reader.RawKey, ""); w.Code != http.StatusOK {
+		t.Fatalf("GET /invoices with invoice:read: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	for _, target := range []string{"/invoices", "/invoices/preview"} {
+		if w := send(router, http.MethodPost, target, "", "{}"); w.Code != http.StatusUnauthorized {
+			t.Fatalf("anonymous POST %s: expected 401, got %d", target, w.Code)
+		}
+		if w := send(router, http.MethodPost, target, reader.RawKey, "{}"); w.Code != http.StatusForbidden {
+			t.Fatalf("POST %s without invoice:write: expected 403, got %d", target, w.Code)
+		}
+	}
+
+	void := "/invoices/" + id + "/void"
+	if w := send(router, http.MethodPost, void, "", ""); w.Code != http.StatusUnauthorized {
+		t.Fatalf("anonymous void: expected 401, got %d", w.Code)
+	}
+	if w := send(router, http.MethodPost, void, reader.RawKey, ""); w.Code != http.StatusForbidden {
+		t.Fatalf("void without invoice:write: expected 403, got %d", w.Code)
+	}
+	if w := send(router, http.MethodPost, void, adminKey, ""); w.Code != http.StatusNotFound {
+		t.Fatalf("void of an unknown invoice: expected 404, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+// TestNewRouter_MountsSignedArchiveLinks tests that in-memory archive links
+// resolve to a route that checks their signature instead of a dead path.
+func TestNewRouter_MountsSignedArchiveLinks(t *testing.T) {
+	router := newTestRouter(t)
+	w := send(router, http.MethodGet, auditzip.StoragePath+"acme/audit/archive.zip?exp=2099-01-01T00:00:00Z&sig=00", "", "")
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("forged archive link: expected 403, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+// TestNewAuthAuditRecorder_SealsWithConfiguredAlgorithm tests that entries
+// written without an auth.Handler, as the middleware and sweepers do, use
+// AUDIT_HASH_ALGORITHM.
+func TestNewAuthAuditRecorder_SealsWithConfiguredAlgorithm(t *testing.T) {
+	t.Setenv("AUDIT_HASH_ALGORITHM", auditchain.SHA512)
+	cfg := auth.LoadConfig()
+	cfg.BcryptCost = 4
+	audit := newAuthAuditRecorder(cfg)
+	authn := auth.Middleware(auth.NewInMemoryAPIKeyStore(cfg), audit, cfg, nil)
+	authn(http.NotFoundHandler()).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/auth/me", nil))
+
+	entries := audit.GetEntries(auth.UnattributedTenantID)
+	if len(entries) != 1 || entries[0].HashAlg != auditchain.SHA512 {
+		t.Fatalf("expected one sha512 entry, got %+v", entries)
+	}
+}
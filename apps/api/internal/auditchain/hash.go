@@ -0,0 +1,58 @@
+// Package auditchain holds the hash-chain encoding shared by the auditzip and
+// pint audit logs, so both services seal and verify entries the same way.
+package auditchain
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"strings"
+)
+
+// Audit hash algorithms. An entry without one was hashed by the legacy
+// '|'-joined encoding and is still verified that way.
+const (
+	SHA256 = "sha256"
+	SHA512 = "sha512"
+)
+
+// ValidateAlgorithm returns an error unless alg is SHA256 or SHA512.
+func ValidateAlgorithm(alg string) error {
+	if newHash(alg) == nil {
+		return fmt.Errorf("unsupported audit hash algorithm %q", alg)
+	}
+	return nil
+}
+
+// Sum hashes an entry's fields with alg. alg and then each field are written
+// as length-prefixed netstrings so no field value can shift into its
+// neighbour. An empty alg uses the legacy '|'-joined SHA-256 encoding; an
+// unknown one yields "".
+func Sum(alg string, fields ...string) string {
+	if alg == "" {
+		sum := sha256.Sum256([]byte(strings.Join(fields, "|")))
+		return hex.EncodeToString(sum[:])
+	}
+	h := newHash(alg)
+	if h == nil {
+		return ""
+	}
+	for _, field := range append([]string{alg}, fields...) {
+		fmt.Fprintf(h, "%d:%s,", len(field), field)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// newHash returns a hash for alg, or nil if alg is unsupported.
+func newHash(alg string) hash.Hash {
+	switch alg {
+	case SHA256:
+		return sha256.New()
+	case SHA512:
+		return sha512.New()
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,34 @@
+package auditchain
+
+import "testing"
+
+func TestValidateAlgorithm(t *testing.T) {
+	for _, alg := range []string{SHA256, SHA512} {
+		if err := ValidateAlgorithm(alg); err != nil {
+			t.Errorf("ValidateAlgorithm(%q) = %v", alg, err)
+		}
+	}
+	for _, alg := range []string{"", "md5", "SHA256"} {
+		if err := ValidateAlgorithm(alg); err == nil {
+			t.Errorf("ValidateAlgorithm(%q) should fail", alg)
+		}
+	}
+}
+
+func TestSumEncodings(t *testing.T) {
+	// The legacy encoding cannot tell where a '|' inside a field belongs.
+	if Sum("", "a|b", "c") != Sum("", "a", "b|c") {
+		t.Fatal("expected the legacy encoding to collide; the fixture no longer exercises it")
+	}
+	for _, alg := range []string{SHA256, SHA512} {
+		if Sum(alg, "a|b", "c") == Sum(alg, "a", "b|c") {
+			t.Errorf("%s: expected netstring fields to hash differently", alg)
+		}
+	}
+	if Sum(SHA256, "a") == Sum(SHA512, "a") {
+		t.Error("expected the algorithm to be part of the sealed fields")
+	}
+	if got := Sum("md5", "a"); got != "" {
+		t.Errorf("expected an unknown algorithm to yield \"\", got %q", got)
+	}
+}
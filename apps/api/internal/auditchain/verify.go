@@ -0,0 +1,45 @@
+package auditchain
+
+// Export is the body of GET /audit/log: a tenant's chain oldest first and
+// whether re-hashing every entry reproduces it.
+type Export[E any] struct {
+	TenantID string `json:"tenantId"`
+	Entries  []E    `json:"entries"`
+	Verified bool   `json:"verified"`
+	BrokenAt *int   `json:"brokenAt,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// Link is what Verify checks of one entry: the hash it records, the PrevHash
+// it links to, and its hash recomputed from its contents.
+type Link struct {
+	PrevHash   string
+	Hash       string
+	Recomputed string
+}
+
+// Verify checks every entry's recomputed hash and that each PrevHash links to
+// the previous entry, stopping at the first broken index.
+func Verify[E any](tenantID string, entries []E, link func(E) Link) Export[E] {
+	if entries == nil {
+		entries = []E{}
+	}
+	export := Export[E]{TenantID: tenantID, Entries: entries, Verified: true}
+	prevHash := ""
+	for i, entry := range entries {
+		l := link(entry)
+		reason := ""
+		switch {
+		case l.PrevHash != prevHash:
+			reason = "prevHash does not match previous entry hash"
+		case l.Recomputed != l.Hash:
+			reason = "hash does not match entry contents"
+		}
+		if reason != "" {
+			export.Verified, export.BrokenAt, export.Reason = false, &i, reason
+			break
+		}
+		prevHash = l.Hash
+	}
+	return export
+}
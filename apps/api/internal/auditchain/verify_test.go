@@ -0,0 +1,29 @@
+package auditchain
+
+import "testing"
+
+func TestVerify(t *testing.T) {
+	links := []Link{
+		{PrevHash: "", Hash: "h1", Recomputed: "h1"},
+		{PrevHash: "h1", Hash: "h2", Recomputed: "h2"},
+		{PrevHash: "h2", Hash: "h3", Recomputed: "h3"},
+	}
+	identity := func(l Link) Link { return l }
+
+	if export := Verify("tenant-a", links, identity); !export.Verified || len(export.Entries) != 3 {
+		t.Fatalf("expected a verified chain of 3, got %+v", export)
+	}
+	if export := Verify[Link]("tenant-a", nil, identity); !export.Verified || export.Entries == nil {
+		t.Fatalf("expected an empty, non-nil verified chain, got %+v", export)
+	}
+
+	links[1].Recomputed = "tampered"
+	if export := Verify("tenant-a", links, identity); export.Verified || *export.BrokenAt != 1 || export.Reason != "hash does not match entry contents" {
+		t.Fatalf("expected a content break at 1, got %+v", export)
+	}
+	links[1].Recomputed = "h2"
+	links[2].PrevHash = "h1"
+	if export := Verify("tenant-a", links, identity); export.Verified || *export.BrokenAt != 2 || export.Reason != "prevHash does not match previous entry hash" {
+		t.Fatalf("expected a link break at 2, got %+v", export)
+	}
+}
@@ -29,22 +29,41 @@ const (
 
 // Defines values for AuditZipRequestFormat.
 const (
-	Zip AuditZipRequestFormat = "zip"
+	Targz AuditZipRequestFormat = "targz"
+	Zip   AuditZipRequestFormat = "zip"
 )
 
 // Defines values for ConflictErrorConflictReason.
 const (
+	ArtifactNotReady        ConflictErrorConflictReason = "artifact_not_ready"
 	DuplicateJob            ConflictErrorConflictReason = "duplicate_job"
 	IdempotencyBodyMismatch ConflictErrorConflictReason = "idempotency_body_mismatch"
 	IdempotencyReplay       ConflictErrorConflictReason = "idempotency_replay"
 	NotCancelable           ConflictErrorConflictReason = "not_cancelable"
 )
 
+// AuditZipChildJob defines model for AuditZipChildJob.
+type AuditZipChildJob struct {
+	From     openapi_types.Date `json:"from"`
+	JobId    openapi_types.UUID `json:"jobId"`
+	Progress int                `json:"progress"`
+
+	// SignedUrl Signed URL of the child archive once it has succeeded
+	SignedUrl *string `json:"signedUrl,omitempty"`
+
+	// Status Child job status (same values as AuditZipJob.status)
+	Status string             `json:"status"`
+	To     openapi_types.Date `json:"to"`
+}
+
 // AuditZipJob defines model for AuditZipJob.
 type AuditZipJob struct {
 	// CanCancel true when cancel=true is accepted
 	CanCancel *bool `json:"canCancel,omitempty"`
 
+	// Children Child jobs of an auto-split request, in range order
+	Children *[]AuditZipChildJob `json:"children,omitempty"`
+
 	// CriteriaHash SHA-256 hex hash of the request criteria for audit chain
 	CriteriaHash *string            `json:"criteriaHash,omitempty"`
 	Error        *InternalError     `json:"error,omitempty"`
@@ -63,6 +82,13 @@ type AuditZipJobStatus string
 
 // AuditZipRequest defines model for AuditZipRequest.
 type AuditZipRequest struct {
+	// AutoSplit Fan a range above the split threshold out into child jobs instead of rejecting it with 413
+	AutoSplit *bool `json:"autoSplit,omitempty"`
+
+	// CallbackUrl HTTPS URL that receives a signed POST when the job reaches a terminal state
+	CallbackUrl *string `json:"callbackUrl"`
+
+	// Format Archive packaging; targz produces a gzipped tarball
 	Format    AuditZipRequestFormat `json:"format"`
 	From      openapi_types.Date    `json:"from"`
 	MaxAmount *float64              `json:"maxAmount"`
@@ -197,6 +223,9 @@ type RateLimit = RateLimitError
 // RequestTooLarge defines model for RequestTooLarge.
 type RequestTooLarge = RequestTooLargeError
 
+// ServiceUnavailable defines model for ServiceUnavailable.
+type ServiceUnavailable = InternalError
+
 // GetAuditZipJobParams defines parameters for GetAuditZipJob.
 type GetAuditZipJobParams struct {
 	// Cancel Request cancellation when the job is in running state.
@@ -2,29 +2,61 @@ package auditzip
 
 import (
 	"context"
-	"crypto/sha256"
-	"encoding/hex"
-	"fmt"
 	"log/slog"
 	"time"
+
+	"github.com/yourorg/yourapp/apps/api/internal/auditchain"
 )
 
 type AuditRecorder interface {
 	Append(ctx context.Context, entry AuditLog) error
 	Last(ctx context.Context, tenantID string) (AuditLog, error)
+	// All returns tenantID's entries in chain order, oldest first.
+	All(ctx context.Context, tenantID string) ([]AuditLog, error)
 }
 
 func HashChain(ctx context.Context, rec AuditRecorder, tenantID string, entry AuditLog) (AuditLog, error) {
+	if entry.HashAlg == "" {
+		entry.HashAlg = auditchain.SHA256
+	}
+	if err := auditchain.ValidateAlgorithm(entry.HashAlg); err != nil {
+		return entry, err
+	}
 	prev, _ := rec.Last(ctx, tenantID)
 	entry.PrevHash = prev.Hash
 	entry.Hash = hashAudit(entry)
 	return entry, rec.Append(ctx, entry)
 }
 
+// hashAudit seals entry's fields with auditchain.Sum under entry.HashAlg;
+// entries without one use the legacy encoding.
 func hashAudit(entry AuditLog) string {
-	payload := fmt.Sprintf("%s|%s|%s|%s|%s|%s|%s", entry.CorrID, entry.TenantID, entry.Actor, entry.Action, entry.CriteriaHash, entry.Ts.UTC().Format(time.RFC3339Nano), entry.PrevHash)
-	sum := sha256.Sum256([]byte(payload))
-	return hex.EncodeToString(sum[:])
+	return auditchain.Sum(entry.HashAlg, entry.CorrID, entry.TenantID, entry.Actor, entry.Action, entry.CriteriaHash, entry.Ts.UTC().Format(time.RFC3339Nano), entry.PrevHash)
+}
+
+// AuditLogExport is the body of GET /audit/log for this service's chain.
+type AuditLogExport = auditchain.Export[AuditLog]
+
+// VerifyChain recomputes every entry hash with hashAudit and checks each
+// PrevHash links to the previous entry, stopping at the first broken index.
+func VerifyChain(tenantID string, entries []AuditLog) AuditLogExport {
+	return auditchain.Verify(tenantID, entries, func(entry AuditLog) auditchain.Link {
+		return auditchain.Link{PrevHash: entry.PrevHash, Hash: entry.Hash, Recomputed: hashAudit(entry)}
+	})
+}
+
+type corrIDContextKey struct{}
+
+// withCorrelationID stores the request's correlation ID in ctx, so work that
+// outlives the request, such as a queued job, logs and traces under it.
+func withCorrelationID(ctx context.Context, corrID string) context.Context {
+	return context.WithValue(ctx, corrIDContextKey{}, corrID)
+}
+
+// correlationID returns the correlation ID stored by withCorrelationID, or "".
+func correlationID(ctx context.Context) string {
+	corrID, _ := ctx.Value(corrIDContextKey{}).(string)
+	return corrID
 }
 
 func CorrelationLogger(logger *slog.Logger, corrID, tenantID string) *slog.Logger {
@@ -0,0 +1,117 @@
+package auditzip
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/yourorg/yourapp/apps/api/internal/auditchain"
+	"github.com/yourorg/yourapp/apps/api/internal/auth"
+)
+
+func exportAuditLog(t *testing.T, svc Service, tenantID string) AuditLogExport {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodGet, "/audit/log", nil)
+	r = r.WithContext(auth.ContextWithActor(r.Context(), &auth.Actor{TenantID: tenantID, Scopes: []string{auth.Scopes.AuditRead}}))
+	w := httptest.NewRecorder()
+	svc.ExportAuditLog(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var export AuditLogExport
+	if err := json.Unmarshal(w.Body.Bytes(), &export); err != nil {
+		t.Fatal(err)
+	}
+	return export
+}
+
+func TestExportAuditLogVerifiesChain(t *testing.T) {
+	rec := NewMemoryAuditRecorder()
+	svc := NewService(testQueueConfig(), NewJobQueue(NewInMemoryStorage(), testQueueConfig()), rec, nil)
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, action := range []string{"audit.zip.create", "audit.zip.get", "audit.zip.list"} {
+		entry := AuditLog{AuditID: action, CorrID: "corr-1", TenantID: "tenant-a", Actor: "system", Action: action, Ts: base.Add(time.Duration(i) * time.Second)}
+		if _, err := HashChain(context.Background(), rec, "tenant-a", entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := HashChain(context.Background(), rec, "tenant-b", AuditLog{TenantID: "tenant-b", Action: "audit.zip.create", Ts: base}); err != nil {
+		t.Fatal(err)
+	}
+
+	export := exportAuditLog(t, svc, "tenant-a")
+	if !export.Verified || export.BrokenAt != nil || len(export.Entries) != 3 {
+		t.Fatalf("expected a verified chain of 3 entries, got %+v", export)
+	}
+	if export.Entries[0].Action != "audit.zip.create" || export.Entries[2].PrevHash != export.Entries[1].Hash {
+		t.Fatalf("expected entries oldest first and linked, got %+v", export.Entries)
+	}
+
+	rec.byTenant["tenant-a"][1].Action = "audit.zip.cancel"
+	export = exportAuditLog(t, svc, "tenant-a")
+	if export.Verified || export.BrokenAt == nil || *export.BrokenAt != 1 {
+		t.Fatalf("expected the tampered middle entry to break the chain at 1, got %+v", export)
+	}
+}
+
+func TestExportAuditLogEmptyChain(t *testing.T) {
+	svc := NewService(testQueueConfig(), NewJobQueue(NewInMemoryStorage(), testQueueConfig()), NewMemoryAuditRecorder(), nil)
+	export := exportAuditLog(t, svc, "tenant-a")
+	if !export.Verified || export.Entries == nil || len(export.Entries) != 0 {
+		t.Fatalf("expected an empty verified chain, got %+v", export)
+	}
+}
+
+func TestHashAuditSeparatesFieldBoundaries(t *testing.T) {
+	ts := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	a := AuditLog{CorrID: "corr|tenant-a", TenantID: "x", Actor: "system", Action: "audit.zip.create", Ts: ts}
+	b := AuditLog{CorrID: "corr", TenantID: "tenant-a|x", Actor: "system", Action: "audit.zip.create", Ts: ts}
+	if hashAudit(a) != hashAudit(b) {
+		t.Fatal("expected the legacy encoding to collide; the fixture no longer exercises it")
+	}
+	for _, alg := range []string{auditchain.SHA256, auditchain.SHA512} {
+		a.HashAlg, b.HashAlg = alg, alg
+		if hashAudit(a) == hashAudit(b) {
+			t.Fatalf("%s: expected entries differing by '|' placement to hash differently", alg)
+		}
+	}
+	if got := len(hashAudit(a)); got != 128 {
+		t.Fatalf("expected a 128-hex-digit SHA-512 hash, got %d", got)
+	}
+}
+
+func TestVerifyChainAcceptsLegacyEntries(t *testing.T) {
+	rec := NewMemoryAuditRecorder()
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	// An entry written before HashAlg existed, hashed in the legacy encoding.
+	legacy := AuditLog{AuditID: "a1", CorrID: "corr-1", TenantID: "tenant-a", Actor: "system", Action: "audit.zip.create", Ts: base}
+	legacy.Hash = hashAudit(legacy)
+	if err := rec.Append(context.Background(), legacy); err != nil {
+		t.Fatal(err)
+	}
+	for i, alg := range []string{"", auditchain.SHA512} {
+		entry := AuditLog{AuditID: fmt.Sprintf("a%d", i+2), CorrID: "corr-1", TenantID: "tenant-a", Actor: "system", Action: "audit.zip.get", Ts: base.Add(time.Duration(i+1) * time.Second), HashAlg: alg}
+		if _, err := HashChain(context.Background(), rec, "tenant-a", entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := HashChain(context.Background(), rec, "tenant-a", AuditLog{TenantID: "tenant-a", HashAlg: "md5"}); err == nil {
+		t.Fatal("expected an unsupported algorithm to be rejected")
+	}
+
+	entries, _ := rec.All(context.Background(), "tenant-a")
+	if entries[1].HashAlg != auditchain.SHA256 || entries[2].HashAlg != auditchain.SHA512 {
+		t.Fatalf("expected new entries to record their algorithm, got %q %q", entries[1].HashAlg, entries[2].HashAlg)
+	}
+	if export := VerifyChain("tenant-a", entries); !export.Verified || len(export.Entries) != 3 {
+		t.Fatalf("expected a mixed legacy and current chain to verify, got %+v", export)
+	}
+	entries[0].Actor = "intruder"
+	if export := VerifyChain("tenant-a", entries); export.Verified || *export.BrokenAt != 0 {
+		t.Fatalf("expected the tampered legacy entry to break the chain at 0, got %+v", export)
+	}
+}
@@ -0,0 +1,109 @@
+package auditzip
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// CallbackSignatureHeader carries the hex HMAC-SHA256 of the callback body,
+// keyed with Config.CallbackSecret and prefixed with "sha256=".
+const CallbackSignatureHeader = "X-PromptPack-Signature"
+
+// CallbackPayload is POSTed to a job's callbackUrl once it reaches a terminal state.
+type CallbackPayload struct {
+	JobID        string            `json:"jobId"`
+	Status       AuditZipJobStatus `json:"status"`
+	SignedURL    *string           `json:"signedUrl,omitempty"`
+	ExpiresAt    *time.Time        `json:"expiresAt,omitempty"`
+	CriteriaHash string            `json:"criteriaHash"`
+}
+
+func validateCallbackURL(raw string, cfg Config) error {
+	if cfg.CallbackSecret == "" {
+		return errors.New("callbacks are not enabled")
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return errors.New("callbackUrl must be an absolute https URL")
+	}
+	return nil
+}
+
+// SignCallback returns the CallbackSignatureHeader value for body.
+func SignCallback(body []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// notifyLocked schedules the callback for a job that just reached a terminal
+// state. Delivery runs in the background so it never holds q.mu; callers must
+// hold q.mu.
+func (q *JobQueue) notifyLocked(state *jobState) {
+	if state.request.CallbackUrl == nil {
+		return
+	}
+	payload := CallbackPayload{
+		JobID:        state.job.JobId.String(),
+		Status:       state.job.Status,
+		CriteriaHash: state.criteriaHash,
+	}
+	if res := state.job.Result; res != nil {
+		signedURL, expiresAt := res.SignedUrl, res.ExpiresAt
+		payload.SignedURL = &signedURL
+		payload.ExpiresAt = &expiresAt
+	}
+	go q.deliverCallback(*state.request.CallbackUrl, payload, CorrelationLogger(q.logger, state.corrID, state.tenantID))
+}
+
+// deliverCallback POSTs the signed payload, retrying non-2xx responses and
+// transport errors with the job backoff up to cfg.MaxRetries attempts.
+func (q *JobQueue) deliverCallback(callbackURL string, payload CallbackPayload, log *slog.Logger) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		log.Error("audit zip callback marshal failed", "jobId", payload.JobID, "error", err)
+		return
+	}
+	signature := SignCallback(body, q.cfg.CallbackSecret)
+	for attempt := 1; ; attempt++ {
+		err := q.postCallback(callbackURL, body, signature)
+		if err == nil {
+			return
+		}
+		if attempt >= q.cfg.MaxRetries {
+			log.Warn("audit zip callback failed", "jobId", payload.JobID, "attempts", attempt, "error", err)
+			return
+		}
+		time.Sleep(q.retryDelay(attempt))
+	}
+}
+
+func (q *JobQueue) postCallback(callbackURL string, body []byte, signature string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), q.cfg.CallbackTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, callbackURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(CallbackSignatureHeader, signature)
+	resp, err := q.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("callback returned %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package auditzip
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// callbackReceiver records callback deliveries, answering 503 to the first
+// failures attempts.
+type callbackReceiver struct {
+	mu       sync.Mutex
+	failures int
+	attempts int
+	bodies   [][]byte
+	sigs     []string
+	done     chan struct{}
+}
+
+func (c *callbackReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.attempts++
+	c.bodies = append(c.bodies, body)
+	c.sigs = append(c.sigs, r.Header.Get(CallbackSignatureHeader))
+	if c.attempts <= c.failures {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+	close(c.done)
+}
+
+func callbackQueue(t *testing.T, receiver *callbackReceiver) (*JobQueue, string) {
+	t.Helper()
+	srv := httptest.NewTLSServer(receiver)
+	t.Cleanup(srv.Close)
+	cfg := testQueueConfig()
+	cfg.MaxRetries = 3
+	cfg.CallbackSecret = "callback-secret"
+	cfg.CallbackTimeout = time.Second
+	q := NewJobQueue(NewInMemoryStorage(), cfg)
+	q.httpClient = srv.Client()
+	q.process = func(ctx context.Context, state *jobState) error {
+		q.completeJob(state.job.JobId, "https://storage.local/ok", time.Now().UTC(), 1)
+		return nil
+	}
+	return q, srv.URL + "/hooks/audit"
+}
+
+func TestJobCallbackIsSignedAndRetried(t *testing.T) {
+	receiver := &callbackReceiver{failures: 2, done: make(chan struct{})}
+	q, callbackURL := callbackQueue(t, receiver)
+
+	req := testRequest(1)
+	req.CallbackUrl = &callbackURL
+	job, err := q.Enqueue(context.Background(), "tenant-a", uuid.NewString(), "hash-1", req)
+	if err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	select {
+	case <-receiver.done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("callback was not delivered")
+	}
+
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+	if receiver.attempts != 3 {
+		t.Fatalf("expected 3 attempts (2 failures then success), got %d", receiver.attempts)
+	}
+	body := receiver.bodies[len(receiver.bodies)-1]
+	if got, want := receiver.sigs[len(receiver.sigs)-1], SignCallback(body, "callback-secret"); got != want {
+		t.Fatalf("signature = %q, want %q", got, want)
+	}
+	var payload CallbackPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		t.Fatalf("decode payload: %v", err)
+	}
+	if payload.JobID != job.JobId.String() || payload.Status != Succeeded || payload.CriteriaHash != "hash-1" {
+		t.Fatalf("unexpected payload %+v", payload)
+	}
+	if payload.SignedURL == nil || *payload.SignedURL != "https://storage.local/ok" || payload.ExpiresAt == nil {
+		t.Fatalf("expected signed URL and expiry in payload, got %+v", payload)
+	}
+}
+
+func TestJobCallbackGivesUpAfterMaxRetries(t *testing.T) {
+	receiver := &callbackReceiver{failures: 10, done: make(chan struct{})}
+	q, callbackURL := callbackQueue(t, receiver)
+
+	req := testRequest(1)
+	req.CallbackUrl = &callbackURL
+	job, err := q.Enqueue(context.Background(), "tenant-a", uuid.NewString(), "hash-1", req)
+	if err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	waitForStatus(t, q, job.JobId.String(), Succeeded)
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		receiver.mu.Lock()
+		attempts := receiver.attempts
+		receiver.mu.Unlock()
+		if attempts >= 3 {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+	if receiver.attempts != 3 {
+		t.Fatalf("expected delivery capped at MaxRetries=3, got %d attempts", receiver.attempts)
+	}
+}
+
+func TestValidateRequestCallbackURL(t *testing.T) {
+	cfg := testQueueConfig()
+	cfg.CallbackSecret = "callback-secret"
+	for _, tc := range []struct {
+		url  string
+		want bool
+	}{
+		{"https://hooks.example.com/audit", true},
+		{"http://hooks.example.com/audit", false},
+		{"/relative", false},
+		{"https://", false},
+	} {
+		req := testRequest(1)
+		req.CallbackUrl = &tc.url
+		errs, _ := ValidateRequest(req, cfg)
+		if ok := len(errs) == 0; ok != tc.want {
+			t.Errorf("callbackUrl %q: valid = %v, want %v (%v)", tc.url, ok, tc.want, errs)
+		}
+	}
+
+	cfg.CallbackSecret = ""
+	req := testRequest(1)
+	url := "https://hooks.example.com/audit"
+	req.CallbackUrl = &url
+	if errs, _ := ValidateRequest(req, cfg); len(errs) != 1 || errs[0].Path != "callbackUrl" {
+		t.Fatalf("expected callbackUrl rejected without a secret, got %v", errs)
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/yourorg/yourapp/apps/api/internal/auditchain"
 )
 
 type Config struct {
@@ -12,6 +14,8 @@ type Config struct {
 	S3Bucket           string
 	SignURLTTL         time.Duration
 	RetentionPeriod    time.Duration
+	JobRetention       time.Duration
+	JobReapInterval    time.Duration
 	MaxRangeDays       int
 	EstimatedMBPerDay  float64
 	SplitChunkMB       float64
@@ -19,35 +23,121 @@ type Config struct {
 	MaxConcurrentJobs  int
 	MaxRetries         int
 	RetryBaseDelay     time.Duration
+	MaxRetryDelay      time.Duration
 	RateLimitPerMinute int
+	AuditRateLimits    bool
 	QueueRetryAfter    time.Duration
+	MaxInFlightReplays int
+	ResumeJobsOnStart  bool
+	IdempotencyKeyMax  int
+	IdempotencyFormats []string
+	IdempotencyTTL     time.Duration
+	EmitLinkHeaders    bool
+	JobThroughputMBps  float64
 	DefaultLocale      string
 	DefaultTimeZone    string
+	EnableEventStream  bool
+	StorageBackend     string
+	S3Region           string
 	EnableSSE          bool
 	KMSKeyID           string
 	AllowedOrigins     []string
+	// NormalizePartner coalesces partner spellings before hashing and filtering.
+	NormalizePartner bool
+	// PartnerAliases maps partner names to a canonical name when NormalizePartner is set.
+	PartnerAliases map[string]string
+	// CallbackSecret signs job callbacks; callbackUrl is rejected while it is empty.
+	CallbackSecret string
+	// CallbackTimeout bounds each callback delivery attempt.
+	CallbackTimeout time.Duration
+	// MaxConcurrentJobsPerTenant caps one tenant's running jobs within the global
+	// MaxConcurrentJobs (0 disables the cap); excess jobs wait Queued.
+	MaxConcurrentJobsPerTenant int
+	// StrictBodyLength rejects non-chunked bodies whose size differs from Content-Length.
+	StrictBodyLength bool
+	// ShutdownTimeout bounds how long SIGTERM waits for in-flight requests and
+	// running jobs before the server exits anyway.
+	ShutdownTimeout time.Duration
+	// AllowedMethods and AllowedHeaders are advertised to allowed origins;
+	// preflights requesting only allowlisted headers get them echoed back.
+	AllowedMethods []string
+	AllowedHeaders []string
+	// CORSMaxAge is how long browsers may cache a preflight response.
+	CORSMaxAge time.Duration
+	// PublicBaseURL is where InMemoryStorage signed URLs point: archives are
+	// linked as {PublicBaseURL}/{key} and served by StorageDownloadHandler.
+	PublicBaseURL string
+	// StorageSigningSecret keys the HMAC on InMemoryStorage signed URLs. When
+	// empty a random per-process key is used.
+	StorageSigningSecret string
+	// MaxRequestBytes bounds the enqueue request body; larger bodies get 413
+	// (0 disables the bound).
+	MaxRequestBytes int64
+	// ResultReuseWindow is how long after a job succeeds an enqueue with the
+	// same criteria returns that job, re-signed, instead of starting a new one
+	// (0 disables reuse). Reuse stops early once the archive is gone.
+	ResultReuseWindow time.Duration
+	// TLSCertFile and TLSKeyFile make the server terminate TLS itself, which
+	// client-certificate authentication needs (both empty serves plain HTTP).
+	TLSCertFile string
+	TLSKeyFile  string
+	// AuditHashAlgorithm hashes new audit entries: auditchain.SHA256 or
+	// auditchain.SHA512. Existing entries verify with the algorithm they recorded.
+	AuditHashAlgorithm string
 }
 
 func LoadConfig() Config {
+	retention := time.Duration(getInt("AUDIT_RETENTION_DAYS", 7)) * 24 * time.Hour
 	return Config{
-		S3Endpoint:         getenv("S3_ENDPOINT", "https://s3.example.com"),
-		S3Bucket:           getenv("AUDIT_S3_BUCKET", "audit-archives"),
-		SignURLTTL:         getDuration("AUDIT_SIGN_URL_TTL", 10*time.Minute),
-		RetentionPeriod:    time.Duration(getInt("AUDIT_RETENTION_DAYS", 7)) * 24 * time.Hour,
-		MaxRangeDays:       getInt("AUDIT_MAX_RANGE_DAYS", 92),
-		EstimatedMBPerDay:  getFloat("AUDIT_EST_MB_PER_DAY", 5.0),
-		SplitChunkMB:       getFloat("AUDIT_SPLIT_CHUNK_MB", 100.0),
-		MaxQueueDepth:      getInt("AUDIT_MAX_QUEUE_DEPTH", 100),
-		MaxConcurrentJobs:  max(1, getInt("AUDIT_MAX_CONCURRENCY", 4)),
-		MaxRetries:         max(1, getInt("AUDIT_MAX_RETRIES", 3)),
-		RetryBaseDelay:     getDuration("AUDIT_RETRY_BASE_DELAY", 2*time.Second),
-		RateLimitPerMinute: getInt("AUDIT_RATE_PER_MIN", 60),
-		QueueRetryAfter:    getDuration("AUDIT_RETRY_AFTER", 30*time.Second),
-		DefaultLocale:      getenv("DEFAULT_LOCALE", "ja-JP"),
-		DefaultTimeZone:    getenv("DEFAULT_TZ", "Asia/Tokyo"),
-		EnableSSE:          getBool("AUDIT_SSE_ENABLED", true),
-		KMSKeyID:           getenv("AUDIT_KMS_KEY", ""),
-		AllowedOrigins:     splitList(getenv("AUDIT_ALLOWED_ORIGINS", "http://localhost:3000")),
+		S3Endpoint:                 getenv("S3_ENDPOINT", "https://s3.example.com"),
+		S3Bucket:                   getenv("AUDIT_S3_BUCKET", "audit-archives"),
+		SignURLTTL:                 getDuration("AUDIT_SIGN_URL_TTL", 10*time.Minute),
+		RetentionPeriod:            retention,
+		JobRetention:               getDuration("AUDIT_JOB_RETENTION", retention),
+		JobReapInterval:            getDuration("AUDIT_JOB_REAP_INTERVAL", time.Minute),
+		MaxRangeDays:               getInt("AUDIT_MAX_RANGE_DAYS", 92),
+		EstimatedMBPerDay:          getFloat("AUDIT_EST_MB_PER_DAY", 5.0),
+		SplitChunkMB:               getFloat("AUDIT_SPLIT_CHUNK_MB", 100.0),
+		MaxQueueDepth:              getInt("AUDIT_MAX_QUEUE_DEPTH", 100),
+		MaxConcurrentJobs:          max(1, getInt("AUDIT_MAX_CONCURRENCY", 4)),
+		MaxRetries:                 max(1, getInt("AUDIT_MAX_RETRIES", 3)),
+		RetryBaseDelay:             getDuration("AUDIT_RETRY_BASE_DELAY", 2*time.Second),
+		MaxRetryDelay:              getDuration("AUDIT_MAX_RETRY_DELAY", time.Minute),
+		RateLimitPerMinute:         getInt("AUDIT_RATE_PER_MIN", 60),
+		AuditRateLimits:            getBool("AUDIT_RATE_LIMIT_EVENTS", true),
+		QueueRetryAfter:            getDuration("AUDIT_RETRY_AFTER", 30*time.Second),
+		MaxInFlightReplays:         getInt("AUDIT_MAX_INFLIGHT_REPLAYS", 32),
+		ResumeJobsOnStart:          getBool("AUDIT_RESUME_JOBS_ON_START", false),
+		IdempotencyKeyMax:          getInt("AUDIT_IDEMPOTENCY_KEY_MAX_LEN", 128),
+		IdempotencyFormats:         splitList(getenv("AUDIT_IDEMPOTENCY_KEY_FORMATS", "uuid,token")),
+		IdempotencyTTL:             getDuration("AUDIT_IDEMPOTENCY_TTL", 24*time.Hour),
+		EmitLinkHeaders:            getBool("AUDIT_LINK_HEADERS", true),
+		JobThroughputMBps:          getFloat("AUDIT_JOB_THROUGHPUT_MBPS", 10.0),
+		DefaultLocale:              getenv("DEFAULT_LOCALE", "ja-JP"),
+		DefaultTimeZone:            getenv("DEFAULT_TZ", "Asia/Tokyo"),
+		EnableEventStream:          getBool("AUDIT_EVENT_STREAM_ENABLED", true),
+		StorageBackend:             getenv("AUDIT_STORAGE_BACKEND", "memory"),
+		S3Region:                   getenv("AUDIT_S3_REGION", "ap-northeast-1"),
+		EnableSSE:                  getBool("AUDIT_SSE_ENABLED", true),
+		KMSKeyID:                   getenv("AUDIT_KMS_KEY", ""),
+		AllowedOrigins:             splitList(getenv("AUDIT_ALLOWED_ORIGINS", "http://localhost:3000")),
+		NormalizePartner:           getBool("AUDIT_NORMALIZE_PARTNER", false),
+		PartnerAliases:             splitMap(getenv("AUDIT_PARTNER_ALIASES", "")),
+		CallbackSecret:             getenv("AUDIT_CALLBACK_SECRET", ""),
+		CallbackTimeout:            getDuration("AUDIT_CALLBACK_TIMEOUT", 10*time.Second),
+		MaxConcurrentJobsPerTenant: getInt("AUDIT_MAX_CONCURRENCY_PER_TENANT", 0),
+		StrictBodyLength:           getBool("AUDIT_STRICT_BODY_LENGTH", false),
+		ShutdownTimeout:            getDuration("AUDIT_SHUTDOWN_TIMEOUT", 30*time.Second),
+		AllowedMethods:             splitList(getenv("AUDIT_ALLOWED_METHODS", "GET,POST,OPTIONS")),
+		AllowedHeaders:             splitList(getenv("AUDIT_ALLOWED_HEADERS", "Content-Type,X-Correlation-Id,X-Tenant-Id,Idempotency-Key,Authorization")),
+		CORSMaxAge:                 getDuration("AUDIT_CORS_MAX_AGE", 600*time.Second),
+		PublicBaseURL:              getenv("AUDIT_PUBLIC_BASE_URL", DefaultPublicBaseURL),
+		StorageSigningSecret:       getenv("AUDIT_STORAGE_SIGNING_SECRET", ""),
+		MaxRequestBytes:            int64(getInt("AUDIT_MAX_REQUEST_BYTES", 64<<10)),
+		ResultReuseWindow:          getDuration("AUDIT_RESULT_REUSE_WINDOW", 5*time.Minute),
+		AuditHashAlgorithm:         getenv("AUDIT_HASH_ALGORITHM", auditchain.SHA256),
+		TLSCertFile:                getenv("AUDIT_TLS_CERT_FILE", ""),
+		TLSKeyFile:                 getenv("AUDIT_TLS_KEY_FILE", ""),
 	}
 }
 
@@ -112,3 +202,16 @@ func splitList(s string) []string {
 	}
 	return out
 }
+
+// splitMap parses "alias=canonical,alias2=canonical2"; malformed pairs are skipped.
+func splitMap(s string) map[string]string {
+	out := map[string]string{}
+	for _, pair := range splitList(s) {
+		k, v, ok := strings.Cut(pair, "=")
+		k, v = strings.TrimSpace(k), strings.TrimSpace(v)
+		if ok && k != "" && v != "" {
+			out[k] = v
+		}
+	}
+	return out
+}
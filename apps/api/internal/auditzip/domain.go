@@ -2,6 +2,24 @@ package auditzip
 
 import "time"
 
+const (
+	DefaultListJobsLimit = 20
+	MaxListJobsLimit     = 100
+)
+
+// ListJobsOptions controls JobQueue.ListByTenant paging and filtering.
+type ListJobsOptions struct {
+	Limit  int
+	Cursor string
+	Status *AuditZipJobStatus
+}
+
+// JobPage is one page of a tenant's jobs, newest first. NextCursor is empty on the last page.
+type JobPage struct {
+	Jobs       []AuditZipJob `json:"jobs"`
+	NextCursor string        `json:"nextCursor,omitempty"`
+}
+
 // AuditLog represents append-only audit entries with hash chaining.
 type AuditLog struct {
 	AuditID      string    `json:"auditId"`
@@ -13,4 +31,7 @@ type AuditLog struct {
 	Ts           time.Time `json:"timestamp"`
 	Hash         string    `json:"hash"`
 	PrevHash     string    `json:"prevHash"`
+	// HashAlg is the algorithm Hash was computed with; empty for entries
+	// written in the legacy encoding.
+	HashAlg string `json:"hashAlg,omitempty"`
 }
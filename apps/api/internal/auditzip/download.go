@@ -0,0 +1,222 @@
+package auditzip
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// errRangeNotSatisfiable reports a Range that starts past the end of the object.
+var errRangeNotSatisfiable = errors.New("range not satisfiable")
+
+// byteRange is the span of length bytes starting at start.
+type byteRange struct {
+	start, length int64
+}
+
+// DownloadAuditZipArtifact handles GET /audit/jobs/{jobId}/download. It must run
+// behind auth.Middleware: the job is looked up for the authenticated tenant, so
+// other tenants' jobs are indistinguishable from missing ones. The archive is
+// streamed from storage, and a single "bytes=" Range is answered with 206 so
+// interrupted downloads can resume. An archive already removed by retention
+// reports 410.
+func (s Service) DownloadAuditZipArtifact(w http.ResponseWriter, r *http.Request, jobID string) {
+	corrID := r.Header.Get("X-Correlation-Id")
+	tenantID, ok := authenticatedTenant(w, r, corrID)
+	if !ok {
+		return
+	}
+	log := CorrelationLogger(s.logger, corrID, tenantID)
+
+	key, name, err := s.queue.Artifact(tenantID, jobID)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		body := NotFoundError{Code: "NOT_FOUND", Message: "job not found", CorrId: corrID, Retryable: false}
+		writeJSON(w, http.StatusNotFound, corrID, body, nil)
+		return
+	case errors.Is(err, ErrArtifactNotReady):
+		body := ConflictError{
+			Code:           "CONFLICT",
+			Message:        conflictMessage(ConflictErr{Reason: ArtifactNotReady}),
+			CorrId:         corrID,
+			Retryable:      true,
+			ConflictReason: ArtifactNotReady,
+		}
+		writeJSON(w, http.StatusConflict, corrID, body, nil)
+		return
+	case err != nil:
+		s.writeInternalError(w, corrID, err)
+		return
+	}
+
+	storage := s.queue.storage
+	gone := NotFoundError{Code: "ARTIFACT_EXPIRED", Message: "archive is no longer retained", CorrId: corrID, Retryable: false}
+	info, err := storage.StatObject(r.Context(), key)
+	if errors.Is(err, ErrObjectNotFound) {
+		writeJSON(w, http.StatusGone, corrID, gone, nil)
+		return
+	}
+	if err != nil {
+		log.Error("audit zip artifact stat failed", "jobId", jobID, "error", err)
+		s.writeInternalError(w, corrID, errors.New("storage error"))
+		return
+	}
+
+	// A stale If-Range means the client's partial copy is of another archive,
+	// so the whole current one is sent instead.
+	rng, partial := byteRange{start: 0, length: info.Size}, false
+	if ifRangeMatches(r.Header.Get("If-Range"), info.ModTime) {
+		rng, partial, err = parseRange(r.Header.Get("Range"), info.Size)
+	}
+	if errors.Is(err, errRangeNotSatisfiable) {
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", info.Size))
+		body := ValidationError{
+			Code:      "RANGE_NOT_SATISFIABLE",
+			Message:   "requested range does not overlap the archive",
+			CorrId:    corrID,
+			Retryable: false,
+			Errors:    []ValidationErrorItem{{Code: "RANGE_NOT_SATISFIABLE", Path: "Range", Message: fmt.Sprintf("archive is %d bytes", info.Size)}},
+		}
+		writeJSON(w, http.StatusRequestedRangeNotSatisfiable, corrID, body, nil)
+		return
+	}
+
+	body, err := storage.GetObjectRange(r.Context(), key, rng.start, rng.length)
+	if errors.Is(err, ErrObjectNotFound) {
+		writeJSON(w, http.StatusGone, corrID, gone, nil)
+		return
+	}
+	if err != nil {
+		log.Error("audit zip artifact read failed", "jobId", jobID, "error", err)
+		s.writeInternalError(w, corrID, errors.New("storage error"))
+		return
+	}
+	defer body.Close()
+	_ = s.appendAudit(context.Background(), tenantID, corrID, "audit.zip.download", "")
+
+	contentType := info.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	w.Header().Set("Content-Length", strconv.FormatInt(rng.length, 10))
+	w.Header().Set("Accept-Ranges", "bytes")
+	w.Header().Set("Cache-Control", "private, no-store")
+	if !info.ModTime.IsZero() {
+		w.Header().Set("Last-Modified", info.ModTime.UTC().Format(http.TimeFormat))
+	}
+	if corrID != "" {
+		w.Header().Set("X-Correlation-Id", corrID)
+	}
+	status := http.StatusOK
+	if partial {
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", rng.start, rng.start+rng.length-1, info.Size))
+		status = http.StatusPartialContent
+	}
+	w.WriteHeader(status)
+	if _, err := io.Copy(w, body); err != nil {
+		log.Warn("audit zip artifact download interrupted", "jobId", jobID, "error", err)
+	}
+}
+
+// StorageDownloadHandler serves the InMemoryStorage objects that signed URLs
+// point at, under StoragePath. The URL's signature is the only credential, so
+// an expired or tampered link gets 403 and a missing object 404.
+func StorageDownloadHandler(storage *InMemoryStorage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		corrID := r.Header.Get("X-Correlation-Id")
+		key := strings.TrimPrefix(r.URL.Path, StoragePath)
+		if err := storage.VerifySignedURL(key, r.URL.Query()); err != nil {
+			code := "URL_SIGNATURE_INVALID"
+			if errors.Is(err, ErrURLExpired) {
+				code = "URL_EXPIRED"
+			}
+			writeJSON(w, http.StatusForbidden, corrID, ForbiddenError{Code: code, Message: err.Error(), CorrId: corrID, Retryable: false}, nil)
+			return
+		}
+		info, err := storage.StatObject(r.Context(), key)
+		if err != nil {
+			writeJSON(w, http.StatusNotFound, corrID, NotFoundError{Code: "NOT_FOUND", Message: "object not found", CorrId: corrID, Retryable: false}, nil)
+			return
+		}
+		body, err := storage.GetObjectRange(r.Context(), key, 0, -1)
+		if err != nil {
+			writeJSON(w, http.StatusNotFound, corrID, NotFoundError{Code: "NOT_FOUND", Message: "object not found", CorrId: corrID, Retryable: false}, nil)
+			return
+		}
+		defer body.Close()
+
+		contentType := info.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Length", strconv.FormatInt(info.Size, 10))
+		w.Header().Set("Cache-Control", "private, no-store")
+		_, _ = io.Copy(w, body)
+	}
+}
+
+// parseRange resolves a Range header against an object of size bytes. partial
+// is true for a single satisfiable "bytes=" range; an absent, malformed or
+// multi-range header selects the whole object, as RFC 9110 permits. A range
+// starting at or past size reports errRangeNotSatisfiable.
+func parseRange(header string, size int64) (rng byteRange, partial bool, err error) {
+	whole := byteRange{start: 0, length: size}
+	spec, ok := strings.CutPrefix(strings.TrimSpace(header), "bytes=")
+	if !ok || strings.Contains(spec, ",") {
+		return whole, false, nil
+	}
+	first, last, ok := strings.Cut(strings.TrimSpace(spec), "-")
+	if !ok {
+		return whole, false, nil
+	}
+	if first == "" {
+		// Suffix range: the final n bytes.
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n < 0 {
+			return whole, false, nil
+		}
+		if n == 0 || size == 0 {
+			return whole, false, errRangeNotSatisfiable
+		}
+		n = min(n, size)
+		return byteRange{start: size - n, length: n}, true, nil
+	}
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 {
+		return whole, false, nil
+	}
+	end := size - 1
+	if last != "" {
+		end, err = strconv.ParseInt(last, 10, 64)
+		if err != nil || end < start {
+			return whole, false, nil
+		}
+		end = min(end, size-1)
+	}
+	if start >= size {
+		return whole, false, errRangeNotSatisfiable
+	}
+	return byteRange{start: start, length: end - start + 1}, true, nil
+}
+
+// ifRangeMatches reports whether a resumed download may use its Range: either
+// no If-Range was sent or it names the archive's current Last-Modified time.
+// Entity tags are not issued, so an If-Range holding one never matches.
+func ifRangeMatches(header string, modTime time.Time) bool {
+	if header == "" {
+		return true
+	}
+	t, err := http.ParseTime(header)
+	if err != nil {
+		return false
+	}
+	return !modTime.IsZero() && modTime.UTC().Truncate(time.Second).Equal(t.UTC())
+}
@@ -0,0 +1,201 @@
+package auditzip
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/yourorg/yourapp/apps/api/internal/auth"
+)
+
+// newDownloadService returns a service whose jobs store archive as their
+// artifact, and the id of one such job owned by tenant-a.
+func newDownloadService(t *testing.T, archive []byte) (Service, *InMemoryStorage, string) {
+	t.Helper()
+	storage := NewInMemoryStorage()
+	q := NewJobQueue(storage, testQueueConfig())
+	q.process = func(ctx context.Context, state *jobState) error {
+		if err := storage.PutObject(ctx, q.archiveKey(state), archive, "application/zip"); err != nil {
+			return err
+		}
+		q.completeJob(state.job.JobId, "https://storage.local/ok", time.Now().UTC(), len(archive))
+		return nil
+	}
+	job, err := q.Enqueue(context.Background(), "tenant-a", uuid.NewString(), "hash-1", testRequest(1))
+	if err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	waitForStatus(t, q, job.JobId.String(), Succeeded)
+	return NewService(testQueueConfig(), q, NewMemoryAuditRecorder(), nil), storage, job.JobId.String()
+}
+
+// download calls DownloadAuditZipArtifact as tenantID would after auth.Middleware.
+func download(svc Service, tenantID, jobID string, header http.Header) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, "/audit/jobs/"+jobID+"/download", nil)
+	for k, v := range header {
+		r.Header[k] = v
+	}
+	r = r.WithContext(auth.ContextWithActor(r.Context(), &auth.Actor{TenantID: tenantID, Scopes: []string{auth.Scopes.AuditRead}}))
+	w := httptest.NewRecorder()
+	svc.DownloadAuditZipArtifact(w, r, jobID)
+	return w
+}
+
+func TestDownloadAuditZipArtifactFull(t *testing.T) {
+	svc, _, jobID := newDownloadService(t, []byte("0123456789"))
+
+	w := download(svc, "tenant-a", jobID, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if w.Body.String() != "0123456789" {
+		t.Fatalf("unexpected body %q", w.Body.String())
+	}
+	if got := w.Header().Get("Content-Length"); got != "10" {
+		t.Fatalf("expected Content-Length 10, got %q", got)
+	}
+	if got := w.Header().Get("Accept-Ranges"); got != "bytes" {
+		t.Fatalf("expected Accept-Ranges bytes, got %q", got)
+	}
+	if got := w.Header().Get("Content-Disposition"); got != `attachment; filename="archive.zip"` {
+		t.Fatalf("unexpected Content-Disposition %q", got)
+	}
+}
+
+func TestDownloadAuditZipArtifactRange(t *testing.T) {
+	svc, _, jobID := newDownloadService(t, []byte("0123456789"))
+
+	tests := []struct {
+		rangeHeader  string
+		status       int
+		body         string
+		contentRange string
+	}{
+		{rangeHeader: "bytes=2-5", status: http.StatusPartialContent, body: "2345", contentRange: "bytes 2-5/10"},
+		{rangeHeader: "bytes=7-", status: http.StatusPartialContent, body: "789", contentRange: "bytes 7-9/10"},
+		{rangeHeader: "bytes=-3", status: http.StatusPartialContent, body: "789", contentRange: "bytes 7-9/10"},
+		{rangeHeader: "bytes=8-100", status: http.StatusPartialContent, body: "89", contentRange: "bytes 8-9/10"},
+		{rangeHeader: "bytes=0-1,4-5", status: http.StatusOK, body: "0123456789"},
+		{rangeHeader: "bytes=10-", status: http.StatusRequestedRangeNotSatisfiable, contentRange: "bytes */10"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rangeHeader, func(t *testing.T) {
+			w := download(svc, "tenant-a", jobID, http.Header{"Range": {tt.rangeHeader}})
+			if w.Code != tt.status {
+				t.Fatalf("expected %d, got %d: %s", tt.status, w.Code, w.Body.String())
+			}
+			if tt.body != "" && w.Body.String() != tt.body {
+				t.Fatalf("expected body %q, got %q", tt.body, w.Body.String())
+			}
+			if got := w.Header().Get("Content-Range"); got != tt.contentRange {
+				t.Fatalf("expected Content-Range %q, got %q", tt.contentRange, got)
+			}
+		})
+	}
+
+	stale := time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)
+	w := download(svc, "tenant-a", jobID, http.Header{"Range": {"bytes=2-5"}, "If-Range": {stale}})
+	if w.Code != http.StatusOK || w.Body.String() != "0123456789" {
+		t.Fatalf("expected the whole archive for a stale If-Range, got %d %q", w.Code, w.Body.String())
+	}
+	w = download(svc, "tenant-a", jobID, nil)
+	w = download(svc, "tenant-a", jobID, http.Header{"Range": {"bytes=2-5"}, "If-Range": {w.Header().Get("Last-Modified")}})
+	if w.Code != http.StatusPartialContent || w.Body.String() != "2345" {
+		t.Fatalf("expected a resumed range for a current If-Range, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestDownloadAuditZipArtifactOtherTenantIsNotFound(t *testing.T) {
+	svc, _, jobID := newDownloadService(t, []byte("secret"))
+
+	w := download(svc, "tenant-b", jobID, nil)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for another tenant's job, got %d", w.Code)
+	}
+	if w.Body.String() == "" || w.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("expected a JSON error body, got %q", w.Body.String())
+	}
+}
+
+func TestDownloadAuditZipArtifactReapedIsGone(t *testing.T) {
+	svc, storage, jobID := newDownloadService(t, []byte("0123456789"))
+	key, _, err := svc.queue.Artifact("tenant-a", jobID)
+	if err != nil {
+		t.Fatalf("artifact: %v", err)
+	}
+	if err := storage.DeleteObject(context.Background(), key); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	if w := download(svc, "tenant-a", jobID, nil); w.Code != http.StatusGone {
+		t.Fatalf("expected 410 once the archive is reaped, got %d", w.Code)
+	}
+}
+
+// storageTarget returns the path and query of a signed URL for key.
+func storageTarget(t *testing.T, st *InMemoryStorage, key string, ttl time.Duration) string {
+	t.Helper()
+	raw, err := st.GetSignedURL(context.Background(), key, ttl)
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	return u.RequestURI()
+}
+
+func TestStorageDownloadHandlerSignedURLs(t *testing.T) {
+	st := NewInMemoryStorage()
+	st.SetSigningSecret("test-secret")
+	key := "tenant-a/audit/archive.zip"
+	_ = st.PutObject(context.Background(), key, []byte("zip"), "application/zip")
+	h := StorageDownloadHandler(st)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, storageTarget(t, st, key, time.Minute), nil))
+	if w.Code != http.StatusOK || w.Body.String() != "zip" {
+		t.Fatalf("expected a valid signed URL to serve the archive, got %d %q", w.Code, w.Body.String())
+	}
+
+	expired := storageTarget(t, st, key, -time.Minute)
+	// Extending an expired link must invalidate its signature.
+	path, rawQuery, _ := strings.Cut(expired, "?")
+	q, _ := url.ParseQuery(rawQuery)
+	q.Set("exp", time.Now().UTC().Add(time.Hour).Format(time.RFC3339))
+	tampered := path + "?" + q.Encode()
+	// A link signed for one key must not open another.
+	otherKey := StoragePath + "tenant-b/audit/archive.zip?" + strings.SplitN(storageTarget(t, st, key, time.Minute), "?", 2)[1]
+	tests := []struct {
+		name   string
+		target string
+		code   string
+	}{
+		{name: "expired", target: expired, code: "URL_EXPIRED"},
+		{name: "tampered exp", target: tampered, code: "URL_SIGNATURE_INVALID"},
+		{name: "other key", target: otherKey, code: "URL_SIGNATURE_INVALID"},
+		{name: "unsigned", target: StoragePath + key, code: "URL_SIGNATURE_INVALID"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.target, nil))
+			if w.Code != http.StatusForbidden {
+				t.Fatalf("expected 403, got %d", w.Code)
+			}
+			var body ForbiddenError
+			_ = json.NewDecoder(w.Body).Decode(&body)
+			if body.Code != tt.code {
+				t.Fatalf("expected %s, got %+v", tt.code, body)
+			}
+		})
+	}
+}
@@ -0,0 +1,242 @@
+package auditzip
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ExportRecord is one audit record written to an export archive.
+type ExportRecord struct {
+	ID         string    `json:"id"`
+	OccurredAt time.Time `json:"occurredAt"`
+	Partner    string    `json:"partner,omitempty"`
+	Amount     float64   `json:"amount"`
+	Action     string    `json:"action"`
+}
+
+// RecordSource supplies the audit records an export draws from. Records calls
+// fn for each of tenantID's records in [from, to), oldest first, stopping at the
+// first error fn returns.
+type RecordSource interface {
+	Records(ctx context.Context, tenantID string, from, to time.Time, fn func(ExportRecord) error) error
+}
+
+// InMemoryRecordSource is the default RecordSource, filled via Add.
+type InMemoryRecordSource struct {
+	mu      sync.RWMutex
+	records map[string][]ExportRecord
+}
+
+func NewInMemoryRecordSource() *InMemoryRecordSource {
+	return &InMemoryRecordSource{records: map[string][]ExportRecord{}}
+}
+
+func (s *InMemoryRecordSource) Add(tenantID string, rec ExportRecord) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	recs := append(s.records[tenantID], rec)
+	sort.SliceStable(recs, func(i, j int) bool { return recs[i].OccurredAt.Before(recs[j].OccurredAt) })
+	s.records[tenantID] = recs
+}
+
+func (s *InMemoryRecordSource) Records(ctx context.Context, tenantID string, from, to time.Time, fn func(ExportRecord) error) error {
+	s.mu.RLock()
+	recs := append([]ExportRecord(nil), s.records[tenantID]...)
+	s.mu.RUnlock()
+	for _, rec := range recs {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if rec.OccurredAt.Before(from) || !rec.OccurredAt.Before(to) {
+			continue
+		}
+		if err := fn(rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// archiveFile describes one per-day entry of an export archive.
+type archiveFile struct {
+	Name    string `json:"name"`
+	Day     string `json:"day"`
+	Records int    `json:"records"`
+}
+
+// archiveIndex is written as index.json inside the archive and alongside it.
+type archiveIndex struct {
+	From              string        `json:"from"`
+	To                string        `json:"to"`
+	Partner           *string       `json:"partner"`
+	PartnerNormalized *string       `json:"partnerNormalized,omitempty"`
+	MinAmount         *float64      `json:"minAmount,omitempty"`
+	MaxAmount         *float64      `json:"maxAmount,omitempty"`
+	Records           int           `json:"records"`
+	Files             []archiveFile `json:"files"`
+}
+
+// archiveName is the object name of an archive in the given format.
+func archiveName(format AuditZipRequestFormat) string {
+	if format == Targz {
+		return "archive.tar.gz"
+	}
+	return "archive.zip"
+}
+
+func archiveContentType(format AuditZipRequestFormat) string {
+	if format == Targz {
+		return "application/gzip"
+	}
+	return "application/zip"
+}
+
+// archiveWriter adds named entries to an archive; each Create ends the
+// previous entry.
+type archiveWriter interface {
+	Create(name string) (io.Writer, error)
+	Close() error
+}
+
+func newArchiveWriter(format AuditZipRequestFormat, w io.Writer) archiveWriter {
+	if format == Targz {
+		gz := gzip.NewWriter(w)
+		return &tarGzWriter{gz: gz, tw: tar.NewWriter(gz)}
+	}
+	return zip.NewWriter(w)
+}
+
+// tarGzWriter buffers each entry until the next Create or Close, since tar
+// headers carry the entry size up front.
+type tarGzWriter struct {
+	gz      *gzip.Writer
+	tw      *tar.Writer
+	name    string
+	pending bool
+	buf     bytes.Buffer
+}
+
+func (t *tarGzWriter) Create(name string) (io.Writer, error) {
+	if err := t.flush(); err != nil {
+		return nil, err
+	}
+	t.name, t.pending = name, true
+	return &t.buf, nil
+}
+
+func (t *tarGzWriter) flush() error {
+	if !t.pending {
+		return nil
+	}
+	hdr := &tar.Header{Name: t.name, Mode: 0o644, Size: int64(t.buf.Len()), ModTime: time.Unix(0, 0).UTC()}
+	if err := t.tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	if _, err := t.tw.Write(t.buf.Bytes()); err != nil {
+		return err
+	}
+	t.buf.Reset()
+	t.pending = false
+	return nil
+}
+
+func (t *tarGzWriter) Close() error {
+	if err := t.flush(); err != nil {
+		return err
+	}
+	if err := t.tw.Close(); err != nil {
+		return err
+	}
+	return t.gz.Close()
+}
+
+// writeArchive streams the job's archive to w in the request's format: one
+// records/YYYY-MM-DD.ndjson entry per day in the request range holding the
+// matching records, then index.json. A zip holds one record in memory at a
+// time; a tar.gz buffers one day's entry. It returns the encoded index.
+func (q *JobQueue) writeArchive(ctx context.Context, state *jobState, w io.Writer) ([]byte, error) {
+	req := state.request
+	index := archiveIndex{
+		From:      req.From.String(),
+		To:        req.To.String(),
+		Partner:   req.Partner,
+		MinAmount: req.MinAmount,
+		MaxAmount: req.MaxAmount,
+		Files:     []archiveFile{},
+	}
+	if q.cfg.NormalizePartner {
+		index.PartnerNormalized = normalizedPartner(req.Partner, q.cfg)
+	}
+	partner := normalizedPartner(req.Partner, q.cfg)
+
+	zw := newArchiveWriter(req.Format, w)
+	for day := req.From.Time.UTC(); !day.After(req.To.Time.UTC()); day = day.AddDate(0, 0, 1) {
+		file := archiveFile{Name: "records/" + day.Format("2006-01-02") + ".ndjson", Day: day.Format("2006-01-02")}
+		entry, err := zw.Create(file.Name)
+		if err != nil {
+			return nil, err
+		}
+		enc := json.NewEncoder(entry)
+		err = q.records.Records(ctx, state.tenantID, day, day.AddDate(0, 0, 1), func(rec ExportRecord) error {
+			if !matchesCriteria(rec, req, partner, q.cfg) {
+				return nil
+			}
+			file.Records++
+			return enc.Encode(rec)
+		})
+		if err != nil {
+			return nil, err
+		}
+		index.Records += file.Records
+		index.Files = append(index.Files, file)
+	}
+
+	indexBody, err := json.Marshal(index)
+	if err != nil {
+		return nil, err
+	}
+	entry, err := zw.Create("index.json")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := entry.Write(indexBody); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return indexBody, nil
+}
+
+// matchesCriteria applies the request's partner and amount filters; partner is
+// the request partner after NormalizePartner.
+func matchesCriteria(rec ExportRecord, req AuditZipRequest, partner *string, cfg Config) bool {
+	if partner != nil && NormalizePartner(rec.Partner, cfg) != *partner {
+		return false
+	}
+	if req.MinAmount != nil && rec.Amount < *req.MinAmount {
+		return false
+	}
+	if req.MaxAmount != nil && rec.Amount > *req.MaxAmount {
+		return false
+	}
+	return true
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	c.n += int64(len(p))
+	return len(p), nil
+}
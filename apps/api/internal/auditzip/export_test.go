@@ -0,0 +1,208 @@
+package auditzip
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	openapi_types "github.com/oapi-codegen/runtime/types"
+)
+
+func storedObjectBody(t *testing.T, storage *InMemoryStorage, key string) []byte {
+	t.Helper()
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+	obj, ok := storage.data[key]
+	if !ok {
+		t.Fatalf("object %s not stored", key)
+	}
+	return obj.body
+}
+
+func TestPersistArtifactsWritesRealZip(t *testing.T) {
+	cfg := testQueueConfig()
+	storage := NewInMemoryStorage()
+	q := NewJobQueue(storage, cfg)
+	source := NewInMemoryRecordSource()
+	day1 := time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)
+	day2 := day1.AddDate(0, 0, 1)
+	source.Add("tenant-a", ExportRecord{ID: "r1", OccurredAt: day1, Partner: "Acme", Amount: 100, Action: "invoice.issue"})
+	source.Add("tenant-a", ExportRecord{ID: "r2", OccurredAt: day2, Partner: "Acme", Amount: 250, Action: "invoice.issue"})
+	source.Add("tenant-a", ExportRecord{ID: "r3", OccurredAt: day2, Partner: "Globex", Amount: 75, Action: "invoice.issue"})
+	source.Add("tenant-a", ExportRecord{ID: "r4", OccurredAt: day2.AddDate(0, 0, 5), Partner: "Acme", Amount: 10, Action: "invoice.issue"})
+	source.Add("tenant-b", ExportRecord{ID: "other", OccurredAt: day1, Partner: "Acme", Amount: 100, Action: "invoice.issue"})
+	q.records = source
+	q.process = func(ctx context.Context, state *jobState) error {
+		size, err := q.persistArtifacts(ctx, state)
+		if err != nil {
+			return err
+		}
+		q.completeJob(state.job.JobId, "https://storage.local/ok", time.Now().UTC(), size)
+		return nil
+	}
+
+	partner := "Acme"
+	req := AuditZipRequest{
+		From:    openapi_types.Date{Time: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+		To:      openapi_types.Date{Time: time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)},
+		Format:  Zip,
+		Partner: &partner,
+	}
+	job, err := q.Enqueue(context.Background(), "tenant-a", uuid.NewString(), "hash-1", req)
+	if err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	done := waitForStatus(t, q, job.JobId.String(), Succeeded)
+
+	q.mu.RLock()
+	state := q.jobs[job.JobId.String()]
+	q.mu.RUnlock()
+	archive := storedObjectBody(t, storage, q.archiveKey(state))
+	if done.Result == nil || done.Result.Size != len(archive) {
+		t.Fatalf("result size = %+v, want %d", done.Result, len(archive))
+	}
+
+	sum := sha256.Sum256(archive)
+	hashes := string(storedObjectBody(t, storage, q.hashKey(state)))
+	if !strings.Contains(hashes, hex.EncodeToString(sum[:])+" archive.zip\n") {
+		t.Fatalf("hashes.txt does not carry the archive hash:\n%s", hashes)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		t.Fatalf("archive is not a valid zip: %v", err)
+	}
+	entries := map[string]string{}
+	var names []string
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("open %s: %v", f.Name, err)
+		}
+		body, _ := io.ReadAll(rc)
+		rc.Close()
+		entries[f.Name] = string(body)
+		names = append(names, f.Name)
+	}
+	if want := "records/2025-01-01.ndjson,records/2025-01-02.ndjson,index.json"; strings.Join(names, ",") != want {
+		t.Fatalf("entries = %v, want %s", names, want)
+	}
+	if !strings.Contains(entries["records/2025-01-01.ndjson"], `"id":"r1"`) {
+		t.Fatalf("day 1 missing r1: %q", entries["records/2025-01-01.ndjson"])
+	}
+	day2Lines := strings.Split(strings.TrimSpace(entries["records/2025-01-02.ndjson"]), "\n")
+	if len(day2Lines) != 1 || !strings.Contains(day2Lines[0], `"id":"r2"`) {
+		t.Fatalf("day 2 should hold only r2, got %q", day2Lines)
+	}
+
+	var index archiveIndex
+	if err := json.Unmarshal([]byte(entries["index.json"]), &index); err != nil {
+		t.Fatalf("decode index: %v", err)
+	}
+	if index.Records != 2 || len(index.Files) != 2 {
+		t.Fatalf("index = %+v, want 2 records in 2 files", index)
+	}
+	if stored := storedObjectBody(t, storage, q.indexKey(state)); string(stored) != entries["index.json"] {
+		t.Fatalf("stored index differs from archived index")
+	}
+	if !strings.Contains(hashes, hashBytes([]byte(entries["index.json"]))+" index.json\n") {
+		t.Fatalf("hashes.txt does not carry the index hash:\n%s", hashes)
+	}
+}
+
+func TestPersistArtifactsWritesTarGz(t *testing.T) {
+	cfg := testQueueConfig()
+	storage := NewInMemoryStorage()
+	q := NewJobQueue(storage, cfg)
+	source := NewInMemoryRecordSource()
+	day := time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)
+	source.Add("tenant-a", ExportRecord{ID: "r1", OccurredAt: day, Partner: "Acme", Amount: 100, Action: "invoice.issue"})
+	q.records = source
+	q.process = func(ctx context.Context, state *jobState) error {
+		size, err := q.persistArtifacts(ctx, state)
+		if err != nil {
+			return err
+		}
+		q.completeJob(state.job.JobId, "https://storage.local/ok", time.Now().UTC(), size)
+		return nil
+	}
+
+	req := AuditZipRequest{
+		From:   openapi_types.Date{Time: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+		To:     openapi_types.Date{Time: time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)},
+		Format: Targz,
+	}
+	job, err := q.Enqueue(context.Background(), "tenant-a", uuid.NewString(), "hash-targz", req)
+	if err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	waitForStatus(t, q, job.JobId.String(), Succeeded)
+
+	q.mu.RLock()
+	state := q.jobs[job.JobId.String()]
+	q.mu.RUnlock()
+	key := q.archiveKey(state)
+	if !strings.HasSuffix(key, "/archive.tar.gz") {
+		t.Fatalf("archive key = %s, want .tar.gz suffix", key)
+	}
+	storage.mu.RLock()
+	contentType := storage.data[key].contentType
+	storage.mu.RUnlock()
+	if contentType != "application/gzip" {
+		t.Fatalf("content type = %q, want application/gzip", contentType)
+	}
+	archive := storedObjectBody(t, storage, key)
+	sum := sha256.Sum256(archive)
+	if hashes := string(storedObjectBody(t, storage, q.hashKey(state))); !strings.Contains(hashes, hex.EncodeToString(sum[:])+" archive.tar.gz\n") {
+		t.Fatalf("hashes.txt does not carry the archive hash:\n%s", hashes)
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		t.Fatalf("archive is not a gzip stream: %v", err)
+	}
+	tr := tar.NewReader(gz)
+	entries := map[string]string{}
+	var names []string
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("read tar: %v", err)
+		}
+		body, _ := io.ReadAll(tr)
+		entries[hdr.Name] = string(body)
+		names = append(names, hdr.Name)
+	}
+	if want := "records/2025-01-01.ndjson,records/2025-01-02.ndjson,index.json"; strings.Join(names, ",") != want {
+		t.Fatalf("entries = %v, want %s", names, want)
+	}
+	if !strings.Contains(entries["records/2025-01-01.ndjson"], `"id":"r1"`) {
+		t.Fatalf("day 1 missing r1: %q", entries["records/2025-01-01.ndjson"])
+	}
+	if stored := storedObjectBody(t, storage, q.indexKey(state)); string(stored) != entries["index.json"] {
+		t.Fatalf("stored index differs from archived index")
+	}
+}
+
+func TestCriteriaHashDistinguishesFormat(t *testing.T) {
+	cfg := testQueueConfig()
+	req := testRequest(1)
+	zipHash := computeCriteriaHash("tenant-a", req, cfg)
+	req.Format = Targz
+	if targzHash := computeCriteriaHash("tenant-a", req, cfg); targzHash == zipHash {
+		t.Fatal("expected zip and targz requests to hash differently")
+	}
+}
@@ -0,0 +1,98 @@
+package auditzip
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// JobRecord is the durable form of a job: enough to answer GET /audit/jobs/{id}
+// and to rebuild the queue's idempotency and duplicate indexes after a restart.
+type JobRecord struct {
+	Job            AuditZipJob     `json:"job"`
+	TenantID       string          `json:"tenantId"`
+	CriteriaHash   string          `json:"criteriaHash"`
+	IdempotencyKey string          `json:"idempotencyKey"`
+	Request        AuditZipRequest `json:"request"`
+	// ParentJobID links a child of an auto-split request to its parent.
+	ParentJobID string `json:"parentJobId,omitempty"`
+	// CorrID is the correlation ID of the request that enqueued the job.
+	CorrID string `json:"corrId,omitempty"`
+}
+
+// JobStore persists job metadata so status survives process restarts.
+// LoadJob returns ErrNotFound for unknown ids.
+type JobStore interface {
+	SaveJob(ctx context.Context, rec JobRecord) error
+	LoadJob(ctx context.Context, jobID string) (JobRecord, error)
+	ListJobsByTenant(ctx context.Context, tenantID string) ([]JobRecord, error)
+	// ListJobs returns every stored job; the queue uses it to rehydrate on startup.
+	ListJobs(ctx context.Context) ([]JobRecord, error)
+	// DeleteJob removes a reaped job; deleting an unknown id is not an error.
+	DeleteJob(ctx context.Context, jobID string) error
+}
+
+// InMemoryJobStore is the default JobStore. It only outlives a JobQueue, not the
+// process, but lets tests exercise rehydration.
+type InMemoryJobStore struct {
+	mu   sync.RWMutex
+	jobs map[string]JobRecord
+}
+
+func NewInMemoryJobStore() *InMemoryJobStore {
+	return &InMemoryJobStore{jobs: map[string]JobRecord{}}
+}
+
+func (s *InMemoryJobStore) SaveJob(ctx context.Context, rec JobRecord) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	rec.Job = cloneJob(rec.Job)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs[rec.Job.JobId.String()] = rec
+	return nil
+}
+
+func (s *InMemoryJobStore) LoadJob(_ context.Context, jobID string) (JobRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	rec, ok := s.jobs[jobID]
+	if !ok {
+		return JobRecord{}, ErrNotFound
+	}
+	rec.Job = cloneJob(rec.Job)
+	return rec, nil
+}
+
+func (s *InMemoryJobStore) DeleteJob(_ context.Context, jobID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.jobs, jobID)
+	return nil
+}
+
+func (s *InMemoryJobStore) ListJobsByTenant(_ context.Context, tenantID string) ([]JobRecord, error) {
+	return s.list(func(rec JobRecord) bool { return rec.TenantID == tenantID }), nil
+}
+
+func (s *InMemoryJobStore) ListJobs(_ context.Context) ([]JobRecord, error) {
+	return s.list(func(JobRecord) bool { return true }), nil
+}
+
+// list returns matching records oldest first.
+func (s *InMemoryJobStore) list(match func(JobRecord) bool) []JobRecord {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]JobRecord, 0, len(s.jobs))
+	for _, rec := range s.jobs {
+		if match(rec) {
+			rec.Job = cloneJob(rec.Job)
+			out = append(out, rec)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Job.RequestedAt.Before(out[j].Job.RequestedAt)
+	})
+	return out
+}
@@ -0,0 +1,132 @@
+package auditzip
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestJobQueueRehydratesFromStore(t *testing.T) {
+	store := NewInMemoryJobStore()
+	cfg := testQueueConfig()
+	q, err := NewJobQueueWithStore(context.Background(), NewInMemoryStorage(), store, cfg)
+	if err != nil {
+		t.Fatalf("new queue: %v", err)
+	}
+	q.process = func(ctx context.Context, state *jobState) error {
+		q.completeJob(state.job.JobId, "https://storage.local/ok", time.Now().UTC(), 1)
+		return nil
+	}
+
+	key := uuid.NewString()
+	job, err := q.Enqueue(context.Background(), "tenant-a", key, "hash-1", testRequest(1))
+	if err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	want := waitForStatus(t, q, job.JobId.String(), Succeeded)
+
+	restarted, err := NewJobQueueWithStore(context.Background(), NewInMemoryStorage(), store, cfg)
+	if err != nil {
+		t.Fatalf("rehydrate: %v", err)
+	}
+	got, tenantID, ok := restarted.Get(job.JobId.String())
+	if !ok {
+		t.Fatalf("expected job %s after restart", job.JobId)
+	}
+	if tenantID != "tenant-a" {
+		t.Fatalf("tenant = %q, want tenant-a", tenantID)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("rehydrated job differs:\n got %+v\nwant %+v", got, want)
+	}
+
+	// The idempotency index survives too: a replay returns the original job.
+	replay, err := restarted.Enqueue(context.Background(), "tenant-a", key, "hash-1", testRequest(1))
+	if err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	if replay.JobId != job.JobId {
+		t.Fatalf("replay got job %s, want %s", replay.JobId, job.JobId)
+	}
+
+	records, err := store.ListJobsByTenant(context.Background(), "tenant-a")
+	if err != nil || len(records) != 1 {
+		t.Fatalf("ListJobsByTenant = %v, %v", records, err)
+	}
+	if others, _ := store.ListJobsByTenant(context.Background(), "tenant-b"); len(others) != 0 {
+		t.Fatalf("expected no jobs for tenant-b, got %d", len(others))
+	}
+}
+
+func interruptedRecord(t *testing.T, store JobStore) string {
+	t.Helper()
+	start := time.Now().UTC()
+	criteriaHash := "hash-1"
+	enable := true
+	job := AuditZipJob{
+		JobId:        uuid.New(),
+		Status:       Running,
+		Progress:     50,
+		RequestedAt:  start,
+		StartedAt:    &start,
+		CriteriaHash: &criteriaHash,
+		CanCancel:    &enable,
+	}
+	rec := JobRecord{Job: job, TenantID: "tenant-a", CriteriaHash: criteriaHash, IdempotencyKey: uuid.NewString(), Request: testRequest(1)}
+	if err := store.SaveJob(context.Background(), rec); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	return job.JobId.String()
+}
+
+func TestJobQueueRehydrateFailsInterruptedJobs(t *testing.T) {
+	store := NewInMemoryJobStore()
+	jobID := interruptedRecord(t, store)
+
+	q, err := NewJobQueueWithStore(context.Background(), NewInMemoryStorage(), store, testQueueConfig())
+	if err != nil {
+		t.Fatalf("rehydrate: %v", err)
+	}
+	job, _, ok := q.Get(jobID)
+	if !ok {
+		t.Fatalf("expected job %s after restart", jobID)
+	}
+	if job.Status != Failed || job.Error == nil || job.Error.Code != "INTERRUPTED" || !job.Error.Retryable {
+		t.Fatalf("expected retryable INTERRUPTED failure, got %s %+v", job.Status, job.Error)
+	}
+	if job.FinishedAt == nil {
+		t.Fatalf("expected finishedAt to be set")
+	}
+	stored, err := store.LoadJob(context.Background(), jobID)
+	if err != nil || stored.Job.Status != Failed {
+		t.Fatalf("expected failure to be persisted, got %v %v", stored.Job.Status, err)
+	}
+}
+
+func TestJobQueueRehydrateResumesInterruptedJobs(t *testing.T) {
+	store := NewInMemoryJobStore()
+	jobID := interruptedRecord(t, store)
+	cfg := testQueueConfig()
+	cfg.ResumeJobsOnStart = true
+
+	storage := NewInMemoryStorage()
+	q := NewJobQueue(storage, cfg)
+	q.store = store
+	q.process = func(ctx context.Context, state *jobState) error {
+		q.completeJob(state.job.JobId, "https://storage.local/ok", time.Now().UTC(), 1)
+		return nil
+	}
+	if err := q.rehydrate(context.Background()); err != nil {
+		t.Fatalf("rehydrate: %v", err)
+	}
+	waitForStatus(t, q, jobID, Succeeded)
+}
+
+func TestInMemoryJobStoreLoadMissing(t *testing.T) {
+	if _, err := NewInMemoryJobStore().LoadJob(context.Background(), uuid.NewString()); err != ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
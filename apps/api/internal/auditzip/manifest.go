@@ -0,0 +1,107 @@
+package auditzip
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/yourorg/yourapp/apps/api/internal/auth"
+)
+
+// VerifyManifests re-hashes the stored files of every succeeded job against
+// the job's hashes.txt, for auth.IntegrityChecker. Jobs whose manifest has
+// already been removed by retention are skipped.
+func (q *JobQueue) VerifyManifests(ctx context.Context) []auth.ManifestCheck {
+	type manifest struct {
+		tenantID, jobID, key string
+	}
+	q.mu.RLock()
+	var manifests []manifest
+	for _, state := range q.jobs {
+		if state.job.Status == Succeeded && len(state.children) == 0 {
+			manifests = append(manifests, manifest{state.tenantID, state.job.JobId.String(), q.hashKey(state)})
+		}
+	}
+	q.mu.RUnlock()
+
+	var checks []auth.ManifestCheck
+	for _, m := range manifests {
+		if ctx.Err() != nil {
+			break
+		}
+		err := verifyManifest(ctx, q.storage, m.key)
+		if errors.Is(err, errManifestGone) {
+			continue
+		}
+		checks = append(checks, auth.ManifestCheck{TenantID: m.tenantID, Artifact: m.jobID, Err: err})
+	}
+	return checks
+}
+
+// errManifestGone reports a manifest retention has already deleted.
+var errManifestGone = errors.New("manifest removed")
+
+// verifyManifest checks every "<sha256> <name>" line of the manifest at key
+// against the object name beside it. Retention deletes the manifest first, so
+// a listed file that is missing while the manifest remains is a failure.
+func verifyManifest(ctx context.Context, storage Storage, key string) error {
+	manifest, err := readObject(ctx, storage, key)
+	if errors.Is(err, ErrObjectNotFound) {
+		return errManifestGone
+	}
+	if err != nil {
+		return fmt.Errorf("read manifest: %w", err)
+	}
+
+	dir := path.Dir(key)
+	listed := 0
+	lines := bufio.NewScanner(bytes.NewReader(manifest))
+	for lines.Scan() {
+		want, name, ok := strings.Cut(lines.Text(), " ")
+		if !ok || name == "" || strings.Contains(name, "/") {
+			return fmt.Errorf("malformed manifest line %q", lines.Text())
+		}
+		got, err := hashObject(ctx, storage, dir+"/"+name)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		if got != want {
+			return fmt.Errorf("%s: sha256 %s does not match manifest %s", name, got, want)
+		}
+		listed++
+	}
+	if listed == 0 {
+		return errors.New("manifest lists no files")
+	}
+	return nil
+}
+
+func readObject(ctx context.Context, storage Storage, key string) ([]byte, error) {
+	body, err := storage.GetObjectRange(ctx, key, 0, -1)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return io.ReadAll(body)
+}
+
+// hashObject streams key through SHA-256, so archives are never held in memory.
+func hashObject(ctx context.Context, storage Storage, key string) (string, error) {
+	body, err := storage.GetObjectRange(ctx, key, 0, -1)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, body); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
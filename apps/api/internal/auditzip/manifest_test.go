@@ -0,0 +1,79 @@
+package auditzip
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/yourorg/yourapp/apps/api/internal/auth"
+)
+
+// newManifestQueue returns a queue that persists real artifacts and the id of
+// one succeeded job owned by tenant-a.
+func newManifestQueue(t *testing.T) (*JobQueue, *InMemoryStorage, string) {
+	t.Helper()
+	storage := NewInMemoryStorage()
+	q := NewJobQueue(storage, testQueueConfig())
+	q.process = func(ctx context.Context, state *jobState) error {
+		size, err := q.persistArtifacts(ctx, state)
+		if err != nil {
+			return err
+		}
+		q.completeJob(state.job.JobId, "https://storage.local/ok", time.Now().UTC(), size)
+		return nil
+	}
+	job, err := q.Enqueue(context.Background(), "tenant-a", uuid.NewString(), "hash-1", testRequest(1))
+	if err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	waitForStatus(t, q, job.JobId.String(), Succeeded)
+	return q, storage, job.JobId.String()
+}
+
+func TestVerifyManifestsCleanArtifacts(t *testing.T) {
+	q, _, jobID := newManifestQueue(t)
+
+	checks := q.VerifyManifests(context.Background())
+	if len(checks) != 1 || checks[0].Err != nil || checks[0].Artifact != jobID || checks[0].TenantID != "tenant-a" {
+		t.Fatalf("expected one clean check for %s, got %+v", jobID, checks)
+	}
+}
+
+func TestVerifyManifestsDetectsTampering(t *testing.T) {
+	q, storage, jobID := newManifestQueue(t)
+	q.mu.RLock()
+	archiveKey := q.archiveKey(q.jobs[jobID])
+	q.mu.RUnlock()
+	if err := storage.PutObject(context.Background(), archiveKey, []byte("forged"), "application/zip"); err != nil {
+		t.Fatal(err)
+	}
+
+	alerts := 0
+	checker := auth.NewIntegrityChecker(auth.NewInMemoryAuthAuditRecorder(), auth.Config{}, auth.IntegrityAlertFunc(func(_ context.Context, result auth.IntegrityResult) {
+		if result.Artifact != jobID || result.Valid {
+			t.Errorf("unexpected alert %+v", result)
+		}
+		alerts++
+	}), nil)
+	checker.AddManifests(q)
+	checker.CheckAll(context.Background())
+	if alerts != 1 {
+		t.Fatalf("expected one alert for the tampered archive, got %d", alerts)
+	}
+}
+
+func TestVerifyManifestsSkipsRetainedOut(t *testing.T) {
+	q, storage, jobID := newManifestQueue(t)
+	q.mu.RLock()
+	hashKey := q.hashKey(q.jobs[jobID])
+	q.mu.RUnlock()
+	if err := storage.DeleteObject(context.Background(), hashKey); err != nil {
+		t.Fatal(err)
+	}
+
+	if checks := q.VerifyManifests(context.Background()); len(checks) != 0 {
+		t.Fatalf("expected no checks once the manifest is gone, got %+v", checks)
+	}
+}
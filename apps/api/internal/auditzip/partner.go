@@ -0,0 +1,43 @@
+package auditzip
+
+import (
+	"strings"
+	"unicode"
+)
+
+// NormalizePartner folds a free-text partner name to a canonical form so that
+// spellings such as "Acme, Inc." and "ACME INC" coalesce: it lower-cases the
+// name, drops everything but letters and digits, then maps the result through
+// cfg.PartnerAliases. It returns partner unchanged unless cfg.NormalizePartner
+// is set.
+func NormalizePartner(partner string, cfg Config) string {
+	if !cfg.NormalizePartner {
+		return partner
+	}
+	folded := foldPartner(partner)
+	for alias, canonical := range cfg.PartnerAliases {
+		if foldPartner(alias) == folded {
+			return foldPartner(canonical)
+		}
+	}
+	return folded
+}
+
+// normalizedPartner applies NormalizePartner to an optional partner filter.
+func normalizedPartner(partner *string, cfg Config) *string {
+	if partner == nil {
+		return nil
+	}
+	normalized := NormalizePartner(*partner, cfg)
+	return &normalized
+}
+
+func foldPartner(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,91 @@
+package auditzip
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func partnerRequest(partner string) AuditZipRequest {
+	req := testRequest(1)
+	req.Partner = &partner
+	return req
+}
+
+func TestCriteriaHashCoalescesPartnerSpellings(t *testing.T) {
+	cfg := testQueueConfig()
+	a := computeCriteriaHash("tenant-a", partnerRequest("Acme, Inc."), cfg)
+	b := computeCriteriaHash("tenant-a", partnerRequest("ACME INC"), cfg)
+	if a == b {
+		t.Fatalf("expected exact matching by default, got equal hashes")
+	}
+
+	cfg.NormalizePartner = true
+	a = computeCriteriaHash("tenant-a", partnerRequest("Acme, Inc."), cfg)
+	b = computeCriteriaHash("tenant-a", partnerRequest("ACME INC"), cfg)
+	if a != b {
+		t.Fatalf("expected equivalent spellings to share a criteria hash")
+	}
+	if other := computeCriteriaHash("tenant-a", partnerRequest("Acme Holdings"), cfg); other == a {
+		t.Fatalf("expected a different partner to hash differently")
+	}
+}
+
+func TestNormalizePartnerAliases(t *testing.T) {
+	cfg := testQueueConfig()
+	cfg.NormalizePartner = true
+	cfg.PartnerAliases = splitMap("Acme Corporation=Acme Inc, ACME K.K.=Acme Inc")
+
+	for _, in := range []string{"Acme, Inc.", "acme corporation", "ACME KK"} {
+		if got := NormalizePartner(in, cfg); got != "acmeinc" {
+			t.Errorf("NormalizePartner(%q) = %q, want acmeinc", in, got)
+		}
+	}
+	if got := NormalizePartner("Globex", cfg); got != "globex" {
+		t.Errorf("NormalizePartner(Globex) = %q, want globex", got)
+	}
+}
+
+func TestIndexRecordsNormalizedPartner(t *testing.T) {
+	cfg := testQueueConfig()
+	cfg.NormalizePartner = true
+	storage := NewInMemoryStorage()
+	q := NewJobQueue(storage, cfg)
+	q.process = func(ctx context.Context, state *jobState) error {
+		if _, err := q.persistArtifacts(ctx, state); err != nil {
+			return err
+		}
+		q.completeJob(state.job.JobId, "https://storage.local/ok", time.Now().UTC(), 1)
+		return nil
+	}
+
+	req := partnerRequest("Acme, Inc.")
+	job, err := q.Enqueue(context.Background(), "tenant-a", uuid.NewString(), computeCriteriaHash("tenant-a", req, cfg), req)
+	if err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	waitForStatus(t, q, job.JobId.String(), Succeeded)
+
+	q.mu.RLock()
+	key := q.indexKey(q.jobs[job.JobId.String()])
+	q.mu.RUnlock()
+	storage.mu.RLock()
+	obj, ok := storage.data[key]
+	storage.mu.RUnlock()
+	if !ok {
+		t.Fatalf("index %s not stored", key)
+	}
+	var index struct {
+		Partner           string `json:"partner"`
+		PartnerNormalized string `json:"partnerNormalized"`
+	}
+	if err := json.Unmarshal(obj.body, &index); err != nil {
+		t.Fatalf("decode index: %v", err)
+	}
+	if index.Partner != "Acme, Inc." || index.PartnerNormalized != "acmeinc" {
+		t.Fatalf("index = %+v, want original and normalized partner", index)
+	}
+}
@@ -3,16 +3,26 @@ package auditzip
 import (
 	"context"
 	"crypto/sha256"
+	"encoding/base64"
 	"encoding/hex"
-	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"math"
+	"math/rand/v2"
+	"net/http"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/google/uuid"
 	openapi_types "github.com/oapi-codegen/runtime/types"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type jobState struct {
@@ -22,6 +32,13 @@ type jobState struct {
 	idempotencyKey string
 	request        AuditZipRequest
 	cancel         context.CancelFunc
+	// parent and children link the jobs of an auto-split request.
+	parent   *jobState
+	children []*jobState
+	// enqueuedBy is the span that enqueued the job; runJob links to it.
+	enqueuedBy trace.SpanContext
+	// corrID is the enqueuing request's correlation ID; the worker logs under it.
+	corrID string
 }
 
 type ConflictErr struct {
@@ -43,60 +60,378 @@ func (e RateLimitErr) Error() string {
 
 var ErrNotFound = errors.New("job not found")
 
+// ErrShuttingDown is returned by Enqueue and EnqueueSplit once Shutdown has
+// been called.
+var ErrShuttingDown = errors.New("job queue is shutting down")
+
+// ErrArtifactNotReady is returned by Artifact for a job without an archive of
+// its own: one that has not succeeded, or an auto-split parent.
+var ErrArtifactNotReady = errors.New("job has no downloadable archive")
+
+// ErrInvalidCursor is returned when a ListByTenant cursor cannot be decoded.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// inflightEnqueue tracks an Enqueue that is still deciding the outcome for an
+// idempotency key, so concurrent replays of that key can wait for its result.
+type inflightEnqueue struct {
+	criteriaHash string
+	done         chan struct{}
+	waiters      int
+	job          AuditZipJob
+	err          error
+}
+
 type JobQueue struct {
 	mu          sync.RWMutex
 	jobs        map[string]*jobState
 	byKey       map[string]*jobState
 	byCriteria  map[string]*jobState
+	inflightMu  sync.Mutex
+	inflight    map[string]*inflightEnqueue
 	storage     Storage
+	store       JobStore
 	cfg         Config
 	workerSlots chan struct{}
+	logger      *slog.Logger
+	now         func() time.Time
+	// random returns a value in [0, 1) for retry jitter; it is swappable for tests.
+	random func() float64
+	// records supplies the audit records written to archives.
+	records RecordSource
+	// httpClient delivers job callbacks; it is swappable for tests.
+	httpClient *http.Client
+	// tenantSlots holds a per-tenant semaphore when MaxConcurrentJobsPerTenant
+	// is set; guarded by tenantSlotsMu.
+	tenantSlotsMu sync.Mutex
+	tenantSlots   map[string]chan struct{}
+	// subscribers holds per-job event channels; guarded by mu.
+	subscribers map[string]map[chan AuditZipJob]struct{}
+	// process runs a single attempt of a job; it is swappable for tests.
+	process func(ctx context.Context, state *jobState) error
+	// stopping is closed by Shutdown, under mu; running counts runJob goroutines.
+	stopping chan struct{}
+	running  sync.WaitGroup
 }
 
 func NewJobQueue(storage Storage, cfg Config) *JobQueue {
-	return &JobQueue{
+	q := &JobQueue{
 		jobs:        map[string]*jobState{},
 		byKey:       map[string]*jobState{},
 		byCriteria:  map[string]*jobState{},
+		inflight:    map[string]*inflightEnqueue{},
+		subscribers: map[string]map[chan AuditZipJob]struct{}{},
 		storage:     storage,
+		store:       NewInMemoryJobStore(),
 		cfg:         cfg,
 		workerSlots: make(chan struct{}, cfg.MaxConcurrentJobs),
+		tenantSlots: map[string]chan struct{}{},
+		logger:      slog.Default(),
+		now:         time.Now,
+		random:      rand.Float64,
+		records:     NewInMemoryRecordSource(),
+		httpClient:  &http.Client{},
+		stopping:    make(chan struct{}),
+	}
+	q.process = q.processJob
+	return q
+}
+
+// NewJobQueueWithStore builds a queue persisting to store and rehydrates the jobs
+// already in it. Jobs left non-terminal by a previous process are re-queued when
+// cfg.ResumeJobsOnStart is set, otherwise marked Failed with a retryable error.
+func NewJobQueueWithStore(ctx context.Context, storage Storage, store JobStore, cfg Config) (*JobQueue, error) {
+	q := NewJobQueue(storage, cfg)
+	q.store = store
+	if err := q.rehydrate(ctx); err != nil {
+		return nil, fmt.Errorf("rehydrate jobs: %w", err)
 	}
+	return q, nil
 }
 
+func (q *JobQueue) rehydrate(ctx context.Context) error {
+	records, err := q.store.ListJobs(ctx)
+	if err != nil {
+		return err
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	parentIDs := map[*jobState]string{}
+	for _, rec := range records {
+		state := &jobState{
+			job:            rec.Job,
+			tenantID:       rec.TenantID,
+			criteriaHash:   rec.CriteriaHash,
+			idempotencyKey: rec.IdempotencyKey,
+			request:        rec.Request,
+			cancel:         func() {},
+			corrID:         rec.CorrID,
+		}
+		jobID := rec.Job.JobId.String()
+		q.jobs[jobID] = state
+		q.byKey[fmt.Sprintf("%s:%s", rec.TenantID, rec.IdempotencyKey)] = state
+		q.byCriteria[fmt.Sprintf("%s:%s", rec.TenantID, rec.CriteriaHash)] = state
+		if rec.ParentJobID != "" {
+			parentIDs[state] = rec.ParentJobID
+		}
+		// Artifacts of jobs finished before the restart lost their deletion
+		// timer with the old process.
+		if state.job.Status == Succeeded && state.job.FinishedAt != nil {
+			q.scheduleArtifactDeletion(state, *state.job.FinishedAt)
+		}
+		// Parents are re-aggregated from their children below.
+		if isTerminal(state.job.Status) || state.isParent() {
+			continue
+		}
+
+		disable := false
+		state.job.CanCancel = &disable
+		if q.cfg.ResumeJobsOnStart {
+			state.job.Status = Queued
+			state.job.Progress = 0
+			state.job.StartedAt = nil
+			jobCtx, cancel := context.WithCancel(context.Background())
+			state.cancel = cancel
+			q.persistLocked(state)
+			q.startJobLocked(withCorrelationID(jobCtx, state.corrID), state)
+			continue
+		}
+		now := q.now().UTC()
+		state.job.Status = Failed
+		state.job.FinishedAt = &now
+		state.job.Result = nil
+		state.job.Error = &InternalError{Code: "INTERRUPTED", Message: "job interrupted by a service restart", Retryable: true}
+		q.persistLocked(state)
+	}
+	q.linkChildrenLocked(parentIDs)
+	return nil
+}
+
+// persistLocked saves state to the job store. Persistence failures are logged
+// rather than failing the in-memory transition; callers must hold q.mu.
+func (q *JobQueue) persistLocked(state *jobState) {
+	rec := JobRecord{
+		Job:            cloneJob(state.job),
+		TenantID:       state.tenantID,
+		CriteriaHash:   state.criteriaHash,
+		IdempotencyKey: state.idempotencyKey,
+		Request:        state.request,
+		CorrID:         state.corrID,
+	}
+	if state.parent != nil {
+		rec.ParentJobID = state.parent.job.JobId.String()
+	}
+	if err := q.store.SaveJob(context.Background(), rec); err != nil {
+		CorrelationLogger(q.logger, state.corrID, state.tenantID).Warn("audit zip job persist failed", "jobId", state.job.JobId, "error", err)
+	}
+}
+
+// Enqueue creates a job for the request, or replays the existing job for the same
+// idempotency key. Concurrent replays of a key whose original is still in flight
+// wait for the original's result instead of contending on the queue lock; past
+// MaxInFlightReplays waiters they are shed with a RateLimitErr.
 func (q *JobQueue) Enqueue(ctx context.Context, tenantID, idempotencyKey, criteriaHash string, req AuditZipRequest) (AuditZipJob, error) {
+	ctx, span := startSpan(ctx, "auditzip.JobQueue.Enqueue", tenantID)
+	job, err := q.dedupe(ctx, tenantID, idempotencyKey, criteriaHash, func() (AuditZipJob, error) {
+		return q.enqueue(ctx, tenantID, idempotencyKey, criteriaHash, req)
+	})
+	span.SetAttributes(attribute.String("job.id", job.JobId.String()))
+	endSpan(span, err)
+	return job, err
+}
+
+// dedupe runs create for the first caller of an idempotency key and makes
+// concurrent replays of that key wait for its outcome.
+func (q *JobQueue) dedupe(ctx context.Context, tenantID, idempotencyKey, criteriaHash string, create func() (AuditZipJob, error)) (AuditZipJob, error) {
+	key := fmt.Sprintf("%s:%s", tenantID, idempotencyKey)
+
+	q.inflightMu.Lock()
+	if call, ok := q.inflight[key]; ok {
+		if q.cfg.MaxInFlightReplays > 0 && call.waiters >= q.cfg.MaxInFlightReplays {
+			q.inflightMu.Unlock()
+			return AuditZipJob{}, RateLimitErr{RetryAfter: q.cfg.QueueRetryAfter}
+		}
+		call.waiters++
+		q.inflightMu.Unlock()
+		return q.awaitInFlight(ctx, call, criteriaHash, create)
+	}
+	call := &inflightEnqueue{criteriaHash: criteriaHash, done: make(chan struct{})}
+	q.inflight[key] = call
+	q.inflightMu.Unlock()
+
+	call.job, call.err = create()
+
+	q.inflightMu.Lock()
+	delete(q.inflight, key)
+	q.inflightMu.Unlock()
+	close(call.done)
+	return cloneJob(call.job), call.err
+}
+
+// awaitInFlight waits for the in-flight original of a replayed key. A replay with
+// the same body gets the original's outcome; a different body falls back to the
+// regular path, which reports the idempotency mismatch.
+func (q *JobQueue) awaitInFlight(ctx context.Context, call *inflightEnqueue, criteriaHash string, create func() (AuditZipJob, error)) (AuditZipJob, error) {
+	defer func() {
+		q.inflightMu.Lock()
+		call.waiters--
+		q.inflightMu.Unlock()
+	}()
+	select {
+	case <-call.done:
+	case <-ctx.Done():
+		return AuditZipJob{}, ctx.Err()
+	}
+	if call.criteriaHash == criteriaHash {
+		return cloneJob(call.job), call.err
+	}
+	return create()
+}
+
+func (q *JobQueue) enqueue(ctx context.Context, tenantID, idempotencyKey, criteriaHash string, req AuditZipRequest) (AuditZipJob, error) {
 	q.mu.Lock()
 	defer q.mu.Unlock()
 
+	if q.stoppingLocked() {
+		return AuditZipJob{}, ErrShuttingDown
+	}
 	if q.cfg.MaxQueueDepth > 0 && q.activeCountLocked() >= q.cfg.MaxQueueDepth {
 		return AuditZipJob{}, RateLimitErr{RetryAfter: q.cfg.QueueRetryAfter}
 	}
+	if existing, ok, err := q.replayLocked(tenantID, idempotencyKey, criteriaHash); ok {
+		return existing, err
+	}
 
-	key := fmt.Sprintf("%s:%s", tenantID, idempotencyKey)
 	criteriaKey := fmt.Sprintf("%s:%s", tenantID, criteriaHash)
-
-	if existing, ok := q.byKey[key]; ok {
-		if existing.criteriaHash == criteriaHash && existing.tenantID == tenantID {
-			return cloneJob(existing.job), nil
+	if existing, ok := q.byCriteria[criteriaKey]; ok {
+		if !isTerminal(existing.job.Status) {
+			return AuditZipJob{}, ConflictErr{Reason: DuplicateJob, JobID: existing.job.JobId.String()}
+		}
+		if reused, ok := q.reuseResultLocked(ctx, existing, idempotencyKey); ok {
+			return reused, nil
 		}
-		return AuditZipJob{}, ConflictErr{Reason: IdempotencyBodyMismatch, JobID: existing.job.JobId.String()}
 	}
 
-	if existing, ok := q.byCriteria[criteriaKey]; ok && !isTerminal(existing.job.Status) {
-		return AuditZipJob{}, ConflictErr{Reason: DuplicateJob, JobID: existing.job.JobId.String()}
+	state, jobCtx := q.addJobLocked(ctx, tenantID, idempotencyKey, criteriaHash, req)
+	q.startJobLocked(jobCtx, state)
+	return cloneJob(state.job), nil
+}
+
+// reuseResultLocked returns existing in place of a new job when it succeeded
+// within cfg.ResultReuseWindow and its archive is still in storage, re-signing
+// the download URL and mapping idempotencyKey to it. ok is false when a new
+// job should run instead. The storage check runs under q.mu so concurrent
+// requests for the same criteria cannot both start a job. Callers must hold q.mu.
+func (q *JobQueue) reuseResultLocked(ctx context.Context, existing *jobState, idempotencyKey string) (AuditZipJob, bool) {
+	if q.cfg.ResultReuseWindow <= 0 || existing.job.Status != Succeeded || existing.job.FinishedAt == nil || len(existing.children) > 0 {
+		return AuditZipJob{}, false
+	}
+	if !q.now().Before(existing.job.FinishedAt.Add(q.cfg.ResultReuseWindow)) {
+		return AuditZipJob{}, false
+	}
+	key := q.archiveKey(existing)
+	if _, err := q.storage.StatObject(ctx, key); err != nil {
+		return AuditZipJob{}, false
+	}
+	expiry := q.now().UTC().Add(q.cfg.SignURLTTL)
+	signed, err := q.storage.GetSignedURL(ctx, key, q.cfg.SignURLTTL)
+	if err != nil {
+		return AuditZipJob{}, false
+	}
+	if existing.job.Result != nil {
+		existing.job.Result.SignedUrl = signed
+		existing.job.Result.ExpiresAt = expiry
+	}
+	q.byKey[fmt.Sprintf("%s:%s", existing.tenantID, idempotencyKey)] = existing
+	q.persistLocked(existing)
+	return cloneJob(existing.job), true
+}
+
+// startJobLocked runs state in the background, tracked so Shutdown can wait
+// for it. Callers must hold q.mu, which orders it before Shutdown's wait.
+func (q *JobQueue) startJobLocked(ctx context.Context, state *jobState) {
+	q.running.Add(1)
+	go func() {
+		defer q.running.Done()
+		q.runJob(ctx, state)
+	}()
+}
+
+func (q *JobQueue) stoppingLocked() bool {
+	select {
+	case <-q.stopping:
+		return true
+	default:
+		return false
+	}
+}
+
+// Shutdown stops the queue from starting jobs and waits for running ones to
+// finish. Jobs still waiting for a slot stay Queued in the job store, where a
+// restart with ResumeJobsOnStart picks them up again. If ctx ends first,
+// Shutdown returns its error and running jobs keep their last persisted state.
+func (q *JobQueue) Shutdown(ctx context.Context) error {
+	q.mu.Lock()
+	if !q.stoppingLocked() {
+		close(q.stopping)
 	}
+	q.mu.Unlock()
 
+	done := make(chan struct{})
+	go func() {
+		q.running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// replayLocked resolves an idempotency key that was already used: the original
+// job when the criteria match, an IdempotencyBodyMismatch conflict otherwise.
+// ok is false for an unused or expired key; an expired mapping is dropped so
+// the key starts a fresh job. Callers must hold q.mu.
+func (q *JobQueue) replayLocked(tenantID, idempotencyKey, criteriaHash string) (AuditZipJob, bool, error) {
+	key := fmt.Sprintf("%s:%s", tenantID, idempotencyKey)
+	existing, ok := q.byKey[key]
+	if !ok {
+		return AuditZipJob{}, false, nil
+	}
+	if q.idempotencyExpired(existing) {
+		delete(q.byKey, key)
+		return AuditZipJob{}, false, nil
+	}
+	if existing.criteriaHash == criteriaHash && existing.tenantID == tenantID {
+		return cloneJob(existing.job), true, nil
+	}
+	return AuditZipJob{}, true, ConflictErr{Reason: IdempotencyBodyMismatch, JobID: existing.job.JobId.String()}
+}
+
+// idempotencyExpired reports whether state's idempotency mapping has outlived
+// cfg.IdempotencyTTL, measured from when the job was requested.
+func (q *JobQueue) idempotencyExpired(state *jobState) bool {
+	return q.cfg.IdempotencyTTL > 0 && !q.now().Before(state.job.RequestedAt.Add(q.cfg.IdempotencyTTL))
+}
+
+// addJobLocked registers and persists a new queued job without starting it,
+// returning its state and the context runJob should use. The correlation ID
+// and span in ctx are carried over to the job; the returned context derives
+// from the job's cancelable context. Callers must hold q.mu.
+func (q *JobQueue) addJobLocked(ctx context.Context, tenantID, idempotencyKey, criteriaHash string, req AuditZipRequest) (*jobState, context.Context) {
 	jobID := uuid.New()
-	canCancel := false
+	canCancel := true
 	job := AuditZipJob{
 		JobId:        jobID,
 		Status:       Queued,
 		Progress:     0,
-		RequestedAt:  time.Now().UTC(),
+		RequestedAt:  q.now().UTC(),
 		RetryCount:   0,
 		CriteriaHash: &criteriaHash,
 		CanCancel:    &canCancel,
 	}
+	corrID := correlationID(ctx)
 	jobCtx, cancel := context.WithCancel(context.Background())
 	state := &jobState{
 		job:            job,
@@ -105,15 +440,20 @@ func (q *JobQueue) Enqueue(ctx context.Context, tenantID, idempotencyKey, criter
 		idempotencyKey: idempotencyKey,
 		request:        req,
 		cancel:         cancel,
+		enqueuedBy:     trace.SpanContextFromContext(ctx),
+		corrID:         corrID,
 	}
 	q.jobs[jobID.String()] = state
-	q.byKey[key] = state
-	q.byCriteria[criteriaKey] = state
-
-	go q.runJob(jobCtx, state)
-	return cloneJob(job), nil
+	q.byKey[fmt.Sprintf("%s:%s", tenantID, idempotencyKey)] = state
+	q.byCriteria[fmt.Sprintf("%s:%s", tenantID, criteriaHash)] = state
+	q.persistLocked(state)
+	return state, withCorrelationID(jobCtx, corrID)
 }
 
+// Cancel stops a queued or running job. Queued jobs are canceled before they take
+// a worker slot; runJob re-checks the status under the same lock, so a job cannot
+// slip into Running after being canceled here. Canceling an auto-split parent
+// cancels its unfinished children.
 func (q *JobQueue) Cancel(tenantID, jobID string) (AuditZipJob, error) {
 	q.mu.Lock()
 	defer q.mu.Unlock()
@@ -124,11 +464,24 @@ func (q *JobQueue) Cancel(tenantID, jobID string) (AuditZipJob, error) {
 	if state.tenantID != tenantID {
 		return AuditZipJob{}, ErrNotFound
 	}
-	if state.job.Status != Running {
+	if state.job.Status != Running && state.job.Status != Queued {
 		return cloneJob(state.job), ConflictErr{Reason: NotCancelable, JobID: jobID}
 	}
+	for _, child := range state.children {
+		if !isTerminal(child.job.Status) {
+			q.cancelLocked(child)
+		}
+	}
+	q.cancelLocked(state)
+	if state.parent != nil {
+		q.aggregateLocked(state.parent)
+	}
+	return cloneJob(state.job), nil
+}
+
+func (q *JobQueue) cancelLocked(state *jobState) {
 	state.cancel()
-	now := time.Now().UTC()
+	now := q.now().UTC()
 	state.job.Status = Canceled
 	state.job.FinishedAt = &now
 	state.job.Progress = minInt(100, state.job.Progress)
@@ -136,8 +489,12 @@ func (q *JobQueue) Cancel(tenantID, jobID string) (AuditZipJob, error) {
 	disable := false
 	state.job.CanCancel = &disable
 	state.job.Result = nil
-	q.jobs[jobID] = state
-	return cloneJob(state.job), nil
+	if state.isParent() {
+		state.job.Children = childSummaries(state.children)
+	}
+	q.persistLocked(state)
+	q.publishLocked(state)
+	q.notifyLocked(state)
 }
 
 func (q *JobQueue) Get(jobID string) (AuditZipJob, string, bool) {
@@ -150,42 +507,301 @@ func (q *JobQueue) Get(jobID string) (AuditZipJob, string, bool) {
 	return cloneJob(state.job), state.tenantID, true
 }
 
+// Artifact returns the storage key and file name of the archive produced by
+// tenantID's job jobID. Jobs of other tenants report ErrNotFound. The key is
+// returned even when retention has since deleted the object.
+func (q *JobQueue) Artifact(tenantID, jobID string) (key, name string, err error) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	state, ok := q.jobs[jobID]
+	if !ok || state.tenantID != tenantID {
+		return "", "", ErrNotFound
+	}
+	if state.job.Status != Succeeded || len(state.children) > 0 {
+		return "", "", ErrArtifactNotReady
+	}
+	return q.archiveKey(state), archiveName(state.request.Format), nil
+}
+
+// ListByTenant returns tenantID's jobs newest first (ties broken by job id),
+// optionally filtered by status. Jobs are snapshotted under the read lock and
+// paged afterwards, so listing never blocks job progress for long.
+func (q *JobQueue) ListByTenant(tenantID string, opts ListJobsOptions) (JobPage, error) {
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = DefaultListJobsLimit
+	}
+	if limit > MaxListJobsLimit {
+		limit = MaxListJobsLimit
+	}
+	var after *jobCursor
+	if opts.Cursor != "" {
+		c, err := decodeJobCursor(opts.Cursor)
+		if err != nil {
+			return JobPage{}, err
+		}
+		after = &c
+	}
+
+	q.mu.RLock()
+	jobs := make([]AuditZipJob, 0)
+	for _, state := range q.jobs {
+		if state.tenantID != tenantID {
+			continue
+		}
+		if opts.Status != nil && state.job.Status != *opts.Status {
+			continue
+		}
+		jobs = append(jobs, cloneJob(state.job))
+	}
+	q.mu.RUnlock()
+
+	sort.Slice(jobs, func(i, j int) bool {
+		return cursorForJob(jobs[i]).before(cursorForJob(jobs[j]))
+	})
+	page := JobPage{Jobs: make([]AuditZipJob, 0, limit)}
+	for _, job := range jobs {
+		if after != nil && !after.before(cursorForJob(job)) {
+			continue
+		}
+		if len(page.Jobs) == limit {
+			page.NextCursor = cursorForJob(page.Jobs[limit-1]).encode()
+			break
+		}
+		page.Jobs = append(page.Jobs, job)
+	}
+	return page, nil
+}
+
+// jobCursor is the (RequestedAt, JobId) position of the last job on a page.
+type jobCursor struct {
+	requestedAt int64
+	jobID       string
+}
+
+func cursorForJob(job AuditZipJob) jobCursor {
+	return jobCursor{requestedAt: job.RequestedAt.UnixNano(), jobID: job.JobId.String()}
+}
+
+// before reports whether c sorts ahead of other in newest-first order.
+func (c jobCursor) before(other jobCursor) bool {
+	if c.requestedAt != other.requestedAt {
+		return c.requestedAt > other.requestedAt
+	}
+	return c.jobID > other.jobID
+}
+
+func (c jobCursor) encode() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d|%s", c.requestedAt, c.jobID)))
+}
+
+func decodeJobCursor(s string) (jobCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return jobCursor{}, ErrInvalidCursor
+	}
+	nanos, id, ok := strings.Cut(string(raw), "|")
+	if !ok || id == "" {
+		return jobCursor{}, ErrInvalidCursor
+	}
+	n, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return jobCursor{}, ErrInvalidCursor
+	}
+	return jobCursor{requestedAt: n, jobID: id}, nil
+}
+
+// StartReaper removes terminal jobs older than cfg.JobRetention every
+// cfg.JobReapInterval until ctx is done.
+func (q *JobQueue) StartReaper(ctx context.Context) {
+	if q.cfg.JobReapInterval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(q.cfg.JobReapInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if n := q.reapExpired(); n > 0 {
+					q.logger.Info("audit zip jobs reaped", "count", n)
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// reapExpired drops terminal jobs that finished more than cfg.JobRetention ago
+// from every index and the job store, returning how many were removed. It also
+// drops idempotency mappings past cfg.IdempotencyTTL.
+func (q *JobQueue) reapExpired() int {
+	cutoff := q.now().Add(-q.cfg.JobRetention)
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	reaped := map[*jobState]bool{}
+	for jobID, state := range q.jobs {
+		if !isTerminal(state.job.Status) {
+			continue
+		}
+		finished := state.job.RequestedAt
+		if state.job.FinishedAt != nil {
+			finished = *state.job.FinishedAt
+		}
+		if finished.After(cutoff) {
+			continue
+		}
+		if state.cancel != nil {
+			state.cancel()
+		}
+		delete(q.jobs, jobID)
+		criteriaKey := fmt.Sprintf("%s:%s", state.tenantID, state.criteriaHash)
+		if q.byCriteria[criteriaKey] == state {
+			delete(q.byCriteria, criteriaKey)
+		}
+		if err := q.store.DeleteJob(context.Background(), jobID); err != nil {
+			q.logger.Warn("audit zip job delete failed", "jobId", jobID, "error", err)
+		}
+		reaped[state] = true
+	}
+	// A reaped job may be mapped under several idempotency keys: its own and
+	// any whose enqueue reused its result.
+	for key, state := range q.byKey {
+		if reaped[state] || q.idempotencyExpired(state) {
+			delete(q.byKey, key)
+		}
+	}
+	return len(reaped)
+}
+
 func (q *JobQueue) runJob(ctx context.Context, state *jobState) {
-	q.workerSlots <- struct{}{}
+	// The tenant slot is taken first so a tenant at its cap never holds a
+	// global slot another tenant could use.
+	tenantSlot := q.tenantSlot(state.tenantID)
+	if tenantSlot != nil {
+		select {
+		case tenantSlot <- struct{}{}:
+		case <-ctx.Done():
+			return // canceled while queued
+		case <-q.stopping:
+			return // shutting down; the job stays queued
+		}
+		defer func() { <-tenantSlot }()
+	}
+	select {
+	case q.workerSlots <- struct{}{}:
+	case <-ctx.Done():
+		return // canceled while queued
+	case <-q.stopping:
+		return // shutting down; the job stays queued
+	}
 	defer func() { <-q.workerSlots }()
+	defer func() {
+		if rec := recover(); rec != nil {
+			q.jobLogger(ctx, state).Error("audit zip job panicked", "panic", rec, "stack", string(debug.Stack()))
+			q.panicJob(state.job.JobId, rec)
+		}
+	}()
 
-	start := time.Now().UTC()
-	q.updateStatus(state.job.JobId, Running, func(job *AuditZipJob) {
+	start := q.now().UTC()
+	err := q.updateWithErr(state.job.JobId, func(job *AuditZipJob) error {
+		if job.Status != Queued {
+			return context.Canceled
+		}
+		if q.stoppingLocked() {
+			return ErrShuttingDown
+		}
+		job.Status = Running
 		job.StartedAt = &start
 		enable := true
 		job.CanCancel = &enable
 		job.Progress = 5
+		return nil
 	})
+	if err != nil {
+		return
+	}
+
+	// Jobs outlive the request that enqueued them, so the run is its own trace
+	// linked back to the enqueue span.
+	ctx, span := startSpan(ctx, "auditzip.JobQueue.runJob", state.tenantID,
+		trace.WithLinks(trace.Link{SpanContext: state.enqueuedBy}),
+		trace.WithAttributes(attribute.String("job.id", state.job.JobId.String())),
+	)
+	var runErr error
+	defer func() { endSpan(span, runErr) }()
+	log := q.jobLogger(ctx, state)
+	log.Info("audit zip job started")
 
 	attempt := 0
 	for {
 		attempt++
 		q.setRetryCount(state.job.JobId, attempt-1)
-		err := q.processJob(ctx, state)
+		span.SetAttributes(attribute.Int("job.attempt", attempt))
+		err := q.process(ctx, state)
 		if err == nil {
 			return
 		}
 		if errors.Is(err, context.Canceled) {
+			log.Info("audit zip job canceled")
 			return
 		}
 		if attempt >= q.cfg.MaxRetries {
+			runErr = err
+			log.Error("audit zip job failed", "attempts", attempt, "error", err)
 			q.failJob(state.job.JobId, err)
 			return
 		}
-		backoff := q.cfg.RetryBaseDelay * time.Duration(math.Pow(2, float64(attempt-1)))
-		select {
-		case <-time.After(backoff):
-		case <-ctx.Done():
+		log.Warn("audit zip job attempt failed", "attempt", attempt, "error", err)
+		if err := waitRetry(ctx, q.retryDelay(attempt)); err != nil {
 			return
 		}
 	}
 }
 
+// retryDelay returns the wait before retrying after attempt failed: full jitter
+// over RetryBaseDelay·2^(attempt-1), capped at cfg.MaxRetryDelay when set.
+func (q *JobQueue) retryDelay(attempt int) time.Duration {
+	backoff := float64(q.cfg.RetryBaseDelay) * math.Pow(2, float64(attempt-1))
+	if q.cfg.MaxRetryDelay > 0 && backoff > float64(q.cfg.MaxRetryDelay) {
+		backoff = float64(q.cfg.MaxRetryDelay)
+	}
+	if backoff > math.MaxInt64 {
+		backoff = math.MaxInt64
+	}
+	return time.Duration(q.random() * backoff)
+}
+
+// waitRetry sleeps for d, returning ctx.Err() as soon as ctx is done.
+func waitRetry(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// tenantSlot returns tenantID's semaphore, or nil when per-tenant concurrency
+// is unlimited. Semaphores are kept for the queue's lifetime so concurrent jobs
+// of a tenant always share one.
+func (q *JobQueue) tenantSlot(tenantID string) chan struct{} {
+	if q.cfg.MaxConcurrentJobsPerTenant <= 0 {
+		return nil
+	}
+	q.tenantSlotsMu.Lock()
+	defer q.tenantSlotsMu.Unlock()
+	slot, ok := q.tenantSlots[tenantID]
+	if !ok {
+		slot = make(chan struct{}, q.cfg.MaxConcurrentJobsPerTenant)
+		q.tenantSlots[tenantID] = slot
+	}
+	return slot
+}
+
 func (q *JobQueue) processJob(ctx context.Context, state *jobState) error {
 	if err := q.bumpProgress(state.job.JobId, 10); err != nil {
 		return err
@@ -209,59 +825,74 @@ func (q *JobQueue) processJob(ctx context.Context, state *jobState) error {
 		return err
 	}
 
-	expiry := time.Now().UTC().Add(q.cfg.SignURLTTL)
-	signed, err := q.storage.GetSignedURL(ctx, q.zipKey(state), q.cfg.SignURLTTL)
+	expiry := q.now().UTC().Add(q.cfg.SignURLTTL)
+	signed, err := q.storage.GetSignedURL(ctx, q.archiveKey(state), q.cfg.SignURLTTL)
 	if err != nil {
 		return err
 	}
 	q.completeJob(state.job.JobId, signed, expiry, size)
+	q.jobLogger(ctx, state).Info("audit zip job completed", "sizeBytes", size)
 	return nil
 }
 
+// jobLogger logs under the correlation ID of the request that enqueued the
+// job, carried in the worker's ctx.
+func (q *JobQueue) jobLogger(ctx context.Context, state *jobState) *slog.Logger {
+	return CorrelationLogger(q.logger, correlationID(ctx), state.tenantID).With("jobId", state.job.JobId.String())
+}
+
+// persistArtifacts streams the archive into storage through a pipe, hashing and
+// counting it on the way, then stores index.json and hashes.txt beside it.
 func (q *JobQueue) persistArtifacts(ctx context.Context, state *jobState) (int, error) {
-	payload := []byte(fmt.Sprintf("audit export %s to %s partner %v", state.request.From.String(), state.request.To.String(), state.request.Partner))
-	indexPayload := struct {
-		From    string  `json:"from"`
-		To      string  `json:"to"`
-		Partner *string `json:"partner"`
-	}{
-		From:    state.request.From.String(),
-		To:      state.request.To.String(),
-		Partner: state.request.Partner,
-	}
-	index, _ := json.Marshal(indexPayload)
-	hashes := []byte(fmt.Sprintf("%s archive.zip\n%s index.json\n", hashBytes(payload), hashBytes(index)))
-
-	keys := []struct {
-		key  string
-		body []byte
-		ct   string
-	}{
-		{q.zipKey(state), payload, "application/zip"},
-		{q.indexKey(state), index, "application/json"},
-		{q.hashKey(state), hashes, "text/plain"},
-	}
-	for _, obj := range keys {
-		if err := q.storage.PutObject(ctx, obj.key, obj.body, obj.ct); err != nil {
-			return 0, err
-		}
-	}
+	pr, pw := io.Pipe()
+	hasher := sha256.New()
+	counter := &countingWriter{}
+	var index []byte
+	written := make(chan error, 1)
 	go func() {
-		timer := time.NewTimer(q.cfg.RetentionPeriod)
-		defer timer.Stop()
-		select {
-		case <-timer.C:
-			_ = q.storage.DeleteObject(context.Background(), q.zipKey(state))
-			_ = q.storage.DeleteObject(context.Background(), q.indexKey(state))
-			_ = q.storage.DeleteObject(context.Background(), q.hashKey(state))
-		case <-ctx.Done():
-		}
+		var err error
+		index, err = q.writeArchive(ctx, state, io.MultiWriter(hasher, counter, pw))
+		pw.CloseWithError(err)
+		written <- err
 	}()
-	return len(payload), nil
+	putErr := q.storage.PutObjectStream(ctx, q.archiveKey(state), pr, archiveContentType(state.request.Format))
+	// Unblock the writer if storage stopped reading early.
+	pr.CloseWithError(io.ErrClosedPipe)
+	writeErr := <-written
+	if putErr != nil {
+		return 0, putErr
+	}
+	if writeErr != nil {
+		return 0, writeErr
+	}
+
+	hashes := []byte(fmt.Sprintf("%s %s\n%s index.json\n", hex.EncodeToString(hasher.Sum(nil)), archiveName(state.request.Format), hashBytes(index)))
+	if err := q.storage.PutObject(ctx, q.indexKey(state), index, "application/json"); err != nil {
+		return 0, err
+	}
+	if err := q.storage.PutObject(ctx, q.hashKey(state), hashes, "text/plain"); err != nil {
+		return 0, err
+	}
+	q.scheduleArtifactDeletion(state, q.now())
+	return int(counter.n), nil
+}
+
+// scheduleArtifactDeletion removes state's archive, index and manifest once
+// RetentionPeriod has passed since finishedAt. The timer is independent of the
+// job's context, so reaping the job record early never keeps its artifacts
+// past retention.
+func (q *JobQueue) scheduleArtifactDeletion(state *jobState, finishedAt time.Time) {
+	time.AfterFunc(finishedAt.Add(q.cfg.RetentionPeriod).Sub(q.now()), func() {
+		// The manifest goes first so VerifyManifests never sees it
+		// without the files it lists.
+		_ = q.storage.DeleteObject(context.Background(), q.hashKey(state))
+		_ = q.storage.DeleteObject(context.Background(), q.archiveKey(state))
+		_ = q.storage.DeleteObject(context.Background(), q.indexKey(state))
+	})
 }
 
 func (q *JobQueue) completeJob(jobID openapiUUID, signedURL string, expiresAt time.Time, size int) {
-	now := time.Now().UTC()
+	now := q.now().UTC()
 	q.updateStatus(jobID, Succeeded, func(job *AuditZipJob) {
 		job.FinishedAt = &now
 		job.Progress = 100
@@ -273,7 +904,7 @@ func (q *JobQueue) completeJob(jobID openapiUUID, signedURL string, expiresAt ti
 }
 
 func (q *JobQueue) failJob(jobID openapiUUID, err error) {
-	now := time.Now().UTC()
+	now := q.now().UTC()
 	q.updateStatus(jobID, Failed, func(job *AuditZipJob) {
 		job.FinishedAt = &now
 		disable := false
@@ -283,6 +914,17 @@ func (q *JobQueue) failJob(jobID openapiUUID, err error) {
 	})
 }
 
+func (q *JobQueue) panicJob(jobID openapiUUID, rec any) {
+	now := q.now().UTC()
+	q.updateStatus(jobID, Failed, func(job *AuditZipJob) {
+		job.FinishedAt = &now
+		disable := false
+		job.CanCancel = &disable
+		job.Result = nil
+		job.Error = &InternalError{Code: "INTERNAL_PANIC", Message: fmt.Sprintf("job panicked: %v", rec), Retryable: false}
+	})
+}
+
 func (q *JobQueue) bumpProgress(jobID openapiUUID, progress int) error {
 	return q.updateWithErr(jobID, func(job *AuditZipJob) error {
 		if job.Status == Canceled {
@@ -296,8 +938,9 @@ func (q *JobQueue) bumpProgress(jobID openapiUUID, progress int) error {
 }
 
 func (q *JobQueue) setRetryCount(jobID openapiUUID, retries int) {
-	q.updateStatus(jobID, Running, func(job *AuditZipJob) {
+	_ = q.updateWithErr(jobID, func(job *AuditZipJob) error {
 		job.RetryCount = retries
+		return nil
 	})
 }
 
@@ -316,15 +959,95 @@ func (q *JobQueue) updateWithErr(jobID openapiUUID, mutate func(job *AuditZipJob
 	if !ok {
 		return ErrNotFound
 	}
+	status, progress := state.job.Status, state.job.Progress
 	if err := mutate(&state.job); err != nil {
 		return err
 	}
 	q.jobs[jobID.String()] = state
+	q.persistLocked(state)
+	if state.job.Status != status || state.job.Progress != progress {
+		q.publishLocked(state)
+	}
+	if !isTerminal(status) && isTerminal(state.job.Status) {
+		q.notifyLocked(state)
+	}
+	if state.parent != nil {
+		q.aggregateLocked(state.parent)
+	}
 	return nil
 }
 
-func (q *JobQueue) zipKey(state *jobState) string {
-	return fmt.Sprintf("%s/%s/%s/archive.zip", q.cfg.S3Bucket, state.tenantID, state.job.JobId)
+// subscriberBuffer bounds how many undelivered snapshots a subscriber may hold
+// before older ones are coalesced away.
+const subscriberBuffer = 8
+
+// Subscribe returns a channel receiving a snapshot of the job now and after every
+// status or progress change. The channel is closed after the terminal snapshot or
+// by the returned unsubscribe func. Jobs of other tenants report ErrNotFound.
+func (q *JobQueue) Subscribe(tenantID, jobID string) (<-chan AuditZipJob, func(), error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	state, ok := q.jobs[jobID]
+	if !ok || state.tenantID != tenantID {
+		return nil, nil, ErrNotFound
+	}
+	ch := make(chan AuditZipJob, subscriberBuffer)
+	ch <- cloneJob(state.job)
+	if isTerminal(state.job.Status) {
+		close(ch)
+		return ch, func() {}, nil
+	}
+	if q.subscribers[jobID] == nil {
+		q.subscribers[jobID] = map[chan AuditZipJob]struct{}{}
+	}
+	q.subscribers[jobID][ch] = struct{}{}
+	unsubscribe := func() {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		if _, ok := q.subscribers[jobID][ch]; ok {
+			delete(q.subscribers[jobID], ch)
+			if len(q.subscribers[jobID]) == 0 {
+				delete(q.subscribers, jobID)
+			}
+			close(ch)
+		}
+	}
+	return ch, unsubscribe, nil
+}
+
+// publishLocked fans the job's current snapshot out to its subscribers without
+// blocking: a subscriber whose buffer is full loses its oldest pending snapshot.
+// Terminal snapshots close and drop every subscriber. Callers must hold q.mu,
+// which also makes publishLocked the only sender on these channels.
+func (q *JobQueue) publishLocked(state *jobState) {
+	jobID := state.job.JobId.String()
+	subs := q.subscribers[jobID]
+	if len(subs) == 0 {
+		return
+	}
+	terminal := isTerminal(state.job.Status)
+	for ch := range subs {
+		snapshot := cloneJob(state.job)
+		select {
+		case ch <- snapshot:
+		default:
+			select {
+			case <-ch:
+			default:
+			}
+			ch <- snapshot
+		}
+		if terminal {
+			close(ch)
+		}
+	}
+	if terminal {
+		delete(q.subscribers, jobID)
+	}
+}
+
+func (q *JobQueue) archiveKey(state *jobState) string {
+	return fmt.Sprintf("%s/%s/%s/%s", q.cfg.S3Bucket, state.tenantID, state.job.JobId, archiveName(state.request.Format))
 }
 
 func (q *JobQueue) indexKey(state *jobState) string {
@@ -361,6 +1084,17 @@ func cloneJob(job AuditZipJob) AuditZipJob {
 		t := *job.FinishedAt
 		clone.FinishedAt = &t
 	}
+	if job.Children != nil {
+		children := make([]AuditZipChildJob, len(*job.Children))
+		for i, child := range *job.Children {
+			if child.SignedUrl != nil {
+				u := *child.SignedUrl
+				child.SignedUrl = &u
+			}
+			children[i] = child
+		}
+		clone.Children = &children
+	}
 	return clone
 }
 
@@ -378,7 +1112,7 @@ func minInt(a, b int) int {
 func (q *JobQueue) activeCountLocked() int {
 	count := 0
 	for _, state := range q.jobs {
-		if !isTerminal(state.job.Status) {
+		if !isTerminal(state.job.Status) && !state.isParent() {
 			count++
 		}
 	}
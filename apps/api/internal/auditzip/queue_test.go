@@ -0,0 +1,791 @@
+package auditzip
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	openapi_types "github.com/oapi-codegen/runtime/types"
+)
+
+func testQueueConfig() Config {
+	cfg := LoadConfig()
+	cfg.MaxConcurrentJobs = 1
+	cfg.MaxRetries = 1
+	cfg.RetryBaseDelay = time.Millisecond
+	cfg.RetentionPeriod = time.Hour
+	return cfg
+}
+
+func testRequest(day int) AuditZipRequest {
+	return AuditZipRequest{
+		From:   openapi_types.Date{Time: time.Date(2025, 1, day, 0, 0, 0, 0, time.UTC)},
+		To:     openapi_types.Date{Time: time.Date(2025, 1, day, 0, 0, 0, 0, time.UTC)},
+		Format: Zip,
+	}
+}
+
+func waitForStatus(t *testing.T, q *JobQueue, jobID string, status AuditZipJobStatus) AuditZipJob {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if job, _, ok := q.Get(jobID); ok && job.Status == status {
+			return job
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	job, _, _ := q.Get(jobID)
+	t.Fatalf("job %s did not reach %s, last status %s", jobID, status, job.Status)
+	return job
+}
+
+func TestRunJobRecoversFromPanic(t *testing.T) {
+	q := NewJobQueue(NewInMemoryStorage(), testQueueConfig())
+	q.process = func(ctx context.Context, state *jobState) error {
+		if state.request.From.Time.Day() == 1 {
+			panic("boom")
+		}
+		q.completeJob(state.job.JobId, "https://storage.local/ok", time.Now().UTC(), 1)
+		return nil
+	}
+
+	job, err := q.Enqueue(context.Background(), "tenant-a", uuid.NewString(), "hash-1", testRequest(1))
+	if err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	failed := waitForStatus(t, q, job.JobId.String(), Failed)
+	if failed.Error == nil || failed.Error.Code != "INTERNAL_PANIC" {
+		t.Fatalf("expected INTERNAL_PANIC error, got %+v", failed.Error)
+	}
+	if failed.FinishedAt == nil {
+		t.Fatalf("expected finishedAt to be set")
+	}
+
+	// The single worker slot must have been released for the next job to run.
+	next, err := q.Enqueue(context.Background(), "tenant-a", uuid.NewString(), "hash-2", testRequest(2))
+	if err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	waitForStatus(t, q, next.JobId.String(), Succeeded)
+	deadline := time.Now().Add(time.Second)
+	for len(q.workerSlots) != 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if len(q.workerSlots) != 0 {
+		t.Fatalf("expected all worker slots released, %d held", len(q.workerSlots))
+	}
+}
+
+func TestEnqueueConcurrentReplaysShareOriginal(t *testing.T) {
+	const n = 50
+	cfg := testQueueConfig()
+	cfg.MaxInFlightReplays = n
+	q := NewJobQueue(NewInMemoryStorage(), cfg)
+	var runs atomic.Int32
+	q.process = func(ctx context.Context, state *jobState) error {
+		runs.Add(1)
+		q.completeJob(state.job.JobId, "https://storage.local/ok", time.Now().UTC(), 1)
+		return nil
+	}
+
+	key := uuid.NewString()
+	ids := make([]string, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			job, err := q.Enqueue(context.Background(), "tenant-a", key, "hash-1", testRequest(1))
+			ids[i], errs[i] = job.JobId.String(), err
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	for i := 0; i < n; i++ {
+		if errs[i] != nil {
+			t.Fatalf("request %d: enqueue: %v", i, errs[i])
+		}
+		if ids[i] != ids[0] {
+			t.Fatalf("request %d got job %s, want %s", i, ids[i], ids[0])
+		}
+	}
+	waitForStatus(t, q, ids[0], Succeeded)
+	if got := runs.Load(); got != 1 {
+		t.Fatalf("expected original to run once, ran %d times", got)
+	}
+}
+
+func TestEnqueueShedsReplaysPastLimit(t *testing.T) {
+	cfg := testQueueConfig()
+	cfg.MaxInFlightReplays = 1
+	q := NewJobQueue(NewInMemoryStorage(), cfg)
+	key := uuid.NewString()
+	inflightKey := "tenant-a:" + key
+
+	// Hold the queue lock so the original stays in flight.
+	q.mu.Lock()
+	results := make(chan error, 2)
+	go func() {
+		_, err := q.Enqueue(context.Background(), "tenant-a", key, "hash-1", testRequest(1))
+		results <- err
+	}()
+	waitForInFlight(t, q, inflightKey, 0)
+	go func() {
+		_, err := q.Enqueue(context.Background(), "tenant-a", key, "hash-1", testRequest(1))
+		results <- err
+	}()
+	waitForInFlight(t, q, inflightKey, 1)
+
+	_, err := q.Enqueue(context.Background(), "tenant-a", key, "hash-1", testRequest(1))
+	var rl RateLimitErr
+	if !errors.As(err, &rl) {
+		t.Fatalf("expected RateLimitErr past the replay limit, got %v", err)
+	}
+	if rl.RetryAfter != cfg.QueueRetryAfter {
+		t.Fatalf("expected retry after %v, got %v", cfg.QueueRetryAfter, rl.RetryAfter)
+	}
+
+	q.mu.Unlock()
+	for i := 0; i < 2; i++ {
+		if err := <-results; err != nil {
+			t.Fatalf("expected original and waiting replay to succeed, got %v", err)
+		}
+	}
+}
+
+func waitForInFlight(t *testing.T, q *JobQueue, key string, waiters int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		q.inflightMu.Lock()
+		call, ok := q.inflight[key]
+		got := -1
+		if ok {
+			got = call.waiters
+		}
+		q.inflightMu.Unlock()
+		if got == waiters {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("key %s did not reach %d in-flight waiters", key, waiters)
+}
+
+// testClock is a settable clock for JobQueue.now.
+type testClock struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+func (c *testClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.t = c.t.Add(d)
+}
+
+func TestReapExpiredRemovesOnlyOldTerminalJobs(t *testing.T) {
+	cfg := testQueueConfig()
+	cfg.MaxConcurrentJobs = 3
+	cfg.JobRetention = time.Hour
+	q := NewJobQueue(NewInMemoryStorage(), cfg)
+	clock := &testClock{t: time.Now()}
+	q.now = clock.Now
+	release := make(chan struct{})
+	q.process = func(ctx context.Context, state *jobState) error {
+		switch state.request.From.Time.Day() {
+		case 2:
+			return errors.New("boom")
+		case 3:
+			select {
+			case <-release:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		q.completeJob(state.job.JobId, "https://storage.local/ok", q.now().UTC(), 1)
+		return nil
+	}
+	defer close(release)
+
+	doneKey := uuid.NewString()
+	done, _ := q.Enqueue(context.Background(), "tenant-a", doneKey, "hash-1", testRequest(1))
+	failed, _ := q.Enqueue(context.Background(), "tenant-a", uuid.NewString(), "hash-2", testRequest(2))
+	active, _ := q.Enqueue(context.Background(), "tenant-a", uuid.NewString(), "hash-3", testRequest(3))
+	waitForStatus(t, q, done.JobId.String(), Succeeded)
+	waitForStatus(t, q, failed.JobId.String(), Failed)
+	waitForStatus(t, q, active.JobId.String(), Running)
+
+	clock.Advance(30 * time.Minute)
+	if n := q.reapExpired(); n != 0 {
+		t.Fatalf("expected nothing reaped within retention, got %d", n)
+	}
+	if _, _, ok := q.Get(done.JobId.String()); !ok {
+		t.Fatalf("expected job within retention to be returned")
+	}
+
+	clock.Advance(time.Hour)
+	if n := q.reapExpired(); n != 2 {
+		t.Fatalf("expected 2 terminal jobs reaped, got %d", n)
+	}
+	for _, id := range []string{done.JobId.String(), failed.JobId.String()} {
+		if _, _, ok := q.Get(id); ok {
+			t.Fatalf("expected job %s to be reaped", id)
+		}
+		if _, err := q.store.LoadJob(context.Background(), id); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("expected job %s removed from store, got %v", id, err)
+		}
+	}
+	if job, _, ok := q.Get(active.JobId.String()); !ok || job.Status != Running {
+		t.Fatalf("expected active job to survive, got %v %v", job.Status, ok)
+	}
+
+	q.mu.RLock()
+	jobs, keys, criteria := len(q.jobs), len(q.byKey), len(q.byCriteria)
+	q.mu.RUnlock()
+	if jobs != 1 || keys != 1 || criteria != 1 {
+		t.Fatalf("expected only the active job indexed, got jobs=%d byKey=%d byCriteria=%d", jobs, keys, criteria)
+	}
+
+	// The reaped idempotency key is free again.
+	again, err := q.Enqueue(context.Background(), "tenant-a", doneKey, "hash-4", testRequest(4))
+	if err != nil || again.JobId == done.JobId {
+		t.Fatalf("expected a fresh job for the reaped key, got %v %v", again.JobId, err)
+	}
+}
+
+func TestCancelQueuedJobNeverRuns(t *testing.T) {
+	q := NewJobQueue(NewInMemoryStorage(), testQueueConfig()) // one worker slot
+	release := make(chan struct{})
+	var ranQueued atomic.Int32
+	q.process = func(ctx context.Context, state *jobState) error {
+		if state.request.From.Time.Day() == 2 {
+			ranQueued.Add(1)
+		} else {
+			<-release
+		}
+		q.completeJob(state.job.JobId, "https://storage.local/ok", time.Now().UTC(), 1)
+		return nil
+	}
+
+	busy, err := q.Enqueue(context.Background(), "tenant-a", uuid.NewString(), "hash-1", testRequest(1))
+	if err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	waitForStatus(t, q, busy.JobId.String(), Running)
+	queued, err := q.Enqueue(context.Background(), "tenant-a", uuid.NewString(), "hash-2", testRequest(2))
+	if err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	if queued.Status != Queued || queued.CanCancel == nil || !*queued.CanCancel {
+		t.Fatalf("expected cancelable queued job, got %s %v", queued.Status, queued.CanCancel)
+	}
+
+	canceled, err := q.Cancel("tenant-a", queued.JobId.String())
+	if err != nil {
+		t.Fatalf("cancel queued job: %v", err)
+	}
+	if canceled.Status != Canceled || canceled.FinishedAt == nil {
+		t.Fatalf("expected canceled job, got %+v", canceled)
+	}
+
+	close(release)
+	waitForStatus(t, q, busy.JobId.String(), Succeeded)
+	// Give the canceled job's goroutine a chance to (wrongly) take the freed slot.
+	time.Sleep(50 * time.Millisecond)
+	if n := ranQueued.Load(); n != 0 {
+		t.Fatalf("canceled queued job ran %d times", n)
+	}
+	if job, _, _ := q.Get(queued.JobId.String()); job.Status != Canceled || job.StartedAt != nil {
+		t.Fatalf("expected job to stay canceled and never start, got %s started=%v", job.Status, job.StartedAt)
+	}
+	if len(q.workerSlots) != 0 {
+		t.Fatalf("expected worker slots released, %d held", len(q.workerSlots))
+	}
+}
+
+func TestShutdownFinishesRunningJobsAndKeepsQueued(t *testing.T) {
+	q := NewJobQueue(NewInMemoryStorage(), testQueueConfig()) // one worker slot
+	release := make(chan struct{})
+	var ranQueued atomic.Int32
+	q.process = func(ctx context.Context, state *jobState) error {
+		if state.request.From.Time.Day() == 2 {
+			ranQueued.Add(1)
+		} else {
+			<-release
+		}
+		q.completeJob(state.job.JobId, "https://storage.local/ok", time.Now().UTC(), 1)
+		return nil
+	}
+
+	running, _ := q.Enqueue(context.Background(), "tenant-a", uuid.NewString(), "hash-1", testRequest(1))
+	waitForStatus(t, q, running.JobId.String(), Running)
+	queued, _ := q.Enqueue(context.Background(), "tenant-a", uuid.NewString(), "hash-2", testRequest(2))
+
+	done := make(chan error, 1)
+	go func() { done <- q.Shutdown(context.Background()) }()
+	<-q.stopping
+	if _, err := q.Enqueue(context.Background(), "tenant-a", uuid.NewString(), "hash-3", testRequest(3)); !errors.Is(err, ErrShuttingDown) {
+		t.Fatalf("expected ErrShuttingDown for a new job, got %v", err)
+	}
+	select {
+	case err := <-done:
+		t.Fatalf("Shutdown returned %v before the running job finished", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+	if job, _, _ := q.Get(running.JobId.String()); job.Status != Succeeded {
+		t.Fatalf("expected the running job to finish, got %s", job.Status)
+	}
+	if job, _, _ := q.Get(queued.JobId.String()); job.Status != Queued || ranQueued.Load() != 0 {
+		t.Fatalf("expected the queued job to stay queued, got %s (ran %d)", job.Status, ranQueued.Load())
+	}
+	record, err := q.store.LoadJob(context.Background(), queued.JobId.String())
+	if err != nil || record.Job.Status != Queued {
+		t.Fatalf("expected the queued job persisted as queued, got %+v, %v", record.Job, err)
+	}
+}
+
+func TestShutdownReturnsOnDeadline(t *testing.T) {
+	q := NewJobQueue(NewInMemoryStorage(), testQueueConfig())
+	release := make(chan struct{})
+	defer close(release)
+	q.process = func(ctx context.Context, state *jobState) error {
+		<-release
+		return nil
+	}
+	job, _ := q.Enqueue(context.Background(), "tenant-a", uuid.NewString(), "hash-1", testRequest(1))
+	waitForStatus(t, q, job.JobId.String(), Running)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := q.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected DeadlineExceeded while a job is still running, got %v", err)
+	}
+}
+
+func TestRunJobRechecksStatusAfterSlot(t *testing.T) {
+	q := NewJobQueue(NewInMemoryStorage(), testQueueConfig())
+	var ran atomic.Int32
+	q.process = func(ctx context.Context, state *jobState) error {
+		ran.Add(1)
+		return nil
+	}
+
+	// Simulate Cancel landing after runJob took a worker slot but before its
+	// context cancellation was observed: the status re-check must stop the run.
+	jobID := uuid.New()
+	state := &jobState{job: AuditZipJob{JobId: jobID, Status: Canceled}, tenantID: "tenant-a"}
+	q.jobs[jobID.String()] = state
+	q.runJob(context.Background(), state)
+
+	if n := ran.Load(); n != 0 {
+		t.Fatalf("canceled job ran %d times", n)
+	}
+	if job, _, _ := q.Get(jobID.String()); job.Status != Canceled || job.StartedAt != nil {
+		t.Fatalf("expected job to stay canceled, got %s started=%v", job.Status, job.StartedAt)
+	}
+}
+
+func TestRetryDelayJitterWithinCappedBackoff(t *testing.T) {
+	cfg := testQueueConfig()
+	cfg.RetryBaseDelay = time.Second
+	cfg.MaxRetryDelay = 5 * time.Second
+	q := NewJobQueue(NewInMemoryStorage(), cfg)
+
+	for _, r := range []float64{0, 0.25, 0.5, 0.999999} {
+		q.random = func() float64 { return r }
+		for attempt := 1; attempt <= 64; attempt++ {
+			ceiling := cfg.MaxRetryDelay
+			if attempt <= 3 {
+				ceiling = cfg.RetryBaseDelay << (attempt - 1)
+			}
+			got := q.retryDelay(attempt)
+			if got < 0 || got > ceiling {
+				t.Fatalf("attempt %d, random %.2f: delay %s outside [0, %s]", attempt, r, got, ceiling)
+			}
+			if want := time.Duration(r * float64(ceiling)); got != want {
+				t.Fatalf("attempt %d, random %.2f: delay %s, want %s", attempt, r, got, want)
+			}
+		}
+	}
+}
+
+func TestWaitRetryAbortsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start := time.Now()
+	if err := waitRetry(ctx, time.Hour); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Fatalf("canceled wait took %s", elapsed)
+	}
+	if err := waitRetry(context.Background(), time.Millisecond); err != nil {
+		t.Fatalf("expected uncanceled wait to finish, got %v", err)
+	}
+}
+
+func TestPerTenantConcurrencyCap(t *testing.T) {
+	cfg := testQueueConfig()
+	cfg.MaxConcurrentJobs = 3
+	cfg.MaxConcurrentJobsPerTenant = 1
+	q := NewJobQueue(NewInMemoryStorage(), cfg)
+	release := make(chan struct{})
+	q.process = func(ctx context.Context, state *jobState) error {
+		if state.tenantID == "tenant-a" {
+			select {
+			case <-release:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		q.completeJob(state.job.JobId, "https://storage.local/ok", time.Now().UTC(), 1)
+		return nil
+	}
+
+	var noisy []string
+	for day := 1; day <= 5; day++ {
+		job, err := q.Enqueue(context.Background(), "tenant-a", uuid.NewString(), fmt.Sprintf("hash-a-%d", day), testRequest(day))
+		if err != nil {
+			t.Fatalf("enqueue tenant-a: %v", err)
+		}
+		noisy = append(noisy, job.JobId.String())
+	}
+	quiet, err := q.Enqueue(context.Background(), "tenant-b", uuid.NewString(), "hash-b", testRequest(1))
+	if err != nil {
+		t.Fatalf("enqueue tenant-b: %v", err)
+	}
+	waitForStatus(t, q, quiet.JobId.String(), Succeeded)
+
+	countRunning := func() int {
+		running := 0
+		for _, id := range noisy {
+			job, _, _ := q.Get(id)
+			switch job.Status {
+			case Running:
+				running++
+			case Queued:
+			default:
+				t.Fatalf("tenant-a job %s in unexpected status %s", id, job.Status)
+			}
+		}
+		return running
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for countRunning() == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond) // let any over-cap job start (wrongly)
+	if running := countRunning(); running != 1 {
+		t.Fatalf("expected tenant-a capped at 1 running job, got %d", running)
+	}
+
+	// Canceling a queued job and finishing the rest must release every slot.
+	for _, id := range noisy {
+		if job, _, _ := q.Get(id); job.Status == Queued {
+			if _, err := q.Cancel("tenant-a", id); err != nil {
+				t.Fatalf("cancel %s: %v", id, err)
+			}
+			break
+		}
+	}
+	close(release)
+	for _, id := range noisy {
+		deadline := time.Now().Add(2 * time.Second)
+		for {
+			job, _, _ := q.Get(id)
+			if isTerminal(job.Status) {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("tenant-a job %s stuck in %s", id, job.Status)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+	deadline = time.Now().Add(time.Second)
+	for (len(q.workerSlots) != 0 || len(q.tenantSlot("tenant-a")) != 0) && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if len(q.workerSlots) != 0 || len(q.tenantSlot("tenant-a")) != 0 {
+		t.Fatalf("expected slots released, global %d tenant %d", len(q.workerSlots), len(q.tenantSlot("tenant-a")))
+	}
+}
+
+func TestIdempotencyKeyExpiresAfterTTL(t *testing.T) {
+	cfg := testQueueConfig()
+	cfg.IdempotencyTTL = time.Hour
+	q := NewJobQueue(NewInMemoryStorage(), cfg)
+	clock := &testClock{t: time.Now()}
+	q.now = clock.Now
+	q.process = func(ctx context.Context, state *jobState) error {
+		q.completeJob(state.job.JobId, "https://storage.local/ok", q.now().UTC(), 1)
+		return nil
+	}
+
+	key := uuid.NewString()
+	first, err := q.Enqueue(context.Background(), "tenant-a", key, "hash-1", testRequest(1))
+	if err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	waitForStatus(t, q, first.JobId.String(), Succeeded)
+
+	clock.Advance(30 * time.Minute)
+	replay, err := q.Enqueue(context.Background(), "tenant-a", key, "hash-1", testRequest(1))
+	if err != nil || replay.JobId != first.JobId {
+		t.Fatalf("expected replay within TTL to return job %s, got %s %v", first.JobId, replay.JobId, err)
+	}
+	var conflict ConflictErr
+	if _, err := q.Enqueue(context.Background(), "tenant-a", key, "hash-2", testRequest(2)); !errors.As(err, &conflict) || conflict.Reason != IdempotencyBodyMismatch {
+		t.Fatalf("expected body mismatch conflict within TTL, got %v", err)
+	}
+
+	clock.Advance(time.Hour)
+	fresh, err := q.Enqueue(context.Background(), "tenant-a", key, "hash-1", testRequest(1))
+	if err != nil {
+		t.Fatalf("enqueue after TTL: %v", err)
+	}
+	if fresh.JobId == first.JobId {
+		t.Fatal("expected a fresh job after the TTL")
+	}
+	if job, _, ok := q.Get(first.JobId.String()); !ok || job.Status != Succeeded {
+		t.Fatalf("expected the original job to remain readable, got %v %v", job.Status, ok)
+	}
+}
+
+func TestReapExpiredDropsExpiredIdempotencyKeys(t *testing.T) {
+	cfg := testQueueConfig()
+	cfg.IdempotencyTTL = time.Hour
+	cfg.JobRetention = 24 * time.Hour
+	q := NewJobQueue(NewInMemoryStorage(), cfg)
+	clock := &testClock{t: time.Now()}
+	q.now = clock.Now
+	q.process = func(ctx context.Context, state *jobState) error {
+		q.completeJob(state.job.JobId, "https://storage.local/ok", q.now().UTC(), 1)
+		return nil
+	}
+
+	job, _ := q.Enqueue(context.Background(), "tenant-a", uuid.NewString(), "hash-1", testRequest(1))
+	waitForStatus(t, q, job.JobId.String(), Succeeded)
+
+	clock.Advance(2 * time.Hour)
+	if n := q.reapExpired(); n != 0 {
+		t.Fatalf("expected no jobs reaped within retention, got %d", n)
+	}
+	q.mu.RLock()
+	jobs, keys := len(q.jobs), len(q.byKey)
+	q.mu.RUnlock()
+	if jobs != 1 || keys != 0 {
+		t.Fatalf("expected the job kept and its key dropped, got jobs=%d byKey=%d", jobs, keys)
+	}
+}
+
+func TestReapExpiredDropsReusedResultKeys(t *testing.T) {
+	cfg := testQueueConfig()
+	cfg.IdempotencyTTL = 0
+	cfg.JobRetention = time.Hour
+	cfg.ResultReuseWindow = 10 * time.Minute
+	q := NewJobQueue(NewInMemoryStorage(), cfg)
+	clock := &testClock{t: time.Now()}
+	q.now = clock.Now
+	q.process = func(ctx context.Context, state *jobState) error {
+		if err := q.storage.PutObject(ctx, q.archiveKey(state), []byte("zip"), "application/zip"); err != nil {
+			return err
+		}
+		q.completeJob(state.job.JobId, "https://storage.local/ok", q.now().UTC(), 3)
+		return nil
+	}
+
+	first, _ := q.Enqueue(context.Background(), "tenant-a", uuid.NewString(), "hash-1", testRequest(1))
+	waitForStatus(t, q, first.JobId.String(), Succeeded)
+	clock.Advance(5 * time.Minute)
+	if reused, err := q.Enqueue(context.Background(), "tenant-a", uuid.NewString(), "hash-1", testRequest(1)); err != nil || reused.JobId != first.JobId {
+		t.Fatalf("expected job %s reused, got %s %v", first.JobId, reused.JobId, err)
+	}
+
+	clock.Advance(2 * time.Hour)
+	if n := q.reapExpired(); n != 1 {
+		t.Fatalf("expected 1 job reaped, got %d", n)
+	}
+	q.mu.RLock()
+	keys := len(q.byKey)
+	q.mu.RUnlock()
+	if keys != 0 {
+		t.Fatalf("expected every key mapped to the reaped job dropped, got byKey=%d", keys)
+	}
+}
+
+// persistingQueue returns a queue over storage and store whose jobs persist
+// real artifacts, and the archive key of one succeeded job owned by tenant-a.
+func persistingQueue(t *testing.T, storage *InMemoryStorage, store JobStore, cfg Config) (*JobQueue, string, string) {
+	t.Helper()
+	q, err := NewJobQueueWithStore(context.Background(), storage, store, cfg)
+	if err != nil {
+		t.Fatalf("new queue: %v", err)
+	}
+	q.process = func(ctx context.Context, state *jobState) error {
+		size, err := q.persistArtifacts(ctx, state)
+		if err != nil {
+			return err
+		}
+		q.completeJob(state.job.JobId, "https://storage.local/ok", time.Now().UTC(), size)
+		return nil
+	}
+	job, err := q.Enqueue(context.Background(), "tenant-a", uuid.NewString(), "hash-1", testRequest(1))
+	if err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	waitForStatus(t, q, job.JobId.String(), Succeeded)
+	key, _, err := q.Artifact("tenant-a", job.JobId.String())
+	if err != nil {
+		t.Fatalf("artifact: %v", err)
+	}
+	return q, job.JobId.String(), key
+}
+
+// waitForDeletion fails t unless key disappears from storage within 2s.
+func waitForDeletion(t *testing.T, storage *InMemoryStorage, key string) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, err := storage.StatObject(context.Background(), key); errors.Is(err, ErrObjectNotFound) {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("expected %s to be deleted at retention", key)
+}
+
+func TestReapedJobArtifactsAreDeletedAtRetention(t *testing.T) {
+	cfg := testQueueConfig()
+	cfg.RetentionPeriod = 200 * time.Millisecond
+	cfg.JobRetention = time.Millisecond
+	storage := NewInMemoryStorage()
+	q, _, key := persistingQueue(t, storage, NewInMemoryJobStore(), cfg)
+
+	time.Sleep(5 * time.Millisecond)
+	if n := q.reapExpired(); n != 1 {
+		t.Fatalf("expected 1 job reaped, got %d", n)
+	}
+	if _, err := storage.StatObject(context.Background(), key); err != nil {
+		t.Fatalf("expected the archive kept until retention, got %v", err)
+	}
+	waitForDeletion(t, storage, key)
+}
+
+func TestRehydratedJobArtifactsAreDeletedAtRetention(t *testing.T) {
+	cfg := testQueueConfig()
+	storage, store := NewInMemoryStorage(), NewInMemoryJobStore()
+	_, _, key := persistingQueue(t, storage, store, cfg)
+
+	// The first process's hour-long timer is lost with it; the restarted
+	// queue must schedule deletion from the job's FinishedAt.
+	cfg.RetentionPeriod = 50 * time.Millisecond
+	if _, err := NewJobQueueWithStore(context.Background(), storage, store, cfg); err != nil {
+		t.Fatalf("rehydrate: %v", err)
+	}
+	waitForDeletion(t, storage, key)
+}
+
+func TestEnqueueReusesRecentResult(t *testing.T) {
+	cfg := testQueueConfig()
+	cfg.ResultReuseWindow = 10 * time.Minute
+	storage := NewInMemoryStorage()
+	q := NewJobQueue(storage, cfg)
+	clock := &testClock{t: time.Now()}
+	q.now = clock.Now
+	q.process = func(ctx context.Context, state *jobState) error {
+		if err := q.storage.PutObject(ctx, q.archiveKey(state), []byte("zip"), "application/zip"); err != nil {
+			return err
+		}
+		q.completeJob(state.job.JobId, "https://storage.local/stale", q.now().UTC(), 3)
+		return nil
+	}
+
+	first, err := q.Enqueue(context.Background(), "tenant-a", uuid.NewString(), "hash-1", testRequest(1))
+	if err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	waitForStatus(t, q, first.JobId.String(), Succeeded)
+
+	clock.Advance(5 * time.Minute)
+	reusedKey := uuid.NewString()
+	reused, err := q.Enqueue(context.Background(), "tenant-a", reusedKey, "hash-1", testRequest(1))
+	if err != nil {
+		t.Fatalf("enqueue within window: %v", err)
+	}
+	if reused.JobId != first.JobId || reused.Status != Succeeded {
+		t.Fatalf("expected job %s reused, got %s %s", first.JobId, reused.JobId, reused.Status)
+	}
+	if reused.Result == nil || reused.Result.SignedUrl == "https://storage.local/stale" {
+		t.Fatalf("expected a freshly signed URL, got %+v", reused.Result)
+	}
+	if !reused.Result.ExpiresAt.Equal(clock.Now().UTC().Add(cfg.SignURLTTL)) {
+		t.Fatalf("expected expiry re-based on now, got %v", reused.Result.ExpiresAt)
+	}
+	// The reusing request's key now replays the same job.
+	if replay, err := q.Enqueue(context.Background(), "tenant-a", reusedKey, "hash-1", testRequest(1)); err != nil || replay.JobId != first.JobId {
+		t.Fatalf("expected replay of reused key to return %s, got %s %v", first.JobId, replay.JobId, err)
+	}
+
+	// Past the window a new job runs even though the archive still exists.
+	clock.Advance(10 * time.Minute)
+	later, err := q.Enqueue(context.Background(), "tenant-a", uuid.NewString(), "hash-1", testRequest(1))
+	if err != nil || later.JobId == first.JobId {
+		t.Fatalf("expected a new job past the window, got %s %v", later.JobId, err)
+	}
+	waitForStatus(t, q, later.JobId.String(), Succeeded)
+}
+
+func TestEnqueueStartsNewJobOnceArtifactIsGone(t *testing.T) {
+	cfg := testQueueConfig()
+	cfg.ResultReuseWindow = 10 * time.Minute
+	storage := NewInMemoryStorage()
+	q := NewJobQueue(storage, cfg)
+	q.process = func(ctx context.Context, state *jobState) error {
+		if err := q.storage.PutObject(ctx, q.archiveKey(state), []byte("zip"), "application/zip"); err != nil {
+			return err
+		}
+		q.completeJob(state.job.JobId, "https://storage.local/ok", q.now().UTC(), 3)
+		return nil
+	}
+
+	first, _ := q.Enqueue(context.Background(), "tenant-a", uuid.NewString(), "hash-1", testRequest(1))
+	waitForStatus(t, q, first.JobId.String(), Succeeded)
+	key, _, err := q.Artifact("tenant-a", first.JobId.String())
+	if err != nil {
+		t.Fatalf("artifact: %v", err)
+	}
+	if err := storage.DeleteObject(context.Background(), key); err != nil {
+		t.Fatalf("delete archive: %v", err)
+	}
+
+	next, err := q.Enqueue(context.Background(), "tenant-a", uuid.NewString(), "hash-1", testRequest(1))
+	if err != nil {
+		t.Fatalf("enqueue after archive removal: %v", err)
+	}
+	if next.JobId == first.JobId {
+		t.Fatal("expected a new job once the archive is gone")
+	}
+	waitForStatus(t, q, next.JobId.String(), Succeeded)
+}
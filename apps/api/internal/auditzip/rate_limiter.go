@@ -10,6 +10,7 @@ type RateLimiter struct {
 	perTenant map[string]*tenantRate
 	limit     int
 	window    time.Duration
+	now       func() time.Time
 }
 
 type tenantRate struct {
@@ -25,6 +26,7 @@ func NewRateLimiter(limit int, window time.Duration) *RateLimiter {
 		perTenant: map[string]*tenantRate{},
 		limit:     limit,
 		window:    window,
+		now:       time.Now,
 	}
 }
 
@@ -34,7 +36,7 @@ func (r *RateLimiter) Allow(tenant string) (bool, time.Duration) {
 	}
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	now := time.Now()
+	now := r.now()
 	state, ok := r.perTenant[tenant]
 	if !ok {
 		state = &tenantRate{windowStart: now}
@@ -50,3 +52,36 @@ func (r *RateLimiter) Allow(tenant string) (bool, time.Duration) {
 	state.count++
 	return true, 0
 }
+
+// Remaining reports how many requests tenant may still make in the current
+// window and when that window ends, without counting a request. A tenant with
+// no window open, or whose window has lapsed, has the full limit starting now.
+func (r *RateLimiter) Remaining(tenant string) (int, time.Time) {
+	if r == nil || r.limit == 0 {
+		return 0, time.Time{}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.now()
+	state, ok := r.perTenant[tenant]
+	if !ok || now.Sub(state.windowStart) >= r.window {
+		return r.limit, now.Add(r.window)
+	}
+	return max(r.limit-state.count, 0), state.windowStart.Add(r.window)
+}
+
+// Limit returns the number of requests allowed per tenant per window (0 when disabled).
+func (r *RateLimiter) Limit() int {
+	if r == nil {
+		return 0
+	}
+	return r.limit
+}
+
+// Window returns the limiter's fixed window.
+func (r *RateLimiter) Window() time.Duration {
+	if r == nil {
+		return 0
+	}
+	return r.window
+}
@@ -0,0 +1,175 @@
+package auditzip
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// s3API is the subset of *s3.Client used by S3Storage.
+type s3API interface {
+	PutObject(ctx context.Context, in *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+	HeadObject(ctx context.Context, in *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error)
+	GetObject(ctx context.Context, in *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+	DeleteObject(ctx context.Context, in *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error)
+}
+
+// s3Presigner is the subset of *s3.PresignClient used by S3Storage.
+type s3Presigner interface {
+	PresignGetObject(ctx context.Context, in *s3.GetObjectInput, optFns ...func(*s3.PresignOptions)) (*v4.PresignedHTTPRequest, error)
+}
+
+// S3Storage stores archives in an S3-compatible bucket and hands out presigned
+// GET URLs. Credentials come from the default AWS chain (env, profile, IRSA).
+type S3Storage struct {
+	client    s3API
+	presigner s3Presigner
+	bucket    string
+	sse       bool
+	kmsKeyID  string
+}
+
+// NewS3Storage builds an S3Storage for cfg.S3Bucket. A custom cfg.S3Endpoint
+// (e.g. MinIO) switches the client to path-style addressing.
+func NewS3Storage(ctx context.Context, cfg Config) (*S3Storage, error) {
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx, awsconfig.WithRegion(cfg.S3Region))
+	if err != nil {
+		return nil, fmt.Errorf("load aws config: %w", err)
+	}
+	client := s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		if cfg.S3Endpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.S3Endpoint)
+			o.UsePathStyle = true
+		}
+	})
+	return newS3Storage(client, s3.NewPresignClient(client), cfg), nil
+}
+
+func newS3Storage(client s3API, presigner s3Presigner, cfg Config) *S3Storage {
+	return &S3Storage{
+		client:    client,
+		presigner: presigner,
+		bucket:    cfg.S3Bucket,
+		sse:       cfg.EnableSSE,
+		kmsKeyID:  cfg.KMSKeyID,
+	}
+}
+
+// PutObject uploads body, requesting server-side encryption when enabled:
+// SSE-KMS with KMSKeyID when set, otherwise SSE-S3 (AES256).
+func (s *S3Storage) PutObject(ctx context.Context, key string, body []byte, contentType string) error {
+	return s.PutObjectStream(ctx, key, bytes.NewReader(body), contentType)
+}
+
+// PutObjectStream uploads body like PutObject. The SDK sends unseekable bodies
+// with a trailing checksum, which requires an https endpoint.
+func (s *S3Storage) PutObjectStream(ctx context.Context, key string, body io.Reader, contentType string) error {
+	in := &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(key),
+		Body:        body,
+		ContentType: aws.String(contentType),
+	}
+	if s.sse {
+		if s.kmsKeyID != "" {
+			in.ServerSideEncryption = types.ServerSideEncryptionAwsKms
+			in.SSEKMSKeyId = aws.String(s.kmsKeyID)
+		} else {
+			in.ServerSideEncryption = types.ServerSideEncryptionAes256
+		}
+	}
+	if _, err := s.client.PutObject(ctx, in); err != nil {
+		return fmt.Errorf("s3 put %s: %w", key, err)
+	}
+	return nil
+}
+
+func (s *S3Storage) GetSignedURL(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	req, err := s.presigner.PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	}, s3.WithPresignExpires(ttl))
+	if err != nil {
+		return "", fmt.Errorf("s3 presign %s: %w", key, err)
+	}
+	return req.URL, nil
+}
+
+func (s *S3Storage) StatObject(ctx context.Context, key string) (ObjectInfo, error) {
+	out, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		if isS3NotFound(err) {
+			return ObjectInfo{}, ErrObjectNotFound
+		}
+		return ObjectInfo{}, fmt.Errorf("s3 head %s: %w", key, err)
+	}
+	return ObjectInfo{
+		Size:        aws.ToInt64(out.ContentLength),
+		ContentType: aws.ToString(out.ContentType),
+		ModTime:     aws.ToTime(out.LastModified),
+	}, nil
+}
+
+// GetObjectRange issues a ranged GET, so only the requested bytes leave the
+// bucket and the body is streamed rather than buffered.
+func (s *S3Storage) GetObjectRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	in := &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	}
+	switch {
+	case length == 0:
+		return io.NopCloser(bytes.NewReader(nil)), nil
+	case length > 0:
+		in.Range = aws.String(fmt.Sprintf("bytes=%d-%d", offset, offset+length-1))
+	case offset > 0:
+		in.Range = aws.String(fmt.Sprintf("bytes=%d-", offset))
+	}
+	out, err := s.client.GetObject(ctx, in)
+	if err != nil {
+		if isS3NotFound(err) {
+			return nil, ErrObjectNotFound
+		}
+		return nil, fmt.Errorf("s3 get %s: %w", key, err)
+	}
+	return out.Body, nil
+}
+
+// DeleteObject removes key; an already-deleted object is not an error.
+func (s *S3Storage) DeleteObject(ctx context.Context, key string) error {
+	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil && !isS3NotFound(err) {
+		return fmt.Errorf("s3 delete %s: %w", key, err)
+	}
+	return nil
+}
+
+func isS3NotFound(err error) bool {
+	var nsk *types.NoSuchKey
+	if errors.As(err, &nsk) {
+		return true
+	}
+	var coded interface{ ErrorCode() string }
+	if errors.As(err, &coded) {
+		switch coded.ErrorCode() {
+		case "NoSuchKey", "NotFound":
+			return true
+		}
+	}
+	return false
+}
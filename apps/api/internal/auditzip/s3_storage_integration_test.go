@@ -0,0 +1,65 @@
+//go:build integration
+
+package auditzip
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// TestS3StorageMinIO runs against a local MinIO container, e.g.
+//
+//	docker run -p 9000:9000 -e MINIO_ROOT_USER=minio -e MINIO_ROOT_PASSWORD=minio123 minio/minio server /data
+//	AWS_ACCESS_KEY_ID=minio AWS_SECRET_ACCESS_KEY=minio123 AUDIT_S3_IT_ENDPOINT=http://localhost:9000 \
+//	  go test -tags integration -run TestS3StorageMinIO ./internal/auditzip
+func TestS3StorageMinIO(t *testing.T) {
+	endpoint := os.Getenv("AUDIT_S3_IT_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("AUDIT_S3_IT_ENDPOINT not set")
+	}
+	ctx := context.Background()
+	cfg := testQueueConfig()
+	cfg.S3Endpoint = endpoint
+	cfg.S3Region = "us-east-1"
+	cfg.S3Bucket = "audit-it-" + time.Now().UTC().Format("20060102150405")
+	// MinIO rejects SSE requests unless a KMS is configured.
+	cfg.EnableSSE = false
+
+	st, err := NewS3Storage(ctx, cfg)
+	if err != nil {
+		t.Fatalf("new storage: %v", err)
+	}
+	if _, err := st.client.(*s3.Client).CreateBucket(ctx, &s3.CreateBucketInput{Bucket: aws.String(cfg.S3Bucket)}); err != nil {
+		t.Fatalf("create bucket: %v", err)
+	}
+
+	if err := st.PutObject(ctx, "tenant-a/job.zip", []byte("archive"), "application/zip"); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	signed, err := st.GetSignedURL(ctx, "tenant-a/job.zip", time.Minute)
+	if err != nil {
+		t.Fatalf("presign: %v", err)
+	}
+	resp, err := http.Get(signed)
+	if err != nil {
+		t.Fatalf("get signed url: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(body) != "archive" {
+		t.Fatalf("signed GET = %d %q", resp.StatusCode, body)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := st.DeleteObject(ctx, "tenant-a/job.zip"); err != nil {
+			t.Fatalf("delete #%d: %v", i+1, err)
+		}
+	}
+}
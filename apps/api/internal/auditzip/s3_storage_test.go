@@ -0,0 +1,162 @@
+package auditzip
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+type fakeS3 struct {
+	puts      []*s3.PutObjectInput
+	deleteErr error
+	getErr    error
+	gets      []*s3.GetObjectInput
+	expires   time.Duration
+}
+
+func (f *fakeS3) HeadObject(_ context.Context, _ *s3.HeadObjectInput, _ ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	if f.getErr != nil {
+		return nil, f.getErr
+	}
+	size := int64(3)
+	return &s3.HeadObjectOutput{ContentLength: &size}, nil
+}
+
+func (f *fakeS3) GetObject(_ context.Context, in *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	f.gets = append(f.gets, in)
+	if f.getErr != nil {
+		return nil, f.getErr
+	}
+	return &s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader("zip"))}, nil
+}
+
+func (f *fakeS3) PutObject(_ context.Context, in *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	f.puts = append(f.puts, in)
+	return &s3.PutObjectOutput{}, nil
+}
+
+func (f *fakeS3) DeleteObject(_ context.Context, _ *s3.DeleteObjectInput, _ ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+	return &s3.DeleteObjectOutput{}, f.deleteErr
+}
+
+func (f *fakeS3) PresignGetObject(_ context.Context, in *s3.GetObjectInput, optFns ...func(*s3.PresignOptions)) (*v4.PresignedHTTPRequest, error) {
+	var opts s3.PresignOptions
+	for _, fn := range optFns {
+		fn(&opts)
+	}
+	f.expires = opts.Expires
+	return &v4.PresignedHTTPRequest{URL: "https://s3.example.com/" + *in.Bucket + "/" + *in.Key}, nil
+}
+
+func TestS3StoragePutObjectSetsSSE(t *testing.T) {
+	tests := []struct {
+		name     string
+		sse      bool
+		kmsKeyID string
+		wantSSE  types.ServerSideEncryption
+		wantKMS  string
+	}{
+		{name: "kms", sse: true, kmsKeyID: "arn:aws:kms:ap-northeast-1:111122223333:key/abc", wantSSE: types.ServerSideEncryptionAwsKms, wantKMS: "arn:aws:kms:ap-northeast-1:111122223333:key/abc"},
+		{name: "s3 managed", sse: true, wantSSE: types.ServerSideEncryptionAes256},
+		{name: "disabled", sse: false, kmsKeyID: "ignored"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testQueueConfig()
+			cfg.S3Bucket = "audit-archives"
+			cfg.EnableSSE = tt.sse
+			cfg.KMSKeyID = tt.kmsKeyID
+			fake := &fakeS3{}
+			st := newS3Storage(fake, fake, cfg)
+
+			if err := st.PutObject(context.Background(), "t/a.zip", []byte("zip"), "application/zip"); err != nil {
+				t.Fatalf("put: %v", err)
+			}
+			in := fake.puts[0]
+			if in.ServerSideEncryption != tt.wantSSE {
+				t.Fatalf("ServerSideEncryption = %q, want %q", in.ServerSideEncryption, tt.wantSSE)
+			}
+			gotKMS := ""
+			if in.SSEKMSKeyId != nil {
+				gotKMS = *in.SSEKMSKeyId
+			}
+			if gotKMS != tt.wantKMS {
+				t.Fatalf("SSEKMSKeyId = %q, want %q", gotKMS, tt.wantKMS)
+			}
+			if *in.Bucket != "audit-archives" || *in.Key != "t/a.zip" || *in.ContentType != "application/zip" {
+				t.Fatalf("unexpected put input %+v", in)
+			}
+			body, _ := io.ReadAll(in.Body)
+			if string(body) != "zip" {
+				t.Fatalf("body = %q", body)
+			}
+		})
+	}
+}
+
+func TestS3StorageGetSignedURLUsesTTL(t *testing.T) {
+	fake := &fakeS3{}
+	st := newS3Storage(fake, fake, testQueueConfig())
+	if _, err := st.GetSignedURL(context.Background(), "t/a.zip", 10*time.Minute); err != nil {
+		t.Fatalf("presign: %v", err)
+	}
+	if fake.expires != 10*time.Minute {
+		t.Fatalf("presign expiry = %v, want 10m", fake.expires)
+	}
+}
+
+func TestS3StorageDeleteToleratesMissingObject(t *testing.T) {
+	fake := &fakeS3{deleteErr: &types.NoSuchKey{}}
+	st := newS3Storage(fake, fake, testQueueConfig())
+	if err := st.DeleteObject(context.Background(), "t/gone.zip"); err != nil {
+		t.Fatalf("expected missing object to be ignored, got %v", err)
+	}
+
+	fake.deleteErr = errors.New("access denied")
+	if err := st.DeleteObject(context.Background(), "t/a.zip"); err == nil {
+		t.Fatal("expected other delete errors to surface")
+	}
+}
+
+func TestS3StorageGetObjectRangeSendsRangeHeader(t *testing.T) {
+	tests := []struct {
+		offset, length int64
+		want           string
+	}{
+		{offset: 0, length: -1, want: ""},
+		{offset: 10, length: -1, want: "bytes=10-"},
+		{offset: 10, length: 5, want: "bytes=10-14"},
+	}
+	for _, tt := range tests {
+		fake := &fakeS3{}
+		st := newS3Storage(fake, fake, testQueueConfig())
+		body, err := st.GetObjectRange(context.Background(), "t/a.zip", tt.offset, tt.length)
+		if err != nil {
+			t.Fatalf("get: %v", err)
+		}
+		_ = body.Close()
+		got := ""
+		if r := fake.gets[0].Range; r != nil {
+			got = *r
+		}
+		if got != tt.want {
+			t.Fatalf("Range for offset %d length %d = %q, want %q", tt.offset, tt.length, got, tt.want)
+		}
+	}
+
+	fake := &fakeS3{getErr: &types.NoSuchKey{}}
+	st := newS3Storage(fake, fake, testQueueConfig())
+	if _, err := st.GetObjectRange(context.Background(), "t/gone.zip", 0, -1); !errors.Is(err, ErrObjectNotFound) {
+		t.Fatalf("expected ErrObjectNotFound, got %v", err)
+	}
+	if _, err := st.StatObject(context.Background(), "t/gone.zip"); !errors.Is(err, ErrObjectNotFound) {
+		t.Fatalf("expected ErrObjectNotFound from stat, got %v", err)
+	}
+}
@@ -5,21 +5,30 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"math"
 	"net/http"
+	"strconv"
+	"sync"
 	"time"
 
 	openapi_types "github.com/oapi-codegen/runtime/types"
+
+	"github.com/yourorg/yourapp/apps/api/internal/auth"
+	"github.com/yourorg/yourapp/apps/api/internal/ratelimit"
+	"github.com/yourorg/yourapp/apps/api/internal/rules"
 )
 
 type Service struct {
-	cfg     Config
-	queue   *JobQueue
-	audit   AuditRecorder
-	logger  *slog.Logger
-	limiter *RateLimiter
+	cfg        Config
+	queue      *JobQueue
+	audit      AuditRecorder
+	logger     *slog.Logger
+	limiter    *RateLimiter
+	rateEvents ratelimit.Recorder
 }
 
 func NewService(cfg Config, queue *JobQueue, audit AuditRecorder, logger *slog.Logger) Service {
@@ -35,46 +44,61 @@ func NewService(cfg Config, queue *JobQueue, audit AuditRecorder, logger *slog.L
 	}
 }
 
+// WithRateLimitRecorder returns a copy of s reporting each rate-limit rejection
+// to rec when cfg.AuditRateLimits is set.
+func (s Service) WithRateLimitRecorder(rec ratelimit.Recorder) Service {
+	s.rateEvents = rec
+	return s
+}
+
+// Limiter names reported in rate-limit events.
+const (
+	LimiterTenant = "auditzip.tenant"
+	LimiterQueue  = "auditzip.queue"
+)
+
 func (s Service) EnqueueAuditZip(w http.ResponseWriter, r *http.Request, params EnqueueAuditZipParams) {
 	corrID := params.XCorrelationId.String()
 	tenantID := string(params.XTenantId)
 	idempotencyKey := params.IdempotencyKey.String()
 	log := CorrelationLogger(s.logger, corrID, tenantID)
 
-	if ok, retryAfter := s.limiter.Allow(tenantID); !ok {
+	allowed, retryAfter := s.limiter.Allow(tenantID)
+	s.setRateLimitHeaders(w, tenantID)
+	if !allowed {
+		s.recordRateLimit(r.Context(), tenantID, corrID, LimiterTenant, s.limiter.Limit(), s.limiter.Window(), retryAfter)
 		body := RateLimitError{Code: "RATE_LIMITED", Message: "too many requests", CorrId: corrID, Retryable: true, RetryAfterSeconds: toRetrySeconds(retryAfter)}
 		writeJSON(w, http.StatusTooManyRequests, corrID, body, map[string]string{"Retry-After": formatRetryAfter(retryAfter)})
 		return
 	}
 
-	req, err := decodeRequest(r.Body)
-	if err != nil {
+	if err := ValidateIdempotencyKey(idempotencyKey, s.cfg); err != nil {
 		body := ValidationError{
-			Code:      "BAD_JSON",
-			Message:   "invalid JSON",
+			Code:      "INVALID_IDEMPOTENCY_KEY",
+			Message:   "invalid idempotency key",
 			CorrId:    corrID,
 			Retryable: false,
-			Errors:    []ValidationErrorItem{{Code: "BAD_JSON", Path: "body", Message: err.Error()}},
+			Errors:    []ValidationErrorItem{{Code: "INVALID_IDEMPOTENCY_KEY", Path: "Idempotency-Key", Message: err.Error()}},
 		}
 		writeJSON(w, http.StatusBadRequest, corrID, body, nil)
 		return
 	}
+
+	req, err := decodeRequest(w, r, s.cfg.StrictBodyLength, s.cfg.MaxRequestBytes)
+	if err != nil {
+		s.writeDecodeError(w, corrID, err)
+		return
+	}
 	errs, hint := ValidateRequest(req, s.cfg)
 	if len(errs) > 0 {
-		body := ValidationError{
-			Code:      "VALIDATION_ERROR",
-			Message:   "request validation failed",
-			CorrId:    corrID,
-			Retryable: false,
-			Errors:    errs,
-		}
-		writeJSON(w, http.StatusBadRequest, corrID, body, nil)
+		s.writeRequestErrors(w, corrID, errs)
 		return
 	}
-	if hint != nil {
+	autoSplit := req.AutoSplit != nil && *req.AutoSplit
+	if hint != nil && !autoSplit {
 		body := RequestTooLargeError{
-			Code:      "AUDIT-REQ-413",
-			Message:   "result exceeds threshold; split by hint",
+			Code:      rules.AuditReq413,
+			Message:   rules.Message(rules.AuditReq413),
 			CorrId:    corrID,
 			Retryable: false,
 			SplitHint: *hint,
@@ -83,8 +107,17 @@ func (s Service) EnqueueAuditZip(w http.ResponseWriter, r *http.Request, params
 		return
 	}
 
-	criteriaHash := computeCriteriaHash(tenantID, req)
-	job, err := s.queue.Enqueue(context.Background(), tenantID, idempotencyKey, criteriaHash, req)
+	// Enqueue outlives the request, so it keeps the request's span but not its cancellation.
+	enqueueCtx := withCorrelationID(context.WithoutCancel(r.Context()), corrID)
+	var job AuditZipJob
+	var criteriaHash string
+	if hint != nil {
+		criteriaHash = splitCriteriaHash(tenantID, req, hint.Chunks, s.cfg)
+		job, err = s.queue.EnqueueSplit(enqueueCtx, tenantID, idempotencyKey, criteriaHash, req, hint.Chunks)
+	} else {
+		criteriaHash = computeCriteriaHash(tenantID, req, s.cfg)
+		job, err = s.queue.Enqueue(enqueueCtx, tenantID, idempotencyKey, criteriaHash, req)
+	}
 	if err != nil {
 		switch e := err.(type) {
 		case ConflictErr:
@@ -98,6 +131,7 @@ func (s Service) EnqueueAuditZip(w http.ResponseWriter, r *http.Request, params
 			writeJSON(w, http.StatusConflict, corrID, body, nil)
 			return
 		case RateLimitErr:
+			s.recordRateLimit(r.Context(), tenantID, corrID, LimiterQueue, s.cfg.MaxQueueDepth, 0, e.RetryAfter)
 			body := RateLimitError{
 				Code:              "RATE_LIMITED",
 				Message:           "queue is full",
@@ -108,6 +142,11 @@ func (s Service) EnqueueAuditZip(w http.ResponseWriter, r *http.Request, params
 			writeJSON(w, http.StatusTooManyRequests, corrID, body, map[string]string{"Retry-After": formatRetryAfter(e.RetryAfter)})
 			return
 		default:
+			if errors.Is(err, ErrShuttingDown) {
+				body := InternalError{Code: "SHUTTING_DOWN", Message: "server is shutting down", CorrId: corrID, Retryable: true}
+				writeJSON(w, http.StatusServiceUnavailable, corrID, body, map[string]string{"Retry-After": formatRetryAfter(s.cfg.QueueRetryAfter)})
+				return
+			}
 			s.writeInternalError(w, corrID, err)
 			return
 		}
@@ -116,7 +155,12 @@ func (s Service) EnqueueAuditZip(w http.ResponseWriter, r *http.Request, params
 	_ = s.appendAudit(context.Background(), tenantID, corrID, "audit.zip.create", criteriaHash)
 
 	location := fmt.Sprintf("/audit/jobs/%s", job.JobId)
-	writeJSON(w, http.StatusAccepted, corrID, s.decorateJob(job, corrID), map[string]string{"Location": location})
+	headers := map[string]string{"Location": location}
+	if s.cfg.EmitLinkHeaders {
+		headers["Link"] = s.jobLinks(location)
+		headers["X-Poll-Interval-Seconds"] = fmt.Sprintf("%d", pollIntervalSeconds(req, s.cfg))
+	}
+	writeJSON(w, http.StatusAccepted, corrID, s.decorateJob(job, corrID), headers)
 	log.Info("audit zip job enqueued", "jobId", job.JobId, "criteriaHash", criteriaHash)
 }
 
@@ -161,18 +205,290 @@ func (s Service) GetAuditZipJob(w http.ResponseWriter, r *http.Request, jobID op
 	log.Info("audit zip job fetched", "jobId", job.JobId, "status", job.Status)
 }
 
+// ListAuditZipJobs handles GET /audit/jobs: the caller's jobs, newest first,
+// paged with limit/cursor and optionally filtered by status.
+// EstimateAuditZip matches POST /audit/zip/estimate. It validates the request
+// body like EnqueueAuditZip and returns its ExportEstimate without enqueuing a
+// job, so callers can see the approximate size and split before exporting.
+func (s Service) EstimateAuditZip(w http.ResponseWriter, r *http.Request) {
+	corrID := r.Header.Get("X-Correlation-Id")
+	if r.Header.Get("X-Tenant-Id") == "" {
+		s.writeValidationError(w, corrID, "X-Tenant-Id", "X-Tenant-Id header is required")
+		return
+	}
+
+	req, err := decodeRequest(w, r, s.cfg.StrictBodyLength, s.cfg.MaxRequestBytes)
+	if err != nil {
+		s.writeDecodeError(w, corrID, err)
+		return
+	}
+	if errs, _ := ValidateRequest(req, s.cfg); len(errs) > 0 {
+		s.writeRequestErrors(w, corrID, errs)
+		return
+	}
+	writeJSON(w, http.StatusOK, corrID, EstimateExport(req.From.Time, req.To.Time, s.cfg), nil)
+}
+
+func (s Service) ListAuditZipJobs(w http.ResponseWriter, r *http.Request) {
+	corrID := r.Header.Get("X-Correlation-Id")
+	tenantID := r.Header.Get("X-Tenant-Id")
+	if tenantID == "" {
+		s.writeValidationError(w, corrID, "X-Tenant-Id", "X-Tenant-Id header is required")
+		return
+	}
+
+	query := r.URL.Query()
+	opts := ListJobsOptions{Cursor: query.Get("cursor")}
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > MaxListJobsLimit {
+			s.writeValidationError(w, corrID, "limit", fmt.Sprintf("limit must be between 1 and %d", MaxListJobsLimit))
+			return
+		}
+		opts.Limit = limit
+	}
+	if v := query.Get("status"); v != "" {
+		status := AuditZipJobStatus(v)
+		switch status {
+		case Queued, Running, Succeeded, Failed, Canceled:
+		default:
+			s.writeValidationError(w, corrID, "status", "unknown status "+v)
+			return
+		}
+		opts.Status = &status
+	}
+
+	page, err := s.queue.ListByTenant(tenantID, opts)
+	if err != nil {
+		if errors.Is(err, ErrInvalidCursor) {
+			s.writeValidationError(w, corrID, "cursor", err.Error())
+			return
+		}
+		s.writeInternalError(w, corrID, err)
+		return
+	}
+	for i := range page.Jobs {
+		page.Jobs[i] = s.decorateJob(page.Jobs[i], corrID)
+	}
+	_ = s.appendAudit(context.Background(), tenantID, corrID, "audit.zip.list", "")
+	writeJSON(w, http.StatusOK, corrID, page, nil)
+}
+
+// StreamAuditZipJobEvents handles GET /audit/jobs/{jobId}/events: a Server-Sent
+// Events stream with a "progress" event per status or progress change, ending
+// with an event named after the terminal status whose job carries the signed URL
+// or error. A client disconnect unsubscribes without affecting the job.
+func (s Service) StreamAuditZipJobEvents(w http.ResponseWriter, r *http.Request, jobID string) {
+	corrID := r.Header.Get("X-Correlation-Id")
+	tenantID := r.Header.Get("X-Tenant-Id")
+	if tenantID == "" {
+		s.writeValidationError(w, corrID, "X-Tenant-Id", "X-Tenant-Id header is required")
+		return
+	}
+	notFound := NotFoundError{Code: "NOT_FOUND", Message: "job not found", CorrId: corrID, Retryable: false}
+	if !s.cfg.EnableEventStream {
+		writeJSON(w, http.StatusNotFound, corrID, notFound, nil)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		s.writeInternalError(w, corrID, errors.New("streaming unsupported"))
+		return
+	}
+
+	// Ownership is checked by Subscribe before any event is delivered.
+	events, unsubscribe, err := s.queue.Subscribe(tenantID, jobID)
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, corrID, notFound, nil)
+		return
+	}
+	defer unsubscribe()
+	_ = s.appendAudit(context.Background(), tenantID, corrID, "audit.zip.events", "")
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	if corrID != "" {
+		w.Header().Set("X-Correlation-Id", corrID)
+	}
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case job, ok := <-events:
+			if !ok {
+				return
+			}
+			event := "progress"
+			if isTerminal(job.Status) {
+				event = string(job.Status)
+			}
+			data, err := json.Marshal(s.decorateJob(job, corrID))
+			if err != nil {
+				return
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// ExportAuditLog handles GET /audit/log behind auth.Middleware: the
+// authenticated tenant's audit chain oldest first, with Verified reporting
+// whether re-hashing every entry reproduces it.
+func (s Service) ExportAuditLog(w http.ResponseWriter, r *http.Request) {
+	corrID := r.Header.Get("X-Correlation-Id")
+	tenantID, ok := authenticatedTenant(w, r, corrID)
+	if !ok {
+		return
+	}
+	var entries []AuditLog
+	if s.audit != nil {
+		var err error
+		if entries, err = s.audit.All(r.Context(), tenantID); err != nil {
+			s.writeInternalError(w, corrID, err)
+			return
+		}
+	}
+	export := VerifyChain(tenantID, entries)
+	if !export.Verified {
+		CorrelationLogger(s.logger, corrID, tenantID).Warn("audit chain broken", "brokenAt", *export.BrokenAt, "reason", export.Reason)
+	}
+	writeJSON(w, http.StatusOK, corrID, export, nil)
+}
+
+// authenticatedTenant returns the tenant auth.Middleware resolved for r, or
+// writes a 401 when the route was reached without it.
+func authenticatedTenant(w http.ResponseWriter, r *http.Request, corrID string) (string, bool) {
+	actor, ok := auth.ActorFromContext(r.Context())
+	if !ok {
+		body := ForbiddenError{Code: "AUTH_REQUIRED", Message: "authentication required", CorrId: corrID, Retryable: false}
+		writeJSON(w, http.StatusUnauthorized, corrID, body, nil)
+		return "", false
+	}
+	return actor.TenantID, true
+}
+
+func (s Service) writeValidationError(w http.ResponseWriter, corrID, path, message string) {
+	body := ValidationError{
+		Code:      "VALIDATION_ERROR",
+		Message:   "request validation failed",
+		CorrId:    corrID,
+		Retryable: false,
+		Errors:    []ValidationErrorItem{{Code: "VALIDATION_ERROR", Path: path, Message: message}},
+	}
+	writeJSON(w, http.StatusBadRequest, corrID, body, nil)
+}
+
+// writeRequestErrors reports ValidateRequest's errors as a 400.
+func (s Service) writeRequestErrors(w http.ResponseWriter, corrID string, errs []ValidationErrorItem) {
+	body := ValidationError{
+		Code:      "VALIDATION_ERROR",
+		Message:   "request validation failed",
+		CorrId:    corrID,
+		Retryable: false,
+		Errors:    errs,
+	}
+	writeJSON(w, http.StatusBadRequest, corrID, body, nil)
+}
+
+// writeDecodeError reports a decodeRequest failure: 413 for an oversized
+// body, 400 for a Content-Length mismatch or malformed JSON.
+func (s Service) writeDecodeError(w http.ResponseWriter, corrID string, err error) {
+	switch {
+	case errors.Is(err, ErrBodyTooLarge):
+		detail := fmt.Sprintf("request body exceeds %d bytes", s.cfg.MaxRequestBytes)
+		body := ValidationError{
+			Code:      "BODY_TOO_LARGE",
+			Message:   "request body too large",
+			CorrId:    corrID,
+			Retryable: false,
+			Errors:    []ValidationErrorItem{{Code: "BODY_TOO_LARGE", Path: "body", Message: detail}},
+		}
+		writeJSON(w, http.StatusRequestEntityTooLarge, corrID, body, nil)
+	case errors.Is(err, ErrBodyLengthMismatch):
+		body := ValidationError{
+			Code:      "BODY_LENGTH_MISMATCH",
+			Message:   "request body does not match Content-Length",
+			CorrId:    corrID,
+			Retryable: false,
+			Errors:    []ValidationErrorItem{{Code: "BODY_LENGTH_MISMATCH", Path: "Content-Length", Message: err.Error()}},
+		}
+		writeJSON(w, http.StatusBadRequest, corrID, body, nil)
+	default:
+		body := ValidationError{
+			Code:      "BAD_JSON",
+			Message:   "invalid JSON",
+			CorrId:    corrID,
+			Retryable: false,
+			Errors:    []ValidationErrorItem{{Code: "BAD_JSON", Path: "body", Message: err.Error()}},
+		}
+		writeJSON(w, http.StatusBadRequest, corrID, body, nil)
+	}
+}
+
 func (s Service) writeInternalError(w http.ResponseWriter, corrID string, err error) {
 	body := InternalError{Code: "INTERNAL_ERROR", Message: err.Error(), CorrId: corrID, Retryable: true}
 	writeJSON(w, http.StatusInternalServerError, corrID, body, nil)
 }
 
-func decodeRequest(body io.ReadCloser) (AuditZipRequest, error) {
-	defer body.Close()
+// ErrBodyLengthMismatch is returned by decodeRequest when a non-chunked body
+// holds more or fewer bytes than its Content-Length declares.
+var ErrBodyLengthMismatch = errors.New("request body length does not match Content-Length")
+
+// ErrBodyTooLarge is returned by decodeRequest when the body exceeds its limit.
+var ErrBodyTooLarge = errors.New("request body too large")
+
+// decodeRequest decodes the JSON body, reading at most limit bytes (limit <= 0
+// leaves it unbounded); a declared Content-Length over the limit is rejected
+// before any of it is read. With checkLength set, it drains the rest of a body
+// with a declared length, reading one byte past it, and reports any difference
+// as ErrBodyLengthMismatch ahead of JSON errors.
+func decodeRequest(w http.ResponseWriter, r *http.Request, checkLength bool, limit int64) (AuditZipRequest, error) {
+	defer r.Body.Close()
 	var req AuditZipRequest
-	if err := json.NewDecoder(body).Decode(&req); err != nil {
-		return req, err
+	if limit > 0 {
+		if r.ContentLength > limit {
+			return req, ErrBodyTooLarge
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+	}
+	var maxErr *http.MaxBytesError
+	body := &countingReader{r: r.Body}
+	err := json.NewDecoder(body).Decode(&req)
+	if errors.As(err, &maxErr) {
+		return req, ErrBodyTooLarge
 	}
-	return req, nil
+	if checkLength && r.ContentLength >= 0 && len(r.TransferEncoding) == 0 {
+		remaining := r.ContentLength - body.n
+		if remaining < 0 {
+			remaining = 0
+		}
+		if _, err := io.Copy(io.Discard, io.LimitReader(body, remaining+1)); errors.As(err, &maxErr) {
+			return req, ErrBodyTooLarge
+		}
+		if body.n != r.ContentLength {
+			return req, ErrBodyLengthMismatch
+		}
+	}
+	return req, err
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
 }
 
 func writeJSON(w http.ResponseWriter, status int, corrID string, v any, extra map[string]string) {
@@ -187,7 +503,9 @@ func writeJSON(w http.ResponseWriter, status int, corrID string, v any, extra ma
 	_ = json.NewEncoder(w).Encode(v)
 }
 
-func computeCriteriaHash(tenantID string, req AuditZipRequest) string {
+// computeCriteriaHash fingerprints the export criteria, using the normalized
+// partner so equivalent spellings share a hash when normalization is enabled.
+func computeCriteriaHash(tenantID string, req AuditZipRequest, cfg Config) string {
 	payload := struct {
 		Tenant    string   `json:"tenant"`
 		From      string   `json:"from"`
@@ -200,7 +518,7 @@ func computeCriteriaHash(tenantID string, req AuditZipRequest) string {
 		Tenant:    tenantID,
 		From:      req.From.Time.Format("2006-01-02"),
 		To:        req.To.Time.Format("2006-01-02"),
-		Partner:   req.Partner,
+		Partner:   normalizedPartner(req.Partner, cfg),
 		MinAmount: req.MinAmount,
 		MaxAmount: req.MaxAmount,
 		Format:    string(req.Format),
@@ -218,6 +536,8 @@ func conflictMessage(e ConflictErr) string {
 		return "duplicate request exists for the same criteria"
 	case NotCancelable:
 		return "job is not cancelable in current state"
+	case ArtifactNotReady:
+		return "job has no archive to download"
 	default:
 		return "duplicate request"
 	}
@@ -237,6 +557,35 @@ func (s Service) decorateJob(job AuditZipJob, corrID string) AuditZipJob {
 	return job
 }
 
+// jobLinks builds the RFC 8288 Link header for a job: its status resource and,
+// when the event stream is enabled, its events endpoint.
+func (s Service) jobLinks(location string) string {
+	links := fmt.Sprintf(`<%s>; rel="self"`, location)
+	if s.cfg.EnableEventStream {
+		links += fmt.Sprintf(`, <%s/events>; rel="events"`, location)
+	}
+	return links
+}
+
+// pollIntervalSeconds suggests how often to poll a job, derived from its expected
+// duration (estimated archive size over throughput): roughly four polls per job,
+// clamped to [1, 30] seconds.
+func pollIntervalSeconds(req AuditZipRequest, cfg Config) int {
+	if cfg.JobThroughputMBps <= 0 {
+		return 1
+	}
+	rangeDays := int(req.To.Time.Sub(req.From.Time).Hours()/24) + 1
+	expected := cfg.EstimatedMBPerDay * float64(rangeDays) / cfg.JobThroughputMBps
+	interval := int(math.Ceil(expected / 4))
+	if interval < 1 {
+		return 1
+	}
+	if interval > 30 {
+		return 30
+	}
+	return interval
+}
+
 func formatRetryAfter(d time.Duration) string {
 	seconds := toRetrySeconds(d)
 	if seconds < 1 {
@@ -252,6 +601,46 @@ func toRetrySeconds(d time.Duration) int {
 	return int(d.Seconds())
 }
 
+// setRateLimitHeaders reports the tenant's remaining enqueue allowance as
+// X-RateLimit-* headers, so clients can slow down before hitting 429. Nothing
+// is set when tenant rate limiting is disabled.
+func (s Service) setRateLimitHeaders(w http.ResponseWriter, tenantID string) {
+	limit := s.limiter.Limit()
+	if limit == 0 {
+		return
+	}
+	remaining, resetAt := s.limiter.Remaining(tenantID)
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(limit))
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+	w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(resetAt.Add(time.Second-1).Unix(), 10))
+}
+
+// recordRateLimit chains an audit.zip.rate_limited entry for a rejected enqueue,
+// so throttled requests leave no gap in the audit log, and reports it as a
+// structured rate-limit event when cfg.AuditRateLimits is set. limit is the
+// effective allowance per window; the queue limiter has no window.
+func (s Service) recordRateLimit(ctx context.Context, tenantID, corrID, limiter string, limit int, window, retryAfter time.Duration) {
+	_ = s.appendAudit(ctx, tenantID, corrID, "audit.zip.rate_limited", "")
+	if !s.cfg.AuditRateLimits {
+		return
+	}
+	ev := ratelimit.Event{
+		TenantID:          tenantID,
+		Limiter:           limiter,
+		Key:               tenantID,
+		CorrID:            corrID,
+		Limit:             limit,
+		WindowSeconds:     int(window.Seconds()),
+		RetryAfterSeconds: toRetrySeconds(retryAfter),
+		Timestamp:         time.Now().UTC(),
+	}
+	if s.rateEvents != nil {
+		s.rateEvents.RecordRateLimit(ctx, ev)
+	}
+	CorrelationLogger(s.logger, corrID, tenantID).Warn("audit zip request rate limited",
+		"limiter", ev.Limiter, "limit", ev.Limit, "windowSeconds", ev.WindowSeconds, "retryAfterSeconds", ev.RetryAfterSeconds)
+}
+
 func (s Service) appendAudit(ctx context.Context, tenantID, corrID, action, criteriaHash string) error {
 	if s.audit == nil {
 		return nil
@@ -264,12 +653,14 @@ func (s Service) appendAudit(ctx context.Context, tenantID, corrID, action, crit
 		Action:       action,
 		CriteriaHash: criteriaHash,
 		Ts:           time.Now().UTC(),
+		HashAlg:      s.cfg.AuditHashAlgorithm,
 	}
 	_, err := HashChain(ctx, s.audit, tenantID, entry)
 	return err
 }
 
 type MemoryAuditRecorder struct {
+	mu       sync.RWMutex
 	byTenant map[string][]AuditLog
 }
 
@@ -278,14 +669,24 @@ func NewMemoryAuditRecorder() *MemoryAuditRecorder {
 }
 
 func (m *MemoryAuditRecorder) Append(_ context.Context, entry AuditLog) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.byTenant[entry.TenantID] = append(m.byTenant[entry.TenantID], entry)
 	return nil
 }
 
 func (m *MemoryAuditRecorder) Last(_ context.Context, tenantID string) (AuditLog, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 	list := m.byTenant[tenantID]
 	if len(list) == 0 {
 		return AuditLog{}, fmt.Errorf("empty")
 	}
 	return list[len(list)-1], nil
 }
+
+func (m *MemoryAuditRecorder) All(_ context.Context, tenantID string) ([]AuditLog, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]AuditLog(nil), m.byTenant[tenantID]...), nil
+}
@@ -0,0 +1,712 @@
+package auditzip
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	openapi_types "github.com/oapi-codegen/runtime/types"
+
+	"github.com/yourorg/yourapp/apps/api/internal/ratelimit"
+)
+
+// newTestService returns a service whose jobs complete immediately.
+func newTestService(t *testing.T, cfg Config) Service {
+	t.Helper()
+	q := NewJobQueue(NewInMemoryStorage(), cfg)
+	q.process = func(ctx context.Context, state *jobState) error {
+		q.completeJob(state.job.JobId, "https://storage.local/ok", time.Now().UTC(), 1)
+		return nil
+	}
+	return NewService(cfg, q, NewMemoryAuditRecorder(), nil)
+}
+
+// enqueue posts req to EnqueueAuditZip for tenant-a.
+func enqueue(t *testing.T, svc Service, req AuditZipRequest) *httptest.ResponseRecorder {
+	t.Helper()
+	body, err := json.Marshal(req)
+	if err != nil {
+		t.Fatalf("marshal request: %v", err)
+	}
+	r := httptest.NewRequest(http.MethodPost, "/audit/zip", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	svc.EnqueueAuditZip(w, r, EnqueueAuditZipParams{
+		XCorrelationId: uuid.New(),
+		XTenantId:      "tenant-a",
+		IdempotencyKey: uuid.New(),
+	})
+	return w
+}
+
+func TestEnqueueEmitsLinkHeaders(t *testing.T) {
+	cfg := testQueueConfig()
+	cfg.EmitLinkHeaders = true
+	cfg.EnableEventStream = true
+	cfg.EstimatedMBPerDay = 20
+	cfg.JobThroughputMBps = 1
+	svc := newTestService(t, cfg)
+
+	req := testRequest(1)
+	req.To.Time = req.From.Time.AddDate(0, 0, 9) // 10 days -> 200MB -> 200s expected
+	w := enqueue(t, svc, req)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", w.Code, w.Body.String())
+	}
+
+	location := w.Header().Get("Location")
+	if !strings.HasPrefix(location, "/audit/jobs/") {
+		t.Fatalf("unexpected Location %q", location)
+	}
+	wantLink := `<` + location + `>; rel="self", <` + location + `/events>; rel="events"`
+	if got := w.Header().Get("Link"); got != wantLink {
+		t.Fatalf("Link = %q, want %q", got, wantLink)
+	}
+	if got := w.Header().Get("X-Poll-Interval-Seconds"); got != "30" {
+		t.Fatalf("X-Poll-Interval-Seconds = %q, want 30 (clamped)", got)
+	}
+}
+
+func TestEnqueueLinkHeadersRespectConfig(t *testing.T) {
+	cfg := testQueueConfig()
+	cfg.EmitLinkHeaders = true
+	cfg.EnableEventStream = false
+	svc := newTestService(t, cfg)
+
+	w := enqueue(t, svc, testRequest(1))
+	location := w.Header().Get("Location")
+	if got := w.Header().Get("Link"); got != `<`+location+`>; rel="self"` {
+		t.Fatalf("expected only rel=self without SSE, got %q", got)
+	}
+	if got := w.Header().Get("X-Poll-Interval-Seconds"); got != "1" {
+		t.Fatalf("X-Poll-Interval-Seconds = %q, want 1", got)
+	}
+
+	cfg.EmitLinkHeaders = false
+	w = enqueue(t, newTestService(t, cfg), testRequest(1))
+	if w.Header().Get("Link") != "" || w.Header().Get("X-Poll-Interval-Seconds") != "" {
+		t.Fatalf("expected no hints when disabled, got Link=%q poll=%q", w.Header().Get("Link"), w.Header().Get("X-Poll-Interval-Seconds"))
+	}
+}
+
+// listJobs calls ListAuditZipJobs for tenantID with the given raw query.
+func listJobs(t *testing.T, svc Service, tenantID, query string) (int, JobPage) {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodGet, "/audit/jobs?"+query, nil)
+	r.Header.Set("X-Correlation-Id", uuid.NewString())
+	r.Header.Set("X-Tenant-Id", tenantID)
+	w := httptest.NewRecorder()
+	svc.ListAuditZipJobs(w, r)
+	var page JobPage
+	if w.Code == http.StatusOK {
+		if err := json.NewDecoder(w.Body).Decode(&page); err != nil {
+			t.Fatalf("decode page: %v", err)
+		}
+	}
+	return w.Code, page
+}
+
+// newListTestService enqueues five jobs for tenant-a (days 1..5, day 2 fails)
+// and one for tenant-b, waiting until all are terminal.
+func newListTestService(t *testing.T) Service {
+	t.Helper()
+	cfg := testQueueConfig()
+	q := NewJobQueue(NewInMemoryStorage(), cfg)
+	q.process = func(ctx context.Context, state *jobState) error {
+		if state.request.From.Time.Day() == 2 {
+			return errors.New("boom")
+		}
+		q.completeJob(state.job.JobId, "https://storage.local/ok", time.Now().UTC(), 1)
+		return nil
+	}
+	svc := NewService(cfg, q, NewMemoryAuditRecorder(), nil)
+	enqueueFor := func(tenantID string, day int) {
+		job, err := q.Enqueue(context.Background(), tenantID, uuid.NewString(), fmt.Sprintf("hash-%d", day), testRequest(day))
+		if err != nil {
+			t.Fatalf("enqueue: %v", err)
+		}
+		want := Succeeded
+		if day == 2 {
+			want = Failed
+		}
+		waitForStatus(t, q, job.JobId.String(), want)
+	}
+	for day := 1; day <= 5; day++ {
+		enqueueFor("tenant-a", day)
+	}
+	enqueueFor("tenant-b", 6)
+	return svc
+}
+
+func TestListAuditZipJobsPaginates(t *testing.T) {
+	svc := newListTestService(t)
+
+	var seen []AuditZipJob
+	cursor := ""
+	for pages := 0; ; pages++ {
+		if pages > 3 {
+			t.Fatal("pagination did not terminate")
+		}
+		code, page := listJobs(t, svc, "tenant-a", "limit=2&cursor="+cursor)
+		if code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", code)
+		}
+		if len(page.Jobs) > 2 {
+			t.Fatalf("page exceeds limit: %d jobs", len(page.Jobs))
+		}
+		seen = append(seen, page.Jobs...)
+		if page.NextCursor == "" {
+			break
+		}
+		cursor = page.NextCursor
+	}
+	if len(seen) != 5 {
+		t.Fatalf("expected 5 jobs across pages, got %d", len(seen))
+	}
+	for i, job := range seen {
+		if job.CriteriaHash == nil || *job.CriteriaHash == "" {
+			t.Fatalf("job %d missing criteriaHash", i)
+		}
+		if i > 0 && seen[i-1].RequestedAt.Before(job.RequestedAt) {
+			t.Fatalf("jobs not sorted newest first at %d", i)
+		}
+	}
+	if *seen[0].CriteriaHash != "hash-5" || *seen[4].CriteriaHash != "hash-1" {
+		t.Fatalf("unexpected order: first %s last %s", *seen[0].CriteriaHash, *seen[4].CriteriaHash)
+	}
+}
+
+func TestListAuditZipJobsFiltersStatus(t *testing.T) {
+	svc := newListTestService(t)
+
+	code, page := listJobs(t, svc, "tenant-a", "status=failed")
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if len(page.Jobs) != 1 || page.Jobs[0].Status != Failed || *page.Jobs[0].CriteriaHash != "hash-2" {
+		t.Fatalf("expected only the failed job, got %+v", page.Jobs)
+	}
+	if code, _ := listJobs(t, svc, "tenant-a", "status=bogus"); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for unknown status, got %d", code)
+	}
+	if code, _ := listJobs(t, svc, "tenant-a", "cursor=bm90LWEtY3Vyc29y"); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for bad cursor, got %d", code)
+	}
+}
+
+func TestListAuditZipJobsTenantIsolation(t *testing.T) {
+	svc := newListTestService(t)
+
+	_, page := listJobs(t, svc, "tenant-b", "")
+	if len(page.Jobs) != 1 || *page.Jobs[0].CriteriaHash != "hash-6" {
+		t.Fatalf("expected only tenant-b's job, got %+v", page.Jobs)
+	}
+	_, page = listJobs(t, svc, "tenant-c", "")
+	if len(page.Jobs) != 0 {
+		t.Fatalf("expected no jobs for tenant-c, got %d", len(page.Jobs))
+	}
+}
+
+// sseEvent is one parsed Server-Sent Event from the job events stream.
+type sseEvent struct {
+	name string
+	job  AuditZipJob
+}
+
+func readEvents(t *testing.T, resp *http.Response) []sseEvent {
+	t.Helper()
+	var events []sseEvent
+	var name string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			var job AuditZipJob
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &job); err != nil {
+				t.Fatalf("decode event data: %v", err)
+			}
+			events = append(events, sseEvent{name: name, job: job})
+		}
+	}
+	return events
+}
+
+func TestStreamAuditZipJobEvents(t *testing.T) {
+	cfg := testQueueConfig()
+	cfg.EnableEventStream = true
+	q := NewJobQueue(NewInMemoryStorage(), cfg)
+	start := make(chan struct{})
+	q.process = func(ctx context.Context, state *jobState) error {
+		<-start
+		for _, p := range []int{10, 50, 90} {
+			if err := q.bumpProgress(state.job.JobId, p); err != nil {
+				return err
+			}
+		}
+		q.completeJob(state.job.JobId, "https://storage.local/ok", time.Now().UTC(), 1)
+		return nil
+	}
+	svc := NewService(cfg, q, NewMemoryAuditRecorder(), nil)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		svc.StreamAuditZipJobEvents(w, r, strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/audit/jobs/"), "/events"))
+	}))
+	defer srv.Close()
+
+	job, err := q.Enqueue(context.Background(), "tenant-a", uuid.NewString(), "hash-1", testRequest(1))
+	if err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	jobID := job.JobId.String()
+	waitForStatus(t, q, jobID, Running)
+
+	get := func(tenantID string) *http.Response {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"/audit/jobs/"+jobID+"/events", nil)
+		req.Header.Set("X-Tenant-Id", tenantID)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("get events: %v", err)
+		}
+		return resp
+	}
+
+	other := get("tenant-b")
+	other.Body.Close()
+	if other.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 for another tenant's job, got %d", other.StatusCode)
+	}
+
+	resp := get("tenant-a")
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("expected event stream, got %d %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	close(start)
+
+	events := readEvents(t, resp)
+	var got []string
+	for _, e := range events {
+		got = append(got, fmt.Sprintf("%s:%d", e.name, e.job.Progress))
+	}
+	want := []string{"progress:5", "progress:10", "progress:50", "progress:90", "succeeded:100"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("events = %v, want %v", got, want)
+	}
+	last := events[len(events)-1].job
+	if last.Result == nil || last.Result.SignedUrl != "https://storage.local/ok" {
+		t.Fatalf("expected terminal event to carry the signed URL, got %+v", last.Result)
+	}
+}
+
+func TestStreamAuditZipJobEventsDisconnectUnsubscribes(t *testing.T) {
+	cfg := testQueueConfig()
+	cfg.EnableEventStream = true
+	q := NewJobQueue(NewInMemoryStorage(), cfg)
+	release := make(chan struct{})
+	q.process = func(ctx context.Context, state *jobState) error {
+		<-release
+		q.completeJob(state.job.JobId, "https://storage.local/ok", time.Now().UTC(), 1)
+		return nil
+	}
+	svc := NewService(cfg, q, NewMemoryAuditRecorder(), nil)
+	job, err := q.Enqueue(context.Background(), "tenant-a", uuid.NewString(), "hash-1", testRequest(1))
+	if err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	jobID := job.JobId.String()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodGet, "/audit/jobs/"+jobID+"/events", nil).WithContext(ctx)
+	req.Header.Set("X-Tenant-Id", "tenant-a")
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		svc.StreamAuditZipJobEvents(httptest.NewRecorder(), req, jobID)
+	}()
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("handler did not return after client disconnect")
+	}
+
+	q.mu.RLock()
+	subs := len(q.subscribers[jobID])
+	q.mu.RUnlock()
+	if subs != 0 {
+		t.Fatalf("expected no subscribers after disconnect, got %d", subs)
+	}
+	close(release)
+	waitForStatus(t, q, jobID, Succeeded)
+}
+
+func TestEnqueueRejectsContentLengthMismatch(t *testing.T) {
+	body, err := json.Marshal(testRequest(1))
+	if err != nil {
+		t.Fatalf("marshal request: %v", err)
+	}
+	post := func(strict bool) *httptest.ResponseRecorder {
+		cfg := testQueueConfig()
+		cfg.StrictBodyLength = strict
+		svc := newTestService(t, cfg)
+		r := httptest.NewRequest(http.MethodPost, "/audit/zip", bytes.NewReader(body))
+		r.ContentLength = int64(len(body)) + 16 // declared length exceeds the body
+		w := httptest.NewRecorder()
+		svc.EnqueueAuditZip(w, r, EnqueueAuditZipParams{
+			XCorrelationId: uuid.New(),
+			XTenantId:      "tenant-a",
+			IdempotencyKey: uuid.New(),
+		})
+		return w
+	}
+
+	w := post(true)
+	var resp ValidationError
+	_ = json.NewDecoder(w.Body).Decode(&resp)
+	if w.Code != http.StatusBadRequest || resp.Code != "BODY_LENGTH_MISMATCH" {
+		t.Fatalf("expected 400 BODY_LENGTH_MISMATCH, got %d %+v", w.Code, resp)
+	}
+	if w := post(false); w.Code != http.StatusAccepted {
+		t.Fatalf("expected mismatch to be ignored when disabled, got %d", w.Code)
+	}
+}
+
+func TestEnqueueRecordsRateLimitEvent(t *testing.T) {
+	cfg := testQueueConfig()
+	cfg.RateLimitPerMinute = 1
+	cfg.AuditRateLimits = true
+	events := ratelimit.NewMemoryRecorder()
+	svc := newTestService(t, cfg).WithRateLimitRecorder(events)
+
+	if w := enqueue(t, svc, testRequest(1)); w.Code != http.StatusAccepted {
+		t.Fatalf("expected first request accepted, got %d", w.Code)
+	}
+	if w := enqueue(t, svc, testRequest(2)); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", w.Code)
+	}
+
+	got := events.Events("tenant-a")
+	if len(got) != 1 {
+		t.Fatalf("expected 1 rate-limit event, got %d", len(got))
+	}
+	if ev := got[0]; ev.Limiter != LimiterTenant || ev.Limit != 1 || ev.WindowSeconds != 60 || ev.RetryAfterSeconds < 1 {
+		t.Fatalf("unexpected event %+v", ev)
+	}
+	audit := svc.audit.(*MemoryAuditRecorder)
+	last, _ := audit.Last(context.Background(), "tenant-a")
+	if last.Action != "audit.zip.rate_limited" {
+		t.Fatalf("expected audit.zip.rate_limited entry, got %s", last.Action)
+	}
+}
+
+func TestEnqueueEmitsRateLimitHeaders(t *testing.T) {
+	svc := newTestService(t, testQueueConfig())
+	svc.limiter = NewRateLimiter(2, 200*time.Millisecond)
+	clock := &testClock{t: time.Unix(1700000000, 900*int64(time.Millisecond))}
+	svc.limiter.now = clock.Now
+
+	headers := func(w *httptest.ResponseRecorder) (limit, remaining string, reset int64) {
+		reset, _ = strconv.ParseInt(w.Header().Get("X-RateLimit-Reset"), 10, 64)
+		return w.Header().Get("X-RateLimit-Limit"), w.Header().Get("X-RateLimit-Remaining"), reset
+	}
+	for i, want := range []string{"1", "0"} {
+		w := enqueue(t, svc, testRequest(i+1))
+		if w.Code != http.StatusAccepted {
+			t.Fatalf("request %d: expected 202, got %d", i, w.Code)
+		}
+		limit, remaining, reset := headers(w)
+		if limit != "2" || remaining != want {
+			t.Fatalf("request %d: expected limit 2 remaining %s, got %s/%s", i, want, limit, remaining)
+		}
+		// The window ends at :01.1, which the header rounds up to a whole second.
+		if reset != 1700000002 {
+			t.Fatalf("request %d: expected reset 1700000002, got %d", i, reset)
+		}
+	}
+	w := enqueue(t, svc, testRequest(3))
+	if _, remaining, _ := headers(w); w.Code != http.StatusTooManyRequests || remaining != "0" || w.Header().Get("Retry-After") == "" {
+		t.Fatalf("expected 429 with remaining 0 and Retry-After, got %d remaining %q", w.Code, remaining)
+	}
+
+	clock.Advance(250 * time.Millisecond)
+	w = enqueue(t, svc, testRequest(4))
+	if _, remaining, _ := headers(w); w.Code != http.StatusAccepted || remaining != "1" {
+		t.Fatalf("expected the allowance to reset after the window, got %d remaining %q", w.Code, remaining)
+	}
+
+	svc.limiter = NewRateLimiter(0, time.Minute)
+	if w := enqueue(t, svc, testRequest(5)); w.Header().Get("X-RateLimit-Limit") != "" {
+		t.Fatal("expected no rate-limit headers when limiting is disabled")
+	}
+}
+
+func TestEnqueueRateLimitedIsChainedInAuditLog(t *testing.T) {
+	cfg := testQueueConfig()
+	cfg.RateLimitPerMinute = 1
+	cfg.AuditRateLimits = false
+	svc := newTestService(t, cfg)
+
+	if w := enqueue(t, svc, testRequest(1)); w.Code != http.StatusAccepted {
+		t.Fatalf("expected first request accepted, got %d", w.Code)
+	}
+	w := enqueue(t, svc, testRequest(2))
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", w.Code)
+	}
+
+	entries := svc.audit.(*MemoryAuditRecorder).byTenant["tenant-a"]
+	if len(entries) != 2 {
+		t.Fatalf("expected create and rate_limited entries, got %d", len(entries))
+	}
+	created, limited := entries[0], entries[1]
+	if created.Action != "audit.zip.create" || limited.Action != "audit.zip.rate_limited" {
+		t.Fatalf("unexpected actions %s, %s", created.Action, limited.Action)
+	}
+	if limited.TenantID != "tenant-a" || limited.CorrID != w.Header().Get("X-Correlation-Id") {
+		t.Fatalf("rate_limited entry has tenant %q corrId %q", limited.TenantID, limited.CorrID)
+	}
+	if limited.PrevHash != created.Hash || limited.Hash != hashAudit(limited) {
+		t.Fatalf("rate_limited entry is not chained after the create entry: %+v", limited)
+	}
+}
+
+// syncBuffer collects log output written from job goroutines.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestJobLogsCarryEnqueueCorrelationID(t *testing.T) {
+	cfg := testQueueConfig()
+	cfg.MaxRetries = 2
+	var logs syncBuffer
+	q := NewJobQueue(NewInMemoryStorage(), cfg)
+	q.logger = slog.New(slog.NewJSONHandler(&logs, nil))
+	var workerCorrID atomic.Value
+	q.process = func(ctx context.Context, state *jobState) error {
+		workerCorrID.Store(correlationID(ctx))
+		if state.job.RetryCount == 0 {
+			return errors.New("transient storage error")
+		}
+		q.completeJob(state.job.JobId, "https://storage.local/ok", time.Now().UTC(), 1)
+		q.jobLogger(ctx, state).Info("archive written")
+		return nil
+	}
+	svc := NewService(cfg, q, NewMemoryAuditRecorder(), nil)
+
+	corrID := uuid.New()
+	body, _ := json.Marshal(testRequest(1))
+	w := httptest.NewRecorder()
+	svc.EnqueueAuditZip(w, httptest.NewRequest(http.MethodPost, "/audit/zip", bytes.NewReader(body)), EnqueueAuditZipParams{
+		XCorrelationId: corrID,
+		XTenantId:      "tenant-a",
+		IdempotencyKey: uuid.New(),
+	})
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", w.Code, w.Body.String())
+	}
+	var job AuditZipJob
+	_ = json.NewDecoder(w.Body).Decode(&job)
+	waitForStatus(t, q, job.JobId.String(), Succeeded)
+
+	if got := workerCorrID.Load(); got != corrID.String() {
+		t.Fatalf("expected the worker context to carry %s, got %v", corrID, got)
+	}
+	messages := map[string]bool{}
+	for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+		var entry map[string]any
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("decode log line %q: %v", line, err)
+		}
+		if entry["jobId"] != job.JobId.String() {
+			continue
+		}
+		messages[entry["msg"].(string)] = true
+		if entry["corrId"] != corrID.String() || entry["tenantId"] != "tenant-a" {
+			t.Fatalf("expected worker log %q under corrId %s, got %v", entry["msg"], corrID, entry)
+		}
+	}
+	for _, msg := range []string{"audit zip job started", "audit zip job attempt failed", "archive written"} {
+		if !messages[msg] {
+			t.Fatalf("expected worker log %q, got %v", msg, messages)
+		}
+	}
+	record, err := q.store.LoadJob(context.Background(), job.JobId.String())
+	if err != nil || record.CorrID != corrID.String() {
+		t.Fatalf("expected the correlation ID persisted with the job, got %q, %v", record.CorrID, err)
+	}
+}
+
+func TestJobWithCorrelationIDStillCancels(t *testing.T) {
+	q := NewJobQueue(NewInMemoryStorage(), testQueueConfig())
+	q.process = func(ctx context.Context, state *jobState) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	job, err := q.Enqueue(withCorrelationID(context.Background(), "corr-cancel"), "tenant-a", uuid.NewString(), "hash-1", testRequest(1))
+	if err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	waitForStatus(t, q, job.JobId.String(), Running)
+	if _, err := q.Cancel("tenant-a", job.JobId.String()); err != nil {
+		t.Fatalf("cancel: %v", err)
+	}
+	waitForStatus(t, q, job.JobId.String(), Canceled)
+}
+
+// meteredBody is a request body that counts how many bytes the handler reads.
+type meteredBody struct {
+	r    io.Reader
+	read int64
+}
+
+func (b *meteredBody) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	b.read += int64(n)
+	return n, err
+}
+
+func TestEnqueueBodyTooLarge(t *testing.T) {
+	cfg := testQueueConfig()
+	cfg.MaxRequestBytes = 512
+	svc := newTestService(t, cfg)
+
+	for _, chunked := range []bool{false, true} {
+		prefix := `{"partner":"`
+		size := 513
+		if chunked {
+			size = 1 << 20
+		}
+		body := &meteredBody{r: strings.NewReader(prefix + strings.Repeat("a", size-len(prefix)))}
+		r := httptest.NewRequest(http.MethodPost, "/audit/zip", io.NopCloser(body))
+		r.ContentLength = int64(size)
+		if chunked {
+			r.ContentLength = -1
+		}
+		w := httptest.NewRecorder()
+		svc.EnqueueAuditZip(w, r, EnqueueAuditZipParams{XCorrelationId: uuid.New(), XTenantId: "tenant-a", IdempotencyKey: uuid.New()})
+		if w.Code != http.StatusRequestEntityTooLarge {
+			t.Fatalf("chunked=%v: expected 413, got %d: %s", chunked, w.Code, w.Body.String())
+		}
+		var resp ValidationError
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil || resp.Code != "BODY_TOO_LARGE" {
+			t.Fatalf("chunked=%v: expected BODY_TOO_LARGE, got %+v (%v)", chunked, resp, err)
+		}
+		if want := map[bool]int64{false: 0, true: 513}[chunked]; body.read > want {
+			t.Fatalf("chunked=%v: read %d bytes of the body, want at most %d", chunked, body.read, want)
+		}
+	}
+
+	if w := enqueue(t, svc, testRequest(1)); w.Code != http.StatusAccepted {
+		t.Fatalf("expected a small request to be accepted, got %d", w.Code)
+	}
+}
+
+// estimate calls EstimateAuditZip for tenant-a with req as the body.
+func estimate(t *testing.T, svc Service, req AuditZipRequest) ExportEstimate {
+	t.Helper()
+	body, _ := json.Marshal(req)
+	r := httptest.NewRequest(http.MethodPost, "/audit/zip/estimate", bytes.NewReader(body))
+	r.Header.Set("X-Correlation-Id", uuid.NewString())
+	r.Header.Set("X-Tenant-Id", "tenant-a")
+	w := httptest.NewRecorder()
+	svc.EstimateAuditZip(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("estimate: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var est ExportEstimate
+	if err := json.NewDecoder(w.Body).Decode(&est); err != nil {
+		t.Fatalf("decode estimate: %v", err)
+	}
+	return est
+}
+
+func TestEstimateAuditZipSmallRange(t *testing.T) {
+	cfg := testQueueConfig()
+	cfg.EstimatedMBPerDay = 5
+	cfg.MaxRangeDays = 92
+	svc := newTestService(t, cfg)
+
+	req := AuditZipRequest{
+		From:   openapi_types.Date{Time: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+		To:     openapi_types.Date{Time: time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC)},
+		Format: Zip,
+	}
+	est := estimate(t, svc, req)
+	if est.RangeDays != 10 || est.ApproxSizeMB != 50 || est.WillSplit || est.Chunks != 1 {
+		t.Fatalf("unexpected estimate %+v", est)
+	}
+	if _, hint := ValidateRequest(req, cfg); hint != nil {
+		t.Fatalf("expected no split hint, got %+v", hint)
+	}
+	if page, _ := svc.queue.ListByTenant("tenant-a", ListJobsOptions{}); len(page.Jobs) != 0 {
+		t.Fatalf("expected no job enqueued, got %d", len(page.Jobs))
+	}
+}
+
+func TestEstimateAuditZipLargeRangeMatchesSplitHint(t *testing.T) {
+	cfg := testQueueConfig()
+	cfg.EstimatedMBPerDay = 5
+	cfg.MaxRangeDays = 30
+	svc := newTestService(t, cfg)
+
+	req := AuditZipRequest{
+		From:   openapi_types.Date{Time: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+		To:     openapi_types.Date{Time: time.Date(2025, 3, 31, 0, 0, 0, 0, time.UTC)},
+		Format: Zip,
+	}
+	est := estimate(t, svc, req)
+	if est.RangeDays != 90 || est.ApproxSizeMB != 450 || !est.WillSplit || est.Chunks != 3 {
+		t.Fatalf("unexpected estimate %+v", est)
+	}
+	_, hint := ValidateRequest(req, cfg)
+	if hint == nil || hint.Chunks != est.Chunks {
+		t.Fatalf("expected split hint with %d chunks, got %+v", est.Chunks, hint)
+	}
+	if want := est.ApproxSizeMB / float64(est.Chunks); hint.ApproxSizeMB != want {
+		t.Fatalf("expected %v MB per chunk, got %v", want, hint.ApproxSizeMB)
+	}
+}
+
+func TestEstimateAuditZipRejectsInvalidRequest(t *testing.T) {
+	svc := newTestService(t, testQueueConfig())
+	body, _ := json.Marshal(AuditZipRequest{
+		From:   openapi_types.Date{Time: time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC)},
+		To:     openapi_types.Date{Time: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+		Format: Zip,
+	})
+	r := httptest.NewRequest(http.MethodPost, "/audit/zip/estimate", bytes.NewReader(body))
+	r.Header.Set("X-Tenant-Id", "tenant-a")
+	w := httptest.NewRecorder()
+	svc.EstimateAuditZip(w, r)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d: %s", w.Code, w.Body.String())
+	}
+}
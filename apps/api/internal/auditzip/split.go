@@ -0,0 +1,226 @@
+package auditzip
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	openapi_types "github.com/oapi-codegen/runtime/types"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// splitRequests divides req's [from, to] range into chunks contiguous child
+// requests that tile it exactly, spreading leftover days over the first chunks.
+// Child requests never carry a callback: only the parent reports completion.
+func splitRequests(req AuditZipRequest, chunks int) []AuditZipRequest {
+	from := req.From.Time
+	rangeDays := int(req.To.Time.Sub(from).Hours()/24) + 1
+	if chunks > rangeDays {
+		chunks = rangeDays
+	}
+	if chunks < 1 {
+		chunks = 1
+	}
+	base, extra := rangeDays/chunks, rangeDays%chunks
+	out := make([]AuditZipRequest, 0, chunks)
+	for i := 0; i < chunks; i++ {
+		days := base
+		if i < extra {
+			days++
+		}
+		child := req
+		child.From = openapi_types.Date{Time: from}
+		child.To = openapi_types.Date{Time: from.AddDate(0, 0, days-1)}
+		child.CallbackUrl = nil
+		child.AutoSplit = nil
+		out = append(out, child)
+		from = from.AddDate(0, 0, days)
+	}
+	return out
+}
+
+// splitCriteriaHash fingerprints an auto-split request by its children's
+// criteria hashes, so a retry dedupes against the same sub-ranges and a change
+// in chunking produces a different hash.
+func splitCriteriaHash(tenantID string, req AuditZipRequest, chunks int, cfg Config) string {
+	children := splitRequests(req, chunks)
+	hashes := make([]string, 0, len(children))
+	for _, child := range children {
+		hashes = append(hashes, computeCriteriaHash(tenantID, child, cfg))
+	}
+	sum := sha256.Sum256([]byte("split:" + strings.Join(hashes, ",")))
+	return hex.EncodeToString(sum[:])
+}
+
+// childIdempotencyKey derives a child's key from its parent's; '#' keeps it out
+// of the client-visible key space, which ValidateIdempotencyKey restricts.
+func childIdempotencyKey(idempotencyKey string, index int) string {
+	return fmt.Sprintf("%s#%d", idempotencyKey, index)
+}
+
+// EnqueueSplit creates a parent job for req fanned out into chunks child jobs
+// over contiguous sub-ranges. The parent is never run itself: its status and
+// progress aggregate its children's. Replays of the idempotency key return the
+// parent, as with Enqueue.
+func (q *JobQueue) EnqueueSplit(ctx context.Context, tenantID, idempotencyKey, criteriaHash string, req AuditZipRequest, chunks int) (AuditZipJob, error) {
+	ctx, span := startSpan(ctx, "auditzip.JobQueue.Enqueue", tenantID, trace.WithAttributes(attribute.Int("job.chunks", chunks)))
+	job, err := q.dedupe(ctx, tenantID, idempotencyKey, criteriaHash, func() (AuditZipJob, error) {
+		return q.enqueueSplit(ctx, tenantID, idempotencyKey, criteriaHash, req, chunks)
+	})
+	span.SetAttributes(attribute.String("job.id", job.JobId.String()))
+	endSpan(span, err)
+	return job, err
+}
+
+func (q *JobQueue) enqueueSplit(ctx context.Context, tenantID, idempotencyKey, criteriaHash string, req AuditZipRequest, chunks int) (AuditZipJob, error) {
+	children := splitRequests(req, chunks)
+	childHashes := make([]string, len(children))
+	for i, child := range children {
+		childHashes[i] = computeCriteriaHash(tenantID, child, q.cfg)
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.stoppingLocked() {
+		return AuditZipJob{}, ErrShuttingDown
+	}
+	if existing, ok, err := q.replayLocked(tenantID, idempotencyKey, criteriaHash); ok {
+		return existing, err
+	}
+	if q.cfg.MaxQueueDepth > 0 && q.activeCountLocked()+len(children) > q.cfg.MaxQueueDepth {
+		return AuditZipJob{}, RateLimitErr{RetryAfter: q.cfg.QueueRetryAfter}
+	}
+	for _, hash := range append([]string{criteriaHash}, childHashes...) {
+		if existing, ok := q.byCriteria[fmt.Sprintf("%s:%s", tenantID, hash)]; ok && !isTerminal(existing.job.Status) {
+			return AuditZipJob{}, ConflictErr{Reason: DuplicateJob, JobID: existing.job.JobId.String()}
+		}
+	}
+
+	// Children start only after the parent is fully linked; their first update
+	// waits on q.mu, which is held until then.
+	parent, _ := q.addJobLocked(ctx, tenantID, idempotencyKey, criteriaHash, req)
+	for i, child := range children {
+		state, jobCtx := q.addJobLocked(ctx, tenantID, childIdempotencyKey(idempotencyKey, i), childHashes[i], child)
+		state.parent = parent
+		parent.children = append(parent.children, state)
+		q.persistLocked(state)
+		q.startJobLocked(jobCtx, state)
+	}
+	parent.job.Children = childSummaries(parent.children)
+	q.persistLocked(parent)
+	return cloneJob(parent.job), nil
+}
+
+// aggregateLocked recomputes a parent job from its children: it stays queued
+// until a child starts, succeeds only when every child succeeded, fails if any
+// child failed and is otherwise canceled once all children are terminal.
+// Progress is the children's mean. Callers must hold q.mu.
+func (q *JobQueue) aggregateLocked(parent *jobState) {
+	if q.jobs[parent.job.JobId.String()] != parent {
+		return // reaped
+	}
+	status, progress := parent.job.Status, parent.job.Progress
+	parent.job.Children = childSummaries(parent.children)
+	if isTerminal(status) {
+		q.persistLocked(parent)
+		return
+	}
+
+	total, failed, canceled, pending := 0, 0, 0, 0
+	started := false
+	for _, child := range parent.children {
+		total += child.job.Progress
+		switch child.job.Status {
+		case Failed:
+			failed++
+		case Canceled:
+			canceled++
+		case Queued:
+			pending++
+		case Running:
+			pending++
+			started = true
+		}
+	}
+	now := q.now().UTC()
+	parent.job.Progress = total / len(parent.children)
+	switch {
+	case pending > 0:
+		if started && parent.job.Status == Queued {
+			parent.job.Status = Running
+			parent.job.StartedAt = &now
+		}
+	case failed > 0:
+		parent.job.Status = Failed
+		parent.job.Error = &InternalError{Code: "CHILD_FAILED", Message: fmt.Sprintf("%d of %d child jobs failed", failed, len(parent.children)), Retryable: true}
+	case canceled > 0:
+		parent.job.Status = Canceled
+		parent.job.Error = &InternalError{Code: "CANCELED", Message: "child job canceled", Retryable: true}
+	default:
+		parent.job.Status = Succeeded
+		parent.job.Progress = 100
+	}
+	if isTerminal(parent.job.Status) {
+		parent.job.FinishedAt = &now
+		disable := false
+		parent.job.CanCancel = &disable
+	}
+	q.persistLocked(parent)
+	if parent.job.Status != status || parent.job.Progress != progress {
+		q.publishLocked(parent)
+	}
+	if isTerminal(parent.job.Status) {
+		q.notifyLocked(parent)
+	}
+}
+
+// linkChildrenLocked reattaches rehydrated child jobs to their parents in range
+// order and re-aggregates each parent. Callers must hold q.mu.
+func (q *JobQueue) linkChildrenLocked(parentIDs map[*jobState]string) {
+	parents := map[*jobState]struct{}{}
+	for child, parentID := range parentIDs {
+		parent, ok := q.jobs[parentID]
+		if !ok {
+			continue
+		}
+		child.parent = parent
+		parent.children = append(parent.children, child)
+		parents[parent] = struct{}{}
+	}
+	for parent := range parents {
+		sort.Slice(parent.children, func(i, j int) bool {
+			return parent.children[i].request.From.Time.Before(parent.children[j].request.From.Time)
+		})
+		q.aggregateLocked(parent)
+	}
+}
+
+func childSummaries(children []*jobState) *[]AuditZipChildJob {
+	out := make([]AuditZipChildJob, 0, len(children))
+	for _, child := range children {
+		summary := AuditZipChildJob{
+			JobId:    child.job.JobId,
+			From:     child.request.From,
+			To:       child.request.To,
+			Status:   string(child.job.Status),
+			Progress: child.job.Progress,
+		}
+		if child.job.Result != nil {
+			signedURL := child.job.Result.SignedUrl
+			summary.SignedUrl = &signedURL
+		}
+		out = append(out, summary)
+	}
+	return &out
+}
+
+// isParent reports whether state is an auto-split parent, which holds no
+// worker slot and only mirrors its children.
+func (s *jobState) isParent() bool {
+	return s.job.Children != nil
+}
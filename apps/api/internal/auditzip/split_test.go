@@ -0,0 +1,163 @@
+package auditzip
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestSplitRequestsTilesRange(t *testing.T) {
+	req := testRequest(1)
+	req.To.Time = req.From.Time.AddDate(0, 0, 9) // 10 days
+	callback := "https://hooks.example.com/audit"
+	req.CallbackUrl = &callback
+
+	children := splitRequests(req, 3)
+	if len(children) != 3 {
+		t.Fatalf("expected 3 children, got %d", len(children))
+	}
+	if !children[0].From.Time.Equal(req.From.Time) {
+		t.Fatalf("first child starts %s, want %s", children[0].From, req.From)
+	}
+	if !children[2].To.Time.Equal(req.To.Time) {
+		t.Fatalf("last child ends %s, want %s", children[2].To, req.To)
+	}
+	wantDays := []int{4, 3, 3}
+	for i, child := range children {
+		days := int(child.To.Time.Sub(child.From.Time).Hours()/24) + 1
+		if days != wantDays[i] {
+			t.Fatalf("child %d covers %d days, want %d", i, days, wantDays[i])
+		}
+		if i > 0 && !child.From.Time.Equal(children[i-1].To.Time.AddDate(0, 0, 1)) {
+			t.Fatalf("child %d starts %s, not the day after %s", i, child.From, children[i-1].To)
+		}
+		if child.CallbackUrl != nil {
+			t.Fatalf("child %d carries the parent's callback", i)
+		}
+	}
+}
+
+func TestSplitCriteriaHashAccountsForChunks(t *testing.T) {
+	cfg := testQueueConfig()
+	req := testRequest(1)
+	req.To.Time = req.From.Time.AddDate(0, 0, 9)
+
+	three := splitCriteriaHash("tenant-a", req, 3, cfg)
+	if three != splitCriteriaHash("tenant-a", req, 3, cfg) {
+		t.Fatal("expected split hash to be stable")
+	}
+	if three == splitCriteriaHash("tenant-a", req, 2, cfg) {
+		t.Fatal("expected different chunking to change the hash")
+	}
+	if three == computeCriteriaHash("tenant-a", req, cfg) {
+		t.Fatal("expected split hash to differ from the unsplit hash")
+	}
+}
+
+func enqueueWithKey(t *testing.T, svc Service, req AuditZipRequest, key uuid.UUID) *httptest.ResponseRecorder {
+	t.Helper()
+	body, err := json.Marshal(req)
+	if err != nil {
+		t.Fatalf("marshal request: %v", err)
+	}
+	w := httptest.NewRecorder()
+	svc.EnqueueAuditZip(w, httptest.NewRequest(http.MethodPost, "/audit/zip", bytes.NewReader(body)), EnqueueAuditZipParams{
+		XCorrelationId: uuid.New(),
+		XTenantId:      "tenant-a",
+		IdempotencyKey: key,
+	})
+	return w
+}
+
+func TestEnqueueAuditZipAutoSplit(t *testing.T) {
+	cfg := testQueueConfig()
+	cfg.MaxRangeDays = 4
+	cfg.MaxConcurrentJobs = 3
+	svc := newTestService(t, cfg)
+
+	req := testRequest(1)
+	req.To.Time = req.From.Time.AddDate(0, 0, 9) // 10 days -> 3 chunks
+	if w := enqueue(t, svc, req); w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413 without autoSplit, got %d", w.Code)
+	}
+
+	autoSplit := true
+	req.AutoSplit = &autoSplit
+	key := uuid.New()
+	w := enqueueWithKey(t, svc, req, key)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", w.Code, w.Body.String())
+	}
+	var accepted AuditZipJob
+	if err := json.NewDecoder(w.Body).Decode(&accepted); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if accepted.Children == nil || len(*accepted.Children) != 3 {
+		t.Fatalf("expected 3 children, got %+v", accepted.Children)
+	}
+
+	parent := waitForStatus(t, svc.queue, accepted.JobId.String(), Succeeded)
+	if parent.Progress != 100 || parent.FinishedAt == nil {
+		t.Fatalf("expected finished parent at 100%%, got %d", parent.Progress)
+	}
+	children := *parent.Children
+	if !children[0].From.Time.Equal(req.From.Time) || !children[2].To.Time.Equal(req.To.Time) {
+		t.Fatalf("children %s..%s do not span %s..%s", children[0].From, children[2].To, req.From, req.To)
+	}
+	for i, child := range children {
+		if i > 0 && !child.From.Time.Equal(children[i-1].To.Time.AddDate(0, 0, 1)) {
+			t.Fatalf("child %d starts %s, not the day after %s", i, child.From, children[i-1].To)
+		}
+		if child.Status != string(Succeeded) || child.SignedUrl == nil {
+			t.Fatalf("child %d: expected succeeded with signed URL, got %s %v", i, child.Status, child.SignedUrl)
+		}
+		if _, tenantID, ok := svc.queue.Get(child.JobId.String()); !ok || tenantID != "tenant-a" {
+			t.Fatalf("child %d not retrievable for tenant-a", i)
+		}
+	}
+
+	// A retry with the same key replays the parent instead of splitting again.
+	w = enqueueWithKey(t, svc, req, key)
+	var replay AuditZipJob
+	if err := json.NewDecoder(w.Body).Decode(&replay); err != nil {
+		t.Fatalf("decode replay: %v", err)
+	}
+	if w.Code != http.StatusAccepted || replay.JobId != accepted.JobId {
+		t.Fatalf("replay got %d job %s, want job %s", w.Code, replay.JobId, accepted.JobId)
+	}
+}
+
+func TestCancelSplitParentCancelsChildren(t *testing.T) {
+	cfg := testQueueConfig()
+	q := NewJobQueue(NewInMemoryStorage(), cfg)
+	q.process = func(ctx context.Context, state *jobState) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	req := testRequest(1)
+	req.To.Time = req.From.Time.AddDate(0, 0, 5)
+	job, err := q.EnqueueSplit(context.Background(), "tenant-a", uuid.NewString(), "split-hash", req, 2)
+	if err != nil {
+		t.Fatalf("enqueue split: %v", err)
+	}
+	waitForStatus(t, q, job.JobId.String(), Running)
+
+	canceled, err := q.Cancel("tenant-a", job.JobId.String())
+	if err != nil {
+		t.Fatalf("cancel: %v", err)
+	}
+	if canceled.Status != Canceled {
+		t.Fatalf("expected parent canceled, got %s", canceled.Status)
+	}
+	for i, child := range *canceled.Children {
+		if child.Status != string(Canceled) {
+			t.Fatalf("child %d: expected canceled, got %s", i, child.Status)
+		}
+	}
+}
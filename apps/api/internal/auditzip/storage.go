@@ -1,22 +1,64 @@
 package auditzip
 
 import (
+	"bytes"
 	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
 	"net/url"
+	"strings"
 	"sync"
 	"time"
 )
 
 type Storage interface {
 	PutObject(ctx context.Context, key string, body []byte, contentType string) error
+	// PutObjectStream uploads body until EOF without requiring the caller to
+	// buffer it, so large archives can be written as they are produced.
+	PutObjectStream(ctx context.Context, key string, body io.Reader, contentType string) error
 	GetSignedURL(ctx context.Context, key string, ttl time.Duration) (string, error)
+	// StatObject reports key's size and content type without reading it.
+	StatObject(ctx context.Context, key string) (ObjectInfo, error)
+	// GetObjectRange streams length bytes of key starting at offset; a negative
+	// length reads to the end. The caller must close the returned body.
+	GetObjectRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error)
 	DeleteObject(ctx context.Context, key string) error
 }
 
+// ErrObjectNotFound is returned by StatObject and GetObjectRange for a key that
+// does not exist, including one already removed by retention.
+var ErrObjectNotFound = errors.New("object not found")
+
+// ObjectInfo describes a stored object.
+type ObjectInfo struct {
+	Size        int64
+	ContentType string
+	ModTime     time.Time
+}
+
+// Signed URL errors reported by InMemoryStorage.VerifySignedURL.
+var (
+	ErrURLExpired   = errors.New("signed URL has expired")
+	ErrURLSignature = errors.New("signed URL signature is invalid")
+)
+
+// StoragePath is where StorageDownloadHandler serves InMemoryStorage objects.
+const StoragePath = "/audit/storage/"
+
+// DefaultPublicBaseURL is where the dev server's StoragePath route serves
+// InMemoryStorage objects.
+const DefaultPublicBaseURL = "http://localhost:8080/audit/storage"
+
 type InMemoryStorage struct {
-	mu   sync.RWMutex
-	data map[string]storedObject
+	mu      sync.RWMutex
+	data    map[string]storedObject
+	baseURL *url.URL
+	secret  []byte
 }
 
 type storedObject struct {
@@ -26,7 +68,36 @@ type storedObject struct {
 }
 
 func NewInMemoryStorage() *InMemoryStorage {
-	return &InMemoryStorage{data: map[string]storedObject{}}
+	s, _ := NewInMemoryStorageWithBaseURL(DefaultPublicBaseURL)
+	return s
+}
+
+// NewInMemoryStorageWithBaseURL returns an InMemoryStorage whose signed URLs
+// are publicBaseURL/{key}, so they point at wherever the objects are served.
+func NewInMemoryStorageWithBaseURL(publicBaseURL string) (*InMemoryStorage, error) {
+	base, err := url.Parse(publicBaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("public base URL: %w", err)
+	}
+	if (base.Scheme != "http" && base.Scheme != "https") || base.Host == "" {
+		return nil, fmt.Errorf("public base URL %q must be an absolute http(s) URL", publicBaseURL)
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("signing secret: %w", err)
+	}
+	return &InMemoryStorage{data: map[string]storedObject{}, baseURL: base, secret: secret}, nil
+}
+
+// SetSigningSecret replaces the random per-process key that signs URLs, so
+// links stay valid across restarts and replicas. An empty secret is ignored.
+func (s *InMemoryStorage) SetSigningSecret(secret string) {
+	if secret == "" {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.secret = []byte(secret)
 }
 
 func (s *InMemoryStorage) PutObject(ctx context.Context, key string, body []byte, contentType string) error {
@@ -39,6 +110,14 @@ func (s *InMemoryStorage) PutObject(ctx context.Context, key string, body []byte
 	return nil
 }
 
+func (s *InMemoryStorage) PutObjectStream(ctx context.Context, key string, body io.Reader, contentType string) error {
+	b, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	return s.PutObject(ctx, key, b, contentType)
+}
+
 func (s *InMemoryStorage) GetSignedURL(_ context.Context, key string, ttl time.Duration) (string, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -46,10 +125,81 @@ func (s *InMemoryStorage) GetSignedURL(_ context.Context, key string, ttl time.D
 		return "", fmt.Errorf("not found")
 	}
 	exp := time.Now().UTC().Add(ttl).Format(time.RFC3339)
-	u := url.URL{Scheme: "https", Host: "storage.local", Path: "/" + key, RawQuery: "exp=" + url.QueryEscape(exp)}
+	u := *s.baseURL
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + key
+	q := u.Query()
+	q.Set("exp", exp)
+	q.Set("sig", hex.EncodeToString(s.sign(key, exp)))
+	u.RawQuery = q.Encode()
 	return u.String(), nil
 }
 
+// VerifySignedURL checks the exp and sig parameters of a URL issued by
+// GetSignedURL for key: the signature must match, so exp cannot be altered,
+// and exp must not have passed.
+func (s *InMemoryStorage) VerifySignedURL(key string, query url.Values) error {
+	exp := query.Get("exp")
+	sig, err := hex.DecodeString(query.Get("sig"))
+	if err != nil || exp == "" {
+		return ErrURLSignature
+	}
+	s.mu.RLock()
+	want := s.sign(key, exp)
+	s.mu.RUnlock()
+	if !hmac.Equal(sig, want) {
+		return ErrURLSignature
+	}
+	expiresAt, err := time.Parse(time.RFC3339, exp)
+	if err != nil {
+		return ErrURLSignature
+	}
+	if time.Now().After(expiresAt) {
+		return ErrURLExpired
+	}
+	return nil
+}
+
+// sign returns the HMAC-SHA256 of key and exp. Callers must hold s.mu.
+func (s *InMemoryStorage) sign(key, exp string) []byte {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(key + "\n" + exp))
+	return mac.Sum(nil)
+}
+
+func (s *InMemoryStorage) StatObject(ctx context.Context, key string) (ObjectInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return ObjectInfo{}, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	obj, ok := s.data[key]
+	if !ok {
+		return ObjectInfo{}, ErrObjectNotFound
+	}
+	return ObjectInfo{Size: int64(len(obj.body)), ContentType: obj.contentType, ModTime: obj.createdAt}, nil
+}
+
+func (s *InMemoryStorage) GetObjectRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	obj, ok := s.data[key]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, ErrObjectNotFound
+	}
+	size := int64(len(obj.body))
+	if offset < 0 || offset > size {
+		return nil, fmt.Errorf("offset %d out of range for %d bytes", offset, size)
+	}
+	end := size
+	if length >= 0 && offset+length < size {
+		end = offset + length
+	}
+	return io.NopCloser(bytes.NewReader(obj.body[offset:end])), nil
+}
+
 func (s *InMemoryStorage) DeleteObject(ctx context.Context, key string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
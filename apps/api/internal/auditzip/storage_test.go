@@ -0,0 +1,51 @@
+package auditzip
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestInMemoryStorageSignedURLUsesPublicBaseURL(t *testing.T) {
+	tests := []struct {
+		base string
+		want string
+	}{
+		{base: DefaultPublicBaseURL, want: "http://localhost:8080/audit/storage/t/a.zip"},
+		{base: "http://localhost:8080/audit/storage/", want: "http://localhost:8080/audit/storage/t/a.zip"},
+	}
+	for _, tt := range tests {
+		st, err := NewInMemoryStorageWithBaseURL(tt.base)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.base, err)
+		}
+		if err := st.PutObject(context.Background(), "t/a.zip", []byte("zip"), "application/zip"); err != nil {
+			t.Fatal(err)
+		}
+		before := time.Now().UTC().Add(10 * time.Minute).Truncate(time.Second)
+		raw, err := st.GetSignedURL(context.Background(), "t/a.zip", 10*time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		u, err := url.Parse(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		exp, err := time.Parse(time.RFC3339, u.Query().Get("exp"))
+		if err != nil {
+			t.Fatalf("expected an RFC 3339 exp parameter in %s: %v", raw, err)
+		}
+		if exp.Before(before) {
+			t.Fatalf("exp %v is earlier than the requested ttl", exp)
+		}
+		u.RawQuery = ""
+		if u.String() != tt.want {
+			t.Fatalf("expected %s, got %s", tt.want, u.String())
+		}
+	}
+
+	if _, err := NewInMemoryStorageWithBaseURL("storage.local"); err == nil {
+		t.Fatal("expected a relative base URL to be rejected")
+	}
+}
@@ -0,0 +1,33 @@
+package auditzip
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer resolves through the global provider, so spans are no-ops until the
+// binary installs one with otel.SetTracerProvider.
+var tracer = otel.Tracer("github.com/yourorg/yourapp/apps/api/internal/auditzip")
+
+// startSpan starts a child span of ctx tagged with tenantID and, when ctx
+// carries one, the correlation ID.
+func startSpan(ctx context.Context, name, tenantID string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	attrs := []attribute.KeyValue{attribute.String("tenant.id", tenantID)}
+	if corrID := correlationID(ctx); corrID != "" {
+		attrs = append(attrs, attribute.String("correlation.id", corrID))
+	}
+	return tracer.Start(ctx, name, append(opts, trace.WithAttributes(attrs...))...)
+}
+
+// endSpan marks span failed when err is set, then ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -1,36 +1,102 @@
 package auditzip
 
 import (
+	"errors"
+	"fmt"
 	"math"
+	"strings"
 	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+
+	"github.com/yourorg/yourapp/apps/api/internal/rules"
+)
+
+// Idempotency-Key formats accepted by ValidateIdempotencyKey.
+const (
+	IdempotencyFormatUUID  = "uuid"
+	IdempotencyFormatToken = "token"
 )
 
+// ValidateIdempotencyKey checks that key is safe to use in the tenant:key
+// namespace: non-empty, within cfg.IdempotencyKeyMax, free of the ':' delimiter
+// and control characters, and matching one of cfg.IdempotencyFormats.
+func ValidateIdempotencyKey(key string, cfg Config) error {
+	if key == "" {
+		return errors.New("idempotency key is required")
+	}
+	if cfg.IdempotencyKeyMax > 0 && len(key) > cfg.IdempotencyKeyMax {
+		return fmt.Errorf("idempotency key exceeds %d characters", cfg.IdempotencyKeyMax)
+	}
+	if strings.ContainsRune(key, ':') {
+		return errors.New("idempotency key must not contain ':'")
+	}
+	if strings.IndexFunc(key, unicode.IsControl) >= 0 {
+		return errors.New("idempotency key must not contain control characters")
+	}
+	if len(cfg.IdempotencyFormats) == 0 {
+		return nil
+	}
+	for _, format := range cfg.IdempotencyFormats {
+		if matchesIdempotencyFormat(key, format) {
+			return nil
+		}
+	}
+	return fmt.Errorf("idempotency key must be one of: %s", strings.Join(cfg.IdempotencyFormats, ", "))
+}
+
+func matchesIdempotencyFormat(key, format string) bool {
+	switch format {
+	case IdempotencyFormatUUID:
+		_, err := uuid.Parse(key)
+		return err == nil && len(key) == 36
+	case IdempotencyFormatToken:
+		for _, r := range key {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.') {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+// maxPartnerLength caps AuditZipRequest.Partner.
+const maxPartnerLength = 140
+
 func ValidateRequest(req AuditZipRequest, cfg Config) ([]ValidationErrorItem, *SplitHint) {
 	errs := make([]ValidationErrorItem, 0)
 	if req.From.Time.IsZero() || req.To.Time.IsZero() {
-		errs = append(errs, ValidationErrorItem{Code: "AUDIT-REQ-001", Path: "from/to", Message: "from and to are required"})
+		errs = append(errs, ValidationErrorItem{Code: rules.AuditReq001, Path: "from/to", Message: rules.Message(rules.AuditReq001)})
 		return errs, nil
 	}
 
 	from := req.From.Time
 	to := req.To.Time
 	if to.Before(from) {
-		errs = append(errs, ValidationErrorItem{Code: "AUDIT-REQ-004", Path: "to", Message: "to must be on or after from"})
+		errs = append(errs, ValidationErrorItem{Code: rules.AuditReq004, Path: "to", Message: rules.Message(rules.AuditReq004)})
 	}
-	if req.Format != Zip {
-		errs = append(errs, ValidationErrorItem{Code: "AUDIT-REQ-005", Path: "format", Message: "format must be zip"})
+	if req.Format != Zip && req.Format != Targz {
+		errs = append(errs, ValidationErrorItem{Code: rules.AuditReq005, Path: "format", Message: rules.Message(rules.AuditReq005)})
 	}
-	if req.Partner != nil && len(*req.Partner) > 140 {
-		errs = append(errs, ValidationErrorItem{Code: "AUDIT-REQ-006", Path: "partner", Message: "partner too long"})
+	if req.Partner != nil && len(*req.Partner) > maxPartnerLength {
+		errs = append(errs, ValidationErrorItem{Code: rules.AuditReq006, Path: "partner", Message: rules.Messagef(rules.AuditReq006, "max %d characters", maxPartnerLength)})
 	}
 	if req.MinAmount != nil && *req.MinAmount < 0 {
-		errs = append(errs, ValidationErrorItem{Code: "AUDIT-REQ-007", Path: "minAmount", Message: "minAmount must be >= 0"})
+		errs = append(errs, ValidationErrorItem{Code: rules.AuditReq007, Path: "minAmount", Message: rules.Message(rules.AuditReq007)})
 	}
 	if req.MaxAmount != nil && *req.MaxAmount < 0 {
-		errs = append(errs, ValidationErrorItem{Code: "AUDIT-REQ-008", Path: "maxAmount", Message: "maxAmount must be >= 0"})
+		errs = append(errs, ValidationErrorItem{Code: rules.AuditReq008, Path: "maxAmount", Message: rules.Message(rules.AuditReq008)})
 	}
 	if req.MinAmount != nil && req.MaxAmount != nil && *req.MinAmount > *req.MaxAmount {
-		errs = append(errs, ValidationErrorItem{Code: "AUDIT-REQ-009", Path: "minAmount/maxAmount", Message: "minAmount must be <= maxAmount"})
+		errs = append(errs, ValidationErrorItem{Code: rules.AuditReq009, Path: "minAmount/maxAmount", Message: rules.Message(rules.AuditReq009)})
+	}
+	if req.CallbackUrl != nil {
+		if err := validateCallbackURL(*req.CallbackUrl, cfg); err != nil {
+			errs = append(errs, ValidationErrorItem{Code: rules.AuditReq010, Path: "callbackUrl", Message: rules.Messagef(rules.AuditReq010, "%v", err)})
+		}
 	}
 	if len(errs) > 0 {
 		return errs, nil
@@ -42,18 +108,39 @@ func ValidateRequest(req AuditZipRequest, cfg Config) ([]ValidationErrorItem, *S
 	return errs, nil
 }
 
-func splitHintIfNeeded(from, to time.Time, cfg Config) *SplitHint {
-	if cfg.MaxRangeDays == 0 {
-		return nil
-	}
+// ExportEstimate is the approximate size of an export and whether it exceeds
+// MaxRangeDays, derived from the configured per-day size without reading records.
+type ExportEstimate struct {
+	RangeDays    int     `json:"rangeDays"`
+	ApproxSizeMB float64 `json:"approxSizeMB"`
+	WillSplit    bool    `json:"willSplit"`
+	// Chunks is 1 when the range fits within MaxRangeDays.
+	Chunks int `json:"chunks"`
+}
+
+// EstimateExport sizes the inclusive range from..to. Chunks matches the
+// SplitHint ValidateRequest returns for the same range.
+func EstimateExport(from, to time.Time, cfg Config) ExportEstimate {
 	rangeDays := int(to.Sub(from).Hours()/24) + 1
-	if rangeDays <= cfg.MaxRangeDays {
+	est := ExportEstimate{
+		RangeDays:    rangeDays,
+		ApproxSizeMB: math.Ceil(cfg.EstimatedMBPerDay * float64(rangeDays)),
+		Chunks:       1,
+	}
+	if cfg.MaxRangeDays > 0 && rangeDays > cfg.MaxRangeDays {
+		est.WillSplit = true
+		est.Chunks = int(math.Ceil(float64(rangeDays) / float64(cfg.MaxRangeDays)))
+	}
+	return est
+}
+
+func splitHintIfNeeded(from, to time.Time, cfg Config) *SplitHint {
+	est := EstimateExport(from, to, cfg)
+	if !est.WillSplit {
 		return nil
 	}
-	chunks := int(math.Ceil(float64(rangeDays) / float64(cfg.MaxRangeDays)))
-	approx := math.Ceil(cfg.EstimatedMBPerDay * float64(rangeDays) / float64(chunks))
 	return &SplitHint{
-		Chunks:       chunks,
-		ApproxSizeMB: approx,
+		Chunks:       est.Chunks,
+		ApproxSizeMB: math.Ceil(cfg.EstimatedMBPerDay * float64(est.RangeDays) / float64(est.Chunks)),
 	}
 }
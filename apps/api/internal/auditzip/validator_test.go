@@ -1,6 +1,7 @@
 package auditzip
 
 import (
+	"strings"
 	"testing"
 	"time"
 
@@ -19,6 +20,22 @@ func TestValidateRequestSuccess(t *testing.T) {
 	}
 }
 
+func TestValidateRequestFormats(t *testing.T) {
+	req := AuditZipRequest{
+		From:   openapi_types.Date{Time: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+		To:     openapi_types.Date{Time: time.Date(2025, 1, 31, 0, 0, 0, 0, time.UTC)},
+		Format: Targz,
+	}
+	if errs, _ := ValidateRequest(req, LoadConfig()); len(errs) > 0 {
+		t.Fatalf("expected targz to validate, got %v", errs)
+	}
+	req.Format = "rar"
+	errs, _ := ValidateRequest(req, LoadConfig())
+	if len(errs) != 1 || errs[0].Code != "AUDIT-REQ-005" {
+		t.Fatalf("expected AUDIT-REQ-005 for rar, got %v", errs)
+	}
+}
+
 func TestValidateRequestInvalidDate(t *testing.T) {
 	req := AuditZipRequest{Format: Zip}
 	errs, _ := ValidateRequest(req, LoadConfig())
@@ -55,3 +72,42 @@ func TestValidateRequestSplitHint(t *testing.T) {
 		t.Fatalf("expected split hint, got %+v", hint)
 	}
 }
+
+func TestValidateIdempotencyKey(t *testing.T) {
+	cfg := LoadConfig()
+	cases := []struct {
+		name  string
+		key   string
+		valid bool
+	}{
+		{"uuid", "3f2504e0-4f89-41d3-9a0c-0305e82c3301", true},
+		{"token", "order-42_retry.1", true},
+		{"empty", "", false},
+		{"delimiter", "tenant-b:3f2504e0", false},
+		{"control character", "abc\ndef", false},
+		{"too long", strings.Repeat("a", cfg.IdempotencyKeyMax+1), false},
+		{"space", "abc def", false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateIdempotencyKey(tc.key, cfg)
+			if tc.valid && err != nil {
+				t.Fatalf("expected %q to be accepted, got %v", tc.key, err)
+			}
+			if !tc.valid && err == nil {
+				t.Fatalf("expected %q to be rejected", tc.key)
+			}
+		})
+	}
+}
+
+func TestValidateIdempotencyKeyFormatAllowlist(t *testing.T) {
+	cfg := LoadConfig()
+	cfg.IdempotencyFormats = []string{IdempotencyFormatUUID}
+	if err := ValidateIdempotencyKey("3f2504e0-4f89-41d3-9a0c-0305e82c3301", cfg); err != nil {
+		t.Fatalf("expected uuid to be accepted, got %v", err)
+	}
+	if err := ValidateIdempotencyKey("order-42", cfg); err == nil {
+		t.Fatalf("expected non-uuid key to be rejected when only uuid is allowed")
+	}
+}
@@ -2,6 +2,7 @@ package auth
 
 import (
 "context"
+"strings"
 "testing"
 "time"
 )
@@ -81,6 +82,39 @@ t.Error("VerifyKey() returned true for invalid key")
 }
 }
 
+func TestNeedsRehash(t *testing.T) {
+bcryptCfg := Config{APIKeyHashAlgorithm: "bcrypt", BcryptCost: 10}
+rawKey, _, _ := GenerateAPIKey()
+bcryptHash, err := HashKey(rawKey, bcryptCfg)
+if err != nil {
+t.Fatalf("HashKey() error = %v", err)
+}
+if NeedsRehash(bcryptHash, bcryptCfg) {
+t.Error("NeedsRehash() = true for hash at configured bcrypt cost")
+}
+if !NeedsRehash(bcryptHash, Config{BcryptCost: 12}) {
+t.Error("NeedsRehash() = false for bcrypt hash below configured cost")
+}
+
+argonCfg := Config{APIKeyHashAlgorithm: "argon2", Argon2Time: 1, Argon2Memory: 16 * 1024, Argon2Threads: 2}
+argonHash, err := HashKey(rawKey, argonCfg)
+if err != nil {
+t.Fatalf("HashKey() error = %v", err)
+}
+if NeedsRehash(argonHash, argonCfg) {
+t.Error("NeedsRehash() = true for hash at configured argon2 params")
+}
+stronger := argonCfg
+stronger.Argon2Memory = 32 * 1024
+if !NeedsRehash(argonHash, stronger) {
+t.Error("NeedsRehash() = false for argon2 hash below configured memory")
+}
+
+if NeedsRehash("not-a-hash", bcryptCfg) {
+t.Error("NeedsRehash() = true for unknown hash format")
+}
+}
+
 func TestHashKey_InvalidFormat(t *testing.T) {
 cfg := Config{
 APIKeyHashAlgorithm: "bcrypt",
@@ -109,6 +143,187 @@ t.Errorf("ExtractKeyPrefix() = %s, want empty string", prefix)
 }
 }
 
+func TestGenerateTenantAPIKey(t *testing.T) {
+disc := TenantKeyDiscriminator("Acme_Corp.Japan-01", 8)
+if disc != "acmecorp" {
+t.Fatalf("TenantKeyDiscriminator() = %s, want acmecorp", disc)
+}
+
+rawKey, prefix, err := GenerateTenantAPIKey(disc)
+if err != nil {
+t.Fatalf("GenerateTenantAPIKey() error = %v", err)
+}
+if !strings.HasPrefix(rawKey, KeyPrefix+"acmecorp_") {
+t.Errorf("rawKey = %s, want ppk_acmecorp_ prefix", rawKey)
+}
+if got := ExtractKeyTenant(rawKey); got != "acmecorp" {
+t.Errorf("ExtractKeyTenant() = %s, want acmecorp", got)
+}
+if got := ExtractKeyPrefix(rawKey); got != prefix || !strings.HasPrefix(prefix, "acmecorp_") {
+t.Errorf("ExtractKeyPrefix() = %s, want %s", got, prefix)
+}
+_, random, _ := strings.Cut(strings.TrimPrefix(rawKey, KeyPrefix), "_")
+if len(random) != 43 {
+t.Errorf("random part length = %d, want 43 (32 bytes)", len(random))
+}
+
+// Plain keys carry no discriminator, even when their random part contains '_'.
+for i := 0; i < 50; i++ {
+plain, _, _ := GenerateAPIKey()
+if got := ExtractKeyTenant(plain); got != "" {
+t.Fatalf("ExtractKeyTenant(%s) = %s, want empty", plain, got)
+}
+}
+}
+
+func TestKeyConfig_CustomPrefixRoundTrip(t *testing.T) {
+cfg := Config{
+APIKeyHashAlgorithm: "bcrypt",
+BcryptCost:          10,
+APIKeyPrefix:        "ppk_live_",
+APIKeyEntropyBytes:  MaxKeyEntropyBytes,
+}
+kc := cfg.KeyConfig()
+
+for _, tenant := range []string{"", "acme"} {
+rawKey, prefix, err := kc.Generate(tenant)
+if err != nil {
+t.Fatalf("Generate(%q) error = %v", tenant, err)
+}
+if !strings.HasPrefix(rawKey, "ppk_live_") {
+t.Errorf("rawKey = %s, want ppk_live_ prefix", rawKey)
+}
+if want := len("ppk_live_") + 59; tenant == "" && len(rawKey) != want {
+t.Errorf("rawKey length = %d, want %d (%d bytes)", len(rawKey), want, MaxKeyEntropyBytes)
+}
+
+hash, err := HashKey(rawKey, cfg)
+if err != nil {
+t.Fatalf("HashKey() error = %v", err)
+}
+if !VerifyKey(rawKey, hash, cfg) {
+t.Error("VerifyKey() returned false for valid key")
+}
+if VerifyKey(KeyPrefix+strings.TrimPrefix(rawKey, "ppk_live_"), hash, cfg) {
+t.Error("VerifyKey() accepted the key under the default prefix")
+}
+if got := kc.ExtractPrefix(rawKey); got != prefix {
+t.Errorf("ExtractPrefix() = %s, want %s", got, prefix)
+}
+if got := kc.ExtractTenant(rawKey); got != tenant {
+t.Errorf("ExtractTenant() = %s, want %q", got, tenant)
+}
+}
+}
+
+func TestKeyConfig_Validate(t *testing.T) {
+tests := []struct {
+name string
+kc   KeyConfig
+want error
+}{
+{"default", DefaultKeyConfig, nil},
+{"minimum entropy", KeyConfig{Prefix: "ppk_test_", EntropyBytes: MinKeyEntropyBytes}, nil},
+{"entropy too short", KeyConfig{Prefix: "ppk_", EntropyBytes: MinKeyEntropyBytes - 1}, ErrKeyEntropy},
+{"entropy too long", KeyConfig{Prefix: "ppk_", EntropyBytes: MaxKeyEntropyBytes + 1}, ErrKeyEntropy},
+{"empty prefix", KeyConfig{EntropyBytes: 32}, ErrKeyPrefix},
+{"prefix with dash", KeyConfig{Prefix: "ppk-live_", EntropyBytes: 32}, ErrKeyPrefix},
+}
+for _, tt := range tests {
+if err := tt.kc.Validate(); err != tt.want {
+t.Errorf("%s: Validate() = %v, want %v", tt.name, err, tt.want)
+}
+}
+
+if _, _, err := (KeyConfig{Prefix: "ppk_", EntropyBytes: 8}).Generate(""); err != ErrKeyEntropy {
+t.Errorf("Generate() with 8 bytes error = %v, want ErrKeyEntropy", err)
+}
+}
+
+func TestInMemoryAPIKeyStore_DummyVerifyOnMiss(t *testing.T) {
+cfg := Config{APIKeyHashAlgorithm: "bcrypt", BcryptCost: 10, KeyTenantPrefix: true}
+store := NewInMemoryAPIKeyStore(cfg)
+dummies := 0
+store.onDummyVerify = func() { dummies++ }
+ctx := context.Background()
+if err := store.CreateTenant(ctx, Tenant{ID: "acme", Status: "active"}); err != nil {
+t.Fatalf("CreateTenant() error = %v", err)
+}
+_, rawKey, err := store.CreateKey(ctx, "acme", "Key", []string{"audit:read"}, nil)
+if err != nil {
+t.Fatalf("CreateKey() error = %v", err)
+}
+
+if _, _, err := store.ValidateKey(ctx, rawKey); err != nil {
+t.Fatalf("ValidateKey() error = %v", err)
+}
+if dummies != 0 {
+t.Errorf("dummy verifications on a hit = %d, want 0", dummies)
+}
+
+// An unknown discriminator leaves no candidates; a missing prefix never parses.
+// Both still pay for one hash comparison (counted rather than timed, so the
+// test does not depend on machine speed).
+for i, miss := range []string{KeyPrefix + "globex_" + strings.Repeat("a", 43), "not-a-key"} {
+if _, _, err := store.ValidateKey(ctx, miss); err != ErrInvalidAPIKey {
+t.Errorf("ValidateKey(%q) error = %v, want ErrInvalidAPIKey", miss, err)
+}
+if dummies != i+1 {
+t.Errorf("dummy verifications after miss %d = %d, want %d", i, dummies, i+1)
+}
+}
+}
+
+func TestInMemoryAPIKeyStore_TenantPrefixedKeys(t *testing.T) {
+cfg := Config{
+APIKeyHashAlgorithm:   "bcrypt",
+BcryptCost:            10,
+KeyRotationWindow:     24 * time.Hour,
+KeyTenantPrefix:       true,
+KeyTenantPrefixLength: 8,
+}
+store := NewInMemoryAPIKeyStore(cfg)
+ctx := context.Background()
+for _, id := range []string{"acme-japan", "globex"} {
+if err := store.CreateTenant(ctx, Tenant{ID: id, Name: id, Plan: "pro", Status: "active", CreatedAt: time.Now().UTC()}); err != nil {
+t.Fatalf("CreateTenant() error = %v", err)
+}
+}
+
+key, rawKey, err := store.CreateKey(ctx, "acme-japan", "Tagged", []string{"audit:read"}, nil)
+if err != nil {
+t.Fatalf("CreateKey() error = %v", err)
+}
+if ExtractKeyTenant(rawKey) != "acme-jap" || !strings.HasPrefix(key.KeyPrefix, "acme-jap_") {
+t.Errorf("expected tenant discriminator in key %s (prefix %s)", rawKey, key.KeyPrefix)
+}
+if _, _, err := store.CreateKey(ctx, "globex", "Other", []string{"audit:read"}, nil); err != nil {
+t.Fatalf("CreateKey() error = %v", err)
+}
+
+tenant, validated, err := store.ValidateKey(ctx, rawKey)
+if err != nil {
+t.Fatalf("ValidateKey() error = %v", err)
+}
+if tenant.ID != "acme-japan" || validated.ID != key.ID {
+t.Errorf("ValidateKey() = %s/%s, want acme-japan/%s", tenant.ID, validated.ID, key.ID)
+}
+
+// Re-labelling the key with another tenant's discriminator must not validate.
+_, random, _ := strings.Cut(strings.TrimPrefix(rawKey, KeyPrefix), "_")
+if _, _, err := store.ValidateKey(ctx, KeyPrefix+"globex_"+random); err != ErrInvalidAPIKey {
+t.Errorf("ValidateKey() with swapped discriminator error = %v, want ErrInvalidAPIKey", err)
+}
+
+rotated, rotatedRaw, err := store.RotateKey(ctx, key.ID)
+if err != nil {
+t.Fatalf("RotateKey() error = %v", err)
+}
+if ExtractKeyTenant(rotatedRaw) != "acme-jap" || !strings.HasPrefix(rotated.KeyPrefix, "acme-jap_") {
+t.Errorf("expected rotated key to keep the discriminator, got %s", rotatedRaw)
+}
+}
+
 func TestInMemoryAPIKeyStore_CreateAndValidate(t *testing.T) {
 cfg := Config{
 APIKeyHashAlgorithm: "bcrypt",
@@ -163,6 +378,49 @@ t.Errorf("ValidateKey() error = %v, want ErrInvalidAPIKey", err)
 }
 }
 
+func TestInMemoryAPIKeyStore_ValidateKeyRehashes(t *testing.T) {
+cfg := Config{
+APIKeyHashAlgorithm: "bcrypt",
+BcryptCost:          10,
+}
+store := NewInMemoryAPIKeyStore(cfg)
+ctx := context.Background()
+
+tenant := Tenant{ID: "test-tenant", Name: "Test", Plan: "pro", Status: "active", CreatedAt: time.Now().UTC()}
+_ = store.CreateTenant(ctx, tenant)
+
+key, rawKey, err := store.CreateKey(ctx, "test-tenant", "Test Key", []string{"audit:read"}, nil)
+if err != nil {
+t.Fatalf("CreateKey() error = %v", err)
+}
+oldHash := key.KeyHash
+
+// Operator raises the cost
+store.cfg.BcryptCost = 12
+
+if _, _, err := store.ValidateKey(ctx, rawKey); err != nil {
+t.Fatalf("ValidateKey() error = %v", err)
+}
+
+store.mu.RLock()
+newHash := store.keys[key.ID].KeyHash
+_, oldIndexed := store.keyHash[oldHash]
+indexedID := store.keyHash[newHash]
+store.mu.RUnlock()
+
+if newHash == oldHash || NeedsRehash(newHash, store.cfg) {
+t.Errorf("stored hash was not upgraded to cost 12")
+}
+if oldIndexed || indexedID != key.ID {
+t.Error("hash index not updated after rehash")
+}
+
+// Key still validates against the upgraded hash
+if _, _, err := store.ValidateKey(ctx, rawKey); err != nil {
+t.Errorf("ValidateKey() after rehash error = %v", err)
+}
+}
+
 func TestInMemoryAPIKeyStore_RevokeKey(t *testing.T) {
 cfg := Config{
 APIKeyHashAlgorithm: "bcrypt",
@@ -288,6 +546,57 @@ t.Error("different key should be allowed")
 }
 }
 
+func TestRateLimiter_Peek(t *testing.T) {
+rl := NewRateLimiter(4, time.Second)
+start := time.Now()
+
+if remaining, _ := rl.Peek("test-key"); remaining != 4 {
+t.Fatalf("unused key: remaining = %d, want 4", remaining)
+}
+for i := 0; i < 3; i++ {
+rl.Allow("test-key")
+}
+remaining, resetAt := rl.Peek("test-key")
+if remaining != 1 {
+t.Fatalf("after 3 requests: remaining = %d, want 1", remaining)
+}
+if !resetAt.After(start) || resetAt.After(start.Add(time.Second)) {
+t.Fatalf("resetAt %v should fall within the window starting %v", resetAt, start)
+}
+
+// Peeking must not consume a token
+if again, _ := rl.Peek("test-key"); again != 1 {
+t.Fatalf("repeated Peek: remaining = %d, want 1", again)
+}
+if allowed, _ := rl.Allow("test-key"); !allowed {
+t.Fatal("last token should still be available after Peek")
+}
+if remaining, _ := rl.Peek("test-key"); remaining != 0 {
+t.Fatalf("after 4 requests: remaining = %d, want 0", remaining)
+}
+}
+
+func TestRateLimiter_PeekCountsRefill(t *testing.T) {
+rl := NewRateLimiter(2, 100*time.Millisecond)
+rl.Allow("test-key")
+rl.Allow("test-key")
+
+time.Sleep(60 * time.Millisecond)
+remaining, resetAt := rl.Peek("test-key")
+if remaining != 1 {
+t.Fatalf("after refill: remaining = %d, want 1", remaining)
+}
+if !resetAt.After(time.Now()) {
+t.Fatalf("resetAt %v should be in the future while a token is missing", resetAt)
+}
+if allowed, _ := rl.Allow("test-key"); !allowed {
+t.Fatal("refilled token should be allowed")
+}
+if allowed, _ := rl.Allow("test-key"); allowed {
+t.Fatal("Peek must not have granted an extra token")
+}
+}
+
 func TestActor_HasScope(t *testing.T) {
 tests := []struct {
 name     string
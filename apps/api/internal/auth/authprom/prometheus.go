@@ -0,0 +1,56 @@
+// Package authprom exports auth.Metrics to Prometheus.
+package authprom
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics implements auth.Metrics using a Prometheus counter vector keyed by
+// outcome and a histogram for key validation latency.
+type Metrics struct {
+	outcomes *prometheus.CounterVec
+	latency  prometheus.Histogram
+}
+
+// New creates the collectors and registers them with reg.
+// A nil reg registers with prometheus.DefaultRegisterer.
+func New(reg prometheus.Registerer) (*Metrics, error) {
+	if reg == nil {
+		reg = prometheus.DefaultRegisterer
+	}
+	m := &Metrics{
+		outcomes: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "auth_outcomes_total",
+				Help: "Authentication decisions by outcome",
+			},
+			[]string{"outcome"},
+		),
+		latency: prometheus.NewHistogram(
+			prometheus.HistogramOpts{
+				Name:    "auth_key_validation_duration_seconds",
+				Help:    "API key validation latency",
+				Buckets: prometheus.DefBuckets,
+			},
+		),
+	}
+	if err := reg.Register(m.outcomes); err != nil {
+		return nil, err
+	}
+	if err := reg.Register(m.latency); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// IncCounter increments the counter for the given outcome.
+func (m *Metrics) IncCounter(name string) {
+	m.outcomes.WithLabelValues(name).Inc()
+}
+
+// ObserveValidationLatency records a key validation duration.
+func (m *Metrics) ObserveValidationLatency(d time.Duration) {
+	m.latency.Observe(d.Seconds())
+}
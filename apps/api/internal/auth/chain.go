@@ -0,0 +1,270 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yourorg/yourapp/apps/api/internal/auditchain"
+)
+
+// AuditChainVerification reports the result of walking a tenant's audit hash chain.
+type AuditChainVerification struct {
+	Valid    bool   `json:"valid"`
+	Count    int    `json:"count"`
+	BrokenAt *int   `json:"brokenAt,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// VerifyAuditChain recomputes every entry hash with the algorithm the entry
+// recorded, exactly as appendAuditEntry produces it, and checks each PrevHash links to the previous entry.
+// It stops at the first broken index.
+func VerifyAuditChain(entries []AuditLogEntry) AuditChainVerification {
+	return verifyChainRange(entries, 0, len(entries), "")
+}
+
+// verifyChainRange verifies entries[start:end], where prevHash is the hash of
+// entries[start-1] (empty when start is 0). Count is always len(entries).
+func verifyChainRange(entries []AuditLogEntry, start, end int, prevHash string) AuditChainVerification {
+	for i := start; i < end; i++ {
+		entry := entries[i]
+		if entry.PrevHash != prevHash {
+			return brokenChain(len(entries), i, "prevHash does not match previous entry hash")
+		}
+		hash, err := computeEntryHash(&entry)
+		if err != nil || hash != entry.Hash {
+			return brokenChain(len(entries), i, "hash does not match entry contents")
+		}
+		prevHash = entry.Hash
+	}
+	return AuditChainVerification{Valid: true, Count: len(entries)}
+}
+
+func brokenChain(count, index int, reason string) AuditChainVerification {
+	return AuditChainVerification{Valid: false, Count: count, BrokenAt: &index, Reason: reason}
+}
+
+// ChainRepairedAction is the audit action of the meta-entry RepairChainFrom appends.
+const ChainRepairedAction = "chain.repaired"
+
+var (
+	ErrChainRepairDisabled  = errors.New("audit chain repair is not enabled")
+	ErrRepairScopeRequired  = errors.New("audit:repair scope required")
+	ErrRepairReasonRequired = errors.New("repair reason is required")
+	ErrInvalidRepairIndex   = errors.New("fromIndex is not a valid entry index")
+	ErrUnverifiedCheckpoint = errors.New("entries before fromIndex do not verify")
+	ErrPruneBrokenChain     = errors.New("audit chain does not verify; repair it before pruning")
+)
+
+// ChainRepair documents a RepairChainFrom run. It is stored, signed, as the
+// Details of the chain.repaired entry so the repair is itself auditable.
+type ChainRepair struct {
+	FromIndex     int    `json:"fromIndex"`
+	Resealed      int    `json:"resealed"`
+	PriorHeadHash string `json:"priorHeadHash"`
+	ResealedHead  string `json:"resealedHeadHash"`
+	Reason        string `json:"reason"`
+	RepairedBy    string `json:"repairedBy"`
+	Signature     string `json:"signature"`
+}
+
+// EnableChainRepair allows RepairChainFrom, signing repair records with key.
+func (r *InMemoryAuthAuditRecorder) EnableChainRepair(key []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.repairKey = append([]byte(nil), key...)
+}
+
+// SetHashAlgorithm sets the auditchain algorithm new entries, including the
+// chain.repaired and chain.pruned entries this recorder appends, are sealed
+// with. Re-sealed entries keep the algorithm they recorded.
+func (r *InMemoryAuthAuditRecorder) SetHashAlgorithm(alg string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.hashAlg = alg
+}
+
+// HashAlgorithm returns the algorithm new entries are sealed with.
+func (r *InMemoryAuthAuditRecorder) HashAlgorithm() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.sealAlgorithm()
+}
+
+// sealAlgorithm is HashAlgorithm for callers already holding r.mu.
+func (r *InMemoryAuthAuditRecorder) sealAlgorithm() string {
+	if r.hashAlg == "" {
+		return auditchain.SHA256
+	}
+	return r.hashAlg
+}
+
+// RepairChainFrom re-seals tenantID's chain after a benign break (e.g. a storage
+// glitch). Entries before fromIndex must verify and act as the trusted checkpoint;
+// every entry from fromIndex on gets its PrevHash and Hash recomputed, then a signed
+// chain.repaired entry recording the prior head hash is appended. The actor in ctx
+// must hold Scopes.AuditRepair.
+func (r *InMemoryAuthAuditRecorder) RepairChainFrom(ctx context.Context, tenantID, fromIndex string, reason string) (ChainRepair, error) {
+	actor, ok := ActorFromContext(ctx)
+	if !ok || !actor.HasScope(Scopes.AuditRepair) {
+		return ChainRepair{}, ErrRepairScopeRequired
+	}
+	if strings.TrimSpace(reason) == "" {
+		return ChainRepair{}, ErrRepairReasonRequired
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.repairKey) == 0 {
+		return ChainRepair{}, ErrChainRepairDisabled
+	}
+	entries := r.entries[tenantID]
+	from, err := strconv.Atoi(fromIndex)
+	if err != nil || from < 0 || from >= len(entries) {
+		return ChainRepair{}, ErrInvalidRepairIndex
+	}
+	if !VerifyAuditChain(entries[:from]).Valid {
+		return ChainRepair{}, ErrUnverifiedCheckpoint
+	}
+
+	repaired := append([]AuditLogEntry(nil), entries...)
+	prevHash := ""
+	if from > 0 {
+		prevHash = repaired[from-1].Hash
+	}
+	prevHash, err = resealChain(repaired[from:], prevHash)
+	if err != nil {
+		return ChainRepair{}, err
+	}
+
+	repair := ChainRepair{
+		FromIndex:     from,
+		Resealed:      len(repaired) - from,
+		PriorHeadHash: entries[len(entries)-1].Hash,
+		ResealedHead:  prevHash,
+		Reason:        reason,
+		RepairedBy:    actor.KeyID,
+	}
+	repair.Signature = r.signRepair(tenantID, repair)
+	details, err := json.Marshal(repair)
+	if err != nil {
+		return ChainRepair{}, err
+	}
+	meta := AuditLogEntry{
+		ID:        generateID(),
+		TenantID:  tenantID,
+		Action:    ChainRepairedAction,
+		KeyID:     actor.KeyID,
+		Details:   string(details),
+		Timestamp: time.Now().UTC(),
+		PrevHash:  prevHash,
+		HashAlg:   r.sealAlgorithm(),
+	}
+	if meta.Hash, err = computeEntryHash(&meta); err != nil {
+		return ChainRepair{}, err
+	}
+
+	r.entries[tenantID] = append(repaired, meta)
+	r.rewrites[tenantID]++
+	return repair, nil
+}
+
+// resealChain recomputes PrevHash and Hash for every entry, each under its own
+// HashAlg, so entries[0] links to prevHash, and returns the new head hash.
+func resealChain(entries []AuditLogEntry, prevHash string) (string, error) {
+	for i := range entries {
+		entries[i].PrevHash = prevHash
+		hash, err := computeEntryHash(&entries[i])
+		if err != nil {
+			return "", fmt.Errorf("reseal entry %d: %w", i, err)
+		}
+		entries[i].Hash = hash
+		prevHash = hash
+	}
+	return prevHash, nil
+}
+
+// ChainPrunedAction is the audit action of the marker entry Prune appends.
+const ChainPrunedAction = "chain.pruned"
+
+// ChainPrune documents a Prune run. It is stored as the Details of the
+// chain.pruned entry so the cut can be matched against older exports.
+type ChainPrune struct {
+	Before          time.Time `json:"before"`
+	Pruned          int       `json:"pruned"`
+	PriorAnchorHash string    `json:"priorAnchorHash"`
+	PriorHeadHash   string    `json:"priorHeadHash"`
+	Resealed        int       `json:"resealed"`
+}
+
+// Prune drops tenantID's entries recorded before the cutoff and returns how
+// many were removed. The oldest surviving entry becomes the new anchor: its
+// PrevHash is reset to empty and the survivors are re-sealed, so
+// VerifyAuditChain passes from there. A chain.pruned entry recording the hash
+// of the last pruned entry (PriorAnchorHash) and the prior head is appended.
+// A chain that does not verify is left alone with ErrPruneBrokenChain, since
+// re-sealing would erase the evidence of tampering.
+func (r *InMemoryAuthAuditRecorder) Prune(ctx context.Context, tenantID string, before time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entries := r.entries[tenantID]
+	n := 0
+	for n < len(entries) && entries[n].Timestamp.Before(before) {
+		n++
+	}
+	if n == 0 {
+		return 0, nil
+	}
+	if v := VerifyAuditChain(entries); !v.Valid {
+		return 0, fmt.Errorf("%w: broken at %d: %s", ErrPruneBrokenChain, *v.BrokenAt, v.Reason)
+	}
+
+	survivors := append([]AuditLogEntry(nil), entries[n:]...)
+	head, err := resealChain(survivors, "")
+	if err != nil {
+		return 0, err
+	}
+	details, err := json.Marshal(ChainPrune{
+		Before:          before.UTC(),
+		Pruned:          n,
+		PriorAnchorHash: entries[n-1].Hash,
+		PriorHeadHash:   entries[len(entries)-1].Hash,
+		Resealed:        len(survivors),
+	})
+	if err != nil {
+		return 0, err
+	}
+	marker := AuditLogEntry{
+		ID:        generateID(),
+		TenantID:  tenantID,
+		Action:    ChainPrunedAction,
+		Details:   string(details),
+		Timestamp: time.Now().UTC(),
+		PrevHash:  head,
+		HashAlg:   r.sealAlgorithm(),
+	}
+	if marker.Hash, err = computeEntryHash(&marker); err != nil {
+		return 0, err
+	}
+
+	r.entries[tenantID] = append(survivors, marker)
+	r.rewrites[tenantID]++
+	return n, nil
+}
+
+// signRepair returns the hex HMAC-SHA256 of the repair's identifying fields.
+func (r *InMemoryAuthAuditRecorder) signRepair(tenantID string, repair ChainRepair) string {
+	mac := hmac.New(sha256.New, r.repairKey)
+	fmt.Fprintf(mac, "%s|%d|%d|%s|%s|%s|%s", tenantID, repair.FromIndex, repair.Resealed,
+		repair.PriorHeadHash, repair.ResealedHead, repair.Reason, repair.RepairedBy)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -3,7 +3,10 @@ package auth
 import (
 "os"
 "strconv"
+"strings"
 "time"
+
+"github.com/yourorg/yourapp/apps/api/internal/auditchain"
 )
 
 // Config holds authentication-related configuration.
@@ -26,6 +29,81 @@ RateLimitPerMinute int
 KeyCacheTTL time.Duration
 // EnableAuditLog enables authentication audit logging.
 EnableAuditLog bool
+// AuditDualWriteStrict makes DualAuditRecorder fail writes when the secondary recorder fails.
+AuditDualWriteStrict bool
+// JWTHMACSecret enables HS256 bearer tokens when set.
+JWTHMACSecret string
+// JWTRSAPublicKey is a PEM-encoded public key that enables RS256 bearer tokens when set.
+JWTRSAPublicKey string
+// JWTLeeway is the clock skew tolerated when checking exp and nbf.
+JWTLeeway time.Duration
+// TenantIDPattern is the regular expression new tenant ids must match (empty disables it).
+TenantIDPattern string
+// TenantIDMaxLength bounds the length of new tenant ids.
+TenantIDMaxLength int
+// ReservedTenantIDs lists ids that collide with internal namespaces (case-insensitive).
+ReservedTenantIDs []string
+// ExpiryWarnWindow flags keys expiring within this window as expiringSoon.
+ExpiryWarnWindow time.Duration
+// PlatformAdminTenants lists tenants whose admins may read platform-wide audit
+// partitions such as UnattributedTenantID.
+PlatformAdminTenants []string
+// TenantIdempotencyTTL is how long CreateTenant responses are kept for Idempotency-Key replays.
+TenantIdempotencyTTL time.Duration
+// AuditRepairSigningKey enables RepairChainFrom and signs its chain.repaired entries.
+AuditRepairSigningKey string
+// AuditHashAlgorithm seals new audit entries: auditchain.SHA256 or auditchain.SHA512.
+// Existing entries verify with the algorithm they recorded.
+AuditHashAlgorithm string
+// KeyMetadataMaxEntries bounds the number of metadata entries on a key (0 disables the bound).
+KeyMetadataMaxEntries int
+// KeyMetadataMaxKeyLength bounds the length of each metadata key (0 disables the bound).
+KeyMetadataMaxKeyLength int
+// KeyMetadataMaxValueLength bounds the length of each metadata value (0 disables the bound).
+KeyMetadataMaxValueLength int
+// KeyTenantPrefix embeds a tenant discriminator in new keys (ppk_<tenant>_<random>).
+KeyTenantPrefix bool
+// KeyTenantPrefixLength bounds the discriminator (capped at MaxKeyDiscriminatorLength).
+KeyTenantPrefixLength int
+// AuditRateLimits records a structured rate-limit event for each throttled request.
+AuditRateLimits bool
+// StrictAuthScheme accepts only Bearer and ApiKey Authorization schemes instead of
+// treating any other Authorization value as a raw key.
+StrictAuthScheme bool
+// IntegrityCheckInterval is how often IntegrityChecker re-verifies audit chains (0 disables it).
+IntegrityCheckInterval time.Duration
+// IntegrityCheckBatch bounds the entries verified per tenant per pass (0 disables the bound).
+IntegrityCheckBatch int
+// IntegrityAlertWebhook receives failed integrity checks as JSON (empty only logs them).
+IntegrityAlertWebhook string
+// MaxRequestBytes bounds admin request bodies; larger ones get 413 (0 uses DefaultMaxRequestBytes).
+MaxRequestBytes int64
+// APIKeyPrefix starts every generated key, e.g. ppk_live_ (empty uses KeyPrefix).
+APIKeyPrefix string
+// APIKeyEntropyBytes is the random data per generated key (0 uses DefaultKeyConfig).
+APIKeyEntropyBytes int
+// AllowQueryParamKey accepts the API key from the api_key query parameter when
+// no header carries one. Off by default: URLs end up in logs and browser history.
+AllowQueryParamKey bool
+// PlanPolicies maps Tenant.Plan to its default rate limit and scope ceiling;
+// plans without an entry are unrestricted.
+PlanPolicies map[string]PlanPolicy
+// EnableMTLS authenticates requests carrying a verified client certificate by
+// its subject CN, through the resolver given with WithMTLSResolver.
+EnableMTLS bool
+// MTLSIdentities maps client certificate CNs to the tenant and scopes they
+// authenticate as, read from AUTH_MTLS_IDENTITIES as a JSON object.
+MTLSIdentities StaticMTLSResolver
+// MTLSClientCAFile is a PEM bundle of the CAs client certificates must chain to.
+MTLSClientCAFile string
+// KeyExpirySweepInterval is how often KeyExpirySweeper records keys that have
+// expired (0 disables it).
+KeyExpirySweepInterval time.Duration
+// AuditRetention is how long auth audit entries are kept before AuditPruner
+// removes them (0 keeps them forever).
+AuditRetention time.Duration
+// AuditPruneInterval is how often AuditPruner runs when AuditRetention is set.
+AuditPruneInterval time.Duration
 }
 
 // LoadConfig loads auth configuration from environment variables.
@@ -40,7 +118,53 @@ KeyRotationWindow:   getDuration("AUTH_KEY_ROTATION_WINDOW", 24*time.Hour),
 RateLimitPerMinute:  getInt("AUTH_RATE_PER_MIN", 100),
 KeyCacheTTL:         getDuration("AUTH_KEY_CACHE_TTL", 5*time.Minute),
 EnableAuditLog:      getBool("AUTH_ENABLE_AUDIT", true),
+AuditDualWriteStrict: getBool("AUTH_AUDIT_DUAL_WRITE_STRICT", false),
+JWTHMACSecret:       getenv("AUTH_JWT_HS256_SECRET", ""),
+JWTRSAPublicKey:     getenv("AUTH_JWT_RS256_PUBLIC_KEY", ""),
+JWTLeeway:           getDuration("AUTH_JWT_LEEWAY", 30*time.Second),
+TenantIDPattern:     getenv("AUTH_TENANT_ID_PATTERN", DefaultTenantIDPattern),
+TenantIDMaxLength:   getInt("AUTH_TENANT_ID_MAX_LEN", 63),
+ReservedTenantIDs:   getList("AUTH_RESERVED_TENANT_IDS", DefaultReservedTenantIDs),
+ExpiryWarnWindow:    getDuration("AUTH_KEY_EXPIRY_WARN_WINDOW", 7*24*time.Hour),
+PlatformAdminTenants: getList("AUTH_PLATFORM_ADMIN_TENANTS", nil),
+TenantIdempotencyTTL: getDuration("AUTH_TENANT_IDEMPOTENCY_TTL", 24*time.Hour),
+AuditRepairSigningKey: getenv("AUTH_AUDIT_REPAIR_SIGNING_KEY", ""),
+AuditHashAlgorithm: getenv("AUDIT_HASH_ALGORITHM", auditchain.SHA256),
+KeyMetadataMaxEntries: getInt("AUTH_KEY_METADATA_MAX_ENTRIES", 16),
+KeyMetadataMaxKeyLength: getInt("AUTH_KEY_METADATA_MAX_KEY_LEN", 64),
+KeyMetadataMaxValueLength: getInt("AUTH_KEY_METADATA_MAX_VALUE_LEN", 256),
+KeyTenantPrefix: getBool("AUTH_KEY_TENANT_PREFIX", false),
+KeyTenantPrefixLength: getInt("AUTH_KEY_TENANT_PREFIX_LEN", 8),
+AuditRateLimits: getBool("AUTH_AUDIT_RATE_LIMITS", true),
+StrictAuthScheme: getBool("AUTH_STRICT_SCHEME", false),
+IntegrityCheckInterval: getDuration("AUTH_INTEGRITY_CHECK_INTERVAL", 0),
+IntegrityCheckBatch: getInt("AUTH_INTEGRITY_CHECK_BATCH", 10000),
+IntegrityAlertWebhook: getenv("AUTH_INTEGRITY_ALERT_WEBHOOK", ""),
+MaxRequestBytes: int64(getInt("AUTH_MAX_REQUEST_BYTES", DefaultMaxRequestBytes)),
+APIKeyPrefix: getenv("AUTH_KEY_PREFIX", KeyPrefix),
+APIKeyEntropyBytes: getInt("AUTH_KEY_ENTROPY_BYTES", DefaultKeyConfig.EntropyBytes),
+AllowQueryParamKey: getBool("AUTH_ALLOW_QUERY_PARAM_KEY", false),
+PlanPolicies: getPlanPolicies("AUTH_PLAN_POLICIES", DefaultPlanPolicies),
+EnableMTLS: getBool("AUTH_ENABLE_MTLS", false),
+MTLSIdentities: getMTLSIdentities("AUTH_MTLS_IDENTITIES"),
+MTLSClientCAFile: getenv("AUTH_MTLS_CLIENT_CA", ""),
+KeyExpirySweepInterval: getDuration("AUTH_KEY_EXPIRY_SWEEP_INTERVAL", 0),
+AuditRetention: getDuration("AUTH_AUDIT_RETENTION", 0),
+AuditPruneInterval: getDuration("AUTH_AUDIT_PRUNE_INTERVAL", time.Hour),
+}
+}
+
+// KeyConfig returns the generated key layout, filling unset fields from
+// DefaultKeyConfig. Callers check it with KeyConfig.Validate at startup.
+func (c Config) KeyConfig() KeyConfig {
+kc := DefaultKeyConfig
+if c.APIKeyPrefix != "" {
+kc.Prefix = c.APIKeyPrefix
+}
+if c.APIKeyEntropyBytes != 0 {
+kc.EntropyBytes = c.APIKeyEntropyBytes
 }
+return kc
 }
 
 func getenv(key, def string) string {
@@ -68,6 +192,20 @@ return d
 return def
 }
 
+func getList(key string, def []string) []string {
+v, ok := os.LookupEnv(key)
+if !ok {
+return def
+}
+var out []string
+for _, p := range strings.Split(v, ",") {
+if p = strings.TrimSpace(p); p != "" {
+out = append(out, p)
+}
+}
+return out
+}
+
 func getBool(key string, def bool) bool {
 if v, ok := os.LookupEnv(key); ok {
 if parsed, err := strconv.ParseBool(v); err == nil {
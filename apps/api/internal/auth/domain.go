@@ -11,6 +11,10 @@ type TenantContextKey struct{}
 // ActorContextKey is the context key for authenticated actor.
 type ActorContextKey struct{}
 
+// UnattributedTenantID is the audit partition for auth failures that cannot be
+// tied to a tenant (missing or unknown keys). It can never be a real tenant id.
+const UnattributedTenantID = "_unattributed"
+
 // Tenant represents a tenant with its associated metadata.
 type Tenant struct {
 ID        string    `json:"id"`
@@ -29,12 +33,15 @@ KeyPrefix   string    `json:"keyPrefix"` // First 8 chars for identification
 KeyHash     string    `json:"-"` // Hashed key (never exposed)
 Scopes      []string  `json:"scopes"` // e.g., ["audit:read", "audit:write"]
 RateLimit   int       `json:"rateLimit"` // Per-minute rate limit (0 = default)
+Exempt      bool      `json:"exempt"` // Internal/service key that bypasses rate limiting
 ExpiresAt   *time.Time `json:"expiresAt,omitempty"`
 LastUsedAt  *time.Time `json:"lastUsedAt,omitempty"`
 CreatedAt   time.Time `json:"createdAt"`
 RevokedAt   *time.Time `json:"revokedAt,omitempty"`
 Rotated     bool      `json:"rotated"` // True if this key was rotated (old key in grace period)
 RotatedFrom *string   `json:"rotatedFrom,omitempty"` // ID of the previous key
+Metadata    map[string]string `json:"metadata,omitempty"` // Integrator tags, e.g. env, owner, pipeline id
+Tags        map[string]string `json:"tags,omitempty"` // Grouping labels, e.g. env:staging, bounded by ValidateKeyTags
 }
 
 // Actor represents the authenticated entity making a request.
@@ -42,8 +49,10 @@ type Actor struct {
 TenantID   string   `json:"tenantId"`
 KeyID      string   `json:"keyId"`
 KeyName    string   `json:"keyName"`
+Subject    string   `json:"subject,omitempty"` // JWT sub claim for "user" actors
 Scopes     []string `json:"scopes"`
-ActorType  string   `json:"actorType"` // "api_key" or "user" (future)
+ActorType  string   `json:"actorType"` // "api_key", "user" (JWT) or "mtls"
+RateLimit  int      `json:"rateLimit,omitempty"` // Key's per-minute rate limit (0 = default)
 }
 
 // AuditLogEntry represents an authentication-related audit log entry.
@@ -59,6 +68,29 @@ Details   string    `json:"details,omitempty"`
 Timestamp time.Time `json:"timestamp"`
 PrevHash  string    `json:"prevHash"` // Hash chain for tamper detection
 Hash      string    `json:"hash"`
+HashAlg   string    `json:"hashAlg,omitempty"` // auditchain algorithm of Hash; empty for legacy JSON-encoded entries
+}
+
+// Paging bounds for ListKeysPage.
+const (
+DefaultListKeysLimit = 20
+MaxListKeysLimit     = 100
+)
+
+// ListKeysOptions controls paging and filtering for ListKeysPage.
+type ListKeysOptions struct {
+Limit          int    // Page size (<= 0 uses DefaultListKeysLimit, capped at MaxListKeysLimit)
+Cursor         string // Opaque cursor from a previous page's NextCursor
+IncludeRevoked bool   // Include revoked and rotated keys
+MetadataKey    string // Only keys carrying this metadata key
+MetadataValue  string // With MetadataKey, only keys whose value matches exactly
+Tags           map[string]string // Only keys carrying all of these tags
+}
+
+// KeyPage is a single page of API keys.
+type KeyPage struct {
+Keys       []APIKey
+NextCursor string // Empty when there are no more pages
 }
 
 // APIKeyStore defines the interface for API key persistence.
@@ -71,8 +103,12 @@ CreateKey(ctx context.Context, tenantID string, name string, scopes []string, ex
 RotateKey(ctx context.Context, oldKeyID string) (*APIKey, string, error)
 // RevokeKey immediately revokes an API key.
 RevokeKey(ctx context.Context, keyID string) error
+// RevokeTenantKeys revokes all active keys of a tenant except exceptKeyID.
+RevokeTenantKeys(ctx context.Context, tenantID, exceptKeyID string) (int, error)
 // ListKeys returns all keys for a tenant.
 ListKeys(ctx context.Context, tenantID string) ([]APIKey, error)
+// ListKeysPage returns one page of a tenant's keys sorted by CreatedAt descending.
+ListKeysPage(ctx context.Context, tenantID string, opts ListKeysOptions) (KeyPage, error)
 // UpdateLastUsed updates the last used timestamp (async-safe).
 UpdateLastUsed(ctx context.Context, keyID string) error
 }
@@ -85,6 +121,8 @@ GetTenant(ctx context.Context, tenantID string) (*Tenant, error)
 CreateTenant(ctx context.Context, tenant Tenant) error
 // UpdateTenantStatus updates tenant status (e.g., suspend).
 UpdateTenantStatus(ctx context.Context, tenantID, status string) error
+// DeleteTenant removes a tenant together with all of its keys.
+DeleteTenant(ctx context.Context, tenantID string) error
 }
 
 // AuthAuditRecorder records authentication audit events.
@@ -103,6 +141,9 @@ InvoiceRead  string
 InvoiceWrite string
 AdminRead    string
 AdminWrite   string
+// AuditRepair permits re-sealing a broken audit chain. It is deliberately not
+// part of AllScopes and only platform admins may grant it.
+AuditRepair  string
 }{
 AuditRead:    "audit:read",
 AuditWrite:   "audit:write",
@@ -110,6 +151,7 @@ InvoiceRead:  "invoice:read",
 InvoiceWrite: "invoice:write",
 AdminRead:    "admin:read",
 AdminWrite:   "admin:write",
+AuditRepair:  "audit:repair",
 }
 
 // AllScopes returns all available scopes.
@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// DualAuditRecorder writes audit entries to a primary and a secondary recorder so
+// a durable backend can be backfilled and cut over to without downtime. The
+// primary is authoritative: Last and therefore chain continuity always come from
+// it, and the secondary only mirrors what the primary accepted.
+type DualAuditRecorder struct {
+	primary   AuthAuditRecorder
+	secondary AuthAuditRecorder
+	strict    bool
+	logger    *slog.Logger
+}
+
+// NewDualAuditRecorder creates a recorder mirroring primary writes to secondary.
+// Secondary failures are logged and ignored unless cfg.AuditDualWriteStrict is set.
+func NewDualAuditRecorder(primary, secondary AuthAuditRecorder, cfg Config, logger *slog.Logger) *DualAuditRecorder {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &DualAuditRecorder{
+		primary:   primary,
+		secondary: secondary,
+		strict:    cfg.AuditDualWriteStrict,
+		logger:    logger,
+	}
+}
+
+// Record appends the entry to the primary, then mirrors it to the secondary.
+func (d *DualAuditRecorder) Record(ctx context.Context, entry AuditLogEntry) error {
+	if err := d.primary.Record(ctx, entry); err != nil {
+		return err
+	}
+	if err := d.secondary.Record(ctx, entry); err != nil {
+		d.logger.Warn("secondary audit recorder write failed",
+			slog.String("tenantId", entry.TenantID),
+			slog.String("entryId", entry.ID),
+			slog.String("error", err.Error()),
+		)
+		if d.strict {
+			return fmt.Errorf("secondary audit recorder: %w", err)
+		}
+	}
+	return nil
+}
+
+// Last returns the primary's last entry, which anchors the hash chain.
+func (d *DualAuditRecorder) Last(ctx context.Context, tenantID string) (AuditLogEntry, error) {
+	return d.primary.Last(ctx, tenantID)
+}
+
+// HashAlgorithm returns the primary's algorithm, since the primary's chain is
+// the one new entries extend.
+func (d *DualAuditRecorder) HashAlgorithm() string {
+	return auditHashAlgorithm(d.primary)
+}
@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// failingRecorder rejects every write.
+type failingRecorder struct{}
+
+func (failingRecorder) Record(ctx context.Context, entry AuditLogEntry) error {
+	return errors.New("backend unavailable")
+}
+
+func (failingRecorder) Last(ctx context.Context, tenantID string) (AuditLogEntry, error) {
+	return AuditLogEntry{}, errors.New("backend unavailable")
+}
+
+// TestDualAuditRecorder_MirrorsEntries tests that entries land in both recorders.
+func TestDualAuditRecorder_MirrorsEntries(t *testing.T) {
+	primary := NewInMemoryAuthAuditRecorder()
+	secondary := NewInMemoryAuthAuditRecorder()
+	dual := NewDualAuditRecorder(primary, secondary, Config{}, nil)
+	ctx := context.Background()
+
+	for _, id := range []string{"e1", "e2"} {
+		if err := dual.Record(ctx, AuditLogEntry{ID: id, TenantID: "test-tenant", Timestamp: time.Now().UTC()}); err != nil {
+			t.Fatalf("Record() error = %v", err)
+		}
+	}
+
+	if got := len(primary.GetEntries("test-tenant")); got != 2 {
+		t.Errorf("primary has %d entries, want 2", got)
+	}
+	if got := len(secondary.GetEntries("test-tenant")); got != 2 {
+		t.Errorf("secondary has %d entries, want 2", got)
+	}
+	last, err := dual.Last(ctx, "test-tenant")
+	if err != nil || last.ID != "e2" {
+		t.Errorf("Last() = %q, %v, want e2", last.ID, err)
+	}
+}
+
+// TestDualAuditRecorder_SecondaryFailure tests that a failing secondary leaves the primary chain intact.
+func TestDualAuditRecorder_SecondaryFailure(t *testing.T) {
+	cfg := Config{APIKeyHashAlgorithm: "bcrypt", BcryptCost: 10, EnableAuditLog: true}
+	store := NewInMemoryAPIKeyStore(cfg)
+	ctx := context.Background()
+	_ = store.CreateTenant(ctx, Tenant{ID: "test-tenant", Name: "Test", Plan: "pro", Status: "active", CreatedAt: time.Now().UTC()})
+	_, rawKey, err := store.CreateKey(ctx, "test-tenant", "Test Key", []string{"*"}, nil)
+	if err != nil {
+		t.Fatalf("CreateKey() error = %v", err)
+	}
+
+	primary := NewInMemoryAuthAuditRecorder()
+	dual := NewDualAuditRecorder(primary, failingRecorder{}, cfg, nil)
+	handler := Middleware(store, dual, cfg, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		req.Header.Set("Authorization", "Bearer "+rawKey)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("request %d: expected status %d, got %d", i, http.StatusOK, rec.Code)
+		}
+	}
+
+	result := VerifyAuditChain(primary.GetEntries("test-tenant"))
+	if !result.Valid || result.Count != 3 {
+		t.Errorf("expected valid primary chain of 3 entries, got %+v", result)
+	}
+}
+
+// TestDualAuditRecorder_Strict tests that strict mode surfaces secondary failures.
+func TestDualAuditRecorder_Strict(t *testing.T) {
+	primary := NewInMemoryAuthAuditRecorder()
+	dual := NewDualAuditRecorder(primary, failingRecorder{}, Config{AuditDualWriteStrict: true}, nil)
+
+	if err := dual.Record(context.Background(), AuditLogEntry{ID: "e1", TenantID: "test-tenant"}); err == nil {
+		t.Fatal("expected error from strict dual write")
+	}
+	if got := len(primary.GetEntries("test-tenant")); got != 1 {
+		t.Errorf("primary has %d entries, want 1", got)
+	}
+}
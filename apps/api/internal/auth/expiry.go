@@ -0,0 +1,99 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// ActionKeyAutoExpired is recorded by KeyExpirySweeper when a key's expiry passes.
+const ActionKeyAutoExpired = "auth.key_auto_expired"
+
+// ExpirySource lists keys whose expiry fell within a time window.
+// InMemoryAPIKeyStore implements it.
+type ExpirySource interface {
+	KeysExpiredBetween(after, upTo time.Time) []APIKey
+}
+
+// KeyExpirySweeper records an auth.key_auto_expired audit entry for each key
+// whose ExpiresAt has passed, so there is a trail of when a key stopped
+// working. Each pass covers the window since the previous one, so a key is
+// recorded once; keys that expired before the sweeper was created are not.
+type KeyExpirySweeper struct {
+	source ExpirySource
+	audit  AuthAuditRecorder
+	every  time.Duration
+	logger *slog.Logger
+	now    func() time.Time
+
+	mu        sync.Mutex
+	lastSweep time.Time
+}
+
+// NewKeyExpirySweeper creates a sweeper over source that writes to audit.
+func NewKeyExpirySweeper(source ExpirySource, audit AuthAuditRecorder, cfg Config, logger *slog.Logger) *KeyExpirySweeper {
+	return newKeyExpirySweeper(source, audit, cfg, logger, time.Now)
+}
+
+func newKeyExpirySweeper(source ExpirySource, audit AuthAuditRecorder, cfg Config, logger *slog.Logger, now func() time.Time) *KeyExpirySweeper {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &KeyExpirySweeper{
+		source:    source,
+		audit:     audit,
+		every:     cfg.KeyExpirySweepInterval,
+		logger:    logger,
+		now:       now,
+		lastSweep: now().UTC(),
+	}
+}
+
+// Start runs Sweep every cfg.KeyExpirySweepInterval until ctx is done.
+func (s *KeyExpirySweeper) Start(ctx context.Context) {
+	if s.every <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(s.every)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.Sweep(ctx)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Sweep records every key that expired since the previous pass and returns
+// how many were recorded.
+func (s *KeyExpirySweeper) Sweep(ctx context.Context) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now().UTC()
+	expired := s.source.KeysExpiredBetween(s.lastSweep, now)
+	s.lastSweep = now
+	for _, key := range expired {
+		details := fmt.Sprintf("key %s expired at %s", key.ID, key.ExpiresAt.UTC().Format(time.RFC3339))
+		if key.Rotated {
+			details = fmt.Sprintf("rotated key %s grace period ended at %s", key.ID, key.ExpiresAt.UTC().Format(time.RFC3339))
+		}
+		if s.audit != nil {
+			appendAuditEntry(ctx, s.audit, AuditLogEntry{
+				TenantID:  key.TenantID,
+				Action:    ActionKeyAutoExpired,
+				KeyID:     key.ID,
+				Details:   details,
+				Timestamp: now,
+			})
+		}
+		s.logger.Info("api key expired", slog.String("tenantId", key.TenantID), slog.String("keyId", key.ID))
+	}
+	return len(expired)
+}
@@ -0,0 +1,54 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestKeyExpirySweeper_RecordsExpiryOnce tests that a key's expiry is recorded
+// exactly once, on the first sweep after it passes.
+func TestKeyExpirySweeper_RecordsExpiryOnce(t *testing.T) {
+	_, store, audit, cfg := newTestHandler(t)
+	ctx := context.Background()
+
+	now := time.Now().UTC()
+	clock := func() time.Time { return now }
+	expiresAt := now.Add(time.Hour)
+	key, _, err := store.CreateKey(ctx, "test-tenant", "expiring", []string{Scopes.AuditRead}, &expiresAt)
+	if err != nil {
+		t.Fatalf("CreateKey() error = %v", err)
+	}
+	sweeper := newKeyExpirySweeper(store, audit, cfg, nil, clock)
+
+	countExpired := func() int {
+		n := 0
+		for _, entry := range audit.GetEntries("test-tenant") {
+			if entry.Action == ActionKeyAutoExpired {
+				if entry.KeyID != key.ID {
+					t.Fatalf("expected expiry entry for %s, got %+v", key.ID, entry)
+				}
+				n++
+			}
+		}
+		return n
+	}
+
+	now = now.Add(30 * time.Minute)
+	if n := sweeper.Sweep(ctx); n != 0 || countExpired() != 0 {
+		t.Fatalf("expected nothing recorded before expiry, got %d", n)
+	}
+
+	now = now.Add(time.Hour)
+	if n := sweeper.Sweep(ctx); n != 1 {
+		t.Fatalf("expected 1 key recorded after expiry, got %d", n)
+	}
+	now = now.Add(time.Hour)
+	sweeper.Sweep(ctx)
+	if got := countExpired(); got != 1 {
+		t.Fatalf("expected exactly one %s entry, got %d", ActionKeyAutoExpired, got)
+	}
+	if v := VerifyAuditChain(audit.GetEntries("test-tenant")); !v.Valid {
+		t.Fatalf("expected the expiry entry to extend a valid chain, got %+v", v)
+	}
+}
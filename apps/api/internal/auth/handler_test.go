@@ -0,0 +1,1508 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yourorg/yourapp/apps/api/internal/auditchain"
+)
+
+// newTestHandler creates a handler backed by in-memory stores with one active tenant.
+func newTestHandler(t *testing.T) (*Handler, *InMemoryAPIKeyStore, *InMemoryAuthAuditRecorder, Config) {
+	t.Helper()
+	cfg := Config{
+		APIKeyHashAlgorithm: "bcrypt",
+		BcryptCost:          10,
+		KeyRotationWindow:   24 * time.Hour,
+		EnableAuditLog:      true,
+	}
+	store := NewInMemoryAPIKeyStore(cfg)
+	audit := NewInMemoryAuthAuditRecorder()
+
+	tenant := Tenant{
+		ID:        "test-tenant",
+		Name:      "Test Tenant",
+		Plan:      "pro",
+		Status:    "active",
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := store.CreateTenant(context.Background(), tenant); err != nil {
+		t.Fatalf("CreateTenant() error = %v", err)
+	}
+	return NewHandler(store, audit, cfg, nil), store, audit, cfg
+}
+
+// withActor returns a request carrying an authenticated actor in its context.
+func withActor(req *http.Request, tenantID string, scopes ...string) *http.Request {
+	actor := &Actor{TenantID: tenantID, KeyID: "test-key", KeyName: "Test Key", Scopes: scopes, ActorType: "api_key"}
+	return req.WithContext(ContextWithActor(req.Context(), actor))
+}
+
+// TestVerifyAuditChain_Valid tests that an untouched chain verifies.
+func TestVerifyAuditChain_Valid(t *testing.T) {
+	h, store, audit, cfg := newTestHandler(t)
+	_, rawKey, err := store.CreateKey(context.Background(), "test-tenant", "Test Key", []string{"*"}, nil)
+	if err != nil {
+		t.Fatalf("CreateKey() error = %v", err)
+	}
+
+	middleware := Middleware(store, audit, cfg, nil)
+	handler := middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		req.Header.Set("Authorization", "Bearer "+rawKey)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	req := withActor(httptest.NewRequest(http.MethodGet, "/auth/audit/verify", nil), "test-tenant", Scopes.AdminRead)
+	rec := httptest.NewRecorder()
+	h.VerifyAuditChain(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	var result AuditChainVerification
+	if err := json.NewDecoder(rec.Body).Decode(&result); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if !result.Valid || result.Count != 3 {
+		t.Errorf("expected valid chain of 3 entries, got %+v", result)
+	}
+}
+
+// TestVerifyAuditChain_CorruptedAction tests that tampering with an entry is reported at its index.
+func TestVerifyAuditChain_CorruptedAction(t *testing.T) {
+	h, store, audit, cfg := newTestHandler(t)
+	_, rawKey, err := store.CreateKey(context.Background(), "test-tenant", "Test Key", []string{"*"}, nil)
+	if err != nil {
+		t.Fatalf("CreateKey() error = %v", err)
+	}
+
+	middleware := Middleware(store, audit, cfg, nil)
+	handler := middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	for i := 0; i < 4; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		req.Header.Set("Authorization", "Bearer "+rawKey)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	// Tamper with the third entry
+	audit.mu.Lock()
+	audit.entries["test-tenant"][2].Action = "auth.tampered"
+	audit.mu.Unlock()
+
+	req := withActor(httptest.NewRequest(http.MethodGet, "/auth/audit/verify", nil), "test-tenant", Scopes.AdminRead)
+	rec := httptest.NewRecorder()
+	h.VerifyAuditChain(rec, req)
+
+	var result AuditChainVerification
+	if err := json.NewDecoder(rec.Body).Decode(&result); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if result.Valid {
+		t.Fatal("expected chain to be reported as broken")
+	}
+	if result.BrokenAt == nil || *result.BrokenAt != 2 {
+		t.Errorf("expected break at index 2, got %+v", result.BrokenAt)
+	}
+	if result.Count != 4 {
+		t.Errorf("expected count 4, got %d", result.Count)
+	}
+}
+
+// TestVerifyAuditChain_HonoursRecordedAlgorithm tests that entries are sealed
+// with the recorder's algorithm and verify, and are re-sealed, under the algorithm
+// each one recorded, including legacy entries recorded without one.
+func TestVerifyAuditChain_HonoursRecordedAlgorithm(t *testing.T) {
+	_, store, audit, cfg := newTestHandler(t)
+	legacy := AuditLogEntry{ID: "legacy", TenantID: "test-tenant", Action: "auth.success", Timestamp: time.Now().UTC()}
+	legacy.Hash, _ = computeEntryHash(&legacy)
+	_ = audit.Record(context.Background(), legacy)
+
+	audit.SetHashAlgorithm(auditchain.SHA512)
+	audit.EnableChainRepair([]byte("repair-secret"))
+	h := NewHandler(store, audit, cfg, nil)
+	seedAuditChain(t, store, audit, cfg, 2)
+
+	entries := audit.GetEntries("test-tenant")
+	if entries[0].HashAlg != "" || entries[1].HashAlg != auditchain.SHA512 || entries[2].HashAlg != auditchain.SHA512 {
+		t.Fatalf("expected legacy then sha512 entries, got %q %q %q", entries[0].HashAlg, entries[1].HashAlg, entries[2].HashAlg)
+	}
+	if len(entries[1].Hash) != 128 {
+		t.Errorf("expected a sha512 hex hash, got %q", entries[1].Hash)
+	}
+	if result := VerifyAuditChain(entries); !result.Valid {
+		t.Fatalf("expected mixed-algorithm chain to verify, got %+v", result)
+	}
+
+	// Relabelling an entry's algorithm must break the chain rather than
+	// let it verify under a weaker or unknown encoding.
+	audit.mu.Lock()
+	audit.entries["test-tenant"][2].HashAlg = auditchain.SHA256
+	audit.mu.Unlock()
+	if result := VerifyAuditChain(audit.GetEntries("test-tenant")); result.Valid || *result.BrokenAt != 2 {
+		t.Fatalf("expected break at index 2, got %+v", result)
+	}
+
+	audit.mu.Lock()
+	audit.entries["test-tenant"][2].HashAlg = auditchain.SHA512
+	audit.entries["test-tenant"][1].Hash = "garbled"
+	audit.mu.Unlock()
+	if rec := repairChain(h, `{"fromIndex":"1","reason":"storage glitch"}`, Scopes.AuditRepair); rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	after := audit.GetEntries("test-tenant")
+	if result := VerifyAuditChain(after); !result.Valid {
+		t.Fatalf("expected repaired chain to verify, got %+v", result)
+	}
+	if after[1].HashAlg != auditchain.SHA512 || after[len(after)-1].HashAlg != auditchain.SHA512 {
+		t.Errorf("expected re-sealed and chain.repaired entries to use sha512, got %q and %q", after[1].HashAlg, after[len(after)-1].HashAlg)
+	}
+}
+
+// seedAuditChain authenticates n requests so test-tenant has n chained audit entries.
+func seedAuditChain(t *testing.T, store *InMemoryAPIKeyStore, audit *InMemoryAuthAuditRecorder, cfg Config, n int) {
+	t.Helper()
+	_, rawKey, err := store.CreateKey(context.Background(), "test-tenant", "Test Key", []string{"*"}, nil)
+	if err != nil {
+		t.Fatalf("CreateKey() error = %v", err)
+	}
+	handler := Middleware(store, audit, cfg, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	for i := 0; i < n; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		req.Header.Set("Authorization", "Bearer "+rawKey)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+}
+
+// repairChain calls RepairAuditChain as test-tenant with the given scopes.
+func repairChain(h *Handler, body string, scopes ...string) *httptest.ResponseRecorder {
+	req := withActor(httptest.NewRequest(http.MethodPost, "/auth/audit/repair", strings.NewReader(body)), "test-tenant", scopes...)
+	rec := httptest.NewRecorder()
+	h.RepairAuditChain(rec, req)
+	return rec
+}
+
+// TestRepairAuditChain_ResealsBenignBreak tests that a repair re-seals the chain
+// and records the prior head hash in a chain.repaired entry.
+func TestRepairAuditChain_ResealsBenignBreak(t *testing.T) {
+	h, store, audit, cfg := newTestHandler(t)
+	audit.EnableChainRepair([]byte("repair-secret"))
+	seedAuditChain(t, store, audit, cfg, 4)
+
+	// Simulate a storage glitch that corrupted entry 1's stored hash.
+	audit.mu.Lock()
+	audit.entries["test-tenant"][1].Hash = "garbled"
+	audit.mu.Unlock()
+	before := audit.GetEntries("test-tenant")
+	if result := VerifyAuditChain(before); result.Valid || *result.BrokenAt != 1 {
+		t.Fatalf("expected break at index 1, got %+v", result)
+	}
+	priorHead := before[len(before)-1].Hash
+
+	rec := repairChain(h, `{"fromIndex":"1","reason":"storage glitch INC-42"}`, Scopes.AuditRepair)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+
+	after := audit.GetEntries("test-tenant")
+	if result := VerifyAuditChain(after); !result.Valid {
+		t.Fatalf("expected repaired chain to verify, got %+v", result)
+	}
+	if len(after) != len(before)+1 {
+		t.Fatalf("expected one meta-entry appended, got %d entries", len(after))
+	}
+	if after[0].Hash != before[0].Hash {
+		t.Error("expected entries before the checkpoint to be untouched")
+	}
+
+	meta := after[len(after)-1]
+	if meta.Action != ChainRepairedAction {
+		t.Fatalf("expected %s entry, got %s", ChainRepairedAction, meta.Action)
+	}
+	var repair ChainRepair
+	if err := json.Unmarshal([]byte(meta.Details), &repair); err != nil {
+		t.Fatalf("failed to decode repair details: %v", err)
+	}
+	if repair.PriorHeadHash != priorHead {
+		t.Errorf("expected prior head hash %s, got %s", priorHead, repair.PriorHeadHash)
+	}
+	if repair.FromIndex != 1 || repair.Resealed != 3 || repair.Reason != "storage glitch INC-42" {
+		t.Errorf("unexpected repair record %+v", repair)
+	}
+	if repair.Signature == "" || repair.Signature != audit.signRepair("test-tenant", ChainRepair{
+		FromIndex: 1, Resealed: 3, PriorHeadHash: priorHead, ResealedHead: meta.PrevHash,
+		Reason: repair.Reason, RepairedBy: repair.RepairedBy,
+	}) {
+		t.Error("expected repair record to carry a valid signature")
+	}
+}
+
+// TestRepairAuditChain_Guards tests the scope, enablement, and checkpoint guards.
+func TestRepairAuditChain_Guards(t *testing.T) {
+	h, store, audit, cfg := newTestHandler(t)
+	seedAuditChain(t, store, audit, cfg, 3)
+
+	if rec := repairChain(h, `{"fromIndex":"1","reason":"x"}`, Scopes.AuditRepair); rec.Code != http.StatusForbidden {
+		t.Errorf("expected %d when repair is not enabled, got %d", http.StatusForbidden, rec.Code)
+	}
+
+	audit.EnableChainRepair([]byte("repair-secret"))
+	if rec := repairChain(h, `{"fromIndex":"1","reason":"x"}`, Scopes.AdminWrite); rec.Code != http.StatusForbidden {
+		t.Errorf("expected %d without audit:repair, got %d", http.StatusForbidden, rec.Code)
+	}
+	if rec := repairChain(h, `{"fromIndex":"9","reason":"x"}`, Scopes.AuditRepair); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected %d for out-of-range index, got %d", http.StatusBadRequest, rec.Code)
+	}
+	if rec := repairChain(h, `{"fromIndex":"1"}`, Scopes.AuditRepair); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected %d without a reason, got %d", http.StatusBadRequest, rec.Code)
+	}
+
+	// A checkpoint that does not itself verify cannot anchor a repair.
+	audit.mu.Lock()
+	audit.entries["test-tenant"][0].Action = "auth.tampered"
+	audit.mu.Unlock()
+	if rec := repairChain(h, `{"fromIndex":"2","reason":"x"}`, Scopes.AuditRepair); rec.Code != http.StatusConflict {
+		t.Errorf("expected %d for unverified checkpoint, got %d", http.StatusConflict, rec.Code)
+	}
+}
+
+// TestVerifyAuditChain_RequiresAdminRead tests the scope guard.
+func TestVerifyAuditChain_RequiresAdminRead(t *testing.T) {
+	h, _, _, _ := newTestHandler(t)
+
+	req := withActor(httptest.NewRequest(http.MethodGet, "/auth/audit/verify", nil), "test-tenant", Scopes.AuditRead)
+	rec := httptest.NewRecorder()
+	h.VerifyAuditChain(rec, req)
+
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("expected status %d, got %d", http.StatusForbidden, rec.Code)
+	}
+}
+
+// listKeysPage calls ListAPIKeys with the given query and decodes the response.
+func listKeysPage(t *testing.T, h *Handler, query string) ListAPIKeysResponse {
+	t.Helper()
+	req := withActor(httptest.NewRequest(http.MethodGet, "/auth/keys"+query, nil), "test-tenant", Scopes.AdminRead)
+	rec := httptest.NewRecorder()
+	h.ListAPIKeys(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	var resp ListAPIKeysResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	return resp
+}
+
+// TestListAPIKeys_Pagination tests paging through 25 keys with limit 10.
+func TestListAPIKeys_Pagination(t *testing.T) {
+	h, store, _, _ := newTestHandler(t)
+	for i := 0; i < 25; i++ {
+		if _, _, err := store.CreateKey(context.Background(), "test-tenant", "Key", []string{"audit:read"}, nil); err != nil {
+			t.Fatalf("CreateKey() error = %v", err)
+		}
+	}
+
+	seen := map[string]bool{}
+	var sizes []int
+	var prev *APIKeyInfo
+	cursor := ""
+	for page := 0; page < 5; page++ {
+		query := "?limit=10"
+		if cursor != "" {
+			query += "&cursor=" + cursor
+		}
+		resp := listKeysPage(t, h, query)
+		sizes = append(sizes, len(resp.Keys))
+		for i := range resp.Keys {
+			k := resp.Keys[i]
+			if seen[k.ID] {
+				t.Fatalf("key %s returned twice", k.ID)
+			}
+			seen[k.ID] = true
+			if prev != nil && k.CreatedAt.After(prev.CreatedAt) {
+				t.Fatalf("keys not sorted by createdAt descending")
+			}
+			prev = &k
+		}
+		cursor = resp.NextCursor
+		if cursor == "" {
+			break
+		}
+	}
+
+	if len(sizes) != 3 || sizes[0] != 10 || sizes[1] != 10 || sizes[2] != 5 {
+		t.Errorf("expected pages of 10, 10, 5, got %v", sizes)
+	}
+	if len(seen) != 25 {
+		t.Errorf("expected 25 distinct keys, got %d", len(seen))
+	}
+}
+
+// TestListAPIKeys_ExcludesRevokedAndRotated tests the includeRevoked filter.
+func TestListAPIKeys_ExcludesRevokedAndRotated(t *testing.T) {
+	h, store, _, _ := newTestHandler(t)
+	ctx := context.Background()
+	revoked, _, _ := store.CreateKey(ctx, "test-tenant", "Revoked", []string{"audit:read"}, nil)
+	rotated, _, _ := store.CreateKey(ctx, "test-tenant", "Rotated", []string{"audit:read"}, nil)
+	if err := store.RevokeKey(ctx, revoked.ID); err != nil {
+		t.Fatalf("RevokeKey() error = %v", err)
+	}
+	if _, _, err := store.RotateKey(ctx, rotated.ID); err != nil {
+		t.Fatalf("RotateKey() error = %v", err)
+	}
+
+	resp := listKeysPage(t, h, "")
+	if len(resp.Keys) != 1 || resp.Keys[0].Name != "Rotated (rotated)" {
+		t.Errorf("expected only the replacement key, got %+v", resp.Keys)
+	}
+
+	resp = listKeysPage(t, h, "?includeRevoked=true")
+	if len(resp.Keys) != 3 {
+		t.Errorf("expected 3 keys with includeRevoked, got %d", len(resp.Keys))
+	}
+}
+
+// createKeyWithBody posts body to CreateAPIKey and returns the recorder.
+func createKeyWithBody(t *testing.T, h *Handler, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := withActor(httptest.NewRequest(http.MethodPost, "/auth/keys", strings.NewReader(body)), "test-tenant", AllScopes()...)
+	rec := httptest.NewRecorder()
+	h.CreateAPIKey(rec, req)
+	return rec
+}
+
+// TestCreateAPIKey_MetadataRoundTrips tests that metadata set at creation is
+// returned by create and list, and survives rotation.
+func TestCreateAPIKey_MetadataRoundTrips(t *testing.T) {
+	h, store, _, _ := newTestHandler(t)
+	rec := createKeyWithBody(t, h, `{"name":"CI","scopes":["audit:read"],"metadata":{"env":"prod","owner":"ops@example.com","pipeline":"1234"}}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, rec.Code, rec.Body.String())
+	}
+	var created CreateAPIKeyResponse
+	if err := json.NewDecoder(rec.Body).Decode(&created); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	want := map[string]string{"env": "prod", "owner": "ops@example.com", "pipeline": "1234"}
+	if !reflect.DeepEqual(created.Key.Metadata, want) {
+		t.Errorf("create metadata = %v, want %v", created.Key.Metadata, want)
+	}
+
+	resp := listKeysPage(t, h, "")
+	if len(resp.Keys) != 1 || !reflect.DeepEqual(resp.Keys[0].Metadata, want) {
+		t.Fatalf("list metadata = %+v, want %v", resp.Keys, want)
+	}
+
+	rotated, _, err := store.RotateKey(context.Background(), created.Key.ID)
+	if err != nil {
+		t.Fatalf("RotateKey() error = %v", err)
+	}
+	if !reflect.DeepEqual(rotated.Metadata, want) {
+		t.Errorf("rotated metadata = %v, want %v", rotated.Metadata, want)
+	}
+}
+
+// TestCreateAPIKey_MetadataBounds tests that oversized metadata is rejected.
+func TestCreateAPIKey_BodyTooLarge(t *testing.T) {
+	h, _, _, _ := newTestHandler(t)
+	h.cfg.MaxRequestBytes = 256
+
+	for _, contentLength := range []int64{257, -1} {
+		payload := `{"name":"` + strings.Repeat("a", 1<<20) + `"}`
+		reader := strings.NewReader(payload)
+		req := withActor(httptest.NewRequest(http.MethodPost, "/auth/keys", reader), "test-tenant", AllScopes()...)
+		req.ContentLength = contentLength
+		rec := httptest.NewRecorder()
+		h.CreateAPIKey(rec, req)
+		if rec.Code != http.StatusRequestEntityTooLarge {
+			t.Fatalf("Content-Length %d: status = %d, want 413", contentLength, rec.Code)
+		}
+		var body AuthError
+		if err := json.NewDecoder(rec.Body).Decode(&body); err != nil || body.Code != "BODY_TOO_LARGE" {
+			t.Fatalf("Content-Length %d: body = %+v (%v), want BODY_TOO_LARGE", contentLength, body, err)
+		}
+		if read := int64(len(payload) - reader.Len()); read > 257 {
+			t.Fatalf("Content-Length %d: read %d bytes, want at most 257", contentLength, read)
+		}
+	}
+}
+
+func TestCreateAPIKey_MetadataBounds(t *testing.T) {
+	h, _, _, _ := newTestHandler(t)
+	h.cfg.KeyMetadataMaxEntries = 2
+	h.cfg.KeyMetadataMaxKeyLength = 8
+	h.cfg.KeyMetadataMaxValueLength = 8
+
+	tests := []struct {
+		name     string
+		metadata string
+	}{
+		{"too many entries", `{"a":"1","b":"2","c":"3"}`},
+		{"key too long", `{"environment":"prod"}`},
+		{"value too long", `{"env":"production"}`},
+		{"empty key", `{"":"prod"}`},
+		{"whitespace key", `{"my env":"prod"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := createKeyWithBody(t, h, `{"name":"CI","scopes":["audit:read"],"metadata":`+tt.metadata+`}`)
+			if rec.Code != http.StatusBadRequest {
+				t.Fatalf("expected status %d, got %d: %s", http.StatusBadRequest, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+// TestListAPIKeys_FiltersByMetadata tests the metadataKey/metadataValue filter.
+func TestListAPIKeys_FiltersByMetadata(t *testing.T) {
+	h, _, _, _ := newTestHandler(t)
+	for _, body := range []string{
+		`{"name":"Prod CI","scopes":["audit:read"],"metadata":{"env":"prod"}}`,
+		`{"name":"Staging CI","scopes":["audit:read"],"metadata":{"env":"staging"}}`,
+		`{"name":"Untagged","scopes":["audit:read"]}`,
+	} {
+		if rec := createKeyWithBody(t, h, body); rec.Code != http.StatusCreated {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, rec.Code, rec.Body.String())
+		}
+	}
+
+	resp := listKeysPage(t, h, "?metadataKey=env&metadataValue=prod")
+	if len(resp.Keys) != 1 || resp.Keys[0].Name != "Prod CI" {
+		t.Errorf("expected only the prod key, got %+v", resp.Keys)
+	}
+	if resp := listKeysPage(t, h, "?metadataKey=env"); len(resp.Keys) != 2 {
+		t.Errorf("expected 2 keys tagged env, got %d", len(resp.Keys))
+	}
+	if resp := listKeysPage(t, h, "?metadataKey=env&metadataValue=dev"); len(resp.Keys) != 0 {
+		t.Errorf("expected no dev keys, got %d", len(resp.Keys))
+	}
+
+	req := withActor(httptest.NewRequest(http.MethodGet, "/auth/keys?metadataValue=prod", nil), "test-tenant", Scopes.AdminRead)
+	rec := httptest.NewRecorder()
+	h.ListAPIKeys(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d for metadataValue without metadataKey, got %d", http.StatusBadRequest, rec.Code)
+	}
+}
+
+// TestListAPIKeys_InvalidCursor tests that a malformed cursor is rejected.
+func TestListAPIKeys_InvalidCursor(t *testing.T) {
+	h, _, _, _ := newTestHandler(t)
+	req := withActor(httptest.NewRequest(http.MethodGet, "/auth/keys?cursor=%21%21", nil), "test-tenant", Scopes.AdminRead)
+	rec := httptest.NewRecorder()
+	h.ListAPIKeys(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+}
+
+// TestCreateTenant_IDPolicy tests tenant-id validation at creation.
+func TestCreateTenant_IDPolicy(t *testing.T) {
+	h, _, _, _ := newTestHandler(t)
+	h.cfg.TenantIDPattern = DefaultTenantIDPattern
+	h.cfg.TenantIDMaxLength = 63
+	h.cfg.ReservedTenantIDs = DefaultReservedTenantIDs
+	h.cfg.PlatformAdminTenants = []string{"ops"}
+
+	tests := []struct {
+		name       string
+		id         string
+		wantStatus int
+	}{
+		{"valid", "acme-corp", http.StatusCreated},
+		{"slash", "acme/../corp", http.StatusBadRequest},
+		{"dot segment", "..", http.StatusBadRequest},
+		{"space", "acme corp", http.StatusBadRequest},
+		{"reserved", "admin", http.StatusBadRequest},
+		{"reserved mixed case", "System", http.StatusBadRequest},
+		{"too long", strings.Repeat("a", 64), http.StatusBadRequest},
+		{"platform admin", "ops", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := `{"id":"` + tt.id + `","name":"Acme"}`
+			req := httptest.NewRequest(http.MethodPost, "/auth/tenants", strings.NewReader(body))
+			rec := httptest.NewRecorder()
+			h.CreateTenant(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+			if tt.wantStatus == http.StatusBadRequest {
+				var authErr AuthError
+				_ = json.NewDecoder(rec.Body).Decode(&authErr)
+				if authErr.Code != "VALIDATION_ERROR" {
+					t.Errorf("expected VALIDATION_ERROR, got %s", authErr.Code)
+				}
+			}
+		})
+	}
+}
+
+// postTenant calls CreateTenant with the given JSON body and Idempotency-Key.
+func postTenant(h *Handler, body, idemKey string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/auth/tenants", strings.NewReader(body))
+	if idemKey != "" {
+		req.Header.Set("Idempotency-Key", idemKey)
+	}
+	rec := httptest.NewRecorder()
+	h.CreateTenant(rec, req)
+	return rec
+}
+
+// testIdemKey is a CreateTenant Idempotency-Key long enough to be accepted.
+const testIdemKey = "0f8a4c7e-2b1d-4e59-9a63-5d7c1e8b4f20"
+
+// TestCreateTenant_IdempotentReplay tests that a retried request returns the original response.
+func TestCreateTenant_IdempotentReplay(t *testing.T) {
+	h, _, _, _ := newTestHandler(t)
+	h.cfg.TenantIdempotencyTTL = time.Hour
+
+	first := postTenant(h, `{"id":"acme","name":"Acme"}`, testIdemKey)
+	if first.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, first.Code, first.Body.String())
+	}
+	// Same payload with different formatting is still a replay.
+	replay := postTenant(h, `{ "name": "Acme", "id": "acme" }`, testIdemKey)
+	if replay.Code != http.StatusCreated {
+		t.Fatalf("expected replay status %d, got %d: %s", http.StatusCreated, replay.Code, replay.Body.String())
+	}
+	if replay.Header().Get("Idempotent-Replayed") != "true" {
+		t.Error("expected Idempotent-Replayed header on replay")
+	}
+
+	var want, got CreateTenantResponse
+	_ = json.NewDecoder(first.Body).Decode(&want)
+	_ = json.NewDecoder(replay.Body).Decode(&got)
+	if got.InitialKey.RawKey == "" || got.InitialKey.RawKey != want.InitialKey.RawKey {
+		t.Errorf("expected replay to return original raw key %q, got %q", want.InitialKey.RawKey, got.InitialKey.RawKey)
+	}
+	if got.InitialKey.Key.ID != want.InitialKey.Key.ID {
+		t.Errorf("expected key id %s, got %s", want.InitialKey.Key.ID, got.InitialKey.Key.ID)
+	}
+}
+
+// TestCreateTenant_IdempotentReplayChecksPlan tests that a replay is refused
+// when the replaying caller could not have made the original request.
+func TestCreateTenant_IdempotentReplayChecksPlan(t *testing.T) {
+	h, _, _, _ := newTestHandler(t)
+	h.cfg.TenantIdempotencyTTL = time.Hour
+	h.cfg.PlatformAdminTenants = []string{"ops"}
+
+	body := `{"id":"acme","name":"Acme","plan":"pro"}`
+	req := withActor(httptest.NewRequest(http.MethodPost, "/auth/tenants", strings.NewReader(body)), "ops", Scopes.AdminWrite)
+	req.Header.Set("Idempotency-Key", testIdemKey)
+	rec := httptest.NewRecorder()
+	h.CreateTenant(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("platform admin: expected status %d, got %d: %s", http.StatusCreated, rec.Code, rec.Body.String())
+	}
+
+	if rec := postTenant(h, body, testIdemKey); rec.Code != http.StatusForbidden {
+		t.Fatalf("anonymous replay: expected status %d, got %d: %s", http.StatusForbidden, rec.Code, rec.Body.String())
+	}
+}
+
+// TestCreateTenant_IdempotencyKeyIsNotStoredInPlaintext tests that the stored
+// replay is neither keyed by nor readable without the Idempotency-Key, and
+// that short keys are refused.
+func TestCreateTenant_IdempotencyKeyIsNotStoredInPlaintext(t *testing.T) {
+	h, store, _, _ := newTestHandler(t)
+	h.cfg.TenantIdempotencyTTL = time.Hour
+
+	if rec := postTenant(h, `{"id":"acme","name":"Acme"}`, "idem-1"); rec.Code != http.StatusBadRequest {
+		t.Fatalf("short key: expected status %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+	first := postTenant(h, `{"id":"acme","name":"Acme"}`, testIdemKey)
+	var resp CreateTenantResponse
+	_ = json.NewDecoder(first.Body).Decode(&resp)
+
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+	if len(store.idempotency) != 1 {
+		t.Fatalf("expected one idempotency record, got %d", len(store.idempotency))
+	}
+	for k, rec := range store.idempotency {
+		if strings.Contains(k, testIdemKey) {
+			t.Errorf("expected the record key to hide the Idempotency-Key, got %q", k)
+		}
+		if resp.InitialKey.RawKey == "" || bytes.Contains(rec.Response, []byte(resp.InitialKey.RawKey)) {
+			t.Error("expected the stored response to be encrypted")
+		}
+	}
+}
+
+// TestCreateTenant_IdempotencyBodyMismatch tests that reusing a key with a different body conflicts.
+func TestCreateTenant_IdempotencyBodyMismatch(t *testing.T) {
+	h, _, _, _ := newTestHandler(t)
+	h.cfg.TenantIdempotencyTTL = time.Hour
+
+	if rec := postTenant(h, `{"id":"acme","name":"Acme"}`, testIdemKey); rec.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d", http.StatusCreated, rec.Code)
+	}
+	rec := postTenant(h, `{"id":"acme","name":"Acme Corp"}`, testIdemKey)
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expected status %d, got %d", http.StatusConflict, rec.Code)
+	}
+	var authErr AuthError
+	_ = json.NewDecoder(rec.Body).Decode(&authErr)
+	if authErr.Code != "IDEMPOTENCY_BODY_MISMATCH" {
+		t.Errorf("expected IDEMPOTENCY_BODY_MISMATCH, got %s", authErr.Code)
+	}
+}
+
+// TestCreateTenant_IdempotencyExpires tests that replays stop after the retention window.
+func TestCreateTenant_IdempotencyExpires(t *testing.T) {
+	h, _, _, _ := newTestHandler(t)
+	h.cfg.TenantIdempotencyTTL = 10 * time.Millisecond
+
+	if rec := postTenant(h, `{"id":"acme","name":"Acme"}`, testIdemKey); rec.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d", http.StatusCreated, rec.Code)
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	// Once the record has expired the request is processed afresh and hits the existing tenant.
+	rec := postTenant(h, `{"id":"acme","name":"Acme"}`, testIdemKey)
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expected status %d, got %d", http.StatusConflict, rec.Code)
+	}
+	var authErr AuthError
+	_ = json.NewDecoder(rec.Body).Decode(&authErr)
+	if authErr.Code != "CONFLICT" {
+		t.Errorf("expected CONFLICT, got %s", authErr.Code)
+	}
+}
+
+// patchTenantStatus calls UpdateTenantStatus as a test-tenant admin with the given JSON body.
+func patchTenantStatus(h *Handler, tenantID, body string, scopes ...string) *httptest.ResponseRecorder {
+	return patchTenantStatusAs(h, "test-tenant", tenantID, body, scopes...)
+}
+
+// patchTenantStatusAs calls UpdateTenantStatus as an admin of actorTenant.
+func patchTenantStatusAs(h *Handler, actorTenant, tenantID, body string, scopes ...string) *httptest.ResponseRecorder {
+	req := withActor(httptest.NewRequest(http.MethodPatch, "/auth/tenants/"+tenantID, strings.NewReader(body)), actorTenant, scopes...)
+	rec := httptest.NewRecorder()
+	h.UpdateTenantStatus(rec, req, tenantID)
+	return rec
+}
+
+// TestUpdateTenantStatus_Transitions tests suspending and reactivating a tenant.
+func TestUpdateTenantStatus_Transitions(t *testing.T) {
+	h, store, audit, _ := newTestHandler(t)
+	ctx := context.Background()
+
+	rec := patchTenantStatus(h, "test-tenant", `{"status":"suspended"}`, Scopes.AdminWrite)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	var info TenantInfo
+	if err := json.NewDecoder(rec.Body).Decode(&info); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if info.Status != "suspended" {
+		t.Errorf("response status = %q, want suspended", info.Status)
+	}
+	if tenant, _ := store.GetTenant(ctx, "test-tenant"); tenant.Status != "suspended" {
+		t.Errorf("stored status = %q, want suspended", tenant.Status)
+	}
+
+	rec = patchTenantStatus(h, "test-tenant", `{"status":"active"}`, Scopes.AdminWrite)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	if tenant, _ := store.GetTenant(ctx, "test-tenant"); tenant.Status != "active" {
+		t.Errorf("stored status = %q, want active", tenant.Status)
+	}
+
+	entries := audit.GetEntries("test-tenant")
+	if len(entries) != 2 || entries[0].Action != "tenant.suspended" || entries[1].Action != "tenant.reactivated" {
+		t.Fatalf("unexpected audit entries: %+v", entries)
+	}
+	if result := VerifyAuditChain(entries); !result.Valid {
+		t.Errorf("expected valid audit chain, got %+v", result)
+	}
+}
+
+// TestUpdateTenantStatus_Errors tests validation, not-found, and scope handling.
+func TestUpdateTenantStatus_Errors(t *testing.T) {
+	h, _, _, _ := newTestHandler(t)
+	h.cfg.PlatformAdminTenants = []string{"ops"}
+
+	tests := []struct {
+		name       string
+		actor      string
+		tenantID   string
+		body       string
+		scopes     []string
+		wantStatus int
+		wantCode   string
+	}{
+		{"invalid status", "test-tenant", "test-tenant", `{"status":"deleted"}`, []string{Scopes.AdminWrite}, http.StatusBadRequest, "VALIDATION_ERROR"},
+		{"unknown tenant", "ops", "missing", `{"status":"suspended"}`, []string{Scopes.AdminWrite}, http.StatusNotFound, "NOT_FOUND"},
+		{"read-only scope", "test-tenant", "test-tenant", `{"status":"suspended"}`, []string{Scopes.AdminRead}, http.StatusForbidden, "INSUFFICIENT_SCOPE"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := patchTenantStatusAs(h, tt.actor, tt.tenantID, tt.body, tt.scopes...)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d", tt.wantStatus, rec.Code)
+			}
+			var authErr AuthError
+			_ = json.NewDecoder(rec.Body).Decode(&authErr)
+			if authErr.Code != tt.wantCode {
+				t.Errorf("expected error code %s, got %s", tt.wantCode, authErr.Code)
+			}
+		})
+	}
+}
+
+// TestUpdateTenantStatus_CrossTenant tests that only platform admins may change
+// another tenant's status.
+func TestUpdateTenantStatus_CrossTenant(t *testing.T) {
+	h, store, _, _ := newTestHandler(t)
+	h.cfg.PlatformAdminTenants = []string{"ops"}
+	ctx := context.Background()
+	if err := store.CreateTenant(ctx, Tenant{ID: "other", Name: "Other", Status: "active"}); err != nil {
+		t.Fatalf("CreateTenant() error = %v", err)
+	}
+
+	rec := patchTenantStatusAs(h, "test-tenant", "other", `{"status":"suspended"}`, Scopes.AdminWrite)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("other tenant's admin: status = %d, want 403", rec.Code)
+	}
+	var authErr AuthError
+	_ = json.NewDecoder(rec.Body).Decode(&authErr)
+	if authErr.Code != "FORBIDDEN" {
+		t.Errorf("expected error code FORBIDDEN, got %s", authErr.Code)
+	}
+	if tenant, _ := store.GetTenant(ctx, "other"); tenant.Status != "active" {
+		t.Errorf("stored status = %q, want active", tenant.Status)
+	}
+
+	if rec := patchTenantStatusAs(h, "ops", "other", `{"status":"suspended"}`, Scopes.AdminWrite); rec.Code != http.StatusOK {
+		t.Fatalf("platform admin: status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+}
+
+// TestAuditUnattributedPartition tests that tenantless failures are partitioned away from tenant chains.
+func TestAuditUnattributedPartition(t *testing.T) {
+	h, store, audit, cfg := newTestHandler(t)
+	_, rawKey, err := store.CreateKey(context.Background(), "test-tenant", "Test Key", []string{"*"}, nil)
+	if err != nil {
+		t.Fatalf("CreateKey() error = %v", err)
+	}
+
+	handler := Middleware(store, audit, cfg, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	for _, key := range []string{rawKey, "ppk_invalid_key_12345", rawKey, ""} {
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		if key != "" {
+			req.Header.Set("Authorization", "Bearer "+key)
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	if got := len(audit.GetEntries("")); got != 0 {
+		t.Errorf("expected no entries in the empty tenant bucket, got %d", got)
+	}
+	unattributed := audit.GetEntries(UnattributedTenantID)
+	if len(unattributed) != 2 || unattributed[0].Action != "auth.invalid_key" || unattributed[1].Action != "auth.missing_key" {
+		t.Fatalf("unexpected unattributed entries: %+v", unattributed)
+	}
+	if result := VerifyAuditChain(unattributed); !result.Valid {
+		t.Errorf("expected valid unattributed chain, got %+v", result)
+	}
+	if result := VerifyAuditChain(audit.GetEntries("test-tenant")); !result.Valid || result.Count != 2 {
+		t.Errorf("expected valid tenant chain of 2 entries, got %+v", result)
+	}
+
+	// Only platform admins may read the unattributed partition
+	req := withActor(httptest.NewRequest(http.MethodGet, "/auth/audit/verify?partition=unattributed", nil), "test-tenant", Scopes.AdminRead)
+	rec := httptest.NewRecorder()
+	h.VerifyAuditChain(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected status %d for tenant admin, got %d", http.StatusForbidden, rec.Code)
+	}
+
+	h.cfg.PlatformAdminTenants = []string{"test-tenant"}
+	rec = httptest.NewRecorder()
+	h.VerifyAuditChain(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d for platform admin, got %d", http.StatusOK, rec.Code)
+	}
+	var result AuditChainVerification
+	if err := json.NewDecoder(rec.Body).Decode(&result); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if !result.Valid || result.Count != 2 {
+		t.Errorf("expected valid unattributed chain of 2 entries, got %+v", result)
+	}
+}
+
+// TestWhoAmI tests that the endpoint reflects the key authenticated by Middleware.
+func TestWhoAmI(t *testing.T) {
+	h, store, audit, cfg := newTestHandler(t)
+	h.cfg.RateLimitPerMinute = 100
+	key, rawKey, err := store.CreateKey(context.Background(), "test-tenant", "SDK Key", []string{Scopes.AuditRead, Scopes.InvoiceWrite}, nil)
+	if err != nil {
+		t.Fatalf("CreateKey() error = %v", err)
+	}
+
+	handler := Middleware(store, audit, cfg, nil)(http.HandlerFunc(h.WhoAmI))
+	req := httptest.NewRequest(http.MethodGet, "/auth/me", nil)
+	req.Header.Set("Authorization", "Bearer "+rawKey)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	var resp WhoAmIResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Tenant.ID != "test-tenant" || resp.Tenant.Status != "active" {
+		t.Errorf("unexpected tenant: %+v", resp.Tenant)
+	}
+	if resp.KeyID != key.ID || resp.KeyName != "SDK Key" || resp.ActorType != "api_key" {
+		t.Errorf("unexpected key identity: %+v", resp)
+	}
+	if len(resp.Scopes) != 2 || resp.Scopes[0] != Scopes.AuditRead || resp.Scopes[1] != Scopes.InvoiceWrite {
+		t.Errorf("scopes = %v, want [%s %s]", resp.Scopes, Scopes.AuditRead, Scopes.InvoiceWrite)
+	}
+	if resp.RateLimit != 100 {
+		t.Errorf("rateLimit = %d, want default 100", resp.RateLimit)
+	}
+}
+
+// TestWhoAmI_Unauthenticated tests that a missing actor yields 401.
+func TestWhoAmI_Unauthenticated(t *testing.T) {
+	h, _, _, _ := newTestHandler(t)
+	rec := httptest.NewRecorder()
+	h.WhoAmI(rec, httptest.NewRequest(http.MethodGet, "/auth/me", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected status %d, got %d", http.StatusUnauthorized, rec.Code)
+	}
+}
+
+// TestListAPIKeys_ExpiryWarnings tests expiresInDays and expiringSoon hints.
+func TestListAPIKeys_ExpiryWarnings(t *testing.T) {
+	h, store, _, _ := newTestHandler(t)
+	h.cfg.ExpiryWarnWindow = 7 * 24 * time.Hour
+	ctx := context.Background()
+
+	in3 := time.Now().Add(3 * 24 * time.Hour)
+	in30 := time.Now().Add(30 * 24 * time.Hour)
+	_, _, _ = store.CreateKey(ctx, "test-tenant", "Soon", []string{"audit:read"}, &in3)
+	_, _, _ = store.CreateKey(ctx, "test-tenant", "Later", []string{"audit:read"}, &in30)
+	_, _, _ = store.CreateKey(ctx, "test-tenant", "Never", []string{"audit:read"}, nil)
+
+	resp := listKeysPage(t, h, "")
+	byName := map[string]APIKeyInfo{}
+	for _, k := range resp.Keys {
+		byName[k.Name] = k
+	}
+
+	tests := []struct {
+		name     string
+		wantDays *int
+		wantSoon *bool
+	}{
+		{"Soon", intPtr(3), boolPtr(true)},
+		{"Later", intPtr(30), boolPtr(false)},
+		{"Never", nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k, ok := byName[tt.name]
+			if !ok {
+				t.Fatalf("key %s not listed", tt.name)
+			}
+			if (k.ExpiresInDays == nil) != (tt.wantDays == nil) || (k.ExpiresInDays != nil && *k.ExpiresInDays != *tt.wantDays) {
+				t.Errorf("expiresInDays = %v, want %v", derefInt(k.ExpiresInDays), derefInt(tt.wantDays))
+			}
+			if (k.ExpiringSoon == nil) != (tt.wantSoon == nil) || (k.ExpiringSoon != nil && *k.ExpiringSoon != *tt.wantSoon) {
+				t.Errorf("expiringSoon = %v, want %v", k.ExpiringSoon, tt.wantSoon)
+			}
+		})
+	}
+}
+
+func intPtr(v int) *int    { return &v }
+func boolPtr(v bool) *bool { return &v }
+
+func derefInt(p *int) any {
+	if p == nil {
+		return nil
+	}
+	return *p
+}
+
+// TestCreateAPIKey_ExemptBypassesRateLimit tests that exempt keys are never throttled.
+func TestCreateAPIKey_ExemptBypassesRateLimit(t *testing.T) {
+	h, store, audit, cfg := newTestHandler(t)
+	h.cfg.PlatformAdminTenants = []string{"test-tenant"}
+
+	create := func(body string) string {
+		req := withActor(httptest.NewRequest(http.MethodPost, "/auth/keys", strings.NewReader(body)), "test-tenant", AllScopes()...)
+		rec := httptest.NewRecorder()
+		h.CreateAPIKey(rec, req)
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, rec.Code, rec.Body.String())
+		}
+		var resp CreateAPIKeyResponse
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		return resp.RawKey
+	}
+	probeKey := create(`{"name":"Health Probe","scopes":["audit:read"],"exempt":true}`)
+	normalKey := create(`{"name":"Normal","scopes":["audit:read"]}`)
+
+	handler := Middleware(store, audit, cfg, nil, WithRateLimiter(NewRateLimiter(3, time.Minute)))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	flood := func(rawKey string) (throttled int) {
+		for i := 0; i < 20; i++ {
+			req := httptest.NewRequest(http.MethodGet, "/test", nil)
+			req.Header.Set("Authorization", "Bearer "+rawKey)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code == http.StatusTooManyRequests {
+				throttled++
+			}
+		}
+		return throttled
+	}
+
+	if n := flood(probeKey); n != 0 {
+		t.Errorf("exempt key was throttled %d times", n)
+	}
+	if n := flood(normalKey); n == 0 {
+		t.Error("expected normal key to be throttled")
+	}
+
+	// Exempt traffic is still audited
+	successes := 0
+	for _, e := range audit.GetEntries("test-tenant") {
+		if e.Action == "auth.success" {
+			successes++
+		}
+	}
+	if successes < 20 {
+		t.Errorf("expected exempt requests to be audited, got %d successes", successes)
+	}
+}
+
+// TestCreateAPIKey_ExemptRequiresPlatformAdmin tests that a tenant admin cannot
+// mint a key exempt from rate limiting.
+func TestCreateAPIKey_ExemptRequiresPlatformAdmin(t *testing.T) {
+	h, store, _, _ := newTestHandler(t)
+	h.cfg.PlatformAdminTenants = []string{"ops"}
+
+	body := `{"name":"Health Probe","scopes":["audit:read"],"exempt":true}`
+	req := withActor(httptest.NewRequest(http.MethodPost, "/auth/keys", strings.NewReader(body)), "test-tenant", AllScopes()...)
+	rec := httptest.NewRecorder()
+	h.CreateAPIKey(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusForbidden, rec.Code, rec.Body.String())
+	}
+	var authErr AuthError
+	_ = json.NewDecoder(rec.Body).Decode(&authErr)
+	if authErr.Code != "FORBIDDEN" {
+		t.Errorf("expected error code FORBIDDEN, got %s", authErr.Code)
+	}
+	if keys, _ := store.ListKeys(context.Background(), "test-tenant"); len(keys) != 0 {
+		t.Errorf("expected no key to be created, got %d", len(keys))
+	}
+}
+
+func TestRevokeAllAPIKeys_ExceptSelf(t *testing.T) {
+	h, store, audit, _ := newTestHandler(t)
+	ctx := context.Background()
+
+	self, selfRaw, _ := store.CreateKey(ctx, "test-tenant", "Admin", []string{Scopes.AdminWrite}, nil)
+	var leaked []string
+	for i := 0; i < 3; i++ {
+		_, raw, err := store.CreateKey(ctx, "test-tenant", "Leaked", []string{"audit:read"}, nil)
+		if err != nil {
+			t.Fatalf("CreateKey() error = %v", err)
+		}
+		leaked = append(leaked, raw)
+	}
+	already, _, _ := store.CreateKey(ctx, "test-tenant", "Already revoked", []string{"audit:read"}, nil)
+	if err := store.RevokeKey(ctx, already.ID); err != nil {
+		t.Fatalf("RevokeKey() error = %v", err)
+	}
+	if err := store.CreateTenant(ctx, Tenant{ID: "other-tenant", Name: "Other", Status: "active", CreatedAt: time.Now().UTC()}); err != nil {
+		t.Fatalf("CreateTenant() error = %v", err)
+	}
+	_, otherRaw, _ := store.CreateKey(ctx, "other-tenant", "Other", []string{"audit:read"}, nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/auth/keys/revoke-all", strings.NewReader(`{"exceptSelf":true}`))
+	req.Header.Set("X-Correlation-Id", "corr-revoke-all")
+	actor := &Actor{TenantID: "test-tenant", KeyID: self.ID, KeyName: self.Name, Scopes: self.Scopes, ActorType: "api_key"}
+	req = req.WithContext(ContextWithActor(req.Context(), actor))
+	rec := httptest.NewRecorder()
+	h.RevokeAllAPIKeys(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	var resp RevokeAllKeysResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil || resp.Revoked != 3 {
+		t.Fatalf("response = %+v (%v), want 3 revoked", resp, err)
+	}
+
+	if _, _, err := store.ValidateKey(ctx, selfRaw); err != nil {
+		t.Errorf("caller's key should still validate, got %v", err)
+	}
+	for _, raw := range leaked {
+		if _, _, err := store.ValidateKey(ctx, raw); err == nil {
+			t.Errorf("leaked key %s still validates", raw[:12])
+		}
+	}
+	if _, _, err := store.ValidateKey(ctx, otherRaw); err != nil {
+		t.Errorf("other tenant's key should be untouched, got %v", err)
+	}
+
+	var entries []AuditLogEntry
+	for _, e := range audit.GetEntries("test-tenant") {
+		if e.Action == "auth.revoke_all" {
+			entries = append(entries, e)
+		}
+	}
+	if len(entries) != 1 || entries[0].KeyID != self.ID || !strings.Contains(entries[0].Details, "revoked 3 keys") {
+		t.Errorf("expected one auth.revoke_all entry with the count, got %+v", entries)
+	}
+}
+
+func TestRevokeAllAPIKeys_RequiresAdminWrite(t *testing.T) {
+	h, store, _, _ := newTestHandler(t)
+	_, raw, _ := store.CreateKey(context.Background(), "test-tenant", "Reader", []string{"audit:read"}, nil)
+
+	req := withActor(httptest.NewRequest(http.MethodPost, "/auth/keys/revoke-all", nil), "test-tenant", Scopes.AdminRead)
+	rec := httptest.NewRecorder()
+	h.RevokeAllAPIKeys(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want 403", rec.Code)
+	}
+	if _, _, err := store.ValidateKey(context.Background(), raw); err != nil {
+		t.Errorf("key should still validate, got %v", err)
+	}
+}
+
+// TestCreateAPIKey_TagsRoundTrip tests that tags set at creation are returned
+// by create and list.
+func TestCreateAPIKey_TagsRoundTrip(t *testing.T) {
+	h, _, _, _ := newTestHandler(t)
+	rec := createKeyWithBody(t, h, `{"name":"CI","scopes":["audit:read"],"tags":{"group":"ci","env":"staging"}}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, rec.Code, rec.Body.String())
+	}
+	var created CreateAPIKeyResponse
+	if err := json.NewDecoder(rec.Body).Decode(&created); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	want := map[string]string{"group": "ci", "env": "staging"}
+	if !reflect.DeepEqual(created.Key.Tags, want) {
+		t.Errorf("create tags = %v, want %v", created.Key.Tags, want)
+	}
+	if resp := listKeysPage(t, h, ""); len(resp.Keys) != 1 || !reflect.DeepEqual(resp.Keys[0].Tags, want) {
+		t.Errorf("list tags = %+v, want %v", resp.Keys, want)
+	}
+}
+
+// TestListAPIKeys_FiltersByTag tests the repeatable tag=key:value filter.
+func TestListAPIKeys_FiltersByTag(t *testing.T) {
+	h, _, _, _ := newTestHandler(t)
+	for _, body := range []string{
+		`{"name":"CI staging","scopes":["audit:read"],"tags":{"group":"ci","env":"staging"}}`,
+		`{"name":"CI prod","scopes":["audit:read"],"tags":{"group":"ci","env":"prod"}}`,
+		`{"name":"Mobile","scopes":["audit:read"],"tags":{"group":"mobile"}}`,
+	} {
+		if rec := createKeyWithBody(t, h, body); rec.Code != http.StatusCreated {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, rec.Code, rec.Body.String())
+		}
+	}
+
+	if resp := listKeysPage(t, h, "?tag=group:ci"); len(resp.Keys) != 2 {
+		t.Errorf("expected 2 ci keys, got %+v", resp.Keys)
+	}
+	resp := listKeysPage(t, h, "?tag=group:ci&tag=env:staging")
+	if len(resp.Keys) != 1 || resp.Keys[0].Name != "CI staging" {
+		t.Errorf("expected only the staging ci key, got %+v", resp.Keys)
+	}
+	if resp := listKeysPage(t, h, "?tag=group:web"); len(resp.Keys) != 0 {
+		t.Errorf("expected no keys, got %+v", resp.Keys)
+	}
+
+	for _, query := range []string{"?tag=group", "?tag=group:c%20i"} {
+		req := withActor(httptest.NewRequest(http.MethodGet, "/auth/keys"+query, nil), "test-tenant", Scopes.AdminRead)
+		rec := httptest.NewRecorder()
+		h.ListAPIKeys(rec, req)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d, got %d", query, http.StatusBadRequest, rec.Code)
+		}
+	}
+}
+
+// TestCreateAPIKey_TagBounds tests that invalid tags are rejected.
+func TestCreateAPIKey_TagBounds(t *testing.T) {
+	h, _, _, _ := newTestHandler(t)
+	tests := []struct {
+		name string
+		tags string
+	}{
+		{"value too long", `{"group":"` + strings.Repeat("a", MaxKeyTagLength+1) + `"}`},
+		{"key too long", `{"` + strings.Repeat("a", MaxKeyTagLength+1) + `":"ci"}`},
+		{"disallowed character", `{"group":"ci/cd"}`},
+		{"colon in key", `{"env:x":"ci"}`},
+		{"empty value", `{"group":""}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := createKeyWithBody(t, h, `{"name":"CI","scopes":["audit:read"],"tags":`+tt.tags+`}`)
+			if rec.Code != http.StatusBadRequest {
+				t.Fatalf("expected status %d, got %d: %s", http.StatusBadRequest, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+// TestKeyLifecycle_RecordsChainedAuditEntries tests that creating, rotating and
+// revoking a key each append a chained audit entry naming the acting and
+// affected keys.
+func TestKeyLifecycle_RecordsChainedAuditEntries(t *testing.T) {
+	h, _, audit, _ := newTestHandler(t)
+
+	withCorr := func(req *http.Request, corrID string) *http.Request {
+		req.Header.Set("X-Correlation-Id", corrID)
+		return withActor(req, "test-tenant", AllScopes()...)
+	}
+
+	rec := httptest.NewRecorder()
+	h.CreateAPIKey(rec, withCorr(httptest.NewRequest(http.MethodPost, "/auth/keys", strings.NewReader(`{"name":"CI","scopes":["audit:read"]}`)), "corr-create"))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create status = %d: %s", rec.Code, rec.Body.String())
+	}
+	var created CreateAPIKeyResponse
+	if err := json.NewDecoder(rec.Body).Decode(&created); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	rec = httptest.NewRecorder()
+	h.RotateAPIKey(rec, withCorr(httptest.NewRequest(http.MethodPost, "/auth/keys/"+created.Key.ID+"/rotate", nil), "corr-rotate"), created.Key.ID)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("rotate status = %d: %s", rec.Code, rec.Body.String())
+	}
+	var rotated CreateAPIKeyResponse
+	if err := json.NewDecoder(rec.Body).Decode(&rotated); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	rec = httptest.NewRecorder()
+	h.RevokeAPIKey(rec, withCorr(httptest.NewRequest(http.MethodDelete, "/auth/keys/"+rotated.Key.ID, nil), "corr-revoke"), rotated.Key.ID)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("revoke status = %d: %s", rec.Code, rec.Body.String())
+	}
+
+	entries := audit.GetEntries("test-tenant")
+	want := []struct {
+		action, corrID, affected string
+	}{
+		{"key.created", "corr-create", created.Key.ID},
+		{"key.rotated", "corr-rotate", rotated.Key.ID},
+		{"key.revoked", "corr-revoke", rotated.Key.ID},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("expected %d audit entries, got %+v", len(want), entries)
+	}
+	prevHash := ""
+	for i, w := range want {
+		e := entries[i]
+		if e.Action != w.action || e.CorrID != w.corrID || e.KeyID != "test-key" {
+			t.Errorf("entry %d = %+v, want action %s corrId %s keyId test-key", i, e, w.action, w.corrID)
+		}
+		if !strings.Contains(e.Details, w.affected) {
+			t.Errorf("entry %d details %q do not name key %s", i, e.Details, w.affected)
+		}
+		if e.PrevHash != prevHash || e.Hash == "" {
+			t.Errorf("entry %d prevHash = %q, want %q", i, e.PrevHash, prevHash)
+		}
+		prevHash = e.Hash
+	}
+	if result := VerifyAuditChain(entries); !result.Valid {
+		t.Errorf("expected a valid chain, got %+v", result)
+	}
+}
+
+// TestCreateAPIKey_PlanScopeCeiling tests that a free tenant cannot mint an
+// admin:write key while a pro tenant can.
+func TestCreateAPIKey_PlanScopeCeiling(t *testing.T) {
+	h, store, _, _ := newTestHandler(t)
+	h.cfg.PlanPolicies = DefaultPlanPolicies
+	if err := store.CreateTenant(context.Background(), Tenant{ID: "free-tenant", Name: "Free", Plan: "free", Status: "active"}); err != nil {
+		t.Fatalf("CreateTenant() error = %v", err)
+	}
+
+	create := func(tenantID, scopes string) *httptest.ResponseRecorder {
+		body := `{"name":"Key","scopes":` + scopes + `}`
+		req := withActor(httptest.NewRequest(http.MethodPost, "/auth/keys", strings.NewReader(body)), tenantID, AllScopes()...)
+		rec := httptest.NewRecorder()
+		h.CreateAPIKey(rec, req)
+		return rec
+	}
+
+	rec := create("free-tenant", `["audit:read","admin:write"]`)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("free admin:write: status = %d, want 403", rec.Code)
+	}
+	var authErr AuthError
+	if err := json.NewDecoder(rec.Body).Decode(&authErr); err != nil || authErr.Code != "SCOPE_EXCEEDS_PLAN" || !strings.Contains(authErr.Message, "admin:write") {
+		t.Errorf("free admin:write: error = %+v (%v), want SCOPE_EXCEEDS_PLAN naming admin:write", authErr, err)
+	}
+	if rec := create("free-tenant", `["audit:read"]`); rec.Code != http.StatusCreated {
+		t.Errorf("free audit:read: status = %d, want 201", rec.Code)
+	}
+	if rec := create("test-tenant", `["admin:write"]`); rec.Code != http.StatusCreated {
+		t.Errorf("pro admin:write: status = %d, want 201", rec.Code)
+	}
+}
+
+// TestCreateAPIKey_PlanLookupFailsClosed tests that a key request is refused
+// rather than waved past the plan ceiling when the tenant cannot be loaded.
+func TestCreateAPIKey_PlanLookupFailsClosed(t *testing.T) {
+	h, _, _, _ := newTestHandler(t)
+	h.cfg.PlanPolicies = DefaultPlanPolicies
+
+	req := withActor(httptest.NewRequest(http.MethodPost, "/auth/keys", strings.NewReader(`{"name":"Key","scopes":["admin:write"]}`)), "ghost-tenant", AllScopes()...)
+	rec := httptest.NewRecorder()
+	h.CreateAPIKey(rec, req)
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want 500: %s", rec.Code, rec.Body.String())
+	}
+}
+
+// TestCreateTenant_PlanRequiresPlatformAdmin tests that self-service tenants
+// start on the free plan and only platform admins may choose another.
+func TestCreateTenant_PlanRequiresPlatformAdmin(t *testing.T) {
+	h, store, _, _ := newTestHandler(t)
+	h.cfg.PlatformAdminTenants = []string{"ops"}
+
+	post := func(body, actorTenant string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/auth/tenants", strings.NewReader(body))
+		if actorTenant != "" {
+			req = withActor(req, actorTenant, Scopes.AdminWrite)
+		}
+		rec := httptest.NewRecorder()
+		h.CreateTenant(rec, req)
+		return rec
+	}
+
+	if rec := post(`{"id":"anon-ent","name":"Anon","plan":"enterprise"}`, ""); rec.Code != http.StatusForbidden {
+		t.Errorf("unauthenticated enterprise: status = %d, want 403", rec.Code)
+	}
+	if rec := post(`{"id":"tenant-ent","name":"Tenant","plan":"enterprise"}`, "test-tenant"); rec.Code != http.StatusForbidden {
+		t.Errorf("tenant admin enterprise: status = %d, want 403", rec.Code)
+	}
+	if rec := post(`{"id":"anon-free","name":"Anon","plan":"free"}`, ""); rec.Code != http.StatusCreated {
+		t.Errorf("unauthenticated free: status = %d, want 201: %s", rec.Code, rec.Body.String())
+	}
+	if rec := post(`{"id":"ops-ent","name":"Ops","plan":"enterprise"}`, "ops"); rec.Code != http.StatusCreated {
+		t.Fatalf("platform admin enterprise: status = %d, want 201: %s", rec.Code, rec.Body.String())
+	}
+	if tenant, err := store.GetTenant(context.Background(), "ops-ent"); err != nil || tenant.Plan != "enterprise" {
+		t.Errorf("GetTenant(ops-ent) = %+v, %v; want enterprise plan", tenant, err)
+	}
+}
+
+// TestCreateAPIKey_ScopeEscalation tests that keys cannot carry scopes their
+// creator lacks and that only platform admins may grant audit:repair.
+func TestCreateAPIKey_ScopeEscalation(t *testing.T) {
+	h, store, _, _ := newTestHandler(t)
+	h.cfg.PlatformAdminTenants = []string{"ops"}
+	if err := store.CreateTenant(context.Background(), Tenant{ID: "ops", Name: "Ops", Plan: "enterprise", Status: "active"}); err != nil {
+		t.Fatalf("CreateTenant() error = %v", err)
+	}
+
+	create := func(actorTenant, scopes string, held ...string) (int, string) {
+		body := `{"name":"Key","scopes":` + scopes + `}`
+		req := withActor(httptest.NewRequest(http.MethodPost, "/auth/keys", strings.NewReader(body)), actorTenant, held...)
+		rec := httptest.NewRecorder()
+		h.CreateAPIKey(rec, req)
+		var authErr AuthError
+		_ = json.NewDecoder(rec.Body).Decode(&authErr)
+		return rec.Code, authErr.Code
+	}
+
+	tests := []struct {
+		name       string
+		actor      string
+		scopes     string
+		held       []string
+		wantStatus int
+		wantCode   string
+	}{
+		{"scope not held", "test-tenant", `["invoice:write"]`, []string{Scopes.AdminWrite, Scopes.InvoiceRead}, http.StatusForbidden, "INSUFFICIENT_SCOPE"},
+		{"held scope", "test-tenant", `["invoice:read"]`, []string{Scopes.AdminWrite, Scopes.InvoiceRead}, http.StatusCreated, ""},
+		{"tenant admin grants audit:repair", "test-tenant", `["audit:repair"]`, []string{"*"}, http.StatusForbidden, "FORBIDDEN"},
+		{"platform admin grants audit:repair", "ops", `["audit:repair"]`, AllScopes(), http.StatusCreated, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, code := create(tt.actor, tt.scopes, tt.held...)
+			if status != tt.wantStatus || code != tt.wantCode {
+				t.Errorf("got %d %q, want %d %q", status, code, tt.wantStatus, tt.wantCode)
+			}
+		})
+	}
+}
+
+// TestDeleteTenant_SelfPurge tests that only a platform admin may purge the
+// audit log, and that a tenant purging its own on deletion leaves no partition
+// behind and records the event as unattributed.
+func TestDeleteTenant_SelfPurge(t *testing.T) {
+	h, store, audit, _ := newTestHandler(t)
+	ctx := context.Background()
+	recordAuditEvent(ctx, audit, AuditLogEntry{TenantID: "test-tenant", Action: "key.created"}, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	purge := func() *httptest.ResponseRecorder {
+		req := withActor(httptest.NewRequest(http.MethodDelete, "/auth/tenants/test-tenant?purgeAudit=true", nil), "test-tenant", Scopes.AdminWrite)
+		rec := httptest.NewRecorder()
+		h.DeleteTenant(rec, req, "test-tenant")
+		return rec
+	}
+	if rec := purge(); rec.Code != http.StatusForbidden {
+		t.Fatalf("tenant admin purge: status = %d, want 403", rec.Code)
+	}
+	if _, err := store.GetTenant(ctx, "test-tenant"); err != nil || len(audit.GetEntries("test-tenant")) != 1 {
+		t.Fatalf("expected a refused purge to leave the tenant and its audit log, got %v", err)
+	}
+
+	h.cfg.PlatformAdminTenants = []string{"test-tenant"}
+	rec := purge()
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("status = %d, want 204: %s", rec.Code, rec.Body.String())
+	}
+
+	if entries := audit.GetEntries("test-tenant"); len(entries) != 0 {
+		t.Errorf("expected the purged partition to stay gone, got %+v", entries)
+	}
+	for _, tenantID := range audit.Tenants() {
+		if tenantID == "test-tenant" {
+			t.Error("purged partition was re-created")
+		}
+	}
+	entries := audit.GetEntries(UnattributedTenantID)
+	if len(entries) != 1 || entries[0].Action != "tenant.deleted" {
+		t.Errorf("expected an unattributed tenant.deleted entry, got %+v", entries)
+	}
+}
+
+// TestEffectiveRateLimit tests plan defaults, key overrides and plan caps.
+func TestEffectiveRateLimit(t *testing.T) {
+	cfg := Config{RateLimitPerMinute: 100, PlanPolicies: DefaultPlanPolicies}
+	tests := []struct {
+		plan     string
+		keyLimit int
+		want     int
+	}{
+		{"free", 0, 60},
+		{"free", 1000, 60},
+		{"free", 10, 10},
+		{"pro", 0, 600},
+		{"pro", 5000, 3000},
+		{"enterprise", 10000, 10000},
+		{"custom", 0, 0},
+		{"custom", 250, 250},
+	}
+	for _, tt := range tests {
+		if got := cfg.EffectiveRateLimit(tt.plan, tt.keyLimit); got != tt.want {
+			t.Errorf("EffectiveRateLimit(%q, %d) = %d, want %d", tt.plan, tt.keyLimit, got, tt.want)
+		}
+	}
+}
+
+// TestDeleteTenant_Cascades tests that deleting a tenant removes its keys and
+// hash index entries, optionally purges its audit log, and leaves others intact.
+func TestDeleteTenant_Cascades(t *testing.T) {
+	h, store, audit, _ := newTestHandler(t)
+	h.cfg.PlatformAdminTenants = []string{"ops"}
+	ctx := context.Background()
+	if err := store.CreateTenant(ctx, Tenant{ID: "doomed", Name: "Doomed", Status: "active"}); err != nil {
+		t.Fatalf("CreateTenant() error = %v", err)
+	}
+	_, raw, _ := store.CreateKey(ctx, "doomed", "Key", []string{"audit:read"}, nil)
+	old, _, _ := store.CreateKey(ctx, "doomed", "Rotated", []string{"audit:read"}, nil)
+	_, rotatedRaw, err := store.RotateKey(ctx, old.ID)
+	if err != nil {
+		t.Fatalf("RotateKey() error = %v", err)
+	}
+	_, survivorRaw, _ := store.CreateKey(ctx, "test-tenant", "Survivor", []string{"audit:read"}, nil)
+	recordAuditEvent(ctx, audit, AuditLogEntry{TenantID: "doomed", Action: "key.created"}, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	deleteTenant := func(actorTenant, tenantID, query string) *httptest.ResponseRecorder {
+		req := withActor(httptest.NewRequest(http.MethodDelete, "/auth/tenants/"+tenantID+query, nil), actorTenant, Scopes.AdminWrite)
+		rec := httptest.NewRecorder()
+		h.DeleteTenant(rec, req, tenantID)
+		return rec
+	}
+
+	if rec := deleteTenant("test-tenant", "doomed", ""); rec.Code != http.StatusForbidden {
+		t.Fatalf("other tenant's admin: status = %d, want 403", rec.Code)
+	}
+	if rec := deleteTenant("ops", "doomed", "?purgeAudit=true"); rec.Code != http.StatusNoContent {
+		t.Fatalf("platform admin: status = %d, want 204: %s", rec.Code, rec.Body.String())
+	}
+
+	for _, k := range []string{raw, rotatedRaw} {
+		if _, _, err := store.ValidateKey(ctx, k); err == nil {
+			t.Errorf("key of a deleted tenant still validates")
+		}
+	}
+	if _, _, err := store.ValidateKey(ctx, survivorRaw); err != nil {
+		t.Errorf("other tenant's key should still validate, got %v", err)
+	}
+	store.mu.RLock()
+	for hash, keyID := range store.keyHash {
+		if _, ok := store.keys[keyID]; !ok {
+			t.Errorf("orphaned keyHash entry %s -> %s", hash, keyID)
+		}
+	}
+	store.mu.RUnlock()
+	if entries := audit.GetEntries("doomed"); len(entries) != 0 {
+		t.Errorf("expected the audit partition to be purged, got %d entries", len(entries))
+	}
+	if entries := audit.GetEntries("ops"); len(entries) != 1 || entries[0].Action != "tenant.deleted" {
+		t.Errorf("expected a tenant.deleted entry for the actor, got %+v", entries)
+	}
+
+	if _, err := store.GetTenant(ctx, "doomed"); err == nil {
+		t.Error("GetTenant() should fail after deletion")
+	}
+	if rec := deleteTenant("ops", "doomed", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("second delete: status = %d, want 404", rec.Code)
+	}
+	req := withActor(httptest.NewRequest(http.MethodPatch, "/auth/tenants/doomed", strings.NewReader(`{"status":"active"}`)), "ops", Scopes.AdminWrite)
+	rec := httptest.NewRecorder()
+	h.UpdateTenantStatus(rec, req, "doomed")
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status update after deletion: status = %d, want 404", rec.Code)
+	}
+}
@@ -28,11 +28,71 @@ var ErrInvalidKey = errors.New("invalid API key format")
 // KeyPrefix is prepended to all API keys for easy identification.
 const KeyPrefix = "ppk_" // prompt-pack key
 
+// Bounds for KeyConfig.EntropyBytes. The upper bound keeps the hashed key body
+// (random part plus an optional tenant discriminator) within bcrypt's 72-byte
+// input limit.
+const (
+MinKeyEntropyBytes = 16
+MaxKeyEntropyBytes = 44
+)
+
+// Key layout errors returned by KeyConfig.Validate.
+var (
+ErrKeyEntropy = fmt.Errorf("key entropy must be between %d and %d bytes", MinKeyEntropyBytes, MaxKeyEntropyBytes)
+ErrKeyPrefix  = errors.New("key prefix must be non-empty and contain only letters, digits and '_'")
+)
+
+// KeyConfig controls the layout of generated API keys: <Prefix>[<tenant>_]<random>,
+// where random is EntropyBytes of base64url-encoded data. A per-environment
+// prefix such as ppk_live_ or ppk_test_ makes keys distinguishable at a glance.
+type KeyConfig struct {
+Prefix       string
+EntropyBytes int
+}
+
+// DefaultKeyConfig is the ppk_<random> layout with 32 random bytes.
+var DefaultKeyConfig = KeyConfig{Prefix: KeyPrefix, EntropyBytes: 32}
+
+// Validate checks the prefix alphabet and the entropy bounds.
+func (kc KeyConfig) Validate() error {
+if kc.Prefix == "" || strings.IndexFunc(kc.Prefix, func(r rune) bool {
+return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_')
+}) >= 0 {
+return ErrKeyPrefix
+}
+if kc.EntropyBytes < MinKeyEntropyBytes || kc.EntropyBytes > MaxKeyEntropyBytes {
+return ErrKeyEntropy
+}
+return nil
+}
+
+// encodedLength is the base64url length of the random part.
+func (kc KeyConfig) encodedLength() int {
+return base64.RawURLEncoding.EncodedLen(kc.EntropyBytes)
+}
+
 // GenerateAPIKey generates a new API key with the format: ppk_<random>
 // Returns the raw key (to show user once) and the prefix (for identification).
 func GenerateAPIKey() (rawKey, prefix string, err error) {
-// Generate 32 bytes of random data
-keyBytes := make([]byte, 32)
+return DefaultKeyConfig.Generate("")
+}
+
+// GenerateTenantAPIKey generates a key with the format ppk_<tenant>_<random>,
+// where tenant is a non-secret discriminator from TenantKeyDiscriminator. The
+// random part is the same 32 bytes as GenerateAPIKey, so entropy is unchanged;
+// an empty tenant yields the plain ppk_<random> layout. The prefix is
+// <tenant>_<first 8 random chars>, so stored prefixes reveal the tenant.
+func GenerateTenantAPIKey(tenant string) (rawKey, prefix string, err error) {
+return DefaultKeyConfig.Generate(tenant)
+}
+
+// Generate generates a key in the kc layout, as GenerateTenantAPIKey does for
+// DefaultKeyConfig. An empty tenant omits the discriminator.
+func (kc KeyConfig) Generate(tenant string) (rawKey, prefix string, err error) {
+if err := kc.Validate(); err != nil {
+return "", "", err
+}
+keyBytes := make([]byte, kc.EntropyBytes)
 n, err := rand.Read(keyBytes)
 if err != nil {
 return "", "", fmt.Errorf("failed to generate random key: %w", err)
@@ -43,22 +103,77 @@ return "", "", fmt.Errorf("failed to generate random key: only read %d bytes", n
 
 // Encode as base64url (URL-safe, no padding)
 encoded := base64.RawURLEncoding.EncodeToString(keyBytes)
-rawKey = KeyPrefix + encoded
+rawKey = kc.Prefix + encoded
 
-// Prefix is first 8 characters after ppk_
-if len(encoded) >= 8 {
+// Prefix is first 8 characters of the random part
 prefix = encoded[:8]
-} else {
-prefix = encoded
-}
 
+if tenant != "" {
+rawKey = kc.Prefix + tenant + "_" + encoded
+prefix = tenant + "_" + prefix
+}
 return rawKey, prefix, nil
 }
 
-// HashKey hashes an API key using the specified algorithm.
+// MaxKeyDiscriminatorLength bounds the tenant discriminator so that the hashed
+// key body stays within bcrypt's 72-byte input limit.
+const MaxKeyDiscriminatorLength = 12
+
+// TenantKeyDiscriminator derives the key discriminator for tenantID: its
+// lowercase letters, digits, and hyphens, truncated to maxLen (capped at
+// MaxKeyDiscriminatorLength). It never contains '_', which separates it from
+// the random part.
+func TenantKeyDiscriminator(tenantID string, maxLen int) string {
+if maxLen <= 0 || maxLen > MaxKeyDiscriminatorLength {
+maxLen = MaxKeyDiscriminatorLength
+}
+var b strings.Builder
+for _, r := range strings.ToLower(tenantID) {
+if b.Len() == maxLen {
+break
+}
+if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' {
+b.WriteRune(r)
+}
+}
+return b.String()
+}
+
+// split returns the tenant discriminator (empty for <prefix><random> keys)
+// and the random part of a raw key. Plain keys are told apart by length: their
+// body is exactly the encoded random bytes, which may themselves contain '_'.
+func (kc KeyConfig) split(rawKey string) (tenant, random string, ok bool) {
+body := strings.TrimPrefix(rawKey, kc.Prefix)
+if body == rawKey {
+return "", "", false
+}
+if len(body) <= kc.encodedLength() {
+return "", body, true
+}
+tenant, random, found := strings.Cut(body, "_")
+if !found || tenant == "" || len(tenant) > MaxKeyDiscriminatorLength || len(random) != kc.encodedLength() {
+return "", body, true
+}
+return tenant, random, true
+}
+
+// ExtractKeyTenant returns the tenant discriminator embedded in a raw key, or ""
+// for keys without one.
+func ExtractKeyTenant(rawKey string) string {
+return DefaultKeyConfig.ExtractTenant(rawKey)
+}
+
+// ExtractTenant is ExtractKeyTenant for keys in the kc layout.
+func (kc KeyConfig) ExtractTenant(rawKey string) string {
+tenant, _, _ := kc.split(rawKey)
+return tenant
+}
+
+// HashKey hashes an API key using the specified algorithm. The cfg key prefix
+// is not part of the hashed data.
 func HashKey(rawKey string, cfg Config) (string, error) {
 // Remove prefix if present
-keyData := strings.TrimPrefix(rawKey, KeyPrefix)
+keyData := strings.TrimPrefix(rawKey, cfg.KeyConfig().Prefix)
 if keyData == rawKey {
 // No prefix found - invalid format
 return "", ErrInvalidKey
@@ -76,7 +191,7 @@ return hashBcrypt(keyData, cfg.BcryptCost)
 
 // VerifyKey verifies a raw key against a stored hash.
 func VerifyKey(rawKey, storedHash string, cfg Config) bool {
-keyData := strings.TrimPrefix(rawKey, KeyPrefix)
+keyData := strings.TrimPrefix(rawKey, cfg.KeyConfig().Prefix)
 if keyData == rawKey {
 return false
 }
@@ -93,6 +208,36 @@ return verifyArgon2(keyData, storedHash, cfg)
 return false
 }
 
+// NeedsRehash reports whether storedHash was produced with weaker parameters than
+// cfg currently asks for: a bcrypt cost below BcryptCost, or argon2 memory, time,
+// or parallelism below the configured Argon2 values.
+func NeedsRehash(storedHash string, cfg Config) bool {
+if strings.HasPrefix(storedHash, "$2") {
+cost, err := bcrypt.Cost([]byte(storedHash))
+if err != nil {
+return false
+}
+target := cfg.BcryptCost
+if target < bcrypt.MinCost {
+target = bcrypt.DefaultCost
+}
+return cost < target
+}
+if strings.HasPrefix(storedHash, "$argon2") {
+parts := strings.Split(storedHash, "$")
+if len(parts) != 6 {
+return false
+}
+var memory, time uint32
+var threads uint8
+if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &time, &threads); err != nil {
+return false
+}
+return memory < cfg.Argon2Memory || time < cfg.Argon2Time || threads < cfg.Argon2Threads
+}
+return false
+}
+
 // hashBcrypt hashes using bcrypt.
 func hashBcrypt(data string, cost int) (string, error) {
 hash, err := bcrypt.GenerateFromPassword([]byte(data), cost)
@@ -181,9 +326,17 @@ return hex.EncodeToString(h.Sum(nil))
 
 // ExtractKeyPrefix extracts the prefix from a raw key for identification.
 func ExtractKeyPrefix(rawKey string) string {
-keyData := strings.TrimPrefix(rawKey, KeyPrefix)
-if keyData == rawKey || len(keyData) < 8 {
+return DefaultKeyConfig.ExtractPrefix(rawKey)
+}
+
+// ExtractPrefix is ExtractKeyPrefix for keys in the kc layout.
+func (kc KeyConfig) ExtractPrefix(rawKey string) string {
+tenant, random, ok := kc.split(rawKey)
+if !ok || len(random) < 8 {
 return ""
 }
-return keyData[:8]
+if tenant != "" {
+return tenant + "_" + random[:8]
+}
+return random[:8]
 }
@@ -0,0 +1,295 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// MetricAuditChainBroken is counted by MetricsIntegrityAlerter for every failed
+// integrity check.
+const MetricAuditChainBroken = "audit_chain_broken"
+
+// IntegritySource exposes the audit partitions IntegrityChecker walks.
+// Snapshot returns a tenant's entries and a count that changes whenever the
+// chain is legitimately rewritten, such as by a prune. InMemoryAuthAuditRecorder
+// implements it.
+type IntegritySource interface {
+	Tenants() []string
+	Snapshot(tenantID string) ([]AuditLogEntry, int)
+}
+
+// ManifestCheck reports one stored artifact re-hashed against its manifest.
+// Err is nil when every file the manifest lists still matches.
+type ManifestCheck struct {
+	TenantID string
+	Artifact string
+	Err      error
+}
+
+// ManifestSource re-verifies stored artifacts against their manifests, such as
+// the hashes.txt beside each auditzip archive.
+type ManifestSource interface {
+	VerifyManifests(ctx context.Context) []ManifestCheck
+}
+
+// IntegrityResult records one IntegrityChecker pass over a tenant's chain, or
+// over one artifact manifest when Artifact is set. Checkpoint is the index the
+// next chain pass resumes from.
+type IntegrityResult struct {
+	TenantID   string    `json:"tenantId"`
+	Artifact   string    `json:"artifact,omitempty"`
+	CheckedAt  time.Time `json:"checkedAt"`
+	Checkpoint int       `json:"checkpoint"`
+	AuditChainVerification
+}
+
+// IntegrityAlerter is notified of every failed integrity check.
+type IntegrityAlerter interface {
+	Alert(ctx context.Context, result IntegrityResult)
+}
+
+// IntegrityAlertFunc adapts a function to IntegrityAlerter.
+type IntegrityAlertFunc func(ctx context.Context, result IntegrityResult)
+
+// Alert calls f.
+func (f IntegrityAlertFunc) Alert(ctx context.Context, result IntegrityResult) {
+	f(ctx, result)
+}
+
+// MetricsIntegrityAlerter counts failed checks as MetricAuditChainBroken.
+func MetricsIntegrityAlerter(m Metrics) IntegrityAlerter {
+	return IntegrityAlertFunc(func(context.Context, IntegrityResult) {
+		m.IncCounter(MetricAuditChainBroken)
+	})
+}
+
+// WebhookIntegrityAlerter POSTs each failed result as JSON to url. Delivery
+// errors are logged; the next failing pass alerts again.
+func WebhookIntegrityAlerter(url string, client *http.Client, logger *slog.Logger) IntegrityAlerter {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return IntegrityAlertFunc(func(ctx context.Context, result IntegrityResult) {
+		if err := postIntegrityAlert(ctx, client, url, result); err != nil {
+			logger.Warn("integrity alert webhook failed",
+				slog.String("tenantId", result.TenantID),
+				slog.String("error", err.Error()),
+			)
+		}
+	})
+}
+
+func postIntegrityAlert(ctx context.Context, client *http.Client, url string, result IntegrityResult) error {
+	body, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// chainCheckpoint marks entries[:index] as verified; hash is entries[index-1].Hash.
+// rewrites is the source's rewrite count when the checkpoint was taken.
+type chainCheckpoint struct {
+	index    int
+	hash     string
+	rewrites int
+}
+
+// IntegrityChecker periodically re-verifies every tenant's audit chain so
+// tampering or a broken chain is noticed without waiting for a manual audit.
+// Each pass verifies at most cfg.IntegrityCheckBatch entries per tenant and
+// resumes from a per-tenant checkpoint; once a walk reaches the head the next
+// pass starts over, so every entry is re-verified on a rolling basis.
+type IntegrityChecker struct {
+	source    IntegritySource
+	manifests []ManifestSource
+	batch     int
+	every     time.Duration
+	alert     IntegrityAlerter
+	logger    *slog.Logger
+	now       func() time.Time
+
+	mu          sync.Mutex
+	checkpoints map[string]chainCheckpoint
+	results     map[string]IntegrityResult
+}
+
+// NewIntegrityChecker creates a checker over source. alert may be nil, in which
+// case failures are only logged.
+func NewIntegrityChecker(source IntegritySource, cfg Config, alert IntegrityAlerter, logger *slog.Logger) *IntegrityChecker {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &IntegrityChecker{
+		source:      source,
+		batch:       cfg.IntegrityCheckBatch,
+		every:       cfg.IntegrityCheckInterval,
+		alert:       alert,
+		logger:      logger,
+		now:         time.Now,
+		checkpoints: make(map[string]chainCheckpoint),
+		results:     make(map[string]IntegrityResult),
+	}
+}
+
+// AddManifests makes every CheckAll pass also verify src's artifact manifests.
+// Call it before Start.
+func (c *IntegrityChecker) AddManifests(src ManifestSource) {
+	c.manifests = append(c.manifests, src)
+}
+
+// Start runs CheckAll every cfg.IntegrityCheckInterval until ctx is done.
+func (c *IntegrityChecker) Start(ctx context.Context) {
+	if c.every <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(c.every)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				c.CheckAll(ctx)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// CheckAll runs one pass over every tenant with audit entries, then over the
+// artifact manifests of every source added with AddManifests.
+func (c *IntegrityChecker) CheckAll(ctx context.Context) []IntegrityResult {
+	tenants := c.source.Tenants()
+	results := make([]IntegrityResult, 0, len(tenants))
+	for _, tenantID := range tenants {
+		if ctx.Err() != nil {
+			return results
+		}
+		results = append(results, c.Check(ctx, tenantID))
+	}
+	for _, src := range c.manifests {
+		if ctx.Err() != nil {
+			break
+		}
+		results = append(results, c.checkManifests(ctx, src)...)
+	}
+	return results
+}
+
+// checkManifests verifies src's manifests, alerting on each mismatch.
+func (c *IntegrityChecker) checkManifests(ctx context.Context, src ManifestSource) []IntegrityResult {
+	checks := src.VerifyManifests(ctx)
+	results := make([]IntegrityResult, 0, len(checks))
+	for _, check := range checks {
+		result := IntegrityResult{
+			TenantID:               check.TenantID,
+			Artifact:               check.Artifact,
+			CheckedAt:              c.now().UTC(),
+			AuditChainVerification: AuditChainVerification{Valid: true},
+		}
+		if check.Err != nil {
+			result.Valid = false
+			result.Reason = check.Err.Error()
+			c.logger.Error("artifact manifest integrity check failed",
+				slog.String("tenantId", check.TenantID),
+				slog.String("artifact", check.Artifact),
+				slog.String("reason", result.Reason),
+			)
+			if c.alert != nil {
+				c.alert.Alert(ctx, result)
+			}
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// Check runs one bounded pass over tenantID's chain, records the result and
+// alerts if the chain is broken. A broken chain keeps its checkpoint, so later
+// passes keep alerting until it is repaired. A checkpoint taken before the
+// chain was rewritten (pruned or repaired) is dropped and the walk restarts.
+func (c *IntegrityChecker) Check(ctx context.Context, tenantID string) IntegrityResult {
+	entries, rewrites := c.source.Snapshot(tenantID)
+
+	c.mu.Lock()
+	cp := c.checkpoints[tenantID]
+	c.mu.Unlock()
+	if cp.rewrites != rewrites {
+		cp = chainCheckpoint{rewrites: rewrites}
+	}
+
+	var v AuditChainVerification
+	switch {
+	case cp.index > len(entries):
+		v = brokenChain(len(entries), len(entries), "chain is shorter than the last checkpoint")
+	case cp.index > 0 && entries[cp.index-1].Hash != cp.hash:
+		v = brokenChain(len(entries), cp.index-1, "checkpointed entry hash changed")
+	default:
+		end := len(entries)
+		if c.batch > 0 && end-cp.index > c.batch {
+			end = cp.index + c.batch
+		}
+		v = verifyChainRange(entries, cp.index, end, cp.hash)
+		if v.Valid {
+			if end == len(entries) {
+				cp = chainCheckpoint{rewrites: rewrites}
+			} else {
+				cp = chainCheckpoint{index: end, hash: entries[end-1].Hash, rewrites: rewrites}
+			}
+		}
+	}
+
+	result := IntegrityResult{
+		TenantID:               tenantID,
+		CheckedAt:              c.now().UTC(),
+		Checkpoint:             cp.index,
+		AuditChainVerification: v,
+	}
+	c.mu.Lock()
+	c.checkpoints[tenantID] = cp
+	c.results[tenantID] = result
+	c.mu.Unlock()
+
+	if !v.Valid {
+		c.logger.Error("audit chain integrity check failed",
+			slog.String("tenantId", tenantID),
+			slog.Int("brokenAt", *v.BrokenAt),
+			slog.String("reason", v.Reason),
+		)
+		if c.alert != nil {
+			c.alert.Alert(ctx, result)
+		}
+	}
+	return result
+}
+
+// LastResult returns the most recent result for tenantID.
+func (c *IntegrityChecker) LastResult(tenantID string) (IntegrityResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	result, ok := c.results[tenantID]
+	return result, ok
+}
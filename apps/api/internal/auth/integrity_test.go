@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"context"
+	"testing"
+)
+
+// recordingAlerter collects the results it is alerted with.
+type recordingAlerter struct {
+	alerts []IntegrityResult
+}
+
+func (a *recordingAlerter) Alert(_ context.Context, result IntegrityResult) {
+	a.alerts = append(a.alerts, result)
+}
+
+// TestIntegrityChecker_CleanChainDoesNotAlert tests that a clean chain verifies
+// silently and records its result.
+func TestIntegrityChecker_CleanChainDoesNotAlert(t *testing.T) {
+	_, store, audit, cfg := newTestHandler(t)
+	seedAuditChain(t, store, audit, cfg, 3)
+
+	alerter := &recordingAlerter{}
+	checker := NewIntegrityChecker(audit, cfg, alerter, nil)
+	results := checker.CheckAll(context.Background())
+	if len(results) != 1 || !results[0].Valid || results[0].Count != 3 {
+		t.Fatalf("expected one valid result over 3 entries, got %+v", results)
+	}
+	if len(alerter.alerts) != 0 {
+		t.Fatalf("expected no alerts, got %+v", alerter.alerts)
+	}
+	if last, ok := checker.LastResult("test-tenant"); !ok || !last.Valid {
+		t.Fatalf("expected recorded valid result, got %+v (ok=%v)", last, ok)
+	}
+}
+
+// TestIntegrityChecker_CorruptedChainAlerts tests that tampering fires the alert
+// with the broken index, and keeps firing until the chain is fixed.
+func TestIntegrityChecker_CorruptedChainAlerts(t *testing.T) {
+	_, store, audit, cfg := newTestHandler(t)
+	seedAuditChain(t, store, audit, cfg, 4)
+
+	audit.mu.Lock()
+	audit.entries["test-tenant"][2].Action = "auth.tampered"
+	audit.mu.Unlock()
+
+	alerter := &recordingAlerter{}
+	checker := NewIntegrityChecker(audit, cfg, alerter, nil)
+	checker.CheckAll(context.Background())
+	checker.CheckAll(context.Background())
+	if len(alerter.alerts) != 2 {
+		t.Fatalf("expected an alert per pass, got %d", len(alerter.alerts))
+	}
+	alert := alerter.alerts[0]
+	if alert.TenantID != "test-tenant" || alert.Valid || alert.BrokenAt == nil || *alert.BrokenAt != 2 {
+		t.Fatalf("expected break at index 2 for test-tenant, got %+v", alert)
+	}
+}
+
+// TestIntegrityChecker_BoundedPassesRollOver tests that IntegrityCheckBatch
+// bounds each pass and that a completed walk starts over, so tampering behind
+// the checkpoint is still caught.
+func TestIntegrityChecker_BoundedPassesRollOver(t *testing.T) {
+	_, store, audit, cfg := newTestHandler(t)
+	seedAuditChain(t, store, audit, cfg, 5)
+	cfg.IntegrityCheckBatch = 2
+
+	alerter := &recordingAlerter{}
+	checker := NewIntegrityChecker(audit, cfg, alerter, nil)
+	ctx := context.Background()
+	if result := checker.Check(ctx, "test-tenant"); !result.Valid || result.Checkpoint != 2 {
+		t.Fatalf("expected first pass to stop at 2, got %+v", result)
+	}
+
+	audit.mu.Lock()
+	audit.entries["test-tenant"][0].Action = "auth.tampered"
+	audit.mu.Unlock()
+
+	if result := checker.Check(ctx, "test-tenant"); !result.Valid || result.Checkpoint != 4 {
+		t.Fatalf("expected second pass to stop at 4, got %+v", result)
+	}
+	if result := checker.Check(ctx, "test-tenant"); !result.Valid || result.Checkpoint != 0 {
+		t.Fatalf("expected third pass to reach the head and reset, got %+v", result)
+	}
+	if len(alerter.alerts) != 0 {
+		t.Fatalf("expected no alerts before the walk restarts, got %+v", alerter.alerts)
+	}
+	if result := checker.Check(ctx, "test-tenant"); result.Valid || *result.BrokenAt != 0 {
+		t.Fatalf("expected restarted walk to find the break at 0, got %+v", result)
+	}
+	if len(alerter.alerts) != 1 {
+		t.Fatalf("expected one alert, got %d", len(alerter.alerts))
+	}
+}
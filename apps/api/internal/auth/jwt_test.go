@@ -0,0 +1,181 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const testJWTSecret = "test-jwt-secret"
+
+// signHS256 builds a compact HS256 JWT for claims.
+func signHS256(t *testing.T, secret string, claims JWTClaims) string {
+	t.Helper()
+	input := jwtSigningInput(t, "HS256", claims)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(input))
+	return input + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func jwtSigningInput(t *testing.T, alg string, claims JWTClaims) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": alg, "typ": "JWT"})
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatalf("marshal claims: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+}
+
+func validClaims() JWTClaims {
+	return JWTClaims{
+		Subject:   "user-123",
+		Tenant:    "test-tenant",
+		Scope:     "audit:read invoice:write",
+		ExpiresAt: time.Now().Add(5 * time.Minute).Unix(),
+	}
+}
+
+// newJWTTestServer returns a handler behind Middleware with JWT enabled, plus a raw API key.
+func newJWTTestServer(t *testing.T, cfg Config, captured **Actor) (http.Handler, string) {
+	t.Helper()
+	store := NewInMemoryAPIKeyStore(cfg)
+	ctx := context.Background()
+	_ = store.CreateTenant(ctx, Tenant{ID: "test-tenant", Name: "Test", Plan: "pro", Status: "active", CreatedAt: time.Now().UTC()})
+	_, rawKey, err := store.CreateKey(ctx, "test-tenant", "Test Key", []string{"*"}, nil)
+	if err != nil {
+		t.Fatalf("CreateKey() error = %v", err)
+	}
+
+	verifier, err := NewJWTVerifier(cfg)
+	if err != nil {
+		t.Fatalf("NewJWTVerifier() error = %v", err)
+	}
+	handler := Middleware(store, nil, cfg, nil, WithJWTVerifier(verifier))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*captured, _ = ActorFromContext(r.Context())
+		w.WriteHeader(http.StatusOK)
+	}))
+	return handler, rawKey
+}
+
+func serveBearer(handler http.Handler, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func jwtTestConfig() Config {
+	return Config{APIKeyHashAlgorithm: "bcrypt", BcryptCost: 10, JWTHMACSecret: testJWTSecret}
+}
+
+// TestMiddleware_JWTValid tests that a valid token yields a "user" actor with JWT scopes.
+func TestMiddleware_JWTValid(t *testing.T) {
+	var actor *Actor
+	handler, _ := newJWTTestServer(t, jwtTestConfig(), &actor)
+
+	rec := serveBearer(handler, signHS256(t, testJWTSecret, validClaims()))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	if actor == nil || actor.ActorType != "user" || actor.Subject != "user-123" || actor.TenantID != "test-tenant" {
+		t.Fatalf("unexpected actor: %+v", actor)
+	}
+	if !actor.HasScope("invoice:write") || actor.HasScope("admin:write") {
+		t.Errorf("scopes not mapped from scope claim: %v", actor.Scopes)
+	}
+}
+
+// TestMiddleware_JWTRejected tests that expired and mis-signed tokens return INVALID_TOKEN.
+func TestMiddleware_JWTRejected(t *testing.T) {
+	expired := validClaims()
+	expired.ExpiresAt = time.Now().Add(-time.Hour).Unix()
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"expired", signHS256(t, testJWTSecret, expired)},
+		{"wrong signature", signHS256(t, "other-secret", validClaims())},
+		{"unknown tenant", signHS256(t, testJWTSecret, JWTClaims{Subject: "u", Tenant: "nope", ExpiresAt: time.Now().Add(time.Minute).Unix()})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var actor *Actor
+			handler, _ := newJWTTestServer(t, jwtTestConfig(), &actor)
+			rec := serveBearer(handler, tt.token)
+			if rec.Code != http.StatusUnauthorized {
+				t.Fatalf("expected status %d, got %d", http.StatusUnauthorized, rec.Code)
+			}
+			var authErr AuthError
+			if err := json.NewDecoder(rec.Body).Decode(&authErr); err != nil {
+				t.Fatalf("failed to decode error response: %v", err)
+			}
+			if authErr.Code != "INVALID_TOKEN" {
+				t.Errorf("expected error code INVALID_TOKEN, got %s", authErr.Code)
+			}
+		})
+	}
+}
+
+// TestMiddleware_JWTFallbackToAPIKey tests that API keys still work with JWT enabled.
+func TestMiddleware_JWTFallbackToAPIKey(t *testing.T) {
+	var actor *Actor
+	handler, rawKey := newJWTTestServer(t, jwtTestConfig(), &actor)
+
+	rec := serveBearer(handler, rawKey)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	if actor == nil || actor.ActorType != "api_key" {
+		t.Errorf("expected api_key actor, got %+v", actor)
+	}
+}
+
+// TestJWTVerifier_RS256 tests RS256 verification against a configured public key.
+func TestJWTVerifier_RS256(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&priv.PublicKey)
+	if err != nil {
+		t.Fatalf("MarshalPKIXPublicKey() error = %v", err)
+	}
+	cfg := Config{JWTRSAPublicKey: string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))}
+	verifier, err := NewJWTVerifier(cfg)
+	if err != nil {
+		t.Fatalf("NewJWTVerifier() error = %v", err)
+	}
+
+	input := jwtSigningInput(t, "RS256", validClaims())
+	digest := sha256.Sum256([]byte(input))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, priv, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatalf("SignPKCS1v15() error = %v", err)
+	}
+	claims, err := verifier.Verify(input + "." + base64.RawURLEncoding.EncodeToString(sig))
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if claims.Subject != "user-123" {
+		t.Errorf("Subject = %q, want user-123", claims.Subject)
+	}
+
+	// An HS256 token must not verify when only RS256 is configured
+	if _, err := verifier.Verify(signHS256(t, testJWTSecret, validClaims())); err == nil {
+		t.Error("expected HS256 token to be rejected by RS256-only verifier")
+	}
+}
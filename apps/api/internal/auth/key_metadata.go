@@ -0,0 +1,43 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ValidateKeyMetadata enforces the configured bounds on API key metadata:
+// entry count and key/value lengths. Keys must be non-empty and free of
+// whitespace and control characters so they are usable as list filters.
+func ValidateKeyMetadata(md map[string]string, cfg Config) error {
+	if cfg.KeyMetadataMaxEntries > 0 && len(md) > cfg.KeyMetadataMaxEntries {
+		return fmt.Errorf("metadata must have at most %d entries", cfg.KeyMetadataMaxEntries)
+	}
+	for k, v := range md {
+		if k == "" {
+			return errors.New("metadata keys must not be empty")
+		}
+		if strings.IndexFunc(k, isSpaceOrControl) >= 0 {
+			return fmt.Errorf("metadata key %q must not contain whitespace", k)
+		}
+		if cfg.KeyMetadataMaxKeyLength > 0 && len(k) > cfg.KeyMetadataMaxKeyLength {
+			return fmt.Errorf("metadata key %q must be at most %d characters", k, cfg.KeyMetadataMaxKeyLength)
+		}
+		if cfg.KeyMetadataMaxValueLength > 0 && len(v) > cfg.KeyMetadataMaxValueLength {
+			return fmt.Errorf("metadata value for %q must be at most %d characters", k, cfg.KeyMetadataMaxValueLength)
+		}
+	}
+	return nil
+}
+
+// matchesMetadata reports whether key satisfies the metadata filter in opts.
+func matchesMetadata(key *APIKey, opts ListKeysOptions) bool {
+	if opts.MetadataKey == "" {
+		return true
+	}
+	v, ok := key.Metadata[opts.MetadataKey]
+	if !ok {
+		return false
+	}
+	return opts.MetadataValue == "" || v == opts.MetadataValue
+}
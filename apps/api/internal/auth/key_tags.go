@@ -0,0 +1,70 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Bounds for API key tags.
+const (
+	MaxKeyTags      = 16
+	MaxKeyTagLength = 64 // Applies to tag keys and values alike
+)
+
+// ValidateKeyTags enforces the bounds on API key tags. Keys and values must be
+// non-empty, at most MaxKeyTagLength long and drawn from [A-Za-z0-9._-], which
+// keeps them unambiguous in a tag=key:value filter.
+func ValidateKeyTags(tags map[string]string) error {
+	if len(tags) > MaxKeyTags {
+		return fmt.Errorf("tags must have at most %d entries", MaxKeyTags)
+	}
+	for k, v := range tags {
+		if err := validateTagPart("tag key", k); err != nil {
+			return err
+		}
+		if err := validateTagPart(fmt.Sprintf("tag value for %q", k), v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateTagPart(what, s string) error {
+	if s == "" {
+		return fmt.Errorf("%s must not be empty", what)
+	}
+	if len(s) > MaxKeyTagLength {
+		return fmt.Errorf("%s must be at most %d characters", what, MaxKeyTagLength)
+	}
+	if strings.IndexFunc(s, func(r rune) bool { return !isTagChar(r) }) >= 0 {
+		return fmt.Errorf("%s may only contain letters, digits, '.', '_' and '-'", what)
+	}
+	return nil
+}
+
+func isTagChar(r rune) bool {
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '_' || r == '-'
+}
+
+// ParseTagFilter parses the tag=key:value list filter.
+func ParseTagFilter(s string) (key, value string, err error) {
+	key, value, ok := strings.Cut(s, ":")
+	if !ok {
+		return "", "", errors.New("tag filter must have the form key:value")
+	}
+	if err := ValidateKeyTags(map[string]string{key: value}); err != nil {
+		return "", "", err
+	}
+	return key, value, nil
+}
+
+// matchesTags reports whether key carries every tag in opts.Tags.
+func matchesTags(key *APIKey, opts ListKeysOptions) bool {
+	for k, v := range opts.Tags {
+		if got, ok := key.Tags[k]; !ok || got != v {
+			return false
+		}
+	}
+	return true
+}
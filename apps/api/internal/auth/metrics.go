@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"time"
+
+	"github.com/yourorg/yourapp/apps/api/internal/ratelimit"
+)
+
+// Metric names emitted by Middleware at each authentication decision point.
+const (
+	MetricAuthSuccess      = "auth_success"
+	MetricAuthMissingKey   = "auth_missing_key"
+	MetricAuthInvalidKey   = "auth_invalid_key"
+	MetricAuthExpired      = "auth_expired"
+	MetricAuthRevoked      = "auth_revoked"
+	MetricAuthInvalidToken = "auth_invalid_token"
+	MetricTenantSuspended  = "tenant_suspended"
+	MetricRateLimited      = "rate_limited"
+	MetricAuthBadScheme    = "auth_unsupported_scheme"
+	MetricAuthInvalidCert  = "auth_invalid_cert"
+)
+
+// Metrics receives authentication outcome counters and key validation latency.
+type Metrics interface {
+	// IncCounter increments the named outcome counter (one of the Metric* constants).
+	IncCounter(name string)
+	// ObserveValidationLatency records how long APIKeyStore.ValidateKey took.
+	ObserveValidationLatency(d time.Duration)
+}
+
+// NoopMetrics discards all metrics. It is the default when no Metrics is configured.
+type NoopMetrics struct{}
+
+// IncCounter does nothing.
+func (NoopMetrics) IncCounter(string) {}
+
+// ObserveValidationLatency does nothing.
+func (NoopMetrics) ObserveValidationLatency(time.Duration) {}
+
+// MiddlewareOption configures optional Middleware behaviour.
+type MiddlewareOption func(*middlewareOptions)
+
+type middlewareOptions struct {
+	metrics    Metrics
+	limiter    *RateLimiter
+	jwt        *JWTVerifier
+	mtls       MTLSResolver
+	rateEvents ratelimit.Recorder
+}
+
+func newMiddlewareOptions(opts []MiddlewareOption) middlewareOptions {
+	o := middlewareOptions{metrics: NoopMetrics{}}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithMetrics reports authentication outcomes and validation latency to m.
+func WithMetrics(m Metrics) MiddlewareOption {
+	return func(o *middlewareOptions) {
+		if m != nil {
+			o.metrics = m
+		}
+	}
+}
+
+// WithJWTVerifier accepts bearer tokens that look like JWTs, verifying them with v
+// instead of treating them as API keys. A nil v leaves JWT support disabled.
+func WithJWTVerifier(v *JWTVerifier) MiddlewareOption {
+	return func(o *middlewareOptions) {
+		o.jwt = v
+	}
+}
+
+// WithMTLSResolver maps verified client certificates to actors through r when
+// Config.EnableMTLS is set. A nil r leaves mTLS disabled.
+func WithMTLSResolver(r MTLSResolver) MiddlewareOption {
+	return func(o *middlewareOptions) {
+		o.mtls = r
+	}
+}
+
+// WithRateLimiter enforces per-key rate limiting after a key has been validated.
+func WithRateLimiter(rl *RateLimiter) MiddlewareOption {
+	return func(o *middlewareOptions) {
+		o.limiter = rl
+	}
+}
+
+// WithRateLimitRecorder reports each rate-limit rejection to rec when
+// Config.AuditRateLimits is set.
+func WithRateLimitRecorder(rec ratelimit.Recorder) MiddlewareOption {
+	return func(o *middlewareOptions) {
+		o.rateEvents = rec
+	}
+}
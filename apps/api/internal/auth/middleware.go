@@ -9,9 +9,15 @@ import (
 "errors"
 "fmt"
 "log/slog"
+"math"
 "net/http"
 "strings"
 "time"
+
+"github.com/yourorg/yourapp/apps/api/internal/auditchain"
+"github.com/yourorg/yourapp/apps/api/internal/ratelimit"
+"go.opentelemetry.io/otel/attribute"
+"go.opentelemetry.io/otel/trace"
 )
 
 // AuthErrors defines authentication error types.
@@ -22,6 +28,7 @@ ErrKeyExpired       = errors.New("API key expired")
 ErrKeyRevoked       = errors.New("API key revoked")
 ErrTenantSuspended  = errors.New("tenant suspended")
 ErrInsufficientScope = errors.New("insufficient scope")
+ErrUnsupportedScheme = errors.New("unsupported authorization scheme")
 )
 
 // AuthError represents an authentication error response.
@@ -33,7 +40,9 @@ Retryable bool   `json:"retryable"`
 }
 
 // Middleware creates the API Key authentication middleware.
-func Middleware(store APIKeyStore, audit AuthAuditRecorder, cfg Config, logger *slog.Logger) func(http.Handler) http.Handler {
+// Optional behaviour such as metrics and rate limiting is enabled via opts.
+func Middleware(store APIKeyStore, audit AuthAuditRecorder, cfg Config, logger *slog.Logger, opts ...MiddlewareOption) func(http.Handler) http.Handler {
+o := newMiddlewareOptions(opts)
 return func(next http.Handler) http.Handler {
 return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 corrID := r.Header.Get("X-Correlation-Id")
@@ -41,28 +50,63 @@ if corrID == "" {
 corrID = generateCorrID()
 }
 
-// Extract API key from Authorization header
-rawKey := extractAPIKey(r)
-if rawKey == "" {
-// Also check X-API-Key header for backward compatibility
-rawKey = r.Header.Get("X-API-Key")
+// The request span parents everything the downstream handler traces
+spanCtx, span := tracer.Start(r.Context(), "auth.Middleware", trace.WithAttributes(attribute.String("correlation.id", corrID)))
+defer span.End()
+r = r.WithContext(spanCtx)
+
+// A verified client certificate authenticates on its own when mTLS is enabled;
+// without one the request falls through to API-key auth
+if cfg.EnableMTLS && o.mtls != nil {
+if cert := verifiedClientCert(r); cert != nil {
+serveMTLS(w, r, next, store, audit, cfg, logger, o, corrID, cert)
+return
+}
+}
+
+// Extract API key from Authorization or X-API-Key header (or, when allowed, the query)
+rawKey, fromQuery, err := parseAPIKey(r, cfg.StrictAuthScheme, cfg.AllowQueryParamKey)
+if fromQuery {
+// Nothing downstream may log the key as part of the URL
+r = redactQueryKey(r)
+}
+if err != nil {
+o.metrics.IncCounter(MetricAuthBadScheme)
+writeAuthError(w, http.StatusUnauthorized, "AUTH_SCHEME_UNSUPPORTED", "Authorization scheme must be Bearer or ApiKey", corrID, false)
+recordAuthFailure(r.Context(), audit, "", corrID, "auth.unsupported_scheme", r)
+return
 }
 
 if rawKey == "" {
+o.metrics.IncCounter(MetricAuthMissingKey)
 writeAuthError(w, http.StatusUnauthorized, "AUTH_REQUIRED", "API key required", corrID, false)
 recordAuthFailure(r.Context(), audit, "", corrID, "auth.missing_key", r)
 return
 }
 
+// Bearer tokens shaped like a JWT take the token path when it is enabled
+if o.jwt != nil && looksLikeJWT(rawKey) {
+serveJWT(w, r, next, store, audit, cfg, logger, o, corrID, rawKey)
+return
+}
+
 // Validate the key
-tenant, apiKey, err := store.ValidateKey(r.Context(), rawKey)
+validateStart := time.Now()
+validateCtx, validateSpan := tracer.Start(r.Context(), "auth.ValidateKey")
+tenant, apiKey, err := store.ValidateKey(validateCtx, rawKey)
+endSpan(validateSpan, err)
+o.metrics.ObserveValidationLatency(time.Since(validateStart))
 if err != nil {
-handleAuthError(w, r, audit, cfg, corrID, rawKey, err)
+o.metrics.IncCounter(MetricAuthInvalidKey)
+handleAuthError(w, r, audit, cfg, logger, corrID, rawKey, err)
 return
 }
 
+span.SetAttributes(attribute.String("tenant.id", tenant.ID), attribute.String("auth.key_id", apiKey.ID))
+
 // Check tenant status
 if tenant.Status != "active" {
+o.metrics.IncCounter(MetricTenantSuspended)
 writeAuthError(w, http.StatusForbidden, "TENANT_SUSPENDED", "Tenant account is suspended", corrID, false)
 recordAuthFailure(r.Context(), audit, tenant.ID, corrID, "auth.tenant_suspended", r)
 return
@@ -74,11 +118,13 @@ if apiKey.ExpiresAt != nil && time.Now().After(*apiKey.ExpiresAt) {
 if apiKey.Rotated {
 gracePeriod := time.Now().Add(-cfg.KeyRotationWindow)
 if apiKey.ExpiresAt.Before(gracePeriod) {
+o.metrics.IncCounter(MetricAuthExpired)
 writeAuthError(w, http.StatusUnauthorized, "KEY_EXPIRED", "API key has expired", corrID, false)
 recordAuthFailure(r.Context(), audit, tenant.ID, corrID, "auth.key_expired", r)
 return
 }
 } else {
+o.metrics.IncCounter(MetricAuthExpired)
 writeAuthError(w, http.StatusUnauthorized, "KEY_EXPIRED", "API key has expired", corrID, false)
 recordAuthFailure(r.Context(), audit, tenant.ID, corrID, "auth.key_expired", r)
 return
@@ -87,11 +133,24 @@ return
 
 // Check revocation
 if apiKey.RevokedAt != nil {
+o.metrics.IncCounter(MetricAuthRevoked)
 writeAuthError(w, http.StatusUnauthorized, "KEY_REVOKED", "API key has been revoked", corrID, false)
 recordAuthFailure(r.Context(), audit, tenant.ID, corrID, "auth.key_revoked", r)
 return
 }
 
+// Check rate limit (exempt internal keys bypass it but are still audited).
+// The tenant's plan supplies the default and caps the key's own limit.
+rateLimit := cfg.EffectiveRateLimit(tenant.Plan, apiKey.RateLimit)
+if o.limiter != nil && !apiKey.Exempt {
+allowed, retryAfter := o.limiter.AllowRate(apiKey.ID, rateLimit)
+setRateLimitHeaders(w, o.limiter, apiKey.ID, rateLimit)
+if !allowed {
+rejectRateLimited(w, r, audit, cfg, o, tenant.ID, corrID, LimiterAPIKey, apiKey.ID, rateLimit, retryAfter)
+return
+}
+}
+
 // Build actor
 actor := &Actor{
 TenantID:  tenant.ID,
@@ -99,6 +158,7 @@ KeyID:     apiKey.ID,
 KeyName:   apiKey.Name,
 Scopes:    apiKey.Scopes,
 ActorType: "api_key",
+RateLimit: rateLimit,
 }
 
 // Update last used (fire and forget)
@@ -113,8 +173,13 @@ go func() {
 }()
 
 // Record success
+o.metrics.IncCounter(MetricAuthSuccess)
 if cfg.EnableAuditLog && audit != nil {
-recordAuthSuccess(r.Context(), audit, tenant.ID, corrID, apiKey.ID, r)
+details := ""
+if fromQuery {
+details = "key from " + QueryParamKey + " query parameter"
+}
+recordAuthSuccessDetails(r.Context(), audit, tenant.ID, corrID, apiKey.ID, details, r)
 }
 
 // Add to context and continue
@@ -137,8 +202,134 @@ next.ServeHTTP(w, r.WithContext(ctx))
 }
 }
 
+// serveJWT authenticates a JWT bearer token and maps its claims into a "user" actor.
+func serveJWT(w http.ResponseWriter, r *http.Request, next http.Handler, store APIKeyStore, audit AuthAuditRecorder, cfg Config, logger *slog.Logger, o middlewareOptions, corrID, token string) {
+claims, err := o.jwt.Verify(token)
+if err != nil {
+o.metrics.IncCounter(MetricAuthInvalidToken)
+message := "Invalid token"
+if errors.Is(err, ErrTokenExpired) {
+message = "Token has expired"
+}
+writeAuthError(w, http.StatusUnauthorized, "INVALID_TOKEN", message, corrID, false)
+recordAuthFailure(r.Context(), audit, "", corrID, "auth.invalid_token", r)
+return
+}
+
+// The tenant claim must name a known tenant
+tenants, ok := store.(TenantStore)
+if !ok {
+o.metrics.IncCounter(MetricAuthInvalidToken)
+writeAuthError(w, http.StatusUnauthorized, "INVALID_TOKEN", "Invalid token", corrID, false)
+recordAuthFailure(r.Context(), audit, "", corrID, "auth.invalid_token", r)
+return
+}
+tenant, err := tenants.GetTenant(r.Context(), claims.Tenant)
+if err != nil {
+o.metrics.IncCounter(MetricAuthInvalidToken)
+writeAuthError(w, http.StatusUnauthorized, "INVALID_TOKEN", "Invalid token", corrID, false)
+recordAuthFailure(r.Context(), audit, "", corrID, "auth.invalid_token", r)
+return
+}
+trace.SpanFromContext(r.Context()).SetAttributes(attribute.String("tenant.id", tenant.ID), attribute.String("auth.subject", claims.Subject))
+if tenant.Status != "active" {
+o.metrics.IncCounter(MetricTenantSuspended)
+writeAuthError(w, http.StatusForbidden, "TENANT_SUSPENDED", "Tenant account is suspended", corrID, false)
+recordAuthFailure(r.Context(), audit, tenant.ID, corrID, "auth.tenant_suspended", r)
+return
+}
+
+// Check rate limit, keyed by subject
+if o.limiter != nil {
+limitKey := "user:" + tenant.ID + ":" + claims.Subject
+rateLimit := cfg.EffectiveRateLimit(tenant.Plan, 0)
+allowed, retryAfter := o.limiter.AllowRate(limitKey, rateLimit)
+setRateLimitHeaders(w, o.limiter, limitKey, rateLimit)
+if !allowed {
+rejectRateLimited(w, r, audit, cfg, o, tenant.ID, corrID, LimiterUser, limitKey, rateLimit, retryAfter)
+return
+}
+}
+
+actor := &Actor{
+TenantID:  tenant.ID,
+Subject:   claims.Subject,
+Scopes:    claims.Scopes(),
+ActorType: "user",
+}
+
+o.metrics.IncCounter(MetricAuthSuccess)
+if cfg.EnableAuditLog && audit != nil {
+recordAuthSuccess(r.Context(), audit, tenant.ID, corrID, "", r)
+}
+
+ctx := r.Context()
+ctx = ContextWithTenant(ctx, tenant)
+ctx = ContextWithActor(ctx, actor)
+
+if logger != nil {
+logger.Info("authenticated request",
+slog.String("correlationId", corrID),
+slog.String("tenantId", tenant.ID),
+slog.String("subject", claims.Subject),
+)
+}
+
+next.ServeHTTP(w, r.WithContext(ctx))
+}
+
 // RequireScope creates middleware that enforces a specific scope.
 func RequireScope(scope string) func(http.Handler) http.Handler {
+return RequireAllScopes(scope)
+}
+
+// RequireAnyScope creates middleware that admits an actor holding at least one
+// of the given scopes, e.g. either admin:read or admin:write.
+// It panics when no scopes are given, which would otherwise admit or reject
+// every actor.
+func RequireAnyScope(scopes ...string) func(http.Handler) http.Handler {
+if len(scopes) == 0 {
+panic("auth: RequireAnyScope requires at least one scope")
+}
+message := "Required scope: " + strings.Join(scopes, ", ")
+if len(scopes) > 1 {
+message = "Required scope: one of " + strings.Join(scopes, ", ")
+}
+return requireScopes(message, func(actor *Actor) bool {
+for _, scope := range scopes {
+if actor.HasScope(scope) {
+return true
+}
+}
+return false
+})
+}
+
+// RequireAllScopes creates middleware that admits an actor only when it holds
+// every one of the given scopes.
+// It panics when no scopes are given, which would otherwise admit or reject
+// every actor.
+func RequireAllScopes(scopes ...string) func(http.Handler) http.Handler {
+if len(scopes) == 0 {
+panic("auth: RequireAllScopes requires at least one scope")
+}
+message := "Required scope: " + strings.Join(scopes, ", ")
+if len(scopes) > 1 {
+message = "Required scopes: " + strings.Join(scopes, ", ")
+}
+return requireScopes(message, func(actor *Actor) bool {
+for _, scope := range scopes {
+if !actor.HasScope(scope) {
+return false
+}
+}
+return true
+})
+}
+
+// requireScopes rejects unauthenticated requests with AUTH_REQUIRED and
+// actors failing allowed with INSUFFICIENT_SCOPE and the given message.
+func requireScopes(message string, allowed func(*Actor) bool) func(http.Handler) http.Handler {
 return func(next http.Handler) http.Handler {
 return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 actor, ok := ActorFromContext(r.Context())
@@ -147,10 +338,9 @@ writeAuthError(w, http.StatusUnauthorized, "AUTH_REQUIRED", "Authentication requ
 return
 }
 
-if !actor.HasScope(scope) {
+if !allowed(actor) {
 corrID := r.Header.Get("X-Correlation-Id")
-writeAuthError(w, http.StatusForbidden, "INSUFFICIENT_SCOPE", 
-fmt.Sprintf("Required scope: %s", scope), corrID, false)
+writeAuthError(w, http.StatusForbidden, "INSUFFICIENT_SCOPE", message, corrID, false)
 return
 }
 
@@ -159,44 +349,108 @@ next.ServeHTTP(w, r)
 }
 }
 
-// extractAPIKey extracts the API key from the Authorization header.
-// Supports: Bearer <key>, ApiKey <key>, or just <key>
+// QueryParamKey is the query parameter consulted for an API key when
+// Config.AllowQueryParamKey is set, so plain download links can authenticate.
+const QueryParamKey = "api_key"
+
+// extractAPIKey extracts the API key from the request headers.
+// The Authorization header always wins; X-API-Key is only consulted (for backward
+// compatibility) when Authorization is absent.
+// Supports: Bearer <key>, ApiKey <key> (scheme is case-insensitive), or just <key>.
+// A known scheme with an empty token is treated as a missing key.
 func extractAPIKey(r *http.Request) string {
-auth := r.Header.Get("Authorization")
-if auth == "" {
-return ""
+key, _, _ := parseAPIKey(r, false, false)
+return key
 }
 
-// Handle "Bearer <key>"
-if strings.HasPrefix(auth, "Bearer ") {
-return strings.TrimPrefix(auth, "Bearer ")
+// parseAPIKey extracts the key like extractAPIKey. In strict mode an
+// Authorization value without a Bearer or ApiKey scheme is rejected with
+// ErrUnsupportedScheme instead of being treated as a raw key. With allowQuery,
+// the QueryParamKey query parameter is a last resort when neither header is
+// present; fromQuery reports that it was used.
+func parseAPIKey(r *http.Request, strict, allowQuery bool) (key string, fromQuery bool, err error) {
+auth := strings.TrimSpace(r.Header.Get("Authorization"))
+if auth == "" {
+key = strings.TrimSpace(r.Header.Get("X-API-Key"))
+if key == "" && allowQuery {
+key = strings.TrimSpace(r.URL.Query().Get(QueryParamKey))
+return key, key != "", nil
+}
+return key, false, nil
 }
 
-// Handle "ApiKey <key>"
-if strings.HasPrefix(auth, "ApiKey ") {
-return strings.TrimPrefix(auth, "ApiKey ")
+// Handle "Bearer <key>" and "ApiKey <key>"
+scheme, token, _ := strings.Cut(auth, " ")
+if strings.EqualFold(scheme, "Bearer") || strings.EqualFold(scheme, "ApiKey") {
+return strings.TrimSpace(token), false, nil
+}
+if strict {
+return "", false, ErrUnsupportedScheme
 }
 
 // Handle raw key (less common)
-return auth
+return auth, false, nil
+}
+
+// CredentialsPresent reports whether r carries anything Middleware would
+// authenticate: a verified client certificate when mTLS is enabled, an
+// Authorization header, or a key found the way Middleware finds one. Routes
+// open to anonymous callers use it to authenticate only those that identify
+// themselves.
+func CredentialsPresent(r *http.Request, cfg Config) bool {
+if cfg.EnableMTLS && verifiedClientCert(r) != nil {
+return true
+}
+if strings.TrimSpace(r.Header.Get("Authorization")) != "" {
+return true
+}
+key, _, _ := parseAPIKey(r, cfg.StrictAuthScheme, cfg.AllowQueryParamKey)
+return key != ""
+}
+
+// redactQueryKey returns a shallow copy of r whose URL and RequestURI carry
+// REDACTED in place of the QueryParamKey value.
+func redactQueryKey(r *http.Request) *http.Request {
+r2 := r.Clone(r.Context())
+q := r2.URL.Query()
+q.Set(QueryParamKey, "REDACTED")
+r2.URL.RawQuery = q.Encode()
+r2.RequestURI = r2.URL.RequestURI()
+return r2
 }
 
-func handleAuthError(w http.ResponseWriter, r *http.Request, audit AuthAuditRecorder, cfg Config, corrID, rawKey string, err error) {
-keyPrefix := ExtractKeyPrefix(rawKey)
+// handleAuthError answers a failed key validation. Only the key's identifying
+// prefix (as stored in APIKey.KeyPrefix) is logged and audited, never the key.
+func handleAuthError(w http.ResponseWriter, r *http.Request, audit AuthAuditRecorder, cfg Config, logger *slog.Logger, corrID, rawKey string, err error) {
+keyPrefix := cfg.KeyConfig().ExtractPrefix(rawKey)
 
+var action string
 switch {
 case errors.Is(err, ErrInvalidKey):
+action = "auth.invalid_format"
 writeAuthError(w, http.StatusUnauthorized, "INVALID_KEY", "Invalid API key format", corrID, false)
-recordAuthFailure(r.Context(), audit, "", corrID, "auth.invalid_format", r)
+recordAuthFailure(r.Context(), audit, "", corrID, action, r)
 case errors.Is(err, ErrInvalidAPIKey):
+action = "auth.invalid_key"
 writeAuthError(w, http.StatusUnauthorized, "INVALID_KEY", "Invalid API key", corrID, false)
-recordAuthFailure(r.Context(), audit, "", corrID, "auth.invalid_key", r)
+details := ""
+if keyPrefix != "" {
+details = "keyPrefix=" + keyPrefix
+}
+recordAuthFailureDetails(r.Context(), audit, "", corrID, action, details, r)
 default:
+action = "auth.failed"
 writeAuthError(w, http.StatusUnauthorized, "AUTH_FAILED", "Authentication failed", corrID, false)
-recordAuthFailure(r.Context(), audit, "", corrID, "auth.failed", r)
+recordAuthFailure(r.Context(), audit, "", corrID, action, r)
 }
 
-_ = keyPrefix // Could log this for debugging
+if logger != nil {
+logger.Warn("authentication failed",
+slog.String("correlationId", corrID),
+slog.String("reason", action),
+slog.String("keyPrefix", keyPrefix),
+)
+}
 }
 
 func writeAuthError(w http.ResponseWriter, status int, code, message, corrID string, retryable bool) {
@@ -215,65 +469,126 @@ Retryable: retryable,
 _ = json.NewEncoder(w).Encode(resp)
 }
 
+// Limiter names reported in rate-limit events.
+const (
+LimiterAPIKey = "auth.api_key"
+LimiterUser   = "auth.user"
+LimiterMTLS   = "auth.mtls"
+)
+
+// setRateLimitHeaders reports key's remaining budget as X-RateLimit-Limit,
+// X-RateLimit-Remaining and X-RateLimit-Reset (Unix seconds when the bucket
+// is full again). rate is the key's AllowRate rate (0 for the limiter's own).
+func setRateLimitHeaders(w http.ResponseWriter, limiter *RateLimiter, key string, rate int) {
+remaining, resetAt := limiter.PeekRate(key, rate)
+w.Header().Set("X-RateLimit-Limit", fmt.Sprintf("%d", limiter.rateOr(rate)))
+w.Header().Set("X-RateLimit-Remaining", fmt.Sprintf("%d", remaining))
+w.Header().Set("X-RateLimit-Reset", fmt.Sprintf("%d", int64(math.Ceil(float64(resetAt.UnixNano())/float64(time.Second)))))
+}
+
+// rejectRateLimited answers 429 and, when cfg.AuditRateLimits is set, reports a
+// rate-limit event to the recorder and attaches it to the audit entry's details.
+func rejectRateLimited(w http.ResponseWriter, r *http.Request, audit AuthAuditRecorder, cfg Config, o middlewareOptions, tenantID, corrID, limiter, key string, rate int, retryAfter time.Duration) {
+retrySeconds := int(math.Ceil(retryAfter.Seconds()))
+o.metrics.IncCounter(MetricRateLimited)
+w.Header().Set("Retry-After", fmt.Sprintf("%d", retrySeconds))
+writeAuthError(w, http.StatusTooManyRequests, "RATE_LIMITED", "Rate limit exceeded", corrID, true)
+if !cfg.AuditRateLimits {
+recordAuthFailure(r.Context(), audit, tenantID, corrID, "auth.rate_limited", r)
+return
+}
+ev := ratelimit.Event{
+TenantID:          tenantID,
+Limiter:           limiter,
+Key:               key,
+CorrID:            corrID,
+Limit:             o.limiter.rateOr(rate),
+WindowSeconds:     int(o.limiter.Window().Seconds()),
+RetryAfterSeconds: retrySeconds,
+Timestamp:         time.Now().UTC(),
+}
+if o.rateEvents != nil {
+o.rateEvents.RecordRateLimit(r.Context(), ev)
+}
+details, _ := json.Marshal(ev)
+recordAuthFailureDetails(r.Context(), audit, tenantID, corrID, "auth.rate_limited", string(details), r)
+}
+
 func recordAuthFailure(ctx context.Context, audit AuthAuditRecorder, tenantID, corrID, action string, r *http.Request) {
+recordAuthFailureDetails(ctx, audit, tenantID, corrID, action, "", r)
+}
+
+func recordAuthFailureDetails(ctx context.Context, audit AuthAuditRecorder, tenantID, corrID, action, details string, r *http.Request) {
 if audit == nil {
 return
 }
 
+// Failures with no resolvable tenant get their own partition and chain
+if tenantID == "" {
+tenantID = UnattributedTenantID
+}
+
 entry := AuditLogEntry{
-ID:        generateID(),
 TenantID:  tenantID,
 CorrID:    corrID,
 Action:    action,
 IPAddress: getClientIP(r),
 UserAgent: r.UserAgent(),
+Details:   details,
 Timestamp: time.Now().UTC(),
 }
-
-// Get previous hash for chain
-if tenantID != "" {
-if prev, err := audit.Last(ctx, tenantID); err == nil {
-entry.PrevHash = prev.Hash
-}
+appendAuditEntry(ctx, audit, entry)
 }
 
-// Compute hash using JSON serialization to avoid delimiter collision issues
-hash, err := computeEntryHash(&entry)
-if err != nil {
-// Log error but continue with empty hash rather than blocking audit
-slog.Error("failed to compute audit hash", "error", err, "entryID", entry.ID)
-hash = ""
-}
-entry.Hash = hash
-
-_ = audit.Record(ctx, entry)
+func recordAuthSuccess(ctx context.Context, audit AuthAuditRecorder, tenantID, corrID, keyID string, r *http.Request) {
+recordAuthSuccessDetails(ctx, audit, tenantID, corrID, keyID, "", r)
 }
 
-func recordAuthSuccess(ctx context.Context, audit AuthAuditRecorder, tenantID, corrID, keyID string, r *http.Request) {
+func recordAuthSuccessDetails(ctx context.Context, audit AuthAuditRecorder, tenantID, corrID, keyID, details string, r *http.Request) {
 if audit == nil {
 return
 }
 
 entry := AuditLogEntry{
-ID:        generateID(),
 TenantID:  tenantID,
 CorrID:    corrID,
 Action:    "auth.success",
 KeyID:     keyID,
 IPAddress: getClientIP(r),
 UserAgent: r.UserAgent(),
+Details:   details,
 Timestamp: time.Now().UTC(),
 }
+appendAuditEntry(ctx, audit, entry)
+}
+
+// recordAuditEvent chains and records an arbitrary audit entry (e.g. admin actions).
+// ID, request metadata, timestamp, and hashes are filled in here.
+func recordAuditEvent(ctx context.Context, audit AuthAuditRecorder, entry AuditLogEntry, r *http.Request) {
+if audit == nil {
+return
+}
+
+entry.IPAddress = getClientIP(r)
+entry.UserAgent = r.UserAgent()
+entry.Timestamp = time.Now().UTC()
+appendAuditEntry(ctx, audit, entry)
+}
+
+// appendAuditEntry assigns entry an ID, chains it to the tenant's last entry,
+// seals it with the recorder's hash algorithm and records it. The caller sets
+// Timestamp.
+func appendAuditEntry(ctx context.Context, audit AuthAuditRecorder, entry AuditLogEntry) {
+entry.ID = generateID()
+entry.HashAlg = auditHashAlgorithm(audit)
 
 // Get previous hash for chain
-if prev, err := audit.Last(ctx, tenantID); err == nil {
+if prev, err := audit.Last(ctx, entry.TenantID); err == nil {
 entry.PrevHash = prev.Hash
 }
 
-// Compute hash using JSON serialization to avoid delimiter collision issues
 hash, err := computeEntryHash(&entry)
 if err != nil {
-// Log error but continue with empty hash rather than blocking audit
 slog.Error("failed to compute audit hash", "error", err, "entryID", entry.ID)
 hash = ""
 }
@@ -282,6 +597,17 @@ entry.Hash = hash
 _ = audit.Record(ctx, entry)
 }
 
+// auditHashAlgorithm returns the algorithm new entries recorded to audit are
+// sealed with: the recorder's own when it has one, auditchain.SHA256 otherwise.
+func auditHashAlgorithm(audit AuthAuditRecorder) string {
+if h, ok := audit.(interface{ HashAlgorithm() string }); ok {
+if alg := h.HashAlgorithm(); alg != "" {
+return alg
+}
+}
+return auditchain.SHA256
+}
+
 func getClientIP(r *http.Request) string {
 // Check X-Forwarded-For first (for proxies)
 if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
@@ -307,10 +633,18 @@ func generateCorrID() string {
     return hex.EncodeToString(b)
 }
 
-// computeEntryHash computes the hash for an audit log entry using JSON serialization
-// to avoid delimiter collision issues. All fields except Hash and PrevHash are included
-// to ensure the integrity of the complete audit record.
+// computeEntryHash computes the hash for an audit log entry. Entries with a
+// HashAlg are sealed with auditchain.Sum over every field except Hash; entries
+// without one use the legacy JSON serialization, so chains recorded before
+// HashAlg existed still verify.
 func computeEntryHash(entry *AuditLogEntry) (string, error) {
+if entry.HashAlg != "" {
+if err := auditchain.ValidateAlgorithm(entry.HashAlg); err != nil {
+return "", err
+}
+return auditchain.Sum(entry.HashAlg, entry.ID, entry.TenantID, entry.CorrID, entry.Action, entry.KeyID,
+entry.IPAddress, entry.UserAgent, entry.Details, entry.Timestamp.UTC().Format(time.RFC3339Nano), entry.PrevHash), nil
+}
 hashData := struct {
 ID        string `json:"id"`
 TenantID  string `json:"tenantId"`
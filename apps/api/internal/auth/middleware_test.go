@@ -1,13 +1,21 @@
 package auth
 
 import (
+	"bytes"
 	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/json"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
+
+	"github.com/yourorg/yourapp/apps/api/internal/ratelimit"
 )
 
 // TestMiddleware_ExpiredKey tests the middleware with an expired API key.
@@ -71,7 +79,7 @@ func TestMiddleware_ExpiredKey(t *testing.T) {
 	}
 
 	// Verify audit log was recorded
-	entries := audit.GetEntries("")
+	entries := audit.GetEntries(UnattributedTenantID)
 	found := false
 	for _, entry := range entries {
 		if entry.Action == "auth.invalid_key" {
@@ -369,7 +377,7 @@ func TestMiddleware_RevokedKey(t *testing.T) {
 	}
 
 	// Verify audit log was recorded
-	entries := audit.GetEntries("")
+	entries := audit.GetEntries(UnattributedTenantID)
 	found := false
 	for _, entry := range entries {
 		if entry.Action == "auth.invalid_key" {
@@ -420,7 +428,7 @@ func TestMiddleware_MissingAPIKey(t *testing.T) {
 	}
 
 	// Verify audit log was recorded
-	entries := audit.GetEntries("")
+	entries := audit.GetEntries(UnattributedTenantID)
 	found := false
 	for _, entry := range entries {
 		if entry.Action == "auth.missing_key" {
@@ -472,7 +480,7 @@ func TestMiddleware_InvalidAPIKey(t *testing.T) {
 	}
 
 	// Verify audit log was recorded
-	entries := audit.GetEntries("")
+	entries := audit.GetEntries(UnattributedTenantID)
 	found := false
 	for _, entry := range entries {
 		if entry.Action == "auth.invalid_format" || entry.Action == "auth.invalid_key" {
@@ -770,6 +778,82 @@ func TestRequireScope_NoAuth(t *testing.T) {
 	}
 }
 
+// serveWithScopes runs scopeMiddleware for an actor holding scopes and
+// returns the response.
+func serveWithScopes(scopeMiddleware func(http.Handler) http.Handler, scopes ...string) *httptest.ResponseRecorder {
+	handler := scopeMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	req = req.WithContext(ContextWithActor(req.Context(), &Actor{TenantID: "test-tenant", Scopes: scopes}))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+// TestRequireAnyScope_SecondScope tests that holding only the second acceptable scope is enough.
+func TestRequireAnyScope_SecondScope(t *testing.T) {
+	rec := serveWithScopes(RequireAnyScope("admin:read", "admin:write"), "admin:write")
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+}
+
+// TestRequireAnyScope_NeitherScope tests the 403 when the actor holds none of the scopes.
+func TestRequireAnyScope_NeitherScope(t *testing.T) {
+	rec := serveWithScopes(RequireAnyScope("admin:read", "admin:write"), "audit:read")
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected status %d, got %d", http.StatusForbidden, rec.Code)
+	}
+
+	var authErr AuthError
+	if err := json.NewDecoder(rec.Body).Decode(&authErr); err != nil {
+		t.Fatalf("failed to decode error response: %v", err)
+	}
+	if authErr.Code != "INSUFFICIENT_SCOPE" {
+		t.Errorf("expected error code INSUFFICIENT_SCOPE, got %s", authErr.Code)
+	}
+	if authErr.Message != "Required scope: one of admin:read, admin:write" {
+		t.Errorf("expected message listing both scopes, got %s", authErr.Message)
+	}
+}
+
+// TestRequireAllScopes_MissingOne tests the 403 when the actor lacks one of the scopes.
+func TestRequireAllScopes_MissingOne(t *testing.T) {
+	rec := serveWithScopes(RequireAllScopes("audit:read", "audit:write"), "audit:read")
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected status %d, got %d", http.StatusForbidden, rec.Code)
+	}
+
+	var authErr AuthError
+	if err := json.NewDecoder(rec.Body).Decode(&authErr); err != nil {
+		t.Fatalf("failed to decode error response: %v", err)
+	}
+	if authErr.Code != "INSUFFICIENT_SCOPE" {
+		t.Errorf("expected error code INSUFFICIENT_SCOPE, got %s", authErr.Code)
+	}
+	if authErr.Message != "Required scopes: audit:read, audit:write" {
+		t.Errorf("expected message listing both scopes, got %s", authErr.Message)
+	}
+}
+
+// TestRequireScopes_EmptyPanics tests that both constructors refuse an empty scope list.
+func TestRequireScopes_EmptyPanics(t *testing.T) {
+	for name, build := range map[string]func(...string) func(http.Handler) http.Handler{
+		"RequireAnyScope":  RequireAnyScope,
+		"RequireAllScopes": RequireAllScopes,
+	} {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s() with no scopes did not panic", name)
+				}
+			}()
+			build()
+		})
+	}
+}
+
 // TestMiddleware_AuditLogChaining tests that audit log entries are properly chained.
 func TestMiddleware_AuditLogChaining(t *testing.T) {
 	cfg := Config{
@@ -879,3 +963,584 @@ func TestMiddleware_XAPIKeyHeader(t *testing.T) {
 		t.Errorf("expected status %d, got %d", http.StatusOK, rec.Code)
 	}
 }
+
+// fakeMetrics records counter increments for assertions.
+type fakeMetrics struct {
+	mu        sync.Mutex
+	counters  map[string]int
+	latencies int
+}
+
+func newFakeMetrics() *fakeMetrics {
+	return &fakeMetrics{counters: map[string]int{}}
+}
+
+func (m *fakeMetrics) IncCounter(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.counters[name]++
+}
+
+func (m *fakeMetrics) ObserveValidationLatency(time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.latencies++
+}
+
+// stubKeyStore returns a fixed tenant and key so middleware checks that the
+// in-memory store would otherwise short-circuit can be exercised directly.
+type stubKeyStore struct {
+	tenant *Tenant
+	key    *APIKey
+	err    error
+}
+
+func (s *stubKeyStore) ValidateKey(ctx context.Context, rawKey string) (*Tenant, *APIKey, error) {
+	if s.err != nil {
+		return nil, nil, s.err
+	}
+	return s.tenant, s.key, nil
+}
+
+func (s *stubKeyStore) CreateKey(ctx context.Context, tenantID string, name string, scopes []string, expiresAt *time.Time) (*APIKey, string, error) {
+	return nil, "", nil
+}
+
+func (s *stubKeyStore) RotateKey(ctx context.Context, oldKeyID string) (*APIKey, string, error) {
+	return nil, "", nil
+}
+
+func (s *stubKeyStore) RevokeKey(ctx context.Context, keyID string) error { return nil }
+
+func (s *stubKeyStore) RevokeTenantKeys(ctx context.Context, tenantID, exceptKeyID string) (int, error) {
+	return 0, nil
+}
+
+func (s *stubKeyStore) ListKeys(ctx context.Context, tenantID string) ([]APIKey, error) {
+	return nil, nil
+}
+
+func (s *stubKeyStore) ListKeysPage(ctx context.Context, tenantID string, opts ListKeysOptions) (KeyPage, error) {
+	return KeyPage{}, nil
+}
+
+func (s *stubKeyStore) UpdateLastUsed(ctx context.Context, keyID string) error { return nil }
+
+// TestMiddleware_Metrics tests that each auth path increments the matching counter.
+func TestMiddleware_Metrics(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	active := &Tenant{ID: "test-tenant", Status: "active"}
+
+	tests := []struct {
+		name       string
+		store      *stubKeyStore
+		header     string
+		limit      int
+		requests   int
+		wantStatus int
+		wantMetric string
+	}{
+		{
+			name:       "success",
+			store:      &stubKeyStore{tenant: active, key: &APIKey{ID: "k1", Scopes: []string{"*"}}},
+			header:     "Bearer ppk_valid",
+			requests:   1,
+			wantStatus: http.StatusOK,
+			wantMetric: MetricAuthSuccess,
+		},
+		{
+			name:       "missing key",
+			store:      &stubKeyStore{},
+			requests:   1,
+			wantStatus: http.StatusUnauthorized,
+			wantMetric: MetricAuthMissingKey,
+		},
+		{
+			name:       "invalid key",
+			store:      &stubKeyStore{err: ErrInvalidAPIKey},
+			header:     "Bearer ppk_invalid",
+			requests:   1,
+			wantStatus: http.StatusUnauthorized,
+			wantMetric: MetricAuthInvalidKey,
+		},
+		{
+			name:       "expired",
+			store:      &stubKeyStore{tenant: active, key: &APIKey{ID: "k1", ExpiresAt: &past}},
+			header:     "Bearer ppk_expired",
+			requests:   1,
+			wantStatus: http.StatusUnauthorized,
+			wantMetric: MetricAuthExpired,
+		},
+		{
+			name:       "revoked",
+			store:      &stubKeyStore{tenant: active, key: &APIKey{ID: "k1", RevokedAt: &past}},
+			header:     "Bearer ppk_revoked",
+			requests:   1,
+			wantStatus: http.StatusUnauthorized,
+			wantMetric: MetricAuthRevoked,
+		},
+		{
+			name:       "tenant suspended",
+			store:      &stubKeyStore{tenant: &Tenant{ID: "test-tenant", Status: "suspended"}, key: &APIKey{ID: "k1"}},
+			header:     "Bearer ppk_suspended",
+			requests:   1,
+			wantStatus: http.StatusForbidden,
+			wantMetric: MetricTenantSuspended,
+		},
+		{
+			name:       "rate limited",
+			store:      &stubKeyStore{tenant: active, key: &APIKey{ID: "k1"}},
+			header:     "Bearer ppk_limited",
+			limit:      1,
+			requests:   2,
+			wantStatus: http.StatusTooManyRequests,
+			wantMetric: MetricRateLimited,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metrics := newFakeMetrics()
+			opts := []MiddlewareOption{WithMetrics(metrics)}
+			if tt.limit > 0 {
+				opts = append(opts, WithRateLimiter(NewRateLimiter(tt.limit, time.Minute)))
+			}
+			handler := Middleware(tt.store, nil, Config{}, nil, opts...)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			var rec *httptest.ResponseRecorder
+			for i := 0; i < tt.requests; i++ {
+				req := httptest.NewRequest(http.MethodGet, "/test", nil)
+				if tt.header != "" {
+					req.Header.Set("Authorization", tt.header)
+				}
+				rec = httptest.NewRecorder()
+				handler.ServeHTTP(rec, req)
+			}
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("expected status %d, got %d", tt.wantStatus, rec.Code)
+			}
+
+			metrics.mu.Lock()
+			defer metrics.mu.Unlock()
+			if metrics.counters[tt.wantMetric] != 1 {
+				t.Errorf("expected %s to fire once, got counters %v", tt.wantMetric, metrics.counters)
+			}
+			for name, count := range metrics.counters {
+				if name != tt.wantMetric && name != MetricAuthSuccess && count > 0 {
+					t.Errorf("unexpected counter %s fired %d times", name, count)
+				}
+			}
+			if tt.header != "" && metrics.latencies != tt.requests {
+				t.Errorf("expected %d latency observations, got %d", tt.requests, metrics.latencies)
+			}
+		})
+	}
+}
+
+// TestExtractAPIKey tests header precedence, trimming, and scheme matching.
+func TestExtractAPIKey(t *testing.T) {
+	tests := []struct {
+		name          string
+		authorization string
+		xAPIKey       string
+		want          string
+	}{
+		{"bearer", "Bearer ppk_abc", "", "ppk_abc"},
+		{"apikey", "ApiKey ppk_abc", "", "ppk_abc"},
+		{"lowercase bearer", "bearer ppk_abc", "", "ppk_abc"},
+		{"uppercase apikey", "APIKEY ppk_abc", "", "ppk_abc"},
+		{"mixed case bearer", "BeArEr ppk_abc", "", "ppk_abc"},
+		{"surrounding spaces", "  Bearer   ppk_abc  ", "", "ppk_abc"},
+		{"raw key", "ppk_abc", "", "ppk_abc"},
+		{"bearer without token", "Bearer", "", ""},
+		{"bearer with blank token", "Bearer    ", "", ""},
+		{"x-api-key only", "", "ppk_xyz", "ppk_xyz"},
+		{"x-api-key trimmed", "", "  ppk_xyz ", "ppk_xyz"},
+		{"authorization wins over x-api-key", "Bearer ppk_abc", "ppk_xyz", "ppk_abc"},
+		{"empty bearer does not fall back", "Bearer ", "ppk_xyz", ""},
+		{"blank authorization falls back", "   ", "ppk_xyz", "ppk_xyz"},
+		{"neither header", "", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/test", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			if tt.xAPIKey != "" {
+				req.Header.Set("X-API-Key", tt.xAPIKey)
+			}
+			if got := extractAPIKey(req); got != tt.want {
+				t.Errorf("extractAPIKey() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestMiddleware_EmptyBearerIsMissingKey tests that an empty bearer token yields AUTH_REQUIRED.
+func TestMiddleware_EmptyBearerIsMissingKey(t *testing.T) {
+	cfg := Config{APIKeyHashAlgorithm: "bcrypt", BcryptCost: 10}
+	store := NewInMemoryAPIKeyStore(cfg)
+	handler := Middleware(store, nil, cfg, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	req.Header.Set("Authorization", "Bearer ")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	var authErr AuthError
+	if err := json.NewDecoder(rec.Body).Decode(&authErr); err != nil {
+		t.Fatalf("failed to decode error response: %v", err)
+	}
+	if authErr.Code != "AUTH_REQUIRED" {
+		t.Errorf("expected error code AUTH_REQUIRED, got %s", authErr.Code)
+	}
+}
+
+// TestMiddleware_RateLimitEvent tests that a throttled request records a
+// rate-limit event with the effective limit, in the recorder and the audit chain.
+func TestMiddleware_RateLimitEvent(t *testing.T) {
+	store := &stubKeyStore{tenant: &Tenant{ID: "test-tenant", Status: "active"}, key: &APIKey{ID: "k1"}}
+	audit := NewInMemoryAuthAuditRecorder()
+	events := ratelimit.NewMemoryRecorder()
+	cfg := Config{AuditRateLimits: true}
+	handler := Middleware(store, audit, cfg, nil,
+		WithRateLimiter(NewRateLimiter(2, time.Minute)),
+		WithRateLimitRecorder(events),
+	)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	var rec *httptest.ResponseRecorder
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		req.Header.Set("Authorization", "Bearer ppk_limited")
+		req.Header.Set("X-Correlation-Id", "corr-limited")
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+	}
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 on third request, got %d", rec.Code)
+	}
+
+	got := events.Events("test-tenant")
+	if len(got) != 1 {
+		t.Fatalf("expected 1 rate-limit event, got %d", len(got))
+	}
+	ev := got[0]
+	if ev.Limiter != LimiterAPIKey || ev.Key != "k1" || ev.Limit != 2 || ev.WindowSeconds != 60 || ev.CorrID != "corr-limited" {
+		t.Fatalf("unexpected event %+v", ev)
+	}
+	if ev.RetryAfterSeconds < 1 {
+		t.Fatalf("expected retry-after on event, got %d", ev.RetryAfterSeconds)
+	}
+
+	entries := audit.GetEntries("test-tenant")
+	last := entries[len(entries)-1]
+	if last.Action != "auth.rate_limited" {
+		t.Fatalf("expected auth.rate_limited audit entry, got %s", last.Action)
+	}
+	var details ratelimit.Event
+	if err := json.Unmarshal([]byte(last.Details), &details); err != nil || details.Limit != 2 {
+		t.Fatalf("expected event in audit details, got %q (%v)", last.Details, err)
+	}
+}
+
+func TestMiddleware_RateLimitHeaders(t *testing.T) {
+	store := &stubKeyStore{tenant: &Tenant{ID: "test-tenant", Status: "active"}, key: &APIKey{ID: "k1"}}
+	handler := Middleware(store, nil, Config{}, nil, WithRateLimiter(NewRateLimiter(2, time.Minute)))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	serve := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		req.Header.Set("Authorization", "Bearer ppk_limited")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for i, want := range []string{"1", "0"} {
+		rec := serve()
+		if rec.Code != http.StatusOK || rec.Header().Get("X-RateLimit-Limit") != "2" || rec.Header().Get("X-RateLimit-Remaining") != want {
+			t.Fatalf("request %d: got %d limit %q remaining %q, want remaining %s", i, rec.Code, rec.Header().Get("X-RateLimit-Limit"), rec.Header().Get("X-RateLimit-Remaining"), want)
+		}
+		reset, err := strconv.ParseInt(rec.Header().Get("X-RateLimit-Reset"), 10, 64)
+		if now := time.Now().Unix(); err != nil || reset <= now || reset > now+61 {
+			t.Fatalf("request %d: X-RateLimit-Reset %q should be within the window", i, rec.Header().Get("X-RateLimit-Reset"))
+		}
+	}
+	rec := serve()
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("X-RateLimit-Remaining") != "0" || rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("expected 429 with remaining 0 and Retry-After, got %d %v", rec.Code, rec.Header())
+	}
+
+	unlimited := Middleware(store, nil, Config{}, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	req.Header.Set("Authorization", "Bearer ppk_limited")
+	rec = httptest.NewRecorder()
+	unlimited.ServeHTTP(rec, req)
+	if rec.Header().Get("X-RateLimit-Limit") != "" {
+		t.Fatal("expected no rate-limit headers without a limiter")
+	}
+}
+
+// TestMiddleware_StrictAuthScheme tests that strict mode rejects unknown
+// Authorization schemes while Bearer keys still authenticate.
+func TestMiddleware_StrictAuthScheme(t *testing.T) {
+	store := &stubKeyStore{tenant: &Tenant{ID: "test-tenant", Status: "active"}, key: &APIKey{ID: "k1", Scopes: []string{"*"}}}
+	serve := func(cfg Config, authorization string) (int, AuthError) {
+		handler := Middleware(store, nil, cfg, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		req.Header.Set("Authorization", authorization)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		var authErr AuthError
+		_ = json.NewDecoder(rec.Body).Decode(&authErr)
+		return rec.Code, authErr
+	}
+
+	strict := Config{StrictAuthScheme: true}
+	code, authErr := serve(strict, "Basic dXNlcjpwYXNz")
+	if code != http.StatusUnauthorized || authErr.Code != "AUTH_SCHEME_UNSUPPORTED" {
+		t.Errorf("strict Basic: expected 401 AUTH_SCHEME_UNSUPPORTED, got %d %s", code, authErr.Code)
+	}
+	if code, authErr := serve(strict, "ppk_raw"); authErr.Code != "AUTH_SCHEME_UNSUPPORTED" {
+		t.Errorf("strict raw key: expected AUTH_SCHEME_UNSUPPORTED, got %d %s", code, authErr.Code)
+	}
+	if code, _ := serve(strict, "Bearer ppk_valid"); code != http.StatusOK {
+		t.Errorf("strict Bearer: expected 200, got %d", code)
+	}
+	if code, _ := serve(strict, "ApiKey ppk_valid"); code != http.StatusOK {
+		t.Errorf("strict ApiKey: expected 200, got %d", code)
+	}
+	// Lenient mode keeps the raw-key fallback.
+	if code, _ := serve(Config{}, "ppk_raw"); code != http.StatusOK {
+		t.Errorf("lenient raw key: expected 200, got %d", code)
+	}
+}
+
+// TestMiddleware_QueryParamKey tests that an api_key query parameter
+// authenticates when allowed, loses to a header, and is redacted from the URL.
+func TestMiddleware_QueryParamKey(t *testing.T) {
+	cfg := Config{APIKeyHashAlgorithm: "bcrypt", BcryptCost: 10, EnableAuditLog: true, AllowQueryParamKey: true}
+	store := NewInMemoryAPIKeyStore(cfg)
+	audit := NewInMemoryAuthAuditRecorder()
+	ctx := context.Background()
+	if err := store.CreateTenant(ctx, Tenant{ID: "test-tenant", Status: "active"}); err != nil {
+		t.Fatalf("CreateTenant() error = %v", err)
+	}
+	headerKey, headerRaw, _ := store.CreateKey(ctx, "test-tenant", "Header", []string{"audit:read"}, nil)
+	queryKey, queryRaw, _ := store.CreateKey(ctx, "test-tenant", "Query", []string{"audit:read"}, nil)
+
+	var gotKeyID, gotURL, gotRequestURI string
+	handler := Middleware(store, audit, cfg, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actor, _ := ActorFromContext(r.Context())
+		gotKeyID, gotURL, gotRequestURI = actor.KeyID, r.URL.String(), r.RequestURI
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/audit/zip/download?exp=1&api_key="+queryRaw, nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("query key: status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	if gotKeyID != queryKey.ID {
+		t.Errorf("query key: actor key = %s, want %s", gotKeyID, queryKey.ID)
+	}
+	for _, u := range []string{gotURL, gotRequestURI} {
+		if strings.Contains(u, queryRaw) || !strings.Contains(u, "api_key=REDACTED") || !strings.Contains(u, "exp=1") {
+			t.Errorf("downstream URL %q should keep exp and redact the key", u)
+		}
+	}
+	entries := audit.GetEntries("test-tenant")
+	if len(entries) != 1 || entries[0].Action != "auth.success" || !strings.Contains(entries[0].Details, QueryParamKey) {
+		t.Errorf("expected an auth.success entry noting the query parameter, got %+v", entries)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/audit/zip/download?api_key="+queryRaw, nil)
+	req.Header.Set("Authorization", "Bearer "+headerRaw)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || gotKeyID != headerKey.ID {
+		t.Errorf("header and query: status = %d, actor key = %s, want the header key %s", rec.Code, gotKeyID, headerKey.ID)
+	}
+}
+
+// TestMiddleware_QueryParamKeyOffByDefault tests that the api_key query
+// parameter is ignored unless AllowQueryParamKey is set.
+func TestMiddleware_QueryParamKeyOffByDefault(t *testing.T) {
+	if LoadConfig().AllowQueryParamKey {
+		t.Fatal("AllowQueryParamKey should default to false")
+	}
+
+	cfg := Config{APIKeyHashAlgorithm: "bcrypt", BcryptCost: 10}
+	store := NewInMemoryAPIKeyStore(cfg)
+	if err := store.CreateTenant(context.Background(), Tenant{ID: "test-tenant", Status: "active"}); err != nil {
+		t.Fatalf("CreateTenant() error = %v", err)
+	}
+	_, raw, _ := store.CreateKey(context.Background(), "test-tenant", "Query", []string{"audit:read"}, nil)
+	handler := Middleware(store, nil, cfg, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/test?api_key="+raw, nil))
+	var authErr AuthError
+	if err := json.NewDecoder(rec.Body).Decode(&authErr); err != nil {
+		t.Fatalf("failed to decode error response: %v", err)
+	}
+	if rec.Code != http.StatusUnauthorized || authErr.Code != "AUTH_REQUIRED" {
+		t.Errorf("status = %d code = %s, want 401 AUTH_REQUIRED", rec.Code, authErr.Code)
+	}
+}
+
+// TestMiddleware_PlanRateLimit tests that a free tenant's key is limited to the
+// plan's rate even when the key asks for more and the limiter allows more.
+func TestMiddleware_PlanRateLimit(t *testing.T) {
+	store := &stubKeyStore{
+		tenant: &Tenant{ID: "free-tenant", Plan: "free", Status: "active"},
+		key:    &APIKey{ID: "k1", RateLimit: 5},
+	}
+	cfg := Config{PlanPolicies: map[string]PlanPolicy{"free": {RateLimitPerMinute: 2, MaxRateLimitPerMinute: 2}}}
+	var gotLimit int
+	handler := Middleware(store, nil, cfg, nil, WithRateLimiter(NewRateLimiter(100, time.Minute)))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actor, _ := ActorFromContext(r.Context())
+		gotLimit = actor.RateLimit
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		req.Header.Set("Authorization", "Bearer ppk_free")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		want := http.StatusOK
+		if i == 2 {
+			want = http.StatusTooManyRequests
+		}
+		if rec.Code != want || rec.Header().Get("X-RateLimit-Limit") != "2" {
+			t.Fatalf("request %d: got %d limit %q, want %d limit 2", i, rec.Code, rec.Header().Get("X-RateLimit-Limit"), want)
+		}
+	}
+	if gotLimit != 2 {
+		t.Errorf("actor rate limit = %d, want 2", gotLimit)
+	}
+}
+
+// TestMiddleware_MTLS tests that a verified client certificate authenticates
+// by its CN, and that requests without one fall back to API keys.
+func TestMiddleware_MTLS(t *testing.T) {
+	cfg := Config{APIKeyHashAlgorithm: "bcrypt", BcryptCost: 10, EnableMTLS: true}
+	store := NewInMemoryAPIKeyStore(cfg)
+	ctx := context.Background()
+	if err := store.CreateTenant(ctx, Tenant{ID: "acme", Plan: "enterprise", Status: "active"}); err != nil {
+		t.Fatalf("CreateTenant() error = %v", err)
+	}
+	_, rawKey, _ := store.CreateKey(ctx, "acme", "Key", []string{"audit:read"}, nil)
+	resolver := StaticMTLSResolver{"billing.acme.example": {TenantID: "acme", Scopes: []string{"invoice:write"}}}
+
+	newHandler := func(cfg Config) (http.Handler, **Actor) {
+		var got *Actor
+		h := Middleware(store, nil, cfg, nil, WithMTLSResolver(resolver))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got, _ = ActorFromContext(r.Context())
+			w.WriteHeader(http.StatusOK)
+		}))
+		return h, &got
+	}
+	withCert := func(req *http.Request, cn string) *http.Request {
+		req.TLS.VerifiedChains = [][]*x509.Certificate{{{Subject: pkix.Name{CommonName: cn}}}}
+		return req
+	}
+
+	handler, actor := newHandler(cfg)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, withCert(httptest.NewRequest(http.MethodGet, "https://api.example/test", nil), "billing.acme.example"))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("mapped CN: status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	if a := *actor; a == nil || a.ActorType != "mtls" || a.TenantID != "acme" || a.Subject != "billing.acme.example" || !a.HasScope("invoice:write") || a.HasScope("audit:read") {
+		t.Errorf("mapped CN: actor = %+v, want an mtls actor for acme with invoice:write", *actor)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, withCert(httptest.NewRequest(http.MethodGet, "https://api.example/test", nil), "unknown.example"))
+	if rec.Code != http.StatusUnauthorized || !strings.Contains(rec.Body.String(), "INVALID_CERT") {
+		t.Errorf("unknown CN: status = %d body = %s, want 401 INVALID_CERT", rec.Code, rec.Body.String())
+	}
+
+	// No client certificate: the API key authenticates as before
+	req := httptest.NewRequest(http.MethodGet, "https://api.example/test", nil)
+	req.Header.Set("Authorization", "Bearer "+rawKey)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || (*actor).ActorType != "api_key" {
+		t.Errorf("no cert: status = %d actor = %+v, want the API key actor", rec.Code, *actor)
+	}
+
+	// An unverified peer certificate is ignored
+	req = httptest.NewRequest(http.MethodGet, "https://api.example/test", nil)
+	req.TLS.PeerCertificates = []*x509.Certificate{{Subject: pkix.Name{CommonName: "billing.acme.example"}}}
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("unverified cert: status = %d, want 401", rec.Code)
+	}
+
+	// mTLS disabled: the certificate is ignored
+	cfg.EnableMTLS = false
+	disabled, _ := newHandler(cfg)
+	rec = httptest.NewRecorder()
+	disabled.ServeHTTP(rec, withCert(httptest.NewRequest(http.MethodGet, "https://api.example/test", nil), "billing.acme.example"))
+	if rec.Code != http.StatusUnauthorized || !strings.Contains(rec.Body.String(), "AUTH_REQUIRED") {
+		t.Errorf("disabled: status = %d body = %s, want 401 AUTH_REQUIRED", rec.Code, rec.Body.String())
+	}
+}
+
+// TestMiddleware_AuthFailureLogsPrefixOnly tests that a rejected key is logged
+// and audited by its prefix, and that the full key appears in neither.
+func TestMiddleware_AuthFailureLogsPrefixOnly(t *testing.T) {
+	cfg := Config{APIKeyHashAlgorithm: "bcrypt", BcryptCost: 10, EnableAuditLog: true}
+	store := NewInMemoryAPIKeyStore(cfg)
+	audit := NewInMemoryAuthAuditRecorder()
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&logs, nil))
+	handler := Middleware(store, audit, cfg, logger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	rawKey, prefix, err := GenerateAPIKey()
+	if err != nil {
+		t.Fatalf("GenerateAPIKey() error = %v", err)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	req.Header.Set("Authorization", "Bearer "+rawKey)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want 401", rec.Code)
+	}
+
+	out := logs.String()
+	if !strings.Contains(out, `"keyPrefix":"`+prefix+`"`) || !strings.Contains(out, "auth.invalid_key") {
+		t.Errorf("log should carry the prefix %s and the reason, got %s", prefix, out)
+	}
+	if strings.Contains(out, rawKey) || strings.Contains(out, strings.TrimPrefix(rawKey, KeyPrefix)) {
+		t.Errorf("log leaked the full key: %s", out)
+	}
+	entries := audit.GetEntries(UnattributedTenantID)
+	if len(entries) != 1 || entries[0].Details != "keyPrefix="+prefix {
+		t.Fatalf("expected one audit entry with the prefix, got %+v", entries)
+	}
+	if strings.Contains(entries[0].Details, rawKey) {
+		t.Errorf("audit entry leaked the full key: %+v", entries[0])
+	}
+}
@@ -0,0 +1,141 @@
+package auth
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ErrUnknownCertificate is returned by an MTLSResolver for a CN it does not map.
+var ErrUnknownCertificate = errors.New("unknown client certificate")
+
+// MTLSIdentity is the tenant and scopes a client certificate authenticates as.
+type MTLSIdentity struct {
+	TenantID string   `json:"tenantId"`
+	Scopes   []string `json:"scopes"`
+}
+
+// MTLSResolver maps the subject CN of a verified client certificate to an identity.
+type MTLSResolver interface {
+	ResolveCN(ctx context.Context, cn string) (MTLSIdentity, error)
+}
+
+// StaticMTLSResolver is an MTLSResolver backed by a fixed CN -> identity table.
+type StaticMTLSResolver map[string]MTLSIdentity
+
+// ResolveCN returns the identity for cn, or ErrUnknownCertificate.
+func (m StaticMTLSResolver) ResolveCN(ctx context.Context, cn string) (MTLSIdentity, error) {
+	id, ok := m[cn]
+	if !ok {
+		return MTLSIdentity{}, ErrUnknownCertificate
+	}
+	return id, nil
+}
+
+// getMTLSIdentities reads a JSON object of certificate CN to MTLSIdentity.
+// Malformed values are logged and leave no CN mapped.
+func getMTLSIdentities(key string) StaticMTLSResolver {
+	v, ok := os.LookupEnv(key)
+	if !ok || v == "" {
+		return StaticMTLSResolver{}
+	}
+	var identities StaticMTLSResolver
+	if err := json.Unmarshal([]byte(v), &identities); err != nil {
+		slog.Error("malformed mTLS identities, mapping no certificates", "env", key, "error", err)
+		return StaticMTLSResolver{}
+	}
+	return identities
+}
+
+// verifiedClientCert returns the leaf of the first verified client certificate
+// chain, or nil when the connection carries none. Unverified peer certificates
+// are ignored.
+func verifiedClientCert(r *http.Request) *x509.Certificate {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return r.TLS.VerifiedChains[0][0]
+}
+
+// serveMTLS authenticates a verified client certificate and maps its subject CN
+// into an "mtls" actor.
+func serveMTLS(w http.ResponseWriter, r *http.Request, next http.Handler, store APIKeyStore, audit AuthAuditRecorder, cfg Config, logger *slog.Logger, o middlewareOptions, corrID string, cert *x509.Certificate) {
+	cn := cert.Subject.CommonName
+	identity, err := o.mtls.ResolveCN(r.Context(), cn)
+	if err != nil || cn == "" {
+		o.metrics.IncCounter(MetricAuthInvalidCert)
+		writeAuthError(w, http.StatusUnauthorized, "INVALID_CERT", "Client certificate is not recognised", corrID, false)
+		recordAuthFailure(r.Context(), audit, "", corrID, "auth.invalid_cert", r)
+		return
+	}
+
+	// The resolved tenant must exist and be active
+	tenants, ok := store.(TenantStore)
+	if !ok {
+		o.metrics.IncCounter(MetricAuthInvalidCert)
+		writeAuthError(w, http.StatusUnauthorized, "INVALID_CERT", "Client certificate is not recognised", corrID, false)
+		recordAuthFailure(r.Context(), audit, "", corrID, "auth.invalid_cert", r)
+		return
+	}
+	tenant, err := tenants.GetTenant(r.Context(), identity.TenantID)
+	if err != nil {
+		o.metrics.IncCounter(MetricAuthInvalidCert)
+		writeAuthError(w, http.StatusUnauthorized, "INVALID_CERT", "Client certificate is not recognised", corrID, false)
+		recordAuthFailure(r.Context(), audit, "", corrID, "auth.invalid_cert", r)
+		return
+	}
+	trace.SpanFromContext(r.Context()).SetAttributes(attribute.String("tenant.id", tenant.ID), attribute.String("auth.subject", cn))
+	if tenant.Status != "active" {
+		o.metrics.IncCounter(MetricTenantSuspended)
+		writeAuthError(w, http.StatusForbidden, "TENANT_SUSPENDED", "Tenant account is suspended", corrID, false)
+		recordAuthFailure(r.Context(), audit, tenant.ID, corrID, "auth.tenant_suspended", r)
+		return
+	}
+
+	// Check rate limit, keyed by certificate CN
+	rateLimit := cfg.EffectiveRateLimit(tenant.Plan, 0)
+	if o.limiter != nil {
+		limitKey := "mtls:" + tenant.ID + ":" + cn
+		allowed, retryAfter := o.limiter.AllowRate(limitKey, rateLimit)
+		setRateLimitHeaders(w, o.limiter, limitKey, rateLimit)
+		if !allowed {
+			rejectRateLimited(w, r, audit, cfg, o, tenant.ID, corrID, LimiterMTLS, limitKey, rateLimit, retryAfter)
+			return
+		}
+	}
+
+	actor := &Actor{
+		TenantID:  tenant.ID,
+		Subject:   cn,
+		Scopes:    identity.Scopes,
+		ActorType: "mtls",
+		RateLimit: rateLimit,
+	}
+
+	o.metrics.IncCounter(MetricAuthSuccess)
+	if cfg.EnableAuditLog && audit != nil {
+		recordAuthSuccessDetails(r.Context(), audit, tenant.ID, corrID, "", "client certificate CN="+cn, r)
+	}
+
+	ctx := r.Context()
+	ctx = ContextWithTenant(ctx, tenant)
+	ctx = ContextWithActor(ctx, actor)
+
+	if logger != nil {
+		logger.Info("authenticated request",
+			slog.String("correlationId", corrID),
+			slog.String("tenantId", tenant.ID),
+			slog.String("subject", cn),
+			slog.String("actorType", "mtls"),
+		)
+	}
+
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
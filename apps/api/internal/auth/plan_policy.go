@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"encoding/json"
+	"log/slog"
+	"os"
+	"slices"
+)
+
+// PlanPolicy bounds what keys of a tenant on a plan may do.
+type PlanPolicy struct {
+	// RateLimitPerMinute is the default per-key limit (0 uses Config.RateLimitPerMinute).
+	RateLimitPerMinute int `json:"rateLimitPerMinute"`
+	// MaxRateLimitPerMinute caps per-key overrides (0 leaves them uncapped).
+	MaxRateLimitPerMinute int `json:"maxRateLimitPerMinute"`
+	// MaxScopes lists the scopes new keys may carry; nil allows any scope.
+	MaxScopes []string `json:"maxScopes"`
+}
+
+// DefaultPlanPolicies is the policy table used when AUTH_PLAN_POLICIES is unset.
+// Free tenants keep their initial admin key but cannot mint further admin:write keys.
+var DefaultPlanPolicies = map[string]PlanPolicy{
+	"free": {
+		RateLimitPerMinute:    60,
+		MaxRateLimitPerMinute: 60,
+		MaxScopes: []string{
+			Scopes.AuditRead, Scopes.AuditWrite,
+			Scopes.InvoiceRead, Scopes.InvoiceWrite,
+			Scopes.AdminRead,
+		},
+	},
+	"pro":        {RateLimitPerMinute: 600, MaxRateLimitPerMinute: 3000},
+	"enterprise": {RateLimitPerMinute: 3000},
+}
+
+// ScopesBeyondPlan returns the requested scopes outside the plan's ceiling.
+// Tenants on a plan without a policy are not restricted.
+func (c Config) ScopesBeyondPlan(plan string, scopes []string) []string {
+	policy, ok := c.PlanPolicies[plan]
+	if !ok || policy.MaxScopes == nil {
+		return nil
+	}
+	var beyond []string
+	for _, s := range scopes {
+		if !slices.Contains(policy.MaxScopes, s) {
+			beyond = append(beyond, s)
+		}
+	}
+	return beyond
+}
+
+// EffectiveRateLimit returns the per-minute limit for a key of a tenant on
+// plan: the key's own override, else the plan default, capped by the plan. It
+// returns 0 (the limiter's own rate) when neither key nor plan sets one.
+func (c Config) EffectiveRateLimit(plan string, keyLimit int) int {
+	policy, ok := c.PlanPolicies[plan]
+	if !ok {
+		return keyLimit
+	}
+	rate := policy.RateLimitPerMinute
+	if keyLimit > 0 {
+		rate = keyLimit
+	}
+	if rate <= 0 {
+		rate = c.RateLimitPerMinute
+	}
+	if policy.MaxRateLimitPerMinute > 0 && rate > policy.MaxRateLimitPerMinute {
+		rate = policy.MaxRateLimitPerMinute
+	}
+	return rate
+}
+
+// getPlanPolicies reads a JSON object of plan name to PlanPolicy, falling back
+// to def when the variable is unset. Malformed values are logged and also fall
+// back to def.
+func getPlanPolicies(key string, def map[string]PlanPolicy) map[string]PlanPolicy {
+	v, ok := os.LookupEnv(key)
+	if !ok || v == "" {
+		return def
+	}
+	var policies map[string]PlanPolicy
+	if err := json.Unmarshal([]byte(v), &policies); err != nil {
+		slog.Error("malformed plan policies, using defaults", "env", key, "error", err)
+		return def
+	}
+	return policies
+}
@@ -30,28 +30,28 @@ window:  window,
 // Allow checks if a request should be allowed for the given key.
 // Returns (allowed, retryAfter) where retryAfter is the duration to wait if denied.
 func (rl *RateLimiter) Allow(key string) (bool, time.Duration) {
+return rl.AllowRate(key, 0)
+}
+
+// AllowRate is Allow with a per-key rate per window, such as a plan's limit,
+// in place of the limiter's own (rate <= 0 uses the limiter's).
+func (rl *RateLimiter) AllowRate(key string, rate int) (bool, time.Duration) {
 rl.mu.Lock()
 defer rl.mu.Unlock()
 
+rate = rl.rateOr(rate)
 now := time.Now()
 bucket, exists := rl.buckets[key]
 
 if !exists {
 rl.buckets[key] = &tokenBucket{
-tokens:   rl.rate - 1, // Consume one token
+tokens:   rate - 1, // Consume one token
 lastFill: now,
 }
 return true, 0
 }
 
-// Refill tokens based on elapsed time
-elapsed := now.Sub(bucket.lastFill)
-refill := int(float64(elapsed) / float64(rl.window) * float64(rl.rate))
-
-if refill > 0 {
-bucket.tokens = minInt(rl.rate, bucket.tokens+refill)
-bucket.lastFill = now
-}
+bucket.tokens, bucket.lastFill = rl.refill(bucket, now, rate)
 
 if bucket.tokens > 0 {
 bucket.tokens--
@@ -59,10 +59,69 @@ return true, 0
 }
 
 // Calculate retry-after
-tokenTime := rl.window / time.Duration(rl.rate)
+tokenTime := rl.window / time.Duration(rate)
 return false, tokenTime
 }
 
+// Peek reports the tokens left for key and when its bucket will be full again,
+// without consuming a token. Refill since the last fill is counted exactly as
+// Allow counts it, but nothing is written back, so Allow is unaffected.
+func (rl *RateLimiter) Peek(key string) (remaining int, resetAt time.Time) {
+return rl.PeekRate(key, 0)
+}
+
+// PeekRate is Peek for a key limited with AllowRate.
+func (rl *RateLimiter) PeekRate(key string, rate int) (remaining int, resetAt time.Time) {
+rl.mu.Lock()
+defer rl.mu.Unlock()
+
+rate = rl.rateOr(rate)
+now := time.Now()
+bucket, exists := rl.buckets[key]
+if !exists || rate <= 0 {
+return rate, now
+}
+tokens, lastFill := rl.refill(bucket, now, rate)
+if missing := rate - tokens; missing > 0 {
+// Whole tokens accrue from lastFill at rate per window.
+return tokens, lastFill.Add(time.Duration(float64(rl.window) * float64(missing) / float64(rate)))
+}
+return tokens, now
+}
+
+// rateOr returns rate, or the limiter's own rate when rate <= 0.
+func (rl *RateLimiter) rateOr(rate int) int {
+if rate <= 0 {
+return rl.rate
+}
+return rate
+}
+
+// refill returns bucket's token count and fill time as of now, adding the
+// whole tokens accrued since its last fill. Callers must hold rl.mu.
+func (rl *RateLimiter) refill(bucket *tokenBucket, now time.Time, rate int) (int, time.Time) {
+elapsed := now.Sub(bucket.lastFill)
+refill := int(float64(elapsed) / float64(rl.window) * float64(rate))
+if refill > 0 {
+return minInt(rate, bucket.tokens+refill), now
+}
+if bucket.tokens > rate {
+// The key's rate was lowered since the bucket filled
+return rate, bucket.lastFill
+}
+return bucket.tokens, bucket.lastFill
+}
+
+// Limit returns the number of requests allowed per window.
+func (rl *RateLimiter) Limit() int {
+return rl.rate
+}
+
+// Window returns the limiter's refill window.
+func (rl *RateLimiter) Window() time.Duration {
+return rl.window
+}
+
 // Reset resets the rate limiter for a key (useful for testing).
 func (rl *RateLimiter) Reset(key string) {
 rl.mu.Lock()
@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// AuditPruneSource is the audit store AuditPruner trims.
+// InMemoryAuthAuditRecorder implements it.
+type AuditPruneSource interface {
+	Tenants() []string
+	Prune(ctx context.Context, tenantID string, before time.Time) (int, error)
+}
+
+// AuditPruner enforces cfg.AuditRetention by periodically pruning every
+// tenant's audit partition of entries older than the retention window.
+type AuditPruner struct {
+	source    AuditPruneSource
+	retention time.Duration
+	every     time.Duration
+	logger    *slog.Logger
+	now       func() time.Time
+}
+
+// NewAuditPruner creates a pruner over source.
+func NewAuditPruner(source AuditPruneSource, cfg Config, logger *slog.Logger) *AuditPruner {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &AuditPruner{
+		source:    source,
+		retention: cfg.AuditRetention,
+		every:     cfg.AuditPruneInterval,
+		logger:    logger,
+		now:       time.Now,
+	}
+}
+
+// Start runs PruneAll every cfg.AuditPruneInterval until ctx is done. It does
+// nothing unless both the interval and cfg.AuditRetention are set.
+func (p *AuditPruner) Start(ctx context.Context) {
+	if p.every <= 0 || p.retention <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(p.every)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				p.PruneAll(ctx)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// PruneAll prunes every tenant's entries older than the retention window and
+// returns how many were removed in total. A failing tenant is logged and
+// skipped.
+func (p *AuditPruner) PruneAll(ctx context.Context) int {
+	if p.retention <= 0 {
+		return 0
+	}
+	before := p.now().UTC().Add(-p.retention)
+	total := 0
+	for _, tenantID := range p.source.Tenants() {
+		if ctx.Err() != nil {
+			break
+		}
+		n, err := p.source.Prune(ctx, tenantID, before)
+		if err != nil {
+			p.logger.Error("audit prune failed", slog.String("tenantId", tenantID), slog.String("error", err.Error()))
+			continue
+		}
+		if n > 0 {
+			p.logger.Info("audit entries pruned", slog.String("tenantId", tenantID), slog.Int("pruned", n))
+		}
+		total += n
+	}
+	return total
+}
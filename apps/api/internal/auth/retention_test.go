@@ -0,0 +1,156 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+// recordAt appends a chained entry for tenantID with the given timestamp.
+func recordAt(ctx context.Context, audit *InMemoryAuthAuditRecorder, tenantID, action string, at time.Time) {
+	appendAuditEntry(ctx, audit, AuditLogEntry{TenantID: tenantID, Action: action, Timestamp: at})
+}
+
+// TestInMemoryAuthAuditRecorder_Prune tests that pruning drops old entries and
+// the surviving chain re-verifies from the new anchor.
+func TestInMemoryAuthAuditRecorder_Prune(t *testing.T) {
+	audit := NewInMemoryAuthAuditRecorder()
+	ctx := context.Background()
+	start := time.Now().UTC().Add(-10 * time.Hour)
+	for i := 0; i < 5; i++ {
+		recordAt(ctx, audit, "test-tenant", "auth.success", start.Add(time.Duration(i)*time.Hour))
+	}
+	before := audit.GetEntries("test-tenant")
+
+	n, err := audit.Prune(ctx, "test-tenant", start.Add(2*time.Hour))
+	if err != nil {
+		t.Fatalf("Prune() error = %v", err)
+	}
+	if n != 2 {
+		t.Fatalf("expected 2 entries pruned, got %d", n)
+	}
+
+	entries := audit.GetEntries("test-tenant")
+	if len(entries) != 4 {
+		t.Fatalf("expected 3 survivors and a marker, got %d entries", len(entries))
+	}
+	if entries[0].ID != before[2].ID || entries[0].PrevHash != "" {
+		t.Fatalf("expected %s re-anchored with an empty prevHash, got %+v", before[2].ID, entries[0])
+	}
+	if v := VerifyAuditChain(entries); !v.Valid {
+		t.Fatalf("expected pruned chain to verify, got %+v", v)
+	}
+
+	marker := entries[len(entries)-1]
+	if marker.Action != ChainPrunedAction {
+		t.Fatalf("expected %s marker at the head, got %s", ChainPrunedAction, marker.Action)
+	}
+	var prune ChainPrune
+	if err := json.Unmarshal([]byte(marker.Details), &prune); err != nil {
+		t.Fatalf("decode marker details: %v", err)
+	}
+	if prune.Pruned != 2 || prune.Resealed != 3 || prune.PriorAnchorHash != before[1].Hash || prune.PriorHeadHash != before[4].Hash {
+		t.Fatalf("unexpected prune record %+v", prune)
+	}
+
+	recordAt(ctx, audit, "test-tenant", "auth.success", time.Now().UTC())
+	if v := VerifyAuditChain(audit.GetEntries("test-tenant")); !v.Valid {
+		t.Fatalf("expected new entries to extend the pruned chain, got %+v", v)
+	}
+}
+
+// TestAuditPruner_PruneAll tests that the pruner applies the retention window
+// to every tenant and leaves tenants with nothing to prune untouched.
+func TestAuditPruner_PruneAll(t *testing.T) {
+	audit := NewInMemoryAuthAuditRecorder()
+	ctx := context.Background()
+	now := time.Now().UTC()
+	recordAt(ctx, audit, "tenant-a", "auth.success", now.Add(-48*time.Hour))
+	recordAt(ctx, audit, "tenant-a", "auth.success", now.Add(-time.Hour))
+	recordAt(ctx, audit, "tenant-b", "auth.success", now.Add(-time.Hour))
+
+	pruner := NewAuditPruner(audit, Config{AuditRetention: 24 * time.Hour}, nil)
+	pruner.now = func() time.Time { return now }
+	if n := pruner.PruneAll(ctx); n != 1 {
+		t.Fatalf("expected 1 entry pruned, got %d", n)
+	}
+
+	if entries := audit.GetEntries("tenant-a"); len(entries) != 2 || entries[1].Action != ChainPrunedAction || !VerifyAuditChain(entries).Valid {
+		t.Fatalf("expected tenant-a to keep a verified survivor and marker, got %+v", entries)
+	}
+	if entries := audit.GetEntries("tenant-b"); len(entries) != 1 {
+		t.Fatalf("expected tenant-b untouched, got %+v", entries)
+	}
+}
+
+// TestInMemoryAuthAuditRecorder_PruneRefusesBrokenChain tests that a chain
+// that fails verification is not re-sealed over by a prune.
+func TestInMemoryAuthAuditRecorder_PruneRefusesBrokenChain(t *testing.T) {
+	audit := NewInMemoryAuthAuditRecorder()
+	ctx := context.Background()
+	start := time.Now().UTC().Add(-10 * time.Hour)
+	for i := 0; i < 4; i++ {
+		recordAt(ctx, audit, "test-tenant", "auth.success", start.Add(time.Duration(i)*time.Hour))
+	}
+	audit.mu.Lock()
+	audit.entries["test-tenant"][3].Action = "auth.tampered"
+	audit.mu.Unlock()
+	before := audit.GetEntries("test-tenant")
+
+	n, err := audit.Prune(ctx, "test-tenant", start.Add(2*time.Hour))
+	if !errors.Is(err, ErrPruneBrokenChain) || n != 0 {
+		t.Fatalf("Prune() = %d, %v; want ErrPruneBrokenChain", n, err)
+	}
+	after := audit.GetEntries("test-tenant")
+	if len(after) != len(before) || after[3].Hash != before[3].Hash {
+		t.Fatalf("expected the broken chain untouched, got %+v", after)
+	}
+	if v := VerifyAuditChain(after); v.Valid || *v.BrokenAt != 3 {
+		t.Fatalf("expected the break to remain at 3, got %+v", v)
+	}
+}
+
+// TestAuditPruner_IntegrityCheckerFollowsPrune tests that a checkpoint taken
+// before a prune does not make the re-sealed chain look tampered, and that the
+// restarted walk still catches tampering afterwards.
+func TestAuditPruner_IntegrityCheckerFollowsPrune(t *testing.T) {
+	audit := NewInMemoryAuthAuditRecorder()
+	ctx := context.Background()
+	now := time.Now().UTC()
+	for i := 0; i < 6; i++ {
+		recordAt(ctx, audit, "test-tenant", "auth.success", now.Add(time.Duration(i-6)*time.Hour))
+	}
+
+	alerter := &recordingAlerter{}
+	checker := NewIntegrityChecker(audit, Config{IntegrityCheckBatch: 4}, alerter, nil)
+	if result := checker.Check(ctx, "test-tenant"); !result.Valid || result.Checkpoint != 4 {
+		t.Fatalf("expected the first pass to checkpoint at 4, got %+v", result)
+	}
+
+	pruner := NewAuditPruner(audit, Config{AuditRetention: 3*time.Hour + 30*time.Minute}, nil)
+	pruner.now = func() time.Time { return now }
+	if n := pruner.PruneAll(ctx); n != 3 {
+		t.Fatalf("expected 3 entries pruned, got %d", n)
+	}
+
+	for pass := 0; pass < 2; pass++ {
+		if result := checker.Check(ctx, "test-tenant"); !result.Valid {
+			t.Fatalf("pass %d: expected the pruned chain to verify, got %+v", pass, result)
+		}
+	}
+	if len(alerter.alerts) != 0 {
+		t.Fatalf("expected no alerts after a prune, got %+v", alerter.alerts)
+	}
+
+	audit.mu.Lock()
+	audit.entries["test-tenant"][1].Action = "auth.tampered"
+	audit.mu.Unlock()
+	if result := checker.Check(ctx, "test-tenant"); result.Valid || *result.BrokenAt != 1 {
+		t.Fatalf("expected tampering after the prune to be caught at 1, got %+v", result)
+	}
+	if n := pruner.PruneAll(ctx); n != 0 {
+		t.Fatalf("expected the broken chain not to be pruned, got %d", n)
+	}
+}
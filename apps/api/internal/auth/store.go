@@ -2,19 +2,40 @@ package auth
 
 import (
 "context"
+"encoding/base64"
+"errors"
 "fmt"
+"maps"
+"sort"
+"strconv"
+"strings"
 "sync"
 "time"
 )
 
+// ErrInvalidCursor is returned when a ListKeysPage cursor cannot be decoded.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
 // InMemoryAPIKeyStore provides an in-memory implementation of APIKeyStore.
 // For production, replace with PostgreSQL/Redis implementation.
 type InMemoryAPIKeyStore struct {
-mu       sync.RWMutex
-cfg      Config
-keys     map[string]*APIKey      // keyID -> APIKey
-keyHash  map[string]string       // keyHash -> keyID (for lookup)
-tenants  map[string]*Tenant      // tenantID -> Tenant
+mu          sync.RWMutex
+cfg         Config
+keys        map[string]*APIKey           // keyID -> APIKey
+keyHash     map[string]string            // keyHash -> keyID (for lookup)
+tenants     map[string]*Tenant           // tenantID -> Tenant
+idempotency map[string]IdempotencyRecord // idempotency key -> recorded response
+
+dummyOnce     sync.Once
+dummyHash     string // Fixed hash compared on a miss, see dummyVerify
+onDummyVerify func() // Test hook, called on each dummy comparison
+}
+
+// IdempotencyRecord is a stored response for an Idempotency-Key replay.
+type IdempotencyRecord struct {
+BodyHash  string
+Response  []byte
+ExpiresAt time.Time
 }
 
 // NewInMemoryAPIKeyStore creates a new in-memory API key store.
@@ -24,16 +45,83 @@ cfg:     cfg,
 keys:    make(map[string]*APIKey),
 keyHash: make(map[string]string),
 tenants: make(map[string]*Tenant),
+idempotency: make(map[string]IdempotencyRecord),
 }
 }
 
 // ValidateKey validates a raw API key and returns the tenant.
+// Keys hashed with weaker parameters than the current config are transparently re-hashed.
 func (s *InMemoryAPIKeyStore) ValidateKey(ctx context.Context, rawKey string) (*Tenant, *APIKey, error) {
 s.mu.RLock()
-defer s.mu.RUnlock()
+tenant, key, err := s.findKeyLocked(rawKey)
+var storedHash string
+if err == nil {
+storedHash = key.KeyHash
+}
+s.mu.RUnlock()
+if err != nil {
+s.dummyVerify(rawKey)
+return nil, nil, err
+}
+
+if NeedsRehash(storedHash, s.cfg) {
+s.rehashKey(key, rawKey, storedHash)
+}
+return tenant, key, nil
+}
+
+// dummyVerify compares rawKey against a fixed hash in the configured algorithm
+// and discards the result. Without it a miss with no candidate keys returns at
+// once while a key with a valid prefix pays for a full hash, which would let a
+// caller confirm prefixes by timing.
+func (s *InMemoryAPIKeyStore) dummyVerify(rawKey string) {
+prefix := s.cfg.KeyConfig().Prefix
+s.dummyOnce.Do(func() {
+s.dummyHash, _ = HashKey(prefix+"timing-equalizer-not-a-key", s.cfg)
+})
+if s.onDummyVerify != nil {
+s.onDummyVerify()
+}
+// Always carry the prefix so VerifyKey reaches the hash comparison
+VerifyKey(prefix+strings.TrimPrefix(rawKey, prefix), s.dummyHash, s.cfg)
+}
+
+// rehashKey re-hashes a validated key at the current cost and persists the new hash.
+// Failures are ignored: the old hash remains valid and the upgrade is retried next time.
+func (s *InMemoryAPIKeyStore) rehashKey(key *APIKey, rawKey, oldHash string) {
+newHash, err := HashKey(rawKey, s.cfg)
+if err != nil {
+return
+}
+
+s.mu.Lock()
+defer s.mu.Unlock()
+if key.KeyHash != oldHash {
+return // Rotated or re-hashed concurrently
+}
+key.KeyHash = newHash
+delete(s.keyHash, oldHash)
+s.keyHash[newHash] = key.ID
+}
 
+// generateKey generates a key for tenantID, embedding its discriminator when
+// cfg.KeyTenantPrefix is set.
+func (s *InMemoryAPIKeyStore) generateKey(tenantID string) (string, string, error) {
+if s.cfg.KeyTenantPrefix {
+return s.cfg.KeyConfig().Generate(TenantKeyDiscriminator(tenantID, s.cfg.KeyTenantPrefixLength))
+}
+return s.cfg.KeyConfig().Generate("")
+}
+
+// findKeyLocked returns the active key matching rawKey. Callers must hold s.mu.
+func (s *InMemoryAPIKeyStore) findKeyLocked(rawKey string) (*Tenant, *APIKey, error) {
+// A tenant discriminator narrows the search to keys issued with the same one.
+tenant := s.cfg.KeyConfig().ExtractTenant(rawKey)
 // Search through all keys (not efficient for production)
 for _, key := range s.keys {
+if tenant != "" && !strings.HasPrefix(key.KeyPrefix, tenant+"_") {
+continue
+}
 if VerifyKey(rawKey, key.KeyHash, s.cfg) {
     // Check if key is revoked
     if key.RevokedAt != nil {
@@ -65,7 +153,7 @@ return nil, "", fmt.Errorf("tenant not found: %s", tenantID)
 }
 
 // Generate key
-rawKey, prefix, err := GenerateAPIKey()
+rawKey, prefix, err := s.generateKey(tenantID)
 if err != nil {
 return nil, "", err
 }
@@ -96,6 +184,19 @@ s.keyHash[hash] = keyID
 return key, rawKey, nil
 }
 
+// GetKey returns a copy of the key with the given ID, whatever its state.
+func (s *InMemoryAPIKeyStore) GetKey(ctx context.Context, keyID string) (*APIKey, error) {
+s.mu.RLock()
+defer s.mu.RUnlock()
+
+key, ok := s.keys[keyID]
+if !ok {
+return nil, fmt.Errorf("key not found: %s", keyID)
+}
+k := *key
+return &k, nil
+}
+
 // RotateKey creates a new key and marks the old one for rotation.
 func (s *InMemoryAPIKeyStore) RotateKey(ctx context.Context, oldKeyID string) (*APIKey, string, error) {
 s.mu.Lock()
@@ -111,7 +212,7 @@ return nil, "", fmt.Errorf("cannot rotate revoked key")
 }
 
 // Generate new key
-rawKey, prefix, err := GenerateAPIKey()
+rawKey, prefix, err := s.generateKey(oldKey.TenantID)
 if err != nil {
 return nil, "", err
 }
@@ -138,6 +239,9 @@ KeyPrefix:   prefix,
 KeyHash:     hash,
 Scopes:      oldKey.Scopes,
 RateLimit:   oldKey.RateLimit,
+Exempt:      oldKey.Exempt,
+Metadata:    maps.Clone(oldKey.Metadata),
+Tags:        maps.Clone(oldKey.Tags),
 CreatedAt:   now,
 RotatedFrom: &oldKeyID,
 }
@@ -148,6 +252,48 @@ s.keyHash[hash] = newKeyID
 return newKey, rawKey, nil
 }
 
+// SetKeyExempt marks a key as exempt from (or subject to) rate limiting.
+func (s *InMemoryAPIKeyStore) SetKeyExempt(ctx context.Context, keyID string, exempt bool) error {
+s.mu.Lock()
+defer s.mu.Unlock()
+
+key, ok := s.keys[keyID]
+if !ok {
+return fmt.Errorf("key not found: %s", keyID)
+}
+
+key.Exempt = exempt
+return nil
+}
+
+// SetKeyMetadata replaces a key's metadata. Callers validate it with ValidateKeyMetadata.
+func (s *InMemoryAPIKeyStore) SetKeyMetadata(ctx context.Context, keyID string, metadata map[string]string) error {
+s.mu.Lock()
+defer s.mu.Unlock()
+
+key, ok := s.keys[keyID]
+if !ok {
+return fmt.Errorf("key not found: %s", keyID)
+}
+
+key.Metadata = maps.Clone(metadata)
+return nil
+}
+
+// SetKeyTags replaces a key's tags. Callers validate them with ValidateKeyTags.
+func (s *InMemoryAPIKeyStore) SetKeyTags(ctx context.Context, keyID string, tags map[string]string) error {
+s.mu.Lock()
+defer s.mu.Unlock()
+
+key, ok := s.keys[keyID]
+if !ok {
+return fmt.Errorf("key not found: %s", keyID)
+}
+
+key.Tags = maps.Clone(tags)
+return nil
+}
+
 // RevokeKey revokes an API key immediately.
 func (s *InMemoryAPIKeyStore) RevokeKey(ctx context.Context, keyID string) error {
 s.mu.Lock()
@@ -163,6 +309,28 @@ key.RevokedAt = &now
 return nil
 }
 
+// RevokeTenantKeys revokes every active key of a tenant except exceptKeyID
+// (empty keeps none) and returns how many were revoked. Rotated keys still in
+// their grace period count as active.
+func (s *InMemoryAPIKeyStore) RevokeTenantKeys(ctx context.Context, tenantID, exceptKeyID string) (int, error) {
+s.mu.Lock()
+defer s.mu.Unlock()
+
+now := time.Now().UTC()
+revoked := 0
+for _, key := range s.keys {
+if key.TenantID != tenantID || key.ID == exceptKeyID || key.RevokedAt != nil {
+continue
+}
+if key.ExpiresAt != nil && now.After(*key.ExpiresAt) {
+continue
+}
+key.RevokedAt = &now
+revoked++
+}
+return revoked, nil
+}
+
 // ListKeys returns all keys for a tenant.
 func (s *InMemoryAPIKeyStore) ListKeys(ctx context.Context, tenantID string) ([]APIKey, error) {
 s.mu.RLock()
@@ -174,12 +342,139 @@ if key.TenantID == tenantID {
 // Return copy without hash
 keyCopy := *key
 keyCopy.KeyHash = ""
+keyCopy.Metadata = maps.Clone(key.Metadata)
+keyCopy.Tags = maps.Clone(key.Tags)
 keys = append(keys, keyCopy)
 }
 }
 return keys, nil
 }
 
+// KeysExpiredBetween returns unrevoked keys, across all tenants, whose
+// ExpiresAt falls in (after, upTo]. A rotated key's ExpiresAt is the end of its
+// grace window, so it is returned once that window closes.
+func (s *InMemoryAPIKeyStore) KeysExpiredBetween(after, upTo time.Time) []APIKey {
+s.mu.RLock()
+defer s.mu.RUnlock()
+
+var keys []APIKey
+for _, key := range s.keys {
+if key.RevokedAt != nil || key.ExpiresAt == nil {
+continue
+}
+if key.ExpiresAt.After(after) && !key.ExpiresAt.After(upTo) {
+keyCopy := *key
+keyCopy.KeyHash = ""
+keyCopy.Metadata = maps.Clone(key.Metadata)
+keyCopy.Tags = maps.Clone(key.Tags)
+keys = append(keys, keyCopy)
+}
+}
+return keys
+}
+
+// ListKeysPage returns a page of keys for a tenant, newest first.
+// Revoked and rotated keys are skipped unless opts.IncludeRevoked is set.
+func (s *InMemoryAPIKeyStore) ListKeysPage(ctx context.Context, tenantID string, opts ListKeysOptions) (KeyPage, error) {
+limit := opts.Limit
+if limit <= 0 {
+limit = DefaultListKeysLimit
+}
+if limit > MaxListKeysLimit {
+limit = MaxListKeysLimit
+}
+
+var after *keyCursor
+if opts.Cursor != "" {
+c, err := decodeKeyCursor(opts.Cursor)
+if err != nil {
+return KeyPage{}, err
+}
+after = &c
+}
+
+s.mu.RLock()
+var keys []APIKey
+for _, key := range s.keys {
+if key.TenantID != tenantID {
+continue
+}
+if !opts.IncludeRevoked && (key.RevokedAt != nil || key.Rotated) {
+continue
+}
+if !matchesMetadata(key, opts) || !matchesTags(key, opts) {
+continue
+}
+keyCopy := *key
+keyCopy.KeyHash = ""
+keyCopy.Metadata = maps.Clone(key.Metadata)
+keyCopy.Tags = maps.Clone(key.Tags)
+keys = append(keys, keyCopy)
+}
+s.mu.RUnlock()
+
+// Sort by CreatedAt descending; ID breaks ties so the order is stable.
+sort.Slice(keys, func(i, j int) bool {
+return keyCursorOf(keys[i]).before(keyCursorOf(keys[j]))
+})
+
+start := 0
+if after != nil {
+start = sort.Search(len(keys), func(i int) bool {
+return after.before(keyCursorOf(keys[i]))
+})
+}
+
+page := KeyPage{Keys: []APIKey{}}
+end := start + limit
+if end < len(keys) {
+page.NextCursor = keyCursorOf(keys[end-1]).encode()
+} else {
+end = len(keys)
+}
+page.Keys = append(page.Keys, keys[start:end]...)
+return page, nil
+}
+
+// keyCursor identifies a position in the CreatedAt-descending key order.
+type keyCursor struct {
+createdAt time.Time
+id        string
+}
+
+func keyCursorOf(k APIKey) keyCursor {
+return keyCursor{createdAt: k.CreatedAt, id: k.ID}
+}
+
+// before reports whether c sorts ahead of other (newer first, then higher ID).
+func (c keyCursor) before(other keyCursor) bool {
+if !c.createdAt.Equal(other.createdAt) {
+return c.createdAt.After(other.createdAt)
+}
+return c.id > other.id
+}
+
+func (c keyCursor) encode() string {
+raw := strconv.FormatInt(c.createdAt.UnixNano(), 10) + "|" + c.id
+return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeKeyCursor(s string) (keyCursor, error) {
+raw, err := base64.RawURLEncoding.DecodeString(s)
+if err != nil {
+return keyCursor{}, ErrInvalidCursor
+}
+nanos, id, ok := strings.Cut(string(raw), "|")
+if !ok || id == "" {
+return keyCursor{}, ErrInvalidCursor
+}
+n, err := strconv.ParseInt(nanos, 10, 64)
+if err != nil {
+return keyCursor{}, ErrInvalidCursor
+}
+return keyCursor{createdAt: time.Unix(0, n).UTC(), id: id}, nil
+}
+
 // UpdateLastUsed updates the last used timestamp.
 func (s *InMemoryAPIKeyStore) UpdateLastUsed(ctx context.Context, keyID string) error {
 s.mu.Lock()
@@ -220,6 +515,30 @@ return nil, fmt.Errorf("tenant not found: %s", tenantID)
 return tenant, nil
 }
 
+// DeleteTenant removes a tenant and every key it owns, including rotated and
+// revoked ones. The hash index is swept by key ID rather than by each key's
+// current hash, so entries left behind by a re-hash cannot outlive the tenant.
+func (s *InMemoryAPIKeyStore) DeleteTenant(ctx context.Context, tenantID string) error {
+s.mu.Lock()
+defer s.mu.Unlock()
+
+if _, ok := s.tenants[tenantID]; !ok {
+return fmt.Errorf("tenant not found: %s", tenantID)
+}
+for id, key := range s.keys {
+if key.TenantID == tenantID {
+delete(s.keys, id)
+}
+}
+for hash, keyID := range s.keyHash {
+if _, ok := s.keys[keyID]; !ok {
+delete(s.keyHash, hash)
+}
+}
+delete(s.tenants, tenantID)
+return nil
+}
+
 // UpdateTenantStatus updates a tenant's status.
 func (s *InMemoryAPIKeyStore) UpdateTenantStatus(ctx context.Context, tenantID, status string) error {
 s.mu.Lock()
@@ -234,18 +553,52 @@ tenant.Status = status
 return nil
 }
 
+// GetIdempotency returns the unexpired record stored under key.
+func (s *InMemoryAPIKeyStore) GetIdempotency(ctx context.Context, key string) (IdempotencyRecord, bool) {
+s.mu.RLock()
+defer s.mu.RUnlock()
+
+rec, ok := s.idempotency[key]
+if !ok || !time.Now().Before(rec.ExpiresAt) {
+return IdempotencyRecord{}, false
+}
+return rec, true
+}
+
+// PutIdempotency stores a response under key for ttl, pruning expired records.
+func (s *InMemoryAPIKeyStore) PutIdempotency(ctx context.Context, key, bodyHash string, response []byte, ttl time.Duration) {
+s.mu.Lock()
+defer s.mu.Unlock()
+
+now := time.Now()
+for k, rec := range s.idempotency {
+if !now.Before(rec.ExpiresAt) {
+delete(s.idempotency, k)
+}
+}
+s.idempotency[key] = IdempotencyRecord{
+BodyHash:  bodyHash,
+Response:  response,
+ExpiresAt: now.Add(ttl),
+}
+}
+
 // --- In-memory Audit Recorder ---
 
 // InMemoryAuthAuditRecorder provides an in-memory audit log implementation.
 type InMemoryAuthAuditRecorder struct {
-mu       sync.RWMutex
-entries  map[string][]AuditLogEntry // tenantID -> entries
+mu        sync.RWMutex
+entries   map[string][]AuditLogEntry // tenantID -> entries
+rewrites  map[string]int             // tenantID -> chains rewritten by Prune, repair or purge
+repairKey []byte                     // signs chain.repaired entries; nil disables repair
+hashAlg   string                     // seals new entries; empty means auditchain.SHA256
 }
 
 // NewInMemoryAuthAuditRecorder creates a new in-memory audit recorder.
 func NewInMemoryAuthAuditRecorder() *InMemoryAuthAuditRecorder {
 return &InMemoryAuthAuditRecorder{
-entries: make(map[string][]AuditLogEntry),
+entries:  make(map[string][]AuditLogEntry),
+rewrites: make(map[string]int),
 }
 }
 
@@ -277,3 +630,40 @@ defer r.mu.RUnlock()
 
 return append([]AuditLogEntry{}, r.entries[tenantID]...)
 }
+
+// Snapshot returns tenantID's entries together with how many times the chain
+// has been rewritten (pruned, repaired or purged), read under one lock so
+// IntegrityChecker can tell a rewrite from tampering.
+func (r *InMemoryAuthAuditRecorder) Snapshot(tenantID string) ([]AuditLogEntry, int) {
+r.mu.RLock()
+defer r.mu.RUnlock()
+
+return append([]AuditLogEntry{}, r.entries[tenantID]...), r.rewrites[tenantID]
+}
+
+// PurgeTenant drops a tenant's whole audit partition and returns how many
+// entries it held. It is meant for erasure requests after DeleteTenant.
+func (r *InMemoryAuthAuditRecorder) PurgeTenant(tenantID string) int {
+r.mu.Lock()
+defer r.mu.Unlock()
+
+n := len(r.entries[tenantID])
+delete(r.entries, tenantID)
+r.rewrites[tenantID]++
+return n
+}
+
+// Tenants returns the tenants that have at least one audit entry, sorted.
+func (r *InMemoryAuthAuditRecorder) Tenants() []string {
+r.mu.RLock()
+defer r.mu.RUnlock()
+
+tenants := make([]string, 0, len(r.entries))
+for tenantID, entries := range r.entries {
+if len(entries) > 0 {
+tenants = append(tenants, tenantID)
+}
+}
+sort.Strings(tenants)
+return tenants
+}
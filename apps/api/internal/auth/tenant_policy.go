@@ -0,0 +1,54 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// DefaultTenantIDPattern allows lowercase DNS-label style ids, which are safe in
+// storage keys and URL paths.
+const DefaultTenantIDPattern = `^[a-z0-9][a-z0-9-]*$`
+
+// DefaultReservedTenantIDs collide with internal namespaces or common sentinels.
+var DefaultReservedTenantIDs = []string{"admin", "system", "null", "root", "internal", "auth", "api"}
+
+// ValidateTenantID enforces the configured tenant-id policy: length bound,
+// pattern, and reserved words. It never accepts path separators, dot segments,
+// or whitespace, whatever the configured pattern, nor a platform admin tenant's
+// id, which would make whoever registers it a platform admin.
+func ValidateTenantID(id string, cfg Config) error {
+	if id == "" {
+		return errors.New("id is required")
+	}
+	if cfg.TenantIDMaxLength > 0 && len(id) > cfg.TenantIDMaxLength {
+		return fmt.Errorf("id must be at most %d characters", cfg.TenantIDMaxLength)
+	}
+	if id == UnattributedTenantID {
+		return fmt.Errorf("id %q is reserved", id)
+	}
+	if id == "." || id == ".." || strings.ContainsAny(id, "/\\") || strings.IndexFunc(id, isSpaceOrControl) >= 0 {
+		return errors.New("id must not contain path separators or whitespace")
+	}
+	if cfg.TenantIDPattern != "" {
+		re, err := regexp.Compile(cfg.TenantIDPattern)
+		if err != nil {
+			return fmt.Errorf("tenant id pattern is invalid: %w", err)
+		}
+		if !re.MatchString(id) {
+			return fmt.Errorf("id must match %s", cfg.TenantIDPattern)
+		}
+	}
+	for _, reserved := range slices.Concat(cfg.ReservedTenantIDs, cfg.PlatformAdminTenants) {
+		if strings.EqualFold(id, reserved) {
+			return fmt.Errorf("id %q is reserved", id)
+		}
+	}
+	return nil
+}
+
+func isSpaceOrControl(r rune) bool {
+	return r <= ' ' || r == 0x7f
+}
@@ -0,0 +1,20 @@
+package auth
+
+import (
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer resolves through the global provider, so spans are no-ops until the
+// binary installs one with otel.SetTracerProvider.
+var tracer = otel.Tracer("github.com/yourorg/yourapp/apps/api/internal/auth")
+
+// endSpan marks span failed when err is set, then ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,126 @@
+// Package health serves the liveness and readiness probes orchestrators use
+// to decide whether to restart the process or route traffic to it.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Check reports whether a dependency is usable; a nil error means ready.
+type Check func(ctx context.Context) error
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Checker runs the registered readiness checks. GET /healthz only reports that
+// the process is serving; GET /readyz runs every check and answers 503 when
+// any fails.
+type Checker struct {
+	timeout time.Duration
+	checks  []namedCheck
+}
+
+// NewChecker returns a Checker that gives each readiness check up to timeout
+// (0 disables the limit).
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{timeout: timeout}
+}
+
+// Add registers check under name. Checks run in registration order.
+func (c *Checker) Add(name string, check Check) {
+	c.checks = append(c.checks, namedCheck{name: name, check: check})
+}
+
+// Response is the body of both probes. Checks maps each check name to "ok" or
+// the error it returned.
+type Response struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// Healthz matches GET /healthz.
+func (c *Checker) Healthz(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, Response{Status: "ok"})
+}
+
+// Readyz matches GET /readyz.
+func (c *Checker) Readyz(w http.ResponseWriter, r *http.Request) {
+	resp := Response{Status: "ready", Checks: make(map[string]string, len(c.checks))}
+	status := http.StatusOK
+	for _, nc := range c.checks {
+		if err := c.run(r.Context(), nc.check); err != nil {
+			resp.Checks[nc.name] = err.Error()
+			resp.Status = "not_ready"
+			status = http.StatusServiceUnavailable
+			continue
+		}
+		resp.Checks[nc.name] = "ok"
+	}
+	writeJSON(w, status, resp)
+}
+
+// run calls check, giving up once the timeout passes even if check ignores
+// its context.
+func (c *Checker) run(ctx context.Context, check Check) error {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+	done := make(chan error, 1)
+	go func() { done <- check(ctx) }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ObjectWriter is the part of a storage backend StorageCheck exercises; both
+// the pint and auditzip Storage interfaces satisfy it.
+type ObjectWriter interface {
+	PutObject(ctx context.Context, key string, body []byte, contentType string) error
+}
+
+// StorageCheck reports storage as reachable when a small probe object can be
+// written under key.
+func StorageCheck(storage ObjectWriter, key string) Check {
+	return func(ctx context.Context) error {
+		return storage.PutObject(ctx, key, []byte("ok"), "text/plain")
+	}
+}
+
+// Cached wraps check so its result is reused for ttl, keeping expensive checks
+// such as launching Chromium off the hot path of frequent probes. Concurrent
+// callers share a single run of check.
+func Cached(check Check, ttl time.Duration) Check {
+	var (
+		mu      sync.Mutex
+		checked time.Time
+		last    error
+	)
+	return func(ctx context.Context) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if !checked.IsZero() && time.Since(checked) < ttl {
+			return last
+		}
+		last = check(ctx)
+		checked = time.Now()
+		return last
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,109 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type fakeStorage struct {
+	err  error
+	keys []string
+}
+
+func (f *fakeStorage) PutObject(_ context.Context, key string, _ []byte, _ string) error {
+	f.keys = append(f.keys, key)
+	return f.err
+}
+
+func probe(t *testing.T, handler http.HandlerFunc, path string) (int, Response) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, path, nil))
+	var resp Response
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	return w.Code, resp
+}
+
+func TestHealthz_AlwaysOK(t *testing.T) {
+	checker := NewChecker(time.Second)
+	checker.Add("storage", StorageCheck(&fakeStorage{err: errors.New("down")}, "_health/readyz"))
+
+	code, resp := probe(t, checker.Healthz, "/healthz")
+	if code != http.StatusOK || resp.Status != "ok" {
+		t.Fatalf("expected 200 ok regardless of dependencies, got %d %+v", code, resp)
+	}
+}
+
+func TestReadyz_Ready(t *testing.T) {
+	storage := &fakeStorage{}
+	checker := NewChecker(time.Second)
+	checker.Add("storage", StorageCheck(storage, "_health/readyz"))
+	checker.Add("chromium", func(context.Context) error { return nil })
+
+	code, resp := probe(t, checker.Readyz, "/readyz")
+	if code != http.StatusOK || resp.Status != "ready" {
+		t.Fatalf("expected 200 ready, got %d %+v", code, resp)
+	}
+	if resp.Checks["storage"] != "ok" || resp.Checks["chromium"] != "ok" {
+		t.Fatalf("expected every check ok, got %+v", resp.Checks)
+	}
+	if len(storage.keys) != 1 || storage.keys[0] != "_health/readyz" {
+		t.Fatalf("expected a probe object write, got %v", storage.keys)
+	}
+}
+
+func TestReadyz_NotReadyWhenStorageFails(t *testing.T) {
+	checker := NewChecker(time.Second)
+	checker.Add("storage", StorageCheck(&fakeStorage{err: errors.New("bucket unreachable")}, "_health/readyz"))
+	checker.Add("chromium", func(context.Context) error { return nil })
+
+	code, resp := probe(t, checker.Readyz, "/readyz")
+	if code != http.StatusServiceUnavailable || resp.Status != "not_ready" {
+		t.Fatalf("expected 503 not_ready, got %d %+v", code, resp)
+	}
+	if resp.Checks["storage"] != "bucket unreachable" || resp.Checks["chromium"] != "ok" {
+		t.Fatalf("expected the failing check to be reported, got %+v", resp.Checks)
+	}
+}
+
+func TestReadyz_TimesOutHungCheck(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+	checker := NewChecker(20 * time.Millisecond)
+	checker.Add("chromium", func(context.Context) error { <-block; return nil })
+
+	code, resp := probe(t, checker.Readyz, "/readyz")
+	if code != http.StatusServiceUnavailable || resp.Checks["chromium"] != context.DeadlineExceeded.Error() {
+		t.Fatalf("expected a hung check to time out, got %d %+v", code, resp)
+	}
+}
+
+func TestCached_ReusesResultWithinTTL(t *testing.T) {
+	var calls atomic.Int32
+	check := Cached(func(context.Context) error {
+		calls.Add(1)
+		return errors.New("launch failed")
+	}, 50*time.Millisecond)
+
+	for i := 0; i < 3; i++ {
+		if err := check(context.Background()); err == nil {
+			t.Fatal("expected the cached error")
+		}
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("expected one launch within the TTL, got %d", calls.Load())
+	}
+	time.Sleep(60 * time.Millisecond)
+	_ = check(context.Background())
+	if calls.Load() != 2 {
+		t.Fatalf("expected a fresh check after the TTL, got %d calls", calls.Load())
+	}
+}
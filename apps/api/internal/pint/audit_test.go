@@ -0,0 +1,72 @@
+package pint
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/yourorg/yourapp/apps/api/internal/auditchain"
+	"github.com/yourorg/yourapp/apps/api/internal/auth"
+)
+
+func TestExportAuditLogDetectsTamperedEntry(t *testing.T) {
+	rec := NewMemoryAuditRecorder()
+	svc := NewService(LoadConfig(), NewInMemoryStorage(), rec, nil)
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, action := range []string{"invoice.validate", "invoice.issue", "invoice.get"} {
+		entry := AuditLog{AuditID: action, CorrID: "corr-1", TenantID: "tenant-a", Actor: "system", Action: action, Ts: base.Add(time.Duration(i) * time.Second)}
+		if _, err := HashChain(context.Background(), rec, "tenant-a", entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	export := func() AuditLogExport {
+		r := httptest.NewRequest(http.MethodGet, "/audit/log?module=pint", nil)
+		r = r.WithContext(auth.ContextWithActor(r.Context(), &auth.Actor{TenantID: "tenant-a", Scopes: []string{auth.Scopes.AuditRead}}))
+		w := httptest.NewRecorder()
+		svc.ExportAuditLog(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var body AuditLogExport
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		return body
+	}
+
+	if got := export(); !got.Verified || len(got.Entries) != 3 {
+		t.Fatalf("expected a verified chain of 3 entries, got %+v", got)
+	}
+	rec.byTenant["tenant-a"][1].Ts = base.Add(time.Hour)
+	if got := export(); got.Verified || got.BrokenAt == nil || *got.BrokenAt != 1 {
+		t.Fatalf("expected the tampered middle entry to break the chain at 1, got %+v", got)
+	}
+}
+
+func TestHashAuditCanonicalAndLegacy(t *testing.T) {
+	ts := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	a := AuditLog{CorrID: "corr|tenant-a", TenantID: "x", Actor: "system", Action: "invoice.issue", Ts: ts, HashAlg: auditchain.SHA256}
+	b := AuditLog{CorrID: "corr", TenantID: "tenant-a|x", Actor: "system", Action: "invoice.issue", Ts: ts, HashAlg: auditchain.SHA256}
+	if hashAudit(a) == hashAudit(b) {
+		t.Fatal("expected entries differing by '|' placement to hash differently")
+	}
+
+	rec := NewMemoryAuditRecorder()
+	legacy := AuditLog{AuditID: "a1", CorrID: "corr-1", TenantID: "tenant-a", Actor: "system", Action: "invoice.validate", Ts: ts}
+	legacy.Hash = hashAudit(legacy)
+	if err := rec.Append(context.Background(), legacy); err != nil {
+		t.Fatal(err)
+	}
+	next := AuditLog{AuditID: "a2", CorrID: "corr-1", TenantID: "tenant-a", Actor: "system", Action: "invoice.issue", Ts: ts.Add(time.Second), HashAlg: auditchain.SHA512}
+	if _, err := HashChain(context.Background(), rec, "tenant-a", next); err != nil {
+		t.Fatal(err)
+	}
+	entries, _ := rec.All(context.Background(), "tenant-a")
+	if export := VerifyChain("tenant-a", entries); !export.Verified {
+		t.Fatalf("expected a legacy entry followed by a SHA-512 entry to verify, got %+v", export)
+	}
+}
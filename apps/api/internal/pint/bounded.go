@@ -0,0 +1,42 @@
+package pint
+
+import (
+	"context"
+	"sync"
+)
+
+// runBounded calls fn for every item with at most n calls in flight (n <= 0
+// means no bound) and returns fn's error for each item by index. Once ctx is
+// done no further items are started; each of those reports ctx.Err(). fn gets
+// the item's index so it can write results into a caller-owned slice without
+// further locking.
+func runBounded[T any](ctx context.Context, items []T, n int, fn func(ctx context.Context, i int, item T) error) []error {
+	errs := make([]error, len(items))
+	if n <= 0 || n > len(items) {
+		n = len(items)
+	}
+	sem := make(chan struct{}, n)
+	var wg sync.WaitGroup
+	for i, item := range items {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			errs[i] = ctx.Err()
+			continue
+		}
+		// A slot may win the select above even though ctx is already done.
+		if err := ctx.Err(); err != nil {
+			<-sem
+			errs[i] = err
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			errs[i] = fn(ctx, i, item)
+		}()
+	}
+	wg.Wait()
+	return errs
+}
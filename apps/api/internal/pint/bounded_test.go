@@ -0,0 +1,71 @@
+package pint
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunBounded_CapsConcurrency(t *testing.T) {
+	const n = 3
+	var inFlight, peak atomic.Int32
+	items := make([]int, 30)
+	errs := runBounded(context.Background(), items, n, func(context.Context, int, int) error {
+		cur := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			p := peak.Load()
+			if cur <= p || peak.CompareAndSwap(p, cur) {
+				break
+			}
+		}
+		time.Sleep(2 * time.Millisecond)
+		return nil
+	})
+	if len(errs) != len(items) {
+		t.Fatalf("expected %d errors slots, got %d", len(items), len(errs))
+	}
+	if got := peak.Load(); got > n || got < 2 {
+		t.Fatalf("expected between 2 and %d concurrent calls, peak was %d", n, got)
+	}
+}
+
+func TestRunBounded_CollectsErrorsByIndex(t *testing.T) {
+	items := []int{0, 1, 2, 3, 4, 5}
+	errs := runBounded(context.Background(), items, 2, func(_ context.Context, i int, item int) error {
+		if item%2 == 1 {
+			return fmt.Errorf("item %d", i)
+		}
+		return nil
+	})
+	for i, err := range errs {
+		if (i%2 == 1) != (err != nil) {
+			t.Fatalf("item %d: unexpected error %v", i, err)
+		}
+		if err != nil && err.Error() != fmt.Sprintf("item %d", i) {
+			t.Fatalf("item %d: error reported at wrong index: %v", i, err)
+		}
+	}
+}
+
+func TestRunBounded_StopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var started atomic.Int32
+	errs := runBounded(ctx, make([]int, 10), 1, func(context.Context, int, int) error {
+		if started.Add(1) == 2 {
+			cancel()
+		}
+		return nil
+	})
+	if got := started.Load(); got != 2 {
+		t.Fatalf("expected 2 items to start before cancellation, got %d", got)
+	}
+	for i := 2; i < len(errs); i++ {
+		if !errors.Is(errs[i], context.Canceled) {
+			t.Fatalf("item %d: expected context.Canceled, got %v", i, errs[i])
+		}
+	}
+}
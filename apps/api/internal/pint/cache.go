@@ -0,0 +1,116 @@
+package pint
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// InvoiceValidator validates an invoice draft and computes its totals.
+type InvoiceValidator interface {
+	Validate(draft InvoiceDraft) ValidationResult
+}
+
+// CachingValidator reuses results for identical drafts within a TTL, so an
+// IssueInvoice right after a ValidateInvoice of the same draft skips revalidation.
+// Entries are keyed by the draft content hash together with a fingerprint of the
+// validation-affecting config, so a config change never serves a stale result.
+type CachingValidator struct {
+	inner       InvoiceValidator
+	ttl         time.Duration
+	fingerprint string
+	now         func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]cachedValidation
+}
+
+type cachedValidation struct {
+	result    ValidationResult
+	expiresAt time.Time
+}
+
+func NewCachingValidator(inner InvoiceValidator, cfg Config) *CachingValidator {
+	return &CachingValidator{
+		inner:       inner,
+		ttl:         cfg.ValidationCacheTTL,
+		fingerprint: validationFingerprint(cfg),
+		now:         time.Now,
+		entries:     map[string]cachedValidation{},
+	}
+}
+
+// Validate returns the cached result for draft when present and unexpired,
+// otherwise it delegates to the wrapped validator and caches the outcome.
+func (c *CachingValidator) Validate(draft InvoiceDraft) ValidationResult {
+	key, err := c.cacheKey(draft)
+	if err != nil {
+		return c.inner.Validate(draft)
+	}
+	now := c.now()
+
+	c.mu.Lock()
+	if entry, ok := c.entries[key]; ok && now.Before(entry.expiresAt) {
+		c.mu.Unlock()
+		return cloneValidationResult(entry.result)
+	}
+	c.mu.Unlock()
+
+	result := c.inner.Validate(draft)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k, entry := range c.entries {
+		if !now.Before(entry.expiresAt) {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = cachedValidation{result: cloneValidationResult(result), expiresAt: now.Add(c.ttl)}
+	return result
+}
+
+func (c *CachingValidator) cacheKey(draft InvoiceDraft) (string, error) {
+	body, err := json.Marshal(draft)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	h.Write([]byte(c.fingerprint))
+	h.Write([]byte{0})
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// validationFingerprint captures every config field that influences Validate.
+// fmt prints maps with sorted keys, so equal plan limits fingerprint alike.
+func validationFingerprint(cfg Config) string {
+	return fmt.Sprintf("lines=%d|delta=%g|rounding=%s|desc=%d|units=%s|tax=%s|maxTotal=%g|planMaxTotal=%v|currencies=%s|paymentMeans=%s|strictCountries=%s",
+		cfg.MaxLines,
+		cfg.AllowedDelta,
+		cfg.RoundingMode,
+		cfg.MaxDescription,
+		strings.Join(cfg.ValidUnitCodes, ","),
+		strings.Join(cfg.ValidTaxCategory, ","),
+		cfg.MaxGrandTotal,
+		cfg.PlanMaxGrandTotal,
+		strings.Join(cfg.SupportedCurrencies, ","),
+		strings.Join(cfg.ValidPaymentMeans, ","),
+		strings.Join(cfg.StrictPartyCountries, ","),
+	)
+}
+
+func cloneValidationResult(r ValidationResult) ValidationResult {
+	clone := r
+	clone.Errors = append(make([]ValidationErrorItem, 0, len(r.Errors)), r.Errors...)
+	if r.Totals.Lines != nil {
+		clone.Totals.Lines = append(make([]LineTotals, 0, len(r.Totals.Lines)), r.Totals.Lines...)
+	}
+	if r.Totals.ByCategory != nil {
+		clone.Totals.ByCategory = append(make([]TaxBreakdown, 0, len(r.Totals.ByCategory)), r.Totals.ByCategory...)
+	}
+	return clone
+}
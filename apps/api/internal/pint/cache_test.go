@@ -0,0 +1,103 @@
+package pint
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type spyValidator struct {
+	calls int
+}
+
+func (s *spyValidator) Validate(draft InvoiceDraft) ValidationResult {
+	s.calls++
+	return Validator{Config: LoadConfig()}.Validate(draft)
+}
+
+func TestCachingValidator_HitWithinTTL(t *testing.T) {
+	cfg := LoadConfig()
+	cfg.ValidationCacheTTL = time.Minute
+	spy := &spyValidator{}
+	cache := NewCachingValidator(spy, cfg)
+
+	first := cache.Validate(sampleDraft())
+	second := cache.Validate(sampleDraft())
+	if spy.calls != 1 {
+		t.Fatalf("expected 1 underlying validation, got %d", spy.calls)
+	}
+	if !reflect.DeepEqual(first.Totals, second.Totals) || first.Valid != second.Valid {
+		t.Fatalf("cached result differs: %+v vs %+v", first, second)
+	}
+}
+
+func TestCachingValidator_ChangedDraftMisses(t *testing.T) {
+	cfg := LoadConfig()
+	cfg.ValidationCacheTTL = time.Minute
+	spy := &spyValidator{}
+	cache := NewCachingValidator(spy, cfg)
+
+	cache.Validate(sampleDraft())
+	changed := sampleDraft()
+	changed.Lines[0].Quantity = 11
+	result := cache.Validate(changed)
+	if spy.calls != 2 {
+		t.Fatalf("expected changed draft to miss the cache, got %d calls", spy.calls)
+	}
+	if result.Totals.Subtotal != 13200 {
+		t.Fatalf("expected subtotal for changed draft, got %+v", result.Totals)
+	}
+}
+
+func TestCachingValidator_ExpiresAfterTTL(t *testing.T) {
+	cfg := LoadConfig()
+	cfg.ValidationCacheTTL = time.Minute
+	spy := &spyValidator{}
+	cache := NewCachingValidator(spy, cfg)
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+
+	cache.Validate(sampleDraft())
+	now = now.Add(2 * time.Minute)
+	cache.Validate(sampleDraft())
+	if spy.calls != 2 {
+		t.Fatalf("expected expired entry to revalidate, got %d calls", spy.calls)
+	}
+}
+
+func TestCachingValidator_ConfigChangeMisses(t *testing.T) {
+	cfg := LoadConfig()
+	cfg.ValidationCacheTTL = time.Minute
+	a := NewCachingValidator(&spyValidator{}, cfg)
+	cfg.RoundingMode = "HALF_EVEN"
+	b := NewCachingValidator(&spyValidator{}, cfg)
+
+	keyA, _ := a.cacheKey(sampleDraft())
+	keyB, _ := b.cacheKey(sampleDraft())
+	if keyA == keyB {
+		t.Fatalf("expected rounding mode change to alter the cache key")
+	}
+}
+
+func TestCachingValidator_EveryValidatorInputChangesKey(t *testing.T) {
+	changes := map[string]func(*Config){
+		"PlanMaxGrandTotal":    func(c *Config) { c.PlanMaxGrandTotal = map[string]float64{"pro": 1000} },
+		"StrictPartyCountries": func(c *Config) { c.StrictPartyCountries = []string{"JP"} },
+		"ValidPaymentMeans":    func(c *Config) { c.ValidPaymentMeans = []string{"30"} },
+		"SupportedCurrencies":  func(c *Config) { c.SupportedCurrencies = []string{"USD"} },
+		"MaxGrandTotal":        func(c *Config) { c.MaxGrandTotal = 1 },
+	}
+	base := LoadConfig()
+	base.PlanMaxGrandTotal = nil
+	base.StrictPartyCountries = nil
+	base.ValidPaymentMeans = []string{"10", "42"}
+	baseKey, _ := NewCachingValidator(&spyValidator{}, base).cacheKey(sampleDraft())
+	for field, change := range changes {
+		cfg := base
+		change(&cfg)
+		key, _ := NewCachingValidator(&spyValidator{}, cfg).cacheKey(sampleDraft())
+		if key == baseKey {
+			t.Errorf("expected a %s change to alter the cache key", field)
+		}
+	}
+}
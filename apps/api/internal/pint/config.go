@@ -3,55 +3,150 @@ package pint
 import (
 	"os"
 	"strconv"
+	"strings"
 	"time"
+
+	"github.com/yourorg/yourapp/apps/api/internal/auditchain"
 )
 
 // Config holds environment-driven settings for storage, validation, and signing.
 type Config struct {
-	S3Endpoint       string
-	S3Bucket         string
-	SignURLTTL       time.Duration
-	MaxLines         int
-	AllowedDelta     float64
-	RoundingMode     string
-	MaxDescription   int
-	PDFEnabled       bool
-	DefaultTimeZone  string
-	DefaultLocale    string
-	MaxParallelJobs  int
-	EnableAuditHash  bool
-	ValidUnitCodes   []string
-	ValidTaxCategory []string
-	PDFChromiumPath  string
-	PDFTimeout       time.Duration
-	PDFTmpDir        string
-	PDFLocale        string
-	PDFTimeZone      string
-	PDFFontsDir      string
+	S3Endpoint         string
+	S3Bucket           string
+	SignURLTTL         time.Duration
+	MaxLines           int
+	AllowedDelta       float64
+	RoundingMode       string
+	MaxDescription     int
+	PDFEnabled         bool
+	DefaultTimeZone    string
+	DefaultLocale      string
+	MaxParallelJobs    int
+	EnableAuditHash    bool
+	ValidUnitCodes     []string
+	ValidTaxCategory   []string
+	PDFChromiumPath    string
+	PDFTimeout         time.Duration
+	PDFTmpDir          string
+	PDFLocale          string
+	PDFTimeZone        string
+	PDFFontsDir        string
+	ValidationCacheTTL time.Duration
+	PDFContentHash     bool
+	ValidationStream   bool
+	// MaxGrandTotal caps a document's grand total (0 disables the check);
+	// PlanMaxGrandTotal overrides it per tenant plan, resolved via TenantPlans.
+	MaxGrandTotal     float64
+	PlanMaxGrandTotal map[string]float64
+	TenantPlans       map[string]string
+	// DownloadConcurrency caps concurrent signed downloads per tenant (0 disables);
+	// DownloadQueueWait is how long an excess download waits for a slot before a 429.
+	DownloadConcurrency int
+	DownloadQueueWait   time.Duration
+	// PDFRetryAttempts re-renders a PDF that failed at issue time in the
+	// background (0 disables), waiting PDFRetryDelay, doubled per attempt, between tries.
+	PDFRetryAttempts int
+	PDFRetryDelay    time.Duration
+	// StrictBodyLength rejects non-chunked bodies whose size differs from Content-Length.
+	StrictBodyLength bool
+	// SupportedCurrencies lists the ISO 4217 codes a draft may be invoiced in.
+	SupportedCurrencies []string
+	// PDFPoolSize caps how many tabs render concurrently in the shared
+	// Chromium process (0 disables the cap).
+	PDFPoolSize int
+	// PDFFontFamily names the font the PDF is set in. The first .woff2 in
+	// PDFFontsDir is embedded under this name; without one the host's
+	// installed font of that name, if any, is used.
+	PDFFontFamily string
+	// MaxBatchSize caps the drafts in one batch validation request (0 disables
+	// the cap); batches are validated MaxParallelJobs drafts at a time.
+	MaxBatchSize int
+	// UBLSigningKeyFile and UBLSigningCertFile are PEM files; when both are
+	// set, issued invoices are also stored with an XML-DSig signature.
+	UBLSigningKeyFile  string
+	UBLSigningCertFile string
+	// InvoiceNumberFormat is the pattern for numbers assigned to drafts that
+	// omit one: {year} is the issue year and {seq} the tenant's sequence in
+	// that series, zero-padded to InvoiceNumberWidth digits.
+	InvoiceNumberFormat string
+	InvoiceNumberWidth  int
+	// ValidPaymentMeans lists the UNCL4461 payment means codes a draft may use.
+	ValidPaymentMeans []string
+	// PDFReadinessTTL is how long /readyz reuses the result of its Chromium
+	// launch check.
+	PDFReadinessTTL time.Duration
+	// PublicBaseURL is where InMemoryStorage signed URLs point: objects are
+	// linked as {PublicBaseURL}/{key}.
+	PublicBaseURL string
+	// StorageSigningSecret keys the HMAC on InMemoryStorage signed URLs. When
+	// empty a random secret is generated, so links die with the process.
+	StorageSigningSecret string
+	// IdempotencyTTL is how long an issued invoice's response is replayed for
+	// a repeated Idempotency-Key.
+	IdempotencyTTL time.Duration
+	// MaxRequestBytes bounds a single draft body and MaxBatchRequestBytes a
+	// validate-batch body; larger requests get 413 (0 disables the bound).
+	MaxRequestBytes      int64
+	MaxBatchRequestBytes int64
+	// AuditHashAlgorithm hashes new audit entries: auditchain.SHA256 or
+	// auditchain.SHA512. Existing entries verify with the algorithm they recorded.
+	AuditHashAlgorithm string
+	// StrictPartyCountries lists the country codes whose parties must carry a
+	// qualified-invoice registration number (T + 13 digits) and a 7-digit
+	// postal code; parties from other countries are not checked.
+	StrictPartyCountries []string
 }
 
 func LoadConfig() Config {
 	return Config{
-		S3Endpoint:       getenv("S3_ENDPOINT", "https://s3.example.com"),
-		S3Bucket:         getenv("S3_BUCKET", "jp-pint-invoices"),
-		SignURLTTL:       getDuration("SIGN_URL_TTL", 10*time.Minute),
-		MaxLines:         getInt("MAX_INVOICE_LINES", 500),
-		AllowedDelta:     getFloat("ALLOWED_TOTAL_DELTA", 0.01),
-		RoundingMode:     getenv("ROUNDING_MODE", "HALF_UP"),
-		MaxDescription:   getInt("MAX_DESCRIPTION_LEN", 240),
-		PDFEnabled:       getBool("PDF_ENABLED", true),
-		DefaultTimeZone:  getenv("DEFAULT_TZ", "Asia/Tokyo"),
-		DefaultLocale:    getenv("DEFAULT_LOCALE", "ja-JP"),
-		MaxParallelJobs:  getInt("MAX_PARALLEL_JOBS", 4),
-		EnableAuditHash:  getBool("ENABLE_AUDIT_HASH", true),
-		ValidUnitCodes:   []string{"EA", "HUR", "MTR", "D64", "KGM", "LTR"},
-		ValidTaxCategory: []string{"S", "Z", "E", "O", "AE", "K", "G"},
-		PDFChromiumPath:  getenv("PDF_CHROMIUM_PATH", ""),
-		PDFTimeout:       getDuration("PDF_TIMEOUT", 15*time.Second),
-		PDFTmpDir:        getenv("PDF_TMP_DIR", "/tmp"),
-		PDFLocale:        getenv("PDF_LOCALE", "ja-JP"),
-		PDFTimeZone:      getenv("PDF_TIMEZONE", "Asia/Tokyo"),
-		PDFFontsDir:      getenv("PDF_FONTS_DIR", ""),
+		S3Endpoint:           getenv("S3_ENDPOINT", "https://s3.example.com"),
+		S3Bucket:             getenv("S3_BUCKET", "jp-pint-invoices"),
+		SignURLTTL:           getDuration("SIGN_URL_TTL", 10*time.Minute),
+		MaxLines:             getInt("MAX_INVOICE_LINES", 500),
+		AllowedDelta:         getFloat("ALLOWED_TOTAL_DELTA", 0.01),
+		RoundingMode:         getenv("ROUNDING_MODE", "HALF_UP"),
+		MaxDescription:       getInt("MAX_DESCRIPTION_LEN", 240),
+		PDFEnabled:           getBool("PDF_ENABLED", true),
+		DefaultTimeZone:      getenv("DEFAULT_TZ", "Asia/Tokyo"),
+		DefaultLocale:        getenv("DEFAULT_LOCALE", "ja-JP"),
+		MaxParallelJobs:      getInt("MAX_PARALLEL_JOBS", 4),
+		EnableAuditHash:      getBool("ENABLE_AUDIT_HASH", true),
+		ValidUnitCodes:       []string{"EA", "HUR", "MTR", "D64", "KGM", "LTR"},
+		ValidTaxCategory:     []string{"S", "Z", "E", "O", "AE", "K", "G"},
+		PDFChromiumPath:      getenv("PDF_CHROMIUM_PATH", ""),
+		PDFTimeout:           getDuration("PDF_TIMEOUT", 15*time.Second),
+		PDFTmpDir:            getenv("PDF_TMP_DIR", "/tmp"),
+		PDFLocale:            getenv("PDF_LOCALE", "ja-JP"),
+		PDFTimeZone:          getenv("PDF_TIMEZONE", "Asia/Tokyo"),
+		PDFFontsDir:          getenv("PDF_FONTS_DIR", ""),
+		ValidationCacheTTL:   getDuration("VALIDATION_CACHE_TTL", 0),
+		PDFContentHash:       getBool("PDF_CONTENT_HASH", true),
+		ValidationStream:     getBool("VALIDATION_STREAM_ENABLED", true),
+		MaxGrandTotal:        getFloat("MAX_GRAND_TOTAL", 0),
+		PlanMaxGrandTotal:    getFloatMap("MAX_GRAND_TOTAL_BY_PLAN"),
+		TenantPlans:          getStringMap("TENANT_PLANS"),
+		DownloadConcurrency:  getInt("DOWNLOAD_CONCURRENCY_PER_TENANT", 4),
+		DownloadQueueWait:    getDuration("DOWNLOAD_QUEUE_WAIT", 0),
+		PDFRetryAttempts:     getInt("PDF_RETRY_ATTEMPTS", 0),
+		PDFRetryDelay:        getDuration("PDF_RETRY_DELAY", 30*time.Second),
+		StrictBodyLength:     getBool("STRICT_BODY_LENGTH", false),
+		SupportedCurrencies:  getList("SUPPORTED_CURRENCIES", []string{"JPY"}),
+		PDFPoolSize:          getInt("PDF_POOL_SIZE", 4),
+		PDFFontFamily:        getenv("PDF_FONT_FAMILY", "Noto Sans JP"),
+		MaxBatchSize:         getInt("MAX_BATCH_SIZE", 100),
+		UBLSigningKeyFile:    getenv("UBL_SIGNING_KEY_FILE", ""),
+		UBLSigningCertFile:   getenv("UBL_SIGNING_CERT_FILE", ""),
+		InvoiceNumberFormat:  getenv("INVOICE_NUMBER_FORMAT", "INV-{year}-{seq}"),
+		InvoiceNumberWidth:   getInt("INVOICE_NUMBER_WIDTH", 6),
+		ValidPaymentMeans:    []string{"10", "30", "42", "48", "49", "58"},
+		PDFReadinessTTL:      getDuration("PDF_READINESS_TTL", 5*time.Second),
+		PublicBaseURL:        getenv("PUBLIC_BASE_URL", DefaultPublicBaseURL),
+		StorageSigningSecret: getenv("STORAGE_SIGNING_SECRET", ""),
+		IdempotencyTTL:       getDuration("IDEMPOTENCY_TTL", 24*time.Hour),
+		MaxRequestBytes:      int64(getInt("MAX_REQUEST_BYTES", 1<<20)),
+		MaxBatchRequestBytes: int64(getInt("MAX_BATCH_REQUEST_BYTES", 16<<20)),
+		AuditHashAlgorithm:   getenv("AUDIT_HASH_ALGORITHM", auditchain.SHA256),
+		StrictPartyCountries: getList("STRICT_PARTY_COUNTRIES", []string{string(JP)}),
 	}
 }
 
@@ -97,3 +192,40 @@ func getDuration(key string, def time.Duration) time.Duration {
 	}
 	return def
 }
+
+// getList parses a comma-separated list, falling back to def when unset or empty.
+func getList(key string, def []string) []string {
+	var out []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	if len(out) == 0 {
+		return def
+	}
+	return out
+}
+
+// getStringMap parses "k1=v1,k2=v2"; malformed pairs are skipped.
+func getStringMap(key string) map[string]string {
+	out := map[string]string{}
+	for _, pair := range strings.Split(os.Getenv(key), ",") {
+		k, v, ok := strings.Cut(pair, "=")
+		k, v = strings.TrimSpace(k), strings.TrimSpace(v)
+		if ok && k != "" && v != "" {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+func getFloatMap(key string) map[string]float64 {
+	out := map[string]float64{}
+	for k, v := range getStringMap(key) {
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			out[k] = f
+		}
+	}
+	return out
+}
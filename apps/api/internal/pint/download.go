@@ -0,0 +1,117 @@
+package pint
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DownloadThrottle caps concurrent signed-artifact downloads per tenant so one
+// tenant cannot saturate shared egress. Presigned S3 URLs bypass this process,
+// so for S3 the equivalent limit belongs on the bucket/CDN; the throttle covers
+// downloads proxied through DownloadHandler.
+type DownloadThrottle struct {
+	limit int
+	wait  time.Duration
+
+	mu    sync.Mutex
+	slots map[string]chan struct{}
+}
+
+// NewDownloadThrottle allows limit concurrent downloads per tenant, queuing a
+// request for up to wait before rejecting it. A limit <= 0 disables throttling.
+func NewDownloadThrottle(limit int, wait time.Duration) *DownloadThrottle {
+	return &DownloadThrottle{limit: limit, wait: wait, slots: map[string]chan struct{}{}}
+}
+
+// Acquire reserves a download slot for tenantID, returning its release func, or
+// false when none frees up within the configured wait.
+func (t *DownloadThrottle) Acquire(ctx context.Context, tenantID string) (func(), bool) {
+	if t == nil || t.limit <= 0 {
+		return func() {}, true
+	}
+	t.mu.Lock()
+	sem, ok := t.slots[tenantID]
+	if !ok {
+		sem = make(chan struct{}, t.limit)
+		t.slots[tenantID] = sem
+	}
+	t.mu.Unlock()
+
+	release := func() { <-sem }
+	select {
+	case sem <- struct{}{}:
+		return release, true
+	default:
+	}
+	if t.wait <= 0 {
+		return nil, false
+	}
+	timer := time.NewTimer(t.wait)
+	defer timer.Stop()
+	select {
+	case sem <- struct{}{}:
+		return release, true
+	case <-timer.C:
+		return nil, false
+	case <-ctx.Done():
+		return nil, false
+	}
+}
+
+// tenantFromObjectKey returns the tenant prefix of a storage key
+// ("{tenant}/invoices/{id}/invoice.pdf").
+func tenantFromObjectKey(key string) string {
+	tenant, _, _ := strings.Cut(key, "/")
+	return tenant
+}
+
+// DownloadHandler serves GET /storage/{key} for the in-memory dev storage,
+// throttling concurrent downloads per tenant (the key's first path segment).
+// When storage is a URLVerifier, links that are expired or whose exp was
+// tampered with are rejected with 403 before any slot is taken.
+func DownloadHandler(storage Storage, throttle *DownloadThrottle) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := strings.TrimPrefix(r.URL.Path, "/storage/")
+		if verifier, ok := storage.(URLVerifier); ok {
+			if err := verifier.VerifySignedURL(key, r.URL.Query()); err != nil {
+				code := "URL_SIGNATURE_INVALID"
+				if errors.Is(err, ErrURLExpired) {
+					code = "URL_EXPIRED"
+				}
+				writeJSON(w, http.StatusForbidden, ForbiddenError{
+					Code:      code,
+					Message:   err.Error(),
+					CorrId:    r.Header.Get("X-Correlation-Id"),
+					Retryable: false,
+				})
+				return
+			}
+		}
+		release, ok := throttle.Acquire(r.Context(), tenantFromObjectKey(key))
+		if !ok {
+			w.Header().Set("Retry-After", "1")
+			writeJSON(w, http.StatusTooManyRequests, RateLimitError{
+				Code:              "DOWNLOAD_CONCURRENCY",
+				Message:           fmt.Sprintf("too many concurrent downloads (max %d per tenant)", throttle.limit),
+				CorrId:            r.Header.Get("X-Correlation-Id"),
+				Retryable:         true,
+				RetryAfterSeconds: 1,
+			})
+			return
+		}
+		defer release()
+
+		body, ctype, err := storage.GetObject(r.Context(), key)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", ctype)
+		_, _ = w.Write(body)
+	}
+}
@@ -0,0 +1,155 @@
+package pint
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// blockingStorage holds GetObject for keys containing "slow" until release is closed.
+type blockingStorage struct {
+	*InMemoryStorage
+	started chan struct{}
+	release chan struct{}
+}
+
+func (s *blockingStorage) GetObject(ctx context.Context, key string) ([]byte, string, error) {
+	if strings.Contains(key, "slow") {
+		s.started <- struct{}{}
+		<-s.release
+	}
+	return s.InMemoryStorage.GetObject(ctx, key)
+}
+
+// signedTarget returns the path and query of a URL st signs for key.
+func signedTarget(st *InMemoryStorage, key string, ttl time.Duration) string {
+	raw, err := st.GetSignedURL(context.Background(), key, ttl)
+	if err != nil {
+		panic(err)
+	}
+	u, _ := url.Parse(raw)
+	return "/storage/" + key + "?" + u.RawQuery
+}
+
+func download(h http.Handler, st *InMemoryStorage, key string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, signedTarget(st, key, time.Minute), nil))
+	return w
+}
+
+func TestDownloadHandler_ThrottlesPerTenant(t *testing.T) {
+	storage := &blockingStorage{InMemoryStorage: NewInMemoryStorage(), started: make(chan struct{}, 4), release: make(chan struct{})}
+	for _, key := range []string{"tenant-a/invoices/1/slow.pdf", "tenant-a/invoices/2/invoice.pdf", "tenant-b/invoices/3/invoice.pdf"} {
+		_ = storage.PutObject(context.Background(), key, []byte("%PDF-1.4"), "application/pdf")
+	}
+	h := DownloadHandler(storage, NewDownloadThrottle(2, 0))
+
+	var wg sync.WaitGroup
+	codes := make(chan int, 2)
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes <- download(h, storage.InMemoryStorage, "tenant-a/invoices/1/slow.pdf").Code
+		}()
+	}
+	<-storage.started
+	<-storage.started
+
+	w := download(h, storage.InMemoryStorage, "tenant-a/invoices/2/invoice.pdf")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 beyond the limit, got %d", w.Code)
+	}
+	var body map[string]any
+	_ = json.NewDecoder(w.Body).Decode(&body)
+	if body["code"] != "DOWNLOAD_CONCURRENCY" {
+		t.Fatalf("expected DOWNLOAD_CONCURRENCY, got %v", body["code"])
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Fatalf("expected Retry-After on throttled download")
+	}
+	if w := download(h, storage.InMemoryStorage, "tenant-b/invoices/3/invoice.pdf"); w.Code != http.StatusOK {
+		t.Fatalf("expected other tenant unaffected, got %d", w.Code)
+	}
+
+	close(storage.release)
+	wg.Wait()
+	close(codes)
+	for code := range codes {
+		if code != http.StatusOK {
+			t.Fatalf("expected in-flight downloads to finish, got %d", code)
+		}
+	}
+
+	// Serial downloads release their slot and never trip the limit.
+	for i := 0; i < 5; i++ {
+		if w := download(h, storage.InMemoryStorage, "tenant-a/invoices/2/invoice.pdf"); w.Code != http.StatusOK {
+			t.Fatalf("serial download %d: expected 200, got %d", i, w.Code)
+		}
+	}
+}
+
+func TestDownloadThrottle_QueuesWithinWait(t *testing.T) {
+	throttle := NewDownloadThrottle(1, time.Second)
+	release, ok := throttle.Acquire(context.Background(), "tenant-a")
+	if !ok {
+		t.Fatal("expected first slot")
+	}
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		release()
+	}()
+	second, ok := throttle.Acquire(context.Background(), "tenant-a")
+	if !ok {
+		t.Fatal("expected queued download to get the freed slot")
+	}
+	second()
+}
+
+func TestDownloadHandler_SignedURLs(t *testing.T) {
+	st := NewInMemoryStorage()
+	st.SetSigningSecret("test-secret")
+	key := "tenant-a/invoices/1/invoice.pdf"
+	_ = st.PutObject(context.Background(), key, []byte("%PDF-1.4"), "application/pdf")
+	h := DownloadHandler(st, NewDownloadThrottle(0, 0))
+
+	if w := download(h, st, key); w.Code != http.StatusOK || w.Body.String() != "%PDF-1.4" {
+		t.Fatalf("expected a valid signed URL to serve the object, got %d %q", w.Code, w.Body.String())
+	}
+
+	expired := signedTarget(st, key, -time.Minute)
+	// Extending an expired link must invalidate its signature.
+	path, rawQuery, _ := strings.Cut(expired, "?")
+	q, _ := url.ParseQuery(rawQuery)
+	q.Set("exp", time.Now().UTC().Add(time.Hour).Format(time.RFC3339))
+	tampered := path + "?" + q.Encode()
+	tests := []struct {
+		name   string
+		target string
+		code   string
+	}{
+		{name: "expired", target: expired, code: "URL_EXPIRED"},
+		{name: "tampered exp", target: tampered, code: "URL_SIGNATURE_INVALID"},
+		{name: "unsigned", target: "/storage/" + key, code: "URL_SIGNATURE_INVALID"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.target, nil))
+			if w.Code != http.StatusForbidden {
+				t.Fatalf("expected 403, got %d", w.Code)
+			}
+			var body ForbiddenError
+			_ = json.NewDecoder(w.Body).Decode(&body)
+			if body.Code != tt.code {
+				t.Fatalf("expected %s, got %+v", tt.code, body)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package pint
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// The write*Error helpers emit the typed error envelopes from the contract.
+// Every envelope carries the request's correlation ID, as auditzip's do, so
+// clients can quote it and it can be matched against the service logs.
+
+// writeValidationError writes a 400 ValidationErrorResponse. items lists the
+// per-field problems and is nil when the request as a whole was rejected.
+func writeValidationError(w http.ResponseWriter, corrID, code, message string, items []ValidationErrorItem) {
+	if items == nil {
+		items = []ValidationErrorItem{}
+	}
+	writeJSON(w, http.StatusBadRequest, ValidationErrorResponse{
+		Code:      code,
+		Message:   message,
+		CorrId:    corrID,
+		Retryable: false,
+		Errors:    items,
+	})
+}
+
+func writeNotFound(w http.ResponseWriter, corrID, code, message string) {
+	writeJSON(w, http.StatusNotFound, NotFoundError{Code: code, Message: message, CorrId: corrID, Retryable: false})
+}
+
+func writeConflict(w http.ResponseWriter, corrID, code, message string) {
+	writeJSON(w, http.StatusConflict, ConflictError{Code: code, Message: message, CorrId: corrID, Retryable: false})
+}
+
+// writeIdempotencyConflict writes a 409 ConflictError for a reused
+// Idempotency-Key; a replay of a request still in progress may be retried.
+func writeIdempotencyConflict(w http.ResponseWriter, corrID string, reason ConflictErrorConflictReason, message string) {
+	writeJSON(w, http.StatusConflict, ConflictError{
+		Code:           "CONFLICT",
+		Message:        message,
+		CorrId:         corrID,
+		Retryable:      reason == IdempotencyReplay,
+		ConflictReason: &reason,
+	})
+}
+
+// writeBodyTooLarge writes a 413 RequestTooLargeError for a body over limit bytes.
+func writeBodyTooLarge(w http.ResponseWriter, corrID string, limit int64) {
+	writeJSON(w, http.StatusRequestEntityTooLarge, RequestTooLargeError{
+		Code:      "BODY_TOO_LARGE",
+		Message:   fmt.Sprintf("request body exceeds %d bytes", limit),
+		CorrId:    corrID,
+		Retryable: false,
+	})
+}
+
+// writeInternalError writes an InternalError with status, which is 500 unless
+// the failure is a transient 503.
+func writeInternalError(w http.ResponseWriter, status int, corrID, code, message string, retryable bool) {
+	writeJSON(w, status, InternalError{Code: code, Message: message, CorrId: corrID, Retryable: retryable})
+}
@@ -0,0 +1,174 @@
+package pint
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// errorEnvelope decodes any of the typed error bodies; Retryable is a pointer
+// so a missing field is told apart from false.
+type errorEnvelope struct {
+	Code      string                `json:"code"`
+	Message   string                `json:"message"`
+	CorrId    string                `json:"corrId"`
+	Retryable *bool                 `json:"retryable"`
+	Errors    []ValidationErrorItem `json:"errors"`
+}
+
+func TestHandlers_ErrorEnvelopeCarriesCorrID(t *testing.T) {
+	draftBody, _ := json.Marshal(sampleDraft())
+	invalid := sampleDraft()
+	invalid.Lines[0].Quantity = 0
+	invalidBody, _ := json.Marshal(invalid)
+	number := "INV-DUP-1"
+	duplicate := sampleDraft()
+	duplicate.InvoiceNumber = &number
+	duplicateBody, _ := json.Marshal(duplicate)
+
+	signerCfg := LoadConfig()
+	signerCfg.UBLSigningKeyFile = "/nonexistent/key.pem"
+	signerCfg.UBLSigningCertFile = "/nonexistent/cert.pem"
+	batchCfg := LoadConfig()
+	batchCfg.MaxBatchSize = 1
+
+	tests := []struct {
+		name      string
+		cfg       Config
+		setup     func(svc Service)
+		request   func() *http.Request
+		handle    func(svc Service, w http.ResponseWriter, r *http.Request)
+		status    int
+		code      string
+		retryable bool
+		items     bool
+	}{
+		{
+			name: "missing tenant",
+			request: func() *http.Request {
+				r := newInvoiceRequest(http.MethodPost, "/invoices/validate", draftBody)
+				r.Header.Del("X-Tenant-Id")
+				return r
+			},
+			handle: Service.ValidateInvoice,
+			status: http.StatusBadRequest,
+			code:   "BAD_REQUEST",
+		},
+		{
+			name:    "malformed JSON",
+			request: func() *http.Request { return asTenant(newInvoiceRequest(http.MethodPost, "/invoices", []byte("{")), "tenant-a") },
+			handle:  Service.IssueInvoice,
+			status:  http.StatusBadRequest,
+			code:    "BAD_REQUEST",
+		},
+		{
+			name:    "validation failure",
+			request: func() *http.Request { return asTenant(newInvoiceRequest(http.MethodPost, "/invoices", invalidBody), "tenant-a") },
+			handle:  Service.IssueInvoice,
+			status:  http.StatusBadRequest,
+			code:    "VALIDATION_ERROR",
+			items:   true,
+		},
+		{
+			name: "duplicate invoice number",
+			setup: func(svc Service) {
+				w := httptest.NewRecorder()
+				svc.IssueInvoice(w, asTenant(newInvoiceRequest(http.MethodPost, "/invoices", duplicateBody), "tenant-a"))
+			},
+			request: func() *http.Request { return asTenant(newInvoiceRequest(http.MethodPost, "/invoices", duplicateBody), "tenant-a") },
+			handle:  Service.IssueInvoice,
+			status:  http.StatusConflict,
+			code:    "DUPLICATE_INVOICE_NUMBER",
+		},
+		{
+			name:      "misconfigured signer",
+			cfg:       signerCfg,
+			request:   func() *http.Request { return asTenant(newInvoiceRequest(http.MethodPost, "/invoices", draftBody), "tenant-a") },
+			handle:    Service.IssueInvoice,
+			status:    http.StatusInternalServerError,
+			code:      "INTERNAL_ERROR",
+			retryable: false,
+		},
+		{
+			name:    "invoice not found",
+			request: func() *http.Request { return asTenant(newInvoiceRequest(http.MethodGet, "/invoices/missing", nil), "tenant-a") },
+			handle: func(svc Service, w http.ResponseWriter, r *http.Request) {
+				svc.GetInvoice(w, r, "00000000-0000-0000-0000-000000000000")
+			},
+			status: http.StatusNotFound,
+			code:   "NOT_FOUND",
+		},
+		{
+			name:    "invalid list limit",
+			request: func() *http.Request { return asTenant(newInvoiceRequest(http.MethodGet, "/invoices?limit=0", nil), "tenant-a") },
+			handle:  Service.ListInvoices,
+			status:  http.StatusBadRequest,
+			code:    "BAD_REQUEST",
+		},
+		{
+			name: "batch too large",
+			cfg:  batchCfg,
+			request: func() *http.Request {
+				body, _ := json.Marshal(batchValidationRequest{Drafts: []InvoiceDraft{sampleDraft(), sampleDraft()}})
+				return newInvoiceRequest(http.MethodPost, "/invoices/validate-batch", body)
+			},
+			handle: Service.ValidateInvoiceBatch,
+			status: http.StatusRequestEntityTooLarge,
+			code:   "BATCH_TOO_LARGE",
+		},
+	}
+	for _, tt := range tests {
+		cfg := tt.cfg
+		if cfg.S3Bucket == "" {
+			cfg = LoadConfig()
+		}
+		svc, _ := newTestService(cfg)
+		if tt.setup != nil {
+			tt.setup(svc)
+		}
+		w := httptest.NewRecorder()
+		tt.handle(svc, w, tt.request())
+		if w.Code != tt.status {
+			t.Errorf("%s: expected %d, got %d: %s", tt.name, tt.status, w.Code, w.Body.String())
+			continue
+		}
+		var body errorEnvelope
+		if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+			t.Errorf("%s: decode: %v", tt.name, err)
+			continue
+		}
+		if body.Code != tt.code || body.Message == "" || body.CorrId != "corr-1" {
+			t.Errorf("%s: expected code %s with message and corrId corr-1, got %+v", tt.name, tt.code, body)
+		}
+		if body.Retryable == nil || *body.Retryable != tt.retryable {
+			t.Errorf("%s: expected retryable=%v, got %v", tt.name, tt.retryable, body.Retryable)
+		}
+		if tt.status == http.StatusBadRequest && (body.Errors == nil || (len(body.Errors) > 0) != tt.items) {
+			t.Errorf("%s: expected errors array (non-empty: %v), got %+v", tt.name, tt.items, body.Errors)
+		}
+	}
+}
+
+func TestDownloadHandler_RateLimitEnvelope(t *testing.T) {
+	throttle := NewDownloadThrottle(1, 0)
+	release, _ := throttle.Acquire(context.Background(), "tenant-a")
+	defer release()
+
+	st := NewInMemoryStorage()
+	_ = st.PutObject(context.Background(), "tenant-a/invoices/1/invoice.pdf", []byte("%PDF-1.4"), "application/pdf")
+	r := httptest.NewRequest(http.MethodGet, signedTarget(st, "tenant-a/invoices/1/invoice.pdf", time.Minute), nil)
+	r.Header.Set("X-Correlation-Id", "corr-1")
+	w := httptest.NewRecorder()
+	DownloadHandler(st, throttle)(w, r)
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 while the tenant's slot is held, got %d", w.Code)
+	}
+	var body RateLimitError
+	_ = json.NewDecoder(w.Body).Decode(&body)
+	if body.Code != "DOWNLOAD_CONCURRENCY" || body.CorrId != "corr-1" || !body.Retryable || body.RetryAfterSeconds < 1 {
+		t.Fatalf("expected a retryable rate-limit envelope with corrId, got %+v", body)
+	}
+}
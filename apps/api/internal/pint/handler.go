@@ -8,48 +8,97 @@ import (
 	"io"
 	"log/slog"
 	"net/http"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
 	openapi_types "github.com/oapi-codegen/runtime/types"
+	"github.com/yourorg/yourapp/apps/api/internal/auth"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Service wires config, validation, storage, and audit into HTTP handlers.
 type Service struct {
 cfg       Config
-validator Validator
+validator InvoiceValidator
 storage   Storage
 audit     AuditRecorder
+invoices  InvoiceStore
+sequences SequenceStore
+idempotency IdempotencyStore
 logger    *slog.Logger
-pdf       PDFRenderer
+pdf       InvoicePDFRenderer
+// signer is nil when no signing key is configured; signerErr records a
+// configured key that failed to load, which makes issuance fail closed.
+signer    *UBLSigner
+signerErr error
 }
 
 func NewService(cfg Config, storage Storage, audit AuditRecorder, logger *slog.Logger) Service {
+var validator InvoiceValidator = Validator{Config: cfg}
+if cfg.ValidationCacheTTL > 0 {
+validator = NewCachingValidator(validator, cfg)
+}
+signer, signerErr := LoadUBLSigner(cfg)
 return Service{
 cfg:       cfg,
-validator: Validator{Config: cfg},
+validator: validator,
 storage:   storage,
 audit:     audit,
+invoices:  NewInMemoryInvoiceStore(),
+sequences: NewInMemorySequenceStore(),
+idempotency: NewInMemoryIdempotencyStore(cfg.IdempotencyTTL),
 logger:    logger,
 pdf:       NewPDFRenderer(cfg),
+signer:    signer,
+signerErr: signerErr,
+}
+}
+
+// Close releases resources held by the service, such as the shared PDF browser.
+func (s Service) Close() error {
+if closer, ok := s.pdf.(io.Closer); ok {
+return closer.Close()
 }
+return nil
+}
+
+// PDFReady reports whether the PDF renderer can launch Chromium. Renderers
+// without a browser behind them are always ready.
+func (s Service) PDFReady(ctx context.Context) error {
+if pinger, ok := s.pdf.(interface{ Ping(context.Context) error }); ok {
+return pinger.Ping(ctx)
+}
+return nil
+}
+
+// validatorFor returns the validator for tenantID. Tenants whose plan overrides
+// MaxGrandTotal get a dedicated validator, since cached results are plan-agnostic.
+func (s Service) validatorFor(tenantID string) InvoiceValidator {
+plan := s.cfg.TenantPlans[tenantID]
+if _, ok := s.cfg.PlanMaxGrandTotal[plan]; ok && plan != "" {
+return Validator{Config: s.cfg, Plan: plan}
+}
+return s.validator
 }
 
 // ValidateInvoice matches POST /invoices/validate
 func (s Service) ValidateInvoice(w http.ResponseWriter, r *http.Request) {
 	ctx, corrID, tenantID, err := withRequestContext(r)
 	if err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"code": "BAD_REQUEST", "message": err.Error()})
+		writeValidationError(w, corrID, "BAD_REQUEST", err.Error(), nil)
 		return
 	}
 	logger := CorrelationLogger(s.logger, corrID, tenantID)
 
-	draft, err := decodeDraft(r.Body)
+	draft, err := s.decodeDraft(w, r)
 	if err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"code": "BAD_REQUEST", "message": err.Error()})
+		s.writeDraftError(w, corrID, err)
 		return
 	}
-	result := s.validator.Validate(draft)
+	result := validateTraced(ctx, s.validatorFor(tenantID), draft)
 	if err := s.appendAudit(ctx, tenantID, corrID, string(InvoiceValidate)); err != nil {
 		logger.Warn("audit append failed", "error", err)
 	}
@@ -60,84 +109,391 @@ func (s Service) ValidateInvoice(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// IssueInvoice matches POST /invoices
-func (s Service) IssueInvoice(w http.ResponseWriter, r *http.Request) {
+// batchValidationRequest is the body of ValidateInvoiceBatch.
+type batchValidationRequest struct {
+Drafts []InvoiceDraft `json:"drafts"`
+}
+
+// batchValidationResponse holds one result per draft, in request order.
+type batchValidationResponse struct {
+AllValid bool               `json:"allValid"`
+Results  []ValidationResult `json:"results"`
+}
+
+// ValidateInvoiceBatch matches POST /invoices/validate-batch. Drafts are
+// validated concurrently, at most cfg.MaxParallelJobs at a time, and results
+// keep the request order.
+func (s Service) ValidateInvoiceBatch(w http.ResponseWriter, r *http.Request) {
 	ctx, corrID, tenantID, err := withRequestContext(r)
 	if err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"code": "BAD_REQUEST", "message": err.Error()})
+		writeValidationError(w, corrID, "BAD_REQUEST", err.Error(), nil)
 		return
 	}
 	logger := CorrelationLogger(s.logger, corrID, tenantID)
 
-	draft, err := decodeDraft(r.Body)
+	defer r.Body.Close()
+	var req batchValidationRequest
+	err = limitBody(w, r, s.cfg.MaxBatchRequestBytes)
+	if err == nil {
+		err = json.NewDecoder(r.Body).Decode(&req)
+	}
+	if errors.Is(err, ErrBodyTooLarge) || isBodyTooLarge(err) {
+		writeBodyTooLarge(w, corrID, s.cfg.MaxBatchRequestBytes)
+		return
+	}
 	if err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"code": "BAD_REQUEST", "message": err.Error()})
+		writeValidationError(w, corrID, "BAD_REQUEST", fmt.Sprintf("invalid JSON: %v", err), nil)
 		return
 	}
-	validation := s.validator.Validate(draft)
-	if !validation.Valid {
-		writeJSON(w, http.StatusBadRequest, map[string]any{
-			"errors": validation.Errors,
+	if len(req.Drafts) == 0 {
+		writeValidationError(w, corrID, "BAD_REQUEST", "drafts must not be empty", nil)
+		return
+	}
+	if s.cfg.MaxBatchSize > 0 && len(req.Drafts) > s.cfg.MaxBatchSize {
+		writeJSON(w, http.StatusRequestEntityTooLarge, RequestTooLargeError{
+			Code:      "BATCH_TOO_LARGE",
+			Message:   fmt.Sprintf("batch holds %d drafts (max %d)", len(req.Drafts), s.cfg.MaxBatchSize),
+			CorrId:    corrID,
+			Retryable: false,
 		})
 		return
 	}
 
+	validator := s.validatorFor(tenantID)
+	results := make([]ValidationResult, len(req.Drafts))
+	errs := runBounded(ctx, req.Drafts, s.cfg.MaxParallelJobs, func(ctx context.Context, i int, draft InvoiceDraft) error {
+		results[i] = validateTraced(ctx, validator, draft)
+		return nil
+	})
+	if err := errors.Join(errs...); err != nil {
+		logger.Warn("batch validation interrupted", "error", err)
+		writeInternalError(w, http.StatusServiceUnavailable, corrID, "CANCELED", "batch validation was interrupted", true)
+		return
+	}
+
+	resp := batchValidationResponse{AllValid: true, Results: results}
+	for _, result := range results {
+		resp.AllValid = resp.AllValid && result.Valid
+	}
+	if err := s.appendAudit(ctx, tenantID, corrID, string(InvoiceValidate)); err != nil {
+		logger.Warn("audit append failed", "error", err)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// validationStreamEvent is one NDJSON line of ValidateInvoiceStream: an "error"
+// per validation problem as it is found, then a single closing "summary".
+type validationStreamEvent struct {
+Type       string               `json:"type"`
+Error      *ValidationErrorItem `json:"error,omitempty"`
+Valid      *bool                `json:"valid,omitempty"`
+ErrorCount *int                 `json:"errorCount,omitempty"`
+Totals     *Totals              `json:"totals,omitempty"`
+}
+
+// ValidateInvoiceStream matches POST /invoices/validate/stream, emitting
+// validation errors as NDJSON while the draft is checked. ValidateInvoice
+// remains the default; this endpoint can be turned off via VALIDATION_STREAM_ENABLED.
+func (s Service) ValidateInvoiceStream(w http.ResponseWriter, r *http.Request) {
+if !s.cfg.ValidationStream {
+writeNotFound(w, r.Header.Get("X-Correlation-Id"), "NOT_FOUND", "streaming validation disabled")
+return
+}
+ctx, corrID, tenantID, err := withRequestContext(r)
+if err != nil {
+writeValidationError(w, corrID, "BAD_REQUEST", err.Error(), nil)
+return
+}
+logger := CorrelationLogger(s.logger, corrID, tenantID)
+
+draft, err := s.decodeDraft(w, r)
+if err != nil {
+s.writeDraftError(w, corrID, err)
+return
+}
+
+w.Header().Set("Content-Type", "application/x-ndjson")
+w.WriteHeader(http.StatusOK)
+flusher, _ := w.(http.Flusher)
+enc := json.NewEncoder(w)
+send := func(ev validationStreamEvent) {
+_ = enc.Encode(ev)
+if flusher != nil {
+flusher.Flush()
+}
+}
+
+result := Validator{Config: s.cfg, Plan: s.cfg.TenantPlans[tenantID]}.ValidateStream(draft, func(item ValidationErrorItem) {
+send(validationStreamEvent{Type: "error", Error: &item})
+})
+errorCount := len(result.Errors)
+send(validationStreamEvent{Type: "summary", Valid: &result.Valid, ErrorCount: &errorCount, Totals: &result.Totals})
+
+if err := s.appendAudit(ctx, tenantID, corrID, string(InvoiceValidate)); err != nil {
+logger.Warn("audit append failed", "error", err)
+}
+}
+
+// IssueInvoice matches POST /invoices behind auth.Middleware; the invoice is
+// issued for the authenticated tenant. With an Idempotency-Key, a retry of the
+// same draft replays the original response instead of issuing a new invoice.
+func (s Service) IssueInvoice(w http.ResponseWriter, r *http.Request) {
+	ctx, corrID, tenantID, ok := withActorContext(w, r)
+	if !ok {
+		return
+	}
+	logger := CorrelationLogger(s.logger, corrID, tenantID)
+	idempotencyKey := r.Header.Get("Idempotency-Key")
+	if len(idempotencyKey) > MaxIdempotencyKeyLength {
+		writeValidationError(w, corrID, "BAD_REQUEST", fmt.Sprintf("Idempotency-Key must be at most %d characters", MaxIdempotencyKeyLength), nil)
+		return
+	}
+
+	draft, err := s.decodeDraft(w, r)
+	if err != nil {
+		s.writeDraftError(w, corrID, err)
+		return
+	}
+	validation := validateTraced(ctx, s.validatorFor(tenantID), draft)
+	if !validation.Valid {
+		writeValidationError(w, corrID, "VALIDATION_ERROR", "invoice validation failed", validation.Errors)
+		return
+	}
+
+	// Reserve the key before any side effect; unless the response is
+	// recorded, it is freed again so the client can retry.
+	recorded := false
+	if idempotencyKey != "" {
+		replay, err := s.idempotency.Begin(ctx, tenantID, idempotencyKey, draftHash(draft))
+		switch {
+		case errors.Is(err, ErrIdempotencyBodyMismatch):
+			writeIdempotencyConflict(w, corrID, IdempotencyBodyMismatch, err.Error())
+			return
+		case errors.Is(err, ErrIdempotencyInFlight):
+			writeIdempotencyConflict(w, corrID, IdempotencyReplay, err.Error())
+			return
+		case err != nil:
+			logger.Error("idempotency lookup failed", "error", err)
+			writeInternalError(w, http.StatusInternalServerError, corrID, "INTERNAL_ERROR", "failed to check idempotency key", true)
+			return
+		case replay != nil:
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(replay.Status)
+			_, _ = w.Write(replay.Body)
+			return
+		}
+		defer func() {
+			if recorded {
+				return
+			}
+			if err := s.idempotency.Abort(context.WithoutCancel(ctx), tenantID, idempotencyKey); err != nil {
+				logger.Warn("release idempotency key failed", "error", err)
+			}
+		}()
+	}
+
+	invoiceNumber, err := s.assignInvoiceNumber(ctx, tenantID, draft)
+	if err != nil {
+		if errors.Is(err, ErrDuplicateInvoiceNumber) {
+			writeConflict(w, corrID, "DUPLICATE_INVOICE_NUMBER", fmt.Sprintf("invoice number %q is already in use", invoiceNumber))
+			return
+		}
+		logger.Error("assign invoice number failed", "error", err)
+		writeInternalError(w, http.StatusInternalServerError, corrID, "INTERNAL_ERROR", "failed to assign invoice number", true)
+		return
+	}
+	// Hand the number back unless the invoice is recorded, so a failed
+	// issuance neither burns a sequence number nor blocks a retry.
+	issued := false
+	defer func() {
+		if issued {
+			return
+		}
+		if err := s.sequences.Release(context.WithoutCancel(ctx), tenantID, invoiceNumber); err != nil {
+			logger.Warn("release invoice number failed", "invoiceNumber", invoiceNumber, "error", err)
+		}
+	}()
+	draft.InvoiceNumber = &invoiceNumber
+
 	invoiceID := newID()
-	xmlBody, err := BuildUBL(invoiceID, draft, validation.Totals)
+	_, ublSpan := startSpan(ctx, "pint.BuildUBL", trace.WithAttributes(attribute.String("invoice.number", invoiceNumber)))
+	xmlBody, err := BuildUBL(invoiceNumber, draft, validation.Totals)
+	endSpan(ublSpan, err)
 	if err != nil {
 		logger.Error("ubl build failed", "error", err)
-		writeJSON(w, http.StatusInternalServerError, map[string]any{
-			"code":      "INTERNAL_ERROR",
-			"message":   "failed to generate UBL XML",
-			"retryable": true,
-		})
+		writeInternalError(w, http.StatusInternalServerError, corrID, "INTERNAL_ERROR", "failed to generate UBL XML", true)
 		return
 	}
+	if ublErrors := ValidateUBL([]byte(xmlBody)); len(ublErrors) > 0 {
+		logger.Error("generated UBL failed validation", "invoiceId", invoiceID, "errors", ublErrors)
+		writeInternalError(w, http.StatusInternalServerError, corrID, "INTERNAL_ERROR", "generated UBL XML failed validation", false)
+		return
+	}
+
+	var signedXML, signatureDigest string
+	if s.signerErr != nil {
+		logger.Error("ubl signer unavailable", "error", s.signerErr)
+		writeInternalError(w, http.StatusInternalServerError, corrID, "INTERNAL_ERROR", "invoice signing is misconfigured", false)
+		return
+	}
+	if s.signer != nil {
+		signedXML, signatureDigest, err = s.signer.Sign(xmlBody)
+		if err != nil {
+			logger.Error("ubl signing failed", "error", err)
+			writeInternalError(w, http.StatusInternalServerError, corrID, "INTERNAL_ERROR", "failed to sign UBL XML", true)
+			return
+		}
+	}
 
 	xmlKey := fmt.Sprintf("%s/invoices/%s/invoice.xml", tenantID, invoiceID)
 	if err := s.storage.PutObject(ctx, xmlKey, []byte(xmlBody), "application/xml"); err != nil {
 		logger.Error("store xml failed", "error", err)
-		writeJSON(w, http.StatusInternalServerError, map[string]any{
-			"code":      "INTERNAL_ERROR",
-			"message":   "storage error",
-			"retryable": true,
-		})
+		writeInternalError(w, http.StatusInternalServerError, corrID, "INTERNAL_ERROR", "storage error", true)
 		return
 	}
 	xmlURL, _ := s.storage.GetSignedURL(ctx, xmlKey, s.cfg.SignURLTTL)
 
-	var pdfURL string
+	var signedXMLKey, signedXMLURL string
+	if signedXML != "" {
+		signedXMLKey = fmt.Sprintf("%s/invoices/%s/invoice.signed.xml", tenantID, invoiceID)
+		if err := s.storage.PutObject(ctx, signedXMLKey, []byte(signedXML), "application/xml"); err != nil {
+			logger.Error("store signed xml failed", "error", err)
+			writeInternalError(w, http.StatusInternalServerError, corrID, "INTERNAL_ERROR", "storage error", true)
+			return
+		}
+		signedXMLURL, _ = s.storage.GetSignedURL(ctx, signedXMLKey, s.cfg.SignURLTTL)
+	}
+
+	var hash string
+	if s.cfg.PDFContentHash {
+		hash = contentHash([]byte(xmlBody))
+		metaKey := fmt.Sprintf("%s/invoices/%s/meta.json", tenantID, invoiceID)
+		if err := s.storage.PutObject(ctx, metaKey, encodeInvoiceMeta(hash), "application/json"); err != nil {
+			logger.Warn("store meta failed", "error", err)
+		}
+	}
+
+	var pdfURL, storedPDFKey string
+	pdfStatus := InvoiceRecordPdfStatusSkipped
 	if s.cfg.PDFEnabled {
-		pdfKey := fmt.Sprintf("%s/invoices/%s/invoice.pdf", tenantID, invoiceID)
-		if pdfBytes, pdfErr := s.pdf.Render(ctx, draft, validation.Totals); pdfErr == nil {
-			if err := s.storage.PutObject(ctx, pdfKey, pdfBytes, "application/pdf"); err != nil {
-				logger.Warn("store pdf failed", "error", err)
-			} else {
-				pdfURL, _ = s.storage.GetSignedURL(ctx, pdfKey, s.cfg.SignURLTTL)
+		if err := s.renderPDF(ctx, tenantID, invoiceID, draft, validation.Totals, hash); err != nil {
+			logger.Warn("pdf render failed", "error", err)
+			pdfStatus = InvoiceRecordPdfStatusFailed
+			if err := s.recordPDFStatus(ctx, tenantID, invoiceID, pdfStatus); err != nil {
+				logger.Warn("store pdf status failed", "error", err)
+			}
+			if s.cfg.PDFRetryAttempts > 0 {
+				go s.retryPDFRender(tenantID, invoiceID, draft, validation.Totals, hash)
 			}
 		} else {
-			logger.Warn("pdf render failed", "error", pdfErr)
+			pdfStatus = InvoiceRecordPdfStatusRendered
+			storedPDFKey = pdfKey(tenantID, invoiceID)
+			pdfURL, _ = s.storage.GetSignedURL(ctx, storedPDFKey, s.cfg.SignURLTTL)
 		}
 	}
 
+	if err := s.invoices.Save(ctx, tenantID, InvoiceSummary{
+		InvoiceID:     invoiceID,
+		InvoiceNumber: invoiceNumber,
+		Status:        InvoiceRecordStatusIssued,
+		CreatedAt:     time.Now().UTC(),
+		GrandTotal:    validation.Totals.GrandTotal,
+		Currency:      draft.Currency,
+		XMLKey:        xmlKey,
+		SignedXMLKey:  signedXMLKey,
+		PDFKey:        storedPDFKey,
+	}); err != nil {
+		logger.Error("store invoice record failed", "error", err)
+		writeInternalError(w, http.StatusInternalServerError, corrID, "INTERNAL_ERROR", "storage error", true)
+		return
+	}
+	issued = true
+
 	if err := s.appendAudit(ctx, tenantID, corrID, string(InvoiceIssue)); err != nil {
 		logger.Warn("audit append failed", "error", err)
 	}
 
-	writeJSONStatus(w, http.StatusCreated, map[string]any{
-		"invoiceId": invoiceID,
-		"status":    "issued",
-		"xmlUrl":    xmlURL,
-		"pdfUrl":    pdfURL,
-		"expiresAt": time.Now().Add(s.cfg.SignURLTTL).UTC().Format(time.RFC3339),
-	})
+	resp := map[string]any{
+		"invoiceId":     invoiceID,
+		"invoiceNumber": invoiceNumber,
+		"status":        "issued",
+		"xmlUrl":        xmlURL,
+		"pdfUrl":        pdfURL,
+		"pdfStatus":     pdfStatus,
+		"expiresAt":     time.Now().Add(s.cfg.SignURLTTL).UTC().Format(time.RFC3339),
+	}
+	if signatureDigest != "" {
+		resp["signedXmlUrl"] = signedXMLURL
+		resp["signatureDigest"] = signatureDigest
+	}
+	if idempotencyKey != "" {
+		body, _ := json.Marshal(resp)
+		if err := s.idempotency.Complete(context.WithoutCancel(ctx), tenantID, idempotencyKey, IssuedResponse{Status: http.StatusCreated, Body: body}); err != nil {
+			logger.Warn("record idempotent response failed", "error", err)
+		} else {
+			recorded = true
+		}
+	}
+	writeJSONStatus(w, http.StatusCreated, resp)
 }
 
-// GetInvoice matches GET /invoices/{id}
-func (s Service) GetInvoice(w http.ResponseWriter, r *http.Request, id string) {
-	ctx, corrID, tenantID, err := withRequestContext(r)
+// ListInvoices matches GET /invoices behind auth.Middleware, paging the
+// authenticated tenant's issued invoices newest first. from (inclusive) and
+// to (exclusive) bound createdAt as RFC 3339 timestamps.
+func (s Service) ListInvoices(w http.ResponseWriter, r *http.Request) {
+	ctx, corrID, tenantID, ok := withActorContext(w, r)
+	if !ok {
+		return
+	}
+	logger := CorrelationLogger(s.logger, corrID, tenantID)
+
+	query := r.URL.Query()
+	opts := ListInvoicesOptions{Cursor: query.Get("cursor")}
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > MaxListInvoicesLimit {
+			writeValidationError(w, corrID, "BAD_REQUEST", fmt.Sprintf("limit must be between 1 and %d", MaxListInvoicesLimit), nil)
+			return
+		}
+		opts.Limit = limit
+	}
+	for name, bound := range map[string]*time.Time{"from": &opts.From, "to": &opts.To} {
+		if v := query.Get(name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				writeValidationError(w, corrID, "BAD_REQUEST", name + " must be an RFC 3339 timestamp", nil)
+				return
+			}
+			*bound = t
+		}
+	}
+	if !opts.From.IsZero() && !opts.To.IsZero() && !opts.From.Before(opts.To) {
+		writeValidationError(w, corrID, "BAD_REQUEST", "from must be before to", nil)
+		return
+	}
+
+	page, err := s.invoices.ListByTenant(ctx, tenantID, opts)
 	if err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"code": "BAD_REQUEST", "message": err.Error()})
+		if errors.Is(err, ErrInvalidCursor) {
+			writeValidationError(w, corrID, "BAD_REQUEST", err.Error(), nil)
+			return
+		}
+		logger.Error("list invoices failed", "error", err)
+		writeInternalError(w, http.StatusInternalServerError, corrID, "INTERNAL_ERROR", "storage error", true)
+		return
+	}
+
+	if err := s.appendAudit(ctx, tenantID, corrID, "invoice.list"); err != nil {
+		logger.Warn("audit append failed", "error", err)
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+// GetInvoice matches GET /invoices/{id} behind auth.Middleware.
+func (s Service) GetInvoice(w http.ResponseWriter, r *http.Request, id string) {
+	ctx, corrID, tenantID, ok := withActorContext(w, r)
+	if !ok {
 		return
 	}
 	logger := CorrelationLogger(s.logger, corrID, tenantID)
@@ -145,25 +501,31 @@ func (s Service) GetInvoice(w http.ResponseWriter, r *http.Request, id string) {
 	xmlKey := fmt.Sprintf("%s/invoices/%s/invoice.xml", tenantID, id)
 	meta, err := s.storage.Head(ctx, xmlKey)
 	if err != nil {
-		writeJSON(w, http.StatusNotFound, map[string]string{"code": "NOT_FOUND", "message": "invoice not found"})
+		writeNotFound(w, corrID, "NOT_FOUND", "invoice not found")
 		return
 	}
 
 	xmlURL, _ := s.storage.GetSignedURL(ctx, xmlKey, s.cfg.SignURLTTL)
-	pdfKey := fmt.Sprintf("%s/invoices/%s/invoice.pdf", tenantID, id)
-	pdfURL, _ := s.storage.GetSignedURL(ctx, pdfKey, s.cfg.SignURLTTL)
+	pdfURL, _ := s.storage.GetSignedURL(ctx, pdfKey(tenantID, id), s.cfg.SignURLTTL)
+	pdfStatus := s.pdfStatus(ctx, tenantID, id)
 
 	invoiceUUID, err := uuid.Parse(id)
 	if err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"code": "BAD_REQUEST", "message": "invalid invoice ID format"})
+		writeValidationError(w, corrID, "BAD_REQUEST", "invalid invoice ID format", nil)
 		return
 	}
 
+	status := InvoiceRecordStatusIssued
+	if summary, err := s.invoices.Get(ctx, tenantID, id); err == nil {
+		status = summary.Status
+	}
+
 	record := InvoiceRecord{
 		InvoiceId: openapi_types.UUID(invoiceUUID),
-		Status:    InvoiceRecordStatusIssued,
+		Status:    status,
 		XmlUrl:    xmlURL,
 		PdfUrl:    &pdfURL,
+		PdfStatus: &pdfStatus,
 		CreatedAt: meta.UpdatedAt,
 		UpdatedAt: meta.UpdatedAt,
 		Audit: &AuditEntry{
@@ -184,16 +546,269 @@ func (s Service) GetInvoice(w http.ResponseWriter, r *http.Request, id string) {
 	writeJSON(w, http.StatusOK, record)
 }
 
-func decodeDraft(body io.ReadCloser) (InvoiceDraft, error) {
-defer body.Close()
+// VoidInvoice matches POST /invoices/{id}/void behind auth.Middleware. It
+// marks the stored invoice voided and records an invoice.void audit entry; the
+// XML and PDF stay in storage unchanged. Voiding an already-voided invoice is
+// a 409.
+func (s Service) VoidInvoice(w http.ResponseWriter, r *http.Request, id string) {
+	ctx, corrID, tenantID, ok := withActorContext(w, r)
+	if !ok {
+		return
+	}
+	logger := CorrelationLogger(s.logger, corrID, tenantID)
+
+	invoice, err := s.invoices.Void(ctx, tenantID, id)
+	switch {
+	case errors.Is(err, ErrInvoiceNotFound):
+		writeNotFound(w, corrID, "NOT_FOUND", "invoice not found")
+		return
+	case errors.Is(err, ErrInvoiceAlreadyVoided):
+		writeConflict(w, corrID, "ALREADY_VOIDED", "invoice is already voided")
+		return
+	case err != nil:
+		logger.Error("void invoice failed", "error", err)
+		writeInternalError(w, http.StatusInternalServerError, corrID, "INTERNAL_ERROR", "storage error", true)
+		return
+	}
+
+	if err := s.appendAudit(ctx, tenantID, corrID, "invoice.void"); err != nil {
+		logger.Warn("audit append failed", "error", err)
+	}
+	writeJSON(w, http.StatusOK, invoice)
+}
+
+// PreviewInvoicePDF matches POST /invoices/preview behind auth.Middleware. It
+// validates the draft with the authenticated tenant's rules and returns the
+// rendered PDF inline without storing anything, assigning an invoice number or
+// recording an invoice.issue audit entry, so the preview carries neither a
+// number nor a content hash.
+func (s Service) PreviewInvoicePDF(w http.ResponseWriter, r *http.Request) {
+	ctx, corrID, tenantID, ok := withActorContext(w, r)
+	if !ok {
+		return
+	}
+	logger := CorrelationLogger(s.logger, corrID, tenantID)
+	if !s.cfg.PDFEnabled {
+		writeInternalError(w, http.StatusServiceUnavailable, corrID, "PDF_DISABLED", "PDF rendering is disabled", false)
+		return
+	}
+
+	draft, err := s.decodeDraft(w, r)
+	if err != nil {
+		s.writeDraftError(w, corrID, err)
+		return
+	}
+	validation := validateTraced(ctx, s.validatorFor(tenantID), draft)
+	if !validation.Valid {
+		writeValidationError(w, corrID, "VALIDATION_ERROR", "invoice validation failed", validation.Errors)
+		return
+	}
+
+	pdfBytes, err := s.pdf.Render(ctx, draft, validation.Totals, "")
+	if err != nil {
+		logger.Error("pdf preview render failed", "error", err)
+		writeInternalError(w, http.StatusInternalServerError, corrID, "INTERNAL_ERROR", "failed to render PDF preview", true)
+		return
+	}
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", `inline; filename="preview.pdf"`)
+	w.Header().Set("Content-Length", strconv.Itoa(len(pdfBytes)))
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(pdfBytes)
+}
+
+// VerifyInvoicePDF matches GET /invoices/{id}/verify-pdf behind
+// auth.Middleware. It re-derives the content hash from the stored XML and
+// checks it against the hash embedded in the stored PDF and the meta record.
+func (s Service) VerifyInvoicePDF(w http.ResponseWriter, r *http.Request, id string) {
+	ctx, corrID, tenantID, ok := withActorContext(w, r)
+	if !ok {
+		return
+	}
+	logger := CorrelationLogger(s.logger, corrID, tenantID)
+
+	xmlBody, _, err := s.storage.GetObject(ctx, fmt.Sprintf("%s/invoices/%s/invoice.xml", tenantID, id))
+	if err != nil {
+		writeNotFound(w, corrID, "NOT_FOUND", "invoice not found")
+		return
+	}
+	pdfBody, _, err := s.storage.GetObject(ctx, fmt.Sprintf("%s/invoices/%s/invoice.pdf", tenantID, id))
+	if err != nil {
+		writeNotFound(w, corrID, "NOT_FOUND", "invoice PDF not found")
+		return
+	}
+
+	xmlHash := contentHash(xmlBody)
+	pdfHash, embedded := extractPDFContentHash(pdfBody)
+	if !embedded {
+		writeConflict(w, corrID, "HASH_NOT_EMBEDDED", "invoice PDF has no embedded content hash")
+		return
+	}
+	var metaHash string
+	if metaBody, _, err := s.storage.GetObject(ctx, fmt.Sprintf("%s/invoices/%s/meta.json", tenantID, id)); err == nil {
+		var meta invoiceMeta
+		if json.Unmarshal(metaBody, &meta) == nil {
+			metaHash = meta.ContentHash
+		}
+	}
+
+	valid := pdfHash == xmlHash && (metaHash == "" || metaHash == xmlHash)
+	if !valid {
+		logger.Warn("invoice pdf does not match xml", "invoiceId", id, "xmlHash", xmlHash, "pdfHash", pdfHash, "metaHash", metaHash)
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"invoiceId": id,
+		"valid":     valid,
+		"xmlHash":   xmlHash,
+		"pdfHash":   pdfHash,
+		"metaHash":  metaHash,
+	})
+}
+
+// GetInvoiceXML matches GET /invoices/{id}/xml. It streams the stored UBL
+// invoice through the API for integrations that would rather not follow a
+// signed URL.
+func (s Service) GetInvoiceXML(w http.ResponseWriter, r *http.Request, id string) {
+	s.serveInvoiceObject(w, r, id, "invoice.xml", "application/xml")
+}
+
+// GetInvoicePDF matches GET /invoices/{id}/pdf. It streams the stored invoice
+// PDF, or returns 503 when there is none because PDF rendering is disabled.
+func (s Service) GetInvoicePDF(w http.ResponseWriter, r *http.Request, id string) {
+	s.serveInvoiceObject(w, r, id, "invoice.pdf", "application/pdf")
+}
+
+// serveInvoiceObject writes the object name of invoice id. It must run behind
+// auth.Middleware: the key is built from the authenticated tenant, so another
+// tenant's invoice is simply not found.
+func (s Service) serveInvoiceObject(w http.ResponseWriter, r *http.Request, id, name, defaultType string) {
+	ctx, corrID, tenantID, ok := withActorContext(w, r)
+	if !ok {
+		return
+	}
+	logger := CorrelationLogger(s.logger, corrID, tenantID)
+	if _, err := uuid.Parse(id); err != nil {
+		writeValidationError(w, corrID, "BAD_REQUEST", "invalid invoice ID format", nil)
+		return
+	}
+
+	body, contentType, err := s.storage.GetObject(ctx, fmt.Sprintf("%s/invoices/%s/%s", tenantID, id, name))
+	switch {
+	case errors.Is(err, ErrObjectNotFound) && defaultType == "application/pdf" && !s.cfg.PDFEnabled:
+		writeInternalError(w, http.StatusServiceUnavailable, corrID, "PDF_DISABLED", "PDF rendering is disabled", false)
+		return
+	case errors.Is(err, ErrObjectNotFound):
+		writeNotFound(w, corrID, "NOT_FOUND", name+" not found")
+		return
+	case err != nil:
+		logger.Error("invoice object read failed", "invoiceId", id, "object", name, "error", err)
+		writeInternalError(w, http.StatusInternalServerError, corrID, "INTERNAL_ERROR", "storage error", true)
+		return
+	}
+	if contentType == "" {
+		contentType = defaultType
+	}
+
+	if err := s.appendAudit(ctx, tenantID, corrID, string(InvoiceGet)); err != nil {
+		logger.Warn("audit append failed", "error", err)
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`inline; filename="%s"`, name))
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(body)
+}
+
+// ErrBodyLengthMismatch is returned by decodeDraft when a non-chunked body
+// holds more or fewer bytes than its Content-Length declares.
+var ErrBodyLengthMismatch = errors.New("request body length does not match Content-Length")
+
+// ErrBodyTooLarge is returned by decodeDraft when the body exceeds
+// cfg.MaxRequestBytes.
+var ErrBodyTooLarge = errors.New("request body too large")
+
+// decodeDraft decodes the invoice draft, reading at most cfg.MaxRequestBytes.
+// With StrictBodyLength set, it drains the rest of a body with a declared
+// length, reading one byte past it, and reports any difference as
+// ErrBodyLengthMismatch ahead of JSON errors.
+func (s Service) decodeDraft(w http.ResponseWriter, r *http.Request) (InvoiceDraft, error) {
+defer r.Body.Close()
 var draft InvoiceDraft
-dec := json.NewDecoder(body)
-if err := dec.Decode(&draft); err != nil {
+if err := limitBody(w, r, s.cfg.MaxRequestBytes); err != nil {
+return draft, err
+}
+body := &countingReader{r: r.Body}
+err := json.NewDecoder(body).Decode(&draft)
+if isBodyTooLarge(err) {
+return draft, ErrBodyTooLarge
+}
+if s.cfg.StrictBodyLength && r.ContentLength >= 0 && len(r.TransferEncoding) == 0 {
+remaining := r.ContentLength - body.n
+if remaining < 0 {
+remaining = 0
+}
+if _, err := io.Copy(io.Discard, io.LimitReader(body, remaining+1)); isBodyTooLarge(err) {
+return draft, ErrBodyTooLarge
+}
+if body.n != r.ContentLength {
+return draft, ErrBodyLengthMismatch
+}
+}
+if err != nil {
 return draft, fmt.Errorf("invalid JSON: %w", err)
 }
 return draft, nil
 }
 
+// limitBody caps r.Body at limit bytes (limit <= 0 leaves it unbounded). A
+// declared Content-Length over the limit is rejected before any of it is read.
+func limitBody(w http.ResponseWriter, r *http.Request, limit int64) error {
+if limit <= 0 {
+return nil
+}
+if r.ContentLength > limit {
+return ErrBodyTooLarge
+}
+r.Body = http.MaxBytesReader(w, r.Body, limit)
+return nil
+}
+
+func isBodyTooLarge(err error) bool {
+var maxErr *http.MaxBytesError
+return errors.As(err, &maxErr)
+}
+
+func draftErrorCode(err error) string {
+if errors.Is(err, ErrBodyLengthMismatch) {
+return "BODY_LENGTH_MISMATCH"
+}
+return "BAD_REQUEST"
+}
+
+// writeDraftError answers a decodeDraft failure: 413 for an oversized body,
+// 400 otherwise.
+func (s Service) writeDraftError(w http.ResponseWriter, corrID string, err error) {
+if errors.Is(err, ErrBodyTooLarge) {
+writeBodyTooLarge(w, corrID, s.cfg.MaxRequestBytes)
+return
+}
+writeValidationError(w, corrID, draftErrorCode(err), err.Error(), nil)
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+r io.Reader
+n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+n, err := c.r.Read(p)
+c.n += int64(n)
+return n, err
+}
+
 func writeJSON(w http.ResponseWriter, status int, v any) {
 writeJSONStatus(w, status, v)
 }
@@ -204,17 +819,78 @@ w.WriteHeader(status)
 _ = json.NewEncoder(w).Encode(v)
 }
 
+// corrIDContextKey and tenantIDContextKey key the request IDs withRequestContext
+// stores; unexported types keep other packages from colliding with them.
+type corrIDContextKey struct{}
+
+type tenantIDContextKey struct{}
+
+// CorrIDFromContext returns the correlation ID stored by a pint handler.
+func CorrIDFromContext(ctx context.Context) (string, bool) {
+corrID, ok := ctx.Value(corrIDContextKey{}).(string)
+return corrID, ok
+}
+
+// TenantIDFromContext returns the tenant ID stored by a pint handler.
+func TenantIDFromContext(ctx context.Context) (string, bool) {
+tenantID, ok := ctx.Value(tenantIDContextKey{}).(string)
+return tenantID, ok
+}
+
+// withRequestContext reads the correlation and tenant IDs from the request
+// headers and returns a context carrying both.
 func withRequestContext(r *http.Request) (context.Context, string, string, error) {
 corr := r.Header.Get("X-Correlation-Id")
 tenant := r.Header.Get("X-Tenant-Id")
 if corr == "" || tenant == "" {
 return r.Context(), corr, tenant, errors.New("missing X-Correlation-Id or X-Tenant-Id")
 }
-ctx := context.WithValue(r.Context(), "corrId", corr)
-ctx = context.WithValue(ctx, "tenantId", tenant)
+ctx := context.WithValue(r.Context(), corrIDContextKey{}, corr)
+ctx = context.WithValue(ctx, tenantIDContextKey{}, tenant)
 return ctx, corr, tenant, nil
 }
 
+// withActorContext is withRequestContext for handlers behind auth.Middleware:
+// the tenant is the authenticated actor's, never a header, so one tenant
+// cannot reach another's invoices. It writes 401 and reports false when the
+// request carries no actor.
+func withActorContext(w http.ResponseWriter, r *http.Request) (context.Context, string, string, bool) {
+corrID := r.Header.Get("X-Correlation-Id")
+actor, ok := auth.ActorFromContext(r.Context())
+if !ok {
+writeJSON(w, http.StatusUnauthorized, ForbiddenError{Code: "AUTH_REQUIRED", Message: "authentication required", CorrId: corrID, Retryable: false})
+return r.Context(), corrID, "", false
+}
+ctx := context.WithValue(r.Context(), corrIDContextKey{}, corrID)
+ctx = context.WithValue(ctx, tenantIDContextKey{}, actor.TenantID)
+return ctx, corrID, actor.TenantID, true
+}
+
+// ExportAuditLog handles GET /audit/log?module=pint behind auth.Middleware:
+// the authenticated tenant's invoice audit chain oldest first, with Verified
+// reporting whether re-hashing every entry reproduces it.
+func (s Service) ExportAuditLog(w http.ResponseWriter, r *http.Request) {
+corrID := r.Header.Get("X-Correlation-Id")
+actor, ok := auth.ActorFromContext(r.Context())
+if !ok {
+writeJSON(w, http.StatusUnauthorized, ForbiddenError{Code: "AUTH_REQUIRED", Message: "authentication required", CorrId: corrID, Retryable: false})
+return
+}
+var entries []AuditLog
+if s.audit != nil {
+var err error
+if entries, err = s.audit.All(r.Context(), actor.TenantID); err != nil {
+writeInternalError(w, http.StatusInternalServerError, corrID, "INTERNAL_ERROR", "audit log unavailable", true)
+return
+}
+}
+export := VerifyChain(actor.TenantID, entries)
+if !export.Verified {
+CorrelationLogger(s.logger, corrID, actor.TenantID).Warn("audit chain broken", "brokenAt", *export.BrokenAt, "reason", export.Reason)
+}
+writeJSON(w, http.StatusOK, export)
+}
+
 func (s Service) appendAudit(ctx context.Context, tenantID, corrID, action string) error {
 if s.audit == nil {
 return nil
@@ -226,12 +902,14 @@ TenantID: tenantID,
 Actor:    "system",
 Action:   action,
 Ts:       time.Now().UTC(),
+HashAlg:  s.cfg.AuditHashAlgorithm,
 }
 _, err := HashChain(ctx, s.audit, tenantID, entry)
 return err
 }
 
 type MemoryAuditRecorder struct {
+mu       sync.RWMutex
 byTenant map[string][]AuditLog
 }
 
@@ -240,14 +918,24 @@ return &MemoryAuditRecorder{byTenant: map[string][]AuditLog{}}
 }
 
 func (m *MemoryAuditRecorder) Append(_ context.Context, entry AuditLog) error {
+m.mu.Lock()
+defer m.mu.Unlock()
 m.byTenant[entry.TenantID] = append(m.byTenant[entry.TenantID], entry)
 return nil
 }
 
 func (m *MemoryAuditRecorder) Last(_ context.Context, tenantID string) (AuditLog, error) {
+m.mu.RLock()
+defer m.mu.RUnlock()
 list := m.byTenant[tenantID]
 if len(list) == 0 {
 return AuditLog{}, fmt.Errorf("empty")
 }
 return list[len(list)-1], nil
 }
+
+func (m *MemoryAuditRecorder) All(_ context.Context, tenantID string) ([]AuditLog, error) {
+m.mu.RLock()
+defer m.mu.RUnlock()
+return append([]AuditLog(nil), m.byTenant[tenantID]...), nil
+}
@@ -0,0 +1,950 @@
+package pint
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yourorg/yourapp/apps/api/internal/auth"
+)
+
+// fakePDFRenderer produces a minimal PDF-like body without Chromium.
+type fakePDFRenderer struct{}
+
+func (fakePDFRenderer) Render(_ context.Context, draft InvoiceDraft, totals Totals, contentHash string) ([]byte, error) {
+	pdf := []byte(fmt.Sprintf("%%PDF-1.4\n%% %s %.0f\n%%%%EOF\n", draft.Customer.Name, totals.GrandTotal))
+	if contentHash != "" {
+		pdf = stampPDFContentHash(pdf, contentHash)
+	}
+	return pdf, nil
+}
+
+func newTestService(cfg Config) (Service, *InMemoryStorage) {
+	storage := NewInMemoryStorage()
+	svc := NewService(cfg, storage, NewMemoryAuditRecorder(), nil)
+	svc.pdf = fakePDFRenderer{}
+	return svc, storage
+}
+
+func newInvoiceRequest(method, target string, body []byte) *http.Request {
+	r := httptest.NewRequest(method, target, bytes.NewReader(body))
+	r.Header.Set("X-Correlation-Id", "corr-1")
+	r.Header.Set("X-Tenant-Id", "tenant-a")
+	return r
+}
+
+// asTenant returns r carrying an authenticated actor of tenantID with
+// invoice scopes, as auth.Middleware would attach it.
+func asTenant(r *http.Request, tenantID string) *http.Request {
+	actor := &auth.Actor{TenantID: tenantID, Scopes: []string{auth.Scopes.InvoiceRead, auth.Scopes.InvoiceWrite}}
+	return r.WithContext(auth.ContextWithActor(r.Context(), actor))
+}
+
+func issueInvoice(t *testing.T, svc Service, draft InvoiceDraft) string {
+	t.Helper()
+	body, _ := json.Marshal(draft)
+	w := httptest.NewRecorder()
+	svc.IssueInvoice(w, asTenant(newInvoiceRequest(http.MethodPost, "/invoices", body), "tenant-a"))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("issue: expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		InvoiceID string `json:"invoiceId"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode issue response: %v", err)
+	}
+	return resp.InvoiceID
+}
+
+func verifyPDF(t *testing.T, svc Service, id string) (int, map[string]any) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	svc.VerifyInvoicePDF(w, asTenant(newInvoiceRequest(http.MethodGet, "/invoices/"+id+"/verify-pdf", nil), "tenant-a"), id)
+	var resp map[string]any
+	_ = json.NewDecoder(w.Body).Decode(&resp)
+	return w.Code, resp
+}
+
+func TestVerifyInvoicePDF_Match(t *testing.T) {
+	cfg := LoadConfig()
+	cfg.PDFEnabled = true
+	cfg.PDFContentHash = true
+	svc, _ := newTestService(cfg)
+
+	id := issueInvoice(t, svc, sampleDraft())
+	code, resp := verifyPDF(t, svc, id)
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %v", code, resp)
+	}
+	if resp["valid"] != true {
+		t.Fatalf("expected matching PDF to verify, got %v", resp)
+	}
+	if resp["pdfHash"] != resp["xmlHash"] || resp["metaHash"] != resp["xmlHash"] {
+		t.Fatalf("expected all hashes to agree, got %v", resp)
+	}
+}
+
+func TestVerifyInvoicePDF_SwappedPDF(t *testing.T) {
+	cfg := LoadConfig()
+	cfg.PDFEnabled = true
+	cfg.PDFContentHash = true
+	svc, storage := newTestService(cfg)
+
+	id := issueInvoice(t, svc, sampleDraft())
+	other := sampleDraft()
+	other.Customer.Name = "Charlie"
+	otherID := issueInvoice(t, svc, other)
+
+	// Replace the first invoice's PDF with the one rendered from the other draft.
+	otherPDF, _, err := storage.GetObject(context.Background(), "tenant-a/invoices/"+otherID+"/invoice.pdf")
+	if err != nil {
+		t.Fatalf("get other pdf: %v", err)
+	}
+	if err := storage.PutObject(context.Background(), "tenant-a/invoices/"+id+"/invoice.pdf", otherPDF, "application/pdf"); err != nil {
+		t.Fatalf("put pdf: %v", err)
+	}
+
+	code, resp := verifyPDF(t, svc, id)
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %v", code, resp)
+	}
+	if resp["valid"] != false {
+		t.Fatalf("expected swapped PDF to be flagged, got %v", resp)
+	}
+}
+
+func TestVerifyInvoicePDF_HashDisabled(t *testing.T) {
+	cfg := LoadConfig()
+	cfg.PDFEnabled = true
+	cfg.PDFContentHash = false
+	svc, _ := newTestService(cfg)
+
+	id := issueInvoice(t, svc, sampleDraft())
+	if code, resp := verifyPDF(t, svc, id); code != http.StatusConflict {
+		t.Fatalf("expected 409 without an embedded hash, got %d: %v", code, resp)
+	}
+}
+
+// flushRecorder counts flushes and the body length seen at each one.
+type flushRecorder struct {
+	*httptest.ResponseRecorder
+	flushedAt []int
+}
+
+func (f *flushRecorder) Flush() {
+	f.flushedAt = append(f.flushedAt, f.Body.Len())
+}
+
+func TestValidateInvoiceStream_EmitsErrorsThenSummary(t *testing.T) {
+	cfg := LoadConfig()
+	cfg.ValidationStream = true
+	svc, _ := newTestService(cfg)
+
+	draft := sampleDraft()
+	var wantPaths []string
+	for i := 1; i < 200; i++ {
+		line := draft.Lines[0]
+		if i%25 == 0 {
+			line.Quantity = 0
+			wantPaths = append(wantPaths, fmt.Sprintf("lines[%d].quantity", i))
+		}
+		if i%40 == 0 {
+			line.TaxCategory = "X"
+			wantPaths = append(wantPaths, fmt.Sprintf("lines[%d].taxCategory", i))
+		}
+		draft.Lines = append(draft.Lines, line)
+	}
+	body, _ := json.Marshal(draft)
+
+	w := &flushRecorder{ResponseRecorder: httptest.NewRecorder()}
+	svc.ValidateInvoiceStream(w, newInvoiceRequest(http.MethodPost, "/invoices/validate/stream", body))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Fatalf("unexpected content type %q", ct)
+	}
+
+	lines := bytes.Split(bytes.TrimSpace(w.Body.Bytes()), []byte("\n"))
+	if len(lines) != len(wantPaths)+1 {
+		t.Fatalf("expected %d NDJSON lines, got %d", len(wantPaths)+1, len(lines))
+	}
+	// Each line is flushed on its own, so errors reach the client before the summary.
+	if len(w.flushedAt) != len(lines) {
+		t.Fatalf("expected %d flushes, got %d", len(lines), len(w.flushedAt))
+	}
+	for i, path := range wantPaths {
+		var ev validationStreamEvent
+		if err := json.Unmarshal(lines[i], &ev); err != nil {
+			t.Fatalf("line %d: %v", i, err)
+		}
+		if ev.Type != "error" || ev.Error == nil || ev.Error.Path != path {
+			t.Fatalf("line %d: expected error at %s, got %s", i, path, lines[i])
+		}
+	}
+
+	var summary validationStreamEvent
+	if err := json.Unmarshal(lines[len(lines)-1], &summary); err != nil {
+		t.Fatalf("summary: %v", err)
+	}
+	want := Validator{Config: cfg}.Validate(draft)
+	if summary.Type != "summary" || summary.Valid == nil || *summary.Valid {
+		t.Fatalf("expected invalid summary, got %s", lines[len(lines)-1])
+	}
+	if *summary.ErrorCount != len(wantPaths) {
+		t.Fatalf("expected errorCount %d, got %d", len(wantPaths), *summary.ErrorCount)
+	}
+	if summary.Totals.Subtotal != want.Totals.Subtotal || summary.Totals.GrandTotal != want.Totals.GrandTotal {
+		t.Fatalf("summary totals %+v, want %+v", *summary.Totals, want.Totals)
+	}
+}
+
+func TestValidateInvoiceStream_Disabled(t *testing.T) {
+	cfg := LoadConfig()
+	cfg.ValidationStream = false
+	svc, _ := newTestService(cfg)
+	body, _ := json.Marshal(sampleDraft())
+	w := httptest.NewRecorder()
+	svc.ValidateInvoiceStream(w, newInvoiceRequest(http.MethodPost, "/invoices/validate/stream", body))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 when disabled, got %d", w.Code)
+	}
+}
+
+func TestValidateInvoice_PlanMaxGrandTotal(t *testing.T) {
+	cfg := LoadConfig()
+	cfg.MaxGrandTotal = 1_000_000
+	cfg.PlanMaxGrandTotal = map[string]float64{"enterprise": 100_000_000}
+	cfg.TenantPlans = map[string]string{"tenant-a": "enterprise"}
+	cfg.ValidationCacheTTL = time.Minute
+	svc, _ := newTestService(cfg)
+
+	draft := sampleDraft()
+	draft.Lines[0].UnitPrice = 1_000_000
+	body, _ := json.Marshal(draft)
+
+	validate := func(tenantID string) bool {
+		r := newInvoiceRequest(http.MethodPost, "/invoices/validate", body)
+		r.Header.Set("X-Tenant-Id", tenantID)
+		w := httptest.NewRecorder()
+		svc.ValidateInvoice(w, r)
+		var resp struct {
+			Valid bool `json:"valid"`
+		}
+		_ = json.NewDecoder(w.Body).Decode(&resp)
+		return resp.Valid
+	}
+	if !validate("tenant-a") {
+		t.Fatal("expected enterprise tenant to pass under its raised cap")
+	}
+	if validate("tenant-b") {
+		t.Fatal("expected default-plan tenant to hit the grand total cap")
+	}
+}
+
+// flakyPDFRenderer fails its first failures calls, then renders like fakePDFRenderer.
+type flakyPDFRenderer struct {
+	mu       sync.Mutex
+	failures int
+	calls    int
+}
+
+func (f *flakyPDFRenderer) Render(ctx context.Context, draft InvoiceDraft, totals Totals, contentHash string) ([]byte, error) {
+	f.mu.Lock()
+	f.calls++
+	fail := f.calls <= f.failures
+	f.mu.Unlock()
+	if fail {
+		return nil, errors.New("chromium unavailable")
+	}
+	return fakePDFRenderer{}.Render(ctx, draft, totals, contentHash)
+}
+
+// issueInvoiceResponse issues draft and returns the decoded 201 body.
+func issueInvoiceResponse(t *testing.T, svc Service, draft InvoiceDraft) map[string]any {
+	t.Helper()
+	body, _ := json.Marshal(draft)
+	w := httptest.NewRecorder()
+	svc.IssueInvoice(w, asTenant(newInvoiceRequest(http.MethodPost, "/invoices", body), "tenant-a"))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("issue: expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp map[string]any
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode issue response: %v", err)
+	}
+	return resp
+}
+
+func getInvoice(t *testing.T, svc Service, id string) InvoiceRecord {
+	t.Helper()
+	w := httptest.NewRecorder()
+	svc.GetInvoice(w, asTenant(newInvoiceRequest(http.MethodGet, "/invoices/"+id, nil), "tenant-a"), id)
+	if w.Code != http.StatusOK {
+		t.Fatalf("get: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var record InvoiceRecord
+	if err := json.NewDecoder(w.Body).Decode(&record); err != nil {
+		t.Fatalf("decode invoice record: %v", err)
+	}
+	return record
+}
+
+func TestIssueInvoice_PDFStatus(t *testing.T) {
+	cfg := LoadConfig()
+	cfg.PDFEnabled = true
+	cfg.PDFRetryAttempts = 0
+	svc, _ := newTestService(cfg)
+
+	resp := issueInvoiceResponse(t, svc, sampleDraft())
+	if resp["pdfStatus"] != "rendered" || resp["pdfUrl"] == "" {
+		t.Fatalf("expected rendered PDF, got %v", resp)
+	}
+
+	svc.pdf = &flakyPDFRenderer{failures: 1}
+	resp = issueInvoiceResponse(t, svc, sampleDraft())
+	if resp["pdfStatus"] != "failed" || resp["pdfUrl"] != "" {
+		t.Fatalf("expected failed PDF without URL, got %v", resp)
+	}
+	if record := getInvoice(t, svc, resp["invoiceId"].(string)); record.PdfStatus == nil || *record.PdfStatus != InvoiceRecordPdfStatusFailed {
+		t.Fatalf("expected GetInvoice to report failed, got %v", record.PdfStatus)
+	}
+
+	cfg.PDFEnabled = false
+	svc, _ = newTestService(cfg)
+	resp = issueInvoiceResponse(t, svc, sampleDraft())
+	if resp["pdfStatus"] != "skipped" {
+		t.Fatalf("expected skipped PDF when disabled, got %v", resp)
+	}
+	if record := getInvoice(t, svc, resp["invoiceId"].(string)); *record.PdfStatus != InvoiceRecordPdfStatusSkipped {
+		t.Fatalf("expected GetInvoice to report skipped, got %v", *record.PdfStatus)
+	}
+}
+
+func TestIssueInvoice_PDFRetryEventuallyRenders(t *testing.T) {
+	cfg := LoadConfig()
+	cfg.PDFEnabled = true
+	cfg.PDFRetryAttempts = 3
+	cfg.PDFRetryDelay = time.Millisecond
+	svc, storage := newTestService(cfg)
+	renderer := &flakyPDFRenderer{failures: 2}
+	svc.pdf = renderer
+
+	resp := issueInvoiceResponse(t, svc, sampleDraft())
+	if resp["pdfStatus"] != "failed" {
+		t.Fatalf("expected failed PDF at issue time, got %v", resp)
+	}
+	id := resp["invoiceId"].(string)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		record := getInvoice(t, svc, id)
+		if *record.PdfStatus == InvoiceRecordPdfStatusRendered {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("PDF retry never rendered, status %s", *record.PdfStatus)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if _, _, err := storage.GetObject(context.Background(), pdfKey("tenant-a", id)); err != nil {
+		t.Fatalf("expected retried PDF to be stored: %v", err)
+	}
+	if code, verify := verifyPDF(t, svc, id); code != http.StatusOK || verify["valid"] != true {
+		t.Fatalf("expected retried PDF to verify, got %d %v", code, verify)
+	}
+}
+
+func TestValidateInvoice_ContentLengthMismatch(t *testing.T) {
+	body, _ := json.Marshal(sampleDraft())
+	validate := func(strict bool) (int, map[string]any) {
+		cfg := LoadConfig()
+		cfg.StrictBodyLength = strict
+		svc, _ := newTestService(cfg)
+		r := newInvoiceRequest(http.MethodPost, "/invoices/validate", body)
+		r.ContentLength = int64(len(body)) + 16 // declared length exceeds the body
+		w := httptest.NewRecorder()
+		svc.ValidateInvoice(w, r)
+		var resp map[string]any
+		_ = json.NewDecoder(w.Body).Decode(&resp)
+		return w.Code, resp
+	}
+
+	code, resp := validate(true)
+	if code != http.StatusBadRequest || resp["code"] != "BODY_LENGTH_MISMATCH" {
+		t.Fatalf("expected 400 BODY_LENGTH_MISMATCH, got %d %v", code, resp)
+	}
+	if code, _ := validate(false); code != http.StatusOK {
+		t.Fatalf("expected mismatch to be ignored when disabled, got %d", code)
+	}
+}
+
+func listInvoices(t *testing.T, svc Service, query string) InvoicePage {
+	t.Helper()
+	w := httptest.NewRecorder()
+	svc.ListInvoices(w, asTenant(newInvoiceRequest(http.MethodGet, "/invoices?"+query, nil), "tenant-a"))
+	if w.Code != http.StatusOK {
+		t.Fatalf("list: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var page InvoicePage
+	if err := json.NewDecoder(w.Body).Decode(&page); err != nil {
+		t.Fatalf("decode list response: %v", err)
+	}
+	return page
+}
+
+func TestListInvoices_PagesTenantInvoices(t *testing.T) {
+	svc, _ := newTestService(LoadConfig())
+
+	issued := map[string]bool{}
+	for i := 0; i < 5; i++ {
+		issued[issueInvoice(t, svc, sampleDraft())] = true
+	}
+	// Another tenant's invoice must not appear in tenant-a's list.
+	body, _ := json.Marshal(sampleDraft())
+	r := asTenant(newInvoiceRequest(http.MethodPost, "/invoices", body), "tenant-b")
+	w := httptest.NewRecorder()
+	svc.IssueInvoice(w, r)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("issue for tenant-b: expected 201, got %d", w.Code)
+	}
+
+	seen := map[string]bool{}
+	cursor, pages := "", 0
+	for {
+		page := listInvoices(t, svc, "limit=2&cursor="+cursor)
+		pages++
+		for _, inv := range page.Invoices {
+			if !issued[inv.InvoiceID] || seen[inv.InvoiceID] {
+				t.Fatalf("unexpected or repeated invoice %s", inv.InvoiceID)
+			}
+			seen[inv.InvoiceID] = true
+			if inv.Status != InvoiceRecordStatusIssued || inv.GrandTotal != 13200 || inv.XMLKey != "tenant-a/invoices/"+inv.InvoiceID+"/invoice.xml" {
+				t.Fatalf("unexpected record %+v", inv)
+			}
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		cursor = page.NextCursor
+	}
+	if pages != 3 || len(seen) != 5 {
+		t.Fatalf("expected 5 invoices over 3 pages, got %d over %d", len(seen), pages)
+	}
+
+	future := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	if page := listInvoices(t, svc, "from="+future); len(page.Invoices) != 0 {
+		t.Fatalf("expected no invoices from the future, got %d", len(page.Invoices))
+	}
+	if page := listInvoices(t, svc, "to="+future); len(page.Invoices) != 5 {
+		t.Fatalf("expected all 5 invoices before the future, got %d", len(page.Invoices))
+	}
+
+	w = httptest.NewRecorder()
+	svc.ListInvoices(w, asTenant(newInvoiceRequest(http.MethodGet, "/invoices?cursor=bogus!", nil), "tenant-a"))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for invalid cursor, got %d", w.Code)
+	}
+}
+
+// TestIssueInvoice_TenantComesFromActor tests that X-Tenant-Id cannot direct
+// an issuance into another tenant's listing or invoice numbers.
+func TestIssueInvoice_TenantComesFromActor(t *testing.T) {
+	svc, _ := newTestService(LoadConfig())
+	draft := sampleDraft()
+	number := "INV-2024-000001"
+	draft.InvoiceNumber = &number
+	body, _ := json.Marshal(draft)
+
+	// newInvoiceRequest names tenant-a in X-Tenant-Id; the actor is tenant-b.
+	w := httptest.NewRecorder()
+	svc.IssueInvoice(w, asTenant(newInvoiceRequest(http.MethodPost, "/invoices", body), "tenant-b"))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("issue as tenant-b: expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	if page := listInvoices(t, svc, ""); len(page.Invoices) != 0 {
+		t.Fatalf("expected tenant-b's invoice outside tenant-a's list, got %d", len(page.Invoices))
+	}
+	w = httptest.NewRecorder()
+	svc.IssueInvoice(w, asTenant(newInvoiceRequest(http.MethodPost, "/invoices", body), "tenant-a"))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected tenant-a's number %s to be free, got %d: %s", number, w.Code, w.Body.String())
+	}
+
+	for _, target := range []string{"/invoices", "/invoices/preview"} {
+		w := httptest.NewRecorder()
+		r := newInvoiceRequest(http.MethodPost, target, body)
+		if target == "/invoices" {
+			svc.IssueInvoice(w, r)
+		} else {
+			svc.PreviewInvoicePDF(w, r)
+		}
+		if w.Code != http.StatusUnauthorized {
+			t.Fatalf("POST %s with only X-Tenant-Id: expected 401, got %d", target, w.Code)
+		}
+	}
+}
+
+func voidInvoice(svc Service, id string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	svc.VoidInvoice(w, asTenant(newInvoiceRequest(http.MethodPost, "/invoices/"+id+"/void", nil), "tenant-a"), id)
+	return w
+}
+
+func TestVoidInvoice(t *testing.T) {
+	svc, _ := newTestService(LoadConfig())
+	id := issueInvoice(t, svc, sampleDraft())
+
+	w := voidInvoice(svc, id)
+	if w.Code != http.StatusOK {
+		t.Fatalf("void: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var voided InvoiceSummary
+	if err := json.NewDecoder(w.Body).Decode(&voided); err != nil {
+		t.Fatalf("decode void response: %v", err)
+	}
+	if voided.Status != InvoiceRecordStatusVoided || voided.VoidedAt == nil {
+		t.Fatalf("expected voided record with voidedAt, got %+v", voided)
+	}
+	if record := getInvoice(t, svc, id); record.Status != InvoiceRecordStatusVoided {
+		t.Fatalf("get after void: expected status voided, got %q", record.Status)
+	}
+
+	entries, _ := svc.audit.All(context.Background(), "tenant-a")
+	found := false
+	for _, entry := range entries {
+		if entry.Action == "invoice.void" {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("expected an invoice.void audit entry, got %+v", entries)
+	}
+
+	w = voidInvoice(svc, id)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("second void: expected 409, got %d: %s", w.Code, w.Body.String())
+	}
+	var conflict ConflictError
+	if err := json.NewDecoder(w.Body).Decode(&conflict); err != nil || conflict.Code != "ALREADY_VOIDED" {
+		t.Fatalf("expected ALREADY_VOIDED, got %+v (%v)", conflict, err)
+	}
+}
+
+func TestVoidInvoice_NotFound(t *testing.T) {
+	svc, _ := newTestService(LoadConfig())
+	id := uuid.NewString()
+	if w := voidInvoice(svc, id); w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// Another tenant cannot void tenant-a's invoice.
+	id = issueInvoice(t, svc, sampleDraft())
+	r := asTenant(newInvoiceRequest(http.MethodPost, "/invoices/"+id+"/void", nil), "tenant-b")
+	w := httptest.NewRecorder()
+	svc.VoidInvoice(w, r, id)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("cross-tenant void: expected 404, got %d", w.Code)
+	}
+
+	// The tenant header alone does not authenticate.
+	w = httptest.NewRecorder()
+	svc.VoidInvoice(w, newInvoiceRequest(http.MethodPost, "/invoices/"+id+"/void", nil), id)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("unauthenticated void: expected 401, got %d", w.Code)
+	}
+}
+
+// issueIdempotent posts draft with the given Idempotency-Key.
+func issueIdempotent(svc Service, key string, draft InvoiceDraft) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(draft)
+	r := asTenant(newInvoiceRequest(http.MethodPost, "/invoices", body), "tenant-a")
+	r.Header.Set("Idempotency-Key", key)
+	w := httptest.NewRecorder()
+	svc.IssueInvoice(w, r)
+	return w
+}
+
+func TestIssueInvoice_IdempotentReplay(t *testing.T) {
+	svc, _ := newTestService(LoadConfig())
+
+	first := issueIdempotent(svc, "key-1", sampleDraft())
+	if first.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", first.Code, first.Body.String())
+	}
+	replay := issueIdempotent(svc, "key-1", sampleDraft())
+	if replay.Code != http.StatusCreated || replay.Header().Get("Idempotent-Replayed") != "true" {
+		t.Fatalf("expected a replayed 201, got %d %v", replay.Code, replay.Header())
+	}
+	var original, replayed map[string]any
+	_ = json.Unmarshal(first.Body.Bytes(), &original)
+	_ = json.Unmarshal(replay.Body.Bytes(), &replayed)
+	if original["invoiceId"] == nil || replayed["invoiceId"] != original["invoiceId"] || replayed["invoiceNumber"] != original["invoiceNumber"] {
+		t.Fatalf("expected the original invoice on replay, got %v then %v", original, replayed)
+	}
+	if page := listInvoices(t, svc, ""); len(page.Invoices) != 1 {
+		t.Fatalf("expected a single issued invoice, got %d", len(page.Invoices))
+	}
+
+	// A fresh key issues a new invoice.
+	w := issueIdempotent(svc, "key-2", sampleDraft())
+	var fresh map[string]any
+	_ = json.Unmarshal(w.Body.Bytes(), &fresh)
+	if w.Code != http.StatusCreated || fresh["invoiceId"] == original["invoiceId"] {
+		t.Fatalf("expected a new invoice for a new key, got %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestIssueInvoice_IdempotencyKeyBodyMismatch(t *testing.T) {
+	svc, _ := newTestService(LoadConfig())
+	if w := issueIdempotent(svc, "key-1", sampleDraft()); w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+
+	changed := sampleDraft()
+	changed.Lines[0].Quantity = 11
+	w := issueIdempotent(svc, "key-1", changed)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for a changed draft, got %d: %s", w.Code, w.Body.String())
+	}
+	var body ConflictError
+	_ = json.NewDecoder(w.Body).Decode(&body)
+	if body.ConflictReason == nil || *body.ConflictReason != IdempotencyBodyMismatch || body.CorrId != "corr-1" || body.Retryable {
+		t.Fatalf("expected a non-retryable idempotency_body_mismatch conflict, got %+v", body)
+	}
+	if page := listInvoices(t, svc, ""); len(page.Invoices) != 1 {
+		t.Fatalf("expected the conflicting draft not to be issued, got %d invoices", len(page.Invoices))
+	}
+}
+
+func TestWithRequestContext_TypedKeys(t *testing.T) {
+	ctx, corrID, tenantID, err := withRequestContext(newInvoiceRequest(http.MethodGet, "/invoices", nil))
+	if err != nil {
+		t.Fatalf("withRequestContext: %v", err)
+	}
+	if got, ok := CorrIDFromContext(ctx); !ok || got != corrID || got != "corr-1" {
+		t.Fatalf("expected corr-1 from context, got %q (ok=%v)", got, ok)
+	}
+	if got, ok := TenantIDFromContext(ctx); !ok || got != tenantID || got != "tenant-a" {
+		t.Fatalf("expected tenant-a from context, got %q (ok=%v)", got, ok)
+	}
+	if ctx.Value("corrId") != nil || ctx.Value("tenantId") != nil {
+		t.Fatal("expected string-keyed lookups to find nothing")
+	}
+	if _, ok := TenantIDFromContext(context.Background()); ok {
+		t.Fatal("expected no tenant in a bare context")
+	}
+}
+
+func validateBatch(t *testing.T, svc Service, drafts []InvoiceDraft) (int, batchValidationResponse) {
+	t.Helper()
+	body, _ := json.Marshal(batchValidationRequest{Drafts: drafts})
+	w := httptest.NewRecorder()
+	svc.ValidateInvoiceBatch(w, newInvoiceRequest(http.MethodPost, "/invoices/validate-batch", body))
+	var resp batchValidationResponse
+	if w.Code == http.StatusOK {
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("decode batch response: %v", err)
+		}
+	}
+	return w.Code, resp
+}
+
+func TestValidateInvoiceBatch_MixedBatch(t *testing.T) {
+	svc, _ := newTestService(LoadConfig())
+	invalid := sampleDraft()
+	invalid.Lines[0].UnitCode = "ZZZ"
+
+	code, resp := validateBatch(t, svc, []InvoiceDraft{sampleDraft(), invalid, sampleDraft()})
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if resp.AllValid || len(resp.Results) != 3 {
+		t.Fatalf("expected 3 results and allValid=false, got %+v", resp)
+	}
+	if !resp.Results[0].Valid || resp.Results[1].Valid || !resp.Results[2].Valid {
+		t.Fatalf("expected only the second draft to fail, got %+v", resp.Results)
+	}
+	if len(resp.Results[1].Errors) == 0 || resp.Results[1].Errors[0].Code != "JP-PINT-CODE-001" {
+		t.Fatalf("expected a unit code error, got %+v", resp.Results[1].Errors)
+	}
+
+	if _, resp := validateBatch(t, svc, []InvoiceDraft{sampleDraft(), sampleDraft()}); !resp.AllValid {
+		t.Fatalf("expected allValid for a clean batch, got %+v", resp)
+	}
+}
+
+func TestValidateInvoiceBatch_TooLarge(t *testing.T) {
+	cfg := LoadConfig()
+	cfg.MaxBatchSize = 2
+	svc, _ := newTestService(cfg)
+	if code, _ := validateBatch(t, svc, []InvoiceDraft{sampleDraft(), sampleDraft(), sampleDraft()}); code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413, got %d", code)
+	}
+}
+
+func TestValidateInvoiceBatch_PreservesOrder(t *testing.T) {
+	cfg := LoadConfig()
+	cfg.MaxParallelJobs = 4
+	svc, _ := newTestService(cfg)
+
+	drafts := make([]InvoiceDraft, 50)
+	for i := range drafts {
+		drafts[i] = sampleDraft()
+		drafts[i].Lines[0].Quantity = 1
+		drafts[i].Lines[0].UnitPrice = float64(100 * (i + 1))
+	}
+	code, resp := validateBatch(t, svc, drafts)
+	if code != http.StatusOK || len(resp.Results) != len(drafts) {
+		t.Fatalf("expected %d results, got %d (status %d)", len(drafts), len(resp.Results), code)
+	}
+	for i, result := range resp.Results {
+		if want := float64(100 * (i + 1)); result.Totals.Subtotal != want {
+			t.Fatalf("result %d: expected subtotal %v, got %v", i, want, result.Totals.Subtotal)
+		}
+	}
+}
+
+func previewInvoice(svc Service, draft InvoiceDraft) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(draft)
+	w := httptest.NewRecorder()
+	svc.PreviewInvoicePDF(w, asTenant(newInvoiceRequest(http.MethodPost, "/invoices/preview", body), "tenant-a"))
+	return w
+}
+
+func TestPreviewInvoicePDF_RendersWithoutPersisting(t *testing.T) {
+	cfg := LoadConfig()
+	cfg.PDFEnabled = true
+	svc, storage := newTestService(cfg)
+
+	w := previewInvoice(svc, sampleDraft())
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("Content-Type"); got != "application/pdf" {
+		t.Fatalf("expected application/pdf, got %q", got)
+	}
+	if !bytes.HasPrefix(w.Body.Bytes(), []byte("%PDF-")) {
+		t.Fatalf("expected PDF magic bytes, got %q", w.Body.String())
+	}
+	if len(storage.data) != 0 {
+		t.Fatalf("expected nothing stored, got %d objects", len(storage.data))
+	}
+	if entries, _ := svc.audit.All(context.Background(), "tenant-a"); len(entries) != 0 {
+		t.Fatalf("expected no audit entries, got %+v", entries)
+	}
+	if page := listInvoices(t, svc, ""); len(page.Invoices) != 0 {
+		t.Fatalf("expected no issued invoices, got %d", len(page.Invoices))
+	}
+}
+
+func TestPreviewInvoicePDF_InvalidDraft(t *testing.T) {
+	cfg := LoadConfig()
+	cfg.PDFEnabled = true
+	svc, _ := newTestService(cfg)
+	draft := sampleDraft()
+	draft.Lines = nil
+
+	w := previewInvoice(svc, draft)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", w.Code)
+	}
+	var body ValidationErrorResponse
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil || body.Code != "VALIDATION_ERROR" || len(body.Errors) == 0 {
+		t.Fatalf("expected validation errors, got %+v (%v)", body, err)
+	}
+}
+
+func TestPreviewInvoicePDF_Disabled(t *testing.T) {
+	cfg := LoadConfig()
+	cfg.PDFEnabled = false
+	svc, _ := newTestService(cfg)
+
+	w := previewInvoice(svc, sampleDraft())
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 with PDFs disabled, got %d", w.Code)
+	}
+	var body InternalError
+	_ = json.NewDecoder(w.Body).Decode(&body)
+	if body.Code != "PDF_DISABLED" || body.CorrId != "corr-1" {
+		t.Fatalf("unexpected error body %+v", body)
+	}
+}
+
+// fetchInvoiceObject fetches the xml or pdf of invoice id as tenant-a.
+func fetchInvoiceObject(svc Service, id, kind string) *httptest.ResponseRecorder {
+	return fetchInvoiceObjectAs(svc, "tenant-a", id, kind)
+}
+
+// fetchInvoiceObjectAs fetches the xml or pdf of invoice id as an actor of
+// tenantID, or unauthenticated when tenantID is empty.
+func fetchInvoiceObjectAs(svc Service, tenantID, id, kind string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/invoices/"+id+"/"+kind, nil)
+	r.Header.Set("X-Correlation-Id", "corr-1")
+	if tenantID != "" {
+		r = r.WithContext(auth.ContextWithActor(r.Context(), &auth.Actor{TenantID: tenantID, Scopes: []string{auth.Scopes.InvoiceRead}}))
+	}
+	if kind == "xml" {
+		svc.GetInvoiceXML(w, r, id)
+	} else {
+		svc.GetInvoicePDF(w, r, id)
+	}
+	return w
+}
+
+func TestGetInvoiceXML_ReturnsUBLInvoice(t *testing.T) {
+	svc, _ := newTestService(LoadConfig())
+	resp := issueInvoiceResponse(t, svc, sampleDraft())
+	id := resp["invoiceId"].(string)
+
+	w := fetchInvoiceObject(svc, id, "xml")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.Contains(ct, "xml") {
+		t.Fatalf("expected an XML content type, got %q", ct)
+	}
+	var doc struct {
+		XMLName xml.Name
+		ID      string `xml:"ID"`
+	}
+	if err := xml.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("parse UBL: %v", err)
+	}
+	if doc.XMLName.Local != "Invoice" || doc.ID != resp["invoiceNumber"] {
+		t.Fatalf("expected UBL Invoice %v, got %s %q", resp["invoiceNumber"], doc.XMLName.Local, doc.ID)
+	}
+
+	w = fetchInvoiceObject(svc, id, "pdf")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/pdf" {
+		t.Fatalf("expected the PDF, got %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+
+	// Another tenant cannot read the invoice through its own key space, and
+	// a tenant header without an authenticated actor is not enough.
+	if w := fetchInvoiceObjectAs(svc, "tenant-b", id, "xml"); w.Code != http.StatusNotFound {
+		t.Fatalf("cross-tenant fetch: expected 404, got %d", w.Code)
+	}
+	r := newInvoiceRequest(http.MethodGet, "/invoices/"+id+"/xml", nil)
+	w = httptest.NewRecorder()
+	svc.GetInvoiceXML(w, r, id)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("unauthenticated fetch: expected 401, got %d", w.Code)
+	}
+}
+
+// failingGetStorage fails every GetObject with a transient error.
+type failingGetStorage struct {
+	*InMemoryStorage
+}
+
+func (s failingGetStorage) GetObject(context.Context, string) ([]byte, string, error) {
+	return nil, "", errors.New("connection reset")
+}
+
+func TestGetInvoiceObject_StorageErrorIsInternal(t *testing.T) {
+	cfg := LoadConfig()
+	cfg.PDFEnabled = false
+	svc, storage := newTestService(cfg)
+	svc.storage = failingGetStorage{storage}
+
+	for _, kind := range []string{"xml", "pdf"} {
+		w := fetchInvoiceObject(svc, uuid.NewString(), kind)
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("%s: expected 500 for a storage failure, got %d", kind, w.Code)
+		}
+	}
+}
+
+func TestGetInvoicePDF_MissingObject(t *testing.T) {
+	svc, _ := newTestService(LoadConfig())
+	if w := fetchInvoiceObject(svc, uuid.NewString(), "pdf"); w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown invoice, got %d", w.Code)
+	}
+
+	cfg := LoadConfig()
+	cfg.PDFEnabled = false
+	svc, _ = newTestService(cfg)
+	id := issueInvoice(t, svc, sampleDraft())
+	if w := fetchInvoiceObject(svc, id, "pdf"); w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 with PDFs disabled, got %d", w.Code)
+	}
+	if w := fetchInvoiceObject(svc, id, "xml"); w.Code != http.StatusOK {
+		t.Fatalf("expected the XML regardless of PDFEnabled, got %d", w.Code)
+	}
+}
+
+// meteredBody is a request body that counts how many bytes the handler reads.
+type meteredBody struct {
+	r    io.Reader
+	read int64
+}
+
+func (b *meteredBody) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	b.read += int64(n)
+	return n, err
+}
+
+func TestDecodeDraft_BodyTooLarge(t *testing.T) {
+	cfg := LoadConfig()
+	cfg.MaxRequestBytes = 1024
+	svc, _ := newTestService(cfg)
+
+	tests := []struct {
+		name          string
+		size          int
+		contentLength bool
+		maxRead       int64
+	}{
+		// A declared length over the limit is refused without reading.
+		{name: "content-length one byte over", size: 1025, contentLength: true, maxRead: 0},
+		// A chunked body is cut off just past the limit.
+		{name: "chunked", size: 1 << 20, contentLength: false, maxRead: 1025},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prefix := `{"notes":"`
+			body := &meteredBody{r: strings.NewReader(prefix + strings.Repeat("a", tt.size-len(prefix)))}
+			r := newInvoiceRequest(http.MethodPost, "/invoices/validate", nil)
+			r.Body = io.NopCloser(body)
+			r.ContentLength = -1
+			if tt.contentLength {
+				r.ContentLength = int64(tt.size)
+			}
+			w := httptest.NewRecorder()
+			svc.ValidateInvoice(w, r)
+			if w.Code != http.StatusRequestEntityTooLarge {
+				t.Fatalf("expected 413, got %d: %s", w.Code, w.Body.String())
+			}
+			var resp RequestTooLargeError
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil || resp.Code != "BODY_TOO_LARGE" || resp.CorrId != "corr-1" {
+				t.Fatalf("expected a BODY_TOO_LARGE envelope, got %+v (%v)", resp, err)
+			}
+			if body.read > tt.maxRead {
+				t.Fatalf("read %d bytes of the body, want at most %d", body.read, tt.maxRead)
+			}
+		})
+	}
+
+	// A draft within the limit is still decoded.
+	body, _ := json.Marshal(sampleDraft())
+	w := httptest.NewRecorder()
+	svc.ValidateInvoice(w, newInvoiceRequest(http.MethodPost, "/invoices/validate", body))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 within the limit, got %d", w.Code)
+	}
+}
@@ -0,0 +1,107 @@
+package pint
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+)
+
+// MaxIdempotencyKeyLength bounds the Idempotency-Key header on POST /invoices.
+const MaxIdempotencyKeyLength = 128
+
+// Idempotency errors returned by IdempotencyStore.Begin.
+var (
+	// ErrIdempotencyBodyMismatch reports a key already used with another draft.
+	ErrIdempotencyBodyMismatch = errors.New("idempotency key already used with a different draft")
+	// ErrIdempotencyInFlight reports a key whose original request is still issuing.
+	ErrIdempotencyInFlight = errors.New("idempotency key is still in progress")
+)
+
+// IssuedResponse is the stored outcome of an idempotent IssueInvoice.
+type IssuedResponse struct {
+	Status int
+	Body   []byte
+}
+
+// IdempotencyStore remembers IssueInvoice responses per (tenant, key) so a
+// client retry replays the original invoice instead of issuing a second one.
+// Begin reserves a key for a draft hash: it returns the stored response of a
+// completed replay, ErrIdempotencyBodyMismatch for a different draft and
+// ErrIdempotencyInFlight while the original has not finished. The caller then
+// either records the response with Complete or frees the key with Abort.
+type IdempotencyStore interface {
+	Begin(ctx context.Context, tenantID, key, draftHash string) (*IssuedResponse, error)
+	Complete(ctx context.Context, tenantID, key string, resp IssuedResponse) error
+	Abort(ctx context.Context, tenantID, key string) error
+}
+
+type idempotencyEntry struct {
+	draftHash string
+	resp      *IssuedResponse
+	expiresAt time.Time
+}
+
+// InMemoryIdempotencyStore is the default IdempotencyStore for local
+// development. Completed keys are forgotten after ttl; a ttl <= 0 keeps them
+// for the life of the process.
+type InMemoryIdempotencyStore struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[string]idempotencyEntry
+}
+
+func NewInMemoryIdempotencyStore(ttl time.Duration) *InMemoryIdempotencyStore {
+	return &InMemoryIdempotencyStore{ttl: ttl, entries: map[string]idempotencyEntry{}}
+}
+
+func (s *InMemoryIdempotencyStore) Begin(ctx context.Context, tenantID, key, draftHash string) (*IssuedResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := tenantID + ":" + key
+	entry, ok := s.entries[id]
+	if ok && entry.resp != nil && s.ttl > 0 && time.Now().After(entry.expiresAt) {
+		ok = false
+	}
+	switch {
+	case !ok:
+		s.entries[id] = idempotencyEntry{draftHash: draftHash}
+		return nil, ctx.Err()
+	case entry.draftHash != draftHash:
+		return nil, ErrIdempotencyBodyMismatch
+	case entry.resp == nil:
+		return nil, ErrIdempotencyInFlight
+	}
+	resp := *entry.resp
+	return &resp, nil
+}
+
+func (s *InMemoryIdempotencyStore) Complete(ctx context.Context, tenantID, key string, resp IssuedResponse) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := tenantID + ":" + key
+	entry := s.entries[id]
+	entry.resp = &resp
+	entry.expiresAt = time.Now().Add(s.ttl)
+	s.entries[id] = entry
+	return ctx.Err()
+}
+
+func (s *InMemoryIdempotencyStore) Abort(ctx context.Context, tenantID, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, tenantID+":"+key)
+	return ctx.Err()
+}
+
+// draftHash fingerprints a decoded draft, so replays that differ only in JSON
+// formatting or field order still match.
+func draftHash(draft InvoiceDraft) string {
+	b, _ := json.Marshal(draft)
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,204 @@
+package pint
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultListInvoicesLimit = 50
+	MaxListInvoicesLimit     = 200
+)
+
+// ErrInvoiceNotFound is returned by InvoiceStore.Get for an unknown invoice.
+var ErrInvoiceNotFound = errors.New("invoice not found")
+
+// ErrInvoiceAlreadyVoided is returned by InvoiceStore.Void for an invoice
+// that has already been voided.
+var ErrInvoiceAlreadyVoided = errors.New("invoice already voided")
+
+// ErrInvalidCursor is returned when a ListByTenant cursor cannot be decoded.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// InvoiceSummary is the indexed record of an issued invoice. The XML and PDF
+// themselves stay in Storage under XMLKey and PDFKey; SignedXMLKey is set
+// only when signing is configured.
+type InvoiceSummary struct {
+	InvoiceID     string              `json:"invoiceId"`
+	InvoiceNumber string              `json:"invoiceNumber"`
+	Status        InvoiceRecordStatus `json:"status"`
+	CreatedAt     time.Time           `json:"createdAt"`
+	GrandTotal    float64             `json:"grandTotal"`
+	Currency      string              `json:"currency"`
+	XMLKey        string              `json:"xmlKey"`
+	SignedXMLKey  string              `json:"signedXmlKey,omitempty"`
+	PDFKey        string              `json:"pdfKey,omitempty"`
+	VoidedAt      *time.Time          `json:"voidedAt,omitempty"`
+}
+
+// ListInvoicesOptions filters and pages ListByTenant. From is inclusive and To
+// exclusive on CreatedAt; a zero bound is open.
+type ListInvoicesOptions struct {
+	Limit  int
+	Cursor string
+	From   time.Time
+	To     time.Time
+}
+
+// InvoicePage is one page of a tenant's invoices, newest first. NextCursor is empty on the last page.
+type InvoicePage struct {
+	Invoices   []InvoiceSummary `json:"invoices"`
+	NextCursor string           `json:"nextCursor,omitempty"`
+}
+
+// InvoiceStore indexes issued invoices per tenant so they can be listed and
+// looked up without knowing their storage keys.
+type InvoiceStore interface {
+	Save(ctx context.Context, tenantID string, invoice InvoiceSummary) error
+	Get(ctx context.Context, tenantID, invoiceID string) (InvoiceSummary, error)
+	ListByTenant(ctx context.Context, tenantID string, opts ListInvoicesOptions) (InvoicePage, error)
+	// Void marks the invoice voided and returns the updated record. It
+	// returns ErrInvoiceNotFound or ErrInvoiceAlreadyVoided without changes.
+	Void(ctx context.Context, tenantID, invoiceID string) (InvoiceSummary, error)
+}
+
+// InMemoryInvoiceStore is the default InvoiceStore for local development.
+type InMemoryInvoiceStore struct {
+	mu       sync.RWMutex
+	byTenant map[string]map[string]InvoiceSummary
+}
+
+func NewInMemoryInvoiceStore() *InMemoryInvoiceStore {
+	return &InMemoryInvoiceStore{byTenant: map[string]map[string]InvoiceSummary{}}
+}
+
+func (s *InMemoryInvoiceStore) Save(ctx context.Context, tenantID string, invoice InvoiceSummary) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.byTenant[tenantID] == nil {
+		s.byTenant[tenantID] = map[string]InvoiceSummary{}
+	}
+	s.byTenant[tenantID][invoice.InvoiceID] = invoice
+	return ctx.Err()
+}
+
+func (s *InMemoryInvoiceStore) Get(_ context.Context, tenantID, invoiceID string) (InvoiceSummary, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	invoice, ok := s.byTenant[tenantID][invoiceID]
+	if !ok {
+		return InvoiceSummary{}, ErrInvoiceNotFound
+	}
+	return invoice, nil
+}
+
+func (s *InMemoryInvoiceStore) Void(_ context.Context, tenantID, invoiceID string) (InvoiceSummary, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	invoice, ok := s.byTenant[tenantID][invoiceID]
+	if !ok {
+		return InvoiceSummary{}, ErrInvoiceNotFound
+	}
+	if invoice.Status == InvoiceRecordStatusVoided {
+		return invoice, ErrInvoiceAlreadyVoided
+	}
+	invoice.Status = InvoiceRecordStatusVoided
+	now := time.Now().UTC()
+	invoice.VoidedAt = &now
+	s.byTenant[tenantID][invoiceID] = invoice
+	return invoice, nil
+}
+
+// ListByTenant returns tenantID's invoices newest first (ties broken by
+// invoice id) within the options' creation-time range.
+func (s *InMemoryInvoiceStore) ListByTenant(_ context.Context, tenantID string, opts ListInvoicesOptions) (InvoicePage, error) {
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = DefaultListInvoicesLimit
+	}
+	if limit > MaxListInvoicesLimit {
+		limit = MaxListInvoicesLimit
+	}
+	var after *invoiceCursor
+	if opts.Cursor != "" {
+		c, err := decodeInvoiceCursor(opts.Cursor)
+		if err != nil {
+			return InvoicePage{}, err
+		}
+		after = &c
+	}
+
+	s.mu.RLock()
+	invoices := make([]InvoiceSummary, 0, len(s.byTenant[tenantID]))
+	for _, invoice := range s.byTenant[tenantID] {
+		if !opts.From.IsZero() && invoice.CreatedAt.Before(opts.From) {
+			continue
+		}
+		if !opts.To.IsZero() && !invoice.CreatedAt.Before(opts.To) {
+			continue
+		}
+		invoices = append(invoices, invoice)
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(invoices, func(i, j int) bool {
+		return cursorForInvoice(invoices[i]).before(cursorForInvoice(invoices[j]))
+	})
+	page := InvoicePage{Invoices: make([]InvoiceSummary, 0, limit)}
+	for _, invoice := range invoices {
+		if after != nil && !after.before(cursorForInvoice(invoice)) {
+			continue
+		}
+		if len(page.Invoices) == limit {
+			page.NextCursor = cursorForInvoice(page.Invoices[limit-1]).encode()
+			break
+		}
+		page.Invoices = append(page.Invoices, invoice)
+	}
+	return page, nil
+}
+
+// invoiceCursor is the (CreatedAt, InvoiceID) position of the last invoice on a page.
+type invoiceCursor struct {
+	createdAt int64
+	invoiceID string
+}
+
+func cursorForInvoice(invoice InvoiceSummary) invoiceCursor {
+	return invoiceCursor{createdAt: invoice.CreatedAt.UnixNano(), invoiceID: invoice.InvoiceID}
+}
+
+// before reports whether c sorts ahead of other in newest-first order.
+func (c invoiceCursor) before(other invoiceCursor) bool {
+	if c.createdAt != other.createdAt {
+		return c.createdAt > other.createdAt
+	}
+	return c.invoiceID > other.invoiceID
+}
+
+func (c invoiceCursor) encode() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d|%s", c.createdAt, c.invoiceID)))
+}
+
+func decodeInvoiceCursor(s string) (invoiceCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return invoiceCursor{}, ErrInvalidCursor
+	}
+	nanos, id, ok := strings.Cut(string(raw), "|")
+	if !ok || id == "" {
+		return invoiceCursor{}, ErrInvalidCursor
+	}
+	n, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return invoiceCursor{}, ErrInvalidCursor
+	}
+	return invoiceCursor{createdAt: n, invoiceID: id}, nil
+}
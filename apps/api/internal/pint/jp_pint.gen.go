@@ -25,9 +25,22 @@ const (
 	InvoiceValidate AuditEntryAction = "invoice.validate"
 )
 
-// Defines values for InvoiceDraftCurrency.
+// Defines values for BankAccountAccountType.
 const (
-	JPY InvoiceDraftCurrency = "JPY"
+	Checking BankAccountAccountType = "checking"
+	Ordinary BankAccountAccountType = "ordinary"
+)
+
+// Defines values for ConflictErrorConflictReason.
+const (
+	IdempotencyBodyMismatch ConflictErrorConflictReason = "idempotency_body_mismatch"
+	IdempotencyReplay       ConflictErrorConflictReason = "idempotency_replay"
+)
+
+// Defines values for InvoiceDraftDocumentType.
+const (
+	CreditNote InvoiceDraftDocumentType = "creditNote"
+	Invoice    InvoiceDraftDocumentType = "invoice"
 )
 
 // Defines values for InvoiceIssuedStatus.
@@ -37,11 +50,26 @@ const (
 	InvoiceIssuedStatusIssued InvoiceIssuedStatus = "issued"
 )
 
+// Defines values for InvoiceIssuedPdfStatus.
+const (
+	InvoiceIssuedPdfStatusFailed   InvoiceIssuedPdfStatus = "failed"
+	InvoiceIssuedPdfStatusRendered InvoiceIssuedPdfStatus = "rendered"
+	InvoiceIssuedPdfStatusSkipped  InvoiceIssuedPdfStatus = "skipped"
+)
+
 // Defines values for InvoiceRecordStatus.
 const (
 	InvoiceRecordStatusDraft  InvoiceRecordStatus = "draft"
 	InvoiceRecordStatusFailed InvoiceRecordStatus = "failed"
 	InvoiceRecordStatusIssued InvoiceRecordStatus = "issued"
+	InvoiceRecordStatusVoided InvoiceRecordStatus = "voided"
+)
+
+// Defines values for InvoiceRecordPdfStatus.
+const (
+	InvoiceRecordPdfStatusFailed   InvoiceRecordPdfStatus = "failed"
+	InvoiceRecordPdfStatusRendered InvoiceRecordPdfStatus = "rendered"
+	InvoiceRecordPdfStatusSkipped  InvoiceRecordPdfStatus = "skipped"
 )
 
 // Defines values for LineItemTaxCategory.
@@ -76,6 +104,19 @@ const (
 	Warning ValidationErrorItemSeverity = "warning"
 )
 
+// AllowanceCharge defines model for AllowanceCharge.
+type AllowanceCharge struct {
+	Amount float64 `json:"amount"`
+
+	// ChargeIndicator true for a charge (surcharge), false for an allowance (discount)
+	ChargeIndicator bool   `json:"chargeIndicator"`
+	Reason          string `json:"reason"`
+
+	// TaxCategory JP PINT tax category code; when omitted the amount carries no tax
+	TaxCategory *string  `json:"taxCategory,omitempty"`
+	TaxRate     *float64 `json:"taxRate,omitempty"`
+}
+
 // AuditEntry defines model for AuditEntry.
 type AuditEntry struct {
 	Action    AuditEntryAction   `json:"action"`
@@ -91,72 +132,146 @@ type AuditEntry struct {
 // AuditEntryAction defines model for AuditEntry.Action.
 type AuditEntryAction string
 
+// BankAccount Payee account; either an IBAN or a Japanese domestic account (bank, branch and account number)
+type BankAccount struct {
+	// AccountHolder Account holder name as registered with the bank (口座名義)
+	AccountHolder *string `json:"accountHolder,omitempty"`
+	AccountNumber *string `json:"accountNumber,omitempty"`
+
+	// AccountType Japanese deposit type, 普通 (ordinary) or 当座 (checking)
+	AccountType *BankAccountAccountType `json:"accountType,omitempty"`
+	BankName    *string                 `json:"bankName,omitempty"`
+	BranchName  *string                 `json:"branchName,omitempty"`
+	Iban        *string                 `json:"iban,omitempty"`
+}
+
+// BankAccountAccountType Japanese deposit type, 普通 (ordinary) or 当座 (checking)
+type BankAccountAccountType string
+
 // ConflictError defines model for ConflictError.
 type ConflictError struct {
-	Code    string `json:"code"`
-	Message string `json:"message"`
+	Code           string                       `json:"code"`
+	ConflictReason *ConflictErrorConflictReason `json:"conflictReason,omitempty"`
+	CorrId         string                       `json:"corrId"`
+	Message        string                       `json:"message"`
+	Retryable      bool                         `json:"retryable"`
 }
 
+// ConflictErrorConflictReason defines model for ConflictError.ConflictReason.
+type ConflictErrorConflictReason string
+
 // ForbiddenError defines model for ForbiddenError.
 type ForbiddenError struct {
-	Code    string `json:"code"`
-	Message string `json:"message"`
+	Code      string `json:"code"`
+	CorrId    string `json:"corrId"`
+	Message   string `json:"message"`
+	Retryable bool   `json:"retryable"`
 }
 
 // InternalError defines model for InternalError.
 type InternalError struct {
 	Code      string `json:"code"`
+	CorrId    string `json:"corrId"`
 	Message   string `json:"message"`
 	Retryable bool   `json:"retryable"`
 }
 
 // InvoiceDraft defines model for InvoiceDraft.
 type InvoiceDraft struct {
-	Currency      InvoiceDraftCurrency `json:"currency"`
-	Customer      Party                `json:"customer"`
-	DueDate       openapi_types.Date   `json:"dueDate"`
-	InvoiceNumber *string              `json:"invoiceNumber,omitempty"`
-	IssueDate     openapi_types.Date   `json:"issueDate"`
-	Lines         []LineItem           `json:"lines"`
-	Notes         *string              `json:"notes,omitempty"`
-	Supplier      Party                `json:"supplier"`
+	// AllowanceCharges Document-level allowances (discounts) and charges (surcharges)
+	AllowanceCharges *[]AllowanceCharge `json:"allowanceCharges,omitempty"`
+
+	// Currency ISO 4217 currency code; must be one of the server's supported currencies (JPY by default)
+	Currency string `json:"currency"`
+	Customer Party  `json:"customer"`
+
+	// DeclaredGrandTotal Client-computed grand total; JP-PINT-MATH-010 is reported when it differs from the computed total by more than the allowed delta
+	DeclaredGrandTotal *float64 `json:"declaredGrandTotal,omitempty"`
+
+	// DocumentType creditNote issues a UBL CreditNote (type code 381) that may carry negative line amounts
+	DocumentType  *InvoiceDraftDocumentType `json:"documentType,omitempty"`
+	DueDate       openapi_types.Date        `json:"dueDate"`
+	InvoiceNumber *string                   `json:"invoiceNumber,omitempty"`
+	IssueDate     openapi_types.Date        `json:"issueDate"`
+	Lines         []LineItem                `json:"lines"`
+	Notes         *string                   `json:"notes,omitempty"`
+	PaymentMeans  *PaymentMeans             `json:"paymentMeans,omitempty"`
+	Supplier      Party                     `json:"supplier"`
+
+	// WithholdingRate Withholding tax (源泉徴収) rate applied to the tax-exclusive amount of lines without their own rate
+	WithholdingRate *float64 `json:"withholdingRate,omitempty"`
 }
 
-// InvoiceDraftCurrency defines model for InvoiceDraft.Currency.
-type InvoiceDraftCurrency string
+// InvoiceDraftDocumentType creditNote issues a UBL CreditNote (type code 381) that may carry negative line amounts
+type InvoiceDraftDocumentType string
 
 // InvoiceIssued defines model for InvoiceIssued.
 type InvoiceIssued struct {
-	ExpiresAt *time.Time          `json:"expiresAt,omitempty"`
-	InvoiceId openapi_types.UUID  `json:"invoiceId"`
-	PdfUrl    *string             `json:"pdfUrl,omitempty"`
-	Status    InvoiceIssuedStatus `json:"status"`
+	ExpiresAt *time.Time         `json:"expiresAt,omitempty"`
+	InvoiceId openapi_types.UUID `json:"invoiceId"`
+
+	// InvoiceNumber The draft's invoice number, or the tenant's next sequence number when the draft omits one; also the UBL cbc:ID
+	InvoiceNumber *string `json:"invoiceNumber,omitempty"`
+	PdfUrl        *string `json:"pdfUrl,omitempty"`
+
+	// PdfStatus Whether the invoice PDF exists; failed renders may be retried in the background
+	PdfStatus *InvoiceIssuedPdfStatus `json:"pdfStatus,omitempty"`
+
+	// SignatureDigest Base64 SHA-256 digest of the unsigned invoice XML that the signature covers
+	SignatureDigest *string `json:"signatureDigest,omitempty"`
+
+	// SignedXmlUrl Signed URL of the XML-DSig signed invoice; present only when a signing key is configured
+	SignedXmlUrl *string             `json:"signedXmlUrl,omitempty"`
+	Status       InvoiceIssuedStatus `json:"status"`
 
 	// XmlUrl Signed URL valid for configured TTL
 	XmlUrl string `json:"xmlUrl"`
 }
 
+// InvoiceIssuedPdfStatus Whether the invoice PDF exists; failed renders may be retried in the background
+type InvoiceIssuedPdfStatus string
+
 // InvoiceIssuedStatus defines model for InvoiceIssued.Status.
 type InvoiceIssuedStatus string
 
 // InvoiceRecord defines model for InvoiceRecord.
 type InvoiceRecord struct {
-	Audit     *AuditEntry         `json:"audit,omitempty"`
-	CreatedAt time.Time           `json:"createdAt"`
-	InvoiceId openapi_types.UUID  `json:"invoiceId"`
-	PdfUrl    *string             `json:"pdfUrl,omitempty"`
-	Status    InvoiceRecordStatus `json:"status"`
-	UpdatedAt time.Time           `json:"updatedAt"`
-	XmlUrl    string              `json:"xmlUrl"`
+	Audit     *AuditEntry        `json:"audit,omitempty"`
+	CreatedAt time.Time          `json:"createdAt"`
+	InvoiceId openapi_types.UUID `json:"invoiceId"`
+
+	// InvoiceNumber The draft's invoice number, or the tenant's next sequence number when the draft omits one; also the UBL cbc:ID
+	InvoiceNumber *string `json:"invoiceNumber,omitempty"`
+	PdfUrl        *string `json:"pdfUrl,omitempty"`
+
+	// PdfStatus Whether the invoice PDF exists; failed renders may be retried in the background
+	PdfStatus *InvoiceRecordPdfStatus `json:"pdfStatus,omitempty"`
+	Status    InvoiceRecordStatus     `json:"status"`
+	UpdatedAt time.Time               `json:"updatedAt"`
+	XmlUrl    string                  `json:"xmlUrl"`
 }
 
+// InvoiceRecordPdfStatus Whether the invoice PDF exists; failed renders may be retried in the background
+type InvoiceRecordPdfStatus string
+
 // InvoiceRecordStatus defines model for InvoiceRecord.Status.
 type InvoiceRecordStatus string
 
 // LineItem defines model for LineItem.
 type LineItem struct {
+	// BaseQuantity Quantity the unit price applies to, e.g. 12 for a per-dozen price; defaults to 1
+	BaseQuantity *float64 `json:"baseQuantity,omitempty"`
+
+	// Currency ISO 4217 currency code; when set it must match the invoice currency
+	Currency    *string `json:"currency,omitempty"`
 	Description string  `json:"description"`
-	Quantity    float64 `json:"quantity"`
+
+	// DiscountAmount Absolute discount taken off the gross line amount
+	DiscountAmount *float64 `json:"discountAmount,omitempty"`
+
+	// DiscountPercent Percentage discount taken off the gross line amount, applied alongside discountAmount
+	DiscountPercent *float64 `json:"discountPercent,omitempty"`
+	Quantity        float64  `json:"quantity"`
 
 	// TaxCategory JP PINT tax category code
 	TaxCategory LineItemTaxCategory `json:"taxCategory"`
@@ -165,6 +280,9 @@ type LineItem struct {
 	// UnitCode UNECE unit code
 	UnitCode  LineItemUnitCode `json:"unitCode"`
 	UnitPrice float64          `json:"unitPrice"`
+
+	// WithholdingRate Withholding tax (源泉徴収) rate for this line, e.g. 0.1021; overrides the draft's withholdingRate
+	WithholdingRate *float64 `json:"withholdingRate,omitempty"`
 }
 
 // LineItemTaxCategory JP PINT tax category code
@@ -175,8 +293,10 @@ type LineItemUnitCode string
 
 // NotFoundError defines model for NotFoundError.
 type NotFoundError struct {
-	Code    string `json:"code"`
-	Message string `json:"message"`
+	Code      string `json:"code"`
+	CorrId    string `json:"corrId"`
+	Message   string `json:"message"`
+	Retryable bool   `json:"retryable"`
 }
 
 // Party defines model for Party.
@@ -193,6 +313,48 @@ type Party struct {
 // PartyCountryCode defines model for Party.CountryCode.
 type PartyCountryCode string
 
+// PaymentMeans defines model for PaymentMeans.
+type PaymentMeans struct {
+	// Account Payee account; either an IBAN or a Japanese domestic account (bank, branch and account number)
+	Account *BankAccount `json:"account,omitempty"`
+
+	// Code UNCL4461 payment means code, e.g. 30 (credit transfer), 42 (payment to bank account), 10 (cash); transfer codes require account
+	Code    string              `json:"code"`
+	DueDate *openapi_types.Date `json:"dueDate,omitempty"`
+
+	// Reference Remittance reference the payer should quote with the payment
+	Reference *string `json:"reference,omitempty"`
+}
+
+// RateLimitError defines model for RateLimitError.
+type RateLimitError struct {
+	Code    string `json:"code"`
+	CorrId  string `json:"corrId"`
+	Message string `json:"message"`
+
+	// RetryAfterSeconds Minimum seconds to wait before retry
+	RetryAfterSeconds int  `json:"retryAfterSeconds"`
+	Retryable         bool `json:"retryable"`
+}
+
+// RequestTooLargeError defines model for RequestTooLargeError.
+type RequestTooLargeError struct {
+	Code      string `json:"code"`
+	CorrId    string `json:"corrId"`
+	Message   string `json:"message"`
+	Retryable bool   `json:"retryable"`
+}
+
+// TaxBreakdown defines model for TaxBreakdown.
+type TaxBreakdown struct {
+	TaxAmount float64 `json:"taxAmount"`
+
+	// TaxCategory JP PINT tax category code
+	TaxCategory   string  `json:"taxCategory"`
+	TaxRate       float64 `json:"taxRate"`
+	TaxableAmount float64 `json:"taxableAmount"`
+}
+
 // ValidationErrorItem defines model for ValidationErrorItem.
 type ValidationErrorItem struct {
 	Code     string                       `json:"code"`
@@ -207,16 +369,35 @@ type ValidationErrorItemSeverity string
 
 // ValidationErrorResponse defines model for ValidationErrorResponse.
 type ValidationErrorResponse struct {
-	Errors []ValidationErrorItem `json:"errors"`
+	Code string `json:"code"`
+
+	// CorrId X-Correlation-Id of the failed request
+	CorrId string `json:"corrId"`
+
+	// Errors Per-field problems; empty when the request as a whole was rejected
+	Errors    []ValidationErrorItem `json:"errors"`
+	Message   string                `json:"message"`
+	Retryable bool                  `json:"retryable"`
 }
 
 // ValidationResponse defines model for ValidationResponse.
 type ValidationResponse struct {
 	Errors []ValidationErrorItem `json:"errors"`
 	Totals *struct {
-		GrandTotal *float64 `json:"grandTotal,omitempty"`
-		Subtotal   *float64 `json:"subtotal,omitempty"`
-		Tax        *float64 `json:"tax,omitempty"`
+		AllowanceTotal *float64 `json:"allowanceTotal,omitempty"`
+
+		// ByCategory Taxable and tax amounts per tax category and rate, in first-seen line order
+		ByCategory  *[]TaxBreakdown `json:"byCategory,omitempty"`
+		ChargeTotal *float64        `json:"chargeTotal,omitempty"`
+		GrandTotal  *float64        `json:"grandTotal,omitempty"`
+
+		// Payable Amount due, grandTotal less withholdingTax
+		Payable  *float64 `json:"payable,omitempty"`
+		Subtotal *float64 `json:"subtotal,omitempty"`
+		Tax      *float64 `json:"tax,omitempty"`
+
+		// WithholdingTax Withholding tax the customer deducts from the payment
+		WithholdingTax *float64 `json:"withholdingTax,omitempty"`
 	} `json:"totals,omitempty"`
 	Valid bool `json:"valid"`
 }
@@ -224,9 +405,15 @@ type ValidationResponse struct {
 // CorrelationId defines model for CorrelationId.
 type CorrelationId = string
 
+// IdempotencyKey defines model for IdempotencyKey.
+type IdempotencyKey = string
+
 // TenantId defines model for TenantId.
 type TenantId = string
 
+// BodyTooLarge defines model for BodyTooLarge.
+type BodyTooLarge = RequestTooLargeError
+
 // Forbidden defines model for Forbidden.
 type Forbidden = ForbiddenError
 
@@ -246,6 +433,9 @@ type IssueInvoiceParams struct {
 
 	// XTenantId Tenant identifier for RBAC and storage segregation
 	XTenantId TenantId `json:"X-Tenant-Id"`
+
+	// IdempotencyKey Optional idempotency key. Same key + same draft returns the original response. Same key + different draft returns 409 conflictReason=idempotency_body_mismatch; a replay while the original is still being issued returns 409 conflictReason=idempotency_replay.
+	IdempotencyKey *IdempotencyKey `json:"Idempotency-Key,omitempty"`
 }
 
 // ValidateInvoiceParams defines parameters for ValidateInvoice.
@@ -378,6 +568,25 @@ func (siw *ServerInterfaceWrapper) IssueInvoice(w http.ResponseWriter, r *http.R
 		return
 	}
 
+	// ------------- Optional header parameter "Idempotency-Key" -------------
+	if valueList, found := headers[http.CanonicalHeaderKey("Idempotency-Key")]; found {
+		var IdempotencyKey IdempotencyKey
+		n := len(valueList)
+		if n != 1 {
+			siw.ErrorHandlerFunc(w, r, &TooManyValuesForParamError{ParamName: "Idempotency-Key", Count: n})
+			return
+		}
+
+		err = runtime.BindStyledParameterWithOptions("simple", "Idempotency-Key", valueList[0], &IdempotencyKey, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationHeader, Explode: false, Required: false})
+		if err != nil {
+			siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "Idempotency-Key", Err: err})
+			return
+		}
+
+		params.IdempotencyKey = &IdempotencyKey
+
+	}
+
 	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		siw.Handler.IssueInvoice(w, r, params)
 	}))
@@ -10,11 +10,35 @@ Errors []ValidationErrorItem `json:"errors"`
 Totals Totals                `json:"totals,omitempty"`
 }
 
-// Totals holds computed invoice totals.
+// Totals holds computed invoice totals. Subtotal sums the lines; the tax
+// exclusive amount is Subtotal - AllowanceTotal + ChargeTotal.
 type Totals struct {
-Subtotal   float64 `json:"subtotal"`
-Tax        float64 `json:"tax"`
-GrandTotal float64 `json:"grandTotal"`
+Subtotal       float64 `json:"subtotal"`
+AllowanceTotal float64 `json:"allowanceTotal"`
+ChargeTotal    float64 `json:"chargeTotal"`
+Tax            float64 `json:"tax"`
+GrandTotal     float64 `json:"grandTotal"`
+// WithholdingTax is the withholding tax (源泉徴収) the customer deducts and
+// pays to the tax office; Payable is GrandTotal less WithholdingTax.
+WithholdingTax float64 `json:"withholdingTax"`
+Payable        float64 `json:"payable"`
+// ByCategory splits Subtotal and Tax per tax category and rate, in the order
+// each pair first appears in the draft.
+ByCategory []TaxBreakdown `json:"byCategory"`
+// Withholding splits WithholdingTax per withholding rate, with the lines'
+// subtotals as the taxable amounts.
+Withholding []TaxBreakdown `json:"-"`
+// Lines holds the rounded per-line amounts the totals were summed from, in
+// draft order, so BuildUBL can emit exactly what was validated.
+Lines []LineTotals `json:"-"`
+}
+
+// LineTotals holds the computed amounts for a single invoice line. Subtotal
+// is net of Discount.
+type LineTotals struct {
+Subtotal float64
+Tax      float64
+Discount float64
 }
 
 // AuditLog represents an audit trail entry for invoice operations.
@@ -28,4 +52,7 @@ Action   string    `json:"action"`
 Ts       time.Time `json:"timestamp"`
 Hash     string    `json:"hash"`
 PrevHash string    `json:"prevHash"`
+// HashAlg is the algorithm Hash was computed with; empty for entries
+// written in the legacy encoding.
+HashAlg string `json:"hashAlg,omitempty"`
 }
@@ -7,53 +7,100 @@ import (
 "fmt"
 "html/template"
 "net/url"
+"os"
+"path/filepath"
+"strconv"
+"strings"
 "time"
 
 "github.com/chromedp/cdproto/page"
 "github.com/chromedp/chromedp"
 )
 
-// PDFRenderer renders invoice PDFs via headless Chromium.
+// InvoicePDFRenderer renders an invoice PDF. contentHash, when non-empty, is
+// embedded in the document so the PDF can later be matched to its XML.
+type InvoicePDFRenderer interface {
+Render(ctx context.Context, draft InvoiceDraft, totals Totals, contentHash string) ([]byte, error)
+}
+
+// PDFRenderer renders invoice PDFs via headless Chromium. One browser process
+// is shared across renders, each of which prints from its own tab; at most
+// cfg.PDFPoolSize tabs render at once. Close shuts the browser down.
 type PDFRenderer struct {
-cfg Config
+cfg  Config
+pool *browserPool
+// fontFace is the @font-face rule embedding the font loaded from
+// cfg.PDFFontsDir, or empty when there is none.
+fontFace template.CSS
+// print loads html in tab and prints it; swapped in tests that run without Chromium.
+print func(tab context.Context, html string) ([]byte, error)
 }
 
-func NewPDFRenderer(cfg Config) PDFRenderer {
-return PDFRenderer{cfg: cfg}
+func NewPDFRenderer(cfg Config) *PDFRenderer {
+return &PDFRenderer{
+cfg:      cfg,
+pool:     newBrowserPool(cfg),
+fontFace: embedFont(defaultString(cfg.PDFFontFamily, "Noto Sans JP"), loadFont(cfg.PDFFontsDir)),
+print:    printToPDF,
+}
 }
 
 // Render builds an HTML from draft/totals and prints it to PDF. If Chromium is
 // unavailable, it returns an error so the caller can decide to retry or skip.
-func (r PDFRenderer) Render(ctx context.Context, draft InvoiceDraft, totals Totals) ([]byte, error) {
-html, err := r.renderHTML(draft, totals)
+func (r *PDFRenderer) Render(ctx context.Context, draft InvoiceDraft, totals Totals, contentHash string) ([]byte, error) {
+ctx, span := startSpan(ctx, "pint.PDFRenderer.Render")
+pdfBuf, err := r.render(ctx, draft, totals, contentHash)
+endSpan(span, err)
+return pdfBuf, err
+}
+
+func (r *PDFRenderer) render(ctx context.Context, draft InvoiceDraft, totals Totals, contentHash string) ([]byte, error) {
+html, err := r.renderHTML(draft, totals, contentHash)
 if err != nil {
 return nil, fmt.Errorf("render html: %w", err)
 }
 
-allocOpts := append(chromedp.DefaultExecAllocatorOptions[:],
-chromedp.Flag("headless", true),
-chromedp.Flag("disable-gpu", true),
-chromedp.Flag("no-sandbox", true),
-)
-if r.cfg.PDFChromiumPath != "" {
-allocOpts = append(allocOpts, chromedp.ExecPath(r.cfg.PDFChromiumPath))
+tab, release, err := r.pool.tab(ctx)
+if err != nil {
+return nil, fmt.Errorf("acquire tab: %w", err)
 }
-
-allocCtx, cancelAlloc := chromedp.NewExecAllocator(ctx, allocOpts...)
-defer cancelAlloc()
+defer release()
 
 ctxTimeout := r.cfg.PDFTimeout
 if ctxTimeout <= 0 {
 ctxTimeout = 15 * time.Second
 }
-runCtx, cancelRun := chromedp.NewContext(allocCtx)
-defer cancelRun()
-runCtx, cancelTimeout := context.WithTimeout(runCtx, ctxTimeout)
+runCtx, cancelTimeout := context.WithTimeout(tab, ctxTimeout)
 defer cancelTimeout()
 
+pdfBuf, err := r.print(runCtx, html)
+if err != nil {
+return nil, fmt.Errorf("chromedp run failed: %w", err)
+}
+if contentHash != "" {
+pdfBuf = stampPDFContentHash(pdfBuf, contentHash)
+}
+return pdfBuf, nil
+}
+
+// Close shuts down the shared browser.
+func (r *PDFRenderer) Close() error {
+return r.pool.Close()
+}
+
+// Ping launches the shared browser, or confirms it is still running, without
+// taking a render slot.
+func (r *PDFRenderer) Ping(ctx context.Context) error {
+if _, err := r.pool.browserContext(); err != nil {
+return err
+}
+return ctx.Err()
+}
+
+func printToPDF(tab context.Context, html string) ([]byte, error) {
 var pdfBuf []byte
 dataURL := "data:text/html," + url.PathEscape(html)
-err = chromedp.Run(runCtx,
+err := chromedp.Run(tab,
 chromedp.Navigate(dataURL),
 chromedp.ActionFunc(func(ctx context.Context) error {
 buf, _, perr := page.PrintToPDF().WithPrintBackground(true).Do(ctx)
@@ -63,14 +110,12 @@ pdfBuf = buf
 return perr
 }),
 )
-if err != nil {
-return nil, fmt.Errorf("chromedp run failed: %w", err)
-}
-return pdfBuf, nil
+return pdfBuf, err
 }
 
 // pdfDraftData is a struct for template rendering with string types
 type pdfDraftData struct {
+Title         string
 Supplier      pdfPartyData
 Customer      pdfPartyData
 IssueDate     string
@@ -79,6 +124,54 @@ Notes         string
 Currency      string
 InvoiceNumber string
 Lines         []pdfLineData
+Payment       *pdfPaymentData
+}
+
+// pdfPaymentData is the お支払い方法 section; Method and AccountType are the
+// Japanese labels for the draft's codes.
+type pdfPaymentData struct {
+Method        string
+DueDate       string
+Reference     string
+IBAN          string
+BankName      string
+BranchName    string
+AccountType   string
+AccountNumber string
+AccountHolder string
+}
+
+// paymentMeansLabels names UNCL4461 payment means codes on the PDF.
+var paymentMeansLabels = map[string]string{
+"10": "現金",
+"30": "銀行振込",
+"42": "銀行振込",
+"48": "クレジットカード",
+"49": "口座振替",
+"58": "SEPA振込",
+}
+
+var accountTypeLabels = map[BankAccountAccountType]string{
+Ordinary: "普通",
+Checking: "当座",
+}
+
+func convertPaymentForPDF(pm PaymentMeans) *pdfPaymentData {
+data := &pdfPaymentData{Method: defaultString(paymentMeansLabels[pm.Code], pm.Code), Reference: stringValue(pm.Reference)}
+if pm.DueDate != nil {
+data.DueDate = pm.DueDate.String()
+}
+if acct := pm.Account; acct != nil {
+data.IBAN = stringValue(acct.Iban)
+data.BankName = stringValue(acct.BankName)
+data.BranchName = stringValue(acct.BranchName)
+data.AccountNumber = stringValue(acct.AccountNumber)
+data.AccountHolder = stringValue(acct.AccountHolder)
+if acct.AccountType != nil {
+data.AccountType = accountTypeLabels[*acct.AccountType]
+}
+}
+return data
 }
 
 type pdfPartyData struct {
@@ -96,9 +189,11 @@ UnitCode    string
 UnitPrice   float64
 TaxCategory string
 TaxRate     float64
+// Amount is the validated line subtotal, net of any line discount.
+Amount      float64
 }
 
-func convertDraftForPDF(draft InvoiceDraft) pdfDraftData {
+func convertDraftForPDF(draft InvoiceDraft, totals Totals) pdfDraftData {
 notes := ""
 if draft.Notes != nil {
 notes = *draft.Notes
@@ -108,7 +203,13 @@ if draft.InvoiceNumber != nil {
 invoiceNumber = *draft.InvoiceNumber
 }
 
+title := "請求書"
+if isCreditNote(draft) {
+title = "クレジットノート"
+}
+
 data := pdfDraftData{
+Title: title,
 Supplier: pdfPartyData{
 Name:        draft.Supplier.Name,
 TaxId:       draft.Supplier.TaxId,
@@ -129,8 +230,15 @@ Notes:         notes,
 Currency:      string(draft.Currency),
 InvoiceNumber: invoiceNumber,
 }
+if draft.PaymentMeans != nil {
+data.Payment = convertPaymentForPDF(*draft.PaymentMeans)
+}
 
-for _, line := range draft.Lines {
+for i, line := range draft.Lines {
+amount := line.Quantity * line.UnitPrice
+if i < len(totals.Lines) {
+amount = totals.Lines[i].Subtotal
+}
 data.Lines = append(data.Lines, pdfLineData{
 Description: line.Description,
 Quantity:    line.Quantity,
@@ -138,16 +246,17 @@ UnitCode:    string(line.UnitCode),
 UnitPrice:   line.UnitPrice,
 TaxCategory: string(line.TaxCategory),
 TaxRate:     line.TaxRate,
+Amount:      amount,
 })
 }
 return data
 }
 
-func (r PDFRenderer) renderHTML(draft InvoiceDraft, totals Totals) (string, error) {
+func (r *PDFRenderer) renderHTML(draft InvoiceDraft, totals Totals, contentHash string) (string, error) {
 tz, _ := time.LoadLocation(defaultString(r.cfg.PDFTimeZone, "Asia/Tokyo"))
 tmpl := template.Must(template.New("invoice").Funcs(template.FuncMap{
 "money": func(v float64) string {
-return fmt.Sprintf("¥%s", formatNumber(v))
+return formatMoney(v, draft.Currency)
 },
 "date": func(v string) string {
 t, err := time.Parse("2006-01-02", v)
@@ -161,25 +270,55 @@ return t.In(tz).Format("2006/01/02")
 "mul100": mul100,
 }).Parse(htmlTemplate))
 
-pdfData := convertDraftForPDF(draft)
+pdfData := convertDraftForPDF(draft, totals)
 
 var buf bytes.Buffer
 if err := tmpl.Execute(&buf, struct {
-Draft  pdfDraftData
-Totals Totals
-Now    string
+Draft       pdfDraftData
+Totals      Totals
+Now         string
+ContentHash string
+FontFace    template.CSS
+FontFamily  string
 }{
-Draft:  pdfData,
-Totals: totals,
-Now:    time.Now().In(tz).Format("2006/01/02 15:04"),
+Draft:       pdfData,
+Totals:      totals,
+Now:         time.Now().In(tz).Format("2006/01/02 15:04"),
+ContentHash: contentHash,
+FontFace:    r.fontFace,
+FontFamily:  defaultString(r.cfg.PDFFontFamily, "Noto Sans JP"),
 }); err != nil {
 return "", err
 }
 return buf.String(), nil
 }
 
-func formatNumber(v float64) string {
-return template.HTMLEscapeString(fmt.Sprintf("%0.0f", v))
+// currencySymbols maps ISO 4217 codes to the symbol money prints; other codes
+// are printed as a "USD 1.00"-style prefix.
+var currencySymbols = map[string]string{
+"JPY": "¥",
+"USD": "$",
+"EUR": "€",
+"GBP": "£",
+}
+
+// zeroDecimalCurrencies have no minor unit and are printed without decimals.
+var zeroDecimalCurrencies = map[string]bool{
+"JPY": true,
+"KRW": true,
+}
+
+// formatMoney renders v in currency with its symbol and minor-unit precision.
+func formatMoney(v float64, currency string) string {
+places := 2
+if zeroDecimalCurrencies[currency] {
+places = 0
+}
+amount := template.HTMLEscapeString(strconv.FormatFloat(v, 'f', places, 64))
+if symbol, ok := currencySymbols[currency]; ok {
+return symbol + amount
+}
+return currency + " " + amount
 }
 
 func htmlEscape(s string) string {
@@ -192,7 +331,8 @@ var htmlTemplate = `
 <head>
   <meta charset="utf-8" />
   <style>
-    body { font-family: 'Noto Sans JP', 'Helvetica Neue', Arial, sans-serif; margin: 24px; color: #0f172a; }
+    {{.FontFace}}
+    body { font-family: '{{.FontFamily}}', 'Helvetica Neue', Arial, sans-serif; margin: 24px; color: #0f172a; }
     h1 { margin: 0 0 8px; }
     .meta { display: flex; justify-content: space-between; margin-bottom: 16px; }
     .card { border: 1px solid #e2e8f0; border-radius: 8px; padding: 12px; margin-bottom: 12px; }
@@ -208,7 +348,7 @@ var htmlTemplate = `
 </head>
 <body>
   <div class="meta">
-    <h1>請求書</h1>
+    <h1>{{.Draft.Title}}</h1>
     <div style="text-align:right">
       <div class="label">発行日</div>
       <div class="value">{{date .Draft.IssueDate}}</div>
@@ -257,7 +397,7 @@ var htmlTemplate = `
         <td>{{printf "%.2f" .Quantity}} {{.UnitCode}}</td>
         <td>{{money .UnitPrice}}</td>
         <td>{{printf "%.0f%%" (mul100 .TaxRate)}}</td>
-        <td class="total">{{money (mul .Quantity .UnitPrice)}}</td>
+        <td class="total">{{money .Amount}}</td>
       </tr>
     {{end}}
     </tbody>
@@ -266,17 +406,34 @@ var htmlTemplate = `
   <div style="display:flex; justify-content:flex-end; margin-top:12px;">
     <div style="min-width:200px;">
       <div class="row" style="justify-content:space-between;"><div>小計</div><div>{{money .Totals.Subtotal}}</div></div>
+      {{if .Totals.AllowanceTotal}}<div class="row" style="justify-content:space-between;"><div>値引</div><div>-{{money .Totals.AllowanceTotal}}</div></div>{{end}}
+      {{if .Totals.ChargeTotal}}<div class="row" style="justify-content:space-between;"><div>追加料金</div><div>{{money .Totals.ChargeTotal}}</div></div>{{end}}
       <div class="row" style="justify-content:space-between;"><div>税額</div><div>{{money .Totals.Tax}}</div></div>
       <div class="row" style="justify-content:space-between; font-weight:700;"><div>合計</div><div>{{money .Totals.GrandTotal}}</div></div>
+      {{if .Totals.WithholdingTax}}<div class="row" style="justify-content:space-between;"><div>源泉徴収税</div><div>-{{money .Totals.WithholdingTax}}</div></div>
+      <div class="row" style="justify-content:space-between; font-weight:700;"><div>お支払金額</div><div>{{money .Totals.Payable}}</div></div>{{end}}
     </div>
   </div>
 
+  {{with .Draft.Payment}}
+  <div class="card">
+    <div class="label">お支払い方法</div>
+    <div class="value">{{.Method}}</div>
+    {{if .DueDate}}<div class="value">お支払期限: {{date .DueDate}}</div>{{end}}
+    {{if .IBAN}}<div class="value">IBAN: {{.IBAN}}</div>{{end}}
+    {{if .BankName}}<div class="value">{{.BankName}} {{.BranchName}}</div>{{end}}
+    {{if .AccountNumber}}<div class="value">{{.AccountType}} {{.AccountNumber}}</div>{{end}}
+    {{if .AccountHolder}}<div class="value">口座名義: {{.AccountHolder}}</div>{{end}}
+    {{if .Reference}}<div class="value">参照番号: {{.Reference}}</div>{{end}}
+  </div>
+  {{end}}
   {{if .Draft.Notes}}
   <div class="card">
     <div class="label">備考</div>
     <div class="value">{{.Draft.Notes}}</div>
   </div>
   {{end}}
+  {{if .ContentHash}}<div id="content-hash" style="display:none">pint-content-hash:{{.ContentHash}}</div>{{end}}
 </body>
 </html>
 `
@@ -285,12 +442,37 @@ var htmlTemplate = `
 func mul(a, b float64) float64 { return a * b }
 func mul100(v float64) float64 { return v * 100 }
 
-// embed font as data URI if needed
-func embedFont(base64Data string) string {
-if base64Data == "" {
+// embedFont returns an @font-face rule carrying font as a base64 data URI
+// under family, or "" when there is no font to embed.
+func embedFont(family string, font []byte) template.CSS {
+if len(font) == 0 {
 return ""
 }
-return fmt.Sprintf("@font-face{font-family:'Noto Sans JP';src:url('data:font/woff2;base64,%s') format('woff2');}", base64.StdEncoding.EncodeToString([]byte(base64Data)))
+family = strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(family)
+return template.CSS(fmt.Sprintf("@font-face{font-family:'%s';src:url('data:font/woff2;base64,%s') format('woff2');}", family, base64.StdEncoding.EncodeToString(font)))
+}
+
+// loadFont reads the first .woff2 file, by name, in dir. A missing dir or
+// font yields nil so rendering falls back to the host's fonts.
+func loadFont(dir string) []byte {
+if dir == "" {
+return nil
+}
+entries, err := os.ReadDir(dir)
+if err != nil {
+return nil
+}
+for _, entry := range entries {
+if entry.IsDir() || !strings.EqualFold(filepath.Ext(entry.Name()), ".woff2") {
+continue
+}
+font, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+if err != nil {
+continue
+}
+return font
+}
+return nil
 }
 
 func defaultString(s, def string) string {
@@ -0,0 +1,52 @@
+package pint
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"regexp"
+)
+
+// pdfHashMarker prefixes the content hash embedded in rendered PDFs.
+const pdfHashMarker = "pint-content-hash:"
+
+var pdfHashPattern = regexp.MustCompile(pdfHashMarker + `([0-9a-f]{64})`)
+
+// invoiceMeta is the stored meta record for an issued invoice.
+type invoiceMeta struct {
+	ContentHash string `json:"contentHash"`
+	Algorithm   string `json:"algorithm"`
+}
+
+// contentHash returns the hex sha256 of the stored XML. The XML is built
+// deterministically from the draft, so it stands in for the draft's content.
+func contentHash(xmlBody []byte) string {
+	sum := sha256.Sum256(xmlBody)
+	return hex.EncodeToString(sum[:])
+}
+
+// stampPDFContentHash appends the hash as a PDF comment after %%EOF. Readers
+// ignore trailing comments, and unlike hidden HTML elements it survives printing.
+func stampPDFContentHash(pdf []byte, hash string) []byte {
+	stamped := make([]byte, 0, len(pdf)+len(pdfHashMarker)+len(hash)+3)
+	stamped = append(stamped, pdf...)
+	if len(stamped) > 0 && stamped[len(stamped)-1] != '\n' {
+		stamped = append(stamped, '\n')
+	}
+	return append(stamped, fmt.Sprintf("%%%s%s\n", pdfHashMarker, hash)...)
+}
+
+// extractPDFContentHash returns the last content hash embedded in pdf, if any.
+func extractPDFContentHash(pdf []byte) (string, bool) {
+	matches := pdfHashPattern.FindAllSubmatch(pdf, -1)
+	if len(matches) == 0 {
+		return "", false
+	}
+	return string(matches[len(matches)-1][1]), true
+}
+
+func encodeInvoiceMeta(hash string) []byte {
+	body, _ := json.Marshal(invoiceMeta{ContentHash: hash, Algorithm: "sha256"})
+	return body
+}
@@ -0,0 +1,149 @@
+package pint
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/chromedp/chromedp"
+)
+
+// ErrPDFRendererClosed is returned by Render after Close.
+var ErrPDFRendererClosed = errors.New("pdf renderer closed")
+
+// browserPool shares one Chromium process between renders. The browser is
+// launched on first use and each render gets its own tab, so a request costs a
+// target rather than a process. A tab is closed when its render finishes; if
+// the browser itself has died, it is dropped and the next render relaunches it.
+type browserPool struct {
+	// launch starts a browser and returns a context bound to it; newTab opens
+	// a tab in that browser. They default to chromedp and are swapped in tests.
+	launch func() (context.Context, context.CancelFunc, error)
+	newTab func(browser context.Context) (context.Context, context.CancelFunc)
+
+	slots chan struct{}
+
+	mu      sync.Mutex
+	browser context.Context
+	cancel  context.CancelFunc
+	closed  bool
+}
+
+func newBrowserPool(cfg Config) *browserPool {
+	p := &browserPool{
+		launch: func() (context.Context, context.CancelFunc, error) {
+			return launchChromium(cfg)
+		},
+		newTab: func(browser context.Context) (context.Context, context.CancelFunc) {
+			return chromedp.NewContext(browser)
+		},
+	}
+	if cfg.PDFPoolSize > 0 {
+		p.slots = make(chan struct{}, cfg.PDFPoolSize)
+	}
+	return p
+}
+
+// launchChromium starts a headless Chromium detached from any request, so one
+// request's cancellation cannot take the shared browser down with it.
+func launchChromium(cfg Config) (context.Context, context.CancelFunc, error) {
+	allocOpts := append(chromedp.DefaultExecAllocatorOptions[:],
+		chromedp.Flag("headless", true),
+		chromedp.Flag("disable-gpu", true),
+		chromedp.Flag("no-sandbox", true),
+	)
+	if cfg.PDFChromiumPath != "" {
+		allocOpts = append(allocOpts, chromedp.ExecPath(cfg.PDFChromiumPath))
+	}
+	allocCtx, cancelAlloc := chromedp.NewExecAllocator(context.Background(), allocOpts...)
+	browserCtx, cancelBrowser := chromedp.NewContext(allocCtx)
+	cancel := func() {
+		cancelBrowser()
+		cancelAlloc()
+	}
+	// Running no actions starts the browser process.
+	if err := chromedp.Run(browserCtx); err != nil {
+		cancel()
+		return nil, nil, fmt.Errorf("launch chromium: %w", err)
+	}
+	return browserCtx, cancel, nil
+}
+
+// tab waits for a free slot and opens a tab, launching the browser if none is
+// running. The tab is also closed if ctx is done first. The caller must call
+// release exactly once.
+func (p *browserPool) tab(ctx context.Context) (context.Context, func(), error) {
+	if p.slots != nil {
+		select {
+		case p.slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		}
+	}
+	freeSlot := func() {
+		if p.slots != nil {
+			<-p.slots
+		}
+	}
+
+	browser, err := p.browserContext()
+	if err != nil {
+		freeSlot()
+		return nil, nil, err
+	}
+	tabCtx, cancelTab := p.newTab(browser)
+	stop := context.AfterFunc(ctx, cancelTab)
+	release := func() {
+		stop()
+		cancelTab()
+		p.dropIfDead(browser)
+		freeSlot()
+	}
+	return tabCtx, release, nil
+}
+
+// browserContext returns the running browser, relaunching it if it has died.
+func (p *browserPool) browserContext() (context.Context, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil, ErrPDFRendererClosed
+	}
+	if p.browser != nil && p.browser.Err() == nil {
+		return p.browser, nil
+	}
+	if p.cancel != nil {
+		p.cancel()
+	}
+	browser, cancel, err := p.launch()
+	if err != nil {
+		p.browser, p.cancel = nil, nil
+		return nil, err
+	}
+	p.browser, p.cancel = browser, cancel
+	return browser, nil
+}
+
+// dropIfDead forgets browser if it is still the pooled one and has exited.
+func (p *browserPool) dropIfDead(browser context.Context) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.browser == browser && browser.Err() != nil {
+		p.cancel()
+		p.browser, p.cancel = nil, nil
+	}
+}
+
+// Close shuts the browser down. Renders already holding a tab fail as their
+// tab goes away; later renders return ErrPDFRendererClosed.
+func (p *browserPool) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	if p.cancel != nil {
+		p.cancel()
+		p.browser, p.cancel = nil, nil
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package pint
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// pdfStatusRecord is stored next to an invoice whose PDF was not rendered, so
+// GetInvoice can tell "never attempted" from "failed".
+type pdfStatusRecord struct {
+	Status    InvoiceRecordPdfStatus `json:"status"`
+	UpdatedAt time.Time              `json:"updatedAt"`
+}
+
+func pdfKey(tenantID, invoiceID string) string {
+	return fmt.Sprintf("%s/invoices/%s/invoice.pdf", tenantID, invoiceID)
+}
+
+func pdfStatusKey(tenantID, invoiceID string) string {
+	return fmt.Sprintf("%s/invoices/%s/pdf-status.json", tenantID, invoiceID)
+}
+
+// renderPDF renders and stores the invoice PDF.
+func (s Service) renderPDF(ctx context.Context, tenantID, invoiceID string, draft InvoiceDraft, totals Totals, hash string) error {
+	pdfBytes, err := s.pdf.Render(ctx, draft, totals, hash)
+	if err != nil {
+		return fmt.Errorf("render pdf: %w", err)
+	}
+	if err := s.storage.PutObject(ctx, pdfKey(tenantID, invoiceID), pdfBytes, "application/pdf"); err != nil {
+		return fmt.Errorf("store pdf: %w", err)
+	}
+	return nil
+}
+
+func (s Service) recordPDFStatus(ctx context.Context, tenantID, invoiceID string, status InvoiceRecordPdfStatus) error {
+	body, _ := json.Marshal(pdfStatusRecord{Status: status, UpdatedAt: time.Now().UTC()})
+	return s.storage.PutObject(ctx, pdfStatusKey(tenantID, invoiceID), body, "application/json")
+}
+
+// pdfStatus reports an invoice's PDF status: rendered once the PDF exists,
+// otherwise whatever was recorded at issue time (skipped when nothing was).
+func (s Service) pdfStatus(ctx context.Context, tenantID, invoiceID string) InvoiceRecordPdfStatus {
+	if _, err := s.storage.Head(ctx, pdfKey(tenantID, invoiceID)); err == nil {
+		return InvoiceRecordPdfStatusRendered
+	}
+	body, _, err := s.storage.GetObject(ctx, pdfStatusKey(tenantID, invoiceID))
+	if err != nil {
+		return InvoiceRecordPdfStatusSkipped
+	}
+	var rec pdfStatusRecord
+	if json.Unmarshal(body, &rec) != nil || rec.Status == "" {
+		return InvoiceRecordPdfStatusSkipped
+	}
+	return rec.Status
+}
+
+// retryPDFRender re-renders a PDF that failed at issue time, up to
+// cfg.PDFRetryAttempts times with doubling delays. It runs detached from the
+// request, so it uses its own context bounded by the PDF timeout per attempt.
+func (s Service) retryPDFRender(tenantID, invoiceID string, draft InvoiceDraft, totals Totals, hash string) {
+	logger := s.logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	delay := s.cfg.PDFRetryDelay
+	for attempt := 1; attempt <= s.cfg.PDFRetryAttempts; attempt++ {
+		time.Sleep(delay)
+		delay *= 2
+
+		timeout := s.cfg.PDFTimeout
+		if timeout <= 0 {
+			timeout = 15 * time.Second
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		err := s.renderPDF(ctx, tenantID, invoiceID, draft, totals, hash)
+		cancel()
+		if err == nil {
+			if err := s.recordPDFStatus(context.Background(), tenantID, invoiceID, InvoiceRecordPdfStatusRendered); err != nil {
+				logger.Warn("pdf status update failed", "invoiceId", invoiceID, "error", err)
+			}
+			logger.Info("pdf render retry succeeded", "invoiceId", invoiceID, "attempt", attempt)
+			return
+		}
+		logger.Warn("pdf render retry failed", "invoiceId", invoiceID, "attempt", attempt, "error", err)
+	}
+	if err := s.recordPDFStatus(context.Background(), tenantID, invoiceID, InvoiceRecordPdfStatusFailed); err != nil {
+		logger.Warn("pdf status update failed", "invoiceId", invoiceID, "error", err)
+	}
+}
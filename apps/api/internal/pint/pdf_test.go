@@ -0,0 +1,250 @@
+package pint
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeBrowser stands in for Chromium: launch counts browser starts and print
+// tracks how many tabs are printing at once.
+type fakeBrowser struct {
+	launches atomic.Int32
+	active   atomic.Int32
+	peak     atomic.Int32
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+}
+
+func newFakeRenderer(cfg Config, browser *fakeBrowser, print func(tab context.Context, html string) ([]byte, error)) *PDFRenderer {
+	r := NewPDFRenderer(cfg)
+	r.pool.launch = func() (context.Context, context.CancelFunc, error) {
+		browser.launches.Add(1)
+		ctx, cancel := context.WithCancel(context.Background())
+		browser.mu.Lock()
+		browser.cancel = cancel
+		browser.mu.Unlock()
+		return ctx, cancel, nil
+	}
+	r.pool.newTab = func(parent context.Context) (context.Context, context.CancelFunc) {
+		return context.WithCancel(parent)
+	}
+	r.print = print
+	return r
+}
+
+func (b *fakeBrowser) print(tab context.Context, _ string) ([]byte, error) {
+	n := b.active.Add(1)
+	defer b.active.Add(-1)
+	for {
+		peak := b.peak.Load()
+		if n <= peak || b.peak.CompareAndSwap(peak, n) {
+			break
+		}
+	}
+	select {
+	case <-time.After(5 * time.Millisecond):
+		return []byte("%PDF-1.4\n%%EOF\n"), nil
+	case <-tab.Done():
+		return nil, tab.Err()
+	}
+}
+
+// crash kills the fake browser, as if the Chromium process exited.
+func (b *fakeBrowser) crash() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.cancel()
+}
+
+func TestPDFRenderer_ConcurrentRendersShareOneBrowser(t *testing.T) {
+	cfg := LoadConfig()
+	cfg.PDFPoolSize = 3
+	browser := &fakeBrowser{}
+	r := newFakeRenderer(cfg, browser, browser.print)
+	defer r.Close()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := r.Render(context.Background(), sampleDraft(), Totals{}, "hash"); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("render failed: %v", err)
+	}
+	if got := browser.launches.Load(); got != 1 {
+		t.Fatalf("expected one browser launch, got %d", got)
+	}
+	if got := browser.peak.Load(); got > 3 {
+		t.Fatalf("expected at most 3 concurrent tabs, got %d", got)
+	}
+}
+
+func TestPDFRenderer_RelaunchesAfterBrowserCrash(t *testing.T) {
+	browser := &fakeBrowser{}
+	crashed := false
+	r := newFakeRenderer(LoadConfig(), browser, func(tab context.Context, html string) ([]byte, error) {
+		if !crashed {
+			crashed = true
+			browser.crash()
+			return nil, errors.New("target crashed")
+		}
+		return browser.print(tab, html)
+	})
+	defer r.Close()
+
+	if _, err := r.Render(context.Background(), sampleDraft(), Totals{}, ""); err == nil {
+		t.Fatal("expected the crashing render to fail")
+	}
+	if _, err := r.Render(context.Background(), sampleDraft(), Totals{}, ""); err != nil {
+		t.Fatalf("expected render after crash to succeed, got %v", err)
+	}
+	if got := browser.launches.Load(); got != 2 {
+		t.Fatalf("expected the browser to be relaunched once, got %d launches", got)
+	}
+}
+
+func TestPDFRenderer_RenderAfterClose(t *testing.T) {
+	browser := &fakeBrowser{}
+	r := newFakeRenderer(LoadConfig(), browser, browser.print)
+	if _, err := r.Render(context.Background(), sampleDraft(), Totals{}, ""); err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	_ = r.Close()
+	if _, err := r.Render(context.Background(), sampleDraft(), Totals{}, ""); !errors.Is(err, ErrPDFRendererClosed) {
+		t.Fatalf("expected ErrPDFRendererClosed, got %v", err)
+	}
+}
+
+func TestRenderHTML_EmbedsFontFromFontsDir(t *testing.T) {
+	dir := t.TempDir()
+	font := []byte("wOF2 fixture font")
+	if err := os.WriteFile(filepath.Join(dir, "NotoSansJP-Regular.woff2"), font, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := LoadConfig()
+	cfg.PDFFontsDir = dir
+	cfg.PDFFontFamily = "Invoice Sans"
+
+	html, err := NewPDFRenderer(cfg).renderHTML(sampleDraft(), Totals{}, "")
+	if err != nil {
+		t.Fatalf("renderHTML: %v", err)
+	}
+	wantFace := "@font-face{font-family:'Invoice Sans';src:url('data:font/woff2;base64," + base64.StdEncoding.EncodeToString(font) + "') format('woff2');}"
+	if !strings.Contains(html, wantFace) {
+		t.Fatalf("expected embedded @font-face %q in HTML:\n%s", wantFace, html)
+	}
+	if !strings.Contains(html, "font-family: 'Invoice Sans'") {
+		t.Fatalf("expected body to use the configured family:\n%s", html)
+	}
+}
+
+func TestRenderHTML_WithoutFontsDirFallsBack(t *testing.T) {
+	cfg := LoadConfig()
+	cfg.PDFFontsDir = t.TempDir()
+
+	html, err := NewPDFRenderer(cfg).renderHTML(sampleDraft(), Totals{}, "")
+	if err != nil {
+		t.Fatalf("renderHTML: %v", err)
+	}
+	if strings.Contains(html, "@font-face") {
+		t.Fatalf("expected no @font-face without a font file")
+	}
+	if !strings.Contains(html, "font-family: 'Noto Sans JP'") {
+		t.Fatalf("expected the default family:\n%s", html)
+	}
+}
+
+func TestRenderHTML_PaymentMeans(t *testing.T) {
+	draft := sampleDraft()
+	draft.PaymentMeans = samplePaymentMeans()
+
+	html, err := NewPDFRenderer(LoadConfig()).renderHTML(draft, Totals{}, "")
+	if err != nil {
+		t.Fatalf("renderHTML: %v", err)
+	}
+	for _, want := range []string{"お支払い方法", "銀行振込", "みずほ銀行 東京営業部", "普通 1234567", "2024/04/30"} {
+		if !strings.Contains(html, want) {
+			t.Errorf("expected %q in payment section", want)
+		}
+	}
+}
+
+func TestRenderHTML_WithholdingTax(t *testing.T) {
+	draft := sampleDraft()
+	rate := 0.1021
+	draft.WithholdingRate = &rate
+	totals := Validator{Config: LoadConfig()}.Validate(draft).Totals
+
+	html, err := NewPDFRenderer(LoadConfig()).renderHTML(draft, totals, "")
+	if err != nil {
+		t.Fatalf("renderHTML: %v", err)
+	}
+	for _, want := range []string{"源泉徴収税", "-¥1225", "お支払金額", "¥11975"} {
+		if !strings.Contains(html, want) {
+			t.Errorf("expected %q in the PDF summary", want)
+		}
+	}
+	plain := Validator{Config: LoadConfig()}.Validate(sampleDraft()).Totals
+	if html, _ := NewPDFRenderer(LoadConfig()).renderHTML(sampleDraft(), plain, ""); strings.Contains(html, "源泉徴収税") {
+		t.Fatal("expected no withholding rows without withholding tax")
+	}
+}
+
+func requireChromium(b *testing.B, cfg Config) {
+	if cfg.PDFChromiumPath != "" {
+		return
+	}
+	for _, name := range []string{"chromium", "chromium-browser", "google-chrome", "headless-shell"} {
+		if _, err := exec.LookPath(name); err == nil {
+			return
+		}
+	}
+	b.Skip("chromium not available")
+}
+
+// BenchmarkPDFRender_PerRequest launches a browser for every render, as the
+// renderer did before it pooled one.
+func BenchmarkPDFRender_PerRequest(b *testing.B) {
+	cfg := LoadConfig()
+	requireChromium(b, cfg)
+	ctx := context.Background()
+	for i := 0; i < b.N; i++ {
+		r := NewPDFRenderer(cfg)
+		if _, err := r.Render(ctx, sampleDraft(), Totals{}, ""); err != nil {
+			b.Fatal(err)
+		}
+		_ = r.Close()
+	}
+}
+
+func BenchmarkPDFRender_Pooled(b *testing.B) {
+	cfg := LoadConfig()
+	requireChromium(b, cfg)
+	r := NewPDFRenderer(cfg)
+	defer r.Close()
+	ctx := context.Background()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := r.Render(ctx, sampleDraft(), Totals{}, ""); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package pint
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrDuplicateInvoiceNumber is returned by SequenceStore.Claim for a number the
+// tenant has already used.
+var ErrDuplicateInvoiceNumber = errors.New("invoice number already used")
+
+// SequenceStore hands out per-tenant invoice numbers. Next allocates the next
+// unused number in a series and Claim records a client-supplied one; both are
+// atomic, so concurrent issuances never share a number. Release returns a
+// number whose issuance failed, so the series stays gapless.
+type SequenceStore interface {
+	Next(ctx context.Context, tenantID, series string, format func(seq int) string) (string, error)
+	Claim(ctx context.Context, tenantID, number string) error
+	Release(ctx context.Context, tenantID, number string) error
+}
+
+// sequenceSlot is the series position Next allocated a number from.
+type sequenceSlot struct {
+	series string
+	seq    int
+}
+
+// InMemorySequenceStore is the default SequenceStore for local development.
+type InMemorySequenceStore struct {
+	mu       sync.Mutex
+	counters map[string]map[string]int
+	used     map[string]map[string]bool
+	slots    map[string]map[string]sequenceSlot
+}
+
+func NewInMemorySequenceStore() *InMemorySequenceStore {
+	return &InMemorySequenceStore{
+		counters: map[string]map[string]int{},
+		used:     map[string]map[string]bool{},
+		slots:    map[string]map[string]sequenceSlot{},
+	}
+}
+
+// Next advances tenantID's counter for series and returns the first number,
+// as rendered by format, that the tenant has not used yet. A canceled ctx
+// fails before anything is allocated, so no number is consumed.
+func (s *InMemorySequenceStore) Next(ctx context.Context, tenantID, series string, format func(seq int) string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.counters[tenantID] == nil {
+		s.counters[tenantID] = map[string]int{}
+		s.slots[tenantID] = map[string]sequenceSlot{}
+	}
+	for {
+		s.counters[tenantID][series]++
+		seq := s.counters[tenantID][series]
+		number := format(seq)
+		if s.used[tenantID][number] {
+			continue
+		}
+		s.markUsedLocked(tenantID, number)
+		s.slots[tenantID][number] = sequenceSlot{series: series, seq: seq}
+		return number, nil
+	}
+}
+
+// Claim records number as used by tenantID. Like Next, a canceled ctx fails
+// before the number is recorded.
+func (s *InMemorySequenceStore) Claim(ctx context.Context, tenantID, number string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.used[tenantID][number] {
+		return ErrDuplicateInvoiceNumber
+	}
+	s.markUsedLocked(tenantID, number)
+	return nil
+}
+
+// Release frees number. A number Next allocated also rewinds its series when
+// it is still the latest one; an earlier number leaves a gap, since later
+// numbers are already out.
+func (s *InMemorySequenceStore) Release(ctx context.Context, tenantID, number string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.used[tenantID], number)
+	if slot, ok := s.slots[tenantID][number]; ok {
+		delete(s.slots[tenantID], number)
+		if s.counters[tenantID][slot.series] == slot.seq {
+			s.counters[tenantID][slot.series]--
+		}
+	}
+	return ctx.Err()
+}
+
+func (s *InMemorySequenceStore) markUsedLocked(tenantID, number string) {
+	if s.used[tenantID] == nil {
+		s.used[tenantID] = map[string]bool{}
+	}
+	s.used[tenantID][number] = true
+}
+
+// invoiceNumberSeries renders cfg.InvoiceNumberFormat for a draft: {year} is
+// the issue year and {seq} is left for the returned formatter, which pads the
+// sequence to cfg.InvoiceNumberWidth digits. Each distinct series (e.g. each
+// year) has its own counter.
+func invoiceNumberSeries(cfg Config, draft InvoiceDraft) (string, func(seq int) string) {
+	format := defaultString(cfg.InvoiceNumberFormat, "INV-{year}-{seq}")
+	if !strings.Contains(format, "{seq}") {
+		format += "{seq}"
+	}
+	series := strings.ReplaceAll(format, "{year}", strconv.Itoa(draft.IssueDate.Year()))
+	width := cfg.InvoiceNumberWidth
+	return series, func(seq int) string {
+		return strings.Replace(series, "{seq}", fmt.Sprintf("%0*d", width, seq), 1)
+	}
+}
+
+// assignInvoiceNumber claims the draft's own invoice number or, when it has
+// none, allocates the tenant's next one.
+func (s Service) assignInvoiceNumber(ctx context.Context, tenantID string, draft InvoiceDraft) (string, error) {
+	if draft.InvoiceNumber != nil {
+		if number := strings.TrimSpace(*draft.InvoiceNumber); number != "" {
+			return number, s.sequences.Claim(ctx, tenantID, number)
+		}
+	}
+	series, format := invoiceNumberSeries(s.cfg, draft)
+	return s.sequences.Next(ctx, tenantID, series, format)
+}
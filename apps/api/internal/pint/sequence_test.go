@@ -0,0 +1,148 @@
+package pint
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func issueForNumber(t *testing.T, svc Service, draft InvoiceDraft) (int, map[string]any) {
+	t.Helper()
+	body, _ := json.Marshal(draft)
+	w := httptest.NewRecorder()
+	svc.IssueInvoice(w, asTenant(newInvoiceRequest(http.MethodPost, "/invoices", body), "tenant-a"))
+	var resp map[string]any
+	_ = json.NewDecoder(w.Body).Decode(&resp)
+	return w.Code, resp
+}
+
+func TestIssueInvoice_AssignsInvoiceNumber(t *testing.T) {
+	svc, storage := newTestService(LoadConfig())
+
+	for _, want := range []string{"INV-2024-000001", "INV-2024-000002"} {
+		code, resp := issueForNumber(t, svc, sampleDraft())
+		if code != http.StatusCreated {
+			t.Fatalf("expected 201, got %d: %v", code, resp)
+		}
+		if resp["invoiceNumber"] != want {
+			t.Fatalf("expected invoice number %s, got %v", want, resp["invoiceNumber"])
+		}
+		id, _ := resp["invoiceId"].(string)
+		xmlBody, _, err := storage.GetObject(context.Background(), "tenant-a/invoices/"+id+"/invoice.xml")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(xmlBody), "<cbc:ID>"+want+"</cbc:ID>") {
+			t.Fatalf("expected cbc:ID %s in UBL", want)
+		}
+	}
+
+	cfg := LoadConfig()
+	cfg.InvoiceNumberFormat = "{year}/{seq}"
+	cfg.InvoiceNumberWidth = 3
+	svc, _ = newTestService(cfg)
+	if _, resp := issueForNumber(t, svc, sampleDraft()); resp["invoiceNumber"] != "2024/001" {
+		t.Fatalf("expected configured format, got %v", resp["invoiceNumber"])
+	}
+}
+
+func TestIssueInvoice_RejectsDuplicateInvoiceNumber(t *testing.T) {
+	svc, _ := newTestService(LoadConfig())
+	draft := sampleDraft()
+	number := "INV-2024-000001"
+	draft.InvoiceNumber = &number
+
+	if code, resp := issueForNumber(t, svc, draft); code != http.StatusCreated || resp["invoiceNumber"] != number {
+		t.Fatalf("expected client number to be kept, got %d: %v", code, resp)
+	}
+	code, resp := issueForNumber(t, svc, draft)
+	if code != http.StatusConflict || resp["code"] != "DUPLICATE_INVOICE_NUMBER" {
+		t.Fatalf("expected 409 DUPLICATE_INVOICE_NUMBER, got %d: %v", code, resp)
+	}
+
+	// The sequence steps over numbers clients have already taken.
+	if _, resp := issueForNumber(t, svc, sampleDraft()); resp["invoiceNumber"] != "INV-2024-000002" {
+		t.Fatalf("expected sequence to skip the claimed number, got %v", resp["invoiceNumber"])
+	}
+}
+
+func TestInMemorySequenceStore_CanceledContextConsumesNothing(t *testing.T) {
+	store := NewInMemorySequenceStore()
+	series, format := invoiceNumberSeries(LoadConfig(), sampleDraft())
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := store.Next(canceled, "tenant-a", series, format); err == nil {
+		t.Fatal("expected Next to fail on a canceled context")
+	}
+	if number, err := store.Next(context.Background(), "tenant-a", series, format); err != nil || number != "INV-2024-000001" {
+		t.Fatalf("expected the first number to still be free, got %q %v", number, err)
+	}
+	if err := store.Claim(canceled, "tenant-a", "CLIENT-1"); err == nil {
+		t.Fatal("expected Claim to fail on a canceled context")
+	}
+	if err := store.Claim(context.Background(), "tenant-a", "CLIENT-1"); err != nil {
+		t.Fatalf("expected the client number to still be free, got %v", err)
+	}
+}
+
+func TestInMemorySequenceStore_ConcurrentNextIsUnique(t *testing.T) {
+	store := NewInMemorySequenceStore()
+	series, format := invoiceNumberSeries(LoadConfig(), sampleDraft())
+
+	const n = 200
+	numbers := make([]string, n)
+	var wg sync.WaitGroup
+	for i := range numbers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			number, err := store.Next(context.Background(), "tenant-a", series, format)
+			if err != nil {
+				t.Error(err)
+			}
+			numbers[i] = number
+		}()
+	}
+	wg.Wait()
+
+	seen := map[string]bool{}
+	for _, number := range numbers {
+		if seen[number] {
+			t.Fatalf("number %s allocated twice", number)
+		}
+		seen[number] = true
+	}
+	for seq := 1; seq <= n; seq++ {
+		if !seen[fmt.Sprintf("INV-2024-%06d", seq)] {
+			t.Fatalf("expected gapless numbers, missing sequence %d", seq)
+		}
+	}
+}
+
+func TestInMemorySequenceStore_ReleaseRewindsLatest(t *testing.T) {
+	ctx := context.Background()
+	store := NewInMemorySequenceStore()
+	series, format := invoiceNumberSeries(LoadConfig(), sampleDraft())
+
+	first, _ := store.Next(ctx, "tenant-a", series, format)
+	if err := store.Release(ctx, "tenant-a", first); err != nil {
+		t.Fatal(err)
+	}
+	again, _ := store.Next(ctx, "tenant-a", series, format)
+	if again != first {
+		t.Fatalf("expected released number %s to be reissued, got %s", first, again)
+	}
+	if err := store.Claim(ctx, "tenant-a", again); !errors.Is(err, ErrDuplicateInvoiceNumber) {
+		t.Fatalf("expected ErrDuplicateInvoiceNumber, got %v", err)
+	}
+	if err := store.Claim(ctx, "tenant-b", again); err != nil {
+		t.Fatalf("expected numbers to be scoped per tenant, got %v", err)
+	}
+}
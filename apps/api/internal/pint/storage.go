@@ -2,9 +2,15 @@ package pint
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
+	"strings"
 	"sync"
 	"time"
 )
@@ -16,6 +22,21 @@ type ObjectMeta struct {
 	ContentType string
 }
 
+// Signed URL errors reported by URLVerifier.
+var (
+	ErrURLExpired   = errors.New("signed URL has expired")
+	ErrURLSignature = errors.New("signed URL signature is invalid")
+)
+
+// ErrObjectNotFound is returned by InMemoryStorage for a key that does not exist.
+var ErrObjectNotFound = errors.New("object not found")
+
+// URLVerifier is implemented by storages whose signed URLs this process serves
+// itself, so DownloadHandler can reject expired or tampered links.
+type URLVerifier interface {
+	VerifySignedURL(key string, query url.Values) error
+}
+
 type Storage interface {
 	PutObject(ctx context.Context, key string, body []byte, contentType string) error
 	GetSignedURL(ctx context.Context, key string, ttl time.Duration) (string, error)
@@ -23,11 +44,17 @@ type Storage interface {
 	GetObject(ctx context.Context, key string) ([]byte, string, error)
 }
 
+// DefaultPublicBaseURL is where the dev server's /storage/* route serves
+// InMemoryStorage objects.
+const DefaultPublicBaseURL = "http://localhost:8080/storage"
+
 // InMemoryStorage is a lightweight stub to unblock local testing without S3.
 type InMemoryStorage struct {
-	mu   sync.RWMutex
-	data map[string]storedObject
-	meta map[string]ObjectMeta
+	mu      sync.RWMutex
+	data    map[string]storedObject
+	meta    map[string]ObjectMeta
+	baseURL *url.URL
+	secret  []byte
 }
 
 type storedObject struct {
@@ -37,10 +64,41 @@ type storedObject struct {
 }
 
 func NewInMemoryStorage() *InMemoryStorage {
+	s, _ := NewInMemoryStorageWithBaseURL(DefaultPublicBaseURL)
+	return s
+}
+
+// NewInMemoryStorageWithBaseURL returns an InMemoryStorage whose signed URLs
+// are publicBaseURL/{key}, so they point at wherever the objects are served.
+func NewInMemoryStorageWithBaseURL(publicBaseURL string) (*InMemoryStorage, error) {
+	base, err := url.Parse(publicBaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("public base URL: %w", err)
+	}
+	if (base.Scheme != "http" && base.Scheme != "https") || base.Host == "" {
+		return nil, fmt.Errorf("public base URL %q must be an absolute http(s) URL", publicBaseURL)
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("signing secret: %w", err)
+	}
 	return &InMemoryStorage{
-		data: map[string]storedObject{},
-		meta: map[string]ObjectMeta{},
+		data:    map[string]storedObject{},
+		meta:    map[string]ObjectMeta{},
+		baseURL: base,
+		secret:  secret,
+	}, nil
+}
+
+// SetSigningSecret replaces the random per-process key that signs URLs, so
+// links stay valid across restarts and replicas. An empty secret is ignored.
+func (s *InMemoryStorage) SetSigningSecret(secret string) {
+	if secret == "" {
+		return
 	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.secret = []byte(secret)
 }
 
 func (s *InMemoryStorage) PutObject(ctx context.Context, key string, body []byte, _ string) error {
@@ -60,24 +118,56 @@ func (s *InMemoryStorage) GetSignedURL(_ context.Context, key string, ttl time.D
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 	if _, ok := s.data[key]; !ok {
-		return "", fmt.Errorf("not found")
+		return "", ErrObjectNotFound
 	}
 	exp := time.Now().UTC().Add(ttl).Format(time.RFC3339)
-	u := url.URL{
-		Scheme:   "http",
-		Host:     "localhost:8080",
-		Path:     "/storage/" + key,
-		RawQuery: "exp=" + url.QueryEscape(exp),
-	}
+	u := *s.baseURL
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + key
+	q := u.Query()
+	q.Set("exp", exp)
+	q.Set("sig", hex.EncodeToString(s.sign(key, exp)))
+	u.RawQuery = q.Encode()
 	return u.String(), nil
 }
 
+// VerifySignedURL checks the exp and sig parameters of a URL issued by
+// GetSignedURL for key: the signature must match, so exp cannot be altered,
+// and exp must not have passed.
+func (s *InMemoryStorage) VerifySignedURL(key string, query url.Values) error {
+	exp := query.Get("exp")
+	sig, err := hex.DecodeString(query.Get("sig"))
+	if err != nil || exp == "" {
+		return ErrURLSignature
+	}
+	s.mu.RLock()
+	want := s.sign(key, exp)
+	s.mu.RUnlock()
+	if !hmac.Equal(sig, want) {
+		return ErrURLSignature
+	}
+	expiresAt, err := time.Parse(time.RFC3339, exp)
+	if err != nil {
+		return ErrURLSignature
+	}
+	if time.Now().After(expiresAt) {
+		return ErrURLExpired
+	}
+	return nil
+}
+
+// sign returns the HMAC-SHA256 of key and exp. Callers must hold s.mu.
+func (s *InMemoryStorage) sign(key, exp string) []byte {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(key + "\n" + exp))
+	return mac.Sum(nil)
+}
+
 func (s *InMemoryStorage) Head(_ context.Context, key string) (ObjectMeta, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 	meta, ok := s.meta[key]
 	if !ok {
-		return ObjectMeta{}, fmt.Errorf("not found")
+		return ObjectMeta{}, ErrObjectNotFound
 	}
 	return meta, nil
 }
@@ -87,7 +177,7 @@ func (s *InMemoryStorage) GetObject(_ context.Context, key string) ([]byte, stri
 	defer s.mu.RUnlock()
 	obj, ok := s.data[key]
 	if !ok {
-		return nil, "", fmt.Errorf("not found")
+		return nil, "", ErrObjectNotFound
 	}
 	return obj.body, obj.contentType, nil
 }
@@ -0,0 +1,51 @@
+package pint
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestInMemoryStorageSignedURLUsesPublicBaseURL(t *testing.T) {
+	tests := []struct {
+		base string
+		want string
+	}{
+		{base: DefaultPublicBaseURL, want: "http://localhost:8080/storage/t/invoice.pdf"},
+		{base: "https://dev.example.com:8443/files?v=1", want: "https://dev.example.com:8443/files/t/invoice.pdf"},
+	}
+	for _, tt := range tests {
+		st, err := NewInMemoryStorageWithBaseURL(tt.base)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.base, err)
+		}
+		if err := st.PutObject(context.Background(), "t/invoice.pdf", []byte("%PDF-1.7"), "application/pdf"); err != nil {
+			t.Fatal(err)
+		}
+		raw, err := st.GetSignedURL(context.Background(), "t/invoice.pdf", time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		u, err := url.Parse(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := time.Parse(time.RFC3339, u.Query().Get("exp")); err != nil {
+			t.Fatalf("expected an RFC 3339 exp parameter in %s: %v", raw, err)
+		}
+		if u.Scheme+"://"+u.Host+u.Path != tt.want {
+			t.Fatalf("expected %s, got %s", tt.want, raw)
+		}
+	}
+
+	st, _ := NewInMemoryStorageWithBaseURL("https://dev.example.com/files?v=1")
+	_ = st.PutObject(context.Background(), "k", []byte("x"), "")
+	raw, _ := st.GetSignedURL(context.Background(), "k", time.Minute)
+	if u, _ := url.Parse(raw); u.Query().Get("v") != "1" {
+		t.Fatalf("expected the base URL's query to be kept, got %s", raw)
+	}
+	if _, err := NewInMemoryStorageWithBaseURL("ftp://files"); err == nil {
+		t.Fatal("expected a non-http base URL to be rejected")
+	}
+}
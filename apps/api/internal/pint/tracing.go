@@ -0,0 +1,48 @@
+package pint
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer resolves through the global provider, so spans are no-ops until the
+// binary installs one with otel.SetTracerProvider.
+var tracer = otel.Tracer("github.com/yourorg/yourapp/apps/api/internal/pint")
+
+// startSpan starts a child span of ctx, tagged with the correlation and tenant
+// IDs withRequestContext stored in it.
+func startSpan(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	var attrs []attribute.KeyValue
+	if corrID, ok := ctx.Value(corrIDContextKey{}).(string); ok {
+		attrs = append(attrs, attribute.String("correlation.id", corrID))
+	}
+	if tenantID, ok := ctx.Value(tenantIDContextKey{}).(string); ok {
+		attrs = append(attrs, attribute.String("tenant.id", tenantID))
+	}
+	return tracer.Start(ctx, name, append(opts, trace.WithAttributes(attrs...))...)
+}
+
+// endSpan marks span failed when err is set, then ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// validateTraced runs validator.Validate under a span.
+func validateTraced(ctx context.Context, validator InvoiceValidator, draft InvoiceDraft) ValidationResult {
+	_, span := startSpan(ctx, "pint.Validator.Validate")
+	result := validator.Validate(draft)
+	span.SetAttributes(
+		attribute.Bool("invoice.valid", result.Valid),
+		attribute.Int("invoice.validation_errors", len(result.Errors)),
+	)
+	span.End()
+	return result
+}
@@ -0,0 +1,89 @@
+package pint
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/yourorg/yourapp/apps/api/internal/auth"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func TestIssueInvoice_TraceSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(noop.NewTracerProvider()) })
+
+	authCfg := auth.Config{APIKeyHashAlgorithm: "bcrypt", BcryptCost: 4, KeyRotationWindow: time.Hour}
+	store := auth.NewInMemoryAPIKeyStore(authCfg)
+	if err := store.CreateTenant(context.Background(), auth.Tenant{ID: "tenant-a", Name: "Tenant A", Status: "active"}); err != nil {
+		t.Fatal(err)
+	}
+	key, rawKey, err := store.CreateKey(context.Background(), "tenant-a", "issuer", []string{"*"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc, _ := newTestService(LoadConfig())
+	svc.pdf = newFakeRenderer(LoadConfig(), &fakeBrowser{}, (&fakeBrowser{}).print)
+	handler := auth.Middleware(store, auth.NewInMemoryAuthAuditRecorder(), authCfg, nil)(http.HandlerFunc(svc.IssueInvoice))
+
+	body, _ := json.Marshal(sampleDraft())
+	r := newInvoiceRequest(http.MethodPost, "/invoices", body)
+	r.Header.Set("Authorization", "Bearer "+rawKey)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+	root, ok := spans["auth.Middleware"]
+	if !ok {
+		t.Fatalf("expected an auth.Middleware span, got %v", spanNames(spans))
+	}
+	if root.Parent().IsValid() {
+		t.Fatal("expected auth.Middleware to be the root span")
+	}
+	rootAttrs := spanAttributes(root)
+	if rootAttrs["correlation.id"] != "corr-1" || rootAttrs["tenant.id"] != "tenant-a" || rootAttrs["auth.key_id"] != key.ID {
+		t.Fatalf("expected correlation, tenant and key attributes on the root span, got %v", rootAttrs)
+	}
+	for _, name := range []string{"auth.ValidateKey", "pint.Validator.Validate", "pint.BuildUBL", "pint.PDFRenderer.Render"} {
+		span, ok := spans[name]
+		if !ok {
+			t.Fatalf("expected a %s span, got %v", name, spanNames(spans))
+		}
+		if span.Parent().SpanID() != root.SpanContext().SpanID() || span.SpanContext().TraceID() != root.SpanContext().TraceID() {
+			t.Fatalf("expected %s to be a child of auth.Middleware", name)
+		}
+		if name != "auth.ValidateKey" && spanAttributes(span)["correlation.id"] != "corr-1" {
+			t.Fatalf("expected %s to carry the correlation ID, got %v", name, spanAttributes(span))
+		}
+	}
+}
+
+func spanNames(spans map[string]sdktrace.ReadOnlySpan) []string {
+	names := make([]string, 0, len(spans))
+	for name := range spans {
+		names = append(names, name)
+	}
+	return names
+}
+
+func spanAttributes(span sdktrace.ReadOnlySpan) map[string]string {
+	attrs := map[string]string{}
+	for _, kv := range span.Attributes() {
+		attrs[string(kv.Key)] = kv.Value.Emit()
+	}
+	return attrs
+}
@@ -5,24 +5,31 @@ import (
 "fmt"
 )
 
+// UBLInvoice is the document root. XMLName is set by BuildUBL so a credit note
+// marshals as CreditNote with its own type code and line elements.
 type UBLInvoice struct {
-XMLName                 xml.Name      `xml:"Invoice"`
-Xmlns                   string        `xml:"xmlns,attr"`
-Cbc                     string        `xml:"xmlns:cbc,attr"`
-Cac                     string        `xml:"xmlns:cac,attr"`
-CustomizationID         string        `xml:"cbc:CustomizationID"`
-ProfileID               string        `xml:"cbc:ProfileID"`
-ID                      string        `xml:"cbc:ID"`
-IssueDate               string        `xml:"cbc:IssueDate"`
-DueDate                 string        `xml:"cbc:DueDate"`
-InvoiceTypeCode         string        `xml:"cbc:InvoiceTypeCode"`
-Note                    string        `xml:"cbc:Note,omitempty"`
-DocumentCurrencyCode    string        `xml:"cbc:DocumentCurrencyCode"`
-AccountingSupplierParty PartyWrapper  `xml:"cac:AccountingSupplierParty"`
-AccountingCustomerParty PartyWrapper  `xml:"cac:AccountingCustomerParty"`
-TaxTotal                TaxTotal      `xml:"cac:TaxTotal"`
-LegalMonetaryTotal      MonetaryTotal `xml:"cac:LegalMonetaryTotal"`
-InvoiceLine             []InvoiceLine `xml:"cac:InvoiceLine"`
+XMLName                 xml.Name
+Xmlns                   string                `xml:"xmlns,attr"`
+Cbc                     string                `xml:"xmlns:cbc,attr"`
+Cac                     string                `xml:"xmlns:cac,attr"`
+CustomizationID         string                `xml:"cbc:CustomizationID"`
+ProfileID               string                `xml:"cbc:ProfileID"`
+ID                      string                `xml:"cbc:ID"`
+IssueDate               string                `xml:"cbc:IssueDate"`
+DueDate                 string                `xml:"cbc:DueDate,omitempty"`
+InvoiceTypeCode         string                `xml:"cbc:InvoiceTypeCode,omitempty"`
+CreditNoteTypeCode      string                `xml:"cbc:CreditNoteTypeCode,omitempty"`
+Note                    string                `xml:"cbc:Note,omitempty"`
+DocumentCurrencyCode    string                `xml:"cbc:DocumentCurrencyCode"`
+AccountingSupplierParty PartyWrapper          `xml:"cac:AccountingSupplierParty"`
+AccountingCustomerParty PartyWrapper          `xml:"cac:AccountingCustomerParty"`
+PaymentMeans            *PaymentMeansType     `xml:"cac:PaymentMeans,omitempty"`
+AllowanceCharge         []AllowanceChargeType `xml:"cac:AllowanceCharge"`
+TaxTotal                TaxTotal              `xml:"cac:TaxTotal"`
+WithholdingTaxTotal     *TaxTotal             `xml:"cac:WithholdingTaxTotal,omitempty"`
+LegalMonetaryTotal      MonetaryTotal         `xml:"cac:LegalMonetaryTotal"`
+InvoiceLine             []InvoiceLine         `xml:"cac:InvoiceLine"`
+CreditNoteLine          []InvoiceLine         `xml:"cac:CreditNoteLine"`
 }
 
 type PartyWrapper struct {
@@ -59,23 +66,65 @@ ID string `xml:"cbc:ID"`
 }
 
 type TaxTotal struct {
-TaxAmount Amount `xml:"cbc:TaxAmount"`
+TaxAmount   Amount        `xml:"cbc:TaxAmount"`
+TaxSubtotal []TaxSubtotal `xml:"cac:TaxSubtotal"`
+}
+
+type TaxSubtotal struct {
+TaxableAmount Amount      `xml:"cbc:TaxableAmount"`
+TaxAmount     Amount      `xml:"cbc:TaxAmount"`
+TaxCategory   TaxCategory `xml:"cac:TaxCategory"`
+}
+
+// PaymentMeansType is cac:PaymentMeans: how and by when the invoice is paid.
+type PaymentMeansType struct {
+PaymentMeansCode      string            `xml:"cbc:PaymentMeansCode"`
+PaymentDueDate        string            `xml:"cbc:PaymentDueDate,omitempty"`
+PaymentID             string            `xml:"cbc:PaymentID,omitempty"`
+PayeeFinancialAccount *FinancialAccount `xml:"cac:PayeeFinancialAccount,omitempty"`
+}
+
+// FinancialAccount is the payee account: an IBAN, or a Japanese account number
+// with its deposit type, branch and bank.
+type FinancialAccount struct {
+ID                         string                      `xml:"cbc:ID"`
+Name                       string                      `xml:"cbc:Name,omitempty"`
+AccountTypeCode            string                      `xml:"cbc:AccountTypeCode,omitempty"`
+FinancialInstitutionBranch *FinancialInstitutionBranch `xml:"cac:FinancialInstitutionBranch,omitempty"`
+}
+
+type FinancialInstitutionBranch struct {
+Name                 string       `xml:"cbc:Name,omitempty"`
+FinancialInstitution *NameWrapper `xml:"cac:FinancialInstitution,omitempty"`
+}
+
+// AllowanceChargeType is a document- or line-level cac:AllowanceCharge; line
+// allowances carry no TaxCategory.
+type AllowanceChargeType struct {
+ChargeIndicator       bool         `xml:"cbc:ChargeIndicator"`
+AllowanceChargeReason string       `xml:"cbc:AllowanceChargeReason"`
+Amount                Amount       `xml:"cbc:Amount"`
+TaxCategory           *TaxCategory `xml:"cac:TaxCategory,omitempty"`
 }
 
 type MonetaryTotal struct {
-LineExtensionAmount Amount `xml:"cbc:LineExtensionAmount"`
-TaxExclusiveAmount  Amount `xml:"cbc:TaxExclusiveAmount"`
-TaxInclusiveAmount  Amount `xml:"cbc:TaxInclusiveAmount"`
-PayableAmount       Amount `xml:"cbc:PayableAmount"`
+LineExtensionAmount  Amount  `xml:"cbc:LineExtensionAmount"`
+TaxExclusiveAmount   Amount  `xml:"cbc:TaxExclusiveAmount"`
+TaxInclusiveAmount   Amount  `xml:"cbc:TaxInclusiveAmount"`
+AllowanceTotalAmount *Amount `xml:"cbc:AllowanceTotalAmount,omitempty"`
+ChargeTotalAmount    *Amount `xml:"cbc:ChargeTotalAmount,omitempty"`
+PayableAmount        Amount  `xml:"cbc:PayableAmount"`
 }
 
 type InvoiceLine struct {
 ID                  string       `xml:"cbc:ID"`
-InvoicedQuantity    Quantity     `xml:"cbc:InvoicedQuantity"`
-LineExtensionAmount Amount       `xml:"cbc:LineExtensionAmount"`
-Item                Item         `xml:"cac:Item"`
-Price               Price        `xml:"cac:Price"`
-TaxTotal            LineTaxTotal `xml:"cac:TaxTotal"`
+InvoicedQuantity    *Quantity    `xml:"cbc:InvoicedQuantity,omitempty"`
+CreditedQuantity    *Quantity    `xml:"cbc:CreditedQuantity,omitempty"`
+LineExtensionAmount Amount                `xml:"cbc:LineExtensionAmount"`
+AllowanceCharge     []AllowanceChargeType `xml:"cac:AllowanceCharge"`
+Item                Item                  `xml:"cac:Item"`
+Price               Price                 `xml:"cac:Price"`
+TaxTotal            LineTaxTotal          `xml:"cac:TaxTotal"`
 }
 
 type Quantity struct {
@@ -100,7 +149,8 @@ TaxScheme TaxInfo `xml:"cac:TaxScheme"`
 }
 
 type Price struct {
-PriceAmount Amount `xml:"cbc:PriceAmount"`
+PriceAmount  Amount    `xml:"cbc:PriceAmount"`
+BaseQuantity *Quantity `xml:"cbc:BaseQuantity,omitempty"`
 }
 
 type LineTaxTotal struct {
@@ -108,7 +158,14 @@ TaxAmount Amount `xml:"cbc:TaxAmount"`
 }
 
 // BuildUBL marshals the draft into a minimal JP PINT aligned UBL XML.
-func BuildUBL(invoiceID string, draft InvoiceDraft, totals Totals) (string, error) {
+// Line amounts are taken from totals.Lines rather than recomputed, so the XML
+// reconciles with the validated totals whatever rounding mode produced them.
+// invoiceNumber is the tenant-facing number written to cbc:ID.
+func BuildUBL(invoiceNumber string, draft InvoiceDraft, totals Totals) (string, error) {
+if len(totals.Lines) != len(draft.Lines) {
+return "", fmt.Errorf("totals cover %d lines, draft has %d", len(totals.Lines), len(draft.Lines))
+}
+
 // Convert generated types to strings
 issueDateStr := draft.IssueDate.String()
 dueDateStr := draft.DueDate.String()
@@ -117,16 +174,18 @@ if draft.Notes != nil {
 notesStr = *draft.Notes
 }
 currencyStr := string(draft.Currency)
+taxExclusive := totals.Subtotal - totals.AllowanceTotal + totals.ChargeTotal
 supplierCountryStr := string(draft.Supplier.CountryCode)
 customerCountryStr := string(draft.Customer.CountryCode)
 
 ubl := UBLInvoice{
+XMLName:              xml.Name{Local: "Invoice"},
 Xmlns:                "urn:oasis:names:specification:ubl:schema:xsd:Invoice-2",
 Cbc:                  "urn:oasis:names:specification:ubl:schema:xsd:CommonBasicComponents-2",
 Cac:                  "urn:oasis:names:specification:ubl:schema:xsd:CommonAggregateComponents-2",
 CustomizationID:      "urn:jp:pint:invoice:1.0",
 ProfileID:            "urn:peppol:bis:billing:3",
-ID:                   invoiceID,
+ID:                   invoiceNumber,
 IssueDate:            issueDateStr,
 DueDate:              dueDateStr,
 InvoiceTypeCode:      "380",
@@ -165,23 +224,87 @@ TaxAmount: Amount{Currency: currencyStr, Value: totals.Tax},
 },
 LegalMonetaryTotal: MonetaryTotal{
 LineExtensionAmount: Amount{Currency: currencyStr, Value: totals.Subtotal},
-TaxExclusiveAmount:  Amount{Currency: currencyStr, Value: totals.Subtotal},
+TaxExclusiveAmount:  Amount{Currency: currencyStr, Value: taxExclusive},
 TaxInclusiveAmount:  Amount{Currency: currencyStr, Value: totals.GrandTotal},
-PayableAmount:       Amount{Currency: currencyStr, Value: totals.GrandTotal},
+PayableAmount:       Amount{Currency: currencyStr, Value: totals.Payable},
+},
+}
+
+// A UBL CreditNote has no top-level due date and uses its own root, type
+// code (381) and line elements.
+creditNote := isCreditNote(draft)
+if creditNote {
+ubl.XMLName = xml.Name{Local: "CreditNote"}
+ubl.Xmlns = "urn:oasis:names:specification:ubl:schema:xsd:CreditNote-2"
+ubl.DueDate = ""
+ubl.InvoiceTypeCode = ""
+ubl.CreditNoteTypeCode = "381"
+}
+
+if draft.PaymentMeans != nil {
+ubl.PaymentMeans = buildPaymentMeans(*draft.PaymentMeans)
+}
+
+if draft.AllowanceCharges != nil {
+for _, ac := range *draft.AllowanceCharges {
+doc := AllowanceChargeType{
+ChargeIndicator:       ac.ChargeIndicator,
+AllowanceChargeReason: ac.Reason,
+Amount:                Amount{Currency: currencyStr, Value: ac.Amount},
+}
+if ac.TaxCategory != nil {
+rate := 0.0
+if ac.TaxRate != nil {
+rate = *ac.TaxRate
+}
+doc.TaxCategory = &TaxCategory{ID: *ac.TaxCategory, Percent: rate * 100, TaxScheme: TaxInfo{ID: "VAT"}}
+}
+ubl.AllowanceCharge = append(ubl.AllowanceCharge, doc)
+}
+}
+if totals.AllowanceTotal > 0 {
+ubl.LegalMonetaryTotal.AllowanceTotalAmount = &Amount{Currency: currencyStr, Value: totals.AllowanceTotal}
+}
+if totals.ChargeTotal > 0 {
+ubl.LegalMonetaryTotal.ChargeTotalAmount = &Amount{Currency: currencyStr, Value: totals.ChargeTotal}
+}
+
+for _, b := range totals.ByCategory {
+ubl.TaxTotal.TaxSubtotal = append(ubl.TaxTotal.TaxSubtotal, TaxSubtotal{
+TaxableAmount: Amount{Currency: currencyStr, Value: b.TaxableAmount},
+TaxAmount:     Amount{Currency: currencyStr, Value: b.TaxAmount},
+TaxCategory: TaxCategory{
+ID:        b.TaxCategory,
+Percent:   b.TaxRate * 100,
+TaxScheme: TaxInfo{ID: "VAT"},
+},
+})
+}
+
+// Withholding tax is deducted from the payment, so it lowers PayableAmount
+// while TaxInclusiveAmount keeps the full consumption-tax-inclusive total.
+if totals.WithholdingTax != 0 {
+ubl.WithholdingTaxTotal = &TaxTotal{TaxAmount: Amount{Currency: currencyStr, Value: totals.WithholdingTax}}
+for _, b := range totals.Withholding {
+ubl.WithholdingTaxTotal.TaxSubtotal = append(ubl.WithholdingTaxTotal.TaxSubtotal, TaxSubtotal{
+TaxableAmount: Amount{Currency: currencyStr, Value: b.TaxableAmount},
+TaxAmount:     Amount{Currency: currencyStr, Value: b.TaxAmount},
+TaxCategory: TaxCategory{
+ID:        b.TaxCategory,
+Percent:   roundAmount(b.TaxRate*100, 4, RoundingHalfUp),
+TaxScheme: TaxInfo{ID: withholdingTaxCategory},
 },
+})
+}
 }
 
 for i, line := range draft.Lines {
-lineSubtotal := line.Quantity * line.UnitPrice
-lineTax := lineSubtotal * line.TaxRate
+lineSubtotal := totals.Lines[i].Subtotal
+lineTax := totals.Lines[i].Tax
 unitCodeStr := string(line.UnitCode)
 taxCategoryStr := string(line.TaxCategory)
-ubl.InvoiceLine = append(ubl.InvoiceLine, InvoiceLine{
+doc := InvoiceLine{
 ID: fmt.Sprintf("%d", i+1),
-InvoicedQuantity: Quantity{
-UnitCode: unitCodeStr,
-Value:    line.Quantity,
-},
 LineExtensionAmount: Amount{
 Currency: currencyStr,
 Value:    lineSubtotal,
@@ -200,8 +323,26 @@ PriceAmount: Amount{Currency: currencyStr, Value: line.UnitPrice},
 TaxTotal: LineTaxTotal{
 TaxAmount: Amount{Currency: currencyStr, Value: lineTax},
 },
+}
+if line.BaseQuantity != nil {
+doc.Price.BaseQuantity = &Quantity{UnitCode: unitCodeStr, Value: *line.BaseQuantity}
+}
+if discount := totals.Lines[i].Discount; discount != 0 {
+doc.AllowanceCharge = append(doc.AllowanceCharge, AllowanceChargeType{
+ChargeIndicator:       false,
+AllowanceChargeReason: "Discount",
+Amount:                Amount{Currency: currencyStr, Value: discount},
 })
 }
+quantity := &Quantity{UnitCode: unitCodeStr, Value: line.Quantity}
+if creditNote {
+doc.CreditedQuantity = quantity
+ubl.CreditNoteLine = append(ubl.CreditNoteLine, doc)
+} else {
+doc.InvoicedQuantity = quantity
+ubl.InvoiceLine = append(ubl.InvoiceLine, doc)
+}
+}
 
 output, err := xml.MarshalIndent(ubl, "", "  ")
 if err != nil {
@@ -209,3 +350,43 @@ return "", fmt.Errorf("marshal UBL: %w", err)
 }
 return xml.Header + string(output), nil
 }
+
+// buildPaymentMeans maps the draft's payment instructions to cac:PaymentMeans.
+// An IBAN takes precedence over a Japanese account as the account ID.
+func buildPaymentMeans(pm PaymentMeans) *PaymentMeansType {
+doc := &PaymentMeansType{PaymentMeansCode: pm.Code}
+if pm.DueDate != nil {
+doc.PaymentDueDate = pm.DueDate.String()
+}
+if pm.Reference != nil {
+doc.PaymentID = *pm.Reference
+}
+acct := pm.Account
+if acct == nil {
+return doc
+}
+account := &FinancialAccount{ID: stringValue(acct.AccountNumber), Name: stringValue(acct.AccountHolder)}
+if hasText(acct.Iban) {
+account.ID = *acct.Iban
+} else {
+if acct.AccountType != nil {
+account.AccountTypeCode = string(*acct.AccountType)
+}
+if hasText(acct.BranchName) || hasText(acct.BankName) {
+account.FinancialInstitutionBranch = &FinancialInstitutionBranch{Name: stringValue(acct.BranchName)}
+if hasText(acct.BankName) {
+account.FinancialInstitutionBranch.FinancialInstitution = &NameWrapper{Name: *acct.BankName}
+}
+}
+}
+doc.PayeeFinancialAccount = account
+return doc
+}
+
+// stringValue returns *s, or "" when s is nil.
+func stringValue(s *string) string {
+if s == nil {
+return ""
+}
+return *s
+}
@@ -0,0 +1,248 @@
+package pint
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"regexp"
+	"strings"
+)
+
+const (
+	xmlDSigNS        = "http://www.w3.org/2000/09/xmldsig#"
+	xmlDSigEnveloped = "http://www.w3.org/2000/09/xmldsig#enveloped-signature"
+	xmlDSigSHA256    = "http://www.w3.org/2001/04/xmlenc#sha256"
+	xmlDSigRSASHA256 = "http://www.w3.org/2001/04/xmldsig-more#rsa-sha256"
+	xmlDSigECSHA256  = "http://www.w3.org/2001/04/xmldsig-more#ecdsa-sha256"
+	xmlDSigC14N      = "http://www.w3.org/2001/10/xml-exc-c14n#"
+
+	// ublSignatureAnchor is the element the cac:Signature block is inserted
+	// before; UBL places cac:Signature ahead of the parties.
+	ublSignatureAnchor = "  <cac:AccountingSupplierParty>"
+)
+
+// ublSignatureBlock matches the cac:Signature block UBLSigner.Sign inserts,
+// including its indentation and trailing newline.
+var ublSignatureBlock = regexp.MustCompile(`(?s)  <cac:Signature>.*?</cac:Signature>\n`)
+
+// UBLSigner adds an enveloped XML-DSig signature to UBL built by BuildUBL.
+// BuildUBL's output is deterministic, so the digest is taken over the
+// document exactly as serialized: removing the cac:Signature block restores
+// the signed bytes.
+type UBLSigner struct {
+	key       crypto.Signer
+	cert      *x509.Certificate
+	algorithm string
+}
+
+// LoadUBLSigner reads cfg.UBLSigningKeyFile and cfg.UBLSigningCertFile. It
+// returns nil, nil when neither is configured, so issuance stays unsigned.
+func LoadUBLSigner(cfg Config) (*UBLSigner, error) {
+	if cfg.UBLSigningKeyFile == "" && cfg.UBLSigningCertFile == "" {
+		return nil, nil
+	}
+	if cfg.UBLSigningKeyFile == "" || cfg.UBLSigningCertFile == "" {
+		return nil, errors.New("UBL signing needs both a key and a certificate")
+	}
+	keyPEM, err := os.ReadFile(cfg.UBLSigningKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("read signing key: %w", err)
+	}
+	certPEM, err := os.ReadFile(cfg.UBLSigningCertFile)
+	if err != nil {
+		return nil, fmt.Errorf("read signing certificate: %w", err)
+	}
+	key, err := parseSigningKey(keyPEM)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		return nil, errors.New("signing certificate is not PEM")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse signing certificate: %w", err)
+	}
+	return NewUBLSigner(key, cert)
+}
+
+// NewUBLSigner signs with key, which must be an RSA or ECDSA key matching cert.
+func NewUBLSigner(key crypto.Signer, cert *x509.Certificate) (*UBLSigner, error) {
+	var algorithm string
+	switch key.Public().(type) {
+	case *rsa.PublicKey:
+		algorithm = xmlDSigRSASHA256
+	case *ecdsa.PublicKey:
+		algorithm = xmlDSigECSHA256
+	default:
+		return nil, fmt.Errorf("unsupported signing key type %T", key.Public())
+	}
+	if pub, ok := key.Public().(interface{ Equal(crypto.PublicKey) bool }); !ok || !pub.Equal(cert.PublicKey) {
+		return nil, errors.New("signing key does not match certificate")
+	}
+	return &UBLSigner{key: key, cert: cert, algorithm: algorithm}, nil
+}
+
+func parseSigningKey(keyPEM []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, errors.New("signing key is not PEM")
+	}
+	var key any
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse signing key: %w", err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported signing key type %T", key)
+	}
+	return signer, nil
+}
+
+// Sign returns xmlBody with a cac:Signature/ds:Signature block inserted and
+// the base64 SHA-256 digest of xmlBody that the signature covers.
+func (s *UBLSigner) Sign(xmlBody string) (string, string, error) {
+	if !strings.Contains(xmlBody, ublSignatureAnchor) {
+		return "", "", errors.New("UBL has no AccountingSupplierParty to anchor the signature")
+	}
+	sum := sha256.Sum256([]byte(xmlBody))
+	digest := base64.StdEncoding.EncodeToString(sum[:])
+	signedInfo := ublSignedInfo(s.algorithm, digest)
+	infoSum := sha256.Sum256([]byte(signedInfo))
+	sig, err := s.key.Sign(rand.Reader, infoSum[:], crypto.SHA256)
+	if err != nil {
+		return "", "", fmt.Errorf("sign UBL: %w", err)
+	}
+	if pub, ok := s.key.Public().(*ecdsa.PublicKey); ok {
+		if sig, err = ecdsaRawSignature(pub, sig); err != nil {
+			return "", "", err
+		}
+	}
+
+	var block strings.Builder
+	block.WriteString("  <cac:Signature>\n")
+	block.WriteString("    <cbc:ID>urn:jp:pint:signature</cbc:ID>\n")
+	block.WriteString(`    <ds:Signature xmlns:ds="` + xmlDSigNS + `">`)
+	block.WriteString(signedInfo)
+	block.WriteString("<ds:SignatureValue>" + base64.StdEncoding.EncodeToString(sig) + "</ds:SignatureValue>")
+	block.WriteString("<ds:KeyInfo><ds:X509Data><ds:X509Certificate>" + base64.StdEncoding.EncodeToString(s.cert.Raw) + "</ds:X509Certificate></ds:X509Data></ds:KeyInfo>")
+	block.WriteString("</ds:Signature>\n")
+	block.WriteString("  </cac:Signature>\n")
+
+	signed := strings.Replace(xmlBody, ublSignatureAnchor, block.String()+ublSignatureAnchor, 1)
+	return signed, digest, nil
+}
+
+// ecdsaRawSignature converts an ASN.1 ECDSA signature to the fixed-width r||s
+// form XML-DSig uses.
+func ecdsaRawSignature(pub *ecdsa.PublicKey, der []byte) ([]byte, error) {
+	var rs struct{ R, S *big.Int }
+	if _, err := asn1.Unmarshal(der, &rs); err != nil {
+		return nil, fmt.Errorf("decode ecdsa signature: %w", err)
+	}
+	size := (pub.Curve.Params().BitSize + 7) / 8
+	raw := make([]byte, 2*size)
+	rs.R.FillBytes(raw[:size])
+	rs.S.FillBytes(raw[size:])
+	return raw, nil
+}
+
+// ublSignedInfo renders the ds:SignedInfo that is signed. It is emitted and
+// re-derived byte for byte, so no canonicalization is needed to verify it.
+func ublSignedInfo(algorithm, digest string) string {
+	return `<ds:SignedInfo>` +
+		`<ds:CanonicalizationMethod Algorithm="` + xmlDSigC14N + `"/>` +
+		`<ds:SignatureMethod Algorithm="` + algorithm + `"/>` +
+		`<ds:Reference URI="">` +
+		`<ds:Transforms><ds:Transform Algorithm="` + xmlDSigEnveloped + `"/></ds:Transforms>` +
+		`<ds:DigestMethod Algorithm="` + xmlDSigSHA256 + `"/>` +
+		`<ds:DigestValue>` + digest + `</ds:DigestValue>` +
+		`</ds:Reference>` +
+		`</ds:SignedInfo>`
+}
+
+var (
+	ublSignatureMethod = regexp.MustCompile(`<ds:SignatureMethod Algorithm="([^"]+)"/>`)
+	ublDigestValue     = regexp.MustCompile(`<ds:DigestValue>([^<]+)</ds:DigestValue>`)
+	ublSignatureValue  = regexp.MustCompile(`<ds:SignatureValue>([^<]+)</ds:SignatureValue>`)
+	ublX509Certificate = regexp.MustCompile(`<ds:X509Certificate>([^<]+)</ds:X509Certificate>`)
+)
+
+// VerifyUBLSignature checks a document signed by UBLSigner: the digest must
+// match the document with its signature block removed, and the signature
+// over SignedInfo must verify against the embedded certificate. It returns
+// the certificate on success.
+func VerifyUBLSignature(signed []byte) (*x509.Certificate, error) {
+	block := ublSignatureBlock.Find(signed)
+	if block == nil {
+		return nil, errors.New("UBL is not signed")
+	}
+	field := func(re *regexp.Regexp) string {
+		if m := re.FindSubmatch(block); m != nil {
+			return string(m[1])
+		}
+		return ""
+	}
+	algorithm, digest := field(ublSignatureMethod), field(ublDigestValue)
+
+	unsigned := ublSignatureBlock.ReplaceAll(signed, nil)
+	sum := sha256.Sum256(unsigned)
+	if base64.StdEncoding.EncodeToString(sum[:]) != digest {
+		return nil, errors.New("UBL digest does not match the document")
+	}
+
+	certDER, err := base64.StdEncoding.DecodeString(field(ublX509Certificate))
+	if err != nil {
+		return nil, fmt.Errorf("decode certificate: %w", err)
+	}
+	cert, err := x509.ParseCertificate(certDER)
+	if err != nil {
+		return nil, fmt.Errorf("parse certificate: %w", err)
+	}
+	sig, err := base64.StdEncoding.DecodeString(field(ublSignatureValue))
+	if err != nil {
+		return nil, fmt.Errorf("decode signature: %w", err)
+	}
+	infoSum := sha256.Sum256([]byte(ublSignedInfo(algorithm, digest)))
+	switch pub := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		if algorithm != xmlDSigRSASHA256 {
+			return nil, fmt.Errorf("signature method %q does not match RSA certificate", algorithm)
+		}
+		err = rsa.VerifyPKCS1v15(pub, crypto.SHA256, infoSum[:], sig)
+	case *ecdsa.PublicKey:
+		if algorithm != xmlDSigECSHA256 {
+			return nil, fmt.Errorf("signature method %q does not match ECDSA certificate", algorithm)
+		}
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*size || !ecdsa.Verify(pub, infoSum[:], new(big.Int).SetBytes(sig[:size]), new(big.Int).SetBytes(sig[size:])) {
+			err = errors.New("ecdsa verification failed")
+		}
+	default:
+		err = fmt.Errorf("unsupported certificate key type %T", pub)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("UBL signature invalid: %w", err)
+	}
+	return cert, nil
+}
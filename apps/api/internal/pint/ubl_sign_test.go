@@ -0,0 +1,186 @@
+package pint
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeSigningFixture writes a PKCS#8 key and a self-signed certificate for it
+// into a temp dir and points cfg at them.
+func writeSigningFixture(t *testing.T, cfg *Config, key crypto.Signer) {
+	t.Helper()
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "pint test signer"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	cfg.UBLSigningKeyFile = filepath.Join(dir, "key.pem")
+	cfg.UBLSigningCertFile = filepath.Join(dir, "cert.pem")
+	if err := os.WriteFile(cfg.UBLSigningKeyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(cfg.UBLSigningCertFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER}), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUBLSigner_SignAndVerify(t *testing.T) {
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	draft := sampleDraft()
+	validation := Validator{Config: LoadConfig()}.Validate(draft)
+	xmlBody, err := BuildUBL("inv-1", draft, validation.Totals)
+	if err != nil {
+		t.Fatalf("BuildUBL: %v", err)
+	}
+
+	for name, key := range map[string]crypto.Signer{"ecdsa": ecKey, "rsa": rsaKey} {
+		cfg := LoadConfig()
+		writeSigningFixture(t, &cfg, key)
+		signer, err := LoadUBLSigner(cfg)
+		if err != nil || signer == nil {
+			t.Fatalf("%s: LoadUBLSigner: %v", name, err)
+		}
+		signed, digest, err := signer.Sign(xmlBody)
+		if err != nil {
+			t.Fatalf("%s: Sign: %v", name, err)
+		}
+		if !strings.Contains(signed, "<ds:DigestValue>"+digest+"</ds:DigestValue>") {
+			t.Fatalf("%s: expected digest %s in signed XML", name, digest)
+		}
+		cert, err := VerifyUBLSignature([]byte(signed))
+		if err != nil {
+			t.Fatalf("%s: expected signature to verify, got %v", name, err)
+		}
+		if cert.Subject.CommonName != "pint test signer" {
+			t.Fatalf("%s: unexpected certificate %v", name, cert.Subject)
+		}
+		if errs := ValidateUBL([]byte(signed)); errs != nil {
+			t.Fatalf("%s: expected signed UBL to stay valid, got %+v", name, errs)
+		}
+
+		tampered := strings.Replace(signed, "<cbc:Name>Bravo</cbc:Name>", "<cbc:Name>Mallory</cbc:Name>", 1)
+		if _, err := VerifyUBLSignature([]byte(tampered)); err == nil {
+			t.Fatalf("%s: expected tampered document to fail verification", name)
+		}
+	}
+}
+
+func TestLoadUBLSigner_Unconfigured(t *testing.T) {
+	if signer, err := LoadUBLSigner(LoadConfig()); signer != nil || err != nil {
+		t.Fatalf("expected no signer without configuration, got %v, %v", signer, err)
+	}
+	cfg := LoadConfig()
+	cfg.UBLSigningKeyFile = "/nonexistent/key.pem"
+	if _, err := LoadUBLSigner(cfg); err == nil {
+		t.Fatal("expected an error for a half-configured signer")
+	}
+}
+
+func TestIssueInvoice_StoresSignedVariant(t *testing.T) {
+	cfg := LoadConfig()
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	writeSigningFixture(t, &cfg, key)
+	svc, storage := newTestService(cfg)
+
+	body, _ := json.Marshal(sampleDraft())
+	w := httptest.NewRecorder()
+	svc.IssueInvoice(w, asTenant(newInvoiceRequest(http.MethodPost, "/invoices", body), "tenant-a"))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp map[string]any
+	_ = json.NewDecoder(w.Body).Decode(&resp)
+	id, _ := resp["invoiceId"].(string)
+	digest, _ := resp["signatureDigest"].(string)
+	if digest == "" || resp["signedXmlUrl"] == "" {
+		t.Fatalf("expected signature digest and signed XML URL, got %v", resp)
+	}
+
+	signed, _, err := storage.GetObject(context.Background(), "tenant-a/invoices/"+id+"/invoice.signed.xml")
+	if err != nil {
+		t.Fatalf("expected signed XML to be stored: %v", err)
+	}
+	if _, err := VerifyUBLSignature(signed); err != nil {
+		t.Fatalf("expected stored signature to verify: %v", err)
+	}
+	unsigned, _, _ := storage.GetObject(context.Background(), "tenant-a/invoices/"+id+"/invoice.xml")
+	if strings.Contains(string(unsigned), "ds:Signature") || !strings.Contains(string(signed), digest) {
+		t.Fatal("expected invoice.xml to stay unsigned and the signed copy to carry the digest")
+	}
+}
+
+func TestIssueInvoice_UnsignedOutputUnchanged(t *testing.T) {
+	svc, storage := newTestService(LoadConfig())
+	draft := sampleDraft()
+
+	body, _ := json.Marshal(draft)
+	w := httptest.NewRecorder()
+	svc.IssueInvoice(w, asTenant(newInvoiceRequest(http.MethodPost, "/invoices", body), "tenant-a"))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp map[string]any
+	_ = json.NewDecoder(w.Body).Decode(&resp)
+	id, _ := resp["invoiceId"].(string)
+	number, _ := resp["invoiceNumber"].(string)
+	if _, ok := resp["signatureDigest"]; ok {
+		t.Fatalf("expected no signature digest without a signing key, got %v", resp)
+	}
+
+	stored, _, err := storage.GetObject(context.Background(), "tenant-a/invoices/"+id+"/invoice.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	draft.InvoiceNumber = &number
+	want, err := BuildUBL(number, draft, Validator{Config: LoadConfig()}.Validate(draft).Totals)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(stored) != want {
+		t.Fatalf("expected stored XML to be exactly BuildUBL's output")
+	}
+	if _, _, err := storage.GetObject(context.Background(), "tenant-a/invoices/"+id+"/invoice.signed.xml"); err == nil {
+		t.Fatal("expected no signed variant without a signing key")
+	}
+}
+
+func TestIssueInvoice_MisconfiguredSignerFailsClosed(t *testing.T) {
+	cfg := LoadConfig()
+	cfg.UBLSigningKeyFile = "/nonexistent/key.pem"
+	cfg.UBLSigningCertFile = "/nonexistent/cert.pem"
+	svc, _ := newTestService(cfg)
+
+	body, _ := json.Marshal(sampleDraft())
+	w := httptest.NewRecorder()
+	svc.IssueInvoice(w, asTenant(newInvoiceRequest(http.MethodPost, "/invoices", body), "tenant-a"))
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500, got %d", w.Code)
+	}
+}
@@ -0,0 +1,387 @@
+package pint
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBuildUBL_UsesValidatedLineAmounts(t *testing.T) {
+	cfg := LoadConfig()
+	cfg.RoundingMode = RoundingHalfEven
+	draft := sampleDraft()
+	// 0.125 rounds to 0.12 under HALF_EVEN but 0.13 under HALF_UP.
+	draft.Lines[0].Quantity = 1
+	draft.Lines[0].UnitPrice = 0.125
+	draft.Lines = append(draft.Lines, LineItem{
+		Description: "Support",
+		Quantity:    3,
+		UnitCode:    HUR,
+		UnitPrice:   0.375,
+		TaxCategory: S,
+		TaxRate:     0.1,
+	})
+
+	validation := Validator{Config: cfg}.Validate(draft)
+	if !validation.Valid {
+		t.Fatalf("expected valid, got errors %+v", validation.Errors)
+	}
+	if got := validation.Totals.Lines[0].Subtotal; got != 0.12 {
+		t.Fatalf("expected HALF_EVEN line subtotal 0.12, got %v", got)
+	}
+
+	xmlBody, err := BuildUBL("inv-1", draft, validation.Totals)
+	if err != nil {
+		t.Fatalf("BuildUBL: %v", err)
+	}
+	var sum float64
+	for i, line := range validation.Totals.Lines {
+		for _, want := range []string{
+			fmt.Sprintf(`<cbc:ID>%d</cbc:ID>`, i+1),
+			fmt.Sprintf(`<cbc:LineExtensionAmount currencyID="JPY">%v</cbc:LineExtensionAmount>`, line.Subtotal),
+			fmt.Sprintf(`<cbc:TaxAmount currencyID="JPY">%v</cbc:TaxAmount>`, line.Tax),
+		} {
+			if !strings.Contains(xmlBody, want) {
+				t.Errorf("line %d: expected %s in UBL", i+1, want)
+			}
+		}
+		sum += line.Subtotal
+	}
+	if sum != validation.Totals.Subtotal {
+		t.Fatalf("line amounts sum to %v, validated subtotal is %v", sum, validation.Totals.Subtotal)
+	}
+}
+
+func TestBuildUBL_RejectsTotalsWithoutLines(t *testing.T) {
+	if _, err := BuildUBL("inv-1", sampleDraft(), Totals{}); err == nil {
+		t.Fatal("expected error when totals do not cover the draft lines")
+	}
+}
+
+func TestBuildUBL_UsesDraftCurrency(t *testing.T) {
+	cfg := LoadConfig()
+	cfg.SupportedCurrencies = []string{"USD"}
+	draft := sampleDraft()
+	draft.Currency = "USD"
+
+	validation := Validator{Config: cfg}.Validate(draft)
+	if !validation.Valid {
+		t.Fatalf("expected valid, got errors %+v", validation.Errors)
+	}
+	xmlBody, err := BuildUBL("inv-1", draft, validation.Totals)
+	if err != nil {
+		t.Fatalf("BuildUBL: %v", err)
+	}
+	if !strings.Contains(xmlBody, `<cbc:DocumentCurrencyCode>USD</cbc:DocumentCurrencyCode>`) {
+		t.Fatalf("expected USD document currency in UBL:\n%s", xmlBody)
+	}
+	if strings.Contains(xmlBody, `currencyID="JPY"`) {
+		t.Fatalf("expected every amount in USD:\n%s", xmlBody)
+	}
+}
+
+func TestFormatMoney(t *testing.T) {
+	for _, tc := range []struct {
+		currency string
+		want     string
+	}{
+		{"JPY", "¥1235"},
+		{"USD", "$1234.56"},
+		{"EUR", "€1234.56"},
+		{"CHF", "CHF 1234.56"},
+	} {
+		if got := formatMoney(1234.56, tc.currency); got != tc.want {
+			t.Errorf("formatMoney(1234.56, %s) = %q, want %q", tc.currency, got, tc.want)
+		}
+	}
+}
+
+func TestBuildUBL_TaxSubtotalPerCategory(t *testing.T) {
+	draft := sampleDraft()
+	draft.Lines = []LineItem{
+		{Description: "Consulting", Quantity: 2, UnitCode: HUR, UnitPrice: 1000, TaxCategory: S, TaxRate: 0.1},
+		{Description: "Export", Quantity: 1, UnitCode: EA, UnitPrice: 500, TaxCategory: E, TaxRate: 0},
+		{Description: "Support", Quantity: 1, UnitCode: HUR, UnitPrice: 300, TaxCategory: S, TaxRate: 0.1},
+	}
+
+	validation := Validator{Config: LoadConfig()}.Validate(draft)
+	if !validation.Valid {
+		t.Fatalf("expected valid, got errors %+v", validation.Errors)
+	}
+	want := []TaxBreakdown{
+		{TaxCategory: "S", TaxRate: 0.1, TaxableAmount: 2300, TaxAmount: 230},
+		{TaxCategory: "E", TaxRate: 0, TaxableAmount: 500, TaxAmount: 0},
+	}
+	if !reflect.DeepEqual(validation.Totals.ByCategory, want) {
+		t.Fatalf("ByCategory = %+v, want %+v", validation.Totals.ByCategory, want)
+	}
+
+	xmlBody, err := BuildUBL("inv-1", draft, validation.Totals)
+	if err != nil {
+		t.Fatalf("BuildUBL: %v", err)
+	}
+	if n := strings.Count(xmlBody, "<cac:TaxSubtotal>"); n != 2 {
+		t.Fatalf("expected 2 TaxSubtotal elements, got %d:\n%s", n, xmlBody)
+	}
+	for _, fragment := range []string{
+		`<cbc:TaxableAmount currencyID="JPY">2300</cbc:TaxableAmount>`,
+		`<cbc:TaxAmount currencyID="JPY">230</cbc:TaxAmount>`,
+		`<cbc:TaxableAmount currencyID="JPY">500</cbc:TaxableAmount>`,
+		`<cac:TaxCategory>`,
+	} {
+		if !strings.Contains(xmlBody, fragment) {
+			t.Errorf("expected %s in UBL", fragment)
+		}
+	}
+}
+
+func TestBuildUBL_AllowanceCharges(t *testing.T) {
+	category := "S"
+	rate := 0.1
+	draft := sampleDraft()
+	draft.AllowanceCharges = &[]AllowanceCharge{
+		{Amount: 1000, Reason: "Volume discount", TaxCategory: &category, TaxRate: &rate},
+		{ChargeIndicator: true, Amount: 500, Reason: "Shipping", TaxCategory: &category, TaxRate: &rate},
+	}
+
+	validation := Validator{Config: LoadConfig()}.Validate(draft)
+	if !validation.Valid {
+		t.Fatalf("expected valid, got errors %+v", validation.Errors)
+	}
+	xmlBody, err := BuildUBL("inv-1", draft, validation.Totals)
+	if err != nil {
+		t.Fatalf("BuildUBL: %v", err)
+	}
+	if n := strings.Count(xmlBody, "<cac:AllowanceCharge>"); n != 2 {
+		t.Fatalf("expected 2 AllowanceCharge elements, got %d:\n%s", n, xmlBody)
+	}
+	for _, fragment := range []string{
+		`<cbc:ChargeIndicator>false</cbc:ChargeIndicator>`,
+		`<cbc:AllowanceChargeReason>Volume discount</cbc:AllowanceChargeReason>`,
+		`<cbc:ChargeIndicator>true</cbc:ChargeIndicator>`,
+		`<cbc:TaxExclusiveAmount currencyID="JPY">11500</cbc:TaxExclusiveAmount>`,
+		`<cbc:AllowanceTotalAmount currencyID="JPY">1000</cbc:AllowanceTotalAmount>`,
+		`<cbc:ChargeTotalAmount currencyID="JPY">500</cbc:ChargeTotalAmount>`,
+		`<cbc:PayableAmount currencyID="JPY">12650</cbc:PayableAmount>`,
+		`<cbc:TaxableAmount currencyID="JPY">11500</cbc:TaxableAmount>`,
+	} {
+		if !strings.Contains(xmlBody, fragment) {
+			t.Errorf("expected %s in UBL", fragment)
+		}
+	}
+	if strings.Index(xmlBody, "<cac:AllowanceCharge>") > strings.Index(xmlBody, "<cac:TaxTotal>") {
+		t.Error("expected AllowanceCharge before TaxTotal")
+	}
+}
+
+func TestBuildUBL_LineDiscountAndBaseQuantity(t *testing.T) {
+	draft := sampleDraft()
+	draft.Lines[0].BaseQuantity = ptrFloat(2)
+	draft.Lines[0].DiscountPercent = ptrFloat(10)
+
+	validation := Validator{Config: LoadConfig()}.Validate(draft)
+	if !validation.Valid {
+		t.Fatalf("expected valid, got errors %+v", validation.Errors)
+	}
+	xmlBody, err := BuildUBL("inv-1", draft, validation.Totals)
+	if err != nil {
+		t.Fatalf("BuildUBL: %v", err)
+	}
+	for _, fragment := range []string{
+		`<cbc:LineExtensionAmount currencyID="JPY">5400</cbc:LineExtensionAmount>`,
+		`<cbc:Amount currencyID="JPY">600</cbc:Amount>`,
+		`<cbc:BaseQuantity unitCode="EA">2</cbc:BaseQuantity>`,
+	} {
+		if !strings.Contains(xmlBody, fragment) {
+			t.Errorf("expected %s in UBL:\n%s", fragment, xmlBody)
+		}
+	}
+	if errs := ValidateUBL([]byte(xmlBody)); errs != nil {
+		t.Fatalf("expected valid UBL, got %+v", errs)
+	}
+}
+
+func TestBuildUBL_OmitsAllowanceTotalsWithoutAllowanceCharges(t *testing.T) {
+	draft := sampleDraft()
+	validation := Validator{Config: LoadConfig()}.Validate(draft)
+	xmlBody, err := BuildUBL("inv-1", draft, validation.Totals)
+	if err != nil {
+		t.Fatalf("BuildUBL: %v", err)
+	}
+	if strings.Contains(xmlBody, "AllowanceTotalAmount") || strings.Contains(xmlBody, "ChargeTotalAmount") || strings.Contains(xmlBody, "<cac:AllowanceCharge>") {
+		t.Fatalf("expected no allowance/charge elements:\n%s", xmlBody)
+	}
+}
+
+func TestBuildUBL_CreditNote(t *testing.T) {
+	draft := sampleCreditNote()
+	validation := Validator{Config: LoadConfig()}.Validate(draft)
+	if !validation.Valid {
+		t.Fatalf("expected valid, got errors %+v", validation.Errors)
+	}
+	xmlBody, err := BuildUBL("cn-1", draft, validation.Totals)
+	if err != nil {
+		t.Fatalf("BuildUBL: %v", err)
+	}
+	for _, want := range []string{
+		`<CreditNote xmlns="urn:oasis:names:specification:ubl:schema:xsd:CreditNote-2"`,
+		`<cbc:CreditNoteTypeCode>381</cbc:CreditNoteTypeCode>`,
+		`<cac:CreditNoteLine>`,
+		`<cbc:CreditedQuantity unitCode="EA">10</cbc:CreditedQuantity>`,
+		`<cbc:PayableAmount currencyID="JPY">-13200</cbc:PayableAmount>`,
+	} {
+		if !strings.Contains(xmlBody, want) {
+			t.Errorf("expected %s in UBL:\n%s", want, xmlBody)
+		}
+	}
+	for _, unwanted := range []string{"InvoiceTypeCode", "<cac:InvoiceLine>", "InvoicedQuantity", "<cbc:DueDate>"} {
+		if strings.Contains(xmlBody, unwanted) {
+			t.Errorf("unexpected %s in credit note UBL", unwanted)
+		}
+	}
+}
+
+func TestBuildUBL_InvoiceTypeCode(t *testing.T) {
+	draft := sampleDraft()
+	validation := Validator{Config: LoadConfig()}.Validate(draft)
+	xmlBody, err := BuildUBL("inv-1", draft, validation.Totals)
+	if err != nil {
+		t.Fatalf("BuildUBL: %v", err)
+	}
+	if !strings.Contains(xmlBody, `<Invoice xmlns="urn:oasis:names:specification:ubl:schema:xsd:Invoice-2"`) || !strings.Contains(xmlBody, `<cbc:InvoiceTypeCode>380</cbc:InvoiceTypeCode>`) {
+		t.Fatalf("expected Invoice root with type code 380:\n%s", xmlBody)
+	}
+}
+
+func TestValidateUBL_AcceptsBuiltDocuments(t *testing.T) {
+	category, rate := "S", 0.1
+	allowances := sampleDraft()
+	allowances.AllowanceCharges = &[]AllowanceCharge{{Amount: 500, Reason: "Discount", TaxCategory: &category, TaxRate: &rate}}
+	for name, draft := range map[string]InvoiceDraft{
+		"invoice":    sampleDraft(),
+		"allowances": allowances,
+		"creditNote": sampleCreditNote(),
+	} {
+		validation := Validator{Config: LoadConfig()}.Validate(draft)
+		xmlBody, err := BuildUBL("inv-1", draft, validation.Totals)
+		if err != nil {
+			t.Fatalf("%s: BuildUBL: %v", name, err)
+		}
+		if errs := ValidateUBL([]byte(xmlBody)); errs != nil {
+			t.Fatalf("%s: expected valid UBL, got %+v", name, errs)
+		}
+	}
+}
+
+func TestValidateUBL_RejectsInconsistentTotals(t *testing.T) {
+	draft := sampleDraft()
+	totals := Validator{Config: LoadConfig()}.Validate(draft).Totals
+	totals.GrandTotal++
+	totals.Payable++
+	xmlBody, err := BuildUBL("inv-1", draft, totals)
+	if err != nil {
+		t.Fatalf("BuildUBL: %v", err)
+	}
+	errs := ValidateUBL([]byte(xmlBody))
+	if len(errs) != 1 || errs[0].Code != "JP-PINT-UBL-004" || errs[0].Path != "Invoice/LegalMonetaryTotal/TaxInclusiveAmount" {
+		t.Fatalf("expected a TaxInclusiveAmount mismatch, got %+v", errs)
+	}
+}
+
+func TestValidateUBL_RejectsMissingAndMisorderedElements(t *testing.T) {
+	draft := sampleDraft()
+	validation := Validator{Config: LoadConfig()}.Validate(draft)
+	xmlBody, err := BuildUBL("inv-1", draft, validation.Totals)
+	if err != nil {
+		t.Fatalf("BuildUBL: %v", err)
+	}
+
+	missing := strings.Replace(xmlBody, "<cbc:ProfileID>urn:peppol:bis:billing:3</cbc:ProfileID>", "", 1)
+	if errs := ValidateUBL([]byte(missing)); len(errs) != 1 || errs[0].Code != "JP-PINT-UBL-002" {
+		t.Fatalf("expected missing ProfileID, got %+v", errs)
+	}
+
+	customization := "<cbc:CustomizationID>urn:jp:pint:invoice:1.0</cbc:CustomizationID>"
+	misordered := strings.Replace(xmlBody, customization, "", 1)
+	misordered = strings.Replace(misordered, "</Invoice>", customization+"</Invoice>", 1)
+	if errs := ValidateUBL([]byte(misordered)); len(errs) == 0 || errs[0].Code != "JP-PINT-UBL-003" {
+		t.Fatalf("expected out-of-order element, got %+v", errs)
+	}
+
+	if errs := ValidateUBL([]byte("<Invoice>")); len(errs) != 1 || errs[0].Code != "JP-PINT-UBL-001" {
+		t.Fatalf("expected malformed XML error, got %+v", errs)
+	}
+}
+
+func TestBuildUBL_PaymentMeans(t *testing.T) {
+	draft := sampleDraft()
+	draft.PaymentMeans = samplePaymentMeans()
+	validation := Validator{Config: LoadConfig()}.Validate(draft)
+	xmlBody, err := BuildUBL("inv-1", draft, validation.Totals)
+	if err != nil {
+		t.Fatalf("BuildUBL: %v", err)
+	}
+	for _, want := range []string{
+		`<cac:PaymentMeans>`,
+		`<cbc:PaymentMeansCode>30</cbc:PaymentMeansCode>`,
+		`<cbc:PaymentDueDate>2024-04-30</cbc:PaymentDueDate>`,
+		`<cbc:PaymentID>INV-2024-000001</cbc:PaymentID>`,
+		`<cbc:ID>1234567</cbc:ID>`,
+		`<cbc:AccountTypeCode>ordinary</cbc:AccountTypeCode>`,
+		`<cbc:Name>東京営業部</cbc:Name>`,
+		`<cac:FinancialInstitution>`,
+	} {
+		if !strings.Contains(xmlBody, want) {
+			t.Errorf("expected %s in UBL:\n%s", want, xmlBody)
+		}
+	}
+	customer := strings.Index(xmlBody, "<cac:AccountingCustomerParty>")
+	payment := strings.Index(xmlBody, "<cac:PaymentMeans>")
+	taxTotal := strings.Index(xmlBody, "<cac:TaxTotal>")
+	if !(customer < payment && payment < taxTotal) {
+		t.Fatalf("expected cac:PaymentMeans between the customer party and cac:TaxTotal")
+	}
+	if errs := ValidateUBL([]byte(xmlBody)); errs != nil {
+		t.Fatalf("expected UBL with payment means to validate, got %+v", errs)
+	}
+
+	draft.PaymentMeans = nil
+	xmlBody, _ = BuildUBL("inv-1", draft, validation.Totals)
+	if strings.Contains(xmlBody, "PaymentMeans") {
+		t.Fatal("expected no cac:PaymentMeans without payment instructions")
+	}
+}
+
+func TestBuildUBL_WithholdingTax(t *testing.T) {
+	draft := sampleDraft()
+	rate := 0.1021
+	draft.WithholdingRate = &rate
+	validation := Validator{Config: LoadConfig()}.Validate(draft)
+	if !validation.Valid {
+		t.Fatalf("expected valid, got errors %+v", validation.Errors)
+	}
+	xmlBody, err := BuildUBL("inv-1", draft, validation.Totals)
+	if err != nil {
+		t.Fatalf("BuildUBL: %v", err)
+	}
+	for _, want := range []string{
+		`<cac:WithholdingTaxTotal>`,
+		`<cbc:TaxAmount currencyID="JPY">1225.2</cbc:TaxAmount>`,
+		`<cbc:Percent>10.21</cbc:Percent>`,
+		`<cbc:TaxInclusiveAmount currencyID="JPY">13200</cbc:TaxInclusiveAmount>`,
+		`<cbc:PayableAmount currencyID="JPY">11974.8</cbc:PayableAmount>`,
+	} {
+		if !strings.Contains(xmlBody, want) {
+			t.Errorf("expected %s in UBL:\n%s", want, xmlBody)
+		}
+	}
+	if errs := ValidateUBL([]byte(xmlBody)); errs != nil {
+		t.Fatalf("expected UBL with withholding tax to validate, got %+v", errs)
+	}
+
+	tampered := strings.Replace(xmlBody, "11974.8</cbc:PayableAmount>", "13200</cbc:PayableAmount>", 1)
+	if errs := ValidateUBL([]byte(tampered)); len(errs) != 1 || errs[0].Path != "Invoice/LegalMonetaryTotal/PayableAmount" {
+		t.Fatalf("expected a PayableAmount mismatch, got %+v", errs)
+	}
+}
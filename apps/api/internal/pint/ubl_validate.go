@@ -0,0 +1,165 @@
+package pint
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+
+	"github.com/yourorg/yourapp/apps/api/internal/rules"
+)
+
+// ublElementOrder lists the top-level elements ValidateUBL requires, in the
+// order UBL 2.1 prescribes. Alternatives share a slot: a document carries an
+// InvoiceTypeCode or a CreditNoteTypeCode, never both.
+var ublElementOrder = [][]string{
+	{"CustomizationID"},
+	{"ProfileID"},
+	{"ID"},
+	{"IssueDate"},
+	{"InvoiceTypeCode", "CreditNoteTypeCode"},
+	{"DocumentCurrencyCode"},
+	{"AccountingSupplierParty"},
+	{"AccountingCustomerParty"},
+	{"TaxTotal"},
+	{"LegalMonetaryTotal"},
+	{"InvoiceLine", "CreditNoteLine"},
+}
+
+// ublTotalsDoc picks the amounts ValidateUBL reconciles out of a UBL document.
+// Tags use local names only so cbc:/cac: prefixes match whatever namespace
+// they are bound to.
+type ublTotalsDoc struct {
+	TaxTotal []struct {
+		TaxAmount float64 `xml:"TaxAmount"`
+	} `xml:"TaxTotal"`
+	WithholdingTaxTotal struct {
+		TaxAmount float64 `xml:"TaxAmount"`
+	} `xml:"WithholdingTaxTotal"`
+	LegalMonetaryTotal struct {
+		LineExtensionAmount  float64 `xml:"LineExtensionAmount"`
+		TaxExclusiveAmount   float64 `xml:"TaxExclusiveAmount"`
+		TaxInclusiveAmount   float64 `xml:"TaxInclusiveAmount"`
+		AllowanceTotalAmount float64 `xml:"AllowanceTotalAmount"`
+		ChargeTotalAmount    float64 `xml:"ChargeTotalAmount"`
+		PayableAmount        float64 `xml:"PayableAmount"`
+	} `xml:"LegalMonetaryTotal"`
+	InvoiceLine []struct {
+		LineExtensionAmount float64 `xml:"LineExtensionAmount"`
+	} `xml:"InvoiceLine"`
+	CreditNoteLine []struct {
+		LineExtensionAmount float64 `xml:"LineExtensionAmount"`
+	} `xml:"CreditNoteLine"`
+}
+
+// ValidateUBL checks a generated UBL document before it leaves the service:
+// the root is Invoice or CreditNote, the required top-level elements are
+// present and in schema order, and the monetary totals reconcile with each
+// other and with the lines. It returns nil for a passing document.
+func ValidateUBL(doc []byte) []ValidationErrorItem {
+	root, children, err := ublTopLevelElements(doc)
+	if err != nil {
+		return []ValidationErrorItem{errItemf(rules.PintUBL001, "", "not well-formed: %v", err)}
+	}
+	if root != "Invoice" && root != "CreditNote" {
+		return []ValidationErrorItem{errItemf(rules.PintUBL001, root, "root must be Invoice or CreditNote")}
+	}
+
+	var errs []ValidationErrorItem
+	last := -1
+	for _, slot := range ublElementOrder {
+		at := -1
+		for _, name := range slot {
+			if i := indexOf(children, name); i >= 0 && (at < 0 || i < at) {
+				at = i
+			}
+		}
+		path := root + "/" + slot[0]
+		switch {
+		case at < 0:
+			errs = append(errs, errItemf(rules.PintUBL002, path, "%s", slot[0]))
+		case at < last:
+			errs = append(errs, errItemf(rules.PintUBL003, path, "%s", children[at]))
+		default:
+			last = at
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+
+	var totals ublTotalsDoc
+	if err := xml.Unmarshal(doc, &totals); err != nil {
+		return []ValidationErrorItem{errItemf(rules.PintUBL001, root, "amounts could not be read: %v", err)}
+	}
+	monetary := totals.LegalMonetaryTotal
+	taxAmount := totals.TaxTotal[0].TaxAmount
+	if exceedsDelta(monetary.TaxInclusiveAmount, monetary.TaxExclusiveAmount+taxAmount, 0) {
+		errs = append(errs, errItemf(rules.PintUBL004, root+"/LegalMonetaryTotal/TaxInclusiveAmount",
+			"TaxInclusiveAmount %v does not equal TaxExclusiveAmount %v + TaxAmount %v", monetary.TaxInclusiveAmount, monetary.TaxExclusiveAmount, taxAmount))
+	}
+	if exceedsDelta(monetary.TaxExclusiveAmount, monetary.LineExtensionAmount-monetary.AllowanceTotalAmount+monetary.ChargeTotalAmount, 0) {
+		errs = append(errs, errItemf(rules.PintUBL004, root+"/LegalMonetaryTotal/TaxExclusiveAmount",
+			"TaxExclusiveAmount %v does not equal LineExtensionAmount %v - AllowanceTotalAmount %v + ChargeTotalAmount %v", monetary.TaxExclusiveAmount, monetary.LineExtensionAmount, monetary.AllowanceTotalAmount, monetary.ChargeTotalAmount))
+	}
+	withholding := totals.WithholdingTaxTotal.TaxAmount
+	if exceedsDelta(monetary.PayableAmount, monetary.TaxInclusiveAmount-withholding, 0) {
+		errs = append(errs, errItemf(rules.PintUBL004, root+"/LegalMonetaryTotal/PayableAmount",
+			"PayableAmount %v does not equal TaxInclusiveAmount %v - WithholdingTaxTotal %v", monetary.PayableAmount, monetary.TaxInclusiveAmount, withholding))
+	}
+	var lineSum float64
+	for _, line := range totals.InvoiceLine {
+		lineSum += line.LineExtensionAmount
+	}
+	for _, line := range totals.CreditNoteLine {
+		lineSum += line.LineExtensionAmount
+	}
+	if exceedsDelta(monetary.LineExtensionAmount, lineSum, 0) {
+		errs = append(errs, errItemf(rules.PintUBL004, root+"/LegalMonetaryTotal/LineExtensionAmount",
+			"LineExtensionAmount %v does not equal the line sum %v", monetary.LineExtensionAmount, lineSum))
+	}
+	return errs
+}
+
+// ublTopLevelElements returns the root element's local name and the local
+// names of its direct children in document order.
+func ublTopLevelElements(doc []byte) (string, []string, error) {
+	dec := xml.NewDecoder(bytes.NewReader(doc))
+	var root string
+	var children []string
+	depth := 0
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return "", nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch depth {
+			case 0:
+				root = t.Name.Local
+			case 1:
+				children = append(children, t.Name.Local)
+			}
+			depth++
+		case xml.EndElement:
+			depth--
+		}
+	}
+	if root == "" {
+		return "", nil, errors.New("no root element")
+	}
+	return root, children, nil
+}
+
+func indexOf(list []string, value string) int {
+	for i, v := range list {
+		if v == value {
+			return i
+		}
+	}
+	return -1
+}
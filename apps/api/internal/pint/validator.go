@@ -3,109 +3,407 @@ package pint
 import (
 "fmt"
 "math"
+"math/big"
+"strconv"
 "strings"
 "time"
 
 openapi_types "github.com/oapi-codegen/runtime/types"
+"github.com/yourorg/yourapp/apps/api/internal/rules"
 )
 
 type Validator struct {
 Config Config
+// Plan selects a per-plan override of Config.MaxGrandTotal; empty uses the default.
+Plan string
 }
 
 func (v Validator) Validate(draft InvoiceDraft) ValidationResult {
+return v.ValidateStream(draft, nil)
+}
+
+// ValidateStream validates draft like Validate, additionally passing each error
+// to emit as soon as it is found (header checks first, then line by line) so
+// callers can surface problems in large drafts before validation finishes.
+func (v Validator) ValidateStream(draft InvoiceDraft, emit func(ValidationErrorItem)) ValidationResult {
 errors := make([]ValidationErrorItem, 0)
+add := func(item ValidationErrorItem) {
+errors = append(errors, item)
+if emit != nil {
+emit(item)
+}
+}
 
 if draft.Supplier.Name == "" || draft.Customer.Name == "" {
-errors = append(errors, errItem("JP-PINT-REQ-001", "supplier.name/customer.name", "Supplier and customer names are required"))
+add(errItem(rules.PintReq001, "supplier.name/customer.name"))
 }
+v.validateParty("supplier", draft.Supplier, add)
+v.validateParty("customer", draft.Customer, add)
 
 // Validate dates - IssueDate and DueDate are openapi_types.Date
 issueDateStr := draft.IssueDate.String()
 dueDateStr := draft.DueDate.String()
 if issueDateStr == "0001-01-01" || dueDateStr == "0001-01-01" {
-errors = append(errors, errItem("JP-PINT-REQ-002", "issueDate/dueDate", "Issue and due dates are required"))
+add(errItem(rules.PintReq002, "issueDate/dueDate"))
 }
 
+// Credit notes reverse an earlier invoice: their lines may carry negative
+// amounts and their due date may precede the issue date.
+creditNote := isCreditNote(draft)
+
 issue := dateToTime(draft.IssueDate)
 due := dateToTime(draft.DueDate)
-if !issue.IsZero() && !due.IsZero() && due.Before(issue) {
-errors = append(errors, errItem("JP-PINT-MATH-002", "dueDate", "Due date must be on or after issue date"))
+if !creditNote && !issue.IsZero() && !due.IsZero() && due.Before(issue) {
+add(errItem(rules.PintMath002, "dueDate"))
 }
 
-if draft.Currency != JPY {
-errors = append(errors, errItem("JP-PINT-REQ-005", "currency", "Only JPY is supported in this version"))
+if !contains(v.Config.SupportedCurrencies, draft.Currency) {
+add(errItemf(rules.PintReq005, "currency", "%q (supported: %s)", draft.Currency, strings.Join(v.Config.SupportedCurrencies, ", ")))
+}
+
+if draft.PaymentMeans != nil {
+v.validatePaymentMeans(*draft.PaymentMeans, issue, add)
 }
 
 if len(draft.Lines) == 0 {
-errors = append(errors, errItem("JP-PINT-REQ-006", "lines", "At least one line item is required"))
+add(errItem(rules.PintReq006, "lines"))
 }
 if len(draft.Lines) > v.Config.MaxLines {
-errors = append(errors, errItem("JP-PINT-LIMIT-001", "lines", fmt.Sprintf("Too many lines (max %d)", v.Config.MaxLines)))
+add(errItemf(rules.PintLimit001, "lines", "max %d", v.Config.MaxLines))
+}
+if draft.WithholdingRate != nil && (*draft.WithholdingRate < 0 || *draft.WithholdingRate > 1) {
+add(errItem(rules.PintMath013, "withholdingRate"))
 }
 
-var subtotal, taxTotal float64
+// Withholding tax (源泉徴収) is taken from each line's tax-exclusive amount at
+// the line's rate, or the document rate when the line has none.
+var subtotal, taxTotal, withholdingTotal float64
+lineTotals := make([]LineTotals, 0, len(draft.Lines))
+byCategory := make([]TaxBreakdown, 0)
+var withholdingByRate []TaxBreakdown
 for i, line := range draft.Lines {
 path := fmt.Sprintf("lines[%d]", i)
 if strings.TrimSpace(line.Description) == "" {
-errors = append(errors, errItem("JP-PINT-REQ-007", path+".description", "Description is required"))
+add(errItem(rules.PintReq007, path+".description"))
 }
 if len(line.Description) > v.Config.MaxDescription {
-errors = append(errors, errItem("JP-PINT-LIMIT-002", path+".description", "Description too long"))
+add(errItemf(rules.PintLimit002, path+".description", "max %d characters", v.Config.MaxDescription))
 }
 if line.Quantity <= 0 {
-errors = append(errors, errItem("JP-PINT-MATH-003", path+".quantity", "Quantity must be positive"))
+add(errItem(rules.PintMath003, path+".quantity"))
 }
-if line.UnitPrice < 0 {
-errors = append(errors, errItem("JP-PINT-MATH-004", path+".unitPrice", "Unit price must be non-negative"))
+if line.UnitPrice < 0 && !creditNote {
+add(errItem(rules.PintMath004, path+".unitPrice"))
 }
 if !contains(v.Config.ValidUnitCodes, string(line.UnitCode)) {
-errors = append(errors, errItem("JP-PINT-CODE-001", path+".unitCode", "Invalid unit code"))
+add(errItemf(rules.PintCode001, path+".unitCode", "%q", line.UnitCode))
 }
 if !contains(v.Config.ValidTaxCategory, string(line.TaxCategory)) {
-errors = append(errors, errItem("JP-PINT-CODE-002", path+".taxCategory", "Invalid tax category"))
+add(errItemf(rules.PintCode002, path+".taxCategory", "%q", line.TaxCategory))
 }
 if line.TaxRate < 0 || line.TaxRate > 1 {
-errors = append(errors, errItem("JP-PINT-MATH-005", path+".taxRate", "Tax rate must be between 0 and 1"))
+add(errItem(rules.PintMath005, path+".taxRate"))
+}
+if line.WithholdingRate != nil && (*line.WithholdingRate < 0 || *line.WithholdingRate > 1) {
+add(errItem(rules.PintMath013, path+".withholdingRate"))
+}
+if line.Currency != nil && *line.Currency != draft.Currency {
+add(errItemf(rules.PintReq008, path+".currency", "line %s, invoice %s", *line.Currency, draft.Currency))
 }
 
-lineSubtotal := round(line.Quantity*line.UnitPrice, 2)
-lineTax := round(lineSubtotal*line.TaxRate, 2)
+// The unit price covers BaseQuantity units (e.g. a per-dozen price), and
+// line discounts come off the gross amount before tax. A credit-note line
+// with a negative gross takes the discount with the same sign so it still
+// shrinks the line.
+baseQuantity := 1.0
+if line.BaseQuantity != nil {
+if *line.BaseQuantity <= 0 {
+add(errItem(rules.PintMath003, path+".baseQuantity"))
+} else {
+baseQuantity = *line.BaseQuantity
+}
+}
+gross := v.round(line.Quantity * line.UnitPrice / baseQuantity)
+var discount float64
+if line.DiscountAmount != nil {
+if *line.DiscountAmount < 0 {
+add(errItem(rules.PintMath012, path+".discountAmount"))
+}
+if gross < 0 {
+discount -= *line.DiscountAmount
+} else {
+discount += *line.DiscountAmount
+}
+}
+if line.DiscountPercent != nil {
+if *line.DiscountPercent < 0 || *line.DiscountPercent > 100 {
+add(errItem(rules.PintMath015, path+".discountPercent"))
+}
+discount += v.round(gross * *line.DiscountPercent / 100)
+}
+discount = v.round(discount)
+if math.Abs(discount) > math.Abs(gross) {
+add(errItemf(rules.PintMath014, path, "discount %.2f, gross %.2f", discount, gross))
+}
+
+lineSubtotal := v.round(gross - discount)
+lineTax := v.round(lineSubtotal * line.TaxRate)
 subtotal += lineSubtotal
 taxTotal += lineTax
+lineTotals = append(lineTotals, LineTotals{Subtotal: lineSubtotal, Tax: lineTax, Discount: discount})
+byCategory = addTaxBreakdown(byCategory, string(line.TaxCategory), line.TaxRate, lineSubtotal, lineTax)
+
+withholdingRate := line.WithholdingRate
+if withholdingRate == nil {
+withholdingRate = draft.WithholdingRate
+}
+if withholdingRate != nil && *withholdingRate > 0 {
+lineWithholding := v.round(lineSubtotal * *withholdingRate)
+withholdingTotal += lineWithholding
+withholdingByRate = addTaxBreakdown(withholdingByRate, withholdingTaxCategory, *withholdingRate, lineSubtotal, lineWithholding)
+}
+}
+
+// Document-level allowances reduce and charges increase the taxable amount of
+// their tax category; without a category they carry no tax.
+var allowanceTotal, chargeTotal float64
+if draft.AllowanceCharges != nil {
+for i, ac := range *draft.AllowanceCharges {
+path := fmt.Sprintf("allowanceCharges[%d]", i)
+if strings.TrimSpace(ac.Reason) == "" {
+add(errItem(rules.PintReq009, path+".reason"))
+}
+if ac.Amount < 0 {
+add(errItem(rules.PintMath012, path+".amount"))
+}
+amount, sign := v.round(ac.Amount), -1.0
+if ac.ChargeIndicator {
+chargeTotal += amount
+sign = 1
+} else {
+allowanceTotal += amount
+}
+if ac.TaxCategory == nil {
+continue
+}
+if !contains(v.Config.ValidTaxCategory, *ac.TaxCategory) {
+add(errItemf(rules.PintCode002, path+".taxCategory", "%q", *ac.TaxCategory))
+}
+rate := 0.0
+if ac.TaxRate != nil {
+rate = *ac.TaxRate
+}
+if rate < 0 || rate > 1 {
+add(errItem(rules.PintMath005, path+".taxRate"))
+}
+tax := v.round(amount * rate)
+taxTotal += sign * tax
+byCategory = addTaxBreakdown(byCategory, *ac.TaxCategory, rate, sign*amount, sign*tax)
+}
+}
+if allowanceTotal > math.Abs(subtotal) {
+add(errItemf(rules.PintMath011, "allowanceCharges", "allowances %.2f, subtotal %.2f", allowanceTotal, subtotal))
 }
 
-grandTotal := round(subtotal+taxTotal, 2)
+grandTotal := v.round(subtotal - allowanceTotal + chargeTotal + taxTotal)
+if draft.DeclaredGrandTotal != nil && exceedsDelta(*draft.DeclaredGrandTotal, grandTotal, v.Config.AllowedDelta) {
+add(errItemf(rules.PintMath010, "declaredGrandTotal", "declared %.2f, computed %.2f, allowed delta %.2f", *draft.DeclaredGrandTotal, grandTotal, v.Config.AllowedDelta))
+}
+if limit := v.maxGrandTotal(); limit > 0 && grandTotal > limit {
+add(errItemf(rules.PintLimit006, "totals.grandTotal", "%.2f exceeds %.2f", grandTotal, limit))
+}
 
 result := ValidationResult{
 Valid:  len(errors) == 0,
 Errors: errors,
 Totals: Totals{
-Subtotal:   subtotal,
-Tax:        taxTotal,
-GrandTotal: grandTotal,
+Subtotal:       subtotal,
+AllowanceTotal: allowanceTotal,
+ChargeTotal:    chargeTotal,
+Tax:            taxTotal,
+GrandTotal:     grandTotal,
+WithholdingTax: withholdingTotal,
+Payable:        v.round(grandTotal - withholdingTotal),
+ByCategory:     byCategory,
+Withholding:    withholdingByRate,
+Lines:          lineTotals,
 },
 }
 return result
 }
 
-func errItem(ruleID, path, message string) ValidationErrorItem {
+// withholdingTaxCategory labels withholding tax entries in
+// Totals.Withholding and the UBL cac:WithholdingTaxTotal.
+const withholdingTaxCategory = "WHT"
+
+// bankTransferMeans are the payment means codes that need a payee account.
+var bankTransferMeans = []string{"30", "42", "58"}
+
+// validatePaymentMeans checks the payment instructions: a known means code, a
+// complete payee account for bank transfers (an IBAN, or bank, branch, account
+// number and holder for a Japanese account) and a due date no earlier than
+// the issue date.
+func (v Validator) validatePaymentMeans(pm PaymentMeans, issue time.Time, add func(ValidationErrorItem)) {
+if !contains(v.Config.ValidPaymentMeans, pm.Code) {
+add(errItemf(rules.PintCode003, "paymentMeans.code", "%q", pm.Code))
+}
+if contains(bankTransferMeans, pm.Code) {
+switch {
+case pm.Account == nil:
+add(errItemf(rules.PintReq010, "paymentMeans.account", "payment means code %s", pm.Code))
+case !hasText(pm.Account.Iban):
+fields := []struct {
+name  string
+value *string
+}{
+{"bankName", pm.Account.BankName},
+{"branchName", pm.Account.BranchName},
+{"accountNumber", pm.Account.AccountNumber},
+{"accountHolder", pm.Account.AccountHolder},
+}
+for _, f := range fields {
+if !hasText(f.value) {
+add(errItem(rules.PintReq010, "paymentMeans.account."+f.name))
+}
+}
+}
+}
+if pm.DueDate != nil && !issue.IsZero() && dateToTime(*pm.DueDate).Before(issue) {
+add(errItem(rules.PintMath002, "paymentMeans.dueDate"))
+}
+}
+
+// validateParty checks the registration number and postal code of a party
+// whose country is in Config.StrictPartyCountries.
+func (v Validator) validateParty(path string, party Party, add func(ValidationErrorItem)) {
+if !contains(v.Config.StrictPartyCountries, string(party.CountryCode)) {
+return
+}
+if !isRegistrationNumber(party.TaxId) {
+add(errItemf(rules.PintCode004, path+".taxId", "%q", party.TaxId))
+}
+if len(party.Postal) != 7 || !allDigits(party.Postal) {
+add(errItemf(rules.PintCode005, path+".postal", "%q", party.Postal))
+}
+}
+
+// isRegistrationNumber reports whether s is a qualified-invoice registration
+// number (適格請求書発行事業者登録番号): T followed by 13 digits.
+func isRegistrationNumber(s string) bool {
+digits, ok := strings.CutPrefix(s, "T")
+return ok && len(digits) == 13 && allDigits(digits)
+}
+
+func allDigits(s string) bool {
+for _, r := range s {
+if r < '0' || r > '9' {
+return false
+}
+}
+return true
+}
+
+// hasText reports whether s is set to something other than whitespace.
+func hasText(s *string) bool {
+return s != nil && strings.TrimSpace(*s) != ""
+}
+
+// isCreditNote reports whether draft is issued as a credit note.
+func isCreditNote(draft InvoiceDraft) bool {
+return draft.DocumentType != nil && *draft.DocumentType == CreditNote
+}
+
+// addTaxBreakdown adds a line's rounded amounts to the breakdown entry for its
+// category and rate, appending a new entry the first time the pair is seen.
+func addTaxBreakdown(breakdown []TaxBreakdown, category string, rate, taxable, tax float64) []TaxBreakdown {
+for i := range breakdown {
+if breakdown[i].TaxCategory == category && breakdown[i].TaxRate == rate {
+breakdown[i].TaxableAmount += taxable
+breakdown[i].TaxAmount += tax
+return breakdown
+}
+}
+return append(breakdown, TaxBreakdown{TaxCategory: category, TaxRate: rate, TaxableAmount: taxable, TaxAmount: tax})
+}
+
+// exceedsDelta reports whether a and b differ by more than delta, ignoring
+// float noise so a difference of exactly delta is within tolerance.
+func exceedsDelta(a, b, delta float64) bool {
+return math.Abs(a-b)-delta > 1e-9
+}
+
+// maxGrandTotal returns the grand-total cap for v.Plan; 0 means unlimited.
+func (v Validator) maxGrandTotal() float64 {
+if limit, ok := v.Config.PlanMaxGrandTotal[v.Plan]; ok && v.Plan != "" {
+return limit
+}
+return v.Config.MaxGrandTotal
+}
+
+// errItem reports ruleID at path with the rule's catalog message.
+func errItem(ruleID, path string) ValidationErrorItem {
 return ValidationErrorItem{
 Code:    ruleID,
 Path:    path,
-Message: message,
+Message: rules.Message(ruleID),
 RuleId:  ruleID,
 }
 }
 
+// errItemf is errItem with a formatted detail appended to the catalog message.
+func errItemf(ruleID, path, format string, args ...any) ValidationErrorItem {
+item := errItem(ruleID, path)
+item.Message = rules.Messagef(ruleID, format, args...)
+return item
+}
+
 // dateToTime converts openapi_types.Date to time.Time
 func dateToTime(d openapi_types.Date) time.Time {
 return d.Time
 }
 
-func round(val float64, places int) float64 {
-p := math.Pow(10, float64(places))
-return math.Round(val*p) / p
+// Rounding modes accepted by Config.RoundingMode.
+const (
+RoundingHalfUp   = "HALF_UP"
+RoundingHalfEven = "HALF_EVEN"
+RoundingDown     = "DOWN"
+RoundingUp       = "UP"
+)
+
+// round rounds a monetary amount to two decimals using v.Config.RoundingMode.
+func (v Validator) round(val float64) float64 {
+return roundAmount(val, 2, v.Config.RoundingMode)
+}
+
+// roundAmount rounds val to places decimals using mode; unknown modes fall back to HALF_UP.
+// HALF_UP rounds ties away from zero, HALF_EVEN to the even neighbour, DOWN towards
+// zero and UP away from zero. val is rounded as the shortest decimal that represents
+// it, so 1.005 is a tie rather than the binary 1.00499999999999989...
+func roundAmount(val float64, places int, mode string) float64 {
+r, ok := new(big.Rat).SetString(strconv.FormatFloat(val, 'f', -1, 64))
+if !ok {
+return val // NaN or ±Inf
+}
+scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(places)), nil)
+r.Mul(r, new(big.Rat).SetInt(scale))
+quo, rem := new(big.Int).QuoRem(r.Num(), r.Denom(), new(big.Int))
+if rem.Sign() != 0 {
+var away bool
+switch strings.ToUpper(mode) {
+case RoundingDown:
+away = false
+case RoundingUp:
+away = true
+default:
+half := new(big.Int).Lsh(new(big.Int).Abs(rem), 1).Cmp(r.Denom())
+away = half > 0 || (half == 0 && (strings.ToUpper(mode) != RoundingHalfEven || quo.Bit(0) == 1))
+}
+if away {
+quo.Add(quo, big.NewInt(int64(r.Sign())))
+}
+}
+out, _ := new(big.Rat).SetFrac(quo, scale).Float64()
+return out
 }
 
 func contains(list []string, value string) bool {
@@ -39,11 +39,76 @@ t.Fatalf("expected invalid unit code")
 }
 }
 
+func TestValidate_SupportedCurrency(t *testing.T) {
+cfg := LoadConfig()
+cfg.SupportedCurrencies = []string{"JPY", "USD"}
+d := sampleDraft()
+d.Currency = "USD"
+result := Validator{Config: cfg}.Validate(d)
+if !result.Valid {
+t.Fatalf("expected USD invoice to be valid, got errors %+v", result.Errors)
+}
+}
+
+func TestValidate_UnsupportedCurrency(t *testing.T) {
+d := sampleDraft()
+d.Currency = "USD"
+result := Validator{Config: LoadConfig()}.Validate(d)
+if result.Valid || len(result.Errors) != 1 || result.Errors[0].Code != "JP-PINT-REQ-005" {
+t.Fatalf("expected JP-PINT-REQ-005 for USD under the default config, got %+v", result.Errors)
+}
+}
+
+func TestValidate_MixedLineCurrency(t *testing.T) {
+cfg := LoadConfig()
+cfg.SupportedCurrencies = []string{"JPY", "USD"}
+d := sampleDraft()
+usd := "USD"
+d.Lines[0].Currency = &usd
+result := Validator{Config: cfg}.Validate(d)
+if result.Valid || len(result.Errors) != 1 || result.Errors[0].Code != "JP-PINT-REQ-008" || result.Errors[0].Path != "lines[0].currency" {
+t.Fatalf("expected JP-PINT-REQ-008 on lines[0].currency, got %+v", result.Errors)
+}
+}
+
+func TestValidate_CreditNoteAllowsNegativeTotals(t *testing.T) {
+v := Validator{Config: LoadConfig()}
+d := sampleCreditNote()
+result := v.Validate(d)
+if !result.Valid {
+t.Fatalf("expected valid credit note, got errors %+v", result.Errors)
+}
+if result.Totals.Subtotal != -12000 || result.Totals.Tax != -1200 || result.Totals.GrandTotal != -13200 {
+t.Fatalf("expected negative totals, got %+v", result.Totals)
+}
+
+invoice := sampleDraft()
+invoice.Lines[0].UnitPrice = -1200
+invoice.DueDate = openapi_types.Date{Time: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)}
+codes := map[string]bool{}
+for _, e := range v.Validate(invoice).Errors {
+codes[e.Code] = true
+}
+if !codes["JP-PINT-MATH-002"] || !codes["JP-PINT-MATH-004"] {
+t.Fatalf("expected invoice to reject negative price and early due date, got %v", codes)
+}
+}
+
+// sampleCreditNote reverses sampleDraft with a due date before the issue date.
+func sampleCreditNote() InvoiceDraft {
+d := sampleDraft()
+docType := CreditNote
+d.DocumentType = &docType
+d.Lines[0].UnitPrice = -1200
+d.DueDate = openapi_types.Date{Time: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)}
+return d
+}
+
 func sampleDraft() InvoiceDraft {
 return InvoiceDraft{
 IssueDate: openapi_types.Date{Time: time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)},
 DueDate:   openapi_types.Date{Time: time.Date(2024, 4, 30, 0, 0, 0, 0, time.UTC)},
-Currency:  JPY,
+Currency:  "JPY",
 Supplier: Party{
 Name:        "Alpha",
 TaxId:       "T1234567890123",
@@ -68,3 +133,375 @@ TaxRate:     0.1,
 }},
 }
 }
+
+func TestValidate_RoundingModes(t *testing.T) {
+tests := []struct {
+mode string
+want float64
+}{
+{RoundingHalfUp, 0.13},
+{RoundingHalfEven, 0.12},
+{RoundingDown, 0.12},
+{RoundingUp, 0.13},
+}
+for _, tt := range tests {
+cfg := LoadConfig()
+cfg.RoundingMode = tt.mode
+d := sampleDraft()
+d.Lines[0].Quantity = 1
+d.Lines[0].UnitPrice = 0.125
+result := Validator{Config: cfg}.Validate(d)
+if result.Totals.Subtotal != tt.want {
+t.Errorf("%s: expected subtotal %v, got %v", tt.mode, tt.want, result.Totals.Subtotal)
+}
+}
+}
+
+func TestRoundAmount_HalfCentTies(t *testing.T) {
+tests := []struct {
+val  float64
+mode string
+want float64
+}{
+{1.005, RoundingHalfUp, 1.01},
+{1.005, RoundingHalfEven, 1.00},
+{1.015, RoundingHalfEven, 1.02},
+{1.005, RoundingDown, 1.00},
+{1.005, RoundingUp, 1.01},
+{-1.005, RoundingHalfUp, -1.01},
+{-1.005, RoundingHalfEven, -1.00},
+{-1.005, RoundingDown, -1.00},
+{-1.005, RoundingUp, -1.01},
+{1.004, RoundingHalfUp, 1.00},
+{1.006, RoundingHalfEven, 1.01},
+{2.5, "unknown", 2.5},
+}
+for _, tt := range tests {
+if got := roundAmount(tt.val, 2, tt.mode); got != tt.want {
+t.Errorf("roundAmount(%v, %s) = %v, want %v", tt.val, tt.mode, got, tt.want)
+}
+}
+}
+
+func TestValidate_RoundingModesOnHalfCent(t *testing.T) {
+tests := []struct {
+mode string
+want float64
+}{
+{RoundingHalfUp, 1.01},
+{RoundingHalfEven, 1.00},
+{RoundingDown, 1.00},
+{RoundingUp, 1.01},
+}
+for _, tt := range tests {
+cfg := LoadConfig()
+cfg.RoundingMode = tt.mode
+d := sampleDraft()
+d.Lines[0].Quantity = 1
+d.Lines[0].UnitPrice = 1.005
+d.Lines[0].TaxRate = 0
+result := Validator{Config: cfg}.Validate(d)
+if result.Totals.Subtotal != tt.want || result.Totals.GrandTotal != tt.want {
+t.Errorf("%s: expected subtotal and grand total %v, got %+v", tt.mode, tt.want, result.Totals)
+}
+}
+}
+
+func TestValidate_DeclaredGrandTotal(t *testing.T) {
+cfg := LoadConfig()
+cfg.AllowedDelta = 0.01
+computed := Validator{Config: cfg}.Validate(sampleDraft()).Totals.GrandTotal
+
+tests := []struct {
+name     string
+declared *float64
+valid    bool
+}{
+{"absent", nil, true},
+{"exact", &computed, true},
+{"within tolerance", ptrFloat(computed + 0.01), true},
+{"out of tolerance", ptrFloat(computed + 0.02), false},
+}
+for _, tt := range tests {
+d := sampleDraft()
+d.DeclaredGrandTotal = tt.declared
+result := Validator{Config: cfg}.Validate(d)
+if result.Valid != tt.valid {
+t.Errorf("%s: expected valid=%v, got errors %+v", tt.name, tt.valid, result.Errors)
+}
+if !tt.valid && (len(result.Errors) != 1 || result.Errors[0].Code != "JP-PINT-MATH-010" || result.Errors[0].Path != "declaredGrandTotal") {
+t.Errorf("%s: expected JP-PINT-MATH-010 on declaredGrandTotal, got %+v", tt.name, result.Errors)
+}
+}
+}
+
+func TestValidate_AllowanceCharges(t *testing.T) {
+category := "S"
+rate := 0.1
+tests := []struct {
+name       string
+ac         AllowanceCharge
+allowance  float64
+charge     float64
+tax        float64
+grandTotal float64
+}{
+{"discount", AllowanceCharge{Amount: 1000, Reason: "Volume discount", TaxCategory: &category, TaxRate: &rate}, 1000, 0, 1100, 12100},
+{"surcharge", AllowanceCharge{ChargeIndicator: true, Amount: 500, Reason: "Shipping", TaxCategory: &category, TaxRate: &rate}, 0, 500, 1250, 13750},
+{"untaxed surcharge", AllowanceCharge{ChargeIndicator: true, Amount: 300, Reason: "Handling"}, 0, 300, 1200, 13500},
+}
+for _, tt := range tests {
+d := sampleDraft()
+d.AllowanceCharges = &[]AllowanceCharge{tt.ac}
+result := Validator{Config: LoadConfig()}.Validate(d)
+if !result.Valid {
+t.Errorf("%s: expected valid, got errors %+v", tt.name, result.Errors)
+continue
+}
+got := result.Totals
+if got.Subtotal != 12000 || got.AllowanceTotal != tt.allowance || got.ChargeTotal != tt.charge || got.Tax != tt.tax || got.GrandTotal != tt.grandTotal {
+t.Errorf("%s: unexpected totals %+v", tt.name, got)
+}
+}
+}
+
+func TestValidate_AllowanceExceedsSubtotal(t *testing.T) {
+d := sampleDraft()
+d.AllowanceCharges = &[]AllowanceCharge{{Amount: 13000, Reason: "Too generous"}}
+result := Validator{Config: LoadConfig()}.Validate(d)
+if result.Valid || len(result.Errors) != 1 || result.Errors[0].Code != "JP-PINT-MATH-011" {
+t.Fatalf("expected JP-PINT-MATH-011, got %+v", result.Errors)
+}
+}
+
+func TestValidate_LineDiscounts(t *testing.T) {
+tests := []struct {
+name     string
+line     func(*LineItem)
+subtotal float64
+discount float64
+tax      float64
+}{
+{"percent", func(l *LineItem) { l.DiscountPercent = ptrFloat(10) }, 10800, 1200, 1080},
+{"absolute per base quantity", func(l *LineItem) {
+l.BaseQuantity = ptrFloat(2)
+l.DiscountAmount = ptrFloat(500)
+}, 5500, 500, 550},
+}
+for _, tt := range tests {
+d := sampleDraft()
+tt.line(&d.Lines[0])
+result := Validator{Config: LoadConfig()}.Validate(d)
+if !result.Valid {
+t.Errorf("%s: expected valid, got errors %+v", tt.name, result.Errors)
+continue
+}
+got := result.Totals
+if got.Subtotal != tt.subtotal || got.Tax != tt.tax || got.Lines[0].Discount != tt.discount {
+t.Errorf("%s: unexpected totals %+v", tt.name, got)
+}
+}
+}
+
+func TestValidate_LineDiscountExceedsGross(t *testing.T) {
+d := sampleDraft()
+d.Lines[0].DiscountAmount = ptrFloat(13000)
+result := Validator{Config: LoadConfig()}.Validate(d)
+if result.Valid || len(result.Errors) != 1 || result.Errors[0].Code != "JP-PINT-MATH-014" || result.Errors[0].Path != "lines[0]" {
+t.Fatalf("expected JP-PINT-MATH-014 on lines[0], got %+v", result.Errors)
+}
+}
+
+func TestValidate_WithholdingTax(t *testing.T) {
+d := sampleDraft()
+docRate, lineRate := 0.1021, 0.2042
+d.WithholdingRate = &docRate
+d.Lines = append(d.Lines, LineItem{Description: "Manuscript fee", Quantity: 1, UnitCode: EA, UnitPrice: 1000, TaxCategory: S, TaxRate: 0.1, WithholdingRate: &lineRate})
+result := Validator{Config: LoadConfig()}.Validate(d)
+if !result.Valid {
+t.Fatalf("expected valid, got errors %+v", result.Errors)
+}
+got := result.Totals
+if got.WithholdingTax != 1429.4 || got.GrandTotal != 14300 || got.Payable != got.GrandTotal-got.WithholdingTax {
+t.Fatalf("expected payable = grand total - withholding, got %+v", got)
+}
+if len(got.Withholding) != 2 || got.Withholding[1].TaxableAmount != 1000 || got.Withholding[1].TaxAmount != 204.2 {
+t.Fatalf("expected withholding split per rate, got %+v", got.Withholding)
+}
+
+bad := 1.5
+d.WithholdingRate = &bad
+result = Validator{Config: LoadConfig()}.Validate(d)
+if result.Valid || len(result.Errors) != 1 || result.Errors[0].Code != "JP-PINT-MATH-013" || result.Errors[0].Path != "withholdingRate" {
+t.Fatalf("expected JP-PINT-MATH-013, got %+v", result.Errors)
+}
+}
+
+// samplePaymentMeans is a bank transfer to a complete Japanese account.
+func samplePaymentMeans() *PaymentMeans {
+bank, branch, number, holder := "みずほ銀行", "東京営業部", "1234567", "アルファ株式会社"
+accountType := Ordinary
+reference := "INV-2024-000001"
+return &PaymentMeans{
+Code:      "30",
+DueDate:   &openapi_types.Date{Time: time.Date(2024, 4, 30, 0, 0, 0, 0, time.UTC)},
+Reference: &reference,
+Account: &BankAccount{
+BankName:      &bank,
+BranchName:    &branch,
+AccountType:   &accountType,
+AccountNumber: &number,
+AccountHolder: &holder,
+},
+}
+}
+
+func TestValidate_PaymentMeans(t *testing.T) {
+iban := "GB82WEST12345698765432"
+tests := []struct {
+name string
+edit func(pm *PaymentMeans)
+code string
+path string
+}{
+{"japanese account", func(*PaymentMeans) {}, "", ""},
+{"iban", func(pm *PaymentMeans) { pm.Code = "58"; pm.Account = &BankAccount{Iban: &iban} }, "", ""},
+{"cash needs no account", func(pm *PaymentMeans) { pm.Code = "10"; pm.Account = nil }, "", ""},
+{"transfer without account", func(pm *PaymentMeans) { pm.Account = nil }, "JP-PINT-REQ-010", "paymentMeans.account"},
+{"incomplete japanese account", func(pm *PaymentMeans) { pm.Account.BranchName = nil }, "JP-PINT-REQ-010", "paymentMeans.account.branchName"},
+{"unknown code", func(pm *PaymentMeans) { pm.Code = "99" }, "JP-PINT-CODE-003", "paymentMeans.code"},
+{"due before issue", func(pm *PaymentMeans) { pm.DueDate.Time = time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC) }, "JP-PINT-MATH-002", "paymentMeans.dueDate"},
+}
+for _, tt := range tests {
+d := sampleDraft()
+d.PaymentMeans = samplePaymentMeans()
+tt.edit(d.PaymentMeans)
+result := Validator{Config: LoadConfig()}.Validate(d)
+if tt.code == "" {
+if !result.Valid {
+t.Errorf("%s: expected valid, got errors %+v", tt.name, result.Errors)
+}
+continue
+}
+if result.Valid || len(result.Errors) != 1 || result.Errors[0].Code != tt.code || result.Errors[0].Path != tt.path {
+t.Errorf("%s: expected %s at %s, got %+v", tt.name, tt.code, tt.path, result.Errors)
+}
+}
+}
+
+func TestValidate_PartyIDs(t *testing.T) {
+tests := []struct {
+name string
+edit func(d *InvoiceDraft)
+code string
+path string
+}{
+{"valid registration number", func(*InvoiceDraft) {}, "", ""},
+{"missing T", func(d *InvoiceDraft) { d.Supplier.TaxId = "1234567890123" }, "JP-PINT-CODE-004", "supplier.taxId"},
+{"too few digits", func(d *InvoiceDraft) { d.Customer.TaxId = "T123456789012" }, "JP-PINT-CODE-004", "customer.taxId"},
+{"non-digit", func(d *InvoiceDraft) { d.Supplier.TaxId = "T12345678901X3" }, "JP-PINT-CODE-004", "supplier.taxId"},
+{"hyphenated postal", func(d *InvoiceDraft) { d.Customer.Postal = "150-0001" }, "JP-PINT-CODE-005", "customer.postal"},
+{"non-JP party exempt", func(d *InvoiceDraft) {
+d.Customer.CountryCode = PartyCountryCode("US")
+d.Customer.TaxId = "12-3456789"
+d.Customer.Postal = "94105"
+}, "", ""},
+}
+for _, tt := range tests {
+d := sampleDraft()
+tt.edit(&d)
+result := Validator{Config: LoadConfig()}.Validate(d)
+if tt.code == "" {
+if !result.Valid {
+t.Errorf("%s: expected valid, got errors %+v", tt.name, result.Errors)
+}
+continue
+}
+if result.Valid || len(result.Errors) != 1 || result.Errors[0].Code != tt.code || result.Errors[0].Path != tt.path {
+t.Errorf("%s: expected %s at %s, got %+v", tt.name, tt.code, tt.path, result.Errors)
+}
+}
+
+// With no strict countries configured, JP parties are not checked either.
+cfg := LoadConfig()
+cfg.StrictPartyCountries = nil
+d := sampleDraft()
+d.Supplier.TaxId = "bogus"
+if result := (Validator{Config: cfg}).Validate(d); !result.Valid {
+t.Errorf("expected party checks disabled, got errors %+v", result.Errors)
+}
+}
+
+func ptrFloat(v float64) *float64 {
+return &v
+}
+
+func TestValidateStream_EmitsErrorsPerLine(t *testing.T) {
+v := Validator{Config: LoadConfig()}
+d := sampleDraft()
+for i := 1; i < 50; i++ {
+line := d.Lines[0]
+if i%10 == 0 {
+line.UnitCode = "ZZZ"
+}
+d.Lines = append(d.Lines, line)
+}
+
+var seen []string
+emitted := 0
+result := v.ValidateStream(d, func(item ValidationErrorItem) {
+seen = append(seen, item.Path)
+emitted++
+})
+want := []string{"lines[10].unitCode", "lines[20].unitCode", "lines[30].unitCode", "lines[40].unitCode"}
+if len(seen) != len(want) {
+t.Fatalf("expected %d emitted errors, got %v", len(want), seen)
+}
+for i := range want {
+if seen[i] != want[i] {
+t.Fatalf("emitted error %d: expected %s, got %s", i, want[i], seen[i])
+}
+}
+if result.Valid || len(result.Errors) != emitted {
+t.Fatalf("expected result to carry the %d emitted errors, got %+v", emitted, result.Errors)
+}
+}
+
+func TestValidate_MaxGrandTotal(t *testing.T) {
+cfg := LoadConfig()
+cfg.MaxGrandTotal = 1_000_000
+cfg.PlanMaxGrandTotal = map[string]float64{"enterprise": 100_000_000}
+
+over := sampleDraft()
+over.Lines[0].UnitPrice = 1_000_000 // 10 x 1,000,000 + 10% tax
+under := sampleDraft()
+
+tests := []struct {
+name    string
+plan    string
+draft   InvoiceDraft
+wantErr bool
+}{
+{"over default cap", "", over, true},
+{"under default cap", "", under, false},
+{"plan without override uses default", "pro", over, true},
+{"enterprise override", "enterprise", over, false},
+}
+for _, tt := range tests {
+result := Validator{Config: cfg, Plan: tt.plan}.Validate(tt.draft)
+found := false
+for _, e := range result.Errors {
+if e.Code == "JP-PINT-LIMIT-006" {
+found = true
+}
+}
+if found != tt.wantErr || result.Valid == tt.wantErr {
+t.Errorf("%s: expected LIMIT-006=%v, got errors %+v", tt.name, tt.wantErr, result.Errors)
+}
+}
+
+cfg.MaxGrandTotal = 0
+if result := (Validator{Config: cfg}).Validate(over); !result.Valid {
+t.Errorf("expected no cap when MaxGrandTotal is zero, got %+v", result.Errors)
+}
+}
@@ -0,0 +1,76 @@
+// Package ratelimit defines the rate-limit rejection event shared by the auth
+// and auditzip limiters, so support tooling can explain throttling and spot
+// under-provisioned tenants from a single event shape.
+package ratelimit
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Event describes one request rejected by a rate limiter.
+type Event struct {
+	TenantID string `json:"tenantId"`
+	// Limiter names the limiter that rejected the request, e.g. "auth.api_key".
+	Limiter string `json:"limiter"`
+	// Key is the limiter bucket the request was counted against.
+	Key    string `json:"key,omitempty"`
+	CorrID string `json:"corrId,omitempty"`
+	// Limit is the effective number of requests allowed per window.
+	Limit             int       `json:"limit"`
+	WindowSeconds     int       `json:"windowSeconds"`
+	RetryAfterSeconds int       `json:"retryAfterSeconds"`
+	Timestamp         time.Time `json:"timestamp"`
+}
+
+// Recorder receives rate-limit events. Implementations must be safe for
+// concurrent use and should not block the rejected request for long.
+type Recorder interface {
+	RecordRateLimit(ctx context.Context, ev Event)
+}
+
+// MemoryRecorder keeps events in memory, grouped by tenant.
+type MemoryRecorder struct {
+	mu     sync.Mutex
+	events map[string][]Event
+}
+
+func NewMemoryRecorder() *MemoryRecorder {
+	return &MemoryRecorder{events: map[string][]Event{}}
+}
+
+func (m *MemoryRecorder) RecordRateLimit(_ context.Context, ev Event) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.events[ev.TenantID] = append(m.events[ev.TenantID], ev)
+}
+
+// Events returns tenantID's events, oldest first.
+func (m *MemoryRecorder) Events(tenantID string) []Event {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Event(nil), m.events[tenantID]...)
+}
+
+// LogRecorder writes each event to a structured logger at warn level.
+type LogRecorder struct {
+	Logger *slog.Logger
+}
+
+func (l LogRecorder) RecordRateLimit(ctx context.Context, ev Event) {
+	logger := l.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	logger.WarnContext(ctx, "rate limit exceeded",
+		slog.String("tenantId", ev.TenantID),
+		slog.String("limiter", ev.Limiter),
+		slog.String("key", ev.Key),
+		slog.String("correlationId", ev.CorrID),
+		slog.Int("limit", ev.Limit),
+		slog.Int("windowSeconds", ev.WindowSeconds),
+		slog.Int("retryAfterSeconds", ev.RetryAfterSeconds),
+	)
+}
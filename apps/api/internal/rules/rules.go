@@ -0,0 +1,171 @@
+// Package rules is the catalog of validation rule codes reported by the pint
+// and auditzip validators. Validators reference the codes declared here and
+// take their messages from the catalog, so GET /validation/rules always
+// describes exactly what the services emit.
+package rules
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+)
+
+// Modules that own rules.
+const (
+	ModulePint     = "pint"
+	ModuleAuditZip = "auditzip"
+)
+
+// JP PINT invoice rules.
+const (
+	PintReq001   = "JP-PINT-REQ-001"
+	PintReq002   = "JP-PINT-REQ-002"
+	PintReq005   = "JP-PINT-REQ-005"
+	PintReq006   = "JP-PINT-REQ-006"
+	PintReq007   = "JP-PINT-REQ-007"
+	PintReq008   = "JP-PINT-REQ-008"
+	PintReq009   = "JP-PINT-REQ-009"
+	PintReq010   = "JP-PINT-REQ-010"
+	PintMath002  = "JP-PINT-MATH-002"
+	PintMath003  = "JP-PINT-MATH-003"
+	PintMath004  = "JP-PINT-MATH-004"
+	PintMath005  = "JP-PINT-MATH-005"
+	PintMath010  = "JP-PINT-MATH-010"
+	PintMath011  = "JP-PINT-MATH-011"
+	PintMath012  = "JP-PINT-MATH-012"
+	PintMath013  = "JP-PINT-MATH-013"
+	PintMath014  = "JP-PINT-MATH-014"
+	PintMath015  = "JP-PINT-MATH-015"
+	PintCode001  = "JP-PINT-CODE-001"
+	PintCode002  = "JP-PINT-CODE-002"
+	PintCode003  = "JP-PINT-CODE-003"
+	PintCode004  = "JP-PINT-CODE-004"
+	PintCode005  = "JP-PINT-CODE-005"
+	PintLimit001 = "JP-PINT-LIMIT-001"
+	PintLimit002 = "JP-PINT-LIMIT-002"
+	PintLimit006 = "JP-PINT-LIMIT-006"
+	PintUBL001   = "JP-PINT-UBL-001"
+	PintUBL002   = "JP-PINT-UBL-002"
+	PintUBL003   = "JP-PINT-UBL-003"
+	PintUBL004   = "JP-PINT-UBL-004"
+)
+
+// Audit zip request rules.
+const (
+	AuditReq001 = "AUDIT-REQ-001"
+	AuditReq004 = "AUDIT-REQ-004"
+	AuditReq005 = "AUDIT-REQ-005"
+	AuditReq006 = "AUDIT-REQ-006"
+	AuditReq007 = "AUDIT-REQ-007"
+	AuditReq008 = "AUDIT-REQ-008"
+	AuditReq009 = "AUDIT-REQ-009"
+	AuditReq010 = "AUDIT-REQ-010"
+	AuditReq413 = "AUDIT-REQ-413"
+)
+
+// Rule describes one validation rule code.
+type Rule struct {
+	Code    string `json:"code"`
+	Module  string `json:"module"`
+	Message string `json:"message"`
+}
+
+var catalog = map[string]Rule{}
+
+func register(module, code, message string) {
+	catalog[code] = Rule{Code: code, Module: module, Message: message}
+}
+
+func init() {
+	register(ModulePint, PintReq001, "Supplier and customer names are required")
+	register(ModulePint, PintReq002, "Issue and due dates are required")
+	register(ModulePint, PintReq005, "Currency is not supported")
+	register(ModulePint, PintReq006, "At least one line item is required")
+	register(ModulePint, PintReq007, "Description is required")
+	register(ModulePint, PintReq008, "Line currency does not match invoice currency")
+	register(ModulePint, PintReq009, "Allowance or charge reason is required")
+	register(ModulePint, PintReq010, "Bank transfers require an IBAN or a complete Japanese bank account")
+	register(ModulePint, PintMath002, "Due date must be on or after issue date")
+	register(ModulePint, PintMath003, "Quantity must be positive")
+	register(ModulePint, PintMath004, "Unit price must be non-negative")
+	register(ModulePint, PintMath005, "Tax rate must be between 0 and 1")
+	register(ModulePint, PintMath010, "Declared grand total differs from the computed total")
+	register(ModulePint, PintMath011, "Allowances exceed the line subtotal")
+	register(ModulePint, PintMath012, "Allowance or charge amount must be non-negative")
+	register(ModulePint, PintMath013, "Withholding rate must be between 0 and 1")
+	register(ModulePint, PintMath014, "Line discount exceeds the gross line amount")
+	register(ModulePint, PintMath015, "Discount percent must be between 0 and 100")
+	register(ModulePint, PintCode001, "Invalid unit code")
+	register(ModulePint, PintCode002, "Invalid tax category")
+	register(ModulePint, PintCode003, "Invalid payment means code")
+	register(ModulePint, PintCode004, "Registration number must be T followed by 13 digits")
+	register(ModulePint, PintCode005, "Postal code must be 7 digits")
+	register(ModulePint, PintLimit001, "Too many lines")
+	register(ModulePint, PintLimit002, "Description too long")
+	register(ModulePint, PintLimit006, "Grand total exceeds the maximum")
+	register(ModulePint, PintUBL001, "UBL document is malformed")
+	register(ModulePint, PintUBL002, "Required UBL element is missing")
+	register(ModulePint, PintUBL003, "UBL element is out of order")
+	register(ModulePint, PintUBL004, "UBL monetary totals do not reconcile")
+
+	register(ModuleAuditZip, AuditReq001, "from and to are required")
+	register(ModuleAuditZip, AuditReq004, "to must be on or after from")
+	register(ModuleAuditZip, AuditReq005, "format must be zip or targz")
+	register(ModuleAuditZip, AuditReq006, "partner too long")
+	register(ModuleAuditZip, AuditReq007, "minAmount must be >= 0")
+	register(ModuleAuditZip, AuditReq008, "maxAmount must be >= 0")
+	register(ModuleAuditZip, AuditReq009, "minAmount must be <= maxAmount")
+	register(ModuleAuditZip, AuditReq010, "callbackUrl is not allowed")
+	register(ModuleAuditZip, AuditReq413, "result exceeds threshold; split by hint")
+}
+
+// Lookup returns the rule registered for code.
+func Lookup(code string) (Rule, bool) {
+	rule, ok := catalog[code]
+	return rule, ok
+}
+
+// Message returns code's catalog message, or code itself if it is unknown.
+func Message(code string) string {
+	if rule, ok := catalog[code]; ok {
+		return rule.Message
+	}
+	return code
+}
+
+// Messagef returns code's catalog message followed by the formatted detail,
+// e.g. "Too many lines: max 500".
+func Messagef(code, format string, args ...any) string {
+	return Message(code) + ": " + fmt.Sprintf(format, args...)
+}
+
+// Catalog returns every rule sorted by code.
+func Catalog() []Rule {
+	list := make([]Rule, 0, len(catalog))
+	for _, rule := range catalog {
+		list = append(list, rule)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Code < list[j].Code })
+	return list
+}
+
+// CatalogResponse is the body of GET /validation/rules.
+type CatalogResponse struct {
+	Rules []Rule `json:"rules"`
+}
+
+// Handler serves GET /validation/rules, optionally filtered by ?module=.
+func Handler(w http.ResponseWriter, r *http.Request) {
+	module := r.URL.Query().Get("module")
+	list := make([]Rule, 0, len(catalog))
+	for _, rule := range Catalog() {
+		if module == "" || rule.Module == module {
+			list = append(list, rule)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(CatalogResponse{Rules: list})
+}
@@ -0,0 +1,143 @@
+package rules
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var codePattern = regexp.MustCompile(`^(JP-PINT|AUDIT)-[A-Z]+-\d+$`)
+
+// declaredCodes maps each rule code constant in rules.go to its value.
+func declaredCodes(t *testing.T) map[string]string {
+	t.Helper()
+	file, err := parser.ParseFile(token.NewFileSet(), "rules.go", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	codes := map[string]string{}
+	ast.Inspect(file, func(n ast.Node) bool {
+		spec, ok := n.(*ast.ValueSpec)
+		if !ok {
+			return true
+		}
+		for i, name := range spec.Names {
+			if i >= len(spec.Values) {
+				continue
+			}
+			if lit, ok := spec.Values[i].(*ast.BasicLit); ok && lit.Kind == token.STRING {
+				value, _ := strconv.Unquote(lit.Value)
+				if codePattern.MatchString(value) {
+					codes[name.Name] = value
+				}
+			}
+		}
+		return true
+	})
+	return codes
+}
+
+func TestEveryDeclaredCodeIsCataloged(t *testing.T) {
+	codes := declaredCodes(t)
+	if len(codes) != len(Catalog()) {
+		t.Fatalf("declared %d codes but cataloged %d", len(codes), len(Catalog()))
+	}
+	for name, code := range codes {
+		rule, ok := Lookup(code)
+		if !ok || rule.Message == "" {
+			t.Errorf("%s (%s) has no catalog entry", name, code)
+		}
+		wantModule := ModulePint
+		if strings.HasPrefix(code, "AUDIT-") {
+			wantModule = ModuleAuditZip
+		}
+		if rule.Module != wantModule {
+			t.Errorf("%s: module %q, want %q", code, rule.Module, wantModule)
+		}
+	}
+}
+
+// TestValidatorCodesAreCataloged checks every rule code the pint and auditzip
+// packages can emit: each must come from a constant of this package that has a
+// catalog entry, never from an inline string literal.
+func TestValidatorCodesAreCataloged(t *testing.T) {
+	codes := declaredCodes(t)
+	for _, dir := range []string{"../pint", "../auditzip"} {
+		files, err := filepath.Glob(filepath.Join(dir, "*.go"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		referenced := 0
+		for _, path := range files {
+			if strings.HasSuffix(path, "_test.go") {
+				continue
+			}
+			file, err := parser.ParseFile(token.NewFileSet(), path, nil, parser.ParseComments)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ast.Inspect(file, func(n ast.Node) bool {
+				switch n := n.(type) {
+				case *ast.BasicLit:
+					if value, err := strconv.Unquote(n.Value); err == nil && n.Kind == token.STRING && codePattern.MatchString(value) {
+						t.Errorf("%s: rule code %s is a string literal; reference the rules package instead", path, value)
+					}
+				case *ast.SelectorExpr:
+					pkg, ok := n.X.(*ast.Ident)
+					if !ok || pkg.Name != "rules" || !strings.HasPrefix(n.Sel.Name, "Pint") && !strings.HasPrefix(n.Sel.Name, "Audit") {
+						return true
+					}
+					referenced++
+					code, ok := codes[n.Sel.Name]
+					if !ok {
+						t.Errorf("%s: rules.%s is not a declared rule code", path, n.Sel.Name)
+					} else if _, ok := Lookup(code); !ok {
+						t.Errorf("%s: %s is missing from the catalog", path, code)
+					}
+				}
+				return true
+			})
+		}
+		if referenced == 0 {
+			t.Errorf("%s references no rule codes", dir)
+		}
+	}
+}
+
+func TestHandlerServesCatalog(t *testing.T) {
+	w := httptest.NewRecorder()
+	Handler(w, httptest.NewRequest(http.MethodGet, "/validation/rules", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	var all CatalogResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &all); err != nil {
+		t.Fatal(err)
+	}
+	if len(all.Rules) != len(Catalog()) || all.Rules[0].Code > all.Rules[1].Code {
+		t.Fatalf("expected the sorted catalog, got %+v", all.Rules)
+	}
+
+	w = httptest.NewRecorder()
+	Handler(w, httptest.NewRequest(http.MethodGet, "/validation/rules?module=auditzip", nil))
+	var audit CatalogResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &audit); err != nil {
+		t.Fatal(err)
+	}
+	if len(audit.Rules) == 0 {
+		t.Fatal("expected auditzip rules")
+	}
+	for _, rule := range audit.Rules {
+		if rule.Module != ModuleAuditZip {
+			t.Fatalf("expected only auditzip rules, got %+v", rule)
+		}
+	}
+}
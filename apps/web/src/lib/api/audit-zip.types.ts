@@ -15,7 +15,7 @@ export interface paths {
         put?: never;
         /**
          * Enqueue audit ZIP export job
-         * @description Issues an async job to build a tenant-scoped audit ZIP. Requires Idempotency-Key; returns 202 with Location for polling. Duplicate keys with a different body return 409.
+         * @description Issues an async job to build a tenant-scoped audit ZIP. Requires Idempotency-Key; returns 202 with Location for polling. Duplicate keys with a different body return 409. A body over AUDIT_MAX_REQUEST_BYTES is rejected with 413 and a ValidationError (code BODY_TOO_LARGE) before it is decoded. A request matching a job that succeeded within AUDIT_RESULT_REUSE_WINDOW, whose archive still exists, returns that job with a freshly signed URL.
          */
         post: operations["enqueueAuditZip"];
         delete?: never;
@@ -58,8 +58,21 @@ export interface components {
             minAmount?: number | null;
             /** Format: double */
             maxAmount?: number | null;
-            /** @enum {string} */
-            format: "zip";
+            /**
+             * @description Archive packaging; targz produces a gzipped tarball
+             * @enum {string}
+             */
+            format: "zip" | "targz";
+            /**
+             * Format: uri
+             * @description HTTPS URL that receives a signed POST when the job reaches a terminal state
+             */
+            callbackUrl?: string | null;
+            /**
+             * @description Fan a range above the split threshold out into child jobs instead of rejecting it with 413
+             * @default false
+             */
+            autoSplit: boolean;
         };
         AuditZipJob: {
             /** Format: uuid */
@@ -78,9 +91,27 @@ export interface components {
             criteriaHash?: string;
             /** @description true when cancel=true is accepted */
             canCancel?: boolean;
+            /** @description Child jobs of an auto-split request, in range order */
+            children?: components["schemas"]["AuditZipChildJob"][];
             result?: components["schemas"]["AuditZipResult"];
             error?: components["schemas"]["InternalError"];
         };
+        AuditZipChildJob: {
+            /** Format: uuid */
+            jobId: string;
+            /** Format: date */
+            from: string;
+            /** Format: date */
+            to: string;
+            /** @description Child job status (same values as AuditZipJob.status) */
+            status: string;
+            progress: number;
+            /**
+             * Format: uri
+             * @description Signed URL of the child archive once it has succeeded
+             */
+            signedUrl?: string;
+        };
         AuditZipResult: {
             /**
              * Format: uri
@@ -128,7 +159,7 @@ export interface components {
             /** @default false */
             retryable: boolean;
             /** @enum {string} */
-            conflictReason: "idempotency_replay" | "idempotency_body_mismatch" | "duplicate_job" | "not_cancelable";
+            conflictReason: "idempotency_replay" | "idempotency_body_mismatch" | "duplicate_job" | "not_cancelable" | "artifact_not_ready";
         };
         RequestTooLargeError: {
             /** @example AUDIT-REQ-413 */
@@ -183,6 +214,9 @@ export interface components {
                 /** @description Polling URL for the job */
                 Location?: string;
                 "X-Correlation-Id": components["headers"]["CorrelationHeader"];
+                "X-RateLimit-Limit"?: components["headers"]["RateLimitLimit"];
+                "X-RateLimit-Remaining"?: components["headers"]["RateLimitRemaining"];
+                "X-RateLimit-Reset"?: components["headers"]["RateLimitReset"];
                 [name: string]: unknown;
             };
             content: {
@@ -244,6 +278,9 @@ export interface components {
             headers: {
                 "X-Correlation-Id": components["headers"]["CorrelationHeader"];
                 "Retry-After": components["headers"]["RetryAfter"];
+                "X-RateLimit-Limit"?: components["headers"]["RateLimitLimit"];
+                "X-RateLimit-Remaining"?: components["headers"]["RateLimitRemaining"];
+                "X-RateLimit-Reset"?: components["headers"]["RateLimitReset"];
                 [name: string]: unknown;
             };
             content: {
@@ -260,6 +297,17 @@ export interface components {
                 "application/json": components["schemas"]["InternalError"];
             };
         };
+        /** @description Server is shutting down and not accepting new jobs; retry against another instance */
+        ServiceUnavailable: {
+            headers: {
+                "X-Correlation-Id": components["headers"]["CorrelationHeader"];
+                "Retry-After": components["headers"]["RetryAfter"];
+                [name: string]: unknown;
+            };
+            content: {
+                "application/json": components["schemas"]["InternalError"];
+            };
+        };
         /** @description Job not found */
         NotFound: {
             headers: {
@@ -285,6 +333,12 @@ export interface components {
         CorrelationHeader: string;
         /** @description Seconds or HTTP-date after which the request can be retried (per RFC 7231). */
         RetryAfter: string;
+        /** @description Requests the tenant may make per rate-limit window. Omitted when tenant rate limiting is disabled. */
+        RateLimitLimit: number;
+        /** @description Requests the tenant has left in the current window. */
+        RateLimitRemaining: number;
+        /** @description Unix time in seconds at which the current window ends and the allowance is restored. */
+        RateLimitReset: number;
     };
     pathItems: never;
 }
@@ -317,6 +371,7 @@ export interface operations {
             413: components["responses"]["RequestTooLarge"];
             429: components["responses"]["RateLimit"];
             500: components["responses"]["InternalError"];
+            503: components["responses"]["ServiceUnavailable"];
         };
     };
     getAuditZipJob: {
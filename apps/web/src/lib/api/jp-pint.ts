@@ -4,3 +4,7 @@ export type InvoiceDraft = components['schemas']['InvoiceDraft']
 export type LineItem = components['schemas']['LineItem']
 export type ValidationErrorItem = components['schemas']['ValidationErrorItem']
 export type ValidationResponse = components['schemas']['ValidationResponse']
+export type ValidationErrorResponse = components['schemas']['ValidationErrorResponse']
+export type ConflictError = components['schemas']['ConflictError']
+export type NotFoundError = components['schemas']['NotFoundError']
+export type InternalError = components['schemas']['InternalError']
@@ -86,8 +86,70 @@ export interface components {
             taxCategory: "S" | "Z" | "E" | "O" | "AE" | "K" | "G";
             /** Format: double */
             taxRate: number;
+            /** @description ISO 4217 currency code; when set it must match the invoice currency */
+            currency?: string;
+            /**
+             * Format: double
+             * @description Withholding tax (源泉徴収) rate for this line, e.g. 0.1021; overrides the draft's withholdingRate
+             */
+            withholdingRate?: number;
+            /**
+             * Format: double
+             * @description Quantity the unit price applies to, e.g. 12 for a per-dozen price; defaults to 1
+             */
+            baseQuantity?: number;
+            /**
+             * Format: double
+             * @description Absolute discount taken off the gross line amount
+             */
+            discountAmount?: number;
+            /**
+             * Format: double
+             * @description Percentage discount taken off the gross line amount, applied alongside discountAmount
+             */
+            discountPercent?: number;
+        };
+        AllowanceCharge: {
+            /** @description true for a charge (surcharge), false for an allowance (discount) */
+            chargeIndicator: boolean;
+            /** Format: double */
+            amount: number;
+            reason: string;
+            /** @description JP PINT tax category code; when omitted the amount carries no tax */
+            taxCategory?: string;
+            /** Format: double */
+            taxRate?: number;
+        };
+        /** @description Payee account; either an IBAN or a Japanese domestic account (bank, branch and account number) */
+        BankAccount: {
+            iban?: string;
+            bankName?: string;
+            branchName?: string;
+            /**
+             * @description Japanese deposit type, 普通 (ordinary) or 当座 (checking)
+             * @enum {string}
+             */
+            accountType?: "ordinary" | "checking";
+            accountNumber?: string;
+            /** @description Account holder name as registered with the bank (口座名義) */
+            accountHolder?: string;
+        };
+        PaymentMeans: {
+            /** @description UNCL4461 payment means code, e.g. 30 (credit transfer), 42 (payment to bank account), 10 (cash); transfer codes require account */
+            code: string;
+            account?: components["schemas"]["BankAccount"];
+            /** Format: date */
+            dueDate?: string;
+            /** @description Remittance reference the payer should quote with the payment */
+            reference?: string;
         };
         InvoiceDraft: {
+            /**
+             * @description creditNote issues a UBL CreditNote (type code 381) that may carry negative line amounts
+             * @default invoice
+             * @enum {string}
+             */
+            documentType: "invoice" | "creditNote";
             invoiceNumber?: string;
             supplier: components["schemas"]["Party"];
             customer: components["schemas"]["Party"];
@@ -95,10 +157,23 @@ export interface components {
             issueDate: string;
             /** Format: date */
             dueDate: string;
-            /** @enum {string} */
-            currency: "JPY";
+            /** @description ISO 4217 currency code; must be one of the server's supported currencies (JPY by default) */
+            currency: string;
             notes?: string;
             lines: components["schemas"]["LineItem"][];
+            /** @description Document-level allowances (discounts) and charges (surcharges) */
+            allowanceCharges?: components["schemas"]["AllowanceCharge"][];
+            /**
+             * Format: double
+             * @description Client-computed grand total; JP-PINT-MATH-010 is reported when it differs from the computed total by more than the allowed delta
+             */
+            declaredGrandTotal?: number;
+            paymentMeans?: components["schemas"]["PaymentMeans"];
+            /**
+             * Format: double
+             * @description Withholding tax (源泉徴収) rate applied to the tax-exclusive amount of lines without their own rate
+             */
+            withholdingRate?: number;
         };
         ValidationErrorItem: {
             code: string;
@@ -108,6 +183,16 @@ export interface components {
             /** @enum {string} */
             severity?: "error" | "warning";
         };
+        TaxBreakdown: {
+            /** @description JP PINT tax category code */
+            taxCategory: string;
+            /** Format: double */
+            taxRate: number;
+            /** Format: double */
+            taxableAmount: number;
+            /** Format: double */
+            taxAmount: number;
+        };
         ValidationResponse: {
             valid: boolean;
             errors: components["schemas"]["ValidationErrorItem"][];
@@ -117,12 +202,30 @@ export interface components {
                 /** Format: double */
                 tax?: number;
                 /** Format: double */
+                allowanceTotal?: number;
+                /** Format: double */
+                chargeTotal?: number;
+                /** Format: double */
                 grandTotal?: number;
+                /**
+                 * Format: double
+                 * @description Withholding tax the customer deducts from the payment
+                 */
+                withholdingTax?: number;
+                /**
+                 * Format: double
+                 * @description Amount due, grandTotal less withholdingTax
+                 */
+                payable?: number;
+                /** @description Taxable and tax amounts per tax category and rate, in first-seen line order */
+                byCategory?: components["schemas"]["TaxBreakdown"][];
             };
         };
         InvoiceIssued: {
             /** Format: uuid */
             invoiceId: string;
+            /** @description The draft's invoice number, or the tenant's next sequence number when the draft omits one; also the UBL cbc:ID */
+            invoiceNumber?: string;
             /** @enum {string} */
             status: "draft" | "issued" | "failed";
             /**
@@ -132,6 +235,18 @@ export interface components {
             xmlUrl: string;
             /** Format: uri */
             pdfUrl?: string;
+            /**
+             * @description Whether the invoice PDF exists; failed renders may be retried in the background
+             * @enum {string}
+             */
+            pdfStatus?: "rendered" | "skipped" | "failed";
+            /**
+             * Format: uri
+             * @description Signed URL of the XML-DSig signed invoice; present only when a signing key is configured
+             */
+            signedXmlUrl?: string;
+            /** @description Base64 SHA-256 digest of the unsigned invoice XML that the signature covers */
+            signatureDigest?: string;
             /** Format: date-time */
             expiresAt?: string;
         };
@@ -139,11 +254,16 @@ export interface components {
             /** Format: uuid */
             invoiceId: string;
             /** @enum {string} */
-            status: "draft" | "issued" | "failed";
+            status: "draft" | "issued" | "failed" | "voided";
             /** Format: uri */
             xmlUrl: string;
             /** Format: uri */
             pdfUrl?: string;
+            /**
+             * @description Whether the invoice PDF exists; failed renders may be retried in the background
+             * @enum {string}
+             */
+            pdfStatus?: "rendered" | "skipped" | "failed";
             /** Format: date-time */
             createdAt: string;
             /** Format: date-time */
@@ -164,6 +284,13 @@ export interface components {
             prevHash: string;
         };
         ValidationErrorResponse: {
+            /** @example VALIDATION_ERROR */
+            code: string;
+            message: string;
+            /** @description X-Correlation-Id of the failed request */
+            corrId: string;
+            retryable: boolean;
+            /** @description Per-field problems; empty when the request as a whole was rejected */
             errors: components["schemas"]["ValidationErrorItem"][];
         };
         ForbiddenError: {
@@ -171,22 +298,47 @@ export interface components {
             code: string;
             /** @example Tenant or role missing */
             message: string;
+            corrId: string;
+            retryable: boolean;
         };
         ConflictError: {
             /** @example CONFLICT */
             code: string;
             /** @example Duplicate invoice number */
             message: string;
+            corrId: string;
+            retryable: boolean;
+            /** @enum {string} */
+            conflictReason?: "idempotency_replay" | "idempotency_body_mismatch";
         };
         NotFoundError: {
             /** @example NOT_FOUND */
             code: string;
             message: string;
+            corrId: string;
+            retryable: boolean;
+        };
+        RequestTooLargeError: {
+            /** @example BATCH_TOO_LARGE */
+            code: string;
+            message: string;
+            corrId: string;
+            retryable: boolean;
+        };
+        RateLimitError: {
+            /** @example DOWNLOAD_CONCURRENCY */
+            code: string;
+            message: string;
+            corrId: string;
+            retryable: boolean;
+            /** @description Minimum seconds to wait before retry */
+            retryAfterSeconds: number;
         };
         InternalError: {
             /** @example INTERNAL_ERROR */
             code: string;
             message: string;
+            corrId: string;
             retryable: boolean;
         };
     };
@@ -207,6 +359,15 @@ export interface components {
                 "application/json": components["schemas"]["ForbiddenError"];
             };
         };
+        /** @description Request body exceeds MAX_REQUEST_BYTES (code BODY_TOO_LARGE) */
+        BodyTooLarge: {
+            headers: {
+                [name: string]: unknown;
+            };
+            content: {
+                "application/json": components["schemas"]["RequestTooLargeError"];
+            };
+        };
         /** @description Validation completed */
         ValidationCompleted: {
             headers: {
@@ -249,6 +410,8 @@ export interface components {
         CorrelationId: string;
         /** @description Tenant identifier for RBAC and storage segregation */
         TenantId: string;
+        /** @description Optional idempotency key. Same key + same draft returns the original response. Same key + different draft returns 409 conflictReason=idempotency_body_mismatch; a replay while the original is still being issued returns 409 conflictReason=idempotency_replay. */
+        IdempotencyKey: string;
     };
     requestBodies: never;
     headers: never;
@@ -285,6 +448,7 @@ export interface operations {
                 };
             };
             403: components["responses"]["Forbidden"];
+            413: components["responses"]["BodyTooLarge"];
             500: components["responses"]["InternalError"];
         };
     };
@@ -296,6 +460,8 @@ export interface operations {
                 "X-Correlation-Id": components["parameters"]["CorrelationId"];
                 /** @description Tenant identifier for RBAC and storage segregation */
                 "X-Tenant-Id": components["parameters"]["TenantId"];
+                /** @description Optional idempotency key. Same key + same draft returns the original response. Same key + different draft returns 409 conflictReason=idempotency_body_mismatch; a replay while the original is still being issued returns 409 conflictReason=idempotency_replay. */
+                "Idempotency-Key"?: components["parameters"]["IdempotencyKey"];
             };
             path?: never;
             cookie?: never;
@@ -317,7 +483,7 @@ export interface operations {
                 };
             };
             403: components["responses"]["Forbidden"];
-            /** @description Duplicate invoice number or conflict */
+            /** @description Duplicate invoice number, or an Idempotency-Key reused with a different draft or still in progress */
             409: {
                 headers: {
                     [name: string]: unknown;
@@ -326,6 +492,7 @@ export interface operations {
                     "application/json": components["schemas"]["ConflictError"];
                 };
             };
+            413: components["responses"]["BodyTooLarge"];
             500: components["responses"]["InternalError"];
         };
     };